@@ -0,0 +1,136 @@
+// Package anomaly flags the rows of a view whose main value changed by
+// much more than usual. For each row the mean and variance of the rate
+// of change of its metric (its latency or number of events) are kept
+// across the intervals, and a row is flagged when the latest rate is
+// more than the configured number of standard deviations above the
+// mean. Older intervals count for less so the idea of usual follows
+// the workload.
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+)
+
+const (
+	// Window is roughly the number of intervals the mean and variance
+	// are taken over
+	Window = 60
+	// MinIntervals is the number of intervals seen before a row may be flagged
+	MinIntervals = 10
+	// minSpread is the smallest standard deviation used, as a fraction
+	// of the mean, so a row which hardly varies isn't flagged for a
+	// small change
+	minSpread = 0.1
+)
+
+// stats holds the exponentially weighted mean and variance of a row's rate
+type stats struct {
+	count    int
+	mean     float64
+	variance float64
+}
+
+// add includes the rate in the mean and variance
+func (s *stats) add(rate float64) {
+	if s.count == 0 {
+		s.mean = rate
+	} else {
+		// the first intervals count equally until there are enough
+		alpha := math.Max(2.0/(Window+1), 1.0/float64(s.count+1))
+		diff := rate - s.mean
+		s.mean += alpha * diff
+		s.variance = (1 - alpha) * (s.variance + alpha*diff*diff)
+	}
+	s.count++
+}
+
+// deviations returns how many standard deviations the rate is above
+// the mean
+func (s stats) deviations(rate float64) float64 {
+	spread := math.Max(math.Sqrt(s.variance), minSpread*s.mean)
+	if spread <= 0 {
+		spread = 1
+	}
+
+	return (rate - s.mean) / spread
+}
+
+// previous holds the value of a row's metric when it was last seen
+type previous struct {
+	value     uint64
+	collected time.Time
+}
+
+// Detector finds the rows whose change is unusually large
+type Detector struct {
+	stddevs  float64                        // deviations above the mean flagged
+	stats    map[string]map[string]*stats   // by view and row name
+	previous map[string]map[string]previous // by view and row name
+	flagged  map[string]float64             // rows flagged by the last check and their deviations
+}
+
+// NewDetector returns a Detector which flags the rows more than stddevs
+// standard deviations above their mean, or does nothing if stddevs is 0
+func NewDetector(stddevs float64) *Detector {
+	return &Detector{
+		stddevs:  stddevs,
+		stats:    make(map[string]map[string]*stats),
+		previous: make(map[string]map[string]previous),
+		flagged:  make(map[string]float64),
+	}
+}
+
+// Enabled returns true if rows are checked for anomalies
+func (d *Detector) Enabled() bool {
+	return d != nil && d.stddevs > 0
+}
+
+// Check compares the change of each row of the view since it was last
+// checked with its usual change, and then includes it in the mean and
+// variance. A row whose value went down was reset so it only starts
+// again from its new value.
+func (d *Detector) Check(view string, rows []ps_table.RowValues, collected time.Time) {
+	if d.stats[view] == nil {
+		d.stats[view] = make(map[string]*stats)
+		d.previous[view] = make(map[string]previous)
+	}
+	d.flagged = make(map[string]float64)
+
+	for _, row := range rows {
+		metric := row_history.Metric(row.Values)
+		if metric == "" {
+			continue
+		}
+		value := row.Values[metric]
+		before, seen := d.previous[view][row.Name]
+		d.previous[view][row.Name] = previous{value: value, collected: collected}
+
+		seconds := collected.Sub(before.collected).Seconds()
+		if !seen || value < before.value || seconds <= 0 {
+			continue
+		}
+		rate := float64(value-before.value) / seconds
+
+		s := d.stats[view][row.Name]
+		if s == nil {
+			s = new(stats)
+			d.stats[view][row.Name] = s
+		}
+		if s.count >= MinIntervals {
+			if deviations := s.deviations(rate); deviations > d.stddevs {
+				d.flagged[row.Name] = deviations
+			}
+		}
+		s.add(rate)
+	}
+}
+
+// Flagged returns the rows flagged by the last check with the number of
+// standard deviations they are above their mean
+func (d *Detector) Flagged() map[string]float64 {
+	return d.flagged
+}
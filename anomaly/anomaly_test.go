@@ -0,0 +1,73 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// rows returns the rows with the given sum_timer_wait values
+func rows(values map[string]uint64) []ps_table.RowValues {
+	var r []ps_table.RowValues
+
+	for name, value := range values {
+		r = append(r, ps_table.RowValues{Name: name, Values: map[string]uint64{"count_star": 1, "sum_timer_wait": value}})
+	}
+
+	return r
+}
+
+func TestCheck(t *testing.T) {
+	d := NewDetector(3)
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	steady, spiky := uint64(0), uint64(0)
+
+	// a steady row and one which varies, each second
+	for i := 0; i <= 2*MinIntervals; i++ {
+		steady += 1000
+		spiky += uint64(200 + 1000*(i%2))
+		d.Check("view", rows(map[string]uint64{"steady": steady, "spiky": spiky}), start.Add(time.Duration(i)*time.Second))
+		if len(d.Flagged()) > 0 {
+			t.Fatalf("Check() interval %d flagged %v, want nothing as the rows are usual", i, d.Flagged())
+		}
+	}
+
+	// both change by 1500: unusual for the steady row, not so much for the varying one
+	steady += 1500
+	spiky += 1500
+	d.Check("view", rows(map[string]uint64{"steady": steady, "spiky": spiky}), start.Add(time.Duration(2*MinIntervals+1)*time.Second))
+	if _, ok := d.Flagged()["steady"]; !ok {
+		t.Errorf("Check() didn't flag the steady row, flagged %v", d.Flagged())
+	}
+	if _, ok := d.Flagged()["spiky"]; ok {
+		t.Errorf("Check() flagged the varying row, flagged %v", d.Flagged())
+	}
+
+	// a value which goes down has been reset and isn't flagged
+	d.Check("view", rows(map[string]uint64{"steady": 10, "spiky": spiky + 700}), start.Add(time.Duration(2*MinIntervals+2)*time.Second))
+	if len(d.Flagged()) > 0 {
+		t.Errorf("Check() after a reset flagged %v, want nothing", d.Flagged())
+	}
+}
+
+func TestCheckRate(t *testing.T) {
+	d := NewDetector(3)
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	value := uint64(0)
+	seconds := 0
+
+	for i := 0; i <= MinIntervals; i++ {
+		value += 1000
+		seconds++
+		d.Check("view", rows(map[string]uint64{"row": value}), start.Add(time.Duration(seconds)*time.Second))
+	}
+
+	// the same rate over a longer interval, e.g. after changing the interval, is usual
+	value += 5000
+	seconds += 5
+	d.Check("view", rows(map[string]uint64{"row": value}), start.Add(time.Duration(seconds)*time.Second))
+	if len(d.Flagged()) > 0 {
+		t.Errorf("Check() over a longer interval flagged %v, want nothing", d.Flagged())
+	}
+}
@@ -9,63 +9,182 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/anomaly"
+	"github.com/sjmudd/ps-top/binlog_events"
+	"github.com/sjmudd/ps-top/computed_column"
 	"github.com/sjmudd/ps-top/connector"
 	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/ddl_progress"
 	"github.com/sjmudd/ps-top/display"
 	"github.com/sjmudd/ps-top/event"
 	fsbi "github.com/sjmudd/ps-top/file_io_latency"
+	"github.com/sjmudd/ps-top/flavor"
+	"github.com/sjmudd/ps-top/follow_thread"
 	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/idle_time"
+	"github.com/sjmudd/ps-top/io_amplification"
+	"github.com/sjmudd/ps-top/key_cache"
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/local_process"
+	"github.com/sjmudd/ps-top/lock_users"
+	"github.com/sjmudd/ps-top/lock_waiters"
+	"github.com/sjmudd/ps-top/lock_waits"
 	"github.com/sjmudd/ps-top/logger"
 	"github.com/sjmudd/ps-top/memory_usage"
 	ewsgben "github.com/sjmudd/ps-top/mutex_latency"
 	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/prepared_statements"
+	"github.com/sjmudd/ps-top/priority"
+	"github.com/sjmudd/ps-top/program_latency"
+	"github.com/sjmudd/ps-top/proxy_backends"
+	"github.com/sjmudd/ps-top/proxy_digests"
+	"github.com/sjmudd/ps-top/proxysql"
+	"github.com/sjmudd/ps-top/ps_sizing"
+	"github.com/sjmudd/ps-top/query_cache"
+	"github.com/sjmudd/ps-top/row_filter"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/session_log"
+	"github.com/sjmudd/ps-top/setup_consumers"
 	"github.com/sjmudd/ps-top/setup_instruments"
+	"github.com/sjmudd/ps-top/slo_budget"
+	"github.com/sjmudd/ps-top/sort_keys"
 	essgben "github.com/sjmudd/ps-top/stages_latency"
+	"github.com/sjmudd/ps-top/statement_stages"
+	"github.com/sjmudd/ps-top/statements_digest"
+	"github.com/sjmudd/ps-top/status_watch"
+	"github.com/sjmudd/ps-top/table_cache"
 	tiwsbt "github.com/sjmudd/ps-top/table_io_latency"
 	tlwsbt "github.com/sjmudd/ps-top/table_lock_latency"
+	"github.com/sjmudd/ps-top/threshold"
+	"github.com/sjmudd/ps-top/ui_script"
+	"github.com/sjmudd/ps-top/unused_indexes"
 	"github.com/sjmudd/ps-top/user_latency"
+	"github.com/sjmudd/ps-top/user_view"
 	"github.com/sjmudd/ps-top/view"
+	"github.com/sjmudd/ps-top/wait_events"
 	"github.com/sjmudd/ps-top/wait_info"
+	"github.com/sjmudd/ps-top/watch_table"
+	"github.com/sjmudd/ps-top/watchdog"
+	"github.com/sjmudd/ps-top/workload_fingerprint"
 )
 
+// maxTabs is the most views which can be open as tabs at once
+const maxTabs = 3
+
 // Flags for initialising the app
 type Settings struct {
+	Absolute  bool // start showing absolute rather than relative statistics
 	Anonymise bool
 	Conn      *connector.Connector
 	Interval  int
 	Count     int
 	Stdout    bool
 	View      string
+	Sort      string // sort keys for the initial view (overrides ~/.pstoprc)
+	Filter    string // filter for the initial view (overrides ~/.pstoprc)
+	Follow    uint64 // processlist id of a connection to follow on startup
+	Watch     string // table to watch, as db.table, on startup
+	Tabs      string // views open as tabs on startup, separated by commas
+	Process   bool   // show the OS statistics of a mysqld on this host
 	Disp      display.Display
+	Schemas   *schema_filter.Filter // schemas the table based views are restricted to
+	Audit     string                // file the user's actions are logged to (optional)
+	Warmup    time.Duration         // wait between the first two collections
+	Title     string                // shown in the header to describe what is being watched
+	Restore   bool                  // restore the setup_instruments settings left by a killed run
+	Refresh   time.Duration         // how often the screen is redrawn between collections, 0 for never
+	Enforce   bool                  // kill the statements matching a watchdog kill rule
+	MaxKills  int                   // most statements the watchdog kills a minute
+	Watchdog  string                // file the watchdog's actions are logged to (optional)
+	Script    []ui_script.Command   // commands run as the data is collected (optional)
+	Workload  string                // file the workload fingerprint is written to on exit (optional)
+	Anomalies float64               // mark rows changing by this many standard deviations above their mean, 0 for never
+	Proxy     *sql.DB               // the ProxySQL admin interface (optional)
+	Changes   bool                  // the display writes the changes of the view's row values
 }
 
 // App holds the data needed by an application
 type App struct {
-	ctx      *context.Context
-	count    int
-	display  display.Display
-	done     chan struct{}
-	sigChan  chan os.Signal
-	wi       wait_info.WaitInfo
-	finished bool
-	stdout   bool
-	dbh      *sql.DB
-	help     bool
-	fsbi     ps_table.Tabler // *ufsbi.File_summary_by_instance
+	ctx                *context.Context
+	count              int
+	refresh            time.Duration // how often the screen is redrawn between collections
+	display            display.Display
+	done               chan struct{}
+	sigChan            chan os.Signal
+	wi                 wait_info.WaitInfo             // used for views without their own interval
+	viewWaits          map[string]*wait_info.WaitInfo // views with their own interval, by name
+	finished           bool
+	stdout             bool
+	dbh                *sql.DB
+	help               bool
+	instruments        bool            // show the instruments screen
+	instrumentsMessage string          // result of the last instrument change
+	about              bool            // show the about screen
+	info               bool            // show the server's configuration
+	consumers          bool            // show the consumers screen
+	consumersMessage   string          // result of the last consumer change
+	history            string          // name of the row whose history is shown, if any
+	fsbi               ps_table.Tabler // *ufsbi.File_summary_by_instance
 	tiwsbt/* ps_table.Tabler */ *tiwsbt.Object
-	tlwsbt             ps_table.Tabler // tlwsbt.Table_lock_waits_summary_by_table
-	ewsgben            ps_table.Tabler // ewsgben.Events_waits_summary_global_by_event_name
-	essgben            ps_table.Tabler // essgben.Events_stages_summary_global_by_event_name
-	memory             ps_table.Tabler // memory_usage.Object
-	users              ps_table.Tabler // user_latency.Object
+	tlwsbt             ps_table.Tabler               // tlwsbt.Table_lock_waits_summary_by_table
+	ewsgben            ps_table.Tabler               // ewsgben.Events_waits_summary_global_by_event_name
+	essgben            ps_table.Tabler               // essgben.Events_stages_summary_global_by_event_name
+	memory             ps_table.Tabler               // memory_usage.Object
+	users              ps_table.Tabler               // user_latency.Object
+	lockWaits          ps_table.Tabler               // lock_waits.Object
+	lockWaiters        ps_table.Tabler               // lock_waiters.Object
+	efficiency         ps_table.Tabler               // statements_digest.Object
+	tableCache         ps_table.Tabler               // table_cache.Object
+	keyCache           ps_table.Tabler               // key_cache.Object
+	queryCache         ps_table.Tabler               // query_cache.Object
+	statementStages    ps_table.Tabler               // statement_stages.Object
+	unusedIndexes      ps_table.Tabler               // unused_indexes.Object
+	binlogEvents       ps_table.Tabler               // binlog_events.Object
+	ddlProgress        ps_table.Tabler               // ddl_progress.Object
+	lockUsers          ps_table.Tabler               // lock_users.Object
+	psSizing           ps_table.Tabler               // ps_sizing.Object
+	programs           ps_table.Tabler               // program_latency.Object
+	sloBudget          ps_table.Tabler               // slo_budget.Object
+	prepared           ps_table.Tabler               // prepared_statements.Object
+	waitEvents         ps_table.Tabler               // wait_events.Object
+	idleTime           ps_table.Tabler               // idle_time.Object
+	proxyDigests       ps_table.Tabler               // proxy_digests.Object
+	proxyBackends      ps_table.Tabler               // proxy_backends.Object
+	userViews          map[view.Code]ps_table.Tabler // user_view.Object for each view in ~/.pstoprc
+	collectErrors      map[ps_table.Tabler]error     // the last collection of these tables failed
+	follow             *follow_thread.Object         // the connection being followed, if any
+	watch              *watch_table.Object           // the table watched, if any
+	tabs               []view.Code                   // views open as tabs, collected together
+	selfStats          *self_stats.Stats             // how ps-top itself is performing
+	lastWaitInfo       *wait_info.WaitInfo           // schedule of the last collection
+	sessionLog         *session_log.Log              // the user's actions are recorded here (if set)
+	flavor             flavor.Flavor                 // the kind of server, e.g. TiDB
 	currentView        view.View
 	wait_info.WaitInfo // embedded
 	setupInstruments   setup_instruments.SetupInstruments
+	setupConsumers     *setup_consumers.SetupConsumers
+	thresholds         *threshold.Watcher
+	watchdog           *watchdog.Watchdog
+	watchdogLog        *session_log.Log // the watchdog's actions are recorded here (if set)
+	rowHistory         *row_history.History
+	rowLengths         io_amplification.RowLengths // average row lengths for the file I/O per row
+	script             []ui_script.Command         // commands of the --script still to run
+	scriptWait         int                         // collections to wait for before running the script
+	serverInfo         server_info.Info            // the server's configuration shown by the info screen
+	fingerprint        string                      // file the workload fingerprint is written to on exit
+	anomalies          *anomaly.Detector           // finds the rows whose change is unusually large
+	priority           priority.Weights            // weights of the score the rows are ordered by with P
+	statusWatch        *status_watch.Watch         // the status variables shown under the header
+	proxy              *sql.DB                     // the ProxySQL admin interface, if given
 }
 
 // ensure performance_schema is enabled
@@ -96,29 +215,99 @@ func NewApp(settings Settings) *App {
 	variables := global.NewVariables(app.dbh)
 	// Prior to setting up screen check that performance_schema is enabled.
 	// On MariaDB this is not the default setting so it will confuse people.
-	ensurePerformanceSchemaEnabled(variables)
+	// TiDB and Vitess only provide some of the tables whatever the setting.
+	app.flavor = flavor.Detect(variables.Get("version"), variables.Get("version_comment"))
+	if app.flavor.Partial() {
+		logger.Println("app.NewApp() connected to", app.flavor.String(), "so only the views whose tables exist are shown")
+	} else {
+		ensurePerformanceSchemaEnabled(variables)
+	}
 
+	lib.SetNameCase(variables.Get("lower_case_table_names"), variables.Get("lower_case_file_system"))
 	app.ctx = context.NewContext(status, variables)
-	app.ctx.SetWantRelativeStats(true)
+	if proxysql.Detect(app.dbh) {
+		logger.Println("app.NewApp() connected through", proxysql.Name)
+		app.ctx.SetProxy(proxysql.Name)
+	}
+	app.proxy = settings.Proxy
+	app.ctx.SetWantRelativeStats(!settings.Absolute)
+	app.ctx.SetSchemaFilter(settings.Schemas)
+	app.ctx.SetTitle(settings.Title)
+	if settings.Process {
+		p, err := local_process.Find(variables.Get("pid_file"), variables.Get("datadir"))
+		if err != nil {
+			log.Fatal("Unable to find the mysqld process on this host: ", err)
+		}
+		app.ctx.SetProcess(p)
+	}
 	app.count = settings.Count
+	app.script = settings.Script
+	app.fingerprint = settings.Workload
 	app.finished = false
 
 	app.stdout = settings.Stdout
+	app.refresh = settings.Refresh
 	app.display = settings.Disp
 	app.display.SetContext(app.ctx)
 	app.SetHelp(false)
 
-	if err := view.ValidateViews(app.dbh); err != nil {
+	if err := view.ValidateViews(app.dbh, app.proxy); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkScript(app.script); err != nil {
+		app.display.Close() // give the terminal back before exiting
 		log.Fatal(err)
 	}
 
+	if settings.Tabs != "" {
+		for _, name := range strings.Split(settings.Tabs, ",") {
+			code, ok := view.CodeByName(strings.TrimSpace(name))
+			if !ok || !view.IsSelectable(code) {
+				log.Fatalf("--tabs: %q is not a view which can be shown", name)
+			}
+			app.openTab(code)
+		}
+		app.ctx.SetTabs(app.tabNames())
+	}
+
 	logger.Println("app.Setup() Setting the default view to:", settings.View)
 	app.currentView.SetByName(settings.View) // if empty will use the default
+	if settings.View == "" && len(app.tabs) > 0 {
+		app.currentView.Set(app.tabs[0])
+	}
+	app.ctx.SetViewNumber(app.currentView.Number())
+	app.ctx.SetViewName(app.currentView.Name())
+	if settings.Sort != "" {
+		sort_keys.Set(app.currentView.Name(), sort_keys.Parse(settings.Sort))
+	}
+	if err := sort_keys.Check(); err != nil {
+		log.Fatal(err)
+	}
+	if settings.Filter != "" {
+		row_filter.Set(app.currentView.Name(), settings.Filter)
+	}
 
 	app.setupInstruments = setup_instruments.NewSetupInstruments(app.dbh)
-	app.setupInstruments.EnableMonitoring()
+	app.setupConsumers = setup_consumers.NewSetupConsumers(app.dbh)
+	if !app.flavor.Partial() {
+		app.setupInstruments.SetServer(variables.Get("hostname") + ":" + variables.Get("port"))
+		app.checkStaleInstruments(settings.Restore)
+		app.setupInstruments.EnableMonitoring()
+	}
 
 	app.wi.SetWaitInterval(time.Second * time.Duration(settings.Interval))
+	app.thresholds = threshold.NewWatcher(app.display)
+	app.anomalies = anomaly.NewDetector(settings.Anomalies)
+	app.priority = priority.Configured()
+	app.statusWatch = status_watch.NewWatch()
+	app.rowHistory = row_history.NewHistory()
+	app.viewWaits = make(map[string]*wait_info.WaitInfo)
+	if !app.stdout {
+		for name, interval := range wait_info.Intervals() {
+			app.viewWaits[name] = new(wait_info.WaitInfo)
+			app.viewWaits[name].SetWaitInterval(interval)
+		}
+	}
 
 	// setup to their initial types/values
 	logger.Println("app.NewApp() Setup models")
@@ -129,7 +318,66 @@ func NewApp(settings Settings) *App {
 	app.essgben = essgben.NewStagesLatency(app.ctx)
 	app.memory = memory_usage.NewMemoryUsage(app.ctx)
 	app.users = user_latency.NewUserLatency(app.ctx)
+	app.lockWaits = lock_waits.NewLockWaits(app.ctx)
+	app.lockWaiters = lock_waiters.NewLockWaiters(app.ctx)
+	app.efficiency = statements_digest.NewStatementsDigest(app.ctx)
+	app.tableCache = table_cache.NewTableCache(app.ctx)
+	app.keyCache = key_cache.NewKeyCache(app.ctx)
+	app.queryCache = query_cache.NewQueryCache(app.ctx)
+	app.statementStages = statement_stages.NewStatementStages(app.ctx)
+	app.unusedIndexes = unused_indexes.NewUnusedIndexes(app.ctx)
+	app.binlogEvents = binlog_events.NewBinlogEvents(app.ctx)
+	app.ddlProgress = ddl_progress.NewDDLProgress(app.ctx)
+	app.lockUsers = lock_users.NewLockUsers(app.ctx)
+	app.psSizing = ps_sizing.NewPSSizing(app.ctx)
+	app.programs = program_latency.NewProgramLatency(app.ctx)
+	app.sloBudget = slo_budget.NewSLOBudget(app.ctx, app.tiwsbt)
+	app.prepared = prepared_statements.NewPreparedStatements(app.ctx)
+	app.waitEvents = wait_events.NewWaitEvents(app.ctx)
+	app.idleTime = idle_time.NewIdleTime(app.ctx)
+	app.proxyDigests = proxy_digests.NewProxyDigests(app.ctx, app.proxy)
+	app.proxyBackends = proxy_backends.NewProxyBackends(app.ctx, app.proxy)
+	app.userViews = make(map[view.Code]ps_table.Tabler)
+	app.collectErrors = make(map[ps_table.Tabler]error)
+	app.selfStats = self_stats.NewStats()
+	if settings.Audit != "" {
+		sessionLog, err := session_log.Open(settings.Audit)
+		if err != nil {
+			log.Fatal("Unable to open the session log: ", err)
+		}
+		app.sessionLog = sessionLog
+		app.sessionLog.Record("start", fmt.Sprintf("%s %s on %s (MySQL %s) view: %s filter: %q sort: %q title: %q",
+			lib.MyName(), app.ctx.Version(), app.ctx.Hostname(), app.ctx.MySQLVersion(), app.currentView.Name(), settings.Filter, settings.Sort, settings.Title))
+	}
+	app.watchdogLog = app.sessionLog
+	if settings.Watchdog != "" {
+		watchdogLog, err := session_log.Open(settings.Watchdog)
+		if err != nil {
+			log.Fatal("Unable to open the watchdog log: ", err)
+		}
+		app.watchdogLog = watchdogLog
+	}
+	app.watchdog = watchdog.NewWatchdog(settings.Enforce, settings.MaxKills, app.watchdogLog)
+	if settings.Enforce && app.watchdogLog == nil {
+		log.Fatal("--enforce needs --watchdog-log or --session-log so the statements killed are recorded")
+	}
+	if settings.Follow > 0 {
+		app.follow = follow_thread.NewFollowThread(app.ctx, settings.Follow)
+	}
+	if settings.Watch != "" {
+		watch, err := watch_table.NewWatchTable(app.ctx, settings.Watch)
+		if err != nil {
+			log.Fatal(err)
+		}
+		app.watch = watch
+	}
+	for code, definition := range view.UserViews() {
+		app.userViews[code] = user_view.NewUserView(app.ctx, definition)
+	}
 	logger.Println("app.NewApp() Finished initialising models")
+	if _, ok := app.currentTable().(ps_table.Valuer); settings.Changes && !ok {
+		log.Fatal("View ", app.currentView.Name(), " does not provide row values so can't be used with --changes")
+	}
 
 	logger.Println("app.NewApp() fixLatencySetting()")
 	app.fixLatencySetting() // adjust to see ops/latency
@@ -137,6 +385,15 @@ func NewApp(settings Settings) *App {
 	logger.Println("app.NewApp() resetDBStatistics()")
 	app.resetDBStatistics()
 
+	// collect again after a short wait so the first data shown has
+	// something to compare against rather than showing only zeros.
+	// Absolute values, e.g. with --once, don't need it.
+	if settings.Warmup > 0 && !settings.Absolute {
+		logger.Println("app.NewApp() warming up for", settings.Warmup)
+		time.Sleep(settings.Warmup)
+		app.collectAll()
+	}
+
 	logger.Println("app.NewApp() finishes")
 	return app
 }
@@ -149,16 +406,109 @@ func (app App) Finished() bool {
 // CollectAll collects all the stats together in one go
 func (app *App) collectAll() {
 	logger.Println("app.collectAll() start")
-	app.fsbi.Collect(app.dbh)
-	app.tlwsbt.Collect(app.dbh)
-	app.tiwsbt.Collect(app.dbh)
-	app.users.Collect(app.dbh)
-	app.essgben.Collect(app.dbh)
-	app.ewsgben.Collect(app.dbh)
-	app.memory.Collect(app.dbh)
+	app.collect(app.fsbi)
+	app.collect(app.tlwsbt)
+	app.collect(app.tiwsbt)
+	app.collect(app.users)
+	app.collect(app.essgben)
+	app.collect(app.ewsgben)
+	app.collect(app.memory)
+	if view.IsSelectable(view.ViewLockWaits) {
+		app.collect(app.lockWaits)
+	}
+	app.collect(app.efficiency)
+	if view.IsSelectable(view.ViewTableCache) {
+		app.collect(app.tableCache)
+	}
+	if view.IsSelectable(view.ViewKeyCache) {
+		app.collect(app.keyCache)
+	}
+	if view.IsSelectable(view.ViewQueryCache) {
+		app.collect(app.queryCache)
+	}
+	if view.IsSelectable(view.ViewStmtStages) {
+		app.collect(app.statementStages)
+	}
+	if view.IsSelectable(view.ViewUnusedIdx) {
+		app.collect(app.unusedIndexes)
+	}
+	if view.IsSelectable(view.ViewBinlog) {
+		app.collect(app.binlogEvents)
+	}
+	if view.IsSelectable(view.ViewDDL) {
+		app.collect(app.ddlProgress)
+	}
+	if view.IsSelectable(view.ViewLockUsers) {
+		app.collect(app.lockUsers)
+	}
+	if view.IsSelectable(view.ViewPSSizing) {
+		app.collect(app.psSizing)
+	}
+	if view.IsSelectable(view.ViewPrograms) {
+		app.collect(app.programs)
+	}
+	if view.IsSelectable(view.ViewSLO) {
+		app.collect(app.sloBudget)
+	}
+	if view.IsSelectable(view.ViewPrepared) {
+		app.collect(app.prepared)
+	}
+	if view.IsSelectable(view.ViewWaits) {
+		app.collect(app.waitEvents)
+	}
+	if view.IsSelectable(view.ViewIdle) {
+		app.collect(app.idleTime)
+	}
+	if view.IsSelectable(view.ViewProxyQuery) {
+		app.collect(app.proxyDigests)
+	}
+	if view.IsSelectable(view.ViewProxyConns) {
+		app.collect(app.proxyBackends)
+	}
+	for code := range app.userViews {
+		if view.IsSelectable(code) {
+			app.collect(app.userViews[code])
+		}
+	}
+	if app.watch != nil {
+		app.collect(app.watch)
+	}
 	logger.Println("app.collectAll() finished")
 }
 
+// collect collects the data of a table. If that fails the error is kept
+// so the previous data can be shown as stale until a collection succeeds.
+func (app *App) collect(table ps_table.Tabler) error {
+	start := time.Now()
+	err := table.Collect(app.dbh)
+	health := ps_table.Health{Collected: start, Took: time.Since(start), Err: err}
+	if err == nil {
+		health.Rows = table.Len()
+	}
+	if healther, ok := table.(ps_table.Healther); ok {
+		healther.SetHealth(health)
+	}
+	app.selfStats.Collected(collectorName(table), health.Took, health.Rows, err)
+	if err != nil {
+		logger.Println("app.collect() failed, keeping the previous data:", err)
+		app.collectErrors[table] = err
+		return err
+	}
+	delete(app.collectErrors, table)
+
+	return nil
+}
+
+// collectorName returns the name of the package collecting the table,
+// which is used in ps-top's own statistics
+func collectorName(table ps_table.Tabler) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", table), "*")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
 // do a fresh collection of data and then update the initial values based on that.
 func (app *App) resetDBStatistics() {
 	logger.Println("app.resetDBStatistcs()")
@@ -175,32 +525,190 @@ func (app *App) setInitialFromCurrent() {
 	app.essgben.SetInitialFromCurrent()
 	app.ewsgben.SetInitialFromCurrent()
 	app.memory.SetInitialFromCurrent()
+	app.lockWaits.SetInitialFromCurrent()
+	app.lockWaiters.SetInitialFromCurrent()
+	app.efficiency.SetInitialFromCurrent()
+	app.tableCache.SetInitialFromCurrent()
+	app.keyCache.SetInitialFromCurrent()
+	app.queryCache.SetInitialFromCurrent()
+	app.statementStages.SetInitialFromCurrent()
+	app.unusedIndexes.SetInitialFromCurrent()
+	app.binlogEvents.SetInitialFromCurrent()
+	app.ddlProgress.SetInitialFromCurrent()
+	app.lockUsers.SetInitialFromCurrent()
+	app.psSizing.SetInitialFromCurrent()
+	app.programs.SetInitialFromCurrent()
+	app.sloBudget.SetInitialFromCurrent()
+	app.prepared.SetInitialFromCurrent()
+	app.waitEvents.SetInitialFromCurrent()
+	app.idleTime.SetInitialFromCurrent()
+	app.proxyDigests.SetInitialFromCurrent()
+	app.proxyBackends.SetInitialFromCurrent()
+	for code := range app.userViews {
+		app.userViews[code].SetInitialFromCurrent()
+	}
+	if app.watch != nil {
+		app.watch.SetInitialFromCurrent()
+	}
 	logger.Println("app.setInitialFromCurrent() took", time.Duration(time.Since(start)).String())
 }
 
+// do a fresh collection of data and then mark the values collected as
+// an additional comparison point, leaving the initial values alone.
+func (app *App) markDBStatistics() {
+	logger.Println("app.markDBStatistics()")
+	app.collectAll()
+	for _, table := range []ps_table.Tabler{app.fsbi, app.tlwsbt, app.tiwsbt, app.essgben, app.ewsgben, app.efficiency, app.programs, app.prepared, app.waitEvents, app.idleTime, app.proxyDigests, app.proxyBackends} {
+		if marker, ok := table.(ps_table.Marker); ok {
+			marker.SetMarkFromCurrent()
+		}
+	}
+}
+
 // Collect the data we are looking at.
 func (app *App) Collect() {
 	logger.Println("app.Collect()")
 	start := time.Now()
 
-	switch app.currentView.Get() {
+	if p := app.ctx.Process(); p != nil {
+		if err := p.Collect(); err != nil {
+			logger.Println("app.Collect() failed to collect the mysqld process statistics:", err)
+		}
+	}
+	if app.wantAmplification() {
+		app.collect(app.fsbi)
+	}
+	table := app.currentTable()
+	if table != nil {
+		app.ctx.SetAnomalies(nil)
+		err := app.collect(table)
+		if valuer, ok := table.(ps_table.Valuer); ok && err == nil {
+			values := valuer.Values()
+			app.rowHistory.Record(app.currentView.Name(), values, time.Now())
+			if app.thresholds.Enabled() {
+				app.thresholds.Check(app.currentView.Name(), values)
+			}
+			if app.anomalies.Enabled() {
+				app.anomalies.Check(app.currentView.Name(), values, time.Now())
+				app.ctx.SetAnomalies(app.anomalies.Flagged())
+			}
+		}
+	}
+	// the other tabs are collected too so switching to them is instant
+	collected := map[ps_table.Tabler]bool{table: true}
+	for _, code := range app.tabs {
+		if tab := app.table(code); tab != nil && !collected[tab] {
+			collected[tab] = true
+			app.collect(tab)
+		}
+	}
+	if app.watchdog.Enabled() {
+		if err := app.watchdog.Check(app.dbh); err != nil {
+			logger.Println("app.Collect() the watchdog failed to check the connections:", err)
+		}
+		app.ctx.SetWatchdog(app.watchdog.Summary())
+	}
+	app.ctx.SetAlert(app.thresholds.Breached() || app.watchdog.Matched())
+	if app.statusWatch.Enabled() {
+		if err := app.statusWatch.Collect(app.ctx.Status()); err != nil {
+			logger.Println("app.Collect() unable to collect the watched status variables:", err)
+		}
+		app.ctx.SetStatusWatch(app.statusWatch.Items())
+	}
+	// count the updates missed while collecting with the same schedule
+	if wi := app.waitInfo(); wi == app.lastWaitInfo {
+		app.selfStats.Dropped(wi.Missed())
+	}
+	app.waitInfo().CollectedNow()
+	app.ctx.SetCollected(time.Now())
+	app.ctx.SetCollectors(app.selfStats.Collectors())
+	app.lastWaitInfo = app.waitInfo()
+	logger.Println("app.Collect() took", time.Duration(time.Since(start)).String())
+}
+
+// currentTable returns the table used by the current view, or nil if
+// there isn't one
+func (app *App) currentTable() ps_table.Tabler {
+	if app.follow != nil {
+		return app.follow
+	}
+	if app.watch != nil {
+		return app.watch
+	}
+	return app.table(app.currentView.Get())
+}
+
+// table returns the table used by the view, or nil if there isn't one
+func (app *App) table(code view.Code) ps_table.Tabler {
+	switch code {
 	case view.ViewLatency, view.ViewOps:
-		app.tiwsbt.Collect(app.dbh)
+		return app.tiwsbt
 	case view.ViewIO:
-		app.fsbi.Collect(app.dbh)
+		return app.fsbi
 	case view.ViewLocks:
-		app.tlwsbt.Collect(app.dbh)
+		if app.ctx.WantLockWaiters() {
+			return app.lockWaiters
+		}
+		return app.tlwsbt
 	case view.ViewUsers:
-		app.users.Collect(app.dbh)
+		return app.users
 	case view.ViewMutex:
-		app.ewsgben.Collect(app.dbh)
+		return app.ewsgben
 	case view.ViewStages:
-		app.essgben.Collect(app.dbh)
+		return app.essgben
 	case view.ViewMemory:
-		app.memory.Collect(app.dbh)
+		return app.memory
+	case view.ViewLockWaits:
+		return app.lockWaits
+	case view.ViewEfficiency:
+		return app.efficiency
+	case view.ViewTableCache:
+		return app.tableCache
+	case view.ViewKeyCache:
+		return app.keyCache
+	case view.ViewQueryCache:
+		return app.queryCache
+	case view.ViewStmtStages:
+		return app.statementStages
+	case view.ViewUnusedIdx:
+		return app.unusedIndexes
+	case view.ViewBinlog:
+		return app.binlogEvents
+	case view.ViewDDL:
+		return app.ddlProgress
+	case view.ViewLockUsers:
+		return app.lockUsers
+	case view.ViewPSSizing:
+		return app.psSizing
+	case view.ViewPrograms:
+		return app.programs
+	case view.ViewSLO:
+		return app.sloBudget
+	case view.ViewPrepared:
+		return app.prepared
+	case view.ViewWaits:
+		return app.waitEvents
+	case view.ViewIdle:
+		return app.idleTime
+	case view.ViewProxyQuery:
+		return app.proxyDigests
+	case view.ViewProxyConns:
+		return app.proxyBackends
 	}
-	app.wi.CollectedNow()
-	logger.Println("app.Collect() took", time.Duration(time.Since(start)).String())
+	if userView, ok := app.userViews[code]; ok {
+		return userView
+	}
+	return nil
+}
+
+// waitInfo returns the WaitInfo which schedules collection of the
+// current view. Views with their own interval in ~/.pstoprc are
+// collected independently so expensive views can be polled less often.
+func (app *App) waitInfo() *wait_info.WaitInfo {
+	if wi, ok := app.viewWaits[app.currentView.Name()]; ok {
+		return wi
+	}
+	return &app.wi
 }
 
 // SetHelp determines if we need to display help
@@ -210,33 +718,338 @@ func (app *App) SetHelp(newHelp bool) {
 	app.display.ClearScreen()
 }
 
+// SetInstruments determines if we need to display the instruments screen
+func (app *App) SetInstruments(instruments bool) {
+	app.instruments = instruments
+	app.instrumentsMessage = ""
+
+	app.display.ClearScreen()
+}
+
+// SetConsumers determines if we need to display the consumers screen
+func (app *App) SetConsumers(consumers bool) {
+	app.consumers = consumers
+	app.consumersMessage = ""
+
+	app.display.ClearScreen()
+}
+
+// enableConsumers enables the consumers needed by the current view
+// which are disabled
+func (app *App) enableConsumers() {
+	enabled, err := app.setupConsumers.EnableMissing(app.currentView.Name())
+	app.setupInstruments.SetConsumers(app.setupConsumers.Enabled())
+	if err != nil {
+		app.consumersMessage = err.Error()
+	} else if len(enabled) == 0 {
+		app.consumersMessage = "All the consumers needed are enabled"
+	} else {
+		app.consumersMessage = "Enabled: " + strings.Join(enabled, ", ")
+	}
+	app.Display()
+}
+
+// SetAbout determines if we need to display the about screen
+func (app *App) SetAbout(about bool) {
+	app.about = about
+
+	app.display.ClearScreen()
+}
+
+// SetInfo determines if we need to display the server's configuration,
+// collecting it when it is shown
+func (app *App) SetInfo(info bool) {
+	app.info = info
+	if info {
+		app.serverInfo = server_info.Collect(app.dbh)
+	}
+
+	app.display.ClearScreen()
+}
+
+// checkStaleInstruments looks for the setup_instruments settings left
+// changed by an earlier run which was killed. They are restored if
+// wanted, otherwise ps-top starts on the instruments screen offering to
+// restore them and ps-stats warns about them.
+func (app *App) checkStaleInstruments(restore bool) {
+	stale := app.setupInstruments.Stale()
+	switch {
+	case stale == "":
+		return
+	case restore:
+		if err := app.setupInstruments.RestoreStale(); err != nil {
+			log.Fatal("Unable to restore setup_instruments: ", err)
+		}
+		logger.Println("app.checkStaleInstruments() restored:", stale)
+	case app.stdout:
+		fmt.Fprintln(os.Stderr, "Warning:", stale+". Use --restore-instruments to restore them.")
+	default:
+		app.instruments = true
+		app.instrumentsMessage = stale + ". Press R to restore them."
+	}
+}
+
+// restoreInstruments restores the setup_instruments settings left
+// changed by an earlier run and enables the instruments we need again
+func (app *App) restoreInstruments() {
+	stale := app.setupInstruments.Stale()
+	if stale == "" {
+		app.instrumentsMessage = "There are no settings left by an earlier run to restore"
+	} else if err := app.setupInstruments.RestoreStale(); err != nil {
+		app.instrumentsMessage = err.Error()
+	} else {
+		app.setupInstruments.EnableMonitoring()
+		app.instrumentsMessage = "Restored: " + stale
+	}
+	app.Display()
+}
+
+// toggle the instrument family with the given number and show the result
+func (app *App) toggleInstrumentFamily(number int) {
+	if err := app.setupInstruments.ToggleFamily(number); err != nil {
+		app.instrumentsMessage = err.Error()
+	} else {
+		app.instrumentsMessage = fmt.Sprintf("Instrument family %d changed", number)
+	}
+	app.Display()
+}
+
+// toggleFollow starts following the connection of the first statement
+// shown in the user_latency view, or stops following a connection
+func (app *App) toggleFollow() {
+	if app.follow != nil {
+		delete(app.collectErrors, app.follow)
+		app.follow = nil
+	} else if users, ok := app.users.(*user_latency.Object); ok && app.currentView.Get() == view.ViewUsers {
+		if id, found := users.FollowID(); found {
+			app.follow = follow_thread.NewFollowThread(app.ctx, id)
+			app.collect(app.follow)
+		}
+	}
+	app.display.ClearScreen()
+	app.Display()
+}
+
+// changeConnectionsPage shows another page of the connections when
+// they are being listed in the user_latency view
+func (app *App) changeConnectionsPage(change int) {
+	if !app.ctx.WantConnections() {
+		return
+	}
+	app.ctx.SetConnectionsPage(app.ctx.ConnectionsPage() + change)
+	app.collect(app.users)
+	if users, ok := app.users.(*user_latency.Object); ok {
+		app.ctx.SetConnectionsPage(users.Page()) // there may be fewer pages
+	}
+	app.display.ClearScreen()
+	app.Display()
+}
+
+// changeObjectType changes the table I/O views from showing all tables
+// to only tables, only temporary tables and back
+func (app *App) changeObjectType() {
+	switch app.ctx.ObjectType() {
+	case context.AllTables:
+		app.ctx.SetObjectType(context.Tables)
+	case context.Tables:
+		app.ctx.SetObjectType(context.TemporaryTables)
+	default:
+		app.ctx.SetObjectType(context.AllTables)
+	}
+	app.ctx.SetSelectedRow(0)
+	app.history = ""
+	app.collect(app.tiwsbt)
+	app.display.ClearScreen()
+	app.Display()
+}
+
+// objectTypeName returns how the type of tables shown is recorded
+func objectTypeName(objectType string) string {
+	switch objectType {
+	case context.Tables:
+		return "tables"
+	case context.TemporaryTables:
+		return "temporary"
+	}
+
+	return "all"
+}
+
+// wantAmplification returns true if the file I/O per row should be
+// shown with the current view
+func (app *App) wantAmplification() bool {
+	v := app.currentView.Get()
+	return app.ctx.WantAmplification() && (v == view.ViewLatency || v == view.ViewOps)
+}
+
+// shownRowNames returns the names of the rows of the current view in
+// the order they are shown, nil if the view doesn't provide them
+func (app *App) shownRowNames() []string {
+	table := app.currentTable()
+	resulter, ok := table.(ps_table.Resulter)
+	if !ok {
+		return nil
+	}
+	results := resulter.Results()
+	content := table.RowContent()
+	if len(results) != len(content) {
+		return nil
+	}
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	if app.ctx.WantPriority() {
+		order = app.priorityOrder(results)
+	}
+
+	re := row_filter.Configured(app.currentView.Name())
+	var names []string
+	for _, i := range order {
+		if re == nil || row_filter.Matches(re, content[i]) {
+			names = append(names, results[i].Name)
+		}
+	}
+
+	return names
+}
+
+// priorityOrder returns the order of the rows of the current view by
+// their priority, their growth coming from their recent history
+func (app *App) priorityOrder(results []ps_table.RowValues) []int {
+	growth := func(name string) float64 {
+		return priority.Growth(app.rowHistory.Series(app.currentView.Name(), name).Changes)
+	}
+
+	return priority.Order(results, growth, app.priority)
+}
+
+// selectRow moves the row selected up or down, the history shown (if
+// any) following it
+func (app *App) selectRow(change int) {
+	if app.help || app.instruments || app.consumers || app.about || app.info {
+		return
+	}
+	names := app.shownRowNames()
+	selected := app.ctx.SelectedRow() + change
+	if selected > len(names) {
+		selected = len(names)
+	}
+	app.ctx.SetSelectedRow(selected)
+	if app.history != "" {
+		app.history = ""
+		if app.ctx.SelectedRow() > 0 {
+			app.history = names[app.ctx.SelectedRow()-1]
+		}
+		app.display.ClearScreen()
+	}
+	app.Display()
+}
+
+// toggleHistory shows the history of the row selected, or stops showing it
+func (app *App) toggleHistory() {
+	if app.history != "" {
+		app.history = ""
+	} else if names := app.shownRowNames(); app.ctx.SelectedRow() > 0 && app.ctx.SelectedRow() <= len(names) {
+		app.history = names[app.ctx.SelectedRow()-1]
+	}
+	app.display.ClearScreen()
+	app.Display()
+}
+
+// selectedRowName returns the name of the row selected, "" if none
+func (app *App) selectedRowName() string {
+	if names := app.shownRowNames(); app.ctx.SelectedRow() > 0 && app.ctx.SelectedRow() <= len(names) {
+		return names[app.ctx.SelectedRow()-1]
+	}
+	return ""
+}
+
+// expandBranch shows, or hides, the events below the wait event class
+// selected, keeping the class selected
+func (app *App) expandBranch(expand bool) {
+	waits, ok := app.waitEvents.(*wait_events.Object)
+	if !ok || app.currentView.Get() != view.ViewWaits || app.help || app.instruments || app.consumers || app.about || app.info {
+		return
+	}
+	name := app.selectedRowName()
+	if name == "" {
+		return
+	}
+	if expand {
+		waits.Expand(name)
+	} else if name = waits.Collapse(name); name == "" {
+		return
+	}
+	for i, shown := range app.shownRowNames() {
+		if shown == name {
+			app.ctx.SetSelectedRow(i + 1)
+		}
+	}
+	app.display.ClearScreen()
+	app.Display()
+}
+
 // Help returns the internal help variable
 func (app App) Help() bool {
 	return app.help
 }
 
-// Display shows the output appropriate to the corresponding view and device
+// Display shows the output appropriate to the corresponding view and
+// device, all at once when it has been drawn so the screen never flickers
 func (app *App) Display() {
 	if app.help {
 		app.display.DisplayHelp() // shouldn't get here if in --stdout mode
-	} else {
-		switch app.currentView.Get() {
-		case view.ViewLatency, view.ViewOps:
-			app.display.Display(app.tiwsbt)
-		case view.ViewIO:
-			app.display.Display(app.fsbi)
-		case view.ViewLocks:
-			app.display.Display(app.tlwsbt)
-		case view.ViewUsers:
-			app.display.Display(app.users)
-		case view.ViewMutex:
-			app.display.Display(app.ewsgben)
-		case view.ViewStages:
-			app.display.Display(app.essgben)
-		case view.ViewMemory:
-			app.display.Display(app.memory)
+	} else if app.instruments {
+		app.display.DisplayInstruments(app.setupInstruments.Families(), app.instrumentsMessage)
+	} else if app.consumers {
+		consumers, err := app.setupConsumers.Consumers(app.currentView.Name())
+		message := app.consumersMessage
+		if err != nil {
+			message = err.Error()
+		}
+		app.display.DisplayConsumers(app.currentView.Name(), consumers, message)
+	} else if app.about {
+		app.display.DisplayAbout(app.selfStats)
+	} else if app.info {
+		app.display.DisplayInfo(app.serverInfo)
+	} else if table := app.currentTable(); table != nil {
+		var data display.GenericData = table
+		if resulter, ok := table.(ps_table.Resulter); ok {
+			if app.ctx.WantAllRows() {
+				data = display.NewAllRowsData(data, resulter)
+			}
+			if app.ctx.WantPercentOfTotal() {
+				data = display.NewPercentData(data, resulter)
+			} else if columns := computed_column.Configured(app.currentView.Name()); len(columns) > 0 {
+				data = display.NewComputedData(data, resulter, columns)
+			}
+			if fileIO, ok := app.fsbi.(ps_table.Resulter); ok && app.wantAmplification() && !app.ctx.WantPercentOfTotal() {
+				rowLengths, err := app.rowLengths.Get(app.dbh, app.ctx.SchemaFilter())
+				if err != nil {
+					logger.Println("app.Display() unable to collect the average row lengths:", err)
+				}
+				data = display.NewAmplificationData(data, resulter, fileIO, rowLengths)
+			}
+		}
+		if resulter, ok := table.(ps_table.Resulter); ok && app.anomalies.Enabled() {
+			data = display.NewAnomalyData(data, resulter, app.ctx.Anomalies())
+		}
+		if resulter, ok := table.(ps_table.Resulter); ok && app.ctx.WantPriority() {
+			data = display.NewPriorityData(data, app.priorityOrder(resulter.Results()))
+		}
+		if re := row_filter.Configured(app.currentView.Name()); re != nil {
+			data = display.NewFilteredData(data, re)
+		}
+		if err := app.collectErrors[table]; err != nil {
+			data = display.NewStaleData(data, err)
+		}
+		app.display.Display(data)
+		if app.history != "" {
+			app.display.DisplayHistory(app.rowHistory.Series(app.currentView.Name(), app.history))
 		}
 	}
+	app.display.Flush()
 }
 
 // fixLatencySetting() ensures the SetWantsLatency() value is
@@ -253,26 +1066,208 @@ func (app *App) fixLatencySetting() {
 // change to the previous display mode
 func (app *App) displayPrevious() {
 	app.currentView.SetPrev()
-	app.fixLatencySetting()
-	app.display.ClearScreen()
-	app.Display()
+	app.displayCurrentView()
 }
 
 // change to the next display mode
 func (app *App) displayNext() {
 	app.currentView.SetNext()
+	app.displayCurrentView()
+}
+
+// change to the display mode with the given number (if there is one)
+func (app *App) displayNumber(number int) {
+	if app.currentView.SetByNumber(number) {
+		app.displayCurrentView()
+	}
+}
+
+// tabNames returns the names of the views open as tabs
+func (app *App) tabNames() []string {
+	names := make([]string, 0, len(app.tabs))
+	for _, code := range app.tabs {
+		names = append(names, code.String())
+	}
+	return names
+}
+
+// openTab opens a tab for the view, closing the oldest if too many are open
+func (app *App) openTab(code view.Code) {
+	for _, tab := range app.tabs {
+		if tab == code {
+			return
+		}
+	}
+	if len(app.tabs) == maxTabs {
+		app.tabs = app.tabs[1:]
+	}
+	app.tabs = append(app.tabs, code)
+}
+
+// toggleTab opens a tab for the current view, or closes it if it's open
+func (app *App) toggleTab() {
+	code := app.currentView.Get()
+	for i, tab := range app.tabs {
+		if tab == code {
+			app.tabs = append(app.tabs[:i:i], app.tabs[i+1:]...)
+			app.ctx.SetTabs(app.tabNames())
+			return
+		}
+	}
+	app.openTab(code)
+	app.ctx.SetTabs(app.tabNames())
+}
+
+// change to the next tab, or to the next view if there are no tabs.
+// The sort and filter of each view are kept so each tab keeps its own.
+func (app *App) displayNextTab() {
+	if len(app.tabs) == 0 {
+		app.displayNext()
+		return
+	}
+	next := app.tabs[0]
+	for i, tab := range app.tabs {
+		if tab == app.currentView.Get() && i+1 < len(app.tabs) {
+			next = app.tabs[i+1]
+		}
+	}
+	app.currentView.Set(next)
+	app.displayCurrentView()
+}
+
+// show the current view after it has been changed
+func (app *App) displayCurrentView() {
+	app.ctx.SetViewNumber(app.currentView.Number())
+	app.ctx.SetViewName(app.currentView.Name())
+	app.ctx.SetSelectedRow(0)
+	app.history = ""
+	app.consumersMessage = ""
 	app.fixLatencySetting()
 	app.display.ClearScreen()
 	app.Display()
 }
 
+// logAction records the user's action, and the state it led to, in the
+// session log
+func (app *App) logAction(e event.Event) {
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+
+	switch e.Type {
+	case event.EventAnonymise:
+		app.sessionLog.Record("anonymise", onOff(anonymiser.Enabled()))
+	case event.EventViewNext, event.EventViewPrev, event.EventViewNumber, event.EventTabNext:
+		if app.instruments {
+			app.sessionLog.Record("instruments", fmt.Sprintf("family %d:", e.Number), app.instrumentsMessage)
+		} else {
+			app.sessionLog.Record("view", app.currentView.Name())
+		}
+	case event.EventDecreasePollTime, event.EventIncreasePollTime:
+		app.sessionLog.Record("interval", app.currentView.Name(), app.waitInfo().WaitInterval().String())
+	case event.EventHelp:
+		app.sessionLog.Record("help", onOff(app.help))
+	case event.EventHistory:
+		if app.history != "" {
+			app.sessionLog.Record("history", app.history)
+		} else {
+			app.sessionLog.Record("history", "off")
+		}
+	case event.EventInstruments:
+		app.sessionLog.Record("instruments", onOff(app.instruments))
+	case event.EventRestoreInstruments:
+		if app.instruments {
+			app.sessionLog.Record("restore_instruments", app.instrumentsMessage)
+		}
+	case event.EventConsumers:
+		app.sessionLog.Record("consumers", onOff(app.consumers))
+	case event.EventEnableConsumers:
+		if app.consumers {
+			app.sessionLog.Record("enable_consumers", app.currentView.Name(), app.consumersMessage)
+		}
+	case event.EventAbout:
+		app.sessionLog.Record("about", onOff(app.about))
+	case event.EventInfo:
+		app.sessionLog.Record("info", onOff(app.info))
+	case event.EventToggleWantRelative:
+		app.sessionLog.Record("relative", onOff(app.ctx.WantRelativeStats()))
+	case event.EventToggleStatements:
+		app.sessionLog.Record("full_statements", onOff(app.ctx.WantFullStatements()))
+	case event.EventToggleByTable:
+		app.sessionLog.Record("by_table", onOff(app.ctx.WantByTable()))
+	case event.EventTogglePartitions:
+		app.sessionLog.Record("partitions", onOff(app.ctx.WantPartitions()))
+	case event.EventToggleOpLatency:
+		app.sessionLog.Record("op_latency", onOff(app.ctx.WantOpLatency()))
+	case event.EventTogglePercent:
+		app.sessionLog.Record("percent_of_total", onOff(app.ctx.WantPercentOfTotal()))
+	case event.EventToggleIOPerRow:
+		app.sessionLog.Record("io_per_row", onOff(app.ctx.WantAmplification()))
+	case event.EventToggleByAccount:
+		app.sessionLog.Record("by_account", onOff(app.ctx.WantByAccount()))
+	case event.EventToggleConnections:
+		app.sessionLog.Record("connections", onOff(app.ctx.WantConnections()))
+	case event.EventToggleAllRows:
+		app.sessionLog.Record("all_rows", onOff(app.ctx.WantAllRows()))
+	case event.EventToggleLockWaiters:
+		app.sessionLog.Record("lock_waiters", onOff(app.ctx.WantLockWaiters()))
+	case event.EventTogglePriority:
+		app.sessionLog.Record("priority", onOff(app.ctx.WantPriority()))
+	case event.EventObjectType:
+		app.sessionLog.Record("object_type", objectTypeName(app.ctx.ObjectType()))
+	case event.EventPageUp, event.EventPageDown:
+		app.sessionLog.Record("page", fmt.Sprintf("%d", app.ctx.ConnectionsPage()+1))
+	case event.EventFollow:
+		if app.follow != nil {
+			app.sessionLog.Record("follow", app.follow.Description())
+		} else {
+			app.sessionLog.Record("follow", "off")
+		}
+	case event.EventExpand:
+		if app.currentView.Get() == view.ViewWaits {
+			app.sessionLog.Record("expand", app.selectedRowName())
+		}
+	case event.EventCollapse:
+		if app.currentView.Get() == view.ViewWaits {
+			app.sessionLog.Record("collapse", app.selectedRowName())
+		}
+	case event.EventResetStatistics:
+		app.sessionLog.Record("reset", app.currentView.Name())
+	case event.EventMark:
+		app.sessionLog.Record("mark", app.currentView.Name())
+	case event.EventToggleSinceMark:
+		app.sessionLog.Record("since_mark", onOff(app.ctx.WantSinceMark()))
+	case event.EventToggleTab:
+		app.sessionLog.Record("tabs", strings.Join(app.tabNames(), ","))
+	case event.EventToggleRawNumbers:
+		app.sessionLog.Record("raw_numbers", onOff(lib.RawNumbers()))
+	case event.EventFinished:
+		app.sessionLog.Record("quit")
+	}
+}
+
 // Cleanup prepares  the application prior to shutting down
 func (app *App) Cleanup() {
+	if app.fingerprint != "" && app.dbh != nil {
+		app.writeFingerprint(app.fingerprint)
+	}
+	if app.watchdogLog != app.sessionLog {
+		app.watchdogLog.Close()
+	}
+	app.sessionLog.Close()
 	app.display.Close()
 	if app.dbh != nil {
+		app.setupConsumers.Restore()
+		app.setupInstruments.SetConsumers(app.setupConsumers.Enabled())
 		app.setupInstruments.RestoreConfiguration()
 		_ = app.dbh.Close()
 	}
+	if app.proxy != nil {
+		_ = app.proxy.Close()
+	}
 	logger.Println("App.Cleanup completed")
 }
 
@@ -285,48 +1280,33 @@ func (app *App) Run() {
 
 	eventChan := app.display.EventChan()
 
+	// redraw the screen between collections so the clock, uptime and
+	// age of the data move on
+	var refresh <-chan time.Time
+	if app.refresh > 0 {
+		ticker := time.NewTicker(app.refresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	app.runScript()
 	for !app.Finished() {
 		select {
+		case <-refresh:
+			app.Display()
 		case sig := <-app.sigChan:
 			fmt.Println("Caught signal: ", sig)
+			app.sessionLog.Record("signal", sig.String())
 			app.finished = true
-		case <-app.wi.WaitNextPeriod():
+		case <-app.waitInfo().WaitNextPeriod():
 			app.Collect()
 			app.Display()
 			if app.stdout {
 				app.setInitialFromCurrent()
 			}
+			app.runScript()
 		case inputEvent := <-eventChan:
-			switch inputEvent.Type {
-			case event.EventAnonymise:
-				anonymiser.Enable(!anonymiser.Enabled()) // toggle current behaviour
-			case event.EventFinished:
-				app.finished = true
-			case event.EventViewNext:
-				app.displayNext()
-			case event.EventViewPrev:
-				app.displayPrevious()
-			case event.EventDecreasePollTime:
-				if app.wi.WaitInterval() > time.Second {
-					app.wi.SetWaitInterval(app.wi.WaitInterval() - time.Second)
-				}
-			case event.EventIncreasePollTime:
-				app.wi.SetWaitInterval(app.wi.WaitInterval() + time.Second)
-			case event.EventHelp:
-				app.SetHelp(!app.Help())
-			case event.EventToggleWantRelative:
-				app.ctx.SetWantRelativeStats(!app.ctx.WantRelativeStats())
-				app.Display()
-			case event.EventResetStatistics:
-				app.resetDBStatistics()
-				app.Display()
-			case event.EventResizeScreen:
-				width, height := inputEvent.Width, inputEvent.Height
-				app.display.Resize(width, height)
-				app.Display()
-			case event.EventError:
-				log.Fatalf("Quitting because of EventError error")
-			}
+			app.handleEvent(inputEvent)
 		}
 		// provide a hook to stop the application if the counter goes down to zero
 		if app.stdout && app.count > 0 {
@@ -337,3 +1317,293 @@ func (app *App) Run() {
 		}
 	}
 }
+
+// RunOnce shows each view which can be selected, from the data
+// collected on startup, and returns without waiting for more
+func (app *App) RunOnce() {
+	logger.Println("app.RunOnce()")
+
+	seen := make(map[view.Code]bool)
+	for code := app.currentView.Get(); !seen[code]; code = app.currentView.SetNext() {
+		seen[code] = true
+		if view.IsSelectable(code) {
+			app.displayCurrentView()
+		}
+	}
+}
+
+// checkScript returns an error if a view the --script changes to can't
+// be shown on this server, so it stops before the script starts rather
+// than part way through
+func checkScript(script []ui_script.Command) error {
+	for _, c := range script {
+		if c.Name != "view" {
+			continue
+		}
+		if number, err := strconv.Atoi(c.Arg); err == nil {
+			var v view.View
+			if !v.SetByNumber(number) {
+				return fmt.Errorf("--script line %d: no view can be shown with the number %d", c.Line, number)
+			}
+			continue
+		}
+		if code, found := view.CodeByName(c.Arg); !found || !view.IsSelectable(code) {
+			return fmt.Errorf("--script line %d: %q is not a view which can be shown", c.Line, c.Arg)
+		}
+	}
+
+	return nil
+}
+
+// runScript runs the commands of the --script after each collection
+// until it has to wait for more collections or ends. Once it ends the
+// keyboard is used as usual.
+func (app *App) runScript() {
+	if app.scriptWait > 0 {
+		if app.scriptWait--; app.scriptWait > 0 {
+			return
+		}
+	}
+
+	for len(app.script) > 0 && !app.finished {
+		c := app.script[0]
+		app.script = app.script[1:]
+		logger.Println("app.runScript() line", c.Line, c.String())
+		app.sessionLog.Record("script", c.String())
+
+		switch c.Name {
+		case "view":
+			if number, err := strconv.Atoi(c.Arg); err == nil {
+				app.displayNumber(number)
+			} else if code, found := view.CodeByName(c.Arg); found {
+				app.currentView.Set(code)
+				app.displayCurrentView()
+			}
+		case "wait":
+			app.scriptWait, _ = strconv.Atoi(c.Arg)
+			return
+		case "export":
+			app.export(c.Arg)
+		case "fingerprint":
+			app.writeFingerprint(c.Arg)
+		case "key":
+			for _, ch := range c.Arg {
+				if e := display.KeyEvent(ch); e.Type != event.EventUnknown {
+					app.handleEvent(e)
+				}
+			}
+		case "reset":
+			app.handleEvent(event.Event{Type: event.EventResetStatistics})
+		case "mark":
+			app.handleEvent(event.Event{Type: event.EventMark})
+		case "quit":
+			app.handleEvent(event.Event{Type: event.EventFinished})
+		}
+	}
+}
+
+// export appends what is shown to the file in the form written by
+// ps-stats
+func (app *App) export(path string) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Println("app.export() failed:", err)
+		app.sessionLog.Record("export_failed", err.Error())
+		return
+	}
+	defer file.Close()
+
+	exporter := display.NewStdoutDisplayTo(file, 0, false)
+	exporter.SetContext(app.ctx)
+	shown := app.display
+	app.display = exporter
+	app.Display()
+	app.display = shown
+}
+
+// writeFingerprint writes the workload fingerprint of the period since
+// the statistics were reset, or marked, to the file
+func (app *App) writeFingerprint(path string) {
+	app.collect(app.efficiency)
+	app.collect(app.tiwsbt)
+
+	f := workload_fingerprint.New(app.efficiency.(ps_table.Resulter).Results(), app.tiwsbt.Results())
+	f.Time = lib.InTimezone(app.tiwsbt.LastCollectTime())
+	f.Host = app.ctx.Hostname()
+	f.Version = app.ctx.MySQLVersion()
+	f.Title = app.ctx.Title()
+	switch {
+	case !app.ctx.WantRelativeStats():
+		f.Seconds = float64(app.ctx.Uptime())
+	case app.ctx.WantSinceMark() && !app.tiwsbt.MarkCollectTime().IsZero():
+		f.Seconds = f.Time.Sub(app.tiwsbt.MarkCollectTime()).Round(time.Millisecond).Seconds()
+	default:
+		f.Seconds = f.Time.Sub(app.tiwsbt.InitialCollectTime()).Round(time.Millisecond).Seconds()
+	}
+
+	if err := f.Write(path); err != nil {
+		logger.Println("app.writeFingerprint() failed:", err)
+		app.sessionLog.Record("fingerprint_failed", err.Error())
+		return
+	}
+	app.sessionLog.Record("fingerprint", path)
+}
+
+// handleEvent acts on an event from the keyboard, or the script, and
+// records it in the session log
+func (app *App) handleEvent(inputEvent event.Event) {
+	switch inputEvent.Type {
+	case event.EventAnonymise:
+		anonymiser.Enable(!anonymiser.Enabled()) // toggle current behaviour
+	case event.EventFinished:
+		app.finished = true
+	case event.EventViewNext:
+		app.displayNext()
+	case event.EventViewPrev:
+		app.displayPrevious()
+	case event.EventTabNext:
+		app.displayNextTab()
+	case event.EventToggleTab:
+		app.toggleTab()
+		app.Display()
+	case event.EventToggleRawNumbers:
+		lib.SetRawNumbers(!lib.RawNumbers())
+		app.Display()
+	case event.EventViewNumber:
+		if app.instruments {
+			app.toggleInstrumentFamily(inputEvent.Number)
+		} else {
+			app.displayNumber(inputEvent.Number)
+		}
+	case event.EventDecreasePollTime:
+		if wi := app.waitInfo(); wi.WaitInterval() > time.Second {
+			wi.SetWaitInterval(wi.WaitInterval() - time.Second)
+		}
+	case event.EventIncreasePollTime:
+		wi := app.waitInfo()
+		wi.SetWaitInterval(wi.WaitInterval() + time.Second)
+	case event.EventHelp:
+		app.SetHelp(!app.Help())
+	case event.EventHistory:
+		if app.help || (app.ctx.SelectedRow() == 0 && app.history == "") {
+			inputEvent.Type = event.EventHelp // there's no row to show, so h asks for help
+			app.SetHelp(!app.Help())
+		} else {
+			app.toggleHistory()
+		}
+	case event.EventSelectUp:
+		app.selectRow(-1)
+	case event.EventSelectDown:
+		app.selectRow(1)
+	case event.EventExpand:
+		app.expandBranch(true)
+	case event.EventCollapse:
+		app.expandBranch(false)
+	case event.EventInstruments:
+		if !app.flavor.Partial() {
+			app.SetInstruments(!app.instruments)
+		}
+	case event.EventRestoreInstruments:
+		if app.instruments {
+			app.restoreInstruments()
+		}
+	case event.EventConsumers:
+		if !app.flavor.Partial() {
+			app.SetConsumers(!app.consumers)
+			app.Display()
+		}
+	case event.EventEnableConsumers:
+		if app.consumers {
+			app.enableConsumers()
+		}
+	case event.EventAbout:
+		app.SetAbout(!app.about)
+		app.Display()
+	case event.EventInfo:
+		app.SetInfo(!app.info)
+		app.Display()
+	case event.EventToggleWantRelative:
+		app.ctx.SetWantRelativeStats(!app.ctx.WantRelativeStats())
+		app.Display()
+	case event.EventToggleStatements:
+		app.ctx.SetWantFullStatements(!app.ctx.WantFullStatements())
+		app.Display()
+	case event.EventFollow:
+		app.toggleFollow()
+	case event.EventToggleByTable:
+		app.ctx.SetWantByTable(!app.ctx.WantByTable())
+		app.Display()
+	case event.EventTogglePartitions:
+		app.ctx.SetWantPartitions(!app.ctx.WantPartitions())
+		app.Display()
+	case event.EventToggleOpLatency:
+		app.ctx.SetWantOpLatency(!app.ctx.WantOpLatency())
+		app.Display()
+	case event.EventTogglePercent:
+		app.ctx.SetWantPercentOfTotal(!app.ctx.WantPercentOfTotal())
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventToggleIOPerRow:
+		app.ctx.SetWantAmplification(!app.ctx.WantAmplification())
+		if app.wantAmplification() {
+			app.collect(app.fsbi)
+		}
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventToggleByAccount:
+		app.ctx.SetWantByAccount(!app.ctx.WantByAccount())
+		app.collect(app.ewsgben)
+		app.Display()
+	case event.EventToggleConnections:
+		app.ctx.SetWantConnections(!app.ctx.WantConnections())
+		app.ctx.SetConnectionsPage(0)
+		app.collect(app.users)
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventToggleAllRows:
+		app.ctx.SetWantAllRows(!app.ctx.WantAllRows())
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventToggleLockWaiters:
+		app.ctx.SetWantLockWaiters(!app.ctx.WantLockWaiters())
+		app.ctx.SetSelectedRow(0)
+		app.history = ""
+		if table := app.table(view.ViewLocks); table != nil {
+			app.collect(table)
+		}
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventObjectType:
+		app.changeObjectType()
+	case event.EventTogglePriority:
+		app.ctx.SetWantPriority(!app.ctx.WantPriority())
+		app.ctx.SetSelectedRow(0)
+		app.history = ""
+		app.display.ClearScreen()
+		app.Display()
+	case event.EventPageUp:
+		app.changeConnectionsPage(-1)
+	case event.EventPageDown:
+		app.changeConnectionsPage(1)
+	case event.EventResetStatistics:
+		app.resetDBStatistics()
+		app.Display()
+	case event.EventMark:
+		app.ctx.SetWantSinceMark(true)
+		app.markDBStatistics()
+		app.Display()
+	case event.EventToggleSinceMark:
+		app.ctx.SetWantSinceMark(!app.ctx.WantSinceMark())
+		if table := app.currentTable(); table != nil {
+			app.collect(table)
+		}
+		app.Display()
+	case event.EventResizeScreen:
+		width, height := inputEvent.Width, inputEvent.Height
+		app.display.Resize(width, height)
+		app.Display()
+	case event.EventError:
+		log.Fatalf("Quitting because of EventError error")
+	}
+	app.logAction(inputEvent)
+}
@@ -8,15 +8,29 @@ import (
 
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/schema_filter"
 )
 
 // Row holds a row of data from table_lock_waits_summary_by_table
 type BaseObject struct {
 	intialCollectTime time.Time // the initial collection time (for relative data)
 	lastCollectTime   time.Time // the last collection time
+	markCollectTime   time.Time // the collection time of the mark (zero if not marked)
+	health            ps_table.Health
 	ctx               *context.Context
 }
 
+// Health returns how the last collection went
+func (o BaseObject) Health() ps_table.Health {
+	return o.health
+}
+
+// SetHealth records how the last collection went
+func (o *BaseObject) SetHealth(health ps_table.Health) {
+	o.health = health
+}
+
 func (o BaseObject) LastCollectTime() time.Time {
 	return o.lastCollectTime
 }
@@ -39,6 +53,17 @@ func (o *BaseObject) SetInitialCollectTimeNow() {
 	o.intialCollectTime = time.Now()
 }
 
+// MarkCollectTime returns the collection time of the values marked as
+// an additional comparison point, or the zero time if there is no mark
+func (o BaseObject) MarkCollectTime() time.Time {
+	return o.markCollectTime
+}
+
+// SetMarkCollectTime records the collection time of the marked values
+func (o *BaseObject) SetMarkCollectTime(mark time.Time) {
+	o.markCollectTime = mark
+}
+
 // SetContext sets the context in this object which can be used later.
 // - it should always be defined (!= nil)
 func (o *BaseObject) SetContext(ctx *context.Context) {
@@ -48,6 +73,14 @@ func (o *BaseObject) SetContext(ctx *context.Context) {
 	o.ctx = ctx
 }
 
+// Status returns a pointer to the global status
+func (o BaseObject) Status() *global.Status {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.Status() o.ctx should not be nil")
+	}
+	return o.ctx.Status()
+}
+
 // Variables returns a pointer to the global variables
 func (o BaseObject) Variables() *global.Variables {
 	if o.ctx == nil {
@@ -65,3 +98,84 @@ func (o BaseObject) WantRelativeStats() bool {
 	}
 	return o.ctx.WantRelativeStats()
 }
+
+// WantFullStatements indicates whether statements should be shown in full
+func (o BaseObject) WantFullStatements() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantFullStatements(): o.ctx should not be nil")
+	}
+	return o.ctx.WantFullStatements()
+}
+
+// WantByTable indicates whether statement latency should be shown by table
+func (o BaseObject) WantByTable() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantByTable(): o.ctx should not be nil")
+	}
+	return o.ctx.WantByTable()
+}
+
+// WantByAccount indicates whether mutex latency should be shown by account
+func (o BaseObject) WantByAccount() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantByAccount(): o.ctx should not be nil")
+	}
+	return o.ctx.WantByAccount()
+}
+
+// WantOpLatency indicates whether the table I/O views should show the latency of each operation
+func (o BaseObject) WantOpLatency() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantOpLatency(): o.ctx should not be nil")
+	}
+	return o.ctx.WantOpLatency()
+}
+
+// WantConnections indicates whether the connections should be listed rather than added up by user
+func (o BaseObject) WantConnections() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantConnections(): o.ctx should not be nil")
+	}
+	return o.ctx.WantConnections()
+}
+
+// ConnectionsPage returns the page of connections listed
+func (o BaseObject) ConnectionsPage() int {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.ConnectionsPage(): o.ctx should not be nil")
+	}
+	return o.ctx.ConnectionsPage()
+}
+
+// SchemaFilter returns the schemas the table based views are restricted to
+func (o BaseObject) SchemaFilter() *schema_filter.Filter {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.SchemaFilter(): o.ctx should not be nil")
+	}
+	return o.ctx.SchemaFilter()
+}
+
+// WantPartitions indicates whether partitioned tables should be shown by partition
+func (o BaseObject) WantPartitions() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.WantPartitions(): o.ctx should not be nil")
+	}
+	return o.ctx.WantPartitions()
+}
+
+// ObjectType returns the type of tables the table I/O views are limited to
+func (o BaseObject) ObjectType() string {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.ObjectType(): o.ctx should not be nil")
+	}
+	return o.ctx.ObjectType()
+}
+
+// SinceMark indicates whether relative values are shown since the mark
+// rather than since the initial values. This needs a mark to be set.
+func (o BaseObject) SinceMark() bool {
+	if o.ctx == nil {
+		log.Fatal("BaseObject.SinceMark(): o.ctx should not be nil")
+	}
+	return o.ctx.WantSinceMark() && !o.markCollectTime.IsZero()
+}
@@ -0,0 +1,316 @@
+// Package binlog_events contains the library routines for reading the
+// events written to the binary log with SHOW BINLOG EVENTS.
+package binlog_events
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// maxEvents is the most events read in one collection. The rest of
+// what was written to the current file is counted as not read.
+const maxEvents = 10000
+
+// unread is the name of the row counting the bytes not read
+const unread = "(not read)"
+
+// position is a position in the binary logs
+type position struct {
+	file string
+	pos  uint64
+}
+
+// Row contains the events of one type written to the binary log
+type Row struct {
+	name   string // Event_type, e.g. Write_rows, Gtid or Xid
+	events uint64
+	bytes  uint64
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// currentPosition returns the position the server is writing to.
+// SHOW MASTER STATUS was replaced by SHOW BINARY LOG STATUS in 8.4.
+func currentPosition(dbh *sql.DB) (position, error) {
+	p, err := queryPosition(dbh, "SHOW MASTER STATUS")
+	if err != nil {
+		p, err = queryPosition(dbh, "SHOW BINARY LOG STATUS")
+	}
+
+	return p, err
+}
+
+// queryPosition returns the file and position of the status command.
+// The number of other columns depends on the version.
+func queryPosition(dbh *sql.DB, query string) (position, error) {
+	var p position
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return p, err
+	}
+	if len(columns) < 2 {
+		return p, fmt.Errorf("%s returned %d column(s)", query, len(columns))
+	}
+	values := make([]interface{}, len(columns))
+	values[0], values[1] = &p.file, &p.pos
+	for i := 2; i < len(values); i++ {
+		values[i] = new(sql.RawBytes)
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return p, err
+		}
+		return p, fmt.Errorf("%s returned no rows, is binary logging enabled?", query)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return p, err
+	}
+
+	return p, rows.Err()
+}
+
+// binaryLogs returns the names of the binary logs in order
+func binaryLogs(dbh *sql.DB) ([]string, error) {
+	var names []string
+
+	rows, err := dbh.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(sql.RawBytes)
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return nil, err
+		}
+		names = append(names, string(*values[0].(*sql.RawBytes)))
+	}
+
+	return names, rows.Err()
+}
+
+// filesBetween returns the binary logs from the one of the last
+// position to the current one. If the last file is no longer there
+// only the current file is returned.
+func filesBetween(logs []string, last, current string) []string {
+	if last == current {
+		return []string{current}
+	}
+	for i := range logs {
+		if logs[i] == last {
+			for j := i + 1; j < len(logs); j++ {
+				if logs[j] == current {
+					return logs[i : j+1]
+				}
+			}
+		}
+	}
+
+	return []string{current}
+}
+
+// quote returns the string quoted for use in a SHOW command
+func quote(s string) string {
+	return "'" + strings.Replace(strings.Replace(s, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
+
+// readEvents adds the events of the file from the given position up
+// to the end position (if not 0) to counts, reading at most limit events.
+// It returns the number of events read and the end of the last one.
+func readEvents(dbh *sql.DB, file string, from, end uint64, limit int, counts map[string]*Row) (int, uint64, error) {
+	query := fmt.Sprintf("SHOW BINLOG EVENTS IN %s FROM %d LIMIT %d", quote(file), from, limit)
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return 0, from, err
+	}
+	defer rows.Close()
+
+	// Log_name, Pos, Event_type, Server_id, End_log_pos, Info
+	var logName, eventType, info string
+	var pos, serverID, endLogPos uint64
+	var read int
+	reached := from
+	for rows.Next() {
+		if err := rows.Scan(&logName, &pos, &eventType, &serverID, &endLogPos, &info); err != nil {
+			return read, reached, err
+		}
+		if end > 0 && pos >= end {
+			break
+		}
+		r, found := counts[eventType]
+		if !found {
+			r = &Row{name: eventType}
+			counts[eventType] = r
+		}
+		r.events++
+		if endLogPos > pos {
+			r.bytes += endLogPos - pos
+			reached = endLogPos
+		}
+		read++
+	}
+
+	return read, reached, rows.Err()
+}
+
+// selectEvents returns the events written between the last and
+// current positions by type.
+func selectEvents(dbh *sql.DB, last, current position) (Rows, error) {
+	files := []string{current.file}
+	if last.file != current.file {
+		logs, err := binaryLogs(dbh)
+		if err != nil {
+			return nil, err
+		}
+		files = filesBetween(logs, last.file, current.file)
+	}
+
+	counts := make(map[string]*Row)
+	limit := maxEvents
+	for i, file := range files {
+		var from, end uint64
+		if file == last.file {
+			from = last.pos
+		}
+		if i == len(files)-1 {
+			end = current.pos
+		}
+		read, reached, err := readEvents(dbh, file, from, end, limit, counts)
+		if err != nil {
+			return nil, err
+		}
+		if limit -= read; limit <= 0 {
+			// count what wasn't read of this file
+			if i == len(files)-1 && current.pos > reached {
+				counts[unread] = &Row{name: unread, bytes: current.pos - reached}
+			}
+			break
+		}
+	}
+
+	var t Rows
+	for _, r := range counts {
+		t = append(t, *r)
+	}
+	logger.Println("binlog_events.selectEvents() read", maxEvents-limit, "event(s) from", files)
+
+	return t, nil
+}
+
+// add adds the events of other to the rows with the same name
+func (rows Rows) add(other Rows) Rows {
+	byName := make(map[string]int)
+	for i := range rows {
+		byName[rows[i].name] = i
+	}
+
+	for _, r := range other {
+		if i, found := byName[r.name]; found {
+			rows[i].events += r.events
+			rows[i].bytes += r.bytes
+		} else {
+			byName[r.name] = len(rows)
+			rows = append(rows, r)
+		}
+	}
+
+	return rows
+}
+
+// differ matches the rows by name to subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.events, row.bytes} },
+	Subtract: func(row *Row, other Row) {
+		row.events -= other.events
+		row.bytes -= other.bytes
+	},
+}
+
+// subtract removes the initial values from the rows with the same name
+func (rows Rows) subtract(initial Rows) {
+	differ.SubtractRows(rows, initial)
+}
+
+// totals returns the totals of all rows
+func (rows Rows) totals() Row {
+	total := Row{name: "Totals"}
+
+	for i := range rows {
+		total.events += rows[i].events
+		total.bytes += rows[i].bytes
+	}
+
+	return total
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"bytes":  func(i, j int) int { return sort_keys.Descending(rows[i].bytes, rows[j].bytes) },
+		"events": func(i, j int) int { return sort_keys.Descending(rows[i].events, rows[j].events) },
+		"name":   func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by bytes (descending) but also by "name" (ascending) if the
+// values are the same after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("binlog_events", "bytes", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("binlog_events", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s|%8s %6s|%8s|%s", "Bytes", "%", "Events", "%", "Avg size", "Event Type")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	name := row.name
+	if row.bytes == 0 && row.events == 0 && name != "Totals" {
+		name = ""
+	}
+	var average uint64
+	if row.events > 0 {
+		average = row.bytes / row.events
+	}
+
+	return fmt.Sprintf("%10s %6s|%8s %6s|%8s|%s",
+		lib.FormatAmount(row.bytes),
+		lib.FormatPct(lib.MyDivide(row.bytes, totals.bytes)),
+		lib.FormatAmount(row.events),
+		lib.FormatPct(lib.MyDivide(row.events, totals.events)),
+		lib.FormatAmount(average),
+		name)
+}
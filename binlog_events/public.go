@@ -0,0 +1,140 @@
+// Package binlog_events shows what is written to the binary log by
+// event type, e.g. row events, GTIDs or Xids, to find where its volume
+// comes from.
+package binlog_events
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the events written since we started reading the binary log
+type Object struct {
+	baseobject.BaseObject          // embedded
+	last                  position // where the events were read up to
+	initial               Rows     // initial data for relative values
+	current               Rows     // events read since the first collection
+	results               Rows     // results (maybe with subtraction)
+	totals                Row      // totals of results
+	disabled              bool     // binary logging is disabled
+}
+
+// NewBinlogEvents returns a pointer to an object of this type
+func NewBinlogEvents(ctx *context.Context) *Object {
+	logger.Println("NewBinlogEvents()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// Collect reads the events written to the binary log since the last
+// collection. The first collection only finds the current position so
+// the absolute values are those since ps-top started, not since the
+// server did.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	if t.disabled = t.Variables().Get("log_bin") == "OFF"; t.disabled {
+		t.SetLastCollectTimeNow()
+		return nil
+	}
+
+	current, err := currentPosition(dbh)
+	if err != nil {
+		return err
+	}
+	if t.last.file != "" {
+		rows, err := selectEvents(dbh, t.last, current)
+		if err != nil {
+			return err
+		}
+		t.current = t.current.add(rows)
+	}
+	t.last = current
+	t.SetLastCollectTimeNow()
+
+	if t.InitialCollectTime().IsZero() {
+		t.copyCurrentToInitial()
+	}
+	t.makeResults()
+
+	logger.Println("binlog_events.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+
+	t.results.sort()
+	t.totals = t.results.totals()
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the totals of all event types
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(e)
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	if t.disabled {
+		return "Binary log events: binary logging is disabled (log_bin = OFF)"
+	}
+
+	return fmt.Sprintf("Binary log events (SHOW BINLOG EVENTS) %s:%d, %d event type(s)", t.last.file, t.last.pos, len(t.results))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
@@ -10,12 +10,15 @@ import (
 	"os"
 	"runtime/pprof"
 	"strconv"
+	"time"
 
 	"github.com/sjmudd/ps-top/app"
 	"github.com/sjmudd/ps-top/connector"
 	"github.com/sjmudd/ps-top/display"
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/proxysql"
+	"github.com/sjmudd/ps-top/schema_filter"
 	"github.com/sjmudd/ps-top/version"
 )
 
@@ -23,13 +26,32 @@ var (
 	connectorFlags connector.Flags
 	count          int
 	delay          int
+	include        schema_filter.Patterns // may be given more than once
+	exclude        schema_filter.Patterns
 
+	absolute    = flag.Bool("absolute", false, "Show absolute statistics (since the server started) rather than the change in each interval")
+	anomalies   = flag.Float64("anomalies", 0, "Mark the rows whose change is more than this many standard deviations above their usual change (0 disables)")
 	cpuprofile  = flag.String("cpuprofile", "", "write cpu profile to file")
+	databases   = flag.String("databases", "", "Only show the tables of these comma separated databases in the table based views")
+	archiveDir  = flag.String("archive-dir", "", "Also append the values of the rows to a gzipped CSV file per view and day in this directory")
+	flagChanges = flag.Bool("changes", false, "Write rows which have changed as NDJSON events instead of the normal output")
+	threshold   = flag.Uint64("changes-threshold", 0, "Only write rows where a value changed by more than this amount (with --changes)")
 	flagDebug   = flag.Bool("debug", false, "Enabling debug logging")
+	flagFilter  = flag.String("filter", "", "Only show rows of the initial view with a column matching this regular expression")
 	flagHelp    = flag.Bool("help", false, "Provide some help for "+lib.MyName())
+	ignoreDBs   = flag.String("ignore-databases", "", "Don't show the tables of these comma separated databases in the table based views")
 	flagLimit   = flag.Int("limit", 0, "Show a maximum of limit entries (defaults to screen size if output to screen)")
+	once        = flag.Bool("once", false, "Print every view once with the values since the server started and exit")
+	flagProcess = flag.Bool("local-process", false, "Show the OS statistics of mysqld when it runs on this host")
 	flagTotals  = flag.Bool("totals", false, "Only show the totals when in stdout mode and no detail (default: false)")
+	proxyAdmin  = flag.String("proxy-admin", "", "Also show the statistics of ProxySQL from its admin interface at [user[:password]@]host[:port]")
+	flagRestore = flag.Bool("restore-instruments", false, "Restore the setup_instruments settings left changed by an earlier run which was killed")
+	rawNumbers  = flag.Bool("raw-numbers", false, "Show latencies in whole microseconds and amounts without a suffix, e.g. to paste into a spreadsheet")
+	flagSort    = flag.String("sort", "", "Provide a comma separated list of keys to sort the initial view by")
 	flagVersion = flag.Bool("version", false, "Show the version of "+lib.MyName())
+	flagWarmup  = flag.Duration("warmup", time.Second, "Wait this long between the initial collection and the first one shown so it shows the changes in that time (0 disables)")
+	timezone    = flag.String("timezone", "local", "Show the times in this zone: local, UTC or a zone name such as Europe/Madrid")
+	flagTitle   = flag.String("title", "", "Show this title, e.g. the shard and incident, in the header and with the data written")
 	flagView    = flag.String("view", "", "Provide view to show when starting "+lib.MyName()+" (default: table_io_latency)")
 )
 
@@ -42,33 +64,80 @@ func usage() {
 	fmt.Println("Usage: " + lib.MyName() + " <options> [delay [count]]")
 	fmt.Println("")
 	fmt.Println("Options:")
+	fmt.Println("--absolute                               Show statistics since the server started rather than the change in each interval")
+	fmt.Println("--allow-cleartext-passwords              Allow sending the password in cleartext for LDAP or PAM accounts (needs --tls or --socket)")
+	fmt.Println("--anomalies=<n>                          Mark the rows changing by more than n standard deviations above their usual change, e.g. 3")
+	fmt.Println("--archive-dir=<dir>                      Also append the values of the rows to <dir>/<view>-<date>.csv.gz, e.g. for DuckDB")
+	fmt.Println("--changes                                Write rows which changed since the last collection as NDJSON events")
+	fmt.Println("--changes-threshold=<n>                  Only write rows where a value changed by more than n (default 0)")
+	fmt.Println("--conn-max-lifetime=<duration>           Close connections after they have been open this long, e.g. 1h (default: keep them)")
+	fmt.Println("--connect-retries=<n>                    Number of times to try connecting again if MySQL can't be reached (default: 5)")
+	fmt.Println("--databases=<db,...>                     Only show the tables of these databases in the table based views")
 	fmt.Println("--defaults-file=/path/to/defaults.file   Connect to MySQL using given defaults-file")
+	fmt.Println("--defaults-group-suffix=<suffix>         Also read the [client<suffix>] group of the defaults-file")
+	fmt.Println("--demo                                   Show synthetic data instead of connecting to MySQL")
+	fmt.Println("--exclude=<regexp>                       Don't show the tables whose db.table name matches the regular expression (may be repeated)")
+	fmt.Println("--filter=<regexp>                        Only show rows of the initial view with a column matching the regular expression")
 	fmt.Println("--help                                   Show this help message")
-	fmt.Println("--host=<hostname>                        MySQL host to connect to")
+	fmt.Println("--host=<hostname>                        MySQL host to connect to, which may be an IPv6 address")
+	fmt.Println("--ignore-databases=<db,...>              Don't show the tables of these databases in the table based views")
+	fmt.Println("--include=<regexp>                       Only show the tables whose db.table name matches the regular expression (may be repeated)")
+	fmt.Println("--ipv4                                   Only connect to the IPv4 addresses of the host")
+	fmt.Println("--ipv6                                   Only connect to the IPv6 addresses of the host")
 	fmt.Println("--limit=<rows>                           Limit the number of lines of output (excluding headers)")
+	fmt.Println("--local-process                          Show mysqld's cpu, io, memory, threads and fds from /proc (mysqld must run on this host)")
+	fmt.Println("--max-idle-conns=<n>                     Maximum number of idle connections kept open (default: 2)")
+	fmt.Println("--max-open-conns=<n>                     Maximum number of connections open at once (default: 5)")
+	fmt.Println("--mysqlx                                 Connect using the X Protocol (default port: 33060)")
+	fmt.Println("--once                                   Print every view once with the values since the server started and exit, e.g. for support bundles")
 	fmt.Println("--password=<password>                    Password to use when connecting")
+	fmt.Println("--password-command=<command>             Run command to get the password (e.g. an IAM token) on each connect")
 	fmt.Println("--port=<port>                            MySQL port to connect to")
+	fmt.Println("--profile=<name>                         Connect using the [<name>] group of the defaults-file")
+	fmt.Println("--proxy-admin=<address>                  Also show ProxySQL's statistics from its admin interface at [user[:password]@]host[:port] (default port 6032)")
+	fmt.Println("--restore-instruments                    Restore the setup_instruments settings left changed by an earlier run which was killed")
+	fmt.Println("--raw-numbers                            Show latencies in whole microseconds and amounts without a k/M/G suffix")
 	fmt.Println("--socket=<path>                          MySQL path of the socket to connect to")
+	fmt.Println("--sort=<key,...>                         Sort the initial view by these keys before its default order")
+	fmt.Println("--timezone=<zone>                        Show the times in local time (the default), UTC or a zone name such as Europe/Madrid")
+	fmt.Println("--title=<text>                           Show this title in the header and with the data written, e.g. --title=\"shard-07 incident 1234\"")
+	fmt.Println("--tls=<true|skip-verify>                 Connect to MySQL using TLS")
 	fmt.Println("--totals                                 Only send the totals to stdout (in stdout mode)")
 	fmt.Println("--user=<user>                            User to connect with")
 	fmt.Println("--use-environment                        Connect to MySQL using a go dsn collected from MYSQL_DSN e.g. MYSQL_DSN='test_user:test_pass@tcp(127.0.0.1:3306)/performance_schema'")
+	fmt.Println("--warmup=<duration>                      Wait this long, e.g. 500ms, after the initial collection so the first data shown has changes (default: 1s)")
 	fmt.Println("--version                                Show the version")
 	fmt.Println("--view=<view>                            Determine the view you want to see when " + lib.MyName() + " starts (default: table_io_latency")
-	fmt.Println("                                         Possible values: table_io_latency table_io_ops file_io_latency table_lock_latency user_latency mutex_latency stages_latency")
+	fmt.Println("                                         Possible values: table_io_latency table_io_ops file_io_latency table_lock_latency user_latency mutex_latency stages_latency lock_waits statement_efficiency table_cache key_cache query_cache statement_stages unused_indexes binlog_events ddl_progress lock_users ps_sizing program_latency slo_budget prepared_statements wait_events idle_time proxy_digests proxy_backends")
 }
 
 func main() {
 	connectorFlags = connector.Flags{
-		Host:           flag.String("host", "", "Provide the hostname of the MySQL to connect to"),
-		Password:       flag.String("password", "", "Provide the password when connecting to the MySQL server"),
-		Port:           flag.Int("port", 0, "Provide the port number of the MySQL to connect to (default: 3306)"), /* Port is deliberately 0 here, defaults to 3306 elsewhere */
-		Socket:         flag.String("socket", "", "Provide the path to the local MySQL server to connect to"),
-		User:           flag.String("user", "", "Provide the username to connect with to MySQL (default: $USER)"),
-		UseEnvironment: flag.Bool("use-environment", false, "Use the environment variable MYSQL_DSN (go dsn) to connect with to MySQL"),
+		DefaultsGroupSuffix: flag.String("defaults-group-suffix", "", "Also read the [client<suffix>] group from the defaults file"),
+		Demo:                flag.Bool("demo", false, "Show synthetic data instead of connecting to MySQL"),
+		AllowCleartext:      flag.Bool("allow-cleartext-passwords", false, "Allow sending the password in cleartext for LDAP or PAM accounts (needs --tls or --socket)"),
+		ConnMaxLifetime:     flag.Duration("conn-max-lifetime", 0, "Close connections to MySQL after they have been open this long (default: 0, keep them)"),
+		ConnectRetries:      flag.Int("connect-retries", connector.ConnectRetries, "Number of times to try connecting again if MySQL can't be reached"),
+		Host:                flag.String("host", "", "Provide the hostname of the MySQL to connect to"),
+		IPv4:                flag.Bool("ipv4", false, "Only connect to the IPv4 addresses of the host"),
+		IPv6:                flag.Bool("ipv6", false, "Only connect to the IPv6 addresses of the host"),
+		MaxIdleConns:        flag.Int("max-idle-conns", connector.MaxIdleConns, "Maximum number of idle connections to MySQL kept open"),
+		MaxOpenConns:        flag.Int("max-open-conns", connector.MaxOpenConns, "Maximum number of connections open to MySQL at once"),
+		Mysqlx:              flag.Bool("mysqlx", false, "Connect using the X Protocol (default port: 33060)"),
+		Password:            flag.String("password", "", "Provide the password when connecting to the MySQL server"),
+		PasswordCommand:     flag.String("password-command", "", "Run this command to get the password each time a connection is made (e.g. to generate an IAM token)"),
+		Profile:             flag.String("profile", "", "Connect using the settings in the [<profile>] group of the defaults file"),
+		Port:                flag.Int("port", 0, "Provide the port number of the MySQL to connect to (default: 3306)"), /* Port is deliberately 0 here, defaults to 3306 elsewhere */
+		Socket:              flag.String("socket", "", "Provide the path to the local MySQL server to connect to"),
+		TLS:                 flag.String("tls", "", "Connect using TLS: true or skip-verify"),
+		User:                flag.String("user", "", "Provide the username to connect with to MySQL (default: $USER)"),
+		UseEnvironment:      flag.Bool("use-environment", false, "Use the environment variable MYSQL_DSN (go dsn) to connect with to MySQL"),
 	}
 
 	var err = errors.New("unknown")
 
+	flag.Var(&include, "include", "Only show the tables whose db.table name matches this regular expression (may be repeated)")
+	flag.Var(&exclude, "exclude", "Don't show the tables whose db.table name matches this regular expression (may be repeated)")
 	flag.Parse()
 
 	// Too many arguments
@@ -115,17 +184,64 @@ func main() {
 		usage()
 		return
 	}
+	lib.SetRawNumbers(*rawNumbers)
+	if err := lib.SetTimezone(*timezone); err != nil {
+		log.Fatal("--timezone should be local, UTC or a zone name such as Europe/Madrid: ", err)
+	}
+	if *anomalies < 0 {
+		log.Fatal("--anomalies should not be negative")
+	}
+
+	if *once && *flagChanges {
+		log.Fatal("--changes needs two collections so can't be used with --once")
+	}
+
+	var disp display.Display = display.NewStdoutDisplay(*flagLimit, true)
+	if *flagChanges {
+		disp = display.NewChangesDisplay(*threshold)
+	}
+	if *archiveDir != "" {
+		archive, err := display.NewArchiveDisplay(*archiveDir)
+		if err != nil {
+			log.Fatal("Unable to create the archive directory: ", err)
+		}
+		disp = display.NewMultiDisplay(disp, archive)
+	}
+	proxy, err := proxysql.Open(*proxyAdmin, *connectorFlags.Demo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	warmup := *flagWarmup
+	if *once {
+		warmup = 0 // the values since the server started are shown
+	}
 
 	settings := app.Settings{
-		Conn:     connector.NewConnector(connectorFlags),
-		Interval: delay,
-		Count:    count,
-		Stdout:   true,
-		View:     *flagView,
-		Disp:     display.NewStdoutDisplay(*flagLimit, true),
+		Absolute:  *absolute || *once,
+		Anomalies: *anomalies,
+		Proxy:     proxy,
+		Conn:      connector.NewConnector(connectorFlags),
+		Interval:  delay,
+		Count:     count,
+		Stdout:    true,
+		Sort:      *flagSort,
+		Filter:    *flagFilter,
+		Process:   *flagProcess,
+		Schemas:   schema_filter.NewFilter(*databases, *ignoreDBs, include, exclude),
+		Warmup:    warmup,
+		Changes:   *flagChanges,
+		Title:     *flagTitle,
+		Restore:   *flagRestore,
+		View:      *flagView,
+		Disp:      disp,
 	}
 
 	app := app.NewApp(settings)
-	app.Run()
+	if *once {
+		app.RunOnce()
+	} else {
+		app.Run()
+	}
 	app.Cleanup()
 }
@@ -8,26 +8,65 @@ import (
 	"log"
 	"os"
 	"runtime/pprof"
+	"time"
 
 	"github.com/sjmudd/ps-top/app"
 	"github.com/sjmudd/ps-top/connector"
 	"github.com/sjmudd/ps-top/display"
+	"github.com/sjmudd/ps-top/global"
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/monitor_user"
+	"github.com/sjmudd/ps-top/proxysql"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/ui_script"
 	"github.com/sjmudd/ps-top/version"
 )
 
 var (
 	connectorFlags connector.Flags
+	flagInclude    schema_filter.Patterns // may be given more than once
+	flagExclude    schema_filter.Patterns
 	cpuprofile     = flag.String("cpuprofile", "", "write cpu profile to file")
+	flagAbsolute   = flag.Bool("absolute", false, "Start by showing absolute statistics (since the server started) rather than relative ones")
+	flagAnomalies  = flag.Float64("anomalies", 0, "Mark the rows whose change is more than this many standard deviations above their usual change (0 disables)")
 	flagAnonymise  = flag.Bool("anonymise", false, "Anonymise hostname, user, db and table names (default: false)")
+	flagArchive    = flag.String("archive-dir", "", "Also append the values of the rows shown to a gzipped CSV file per view and day in this directory")
+	flagChanges    = flag.String("changes-file", "", "Also append a change journal (NDJSON) of the rows shown to this file")
+	flagCreateSQL  = flag.Bool("create-user-sql", false, "Print the statements creating a monitoring account with the grants "+lib.MyName()+" needs on the server connected to")
+	flagCreateExec = flag.Bool("create-user-execute", false, "Create the monitoring account on the server connected to, which needs an admin account")
+	flagCreateUser = flag.String("create-user", monitor_user.DefaultAccount, "The monitoring account (user@host) to create with --create-user-sql or --create-user-execute")
 	flagCount      = flag.Int("count", 0, "Provide the number of iterations to make (default: 0 is forever)")
+	flagDatabases  = flag.String("databases", "", "Only show the tables of these comma separated databases in the table based views")
 	flagDebug      = flag.Bool("debug", false, "Enabling debug logging")
+	flagEnforce    = flag.Bool("enforce", false, "Kill the statements matching a watchdog kill rule rather than only logging them")
+	flagFilter     = flag.String("filter", "", "Only show rows of the initial view with a column matching this regular expression")
+	flagGrafana    = flag.Bool("grafana-dashboard", false, "Print a Grafana dashboard for the metrics served by --metrics-listen and exit")
+	flagFollow     = flag.Uint64("follow", 0, "Follow the connection with this processlist id on startup")
 	flagHelp       = flag.Bool("help", false, "Provide some help for "+lib.MyName())
+	flagIgnoreDBs  = flag.String("ignore-databases", "", "Don't show the tables of these comma separated databases in the table based views")
 	flagInterval   = flag.Int("interval", 1, "Set the initial poll interval (default 1 second)")
 	flagLimit      = flag.Int("limit", 0, "Show a maximum of limit entries (defaults to screen size if output to screen)")
+	flagMaxKills   = flag.Int("max-kills", 5, "Kill at most this many statements a minute when enforcing the watchdog rules")
+	flagMetrics    = flag.String("metrics-listen", "", "Also serve the values of the rows shown in the Prometheus text format on this address, e.g. :9104")
+	flagProcess    = flag.Bool("local-process", false, "Show the OS statistics of mysqld when it runs on this host")
+	flagPlain      = flag.Bool("plain", false, "Write plain text lines, only the rows which change, for screen readers and braille displays")
+	flagScript     = flag.String("script", "", "Run the commands in this file (view, wait, export, key, ...) as the data is collected")
+	flagSessionLog = flag.String("session-log", "", "Append the actions taken (view changes, resets, ...) with their time to this file")
+	flagProxyAdmin = flag.String("proxy-admin", "", "Also show the statistics of ProxySQL from its admin interface at [user[:password]@]host[:port]")
+	flagRefresh    = flag.Int("refresh", 1, "Redraw the screen this often (in seconds) between collections (0 disables)")
+	flagRestore    = flag.Bool("restore-instruments", false, "Restore the setup_instruments settings left changed by an earlier run which was killed")
+	flagRaw        = flag.Bool("raw-numbers", false, "Show latencies in whole microseconds and amounts without a suffix, e.g. to paste into a spreadsheet")
+	flagSort       = flag.String("sort", "", "Provide a comma separated list of keys to sort the initial view by")
 	flagVersion    = flag.Bool("version", false, "Show the version of "+lib.MyName())
+	flagWarmup     = flag.Duration("warmup", time.Second, "Wait this long between the initial collection and the first one shown so it shows the changes in that time (0 disables)")
+	flagTabs       = flag.String("tabs", "", "Open these views, separated by commas, as tabs (at most 3)")
+	flagTimezone   = flag.String("timezone", "local", "Show the times in this zone: local, UTC or a zone name such as Europe/Madrid")
+	flagTitle      = flag.String("title", "", "Show this title, e.g. the shard and incident, in the header and with the data written")
 	flagView       = flag.String("view", "", "Provide view to show when starting "+lib.MyName()+" (default: table_io_latency)")
+	flagWatchTable = flag.String("watch-table", "", "Show everything known about this table, given as db.table, on one screen")
+	flagWatchdog   = flag.String("watchdog-log", "", "Append the statements matched and killed by the watchdog rules to this file")
+	flagWorkload   = flag.String("fingerprint", "", "Write a JSON summary of the workload (top digests and tables, reads and writes) to this file on exit")
 )
 
 func usage() {
@@ -39,34 +78,154 @@ func usage() {
 	fmt.Println("Usage: " + lib.MyName() + " <options>")
 	fmt.Println("")
 	fmt.Println("Options:")
+	fmt.Println("--absolute                               Start by showing statistics since the server started (toggle with t)")
+	fmt.Println("--allow-cleartext-passwords              Allow sending the password in cleartext for LDAP or PAM accounts (needs --tls or --socket)")
+	fmt.Println("--anomalies=<n>                          Mark the rows changing by more than n standard deviations above their usual change, e.g. 3")
 	fmt.Println("--anonymise=<true|false>                 Anonymise hostname, user, db and table names")
+	fmt.Println("--archive-dir=<dir>                      Also append the values of the rows shown to <dir>/<view>-<date>.csv.gz")
+	fmt.Println("--changes-file=<file>                    Also append a change journal (NDJSON) of the rows shown to the file")
 	fmt.Println("--count=<count>                          Set the number of times to watch")
+	fmt.Println("--create-user=<user@host>                The monitoring account to create (default: " + monitor_user.DefaultAccount + ")")
+	fmt.Println("--create-user-execute                    Create the monitoring account on the server connected to with an admin account")
+	fmt.Println("--create-user-sql                        Print the statements creating a monitoring account with the grants needed by the server connected to")
+	fmt.Println("--conn-max-lifetime=<duration>           Close connections after they have been open this long, e.g. 1h (default: keep them)")
+	fmt.Println("--connect-retries=<n>                    Number of times to try connecting again if MySQL can't be reached (default: 5)")
+	fmt.Println("--databases=<db,...>                     Only show the tables of these databases in the table based views")
 	fmt.Println("--defaults-file=/path/to/defaults.file   Connect to MySQL using given defaults-file")
+	fmt.Println("--defaults-group-suffix=<suffix>         Also read the [client<suffix>] group of the defaults-file")
+	fmt.Println("--demo                                   Show synthetic data instead of connecting to MySQL")
+	fmt.Println("--enforce                                Kill the statements matching a watchdog kill rule (needs --watchdog-log or --session-log)")
+	fmt.Println("--exclude=<regexp>                       Don't show the tables whose db.table name matches the regular expression (may be repeated)")
+	fmt.Println("--filter=<regexp>                        Only show rows of the initial view with a column matching the regular expression")
+	fmt.Println("--fingerprint=<file>                     Write a JSON summary of the workload since the reset (or mark) to the file on exit")
+	fmt.Println("--grafana-dashboard                      Print a Grafana dashboard (JSON) for the metrics served by --metrics-listen and exit")
+	fmt.Println("--follow=<id>                            Follow the connection with this processlist id (stop following with f)")
 	fmt.Println("--help                                   Show this help message")
-	fmt.Println("--host=<hostname>                        MySQL host to connect to")
+	fmt.Println("--host=<hostname>                        MySQL host to connect to, which may be an IPv6 address")
+	fmt.Println("--ignore-databases=<db,...>              Don't show the tables of these databases in the table based views")
+	fmt.Println("--include=<regexp>                       Only show the tables whose db.table name matches the regular expression (may be repeated)")
 	fmt.Println("--interval=<seconds>                     Set the default poll interval (in seconds)")
+	fmt.Println("--ipv4                                   Only connect to the IPv4 addresses of the host")
+	fmt.Println("--ipv6                                   Only connect to the IPv6 addresses of the host")
 	fmt.Println("--limit=<rows>                           Limit the number of lines of output (excluding headers)")
+	fmt.Println("--local-process                          Show mysqld's cpu, io, memory, threads and fds from /proc (mysqld must run on this host)")
+	fmt.Println("--max-idle-conns=<n>                     Maximum number of idle connections kept open (default: 2)")
+	fmt.Println("--max-kills=<n>                          Kill at most this many statements a minute when enforcing (default: 5)")
+	fmt.Println("--max-open-conns=<n>                     Maximum number of connections open at once (default: 5)")
+	fmt.Println("--metrics-listen=<address>               Also serve the values of the rows shown for Prometheus on http://<address>/metrics")
+	fmt.Println("--mysqlx                                 Connect using the X Protocol (default port: 33060)")
 	fmt.Println("--password=<password>                    Password to use when connecting")
+	fmt.Println("--password-command=<command>             Run command to get the password (e.g. an IAM token) on each connect")
+	fmt.Println("--plain                                  Write plain text lines with only the rows which change, for screen readers (keys: type them and press return)")
 	fmt.Println("--port=<port>                            MySQL port to connect to")
+	fmt.Println("--profile=<name>                         Connect using the [<name>] group of the defaults-file")
+	fmt.Println("--proxy-admin=<address>                  Also show ProxySQL's statistics from its admin interface at [user[:password]@]host[:port] (default port 6032)")
+	fmt.Println("--refresh=<seconds>                      Redraw the screen this often between collections so the clock and age of the data move on (default: 1, 0 disables)")
+	fmt.Println("--restore-instruments                    Restore the setup_instruments settings left changed by an earlier run which was killed")
+	fmt.Println("--script=<file>                          Run the commands in the file, e.g. view, wait and export, as the data is collected (see README)")
+	fmt.Println("--session-log=<file>                     Append the actions taken, e.g. changing the view, with their time to this file")
+	fmt.Println("--raw-numbers                            Show latencies in whole microseconds and amounts without a k/M/G suffix (n toggles this)")
 	fmt.Println("--socket=<path>                          MySQL path of the socket to connect to")
+	fmt.Println("--sort=<key,...>                         Sort the initial view by these keys before its default order")
+	fmt.Println("--tabs=<view,...>                        Open up to 3 views as tabs, collected together and changed between with <tab>")
+	fmt.Println("--timezone=<zone>                        Show the times in local time (the default), UTC or a zone name such as Europe/Madrid")
+	fmt.Println("--title=<text>                           Show this title in the header and with the data written, e.g. --title=\"shard-07 incident 1234\"")
+	fmt.Println("--tls=<true|skip-verify>                 Connect to MySQL using TLS")
 	fmt.Println("--user=<user>                            User to connect with")
 	fmt.Println("--use-environment                        Connect to MySQL using a go dsn collected from MYSQL_DSN e.g. MYSQL_DSN='test_user:test_pass@tcp(127.0.0.1:3306)/performance_schema'")
+	fmt.Println("--watch-table=<db.table>                 Show the I/O, index usage, lock waits, file I/O and digests of this table on one screen")
+	fmt.Println("--watchdog-log=<file>                    Append the statements matched and killed by the watchdog rules in ~/.pstoprc to the file")
+	fmt.Println("--warmup=<duration>                      Wait this long, e.g. 500ms, after the initial collection so the first data shown has changes (default: 1s)")
 	fmt.Println("--version                                Show the version")
 	fmt.Println("--view=<view>                            Determine the view you want to see when " + lib.MyName() + " starts (default: table_io_latency")
-	fmt.Println("                                         Possible values: table_io_latency table_io_ops file_io_latency table_lock_latency user_latency mutex_latency stages_latency")
+	fmt.Println("                                         Possible values: table_io_latency table_io_ops file_io_latency table_lock_latency user_latency mutex_latency stages_latency lock_waits statement_efficiency table_cache key_cache query_cache statement_stages unused_indexes binlog_events ddl_progress lock_users ps_sizing program_latency slo_budget prepared_statements wait_events idle_time proxy_digests proxy_backends")
+}
+
+// createUser prints the statements creating a monitoring account with
+// the grants needed by the server connected to, or runs them
+func createUser(account string, execute bool) {
+	conn := connector.NewConnector(connectorFlags)
+	version := global.NewVariables(conn.Handle()).Get("version")
+
+	password, err := monitor_user.NewPassword()
+	if err != nil {
+		log.Fatal("Unable to generate a password: ", err)
+	}
+	statements, err := monitor_user.Statements(version, account, password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !execute {
+		fmt.Println("-- the account " + lib.MyName() + " needs on MySQL " + version)
+		for _, s := range statements {
+			fmt.Println(s)
+		}
+		return
+	}
+	existed, err := monitor_user.Exists(conn.Handle(), account)
+	if err != nil {
+		log.Fatal("Unable to check if the monitoring account exists: ", err)
+	}
+	if err := monitor_user.Create(conn.Handle(), statements); err != nil {
+		log.Fatal("Unable to create the monitoring account: ", err)
+	}
+	if existed {
+		fmt.Println("The account " + account + " already existed on MySQL " + version + " so keeps its password and was only granted what " + lib.MyName() + " needs")
+		return
+	}
+	fmt.Println("Created the account " + account + " on MySQL " + version + " with the password: " + password)
+}
+
+// grafanaDashboard prints a Grafana dashboard for the metrics served by
+// --metrics-listen. The columns of each view are those of the demo server,
+// which has every view and whose rows have the same columns as MySQL's.
+func grafanaDashboard() {
+	*connectorFlags.Demo = true
+	proxy, err := proxysql.Open(proxysql.DefaultAddress, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := app.NewApp(app.Settings{
+		Absolute: true,
+		Conn:     connector.NewConnector(connectorFlags),
+		Interval: 1,
+		Stdout:   true,
+		Schemas:  schema_filter.NewFilter("", "", nil, nil),
+		Proxy:    proxy,
+		Disp:     display.NewGrafanaDisplayTo(os.Stdout),
+	})
+	app.RunOnce()
+	app.Cleanup()
 }
 
 func main() {
 	connectorFlags = connector.Flags{
-		DefaultsFile:   flag.String("defaults-file", "", "Define the defaults file to read"),
-		Host:           flag.String("host", "", "Provide the hostname of the MySQL to connect to"),
-		Password:       flag.String("password", "", "Provide the password when connecting to the MySQL server"),
-		Port:           flag.Int("port", 0, "Provide the port number of the MySQL to connect to (default: 3306)"), /* Port is deliberately 0 here, defaults to 3306 elsewhere */
-		Socket:         flag.String("socket", "", "Provide the path to the local MySQL server to connect to"),
-		User:           flag.String("user", "", "Provide the username to connect with to MySQL (default: $USER)"),
-		UseEnvironment: flag.Bool("use-environment", false, "Use the environment variable MYSQL_DSN (go dsn) to connect with to MySQL"),
+		DefaultsFile:        flag.String("defaults-file", "", "Define the defaults file to read"),
+		DefaultsGroupSuffix: flag.String("defaults-group-suffix", "", "Also read the [client<suffix>] group from the defaults file"),
+		Demo:                flag.Bool("demo", false, "Show synthetic data instead of connecting to MySQL"),
+		AllowCleartext:      flag.Bool("allow-cleartext-passwords", false, "Allow sending the password in cleartext for LDAP or PAM accounts (needs --tls or --socket)"),
+		ConnMaxLifetime:     flag.Duration("conn-max-lifetime", 0, "Close connections to MySQL after they have been open this long (default: 0, keep them)"),
+		ConnectRetries:      flag.Int("connect-retries", connector.ConnectRetries, "Number of times to try connecting again if MySQL can't be reached"),
+		Host:                flag.String("host", "", "Provide the hostname of the MySQL to connect to"),
+		IPv4:                flag.Bool("ipv4", false, "Only connect to the IPv4 addresses of the host"),
+		IPv6:                flag.Bool("ipv6", false, "Only connect to the IPv6 addresses of the host"),
+		MaxIdleConns:        flag.Int("max-idle-conns", connector.MaxIdleConns, "Maximum number of idle connections to MySQL kept open"),
+		MaxOpenConns:        flag.Int("max-open-conns", connector.MaxOpenConns, "Maximum number of connections open to MySQL at once"),
+		Mysqlx:              flag.Bool("mysqlx", false, "Connect using the X Protocol (default port: 33060)"),
+		Password:            flag.String("password", "", "Provide the password when connecting to the MySQL server"),
+		PasswordCommand:     flag.String("password-command", "", "Run this command to get the password each time a connection is made (e.g. to generate an IAM token)"),
+		Profile:             flag.String("profile", "", "Connect using the settings in the [<profile>] group of the defaults file"),
+		Port:                flag.Int("port", 0, "Provide the port number of the MySQL to connect to (default: 3306)"), /* Port is deliberately 0 here, defaults to 3306 elsewhere */
+		Socket:              flag.String("socket", "", "Provide the path to the local MySQL server to connect to"),
+		TLS:                 flag.String("tls", "", "Connect using TLS: true or skip-verify"),
+		User:                flag.String("user", "", "Provide the username to connect with to MySQL (default: $USER)"),
+		UseEnvironment:      flag.Bool("use-environment", false, "Use the environment variable MYSQL_DSN (go dsn) to connect with to MySQL"),
 	}
 
+	flag.Var(&flagInclude, "include", "Only show the tables whose db.table name matches this regular expression (may be repeated)")
+	flag.Var(&flagExclude, "exclude", "Don't show the tables whose db.table name matches this regular expression (may be repeated)")
 	flag.Parse()
 
 	if *cpuprofile != "" {
@@ -89,15 +248,106 @@ func main() {
 		usage()
 		return
 	}
+	lib.SetRawNumbers(*flagRaw)
+	if err := lib.SetTimezone(*flagTimezone); err != nil {
+		log.Fatal("--timezone should be local, UTC or a zone name such as Europe/Madrid: ", err)
+	}
+	if *flagAnomalies < 0 {
+		log.Fatal("--anomalies should not be negative")
+	}
+
+	if *flagCreateSQL || *flagCreateExec {
+		createUser(*flagCreateUser, *flagCreateExec)
+		return
+	}
+	if *flagGrafana {
+		grafanaDashboard()
+		return
+	}
+
+	if *flagRefresh < 0 {
+		log.Fatal("--refresh should be a number of seconds, 0 to disable it")
+	}
+	if *flagMaxKills < 1 {
+		log.Fatal("--max-kills should be at least 1")
+	}
+	refresh := time.Second * time.Duration(*flagRefresh)
+	var script []ui_script.Command
+	if *flagScript != "" {
+		var err error
+		if script, err = ui_script.Load(*flagScript); err != nil {
+			log.Fatal("Unable to read the script ", *flagScript, ": ", err)
+		}
+	}
+	// connect before the screen is taken over so any progress connecting
+	// and retrying can be seen
+	conn := connector.NewConnector(connectorFlags)
+	var disp display.Display
+	if *flagPlain {
+		refresh = 0 // only write changes as they are collected
+		disp = display.NewPlainDisplay(*flagLimit)
+	} else {
+		disp = display.NewScreenDisplay(*flagLimit, false)
+	}
+	var sinks []display.Display
+	if *flagChanges != "" {
+		f, err := os.OpenFile(*flagChanges, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal("Unable to open the change journal: ", err)
+		}
+		defer f.Close()
+		sinks = append(sinks, display.NewChangesDisplayTo(f, 0))
+	}
+	if *flagArchive != "" {
+		archive, err := display.NewArchiveDisplay(*flagArchive)
+		if err != nil {
+			log.Fatal("Unable to create the archive directory: ", err)
+		}
+		sinks = append(sinks, archive)
+	}
+	if *flagMetrics != "" {
+		metrics, err := display.NewMetricsDisplay(*flagMetrics)
+		if err != nil {
+			log.Fatal("Unable to serve metrics: ", err)
+		}
+		sinks = append(sinks, metrics)
+	}
+	if len(sinks) > 0 {
+		disp = display.NewMultiDisplay(disp, sinks...)
+	}
+	proxy, err := proxysql.Open(*flagProxyAdmin, *connectorFlags.Demo)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	settings := app.Settings{
+		Absolute:  *flagAbsolute,
 		Anonymise: *flagAnonymise,
-		Conn:      connector.NewConnector(connectorFlags),
+		Conn:      conn,
 		Interval:  *flagInterval,
 		Count:     *flagCount,
 		Stdout:    false,
+		Sort:      *flagSort,
+		Filter:    *flagFilter,
+		Follow:    *flagFollow,
+		Watch:     *flagWatchTable,
+		Tabs:      *flagTabs,
+		Process:   *flagProcess,
+		Schemas:   schema_filter.NewFilter(*flagDatabases, *flagIgnoreDBs, flagInclude, flagExclude),
+		Audit:     *flagSessionLog,
+		Warmup:    *flagWarmup,
+		Title:     *flagTitle,
+		Restore:   *flagRestore,
+		Refresh:   refresh,
+		Enforce:   *flagEnforce,
+		MaxKills:  *flagMaxKills,
+		Watchdog:  *flagWatchdog,
+		Script:    script,
+		Workload:  *flagWorkload,
+		Anomalies: *flagAnomalies,
+		Proxy:     proxy,
 		View:      *flagView,
-		Disp:      display.NewScreenDisplay(*flagLimit, false),
+		Disp:      disp,
 	}
 
 	app := app.NewApp(settings)
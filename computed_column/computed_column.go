@@ -0,0 +1,84 @@
+// Package computed_column provides the extra columns which may be
+// configured for a view, calculated from the values of each row.
+//
+// Columns are configured in a [columns <view>] section of ~/.pstoprc, e.g.
+// [columns table_io_latency]
+// write_pct = (sum_timer_insert + sum_timer_update + sum_timer_delete) / sum_timer_wait * 100
+// fetch_ms = sum_timer_fetch / 1000000000
+// Expressions use + - * / and parentheses over numbers and the names of
+// the values of the view's rows. Columns are shown in name order.
+package computed_column
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// sectionPrefix is the start of the ~/.pstoprc sections defining columns
+const sectionPrefix = "columns "
+
+// Column is a column calculated from the values of a row
+type Column struct {
+	Name       string
+	expression node
+}
+
+var (
+	configured       map[string][]Column // columns by view name
+	loadedConfigured bool                // Have we [attempted to] load ~/.pstoprc?
+)
+
+// NewColumn returns the column with the given name and expression
+func NewColumn(name, expression string) (Column, error) {
+	n, err := parse(expression)
+	if err != nil {
+		return Column{}, fmt.Errorf("column %s: %v", name, err)
+	}
+
+	return Column{Name: name, expression: n}, nil
+}
+
+// load the [columns <view>] sections of ~/.pstoprc (once). Invalid
+// expressions are fatal.
+func load() {
+	if loadedConfigured {
+		return
+	}
+	loadedConfigured = true
+
+	configured = make(map[string][]Column)
+	for view, section := range rc.Sections(sectionPrefix) {
+		var columns []Column
+		for name, expression := range section {
+			c, err := NewColumn(name, expression)
+			if err != nil {
+				log.Fatal("Invalid computed column for view ", view, ": ", err)
+			}
+			columns = append(columns, c)
+		}
+		sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+		configured[view] = columns
+	}
+	logger.Println("computed_column.load() found columns for", len(configured), "view(s)")
+}
+
+// Configured returns the columns configured for the given view
+func Configured(view string) []Column {
+	load()
+	return configured[view]
+}
+
+// Value returns the column's value for a row with the given values,
+// or an empty string if it can't be calculated
+func (c Column) Value(values map[string]uint64) string {
+	value, ok := c.expression.eval(values)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%.2f", value)
+}
@@ -0,0 +1,40 @@
+package computed_column
+
+import (
+	"testing"
+)
+
+func TestValue(t *testing.T) {
+	values := map[string]uint64{"sum_timer_wait": 200, "sum_timer_write": 50, "count_star": 0}
+
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"sum_timer_write / sum_timer_wait * 100", "25.00"},
+		{"SUM_TIMER_WAIT - sum_timer_write", "150.00"},
+		{"(sum_timer_wait - sum_timer_write) / 2", "75.00"},
+		{"sum_timer_wait - sum_timer_write / 2", "175.00"},
+		{"-sum_timer_write + 1.5", "-48.50"},
+		{"sum_timer_wait / count_star", ""},
+		{"unknown_column * 2", ""},
+	}
+
+	for _, test := range tests {
+		c, err := NewColumn("test", test.expression)
+		if err != nil {
+			t.Fatalf("NewColumn(%q) failed: %v", test.expression, err)
+		}
+		if got := c.Value(values); got != test.want {
+			t.Errorf("Value(%q) = %q, want %q", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestNewColumnErrors(t *testing.T) {
+	for _, expression := range []string{"", "a +", "(a", "a b", "a $ b", "1..2"} {
+		if _, err := NewColumn("test", expression); err == nil {
+			t.Errorf("NewColumn(%q) did not fail", expression)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package computed_column
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// node is a parsed part of an expression
+type node interface {
+	// eval returns the value of the node and false if it can't be
+	// calculated, e.g. for a division by zero or an unknown column
+	eval(values map[string]uint64) (float64, bool)
+}
+
+// number is a constant
+type number float64
+
+func (n number) eval(values map[string]uint64) (float64, bool) {
+	return float64(n), true
+}
+
+// column is the value of a column of the row
+type column string
+
+func (c column) eval(values map[string]uint64) (float64, bool) {
+	value, found := values[string(c)]
+	return float64(value), found
+}
+
+// negate is a unary minus
+type negate struct {
+	operand node
+}
+
+func (n negate) eval(values map[string]uint64) (float64, bool) {
+	value, ok := n.operand.eval(values)
+	return -value, ok
+}
+
+// binary is an arithmetic operation on two nodes
+type binary struct {
+	op          byte
+	left, right node
+}
+
+func (b binary) eval(values map[string]uint64) (float64, bool) {
+	left, ok := b.left.eval(values)
+	if !ok {
+		return 0, false
+	}
+	right, ok := b.right.eval(values)
+	if !ok {
+		return 0, false
+	}
+
+	switch b.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	case '/':
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+
+	return 0, false
+}
+
+// parser reads an expression of numbers and column names combined
+// with + - * / and parentheses, with the usual precedence
+type parser struct {
+	input string
+	pos   int
+}
+
+// parse returns the parsed expression
+func parse(input string) (node, error) {
+	p := &parser{input: input}
+
+	n, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+
+	return n, nil
+}
+
+// skipSpace moves past any white space
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the next character which is not white space, 0 at the end
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+// expression is term { (+|-) term }
+func (p *parser) expression() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// term is factor { (*|/) factor }
+func (p *parser) term() (node, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// factor is a number, a column name, -factor or ( expression )
+func (p *parser) factor() (node, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case c == '-':
+		p.pos++
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negate{operand: operand}, nil
+	case c == '(':
+		p.pos++
+		n, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		p.pos++
+		return n, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] == '.' || (p.input[p.pos] >= '0' && p.input[p.pos] <= '9')) {
+			p.pos++
+		}
+		value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return number(value), nil
+	case isNameChar(c):
+		start := p.pos
+		for p.pos < len(p.input) && (isNameChar(p.input[p.pos]) || (p.input[p.pos] >= '0' && p.input[p.pos] <= '9')) {
+			p.pos++
+		}
+		return column(strings.ToLower(p.input[start:p.pos])), nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+// isNameChar returns true if the character may start a column name
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
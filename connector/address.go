@@ -0,0 +1,125 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/mysqlx"
+)
+
+// dialTimeout is how long to wait for the addresses of the host and for
+// each of them to accept the connection with --ipv4 or --ipv6, as the
+// drivers don't pass the dsn's timeout to the dialer
+const dialTimeout = 10 * time.Second
+
+// SetNetwork restricts the connections over TCP to IPv4 (tcp4) or IPv6
+// (tcp6) addresses, or allows either (tcp)
+func (c *Connector) SetNetwork(network string) {
+	c.network = network
+}
+
+// bracketHost returns the host in the form used in a dsn: IPv6 literals
+// need brackets so their colons aren't taken for the port separator
+func bracketHost(host string) string {
+	if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		return "[" + host + "]"
+	}
+
+	return host
+}
+
+// wantIP returns true if the address belongs to the family of the network
+func wantIP(network string, ip net.IP) bool {
+	switch network {
+	case "tcp4":
+		return ip.To4() != nil
+	case "tcp6":
+		return ip.To4() == nil
+	}
+
+	return true
+}
+
+// lookupFunc returns the addresses of a host
+type lookupFunc func(host string) ([]net.IP, error)
+
+// dialFunc connects to an address
+type dialFunc func(network, address string) (net.Conn, error)
+
+// dialAddresses connects to the first address of the host which accepts
+// the connection, trying each address of the network's family in turn
+func dialAddresses(network, address string, lookup lookupFunc, dial dialFunc) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = lookup(host); err != nil {
+			return nil, err
+		}
+	}
+
+	var addresses []string
+	for _, ip := range ips {
+		if wantIP(network, ip) {
+			addresses = append(addresses, net.JoinHostPort(ip.String(), port))
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("%s has no %s address", host, map[string]string{"tcp4": "IPv4", "tcp6": "IPv6"}[network])
+	}
+
+	var failures []string
+	for _, a := range addresses {
+		conn, err := dial(network, a)
+		if err == nil {
+			return conn, nil
+		}
+		if len(addresses) == 1 {
+			return nil, err
+		}
+		logger.Println("connector: unable to connect to", a, "trying the next address:", err)
+		failures = append(failures, err.Error())
+	}
+
+	return nil, fmt.Errorf("unable to connect to any of the %d addresses of %s: %s", len(addresses), host, strings.Join(failures, "; "))
+}
+
+// lookupIP returns the addresses of the host, giving up after dialTimeout
+func lookupIP(host string) ([]net.IP, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// registerDial makes the connections over TCP only use the addresses of
+// the connector's network if it is restricted to IPv4 or IPv6. Otherwise
+// the drivers dial themselves, which uses the dsn's timeout and already
+// tries each address of the host in turn.
+func (c *Connector) registerDial() {
+	if c.network != "tcp4" && c.network != "tcp6" {
+		return
+	}
+	network := c.network
+	dial := func(n, address string) (net.Conn, error) {
+		if n != "tcp" {
+			return net.DialTimeout(n, address, dialTimeout)
+		}
+		return dialAddresses(network, address, lookupIP, func(n, a string) (net.Conn, error) {
+			return net.DialTimeout(n, a, dialTimeout)
+		})
+	}
+
+	logger.Println("connector: only connecting over", network)
+	mysql.RegisterDial("tcp", func(address string) (net.Conn, error) { return dial("tcp", address) })
+	mysqlx.Dial = dial
+}
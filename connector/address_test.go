@@ -0,0 +1,70 @@
+package connector
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestBracketHost(t *testing.T) {
+	tests := map[string]string{
+		"db1.example.com": "db1.example.com",
+		"10.1.2.3":        "10.1.2.3",
+		"::1":             "[::1]",
+		"[2001:db8::10]":  "[2001:db8::10]",
+	}
+
+	for host, expected := range tests {
+		if got := bracketHost(host); got != expected {
+			t.Errorf("bracketHost(%q) = %q, expected %q", host, got, expected)
+		}
+	}
+}
+
+func TestDialAddresses(t *testing.T) {
+	lookup := func(host string) ([]net.IP, error) {
+		if host != "db1" {
+			return nil, errors.New("no such host")
+		}
+		return []net.IP{net.ParseIP("2001:db8::10"), net.ParseIP("10.1.2.3"), net.ParseIP("10.1.2.4")}, nil
+	}
+
+	tests := []struct {
+		network  string
+		address  string
+		accept   string // the address accepting the connection
+		tried    string // the addresses tried
+		expected string // part of the error, empty if none
+	}{
+		{"tcp", "db1:3306", "10.1.2.3:3306", "[2001:db8::10]:3306 10.1.2.3:3306", ""},
+		{"tcp4", "db1:3306", "10.1.2.4:3306", "10.1.2.3:3306 10.1.2.4:3306", ""},
+		{"tcp6", "db1:3306", "[2001:db8::10]:3306", "[2001:db8::10]:3306", ""},
+		{"tcp", "db1:3306", "", "[2001:db8::10]:3306 10.1.2.3:3306 10.1.2.4:3306", "any of the 3 addresses of db1"},
+		{"tcp", "[::1]:3306", "[::1]:3306", "[::1]:3306", ""},
+		{"tcp4", "[::1]:3306", "", "", "::1 has no IPv4 address"},
+		{"tcp", "db2:3306", "", "", "no such host"},
+	}
+
+	for _, test := range tests {
+		var tried []string
+		dial := func(network, address string) (net.Conn, error) {
+			tried = append(tried, address)
+			if address != test.accept {
+				return nil, errors.New("connection refused")
+			}
+			return nil, nil
+		}
+
+		_, err := dialAddresses(test.network, test.address, lookup, dial)
+		if got := strings.Join(tried, " "); got != test.tried {
+			t.Errorf("dialAddresses(%q, %q) tried %q, expected %q", test.network, test.address, got, test.tried)
+		}
+		if test.expected == "" && err != nil {
+			t.Errorf("dialAddresses(%q, %q) failed: %v", test.network, test.address, err)
+		}
+		if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+			t.Errorf("dialAddresses(%q, %q) error %v, expected it to contain %q", test.network, test.address, err, test.expected)
+		}
+	}
+}
@@ -5,14 +5,21 @@ package connector
 import (
 	"database/sql"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/sjmudd/mysql_defaults_file"
+	"github.com/sjmudd/ps-top/demo"
+	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/mysqlx"
 )
 
 const (
 	db           = "performance_schema"
-	MaxOpenConns = 5 // hard-coded value!
+	MaxOpenConns = 5 // default limit of the connections open to the server
+	MaxIdleConns = 2 // default limit of the idle connections kept open
 	sqlDriver    = "mysql"
 
 	// ConnectByDefaultsFile indicates we want to connect using a MySQL defaults file
@@ -21,6 +28,8 @@ const (
 	ConnectByComponents = iota
 	// ConnectByEnvironment indicates we want to connect by using MYSQL_DSN environment variable
 	ConnectByEnvironment = iota
+	// ConnectByDemo indicates we want to use synthetic data rather than connect to MySQL
+	ConnectByDemo = iota
 )
 
 // Connector contains information on how you want to connect
@@ -28,9 +37,30 @@ type Connector struct {
 	connectMethod int
 	components    map[string]string
 	defaultsFile  string
+	params        string             // extra dsn parameters, e.g. tls=true
+	provider      CredentialProvider // provides the password when connecting (optional)
+	xProtocol     bool               // connect using the X Protocol rather than the classic one
+	pool          PoolLimits         // limits of the connection pool
+	retries       int                // times to try connecting again if it fails
+	target        string             // where we connect to, with the password masked
+	network       string             // tcp4 or tcp6 to only connect over IPv4 or IPv6
 	dbh           *sql.DB
 }
 
+// PoolLimits limits the connections the pool keeps open to the server.
+// The collectors share the pool so a query which hangs only blocks the
+// others once all the connections are in use.
+type PoolLimits struct {
+	MaxOpenConns    int           // maximum connections open at once
+	MaxIdleConns    int           // maximum idle connections kept open
+	ConnMaxLifetime time.Duration // close connections after this long, 0 keeps them
+}
+
+// DefaultPoolLimits returns the limits used if none are set
+func DefaultPoolLimits() PoolLimits {
+	return PoolLimits{MaxOpenConns: MaxOpenConns, MaxIdleConns: MaxIdleConns}
+}
+
 // Handle returns the database handle
 func (c Connector) Handle() *sql.DB {
 	return c.dbh
@@ -51,15 +81,61 @@ func (c *Connector) SetComponents(components map[string]string) {
 	c.components = components
 }
 
+// SetParams sets extra dsn parameters to use when connecting by components
+func (c *Connector) SetParams(params string) {
+	c.params = params
+}
+
+// SetCredentialProvider specifies a provider which gives the password
+// each time a connection is made, rather than using a fixed password
+func (c *Connector) SetCredentialProvider(provider CredentialProvider) {
+	c.provider = provider
+}
+
+// PoolLimits returns the limits of the connection pool
+func (c Connector) PoolLimits() PoolLimits {
+	if c.pool.MaxOpenConns == 0 {
+		return DefaultPoolLimits()
+	}
+	return c.pool
+}
+
+// SetPoolLimits sets the limits of the connection pool, which are
+// applied when connecting
+func (c *Connector) SetPoolLimits(pool PoolLimits) {
+	c.pool = pool
+}
+
+// SetXProtocol specifies that connections by components use the X Protocol
+func (c *Connector) SetXProtocol(xProtocol bool) {
+	c.xProtocol = xProtocol
+}
+
+// SetRetries sets how many times to try connecting again if the server
+// can't be reached
+func (c *Connector) SetRetries(retries int) {
+	c.retries = retries
+}
+
 // postConnectAction has things to do after connecting
 func (c *Connector) postConnectAction() {
 	// without calling Ping() we don't actually connect.
-	if err := c.dbh.Ping(); err != nil {
-		log.Fatal(err)
+	var err error
+	if c.connectMethod == ConnectByDemo {
+		err = c.dbh.Ping()
+	} else {
+		err = newProgress(c.target).connect(c.dbh.Ping, c.retries)
+	}
+	if err != nil {
+		log.Fatal(explainError(err, strings.Contains(c.params, "tls=")))
 	}
 
-	// deliberately limit the pool size to 5 to avoid "problems" if any queries hang.
-	c.dbh.SetMaxOpenConns(MaxOpenConns)
+	// deliberately limit the pool size to avoid "problems" if any queries hang.
+	pool := c.PoolLimits()
+	logger.Println("Connector.postConnectAction() pool limits: max open:", pool.MaxOpenConns, "max idle:", pool.MaxIdleConns, "max lifetime:", pool.ConnMaxLifetime)
+	c.dbh.SetMaxOpenConns(pool.MaxOpenConns)
+	c.dbh.SetMaxIdleConns(pool.MaxIdleConns)
+	c.dbh.SetConnMaxLifetime(pool.ConnMaxLifetime)
 }
 
 // SetConnectBy records how we want to connect
@@ -71,14 +147,36 @@ func (c *Connector) SetConnectBy(connectHow int) {
 func (c *Connector) Connect() {
 	var err error
 
+	c.registerDial()
+
 	switch {
 	case c.connectMethod == ConnectByComponents:
 		logger.Println("ConnectByComponents() Connecting...")
 
+		if host, found := c.components["host"]; found {
+			c.components["host"] = bracketHost(host)
+		}
 		newDsn := mysql_defaults_file.BuildDSN(c.components, db)
-		c.dbh, err = sql.Open(sqlDriver, newDsn)
+		if c.params != "" {
+			newDsn += "?" + c.params
+		}
+		c.target = lib.MaskPassword(newDsn)
+		if c.provider != nil {
+			logger.Println("ConnectByComponents() using a credential provider for the password")
+			credentialProvider = c.provider
+			c.dbh, err = sql.Open(credentialsDriver, newDsn)
+		} else if c.xProtocol {
+			logger.Println("ConnectByComponents() using the X Protocol")
+			c.dbh, err = sql.Open(mysqlx.DriverName, newDsn)
+		} else {
+			c.dbh, err = sql.Open(sqlDriver, newDsn)
+		}
 	case c.connectMethod == ConnectByDefaultsFile:
 		logger.Println("ConnectByDefaults_file() Connecting...")
+		c.target = "the settings in " + c.defaultsFile
+		if c.defaultsFile == "" {
+			c.target = "the settings in ~/.my.cnf"
+		}
 
 		c.dbh, err = mysql_defaults_file.OpenUsingDefaultsFile(sqlDriver, c.defaultsFile, db)
 	case c.connectMethod == ConnectByEnvironment:
@@ -92,9 +190,13 @@ func (c *Connector) Connect() {
 		 *  2.12, “Environment Variables”.                                          *
 		 ****************************************************************************/
 		logger.Println("ConnectByEnvironment() Connecting...")
+		c.target = lib.MaskPassword(os.Getenv("MYSQL_DSN"))
 		c.dbh, err = mysql_defaults_file.OpenUsingEnvironment(sqlDriver)
+	case c.connectMethod == ConnectByDemo:
+		logger.Println("ConnectByDemo() Using synthetic data...")
+		c.dbh, err = sql.Open(demo.DriverName, "")
 	default:
-		log.Fatal("Connector.Connect() c.connectMethod not ConnectByDefaultsFile/ConnectByComponents/ConnectByEnvironment")
+		log.Fatal("Connector.Connect() c.connectMethod not ConnectByDefaultsFile/ConnectByComponents/ConnectByEnvironment/ConnectByDemo")
 	}
 
 	// we catch Open...() errors here
@@ -124,3 +226,9 @@ func (c *Connector) ConnectByEnvironment() {
 	c.SetConnectBy(ConnectByEnvironment)
 	c.Connect()
 }
+
+// ConnectByDemo "connects" to a synthetic server which provides demo data
+func (c *Connector) ConnectByDemo() {
+	c.SetConnectBy(ConnectByDemo)
+	c.Connect()
+}
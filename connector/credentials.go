@@ -0,0 +1,91 @@
+package connector
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// credentialsDriver is the name of the sql driver which asks a
+// CredentialProvider for the password each time it connects
+const credentialsDriver = "mysql-credentials"
+
+// CredentialProvider provides the password to use when connecting to
+// MySQL. It is called for every new connection so it may return a
+// short-lived token such as an AWS RDS or GCP Cloud SQL IAM
+// authentication token.
+type CredentialProvider interface {
+	Password() (string, error)
+}
+
+// CommandProvider provides the password by running a shell command and
+// using its output, e.g. "aws rds generate-db-auth-token ...".
+type CommandProvider struct {
+	Command string
+}
+
+// Password runs the command and returns its output without any
+// surrounding whitespace.
+func (p CommandProvider) Password() (string, error) {
+	logger.Println("CommandProvider.Password() running:", p.Command)
+
+	out, err := exec.Command("sh", "-c", p.Command).Output()
+	if err != nil {
+		return "", fmt.Errorf("password command %q failed: %v", p.Command, err)
+	}
+	password := strings.TrimSpace(string(out))
+	if password == "" {
+		return "", fmt.Errorf("password command %q returned no password", p.Command)
+	}
+
+	return password, nil
+}
+
+// the provider used by the credentials driver
+var credentialProvider CredentialProvider
+
+// credentialDriver opens MySQL connections using the password given
+// by the credential provider at the time of connecting. The database/sql
+// pool calls Open() each time it needs a new connection so expired
+// tokens are refreshed when reconnecting.
+type credentialDriver struct{}
+
+// Open gets a new password and opens a connection with it
+func (d credentialDriver) Open(dsn string) (driver.Conn, error) {
+	if credentialProvider == nil {
+		return nil, errors.New("no credential provider configured")
+	}
+	dsn, err := withPassword(dsn, credentialProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.MySQLDriver{}.Open(dsn)
+}
+
+// withPassword returns the dsn with the password given by the provider
+func withPassword(dsn string, provider CredentialProvider) (string, error) {
+	password, err := provider.Password()
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.Passwd = password
+
+	return cfg.FormatDSN(), nil
+}
+
+func init() {
+	sql.Register(credentialsDriver, credentialDriver{})
+}
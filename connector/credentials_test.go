@@ -0,0 +1,59 @@
+package connector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestCommandProvider(t *testing.T) {
+	tests := []struct {
+		command  string
+		password string
+		fails    bool
+	}{
+		{"echo ' token-1234 '", "token-1234", false},
+		{"exit 1", "", true},     // the command fails
+		{"echo '   '", "", true}, // no password
+		{"true", "", true},       // no output
+	}
+
+	for _, test := range tests {
+		password, err := CommandProvider{Command: test.command}.Password()
+		if (err != nil) != test.fails {
+			t.Errorf("Password() of %q returned the error %v, expected an error: %v", test.command, err, test.fails)
+		}
+		if password != test.password {
+			t.Errorf("Password() of %q returned %q, expected %q", test.command, password, test.password)
+		}
+	}
+}
+
+// fixedProvider returns the same password or error each time
+type fixedProvider struct {
+	password string
+	err      error
+}
+
+func (p fixedProvider) Password() (string, error) { return p.password, p.err }
+
+func TestWithPassword(t *testing.T) {
+	dsn := "ps_top@tcp(db:3306)/performance_schema?tls=true&allowCleartextPasswords=true"
+
+	got, err := withPassword(dsn, fixedProvider{password: "to:k@en/1"})
+	if err != nil {
+		t.Fatalf("withPassword() returned an unexpected error: %v", err)
+	}
+	cfg, err := mysql.ParseDSN(got)
+	if err != nil {
+		t.Fatalf("withPassword() returned the DSN %q which can't be parsed: %v", got, err)
+	}
+	if cfg.User != "ps_top" || cfg.Passwd != "to:k@en/1" || cfg.Addr != "db:3306" || cfg.DBName != "performance_schema" || cfg.TLSConfig != "true" || !cfg.AllowCleartextPasswords {
+		t.Errorf("withPassword() returned %q, which doesn't keep the settings of %q with the new password", got, dsn)
+	}
+
+	if _, err := withPassword(dsn, fixedProvider{err: errors.New("token expired")}); err == nil {
+		t.Errorf("withPassword() expected the provider's error")
+	}
+}
@@ -0,0 +1,79 @@
+package connector
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	go_ini "github.com/vaughan0/go-ini" // not sure what to do with dashes in names
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+const (
+	defaultDefaultsFile = "~/.my.cnf"
+	clientGroup         = "client"
+)
+
+// the option names we understand in a defaults file group
+var defaultsFileOptions = []string{"user", "password", "socket", "host", "port", "database"}
+
+// convert a leading ~ to $HOME
+func convertFilename(filename string) string {
+	if strings.HasPrefix(filename, "~") {
+		filename = os.Getenv("HOME") + filename[1:]
+	}
+
+	return filename
+}
+
+// defaultsFileGroups returns the groups to read from the defaults
+// file in the order they should be applied. As with the MySQL
+// clients [client] is read first, then [client<suffix>] if a suffix
+// is given and finally the [<profile>] group if a profile is given.
+// Values in later groups override those in earlier ones.
+func defaultsFileGroups(groupSuffix, profile string) []string {
+	groups := []string{clientGroup}
+
+	if groupSuffix != "" {
+		groups = append(groups, clientGroup+groupSuffix)
+	}
+	if profile != "" {
+		groups = append(groups, profile)
+	}
+
+	return groups
+}
+
+// defaultsFileComponents reads the given groups from the defaults file
+// and returns the connection parameters found as a map.
+func defaultsFileComponents(defaultsFile string, groups []string) map[string]string {
+	if defaultsFile == "" {
+		defaultsFile = defaultDefaultsFile
+	}
+	defaultsFile = convertFilename(defaultsFile)
+
+	i, err := go_ini.LoadFile(defaultsFile)
+	if err != nil {
+		log.Fatal("Could not load defaults file ", defaultsFile, ": ", err)
+	}
+
+	components := make(map[string]string)
+	for _, group := range groups {
+		section, ok := i[group]
+		if !ok {
+			if group != clientGroup {
+				log.Fatal("Defaults file ", defaultsFile, " has no group [", group, "]")
+			}
+			continue
+		}
+		logger.Println("defaultsFileComponents() reading group [" + group + "]")
+		for _, option := range defaultsFileOptions {
+			if value, ok := section[option]; ok {
+				components[option] = value
+			}
+		}
+	}
+
+	return components
+}
@@ -0,0 +1,56 @@
+package connector
+
+import (
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// hint returns how the user might fix an error seen when connecting, or
+// an empty string if there's nothing more to say than the error itself.
+// The driver's own messages mostly talk about DSN settings which users
+// of ps-top don't set directly.
+func hint(err error, tls bool) string {
+	switch err {
+	case mysql.ErrUnknownPlugin:
+		return "The account uses an authentication plugin the classic protocol driver doesn't support, " +
+			"e.g. caching_sha2_password (the MySQL 8.0 default) or sha256_password. " +
+			"Connect with --mysqlx (adding --tls if the account has not logged in since the server started) " +
+			"or use an account IDENTIFIED WITH mysql_native_password."
+	case mysql.ErrCleartextPassword:
+		if !tls {
+			return "The account uses an authentication plugin which needs the password in cleartext, e.g. LDAP or PAM. " +
+				"Connect with --tls and --allow-cleartext-passwords."
+		}
+		return "The account uses an authentication plugin which needs the password in cleartext, e.g. LDAP or PAM. " +
+			"Add --allow-cleartext-passwords."
+	case mysql.ErrOldPassword:
+		return "The account still uses the pre-4.1 password hashing, which is not supported. " +
+			"Change its password with ALTER USER or SET PASSWORD."
+	case mysql.ErrNoTLS:
+		return "The server does not have TLS configured. Connect without --tls or enable TLS on the server."
+	}
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		switch mysqlErr.Number {
+		case 1045: // ER_ACCESS_DENIED_ERROR
+			return "Check the user and password, and the host the account is allowed to connect from. " +
+				"--debug logs where the settings were read from."
+		case 1251: // ER_NOT_SUPPORTED_AUTH_MODE
+			return "The server requires a newer authentication method for this account. " +
+				"Connect with --mysqlx or use an account IDENTIFIED WITH mysql_native_password."
+		case 3159: // ER_SECURE_TRANSPORT_REQUIRED
+			return "The server only accepts secure connections. Connect with --tls or through --socket."
+		}
+	}
+
+	return ""
+}
+
+// explainError adds how to fix an error seen when connecting, if known
+func explainError(err error, tls bool) error {
+	if h := hint(err, tls); h != "" {
+		return fmt.Errorf("%s\n%s", err.Error(), h)
+	}
+
+	return err
+}
@@ -0,0 +1,36 @@
+package connector
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestHint(t *testing.T) {
+	tests := []struct {
+		err      error
+		tls      bool
+		expected string // part of the hint, empty if there should be none
+	}{
+		{mysql.ErrUnknownPlugin, false, "--mysqlx"},
+		{mysql.ErrCleartextPassword, false, "--tls and --allow-cleartext-passwords"},
+		{mysql.ErrCleartextPassword, true, "Add --allow-cleartext-passwords"},
+		{mysql.ErrNoTLS, true, "without --tls"},
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, false, "Check the user and password"},
+		{&mysql.MySQLError{Number: 3159, Message: "secure transport required"}, false, "--tls"},
+		{&mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}, false, ""},
+		{errors.New("dial tcp: connection refused"), false, ""},
+	}
+
+	for _, test := range tests {
+		got := hint(test.err, test.tls)
+		if test.expected == "" && got != "" {
+			t.Errorf("hint(%v, %v) = %q, expected no hint", test.err, test.tls, got)
+		}
+		if !strings.Contains(got, test.expected) {
+			t.Errorf("hint(%v, %v) = %q, expected it to contain %q", test.err, test.tls, got, test.expected)
+		}
+	}
+}
@@ -4,18 +4,41 @@ import (
 	"fmt"
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/mysqlx"
 	"os"
+	"time"
 )
 
 // Flags holds various flags related to connecting to the database
 type Flags struct {
-	Host           *string
-	Socket         *string
-	Port           *int
-	User           *string
-	Password       *string
-	DefaultsFile   *string
-	UseEnvironment *bool
+	Host                *string
+	Socket              *string
+	Port                *int
+	User                *string
+	Password            *string
+	DefaultsFile        *string
+	DefaultsGroupSuffix *string
+	Profile             *string
+	PasswordCommand     *string
+	TLS                 *string
+	AllowCleartext      *bool
+	UseEnvironment      *bool
+	Demo                *bool
+	IPv4                *bool
+	IPv6                *bool
+	Mysqlx              *bool
+	MaxOpenConns        *int
+	MaxIdleConns        *int
+	ConnMaxLifetime     *time.Duration
+	ConnectRetries      *int
+}
+
+// return the value of an optional string flag
+func stringFlag(flag *string) string {
+	if flag == nil {
+		return ""
+	}
+	return *flag
 }
 
 // new connector returns a connected Connector given the different parameters
@@ -23,7 +46,89 @@ func NewConnector(flags Flags) *Connector {
 	var defaultsFile string
 	connector := new(Connector)
 
-	if *flags.UseEnvironment {
+	pool := DefaultPoolLimits()
+	if flags.MaxOpenConns != nil {
+		pool.MaxOpenConns = *flags.MaxOpenConns
+	}
+	if flags.MaxIdleConns != nil {
+		pool.MaxIdleConns = *flags.MaxIdleConns
+	}
+	if flags.ConnMaxLifetime != nil {
+		pool.ConnMaxLifetime = *flags.ConnMaxLifetime
+	}
+	if pool.MaxOpenConns < 1 || pool.MaxIdleConns < 0 || pool.ConnMaxLifetime < 0 {
+		fmt.Println(lib.MyName() + ": --max-open-conns must be at least 1 and --max-idle-conns and --conn-max-lifetime may not be negative")
+		os.Exit(1)
+	}
+	connector.SetPoolLimits(pool)
+
+	retries := ConnectRetries
+	if flags.ConnectRetries != nil {
+		retries = *flags.ConnectRetries
+	}
+	if retries < 0 {
+		fmt.Println(lib.MyName() + ": --connect-retries may not be negative")
+		os.Exit(1)
+	}
+	connector.SetRetries(retries)
+
+	ipv4 := flags.IPv4 != nil && *flags.IPv4
+	ipv6 := flags.IPv6 != nil && *flags.IPv6
+	switch {
+	case ipv4 && ipv6:
+		fmt.Println(lib.MyName() + ": Do not specify --ipv4 and --ipv6 together")
+		os.Exit(1)
+	case ipv4:
+		connector.SetNetwork("tcp4")
+	case ipv6:
+		connector.SetNetwork("tcp6")
+	}
+
+	passwordCommand := stringFlag(flags.PasswordCommand)
+	tls := stringFlag(flags.TLS)
+	xProtocol := flags.Mysqlx != nil && *flags.Mysqlx
+	if xProtocol {
+		if passwordCommand != "" || *flags.UseEnvironment {
+			fmt.Println(lib.MyName() + ": Do not specify --mysqlx with --password-command or --use-environment")
+			os.Exit(1)
+		}
+		connector.SetXProtocol(true)
+	}
+	if passwordCommand != "" {
+		if *flags.Password != "" {
+			fmt.Println(lib.MyName() + ": Do not specify --password and --password-command together")
+			os.Exit(1)
+		}
+		if tls == "" {
+			fmt.Println(lib.MyName() + ": --password-command needs --tls as IAM tokens are sent with the cleartext authentication plugin")
+			os.Exit(1)
+		}
+		connector.SetCredentialProvider(CommandProvider{Command: passwordCommand})
+	}
+	allowCleartext := flags.AllowCleartext != nil && *flags.AllowCleartext
+	if allowCleartext && tls == "" && stringFlag(flags.Socket) == "" {
+		fmt.Println(lib.MyName() + ": --allow-cleartext-passwords sends the password in cleartext so also specify --tls or --socket")
+		os.Exit(1)
+	}
+	if tls != "" {
+		params := "tls=" + tls
+		if passwordCommand != "" || allowCleartext {
+			// IAM tokens are sent with the cleartext plugin so only do this over TLS
+			params += "&allowCleartextPasswords=true"
+		}
+		connector.SetParams(params)
+	} else if allowCleartext {
+		connector.SetParams("allowCleartextPasswords=true")
+	}
+
+	if flags.Demo != nil && *flags.Demo {
+		logger.Println("--demo defined")
+		connector.ConnectByDemo()
+	} else if *flags.UseEnvironment {
+		if passwordCommand != "" || tls != "" {
+			fmt.Println(lib.MyName() + ": Do not specify --use-environment with --password-command or --tls")
+			os.Exit(1)
+		}
 		connector.ConnectByEnvironment()
 	} else {
 		if *flags.Host != "" || *flags.Socket != "" {
@@ -43,6 +148,8 @@ func NewConnector(flags Flags) *Connector {
 					fmt.Println(lib.MyName() + ": Do not specify --socket and --port together")
 					os.Exit(1)
 				}
+			} else if xProtocol && *flags.Host != "" {
+				components["port"] = fmt.Sprintf("%d", mysqlx.DefaultPort)
 			}
 			if *flags.Socket != "" {
 				components["socket"] = *flags.Socket
@@ -61,9 +168,35 @@ func NewConnector(flags Flags) *Connector {
 			} else {
 				logger.Println("connecting by implicit defaults file")
 			}
-			connector.ConnectByDefaultsFile(defaultsFile)
+			groupSuffix := stringFlag(flags.DefaultsGroupSuffix)
+			profile := stringFlag(flags.Profile)
+			if groupSuffix != "" || profile != "" || passwordCommand != "" || tls != "" || xProtocol {
+				logger.Println("--defaults-group-suffix, --profile, --password-command, --tls or --mysqlx defined")
+				groups := defaultsFileGroups(groupSuffix, profile)
+				components := defaultsFileComponents(defaultsFile, groups)
+				if xProtocol {
+					xProtocolComponents(components, *flags.Port)
+				}
+				connector.ConnectByComponents(components)
+			} else {
+				connector.ConnectByDefaultsFile(defaultsFile)
+			}
 		}
 	}
 
 	return connector
 }
+
+// xProtocolComponents adjusts the components read from a defaults file
+// to use the X Protocol. The socket and port given there are those of
+// the classic protocol so we connect over TCP to the X Protocol port.
+func xProtocolComponents(components map[string]string, port int) {
+	if port == 0 {
+		port = mysqlx.DefaultPort
+	}
+	delete(components, "socket")
+	if components["host"] == "" {
+		components["host"] = "localhost"
+	}
+	components["port"] = fmt.Sprintf("%d", port)
+}
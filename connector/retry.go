@@ -0,0 +1,166 @@
+package connector
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+const (
+	// ConnectRetries is the default number of times to try connecting again
+	ConnectRetries = 5
+	// maxRetryWait limits the wait between attempts to connect
+	maxRetryWait = 30 * time.Second
+	// showAfter is how long connecting takes before the progress is shown
+	showAfter = time.Second
+)
+
+// retryable returns false if connecting again won't help because the
+// server refused the account or the settings are wrong, rather than it
+// being slow, unreachable or the name not resolving
+func retryable(err error) bool {
+	switch err {
+	case mysql.ErrUnknownPlugin, mysql.ErrCleartextPassword, mysql.ErrOldPassword, mysql.ErrNoTLS:
+		return false
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		switch mysqlErr.Number {
+		case 1040, 1053, 1203: // too many connections, shutting down, too many user connections
+			return true
+		}
+		return false
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) {
+		return false
+	}
+
+	return !strings.HasPrefix(err.Error(), "invalid DSN")
+}
+
+// retryWait returns how long to wait after the given attempt failed,
+// doubling from 1s up to maxRetryWait
+func retryWait(attempt int) time.Duration {
+	wait := time.Second
+	for i := 1; i < attempt && wait < maxRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxRetryWait {
+		wait = maxRetryWait
+	}
+
+	return wait
+}
+
+// progress shows how connecting is going on stderr, so a server which
+// is slow to answer doesn't look like a hang. On a terminal the line is
+// rewritten as the time passes.
+type progress struct {
+	out      io.Writer
+	terminal bool
+	target   string // where we connect to, with the password masked
+	shown    bool   // something has been written
+	pending  bool   // a line has been written without its newline
+}
+
+// newProgress returns the progress of connecting to the target
+func newProgress(target string) *progress {
+	p := &progress{out: os.Stderr, target: target}
+	if info, err := os.Stderr.Stat(); err == nil {
+		p.terminal = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	return p
+}
+
+// status shows a line of progress. On a terminal a line being updated
+// is rewritten by the next one, otherwise it is only shown once.
+func (p *progress) status(update bool, line string) {
+	defer func() { p.pending, p.shown = update, true }()
+
+	if !p.terminal {
+		if !update || !p.pending {
+			fmt.Fprintln(p.out, line)
+		}
+		return
+	}
+	if p.pending {
+		fmt.Fprint(p.out, "\r\x1b[K")
+	}
+	if update {
+		fmt.Fprint(p.out, line)
+	} else {
+		fmt.Fprintln(p.out, line)
+	}
+}
+
+// end finishes a line being rewritten
+func (p *progress) end() {
+	if p.pending && p.terminal {
+		fmt.Fprintln(p.out)
+	}
+	p.pending = false
+}
+
+// attempt runs connect, showing how long it has been waiting once it
+// takes longer than showAfter
+func (p *progress) attempt(connect func() error, attempt, attempts int) error {
+	result := make(chan error, 1)
+	go func() { result <- connect() }()
+
+	start := time.Now()
+	ticker := time.NewTicker(showAfter)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-result:
+			p.end()
+			return err
+		case <-ticker.C:
+			p.status(true, fmt.Sprintf("%s: connecting to %s (attempt %d of %d) waiting %.0fs",
+				lib.MyName(), p.target, attempt, attempts, time.Since(start).Seconds()))
+		}
+	}
+}
+
+// countdown shows why the attempt failed and counts down to the next
+func (p *progress) countdown(err error, wait time.Duration, next, attempts int) {
+	p.status(false, fmt.Sprintf("%s: unable to connect to %s: %v", lib.MyName(), p.target, err))
+	for left := wait; left > 0; left -= time.Second {
+		p.status(true, fmt.Sprintf("%s: retrying in %.0fs (attempt %d of %d), Ctrl-C to give up",
+			lib.MyName(), left.Seconds(), next, attempts))
+		time.Sleep(min(left, time.Second))
+	}
+	p.end()
+}
+
+// connect calls connect until it succeeds, it fails in a way which
+// won't be fixed by trying again or retries more attempts have failed
+func (p *progress) connect(connect func() error, retries int) error {
+	for attempt := 1; ; attempt++ {
+		err := p.attempt(connect, attempt, retries+1)
+		if err == nil {
+			if p.shown {
+				fmt.Fprintf(p.out, "%s: connected to %s\n", lib.MyName(), p.target)
+			}
+			return nil
+		}
+		logger.Println("connector: attempt", attempt, "to connect to", p.target, "failed:", err)
+		if attempt > retries || !retryable(err) {
+			return err
+		}
+		p.countdown(err, retryWait(attempt), attempt+1, retries+1)
+	}
+}
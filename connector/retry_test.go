@@ -0,0 +1,66 @@
+package connector
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{&net.DNSError{Err: "server misbehaving", Name: "db", IsTemporary: true}, true},
+		{mysql.ErrInvalidConn, true},
+		{&mysql.MySQLError{Number: 1040, Message: "Too many connections"}, true},
+		{&mysql.MySQLError{Number: 1045, Message: "Access denied"}, false},
+		{mysql.ErrUnknownPlugin, false},
+		{errors.New("invalid DSN: missing the slash separating the database name"), false},
+	}
+
+	for _, test := range tests {
+		if got := retryable(test.err); got != test.expected {
+			t.Errorf("retryable(%v) = %v, expected %v", test.err, got, test.expected)
+		}
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{20, maxRetryWait},
+	}
+
+	for _, test := range tests {
+		if got := retryWait(test.attempt); got != test.expected {
+			t.Errorf("retryWait(%d) = %v, expected %v", test.attempt, got, test.expected)
+		}
+	}
+}
+
+func TestConnectGivesUp(t *testing.T) {
+	var out bytes.Buffer
+	p := &progress{out: &out, target: "user:****@tcp(db:3306)/"}
+	attempts := 0
+	denied := &mysql.MySQLError{Number: 1045, Message: "Access denied"}
+
+	err := p.connect(func() error { attempts++; return denied }, 5)
+	if err != denied || attempts != 1 {
+		t.Errorf("connect() = %v after %d attempts, expected %v after 1", err, attempts, denied)
+	}
+	if strings.Contains(out.String(), "retrying") {
+		t.Errorf("connect() showed %q, expected no retry", out.String())
+	}
+}
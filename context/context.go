@@ -7,17 +7,55 @@ import (
 
 	"github.com/sjmudd/ps-top/global"
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/local_process"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/self_stats"
 	"github.com/sjmudd/ps-top/version"
 )
 
+// The object types the table I/O views may be limited to, as given by
+// OBJECT_TYPE in table_io_waits_summary_by_table
+const (
+	AllTables       = ""                // persistent and temporary tables
+	Tables          = "TABLE"           // persistent tables only
+	TemporaryTables = "TEMPORARY TABLE" // temporary tables only
+)
+
 // Context holds the common information
 type Context struct {
+	alert             bool
+	allRows           bool
+	amplification     bool
+	anomalies         map[string]float64
+	byAccount         bool
+	byTable           bool
+	collectors        []self_stats.Collector
+	connections       bool
+	connectionsPage   int
+	fullStatements    bool
 	last              time.Time
+	lockWaiters       bool
+	objectType        string
+	opLatency         bool
+	partitions        bool
+	percentOfTotal    bool
+	priority          bool
+	process           *local_process.Process
+	proxy             string
+	schemas           *schema_filter.Filter
+	selectedRow       int
+	sinceMark         bool
 	status            *global.Status
+	tabs              []string
+	title             string
 	uptime            int
 	variables         *global.Variables
 	version           string
+	viewName          string
+	viewNumber        int
 	wantRelativeStats bool
+	watchdog          string
+	statusWatch       []string
 }
 
 // NewContext returns the pointer to a new (empty) context
@@ -29,6 +67,57 @@ func NewContext(status *global.Status, variables *global.Variables) *Context {
 	return c
 }
 
+// SetAlert records whether a threshold is currently breached
+func (c *Context) SetAlert(alert bool) {
+	c.alert = alert
+}
+
+// Alert returns true if a threshold is currently breached
+func (c Context) Alert() bool {
+	return c.alert
+}
+
+// SetAnomalies records the rows of the view whose change is unusually
+// large, with the number of standard deviations above their mean
+func (c *Context) SetAnomalies(anomalies map[string]float64) {
+	c.anomalies = anomalies
+}
+
+// Anomalies returns the rows of the view whose change is unusually large
+func (c Context) Anomalies() map[string]float64 {
+	return c.anomalies
+}
+
+// SetCollectors records the statistics of each collector after a collection
+func (c *Context) SetCollectors(collectors []self_stats.Collector) {
+	c.collectors = collectors
+}
+
+// Collectors returns the statistics of each collector
+func (c Context) Collectors() []self_stats.Collector {
+	return c.collectors
+}
+
+// SetStatusWatch records the status variables watched with their change
+func (c *Context) SetStatusWatch(items []string) {
+	c.statusWatch = items
+}
+
+// StatusWatch returns the status variables watched with their change
+func (c Context) StatusWatch() []string {
+	return c.statusWatch
+}
+
+// SetWatchdog records the statements matching the watchdog rules, empty if none
+func (c *Context) SetWatchdog(summary string) {
+	c.watchdog = summary
+}
+
+// Watchdog returns the statements matching the watchdog rules, empty if none
+func (c Context) Watchdog() string {
+	return c.watchdog
+}
+
 // Hostname returns the current short hostname
 func (c Context) Hostname() string {
 	hostname := c.variables.Get("hostname")
@@ -38,6 +127,17 @@ func (c Context) Hostname() string {
 	return hostname
 }
 
+// SetProxy records the name of the proxy the connection goes through
+func (c *Context) SetProxy(proxy string) {
+	c.proxy = proxy
+}
+
+// Proxy returns the name of the proxy the connection goes through, or
+// "" if it is made directly to the server
+func (c Context) Proxy() string {
+	return c.proxy
+}
+
 // MySQLVersion returns the current MySQL version
 func (c Context) MySQLVersion() string {
 	return c.variables.Get("version")
@@ -53,9 +153,34 @@ func (c Context) MyName() string {
 	return lib.MyName()
 }
 
-// Uptime returns the time that MySQL has been up
+// SetCollected records when the data shown was collected, also
+// recording the server's uptime at that time
+func (c *Context) SetCollected(collected time.Time) {
+	c.last = collected
+	c.uptime = c.status.Get("Uptime")
+}
+
+// DataAge returns how long ago the data shown was collected, 0 if unknown
+func (c Context) DataAge() time.Duration {
+	if c.last.IsZero() {
+		return 0
+	}
+	return time.Since(c.last)
+}
+
+// Uptime returns the time that MySQL has been up. Once data has been
+// collected this counts on from the uptime seen then, so the screen can
+// be refreshed between collections without asking the server.
 func (c Context) Uptime() int {
-	return c.status.Get("Uptime")
+	if c.last.IsZero() {
+		return c.status.Get("Uptime")
+	}
+	return c.uptime + int(time.Since(c.last).Seconds())
+}
+
+// Status returns a pointer to global.Status
+func (c Context) Status() *global.Status {
+	return c.status
 }
 
 // Variables returns a pointer to global.Variables
@@ -63,6 +188,36 @@ func (c Context) Variables() *global.Variables {
 	return c.variables
 }
 
+// SetProcess sets the local server process whose statistics are shown
+func (c *Context) SetProcess(p *local_process.Process) {
+	c.process = p
+}
+
+// Process returns the local server process or nil if it is not monitored
+func (c Context) Process() *local_process.Process {
+	return c.process
+}
+
+// SetSchemaFilter sets the schemas the table based views are restricted to
+func (c *Context) SetSchemaFilter(f *schema_filter.Filter) {
+	c.schemas = f
+}
+
+// SchemaFilter returns the schema filter or nil if there is none
+func (c Context) SchemaFilter() *schema_filter.Filter {
+	return c.schemas
+}
+
+// SetTitle sets the title shown in the header and written with the data
+func (c *Context) SetTitle(title string) {
+	c.title = title
+}
+
+// Title returns the title given on the command line, if any
+func (c Context) Title() string {
+	return c.title
+}
+
 // SetWantRelativeStats tells what we want to see
 func (c *Context) SetWantRelativeStats(w bool) {
 	c.wantRelativeStats = w
@@ -72,3 +227,189 @@ func (c *Context) SetWantRelativeStats(w bool) {
 func (c Context) WantRelativeStats() bool {
 	return c.wantRelativeStats
 }
+
+// SetWantFullStatements tells whether statements should be shown in full
+func (c *Context) SetWantFullStatements(w bool) {
+	c.fullStatements = w
+}
+
+// WantFullStatements tells us whether statements should be shown in full rather than truncated
+func (c Context) WantFullStatements() bool {
+	return c.fullStatements
+}
+
+// SetWantPartitions tells whether partitioned tables should be shown by partition
+func (c *Context) SetWantPartitions(w bool) {
+	c.partitions = w
+}
+
+// WantPartitions tells us whether partitioned tables should be shown by partition rather than by table
+func (c Context) WantPartitions() bool {
+	return c.partitions
+}
+
+// SetObjectType sets the type of tables the table I/O views are limited to
+func (c *Context) SetObjectType(objectType string) {
+	c.objectType = objectType
+}
+
+// ObjectType returns the type of tables the table I/O views are limited to, AllTables if none
+func (c Context) ObjectType() string {
+	return c.objectType
+}
+
+// SetWantByTable tells whether statement latency should be attributed to the tables used
+func (c *Context) SetWantByTable(w bool) {
+	c.byTable = w
+}
+
+// WantByTable tells us whether statement latency should be shown by table rather than by statement
+func (c Context) WantByTable() bool {
+	return c.byTable
+}
+
+// SetWantByAccount tells whether mutex latency should be shown by account
+func (c *Context) SetWantByAccount(w bool) {
+	c.byAccount = w
+}
+
+// WantByAccount tells us whether mutex latency should be shown by account rather than globally
+func (c Context) WantByAccount() bool {
+	return c.byAccount
+}
+
+// SetWantConnections tells whether the connections should be listed rather than added up by user
+func (c *Context) SetWantConnections(w bool) {
+	c.connections = w
+}
+
+// WantConnections tells us whether the connections should be listed rather than added up by user
+func (c Context) WantConnections() bool {
+	return c.connections
+}
+
+// SetWantLockWaiters tells whether the locks view should show the connections waiting for a lock now rather than the lock latency by table
+func (c *Context) SetWantLockWaiters(w bool) {
+	c.lockWaiters = w
+}
+
+// WantLockWaiters tells us whether the locks view should show the connections waiting for a lock now rather than the lock latency by table
+func (c Context) WantLockWaiters() bool {
+	return c.lockWaiters
+}
+
+// SetWantOpLatency tells whether the table I/O views should show the latency of each operation rather than percentages
+func (c *Context) SetWantOpLatency(w bool) {
+	c.opLatency = w
+}
+
+// WantOpLatency tells us whether the table I/O views should show the latency of each operation rather than percentages
+func (c Context) WantOpLatency() bool {
+	return c.opLatency
+}
+
+// SetWantPercentOfTotal tells whether the values should be shown as percentages of their column totals
+func (c *Context) SetWantPercentOfTotal(w bool) {
+	c.percentOfTotal = w
+}
+
+// WantPercentOfTotal tells us whether the values should be shown as percentages of their column totals
+func (c Context) WantPercentOfTotal() bool {
+	return c.percentOfTotal
+}
+
+// SetWantAmplification tells whether the table I/O views should show the file I/O per row
+func (c *Context) SetWantAmplification(w bool) {
+	c.amplification = w
+}
+
+// WantAmplification tells us whether the table I/O views should show the file I/O per row
+func (c Context) WantAmplification() bool {
+	return c.amplification
+}
+
+// SetWantAllRows tells whether the rows without activity should be shown with their names
+func (c *Context) SetWantAllRows(w bool) {
+	c.allRows = w
+}
+
+// WantAllRows tells us whether the rows without activity should be shown with their names rather than left blank
+func (c Context) WantAllRows() bool {
+	return c.allRows
+}
+
+// SetWantPriority tells whether the rows should be shown in the order of their priority rather than by the view's sort
+func (c *Context) SetWantPriority(w bool) {
+	c.priority = w
+}
+
+// WantPriority tells us whether the rows should be shown in the order of their priority rather than by the view's sort
+func (c Context) WantPriority() bool {
+	return c.priority
+}
+
+// SetWantSinceMark tells whether relative values should be shown since the mark rather than since the initial values
+func (c *Context) SetWantSinceMark(w bool) {
+	c.sinceMark = w
+}
+
+// WantSinceMark tells us whether relative values should be shown since the mark rather than since the initial values
+func (c Context) WantSinceMark() bool {
+	return c.sinceMark
+}
+
+// SetConnectionsPage sets the page of connections listed, starting at 0
+func (c *Context) SetConnectionsPage(page int) {
+	if page < 0 {
+		page = 0
+	}
+	c.connectionsPage = page
+}
+
+// ConnectionsPage returns the page of connections listed
+func (c Context) ConnectionsPage() int {
+	return c.connectionsPage
+}
+
+// SetSelectedRow sets the row selected on the screen, starting at 1, or 0 for none
+func (c *Context) SetSelectedRow(row int) {
+	if row < 0 {
+		row = 0
+	}
+	c.selectedRow = row
+}
+
+// SelectedRow returns the row selected on the screen, 0 if none
+func (c Context) SelectedRow() int {
+	return c.selectedRow
+}
+
+// SetViewNumber records the number (1-9) assigned to the current view
+func (c *Context) SetViewNumber(number int) {
+	c.viewNumber = number
+}
+
+// SetTabs records the names of the views open as tabs
+func (c *Context) SetTabs(tabs []string) {
+	c.tabs = tabs
+}
+
+// Tabs returns the names of the views open as tabs
+func (c Context) Tabs() []string {
+	return c.tabs
+}
+
+// SetViewName records the name of the current view
+func (c *Context) SetViewName(name string) {
+	c.viewName = name
+}
+
+// ViewName returns the name of the current view
+func (c Context) ViewName() string {
+	return c.viewName
+}
+
+// ViewNumber returns the number assigned to the current view, 0 if none
+func (c Context) ViewNumber() int {
+	return c.viewNumber
+}
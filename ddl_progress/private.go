@@ -0,0 +1,163 @@
+// Package ddl_progress contains the library routines for collecting the
+// DDL statements in progress from performance_schema.events_statements_current
+// and the stage each of them is in from events_stages_current.
+package ddl_progress
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// Row contains a DDL statement in progress
+type Row struct {
+	id             uint64 // processlist id of the connection
+	user           string
+	age            uint64 // seconds the statement has been running
+	eventID        uint64 // EVENT_ID of the statement
+	statement      string
+	stage          string // current stage, e.g. alter table (read PK and internal sort)
+	completed      uint64 // WORK_COMPLETED of the stage
+	estimated      uint64 // WORK_ESTIMATED of the stage
+	seen           time.Time
+	firstSeen      time.Time // when the statement was first collected
+	firstCompleted uint64    // WORK_COMPLETED when first collected
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"PROCESSLIST_ID":   func(r *Row) interface{} { return &r.id },
+	"PROCESSLIST_USER": func(r *Row) interface{} { return &r.user },
+	"PROCESSLIST_TIME": func(r *Row) interface{} { return &r.age },
+	"EVENT_ID":         func(r *Row) interface{} { return &r.eventID },
+	"SQL_TEXT":         func(r *Row) interface{} { return &r.statement },
+	"EVENT_NAME":       func(r *Row) interface{} { return &r.stage },
+	"WORK_COMPLETED":   func(r *Row) interface{} { return &r.completed },
+	"WORK_ESTIMATED":   func(r *Row) interface{} { return &r.estimated },
+}
+
+// the statements which report their progress in the stage events
+const ddlQuery = `
+SELECT	t.PROCESSLIST_ID,
+	COALESCE(t.PROCESSLIST_USER, '') AS PROCESSLIST_USER,
+	COALESCE(t.PROCESSLIST_TIME, 0) AS PROCESSLIST_TIME,
+	s.EVENT_ID,
+	COALESCE(s.SQL_TEXT, '') AS SQL_TEXT,
+	COALESCE(g.EVENT_NAME, '') AS EVENT_NAME,
+	COALESCE(g.WORK_COMPLETED, 0) AS WORK_COMPLETED,
+	COALESCE(g.WORK_ESTIMATED, 0) AS WORK_ESTIMATED
+FROM	performance_schema.events_statements_current s
+JOIN	performance_schema.threads t ON t.THREAD_ID = s.THREAD_ID
+LEFT JOIN performance_schema.events_stages_current g ON g.THREAD_ID = s.THREAD_ID AND g.NESTING_EVENT_ID = s.EVENT_ID
+WHERE	s.END_EVENT_ID IS NULL
+AND	s.EVENT_NAME IN ('statement/sql/alter_table', 'statement/sql/create_index', 'statement/sql/optimize')`
+
+// select the DDL statements currently running
+func selectRows(dbh *sql.DB, seen time.Time) (Rows, error) {
+	t, err := lib.ReadRowsFromSQL(dbh, columns, ddlQuery)
+	if err != nil {
+		return nil, err
+	}
+	for i := range t {
+		t[i].seen = seen
+		t[i].user = anonymiser.Anonymise("user", t[i].user)
+		t[i].statement = strings.Join(strings.Fields(t[i].statement), " ")
+		t[i].stage = strings.TrimPrefix(t[i].stage, "stage/")
+	}
+	logger.Println("ddl_progress.selectRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// track carries over when each statement still running was first seen
+// and how much work it had completed then, so its progress can be
+// measured over the intervals it has been collected.
+func (rows Rows) track(previous Rows) {
+	for i := range rows {
+		rows[i].firstSeen, rows[i].firstCompleted = rows[i].seen, rows[i].completed
+		for j := range previous {
+			if previous[j].id == rows[i].id && previous[j].eventID == rows[i].eventID &&
+				previous[j].firstCompleted <= rows[i].completed {
+				rows[i].firstSeen, rows[i].firstCompleted = previous[j].firstSeen, previous[j].firstCompleted
+			}
+		}
+	}
+}
+
+// progress returns the fraction of the estimated work completed
+func (row Row) progress() float64 {
+	return lib.MyDivide(row.completed, row.estimated)
+}
+
+// remaining returns the seconds until the estimated work is completed at
+// the rate seen since the statement was first collected, or if there's
+// no progress seen yet the rate since it started. 0 means unknown.
+func (row Row) remaining() uint64 {
+	if row.estimated <= row.completed {
+		return 0
+	}
+
+	var rate float64
+	if elapsed := row.seen.Sub(row.firstSeen).Seconds(); elapsed > 0 && row.completed > row.firstCompleted {
+		rate = float64(row.completed-row.firstCompleted) / elapsed
+	} else if row.age > 0 {
+		rate = float64(row.completed) / float64(row.age)
+	}
+	if rate <= 0 {
+		return 0
+	}
+
+	return uint64(float64(row.estimated-row.completed)/rate + 0.5)
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"age":       func(i, j int) int { return sort_keys.Descending(rows[i].age, rows[j].age) },
+		"remaining": func(i, j int) int { return sort_keys.Descending(rows[i].remaining(), rows[j].remaining()) },
+		"id":        func(i, j int) int { return -sort_keys.Descending(rows[i].id, rows[j].id) },
+	}
+}
+
+// sort by age (descending) but also by "id" (ascending) if the values
+// are the same after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("ddl_progress", "age", "id"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("ddl_progress", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func headings() string {
+	return fmt.Sprintf("%8s %6s %8s|%8s|%-12s|%-46s|%s", "Age", "Done", "Remains", "Id", "User", "Stage", "Statement")
+}
+
+// generate a printable result
+func (row Row) rowContent() string {
+	var done string
+	if row.estimated > 0 {
+		done = lib.FormatPct(row.progress())
+	}
+
+	return fmt.Sprintf("%8s %6s %8s|%8d|%-12s|%-46s|%s",
+		lib.FormatSeconds(row.age),
+		done,
+		lib.FormatSeconds(row.remaining()),
+		row.id,
+		row.user,
+		row.stage,
+		row.statement)
+}
@@ -0,0 +1,95 @@
+// Package ddl_progress shows the ALTER TABLE, CREATE INDEX and OPTIMIZE
+// TABLE statements which are running, how far through their work they
+// are and when they are expected to finish.
+package ddl_progress
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the DDL statements in progress
+type Object struct {
+	baseobject.BaseObject      // embedded
+	current               Rows // statements running at the last collection
+}
+
+// NewDDLProgress returns a pointer to an object of this type
+func NewDDLProgress(ctx *context.Context) *Object {
+	logger.Println("NewDDLProgress()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the DDL statements running and keeps track of those
+// seen before, so the time remaining is based on the progress made
+// while they have been watched. There are no relative values as this is
+// the current state.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh, start)
+	if err != nil {
+		return err
+	}
+	rows.track(t.current)
+	rows.sort()
+	t.current = rows
+	t.SetLastCollectTimeNow()
+
+	logger.Println("ddl_progress.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+
+	for i := range t.current {
+		rows = append(rows, t.current[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the number of statements running
+func (t Object) TotalRowContent() string {
+	return fmt.Sprintf("%sTotals: %d statement(s)", t.EmptyRowContent(), len(t.current))
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	return fmt.Sprintf("%8s %6s %8s|%8s|%-12s|%-46s|", "", "", "", "", "", "")
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	return fmt.Sprintf("DDL in progress (events_stages_current) %d statement(s)", len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as we show the current state
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("ddl_progress.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
@@ -0,0 +1,80 @@
+package demo
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"strconv"
+)
+
+// the binary log of the synthetic server
+const (
+	binlogFile      = "binlog.000042"
+	binlogStart     = 4  // position of the first event of a file
+	transactionRate = 60 // cycles of binlogCycle written per second
+)
+
+// a binary log event of the synthetic server
+type binlogEvent struct {
+	eventType string
+	size      uint64
+}
+
+// binlogCycle is the events of an insert, update and delete transaction
+var binlogCycle = []binlogEvent{
+	{"Gtid", 79}, {"Query", 80}, {"Table_map", 60}, {"Write_rows", 300}, {"Xid", 31},
+	{"Gtid", 79}, {"Query", 80}, {"Table_map", 60}, {"Update_rows", 520}, {"Xid", 31},
+	{"Gtid", 79}, {"Query", 80}, {"Table_map", 60}, {"Delete_rows", 180}, {"Xid", 31},
+}
+
+// matches SHOW BINLOG EVENTS IN '<file>' FROM <pos> LIMIT <count>
+var binlogEventsQuery = regexp.MustCompile(`(?i)SHOW BINLOG EVENTS IN '([^']*)' FROM (\d+) LIMIT (\d+)`)
+
+// cycleSize returns the number of bytes written by binlogCycle
+func cycleSize() uint64 {
+	var size uint64
+	for _, e := range binlogCycle {
+		size += e.size
+	}
+
+	return size
+}
+
+// binlogPosition returns the current position of the binary log
+func binlogPosition(seconds float64) uint64 {
+	return binlogStart + cycleSize()*uint64(transactionRate*seconds)
+}
+
+// binlogStatus returns the result of SHOW MASTER STATUS
+func binlogStatus(seconds float64) ([]string, [][]driver.Value) {
+	return []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+		[][]driver.Value{{binlogFile, int64(binlogPosition(seconds)), "", "", ""}}
+}
+
+// binlogEvents returns the result of SHOW BINLOG EVENTS for the query
+func binlogEvents(q string, seconds float64) ([]string, [][]driver.Value) {
+	columns := []string{"Log_name", "Pos", "Event_type", "Server_id", "End_log_pos", "Info"}
+	var values [][]driver.Value
+
+	m := binlogEventsQuery.FindStringSubmatch(q)
+	if m == nil || m[1] != binlogFile {
+		return columns, nil
+	}
+	from, _ := strconv.ParseUint(m[2], 10, 64)
+	limit, _ := strconv.Atoi(m[3])
+	end := binlogPosition(seconds)
+	if from < binlogStart {
+		from = binlogStart
+	}
+
+	// start at the beginning of the cycle containing from
+	pos := binlogStart + (from-binlogStart)/cycleSize()*cycleSize()
+	for i := 0; pos < end && len(values) < limit; i++ {
+		e := binlogCycle[i%len(binlogCycle)]
+		if pos >= from {
+			values = append(values, []driver.Value{binlogFile, int64(pos), e.eventType, int64(1), int64(pos + e.size), ""})
+		}
+		pos += e.size
+	}
+
+	return columns, values
+}
@@ -0,0 +1,95 @@
+package demo_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/sjmudd/ps-top/binlog_events"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/ddl_progress"
+	"github.com/sjmudd/ps-top/demo"
+	"github.com/sjmudd/ps-top/file_io_latency"
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/idle_time"
+	"github.com/sjmudd/ps-top/key_cache"
+	"github.com/sjmudd/ps-top/lock_users"
+	"github.com/sjmudd/ps-top/lock_waiters"
+	"github.com/sjmudd/ps-top/lock_waits"
+	"github.com/sjmudd/ps-top/memory_usage"
+	"github.com/sjmudd/ps-top/mutex_latency"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/prepared_statements"
+	"github.com/sjmudd/ps-top/program_latency"
+	"github.com/sjmudd/ps-top/proxy_backends"
+	"github.com/sjmudd/ps-top/proxy_digests"
+	"github.com/sjmudd/ps-top/ps_sizing"
+	"github.com/sjmudd/ps-top/query_cache"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/slo_budget"
+	"github.com/sjmudd/ps-top/stages_latency"
+	"github.com/sjmudd/ps-top/statement_stages"
+	"github.com/sjmudd/ps-top/statements_digest"
+	"github.com/sjmudd/ps-top/table_cache"
+	"github.com/sjmudd/ps-top/table_io_latency"
+	"github.com/sjmudd/ps-top/table_lock_latency"
+	"github.com/sjmudd/ps-top/unused_indexes"
+	"github.com/sjmudd/ps-top/user_latency"
+	"github.com/sjmudd/ps-top/wait_events"
+)
+
+// TestCollectors checks the query of each collector is answered by the
+// synthetic data, so the demo shows every view
+func TestCollectors(t *testing.T) {
+	dbh, err := sql.Open(demo.DriverName, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.Close()
+
+	ctx := context.NewContext(global.NewStatus(dbh), global.NewVariables(dbh))
+	ctx.SetSchemaFilter(schema_filter.NewFilter("", "", nil, nil))
+	tableIO := table_io_latency.NewTableIoLatency(ctx)
+
+	tests := []struct {
+		name  string
+		table ps_table.Tabler
+		empty bool // no rows are expected from the first collection
+	}{
+		{"table_io_latency", tableIO, false},
+		{"file_io_latency", file_io_latency.NewFileSummaryByInstance(ctx), false},
+		{"table_lock_latency", table_lock_latency.NewTableLockLatency(ctx), false},
+		{"user_latency", user_latency.NewUserLatency(ctx), false},
+		{"mutex_latency", mutex_latency.NewMutexLatency(ctx), false},
+		{"stages_latency", stages_latency.NewStagesLatency(ctx), false},
+		{"memory_usage", memory_usage.NewMemoryUsage(ctx), false},
+		{"lock_waits", lock_waits.NewLockWaits(ctx), false},
+		{"lock_waiters", lock_waiters.NewLockWaiters(ctx), false},
+		{"statement_efficiency", statements_digest.NewStatementsDigest(ctx), false},
+		{"table_cache", table_cache.NewTableCache(ctx), false},
+		{"key_cache", key_cache.NewKeyCache(ctx), false},
+		{"query_cache", query_cache.NewQueryCache(ctx), false},
+		{"statement_stages", statement_stages.NewStatementStages(ctx), false},
+		{"unused_indexes", unused_indexes.NewUnusedIndexes(ctx), false},
+		{"binlog_events", binlog_events.NewBinlogEvents(ctx), true}, // the events written since the last collection
+		{"ddl_progress", ddl_progress.NewDDLProgress(ctx), false},
+		{"lock_users", lock_users.NewLockUsers(ctx), false},
+		{"ps_sizing", ps_sizing.NewPSSizing(ctx), false},
+		{"program_latency", program_latency.NewProgramLatency(ctx), false},
+		{"slo_budget", slo_budget.NewSLOBudget(ctx, tableIO), true}, // none configured in ~/.pstoprc
+		{"prepared_statements", prepared_statements.NewPreparedStatements(ctx), false},
+		{"wait_events", wait_events.NewWaitEvents(ctx), false},
+		{"idle_time", idle_time.NewIdleTime(ctx), false},
+		{"proxy_digests", proxy_digests.NewProxyDigests(ctx, dbh), false},
+		{"proxy_backends", proxy_backends.NewProxyBackends(ctx, dbh), false},
+	}
+
+	for _, test := range tests {
+		if err := test.table.Collect(dbh); err != nil {
+			t.Errorf("%s: Collect() failed on the demo data: %v", test.name, err)
+			continue
+		}
+		if test.table.Len() == 0 && !test.empty {
+			t.Errorf("%s: Collect() found no rows in the demo data", test.name)
+		}
+	}
+}
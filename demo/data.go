@@ -0,0 +1,714 @@
+package demo
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// uptime of the synthetic server when the driver is loaded
+const baseUptime = 3 * 86400
+
+// variables of the synthetic server
+var variables = map[string]string{
+	"hostname":           "demo",
+	"version":            "8.0.36-demo",
+	"performance_schema": "ON",
+	"datadir":            "/var/lib/mysql/",
+	"relay_log":          "relay-bin",
+	"log_bin":            "ON",
+	"version_comment":    "MySQL Community Server - GPL",
+	"port":               "3306",
+	"binlog_format":      "ROW",
+	"gtid_mode":          "ON",
+	"read_only":          "OFF",
+	"sql_mode":           "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION",
+	"max_connections":    "500",
+	"key_buffer_size":    "8388608",
+	"sync_binlog":        "1",
+
+	"innodb_buffer_pool_size":        "8589934592",
+	"innodb_buffer_pool_instances":   "8",
+	"innodb_redo_log_capacity":       "2147483648",
+	"innodb_flush_log_at_trx_commit": "1",
+	"innodb_flush_method":            "O_DIRECT",
+
+	"lower_case_table_names": "0",
+	"lower_case_file_system": "OFF",
+
+	"performance_schema_digests_size":         "10000",
+	"performance_schema_max_table_instances":  "-1",
+	"performance_schema_max_table_handles":    "-1",
+	"performance_schema_max_mutex_instances":  "-1",
+	"performance_schema_max_thread_instances": "-1",
+	"performance_schema_max_file_instances":   "-1",
+	"performance_schema_accounts_size":        "-1",
+	"performance_schema_max_metadata_locks":   "-1",
+	"performance_schema_max_index_stat":       "-1",
+	"performance_schema_max_table_lock_stat":  "-1",
+	"performance_schema_max_memory_classes":   "450",
+	"performance_schema_max_statement_stack":  "10",
+}
+
+// a synthetic status variable growing at rate per second, or a gauge
+type statusVariable struct {
+	name  string
+	rate  float64
+	gauge bool
+}
+
+var status = []statusVariable{
+	{"Table_open_cache_hits", 2500, false},
+	{"Table_open_cache_misses", 12, false},
+	{"Table_open_cache_overflows", 3, false},
+	{"Opened_tables", 12, false},
+	{"Open_tables", 1800, true},
+	{"Handler_read_first", 40, false},
+	{"Handler_read_key", 9000, false},
+	{"Handler_read_next", 25000, false},
+	{"Handler_read_rnd_next", 4000, false},
+	{"Handler_write", 800, false},
+	{"Handler_update", 300, false},
+	{"Handler_delete", 50, false},
+	{"Key_read_requests", 600, false},
+	{"Key_reads", 20, false},
+	{"Key_write_requests", 80, false},
+	{"Key_writes", 30, false},
+	{"Key_blocks_used", 5000, true},
+	{"Key_blocks_unused", 1500, true},
+	{"Key_blocks_not_flushed", 40, true},
+	{"Com_select", 900, false},
+	{"Qcache_hits", 1500, false},
+	{"Qcache_inserts", 300, false},
+	{"Qcache_not_cached", 120, false},
+	{"Qcache_lowmem_prunes", 15, false},
+	{"Qcache_queries_in_cache", 4000, true},
+	{"Qcache_free_memory", 8000000, true},
+	{"Qcache_free_blocks", 300, true},
+	{"Qcache_total_blocks", 9000, true},
+	{"Threadpool_threads", 24, true},
+	{"Threadpool_idle_threads", 6, true},
+	{"Innodb_buffer_pool_pages_total", 524288, true},
+	{"Innodb_buffer_pool_pages_data", 480000, true},
+	{"Innodb_buffer_pool_pages_dirty", 12000, true},
+	{"Threads_running", 8, true},
+	{"Innodb_row_lock_waits", 3, false},
+	{"Performance_schema_digest_lost", 2, false},
+	{"Performance_schema_table_handles_lost", 0.05, false},
+}
+
+// the string columns of the rows of each table we have data for
+var tables = map[string][]map[string]string{
+	"table_io_waits_summary_by_table":   append(tableRows(), temporaryTableRow("shop", "tmp_report")),
+	"table_lock_waits_summary_by_table": tableRows(),
+	"table_handles":                     tableRows(),
+	"tables":                            tableRows(),
+	"file_summary_by_instance": nameRows("FILE_NAME",
+		"/var/lib/mysql/shop/orders.ibd",
+		"/var/lib/mysql/shop/order_items.ibd",
+		"/var/lib/mysql/shop/events#P#p2025.ibd",
+		"/var/lib/mysql/shop/events#P#p2026.ibd",
+		"/var/lib/mysql/#innodb_redo/#ib_redo12",
+		"/var/lib/mysql/binlog.000042",
+		"/var/lib/mysql/shop/customers.ibd",
+		"/var/lib/mysql/ibtmp1",
+		"/var/lib/mysql/undo_001",
+		"/var/lib/mysql/relay-bin.000007",
+		"/var/lib/mysql/audit/events.ibd",
+		"/var/lib/mysql/mysql.ibd"),
+	"events_waits_summary_global_by_event_name": nameRows("EVENT_NAME",
+		"wait/synch/mutex/innodb/buf_pool_mutex",
+		"wait/synch/mutex/innodb/log_sys_mutex",
+		"wait/synch/mutex/innodb/trx_sys_mutex",
+		"wait/synch/mutex/innodb/lock_mutex",
+		"wait/synch/mutex/innodb/fil_system_mutex",
+		"wait/synch/mutex/innodb/dict_sys_mutex",
+		"wait/synch/mutex/innodb/flush_list_mutex",
+		"wait/io/file/innodb/innodb_data_file",
+		"wait/io/file/innodb/innodb_log_file",
+		"wait/io/file/sql/binlog",
+		"wait/io/table/sql/handler",
+		"wait/lock/table/sql/handler",
+		"wait/synch/rwlock/innodb/dict_operation_lock",
+		"wait/synch/cond/sql/MYSQL_BIN_LOG::COND_done",
+		"idle"),
+	"events_waits_summary_by_account_by_event_name": accountRows(
+		"wait/synch/mutex/innodb/buf_pool_mutex",
+		"wait/synch/mutex/innodb/log_sys_mutex",
+		"wait/synch/mutex/innodb/trx_sys_mutex",
+		"wait/synch/mutex/innodb/lock_mutex",
+		"wait/io/file/innodb/innodb_data_file",
+		"wait/io/file/sql/binlog"),
+	"events_stages_summary_global_by_event_name": nameRows("EVENT_NAME",
+		"stage/sql/executing",
+		"stage/sql/Sending data",
+		"stage/sql/Opening tables",
+		"stage/sql/statistics",
+		"stage/sql/updating",
+		"stage/sql/System lock",
+		"stage/sql/waiting for handler commit",
+		"stage/sql/freeing items"),
+	"memory_summary_global_by_event_name": nameRows("EVENT_NAME",
+		"memory/innodb/buf_buf_pool",
+		"memory/innodb/log_buffer_memory",
+		"memory/sql/THD::main_mem_root",
+		"memory/performance_schema/table_handles",
+		"memory/sql/TABLE",
+		"memory/innodb/ha_innodb"),
+	"events_statements_summary_by_digest": digestRows(),
+	"events_statements_summary_by_program": {
+		{"OBJECT_TYPE": "PROCEDURE", "OBJECT_SCHEMA": "shop", "OBJECT_NAME": "place_order"},
+		{"OBJECT_TYPE": "TRIGGER", "OBJECT_SCHEMA": "shop", "OBJECT_NAME": "order_items_ai"},
+		{"OBJECT_TYPE": "FUNCTION", "OBJECT_SCHEMA": "shop", "OBJECT_NAME": "stock_level"},
+		{"OBJECT_TYPE": "EVENT", "OBJECT_SCHEMA": "shop", "OBJECT_NAME": "expire_sessions"},
+	},
+	"prepared_statements_instances": {
+		{"OWNER_THREAD_ID": "31", "STATEMENT_ID": "1", "PROCESSLIST_USER": "app", "PROCESSLIST_HOST": "app1.example.com", "SQL_TEXT": "SELECT * FROM orders WHERE customer_id = ?"},
+		{"OWNER_THREAD_ID": "31", "STATEMENT_ID": "2", "PROCESSLIST_USER": "app", "PROCESSLIST_HOST": "app1.example.com", "SQL_TEXT": "UPDATE stock SET quantity = quantity - ? WHERE product_id = ?"},
+		{"OWNER_THREAD_ID": "32", "STATEMENT_ID": "1", "PROCESSLIST_USER": "app", "PROCESSLIST_HOST": "app2.example.com", "SQL_TEXT": "SELECT * FROM orders WHERE customer_id = ?"},
+		{"OWNER_THREAD_ID": "34", "STATEMENT_ID": "1", "PROCESSLIST_USER": "report", "PROCESSLIST_HOST": "localhost", "SQL_TEXT": "SELECT COUNT(*) FROM sessions WHERE last_seen < ?"},
+		{"OWNER_THREAD_ID": "34", "STATEMENT_ID": "2", "PROCESSLIST_USER": "report", "PROCESSLIST_HOST": "localhost", "SQL_TEXT": "SELECT COUNT(*) FROM sessions WHERE last_seen < ?"},
+	},
+	"processlist":                          processlistRows(),
+	"replication_connection_configuration": {}, // not a replica
+	"setup_consumers": {
+		{"NAME": "global_instrumentation", "ENABLED": "YES"},
+		{"NAME": "thread_instrumentation", "ENABLED": "YES"},
+		{"NAME": "statements_digest", "ENABLED": "YES"},
+		{"NAME": "events_statements_current", "ENABLED": "YES"},
+		{"NAME": "events_stages_current", "ENABLED": "YES"},
+		{"NAME": "events_statements_history_long", "ENABLED": "YES"},
+		{"NAME": "events_stages_history_long", "ENABLED": "YES"},
+		{"NAME": "events_statements_cpu", "ENABLED": "YES"},
+	},
+	"events_statements_history_long": statementHistoryRows(),
+	"events_stages_history_long":     stageHistoryRows(),
+	"threads":                        threadRows(),
+	"events_statements_current":      {{"SQL_TEXT": "SELECT * FROM orders WHERE customer_id = 42"}},
+	"events_stages_current":          {{"EVENT_NAME": "stage/sql/executing"}},
+	"events_waits_current":           {{"EVENT_NAME": "wait/io/table/sql/handler shop.orders"}},
+	"table_io_waits_summary_by_index_usage": {
+		{"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "orders", "INDEX_NAME": "idx_status", "COLUMN_NAME": "status,created", "NON_UNIQUE": "1"},
+		{"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "customers", "INDEX_NAME": "uk_email", "COLUMN_NAME": "email", "NON_UNIQUE": "0"},
+		{"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "sessions", "INDEX_NAME": "idx_expires", "COLUMN_NAME": "expires", "NON_UNIQUE": "1"},
+		{"OBJECT_SCHEMA": "audit", "OBJECT_NAME": "events", "INDEX_NAME": "idx_user_time", "COLUMN_NAME": "user_id,created", "NON_UNIQUE": "1"},
+	},
+	"global_variables": {{"VARIABLE_NAME": "log_bin"}},
+	"data_lock_waits": {
+		{"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "stock", "LOCK_MODE": "X,REC_NOT_GAP",
+			"RT.PROCESSLIST_USER": "app", "BT.PROCESSLIST_USER": "batch"},
+		{"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "stock", "LOCK_MODE": "X,REC_NOT_GAP",
+			"RT.PROCESSLIST_USER": "app", "BT.PROCESSLIST_USER": "batch"},
+	},
+	// the holders of the metadata locks and, by alias, who waits for whom
+	"metadata_locks": {
+		{"PROCESSLIST_USER": "app", "WT.PROCESSLIST_USER": "report", "BT.PROCESSLIST_USER": "batch",
+			"WT.PROCESSLIST_ID": "104", "BT.PROCESSLIST_ID": "102",
+			"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "orders", "LOCK_TYPE": "SHARED_READ"},
+		{"PROCESSLIST_USER": "batch", "WT.PROCESSLIST_USER": "app", "BT.PROCESSLIST_USER": "batch",
+			"WT.PROCESSLIST_ID": "103", "BT.PROCESSLIST_ID": "102",
+			"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "orders", "LOCK_TYPE": "SHARED_WRITE"},
+		{"PROCESSLIST_USER": "report", "WT.PROCESSLIST_USER": "report", "BT.PROCESSLIST_USER": "app",
+			"WT.PROCESSLIST_ID": "104", "BT.PROCESSLIST_ID": "101",
+			"OBJECT_SCHEMA": "shop", "OBJECT_NAME": "orders", "LOCK_TYPE": "SHARED_READ"},
+	},
+	"events_waits_summary_by_thread_by_event_name": {{"EVENT_NAME": "idle"}},
+	"innodb_trx": {
+		{"PROCESSLIST_USER": "app"},
+		{"PROCESSLIST_USER": "batch"},
+	},
+	// the stats schema of the ProxySQL admin interface
+	"stats_mysql_query_digest": proxyDigestRows(),
+	"stats_mysql_connection_pool": {
+		{"HOSTGROUP": "10", "SRV_HOST": "db1.example.com", "SRV_PORT": "3306", "STATUS": "ONLINE"},
+		{"HOSTGROUP": "20", "SRV_HOST": "db2.example.com", "SRV_PORT": "3306", "STATUS": "ONLINE"},
+		{"HOSTGROUP": "20", "SRV_HOST": "db3.example.com", "SRV_PORT": "3306", "STATUS": "ONLINE"},
+		{"HOSTGROUP": "20", "SRV_HOST": "db4.example.com", "SRV_PORT": "3306", "STATUS": "SHUNNED"},
+	},
+}
+
+// tableRows returns the rows of the tables of the synthetic server
+func tableRows() []map[string]string {
+	names := []string{"shop.orders", "shop.order_items", "shop.customers", "shop.products",
+		"shop.stock", "shop.payments", "shop.sessions", "audit.events", "audit.logins", "legacy.archive"}
+	var rows []map[string]string
+
+	for _, name := range names {
+		parts := strings.SplitN(name, ".", 2)
+		engine := "InnoDB"
+		if parts[0] == "legacy" {
+			engine = "MyISAM"
+		}
+		rows = append(rows, map[string]string{
+			"OBJECT_TYPE":   "TABLE",
+			"OBJECT_SCHEMA": parts[0],
+			"OBJECT_NAME":   parts[1],
+			"TABLE_SCHEMA":  parts[0],
+			"TABLE_NAME":    parts[1],
+			"ENGINE":        engine,
+		})
+	}
+
+	return rows
+}
+
+// temporaryTableRow returns the row of a temporary table
+func temporaryTableRow(schema, name string) map[string]string {
+	return map[string]string{
+		"OBJECT_TYPE":   "TEMPORARY TABLE",
+		"OBJECT_SCHEMA": schema,
+		"OBJECT_NAME":   name,
+	}
+}
+
+// nameRows returns rows with a single string column
+func nameRows(column string, names ...string) []map[string]string {
+	rows := make([]map[string]string, len(names))
+
+	for i := range names {
+		rows[i] = map[string]string{column: names[i]}
+	}
+
+	return rows
+}
+
+// accountRows returns the rows of each account of the synthetic server for the events
+func accountRows(names ...string) []map[string]string {
+	var rows []map[string]string
+
+	for _, account := range [][2]string{{"app", "app1.example.com"}, {"app", "app2.example.com"}, {"report", "localhost"}} {
+		for i := range names {
+			rows = append(rows, map[string]string{"USER": account[0], "HOST": account[1], "EVENT_NAME": names[i]})
+		}
+	}
+
+	return rows
+}
+
+// digestRows returns the statement digests of the synthetic server
+func digestRows() []map[string]string {
+	texts := []string{
+		"SELECT * FROM `orders` WHERE `customer_id` = ?",
+		"SELECT `p` . * FROM `products` `p` JOIN `stock` `s` USING ( `product_id` ) WHERE `s` . `quantity` > ?",
+		"UPDATE `stock` SET `quantity` = `quantity` - ? WHERE `product_id` = ?",
+		"INSERT INTO `order_items` VALUES (...)",
+		"SELECT COUNT ( * ) FROM `sessions` WHERE `last_seen` < NOW ( ) - INTERVAL ? MINUTE",
+		"DELETE FROM `sessions` WHERE `expires` < NOW ( )",
+	}
+	rows := make([]map[string]string, len(texts))
+
+	for i := range texts {
+		rows[i] = map[string]string{
+			"SCHEMA_NAME": "shop",
+			"DIGEST":      fmt.Sprintf("%064x", hash(texts[i])),
+			"DIGEST_TEXT": texts[i],
+		}
+	}
+
+	return rows
+}
+
+// proxyDigestRows returns the digests seen by ProxySQL, the writes sent
+// to the writer hostgroup (10) and the reads to the readers (20)
+func proxyDigestRows() []map[string]string {
+	var rows []map[string]string
+
+	for _, row := range digestRows() {
+		hostgroup := "20"
+		if !strings.HasPrefix(row["DIGEST_TEXT"], "SELECT") {
+			hostgroup = "10"
+		}
+		rows = append(rows, map[string]string{
+			"HOSTGROUP":   hostgroup,
+			"SCHEMANAME":  row["SCHEMA_NAME"],
+			"USERNAME":    "app",
+			"DIGEST":      "0x" + strings.ToUpper(row["DIGEST"][48:]),
+			"DIGEST_TEXT": row["DIGEST_TEXT"],
+		})
+	}
+
+	return rows
+}
+
+// statementHistoryRows returns recent statements, two of each digest
+func statementHistoryRows() []map[string]string {
+	var rows []map[string]string
+
+	for i, digest := range digestRows() {
+		for j := 0; j < 2; j++ {
+			rows = append(rows, map[string]string{
+				"THREAD_ID":   fmt.Sprintf("%d", 101+j),
+				"EVENT_ID":    fmt.Sprintf("%d", 10*(i+1)),
+				"DIGEST_TEXT": digest["DIGEST_TEXT"],
+			})
+		}
+	}
+
+	return rows
+}
+
+// stageHistoryRows returns the stages of the recent statements
+func stageHistoryRows() []map[string]string {
+	var rows []map[string]string
+
+	for _, statement := range statementHistoryRows() {
+		for _, stage := range []string{"stage/sql/executing", "stage/sql/Opening tables", "stage/sql/statistics"} {
+			rows = append(rows, map[string]string{
+				"THREAD_ID":        statement["THREAD_ID"],
+				"NESTING_EVENT_ID": statement["EVENT_ID"],
+				"EVENT_NAME":       stage,
+			})
+		}
+	}
+
+	return rows
+}
+
+// processlistRows returns the connections to the synthetic server
+func processlistRows() []map[string]string {
+	var rows []map[string]string
+
+	for i := 0; i < 12; i++ {
+		row := map[string]string{
+			"USER":    []string{"app", "app", "app", "report", "repl"}[i%5],
+			"HOST":    fmt.Sprintf("app%d.example.com:%d", i%3+1, 40000+i),
+			"DB":      "shop",
+			"COMMAND": "Sleep",
+			"STATE":   "",
+			"INFO":    "",
+		}
+		if i%3 == 0 {
+			row["COMMAND"] = "Query"
+			row["STATE"] = "executing"
+			row["INFO"] = "SELECT * FROM orders WHERE customer_id = 42"
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// threadRows returns the connections of the synthetic server as in
+// performance_schema.threads, whose hosts have no port
+func threadRows() []map[string]string {
+	var rows []map[string]string
+
+	for _, p := range processlistRows() {
+		row := make(map[string]string)
+		for column, value := range p {
+			row["PROCESSLIST_"+column] = value
+		}
+		row["PROCESSLIST_HOST"] = p["HOST"][:strings.Index(p["HOST"], ":")]
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// hash returns a hash of the string used to give each value its own rate
+func hash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+
+	return h.Sum32()
+}
+
+// counter returns a value growing at roughly rate per second which
+// speeds up and slows down a little over time but never goes backwards
+func counter(rate, seconds float64, seed uint32) float64 {
+	w := 0.2 + float64(seed%7)/10
+
+	return rate * (baseUptime + seconds + 0.8*math.Sin(w*seconds)/w)
+}
+
+// gauge returns a value which varies a little around level
+func gauge(level, seconds float64, seed uint32) float64 {
+	return level * (1 + 0.1*math.Sin(seconds*(0.1+float64(seed%5)/10)))
+}
+
+// the value of a numeric expression of the given row (numbered from 0).
+// Totals such as SUM_TIMER_WAIT grow steadily and other values of the
+// same kind are a share of them so percentages make sense.
+func number(expression string, row int, seconds float64) int64 {
+	weight := 1 / float64(row+1)
+	seed := hash(expression) + uint32(row)
+
+	switch {
+	case expression == "1":
+		return 1
+	case expression == "ID", expression == "PROCESSLIST_ID":
+		return int64(100 + row)
+	case expression == "TIME", strings.Contains(expression, "PROCESSLIST_TIME"):
+		return int64(seconds) % int64(5*(row+1))
+	case strings.Contains(expression, "HIGH_"):
+		return int64(1.5 * gauge(100000*weight, 0, hash(strings.Replace(expression, "HIGH_", "CURRENT_", 1))))
+	case strings.Contains(expression, "CURRENT_"):
+		return int64(gauge(100000*weight, seconds, hash(expression)))
+	case strings.Contains(expression, "COUNT(*)"):
+		return int64(gauge(40*weight, seconds, hash(expression)))
+	case strings.Contains(expression, "LOCK_STATUS"):
+		return int64(gauge(8*weight, seconds, hash(expression)))
+	case strings.Contains(expression, "ROWS_LOCKED"):
+		return int64(gauge(5000*weight, seconds, hash(expression)))
+	case strings.Contains(expression, "LOCK IS NOT NULL"):
+		return int64(gauge(3*weight, seconds, hash(expression)))
+	case strings.Contains(expression, "END_EVENT_ID IS NULL"):
+		return int64(seconds) % 2
+	case strings.Contains(expression, " LIKE "):
+		return int64(row % 2)
+	case strings.Contains(expression, "TIMESTAMPDIFF"):
+		return int64(seconds) % 50
+	case expression == "OBJECT_INSTANCE_BEGIN":
+		return int64(0x7f0000000000 + 0x1000*row)
+	case expression == "AVG_ROW_LENGTH":
+		return int64(80 + 40*(row%5))
+	case expression == "CONNUSED", expression == "CONNFREE":
+		return int64(gauge(20*weight, seconds, hash(expression)))
+	case expression == "LATENCY_US":
+		return int64(gauge(300, seconds, seed))
+	case expression == "SUM_CPU_TIME":
+		// part of the latency, most of it for some statements and
+		// little for those which mostly wait
+		return int64(counter(5e10*weight, seconds, uint32(row)) * (0.1 + float64(row%5)/5))
+	case strings.Contains(expression, "SUM_TIMER_IDLE"):
+		// the connections mostly wait for their clients
+		return int64(counter(1e12*(0.3+float64(seed%7)/10), seconds, seed))
+	}
+
+	kind, rate := "COUNT_STAR", 500.0
+	switch {
+	case strings.Contains(expression, "TIMER"):
+		kind, rate = "SUM_TIMER_WAIT", 5e10
+	case strings.Contains(expression, "SUM_TIME"):
+		kind, rate = "SUM_TIME", 5e4 // in microseconds, e.g. ProxySQL's
+	case strings.Contains(expression, "BYTES"):
+		kind, rate = "BYTES", 2e6
+	case strings.Contains(expression, "ROWS"):
+		kind, rate = "SUM_ROWS_EXAMINED", 20000
+	}
+	total := counter(rate*weight, seconds, uint32(row))
+	if strings.HasSuffix(expression, kind) {
+		return int64(total)
+	}
+
+	return int64(total * (0.05 + float64(seed%6)/40))
+}
+
+// the status variables whose names match the LIKE patterns (or all)
+func statusRows(patterns []driver.Value, seconds float64) [][]driver.Value {
+	var values [][]driver.Value
+
+	all := append([]statusVariable{{"Uptime", 1, false}}, status...)
+	for _, v := range all {
+		matched := len(patterns) == 0
+		for _, pattern := range patterns {
+			prefix := strings.TrimSuffix(fmt.Sprint(pattern), "%")
+			if strings.HasPrefix(strings.ToLower(v.name), strings.ToLower(prefix)) {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+		value := baseUptime + seconds
+		if v.gauge {
+			value = gauge(v.rate, seconds, hash(v.name))
+		} else if v.name != "Uptime" {
+			value = counter(v.rate, seconds, hash(v.name))
+		}
+		values = append(values, []driver.Value{v.name, fmt.Sprintf("%d", int64(value))})
+	}
+
+	return values
+}
+
+// splitTopLevel splits s at each separator which is not inside brackets or quotes
+func splitTopLevel(s string, separator byte) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'':
+			quoted = !quoted
+		case quoted:
+		case s[i] == '(':
+			depth++
+		case s[i] == ')':
+			depth--
+		case s[i] == separator && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// parseSelect returns the expressions selected and the table selected from
+func parseSelect(query string) ([]string, string) {
+	words := strings.Fields(query)
+	var expressions []string
+	var table string
+
+	// find SELECT and the FROM which isn't inside brackets
+	for i := range words {
+		if strings.ToUpper(words[i]) == "SELECT" {
+			rest := strings.Join(words[i+1:], " ")
+			upper := strings.ToUpper(rest)
+			depth := 0
+			for j := 0; j < len(rest); j++ {
+				switch rest[j] {
+				case '(':
+					depth++
+				case ')':
+					depth--
+				}
+				if depth == 0 && strings.HasPrefix(upper[j:], " FROM ") {
+					for _, e := range splitTopLevel(rest[:j], ',') {
+						expressions = append(expressions, strings.TrimSpace(e))
+					}
+					if from := strings.Fields(rest[j+6:]); len(from) > 0 {
+						table = strings.ToLower(from[0][strings.LastIndex(from[0], ".")+1:])
+					}
+					break
+				}
+			}
+			break
+		}
+	}
+
+	return expressions, table
+}
+
+// matches a table alias before a column name, e.g. "t." in "t.COUNT_STAR"
+var tableAlias = regexp.MustCompile(`\b\w+\.`)
+
+// matches an aggregate of a column, which is a number even for string columns
+var aggregate = regexp.MustCompile(`^(SUM|COUNT)\(`)
+
+// matches a condition on the start of the event names, e.g.
+// EVENT_NAME LIKE 'wait/synch/mutex/innodb/%', of which there may be several
+var eventNameLike = regexp.MustCompile(`EVENT_NAME LIKE '([^'%]*)%'`)
+
+// value returns the value of the expression for a row with the given
+// string columns. An expression referring to a string column is given
+// that column's value and others are numbers.
+func value(expression string, row int, strs map[string]string, seconds float64) driver.Value {
+	upper := strings.ToUpper(expression)
+	best := ""
+	for column := range strs {
+		if strings.Contains(upper, column) && len(column) > len(best) {
+			best = column
+		}
+	}
+	if best != "" && !aggregate.MatchString(upper) {
+		return strs[best]
+	}
+	if strings.HasSuffix(upper, "TRX_MYSQL_THREAD_ID") {
+		// the requesting (r) connections wait for the first blocking (b) one
+		if strings.HasPrefix(upper, "R.") {
+			return int64(101 + row)
+		}
+		return int64(100)
+	}
+	if i := strings.Index(upper, " AS "); i > 0 {
+		upper = strings.TrimSpace(upper[:i])
+	}
+
+	return number(tableAlias.ReplaceAllString(upper, ""), row, seconds)
+}
+
+// likeAny returns true if the name starts with any of the prefixes matched by eventNameLike
+func likeAny(name string, like [][]string) bool {
+	for i := range like {
+		if strings.HasPrefix(name, like[i][1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// query returns the columns and rows of the synthetic result of the query
+func query(q string, args []driver.Value, seconds float64) ([]string, [][]driver.Value, error) {
+	upper := strings.ToUpper(q)
+
+	switch {
+	case strings.Contains(upper, "VARIABLE_NAME, VARIABLE_VALUE") && strings.Contains(upper, "VARIABLES"):
+		var values [][]driver.Value
+		for name, value := range variables {
+			values = append(values, []driver.Value{name, value})
+		}
+		return []string{"VARIABLE_NAME", "VARIABLE_VALUE"}, values, nil
+	case strings.Contains(upper, "GLOBAL_STATUS") && strings.Contains(upper, "VARIABLE_NAME = ?"):
+		var values [][]driver.Value
+		for _, row := range statusRows(args, seconds) {
+			if strings.EqualFold(fmt.Sprint(row[0]), fmt.Sprint(args[0])) {
+				values = append(values, row[1:])
+			}
+		}
+		return []string{"VARIABLE_VALUE"}, values, nil
+	case strings.Contains(upper, "GLOBAL_STATUS"):
+		return []string{"VARIABLE_NAME", "VARIABLE_VALUE"}, statusRows(args, seconds), nil
+	case strings.HasPrefix(upper, "SHOW MASTER STATUS"):
+		columns, values := binlogStatus(seconds)
+		return columns, values, nil
+	case strings.HasPrefix(upper, "SHOW BINARY LOGS"):
+		return []string{"Log_name", "File_size"}, [][]driver.Value{{binlogFile, int64(binlogPosition(seconds))}}, nil
+	case strings.HasPrefix(upper, "SHOW BINLOG EVENTS"):
+		columns, values := binlogEvents(q, seconds)
+		return columns, values, nil
+	case strings.Contains(upper, "WORK_ESTIMATED"):
+		columns, values := ddlProgress(seconds)
+		return columns, values, nil
+	case strings.Contains(upper, "FROM SETUP_INSTRUMENTS"):
+		// all instruments are enabled and timed
+		if strings.Contains(upper, "COUNT(*)") {
+			return []string{"COUNT(*)", "ENABLED", "TIMED"}, [][]driver.Value{{int64(50), int64(50), int64(50)}}, nil
+		}
+		return []string{"NAME", "ENABLED", "TIMED"}, nil, nil
+	}
+
+	expressions, table := parseSelect(q)
+	rows, found := tables[table]
+	if !found {
+		return nil, nil, fmt.Errorf("Error 1146: Table '%s' doesn't exist in demo mode", table)
+	}
+
+	like := eventNameLike.FindAllStringSubmatch(q, -1)
+	var values [][]driver.Value
+	for i := range rows {
+		if strings.Contains(upper, "LIMIT 0") || (strings.Contains(upper, "LIMIT 1") && i > 0) {
+			break
+		}
+		if like != nil && !likeAny(rows[i]["EVENT_NAME"], like) {
+			continue
+		}
+		row := make([]driver.Value, len(expressions))
+		for j := range expressions {
+			row[j] = value(expressions[j], i, rows[i], seconds)
+		}
+		values = append(values, row)
+	}
+
+	return columnNames(expressions), values, nil
+}
+
+// columnNames returns the names of the columns of the expressions
+// selected, which is their alias if they have one
+func columnNames(expressions []string) []string {
+	names := make([]string, len(expressions))
+
+	for i := range expressions {
+		names[i] = expressions[i]
+		if j := strings.Index(strings.ToUpper(expressions[i]), " AS "); j > 0 {
+			names[i] = strings.TrimSpace(expressions[i][j+4:])
+		}
+	}
+
+	return names
+}
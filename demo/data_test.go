@@ -0,0 +1,46 @@
+package demo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		columns []string
+		rows    bool // some rows are expected
+	}{
+		{"SELECT VARIABLE_NAME, VARIABLE_VALUE FROM performance_schema.global_variables", []string{"VARIABLE_NAME", "VARIABLE_VALUE"}, true},
+		{"SHOW MASTER STATUS", []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}, true},
+		{"SHOW BINARY LOGS", []string{"Log_name", "File_size"}, true},
+		{"SELECT NAME, ENABLED, TIMED FROM setup_instruments WHERE NAME LIKE 'wait/%'", []string{"NAME", "ENABLED", "TIMED"}, false},
+		{"SELECT EVENT_NAME, SUM_TIMER_WAIT, COUNT_STAR FROM events_waits_summary_global_by_event_name WHERE SUM_TIMER_WAIT > 0", []string{"EVENT_NAME", "SUM_TIMER_WAIT", "COUNT_STAR"}, true},
+		{"SELECT EVENT_NAME AS eventName, COUNT_ALLOC + COUNT_FREE AS totalMemoryOps FROM memory_summary_global_by_event_name", []string{"eventName", "totalMemoryOps"}, true},
+		{"SELECT EVENT_NAME FROM events_waits_summary_global_by_event_name WHERE EVENT_NAME LIKE 'no/such/event/%'", []string{"EVENT_NAME"}, false},
+	}
+
+	for _, test := range tests {
+		columns, values, err := query(test.query, nil, 60)
+		if err != nil {
+			t.Errorf("query(%q) returned an error: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(columns, test.columns) {
+			t.Errorf("query(%q) returned the columns %q, expected %q", test.query, columns, test.columns)
+		}
+		if (len(values) > 0) != test.rows {
+			t.Errorf("query(%q) returned %d row(s), expected rows: %v", test.query, len(values), test.rows)
+		}
+		for _, row := range values {
+			if len(row) != len(columns) {
+				t.Errorf("query(%q) returned a row with %d values for %d columns", test.query, len(row), len(columns))
+				break
+			}
+		}
+	}
+
+	if _, _, err := query("SELECT * FROM no_such_table", nil, 60); err == nil {
+		t.Errorf("query() of a table the demo doesn't have expected an error")
+	}
+}
@@ -0,0 +1,40 @@
+package demo
+
+import (
+	"database/sql/driver"
+)
+
+// the ALTER TABLE running on the synthetic server, which starts again
+// when it has finished
+const (
+	ddlStatement = "ALTER TABLE orders ADD INDEX idx_created (created)"
+	ddlDuration  = 600    // seconds to complete
+	ddlStarted   = 90     // seconds it had been running when the demo started
+	ddlWork      = 240000 // WORK_ESTIMATED
+)
+
+// the stages of the ALTER TABLE and the fraction of the work done at the end of each
+var ddlStages = []struct {
+	name string
+	done float64
+}{
+	{"stage/innodb/alter table (read PK and internal sort)", 0.45},
+	{"stage/innodb/alter table (merge sort)", 0.85},
+	{"stage/innodb/alter table (insert)", 0.95},
+	{"stage/innodb/alter table (log apply index)", 1},
+}
+
+// ddlProgress returns the ALTER TABLE in progress with its current stage
+func ddlProgress(seconds float64) ([]string, [][]driver.Value) {
+	columns := []string{"PROCESSLIST_ID", "PROCESSLIST_USER", "PROCESSLIST_TIME", "EVENT_ID", "SQL_TEXT", "EVENT_NAME", "WORK_COMPLETED", "WORK_ESTIMATED"}
+
+	elapsed := int64(seconds+ddlStarted) % ddlDuration
+	run := (int64(seconds) + ddlStarted) / ddlDuration
+	done := float64(elapsed) / ddlDuration
+	stage := ddlStages[len(ddlStages)-1].name
+	for i := len(ddlStages) - 1; i >= 0 && done < ddlStages[i].done; i-- {
+		stage = ddlStages[i].name
+	}
+
+	return columns, [][]driver.Value{{int64(120), "user1", elapsed, 1000 + run, ddlStatement, stage, int64(done * ddlWork), int64(ddlWork)}}
+}
@@ -0,0 +1,110 @@
+// Package demo provides a database/sql driver which answers the queries
+// made by ps-top with synthetic data so the program can be run without
+// a MySQL server, e.g. for screenshots, demos or working on the display.
+package demo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// DriverName is the name of the demo sql driver
+const DriverName = "ps-top-demo"
+
+// when the driver was loaded, so the synthetic counters grow from then
+var started = time.Now()
+
+// demoDriver returns connections to the synthetic server
+type demoDriver struct{}
+
+// Open returns a new connection, the dsn is ignored
+func (demoDriver) Open(dsn string) (driver.Conn, error) {
+	return conn{}, nil
+}
+
+// conn is a connection to the synthetic server
+type conn struct{}
+
+// Prepare returns a statement for the query
+func (conn) Prepare(query string) (driver.Stmt, error) {
+	return stmt{query: query}, nil
+}
+
+// Close does nothing
+func (conn) Close() error {
+	return nil
+}
+
+// Begin is not supported
+func (conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported in demo mode")
+}
+
+// stmt is a prepared statement
+type stmt struct {
+	query string
+}
+
+// Close does nothing
+func (stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 as we accept any number of arguments
+func (stmt) NumInput() int {
+	return -1
+}
+
+// Exec accepts any change (e.g. to setup_instruments) but does nothing
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	logger.Println("demo.stmt.Exec(", s.query, ")")
+	return driver.RowsAffected(0), nil
+}
+
+// Query returns the synthetic result of the query
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	logger.Println("demo.stmt.Query(", s.query, ")")
+	columns, values, err := query(s.query, args, time.Since(started).Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{columns: columns, values: values}, nil
+}
+
+// rows holds the result of a query
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+// Columns returns the names of the columns
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+// Close does nothing
+func (r *rows) Close() error {
+	return nil
+}
+
+// Next copies the next row into dest
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+
+	return nil
+}
+
+func init() {
+	sql.Register(DriverName, demoDriver{})
+}
@@ -0,0 +1,41 @@
+package display
+
+import (
+	"strings"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// allRowsData shows the names of the rows without activity which the
+// views leave blank, so the full list of objects is seen on a quiet
+// server
+type allRowsData struct {
+	GenericData // embedded
+	resulter    ps_table.Resulter
+}
+
+// NewAllRowsData returns the data with the names of the rows without
+// activity filled in
+func NewAllRowsData(data GenericData, resulter ps_table.Resulter) GenericData {
+	a := allRowsData{GenericData: data, resulter: resulter}
+	return withValues(a, data)
+}
+
+// Description says the rows without activity are shown
+func (a allRowsData) Description() string {
+	return a.GenericData.Description() + " (all rows)"
+}
+
+// RowContent fills in the names left blank
+func (a allRowsData) RowContent() []string {
+	rows := a.GenericData.RowContent()
+	results := a.resulter.Results()
+
+	for i := range rows {
+		if i < len(results) && strings.HasSuffix(rows[i], "|") {
+			rows[i] += results[i].Name
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,59 @@
+package display
+
+import (
+	"github.com/sjmudd/ps-top/io_amplification"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// amplificationData adds the average row length and the file I/O per
+// row of each table to the table I/O data
+type amplificationData struct {
+	GenericData // embedded
+	resulter    ps_table.Resulter
+	estimates   map[string]io_amplification.Estimate
+	total       io_amplification.Estimate
+}
+
+// NewAmplificationData returns the table I/O data with the estimates
+// from the file I/O of the same tables added before the name of each row
+func NewAmplificationData(data GenericData, tableIO, fileIO ps_table.Resulter, rowLengths map[string]uint64) GenericData {
+	estimates, total := io_amplification.Estimates(tableIO.Results(), fileIO.Results(), rowLengths)
+	a := amplificationData{GenericData: data, resulter: tableIO, estimates: estimates, total: total}
+	return withValues(a, data)
+}
+
+// Description adds what the extra columns are
+func (a amplificationData) Description() string {
+	return a.GenericData.Description() + " with file I/O per row"
+}
+
+// Headings adds the names of the estimates
+func (a amplificationData) Headings() string {
+	return insert(a.GenericData.Headings(), io_amplification.Headings)
+}
+
+// RowContent adds the estimates of the table to each row
+func (a amplificationData) RowContent() []string {
+	rows := a.GenericData.RowContent()
+	results := a.resulter.Results()
+
+	for i := range rows {
+		var e io_amplification.Estimate
+		if i < len(results) {
+			e = a.estimates[results[i].Name]
+		}
+		rows[i] = insert(rows[i], e.Columns())
+	}
+
+	return rows
+}
+
+// TotalRowContent adds the estimates of all the tables together
+func (a amplificationData) TotalRowContent() string {
+	return insert(a.GenericData.TotalRowContent(), a.total.Columns())
+}
+
+// EmptyRowContent adds empty estimates
+func (a amplificationData) EmptyRowContent() string {
+	return insert(a.GenericData.EmptyRowContent(), make([]string, len(io_amplification.Headings)))
+}
@@ -0,0 +1,47 @@
+package display
+
+import (
+	"fmt"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// anomalyData marks the rows of the underlying data whose change is
+// unusually large
+type anomalyData struct {
+	GenericData // embedded
+	resulter    ps_table.Resulter
+	anomalies   map[string]float64 // standard deviations above the mean by row name
+}
+
+// NewAnomalyData returns the data with the rows in anomalies marked
+func NewAnomalyData(data GenericData, resulter ps_table.Resulter, anomalies map[string]float64) GenericData {
+	a := anomalyData{GenericData: data, resulter: resulter, anomalies: anomalies}
+	return withValues(a, data)
+}
+
+// Description adds the number of rows marked
+func (a anomalyData) Description() string {
+	if len(a.anomalies) == 0 {
+		return a.GenericData.Description()
+	}
+	return a.GenericData.Description() + fmt.Sprintf(" [%d ANOMALOUS]", len(a.anomalies))
+}
+
+// RowContent marks the rows whose change is unusually large with how
+// many standard deviations it is above their mean
+func (a anomalyData) RowContent() []string {
+	rows := a.GenericData.RowContent()
+	results := a.resulter.Results()
+
+	for i := range rows {
+		if i >= len(results) {
+			break
+		}
+		if deviations, ok := a.anomalies[results[i].Name]; ok {
+			rows[i] += fmt.Sprintf("  <- %.1f sd", deviations)
+		}
+	}
+
+	return rows
+}
@@ -0,0 +1,174 @@
+package display
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+)
+
+// archiveHeading is the schema of every archive file, one line per
+// value so it is the same whatever the view
+var archiveHeading = []string{"time", "host", "title", "view", "name", "column", "value"}
+
+// ArchiveDisplay appends the values of the rows of each collection to
+// a gzipped CSV file per view and day, <dir>/<view>-<yyyy-mm-dd>.csv.gz,
+// so the time series can be loaded directly into tools such as DuckDB
+// or pandas.
+type ArchiveDisplay struct {
+	BaseDisplay // embedded
+	dir         string
+	path        string // file being written
+	file        *os.File
+	gz          *gzip.Writer
+	csv         *csv.Writer
+}
+
+// NewArchiveDisplay returns an ArchiveDisplay writing to the directory,
+// which is created if needed
+func NewArchiveDisplay(dir string) (*ArchiveDisplay, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &ArchiveDisplay{dir: dir}, nil
+}
+
+// open appends to the file, writing the heading if it is new. Each
+// open adds a gzip member to the file which gzip readers treat as one
+// stream.
+func (s *ArchiveDisplay) open(path string) error {
+	s.close()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.path, s.file = path, file
+	s.gz = gzip.NewWriter(file)
+	s.csv = csv.NewWriter(s.gz)
+	if info.Size() == 0 {
+		return s.csv.Write(archiveHeading)
+	}
+
+	return nil
+}
+
+// close finishes the file being written, if any
+func (s *ArchiveDisplay) close() {
+	if s.file == nil {
+		return
+	}
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		log.Println("Unable to write the archive", s.path, ":", err)
+	}
+	if err := s.gz.Close(); err != nil {
+		log.Println("Unable to write the archive", s.path, ":", err)
+	}
+	s.file.Close()
+	s.path, s.file, s.gz, s.csv = "", nil, nil, nil
+}
+
+// ClearScreen does nothing for ArchiveDisplay
+func (s *ArchiveDisplay) ClearScreen() {
+}
+
+// Flush does nothing for ArchiveDisplay as each collection is flushed
+// as it is written
+func (s *ArchiveDisplay) Flush() {
+}
+
+// Display appends the current values of the rows to the file of the
+// view and the day they were collected
+func (s *ArchiveDisplay) Display(p GenericData) {
+	valuer, ok := p.(ps_table.Valuer)
+	if !ok {
+		return
+	}
+	collected := lib.InTimezone(p.LastCollectTime())
+	path := filepath.Join(s.dir, s.ctx.ViewName()+"-"+collected.Format("2006-01-02")+".csv.gz")
+	if path != s.path {
+		if err := s.open(path); err != nil {
+			log.Fatal("Unable to open the archive: ", err)
+		}
+	}
+
+	rows := valuer.Values()
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+	when := collected.Format(time.RFC3339)
+	for i := range rows {
+		columns := make([]string, 0, len(rows[i].Values))
+		for column := range rows[i].Values {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			s.csv.Write([]string{when, s.ctx.Hostname(), s.ctx.Title(), s.ctx.ViewName(), rows[i].Name, column, strconv.FormatUint(rows[i].Values[column], 10)})
+		}
+	}
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		log.Fatal("Unable to write the archive: ", err)
+	}
+	if err := s.gz.Flush(); err != nil {
+		log.Fatal("Unable to write the archive: ", err)
+	}
+}
+
+// DisplayHelp does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayHelp() {
+}
+
+// DisplayInstruments does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+}
+
+// DisplayConsumers does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+}
+
+// DisplayAbout does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayAbout(stats *self_stats.Stats) {
+}
+
+// DisplayInfo does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayInfo(info server_info.Info) {
+}
+
+// DisplayHistory does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) DisplayHistory(series row_history.Series) {
+}
+
+// Close finishes the file being written
+func (s *ArchiveDisplay) Close() {
+	s.close()
+}
+
+// Resize does nothing on an ArchiveDisplay
+func (s *ArchiveDisplay) Resize(width, height int) {
+}
+
+// EventChan returns a channel which never has events
+func (s *ArchiveDisplay) EventChan() chan event.Event {
+	return make(chan event.Event)
+}
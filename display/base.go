@@ -3,10 +3,12 @@ package display
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
 )
 
 // BaseDisplay holds the structure that is common for all types, somewhere
@@ -21,6 +23,15 @@ func (d *BaseDisplay) SetContext(ctx *context.Context) {
 	d.ctx = ctx
 }
 
+// Bell does nothing as only the interactive screen rings the bell
+func (d BaseDisplay) Bell() {
+}
+
+// DesktopNotification does nothing as only the interactive screen sends
+// desktop notifications
+func (d BaseDisplay) DesktopNotification(message string) {
+}
+
 // return ctx.Uptime() but protect against nil pointers
 func (d BaseDisplay) Uptime() int {
 	if d.ctx == nil {
@@ -35,21 +46,140 @@ func (d BaseDisplay) MyName() string {
 }
 
 // HeadingLine returns the heading line as a string
-func (d *BaseDisplay) HeadingLine(haveRelativeStats, wantRelativeStats bool, initial, last time.Time) string {
-	heading := d.MyName() + " " + d.ctx.Version() + " - " + nowHHMMSS() + " " + d.ctx.Hostname() + " / " + d.ctx.MySQLVersion() + ", up " + fmt.Sprintf("%-16s", lib.Uptime(d.Uptime()))
+func (d *BaseDisplay) HeadingLine(p GenericData) string {
+	heading := d.MyName() + " " + d.ctx.Version() + " - "
+	if title := d.ctx.Title(); title != "" {
+		heading += "[" + title + "] "
+	}
+	heading += nowHHMMSS() + " " + d.ctx.Hostname()
+	if proxy := d.ctx.Proxy(); proxy != "" {
+		heading += " via " + proxy
+	}
+	heading += " / " + d.ctx.MySQLVersion() + ", up " + fmt.Sprintf("%-16s", lib.Uptime(d.Uptime()))
 
-	if haveRelativeStats {
-		if wantRelativeStats {
-			heading += " [REL] " + fmt.Sprintf("%.0f seconds", time.Since(initial).Seconds())
+	if p.HaveRelativeStats() {
+		if p.WantRelativeStats() && p.SinceMark() {
+			heading += " [MARK] " + fmt.Sprintf("%.0f seconds", time.Since(p.MarkCollectTime()).Seconds())
+		} else if p.WantRelativeStats() {
+			heading += " [REL] " + fmt.Sprintf("%.0f seconds", time.Since(p.InitialCollectTime()).Seconds())
 		} else {
 			heading += " [ABS]             "
 		}
 	}
+	if lib.RawNumbers() {
+		heading += " [RAW us]"
+	}
+	if age := d.ctx.DataAge(); age >= 2*time.Second {
+		heading += fmt.Sprintf(" (data %.0fs old)", age.Seconds())
+	}
+	if watchdog := d.ctx.Watchdog(); watchdog != "" {
+		heading += " [WATCHDOG " + watchdog + "]"
+	}
+	if p := d.ctx.Process(); p != nil {
+		heading += " | " + p.Summary()
+	}
 	return heading
 }
 
-// if there's a better way of doing this do it better ...
+// viewNumberPrefix returns the number of the current view in a form
+// suitable to be shown before the view's description
+func (d BaseDisplay) viewNumberPrefix() string {
+	if d.ctx == nil || d.ctx.ViewNumber() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%d] ", d.ctx.ViewNumber())
+}
+
+// tabBar returns the views open as tabs, the current one in brackets,
+// to be shown before the view's description, or nothing if there are none
+func (d BaseDisplay) tabBar() string {
+	if d.ctx == nil || len(d.ctx.Tabs()) == 0 {
+		return ""
+	}
+	bar := ""
+	for _, tab := range d.ctx.Tabs() {
+		if tab == d.ctx.ViewName() {
+			bar += "[" + tab + "] "
+		} else {
+			bar += " " + tab + "  "
+		}
+	}
+	return bar + "| "
+}
+
+// statusPanelLines is the number of lines of the status watch panel
+const statusPanelLines = 2
+
+// statusPanel returns the status variables watched, packed into the
+// lines of the panel shown under the header, or nothing if none are
+// watched. Those which don't fit in the width are left out, and if the
+// width is 0 they are all shown on one line.
+func (d BaseDisplay) statusPanel(width int) []string {
+	if d.ctx == nil || len(d.ctx.StatusWatch()) == 0 {
+		return nil
+	}
+	if width <= 0 {
+		return []string{strings.Join(d.ctx.StatusWatch(), "  ")}
+	}
+
+	lines := make([]string, statusPanelLines)
+	line := 0
+	for _, item := range d.ctx.StatusWatch() {
+		if lines[line] != "" && len(lines[line])+2+len(item) > width {
+			if line++; line == len(lines) {
+				break
+			}
+		}
+		if lines[line] != "" {
+			lines[line] += "  "
+		}
+		lines[line] += item
+	}
+	return lines
+}
+
+// UptimeAverages returns the operations and latency per second averaged
+// over the server's uptime when absolute statistics are shown, so they
+// can be compared with the relative ones. It is empty otherwise or if the
+// data doesn't provide the values needed.
+func (d BaseDisplay) UptimeAverages(p GenericData) string {
+	valuer, ok := p.(ps_table.Valuer)
+	uptime := d.Uptime()
+	if !ok || !p.HaveRelativeStats() || p.WantRelativeStats() || uptime <= 0 {
+		return ""
+	}
+
+	var ops, latency uint64
+	var haveOps, haveLatency bool
+	for _, row := range valuer.Values() {
+		if v, found := row.Values["count_star"]; found {
+			ops += v
+			haveOps = true
+		}
+		if v, found := row.Values["sum_timer_wait"]; found {
+			latency += v
+			haveLatency = true
+		}
+	}
+
+	var averages []string
+	if haveOps {
+		averages = append(averages, strings.TrimSpace(lib.FormatAmount(ops/uint64(uptime)))+" ops/s")
+	}
+	if haveLatency {
+		averages = append(averages, strings.TrimSpace(lib.FormatTime(latency/uint64(uptime)))+"/s")
+	}
+	if len(averages) == 0 {
+		return ""
+	}
+	return " (avg since start: " + strings.Join(averages, ", ") + ")"
+}
+
+// nowHHMMSS returns the time now, followed by the zone if it isn't local
 func nowHHMMSS() string {
-	t := time.Now()
+	t := lib.InTimezone(time.Now())
+	if zone := lib.TimezoneName(); zone != "" {
+		return fmt.Sprintf("%2d:%02d:%02d %s", t.Hour(), t.Minute(), t.Second(), zone)
+	}
 	return fmt.Sprintf("%2d:%02d:%02d", t.Hour(), t.Minute(), t.Second())
 }
@@ -0,0 +1,164 @@
+package display
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+)
+
+// ChangesDisplay writes a change journal to stdout. Each collection it
+// writes one JSON object per line (NDJSON) for every row where a value
+// has changed by more than the threshold since the previous collection.
+type ChangesDisplay struct {
+	BaseDisplay // embedded
+	threshold   uint64
+	view        string                       // view of the last collection
+	previous    map[string]map[string]uint64 // values of the last collection by row name
+	encoder     *json.Encoder
+}
+
+// a change event written to the journal
+type change struct {
+	Time    string            `json:"time"`
+	Host    string            `json:"host"`
+	Title   string            `json:"title,omitempty"`
+	View    string            `json:"view"`
+	Name    string            `json:"name"`
+	Before  map[string]uint64 `json:"before"`
+	After   map[string]uint64 `json:"after"`
+	Anomaly float64           `json:"anomaly,omitempty"` // standard deviations above the row's usual change
+}
+
+// NewChangesDisplay returns a ChangesDisplay which reports rows where a
+// value changed by more than threshold between collections
+func NewChangesDisplay(threshold uint64) *ChangesDisplay {
+	return NewChangesDisplayTo(os.Stdout, threshold)
+}
+
+// NewChangesDisplayTo returns a ChangesDisplay which writes its journal to w
+func NewChangesDisplayTo(w io.Writer, threshold uint64) *ChangesDisplay {
+	s := new(ChangesDisplay)
+
+	s.threshold = threshold
+	s.encoder = json.NewEncoder(w)
+
+	return s
+}
+
+// changed returns true if any value has changed by more than the
+// threshold. A counter which has gone backwards has been reset, so
+// its current value is the change.
+func changed(before, after map[string]uint64, threshold uint64) bool {
+	for name, value := range after {
+		delta := value
+		if value >= before[name] {
+			delta = value - before[name]
+		}
+		if delta > threshold {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClearScreen does nothing for ChangesDisplay
+func (s *ChangesDisplay) ClearScreen() {
+}
+
+// Flush does nothing for ChangesDisplay
+func (s *ChangesDisplay) Flush() {
+}
+
+// Display writes the rows which have changed since the last collection.
+// Nothing is written the first time a view is seen as there is nothing
+// to compare against, nor for views without row values, which
+// --changes refuses at startup.
+func (s *ChangesDisplay) Display(p GenericData) {
+	valuer, ok := p.(ps_table.Valuer)
+	if !ok {
+		return
+	}
+	if s.ctx.ViewName() != s.view {
+		s.view = s.ctx.ViewName()
+		s.previous = nil
+	}
+
+	rows := valuer.Values()
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+	current := make(map[string]map[string]uint64, len(rows))
+	for i := range rows {
+		current[rows[i].Name] = rows[i].Values
+		if s.previous == nil || !changed(s.previous[rows[i].Name], rows[i].Values, s.threshold) {
+			continue
+		}
+		err := s.encoder.Encode(change{
+			Time:    lib.InTimezone(p.LastCollectTime()).Format(time.RFC3339),
+			Host:    s.ctx.Hostname(),
+			Title:   s.ctx.Title(),
+			View:    s.ctx.ViewName(),
+			Name:    rows[i].Name,
+			Before:  s.previous[rows[i].Name],
+			After:   rows[i].Values,
+			Anomaly: math.Round(s.ctx.Anomalies()[rows[i].Name]*10) / 10,
+		})
+		if err != nil {
+			log.Fatal("Unable to write change journal: ", err)
+		}
+	}
+	s.previous = current
+}
+
+// DisplayHelp does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayHelp() {
+}
+
+// DisplayInstruments does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+}
+
+// DisplayConsumers does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+}
+
+// DisplayAbout does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayAbout(stats *self_stats.Stats) {
+}
+
+// DisplayInfo does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayInfo(info server_info.Info) {
+}
+
+// DisplayHistory does nothing on a ChangesDisplay
+func (s *ChangesDisplay) DisplayHistory(series row_history.Series) {
+}
+
+// Close does nothing on a ChangesDisplay
+func (s *ChangesDisplay) Close() {
+}
+
+// Resize does nothing on a ChangesDisplay
+func (s *ChangesDisplay) Resize(width, height int) {
+}
+
+// EventChan creates a channel for event.Events and return the channel.
+// currently does nothing...
+func (s *ChangesDisplay) EventChan() chan event.Event {
+	e := make(chan event.Event)
+
+	return e
+}
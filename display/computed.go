@@ -0,0 +1,94 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sjmudd/ps-top/computed_column"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// computedData adds the configured computed columns to the underlying data
+type computedData struct {
+	GenericData // embedded
+	resulter    ps_table.Resulter
+	columns     []computed_column.Column
+}
+
+// NewComputedData returns the data with the computed columns added
+// before the name of each row
+func NewComputedData(data GenericData, resulter ps_table.Resulter, columns []computed_column.Column) GenericData {
+	c := computedData{GenericData: data, resulter: resulter, columns: columns}
+	return withValues(c, data)
+}
+
+// insert adds the given columns in front of the last '|' separated
+// column of the row, which holds the name
+func insert(row string, columns []string) string {
+	var extra string
+	for _, column := range columns {
+		extra += fmt.Sprintf(" %10s", column)
+	}
+
+	if i := strings.LastIndex(row, "|"); i >= 0 {
+		return row[:i] + extra + row[i:]
+	}
+	return row + extra
+}
+
+// values returns the computed columns for a row with the given values
+func (c computedData) values(values map[string]uint64) []string {
+	columns := make([]string, 0, len(c.columns))
+
+	for _, column := range c.columns {
+		columns = append(columns, column.Value(values))
+	}
+
+	return columns
+}
+
+// Headings adds the names of the computed columns
+func (c computedData) Headings() string {
+	names := make([]string, 0, len(c.columns))
+
+	for _, column := range c.columns {
+		names = append(names, column.Name)
+	}
+
+	return insert(c.GenericData.Headings(), names)
+}
+
+// RowContent adds the computed columns to each row
+func (c computedData) RowContent() []string {
+	rows := c.GenericData.RowContent()
+	results := c.resulter.Results()
+
+	for i := range rows {
+		var values map[string]uint64
+		if i < len(results) {
+			values = results[i].Values
+		}
+		rows[i] = insert(rows[i], c.values(values))
+	}
+
+	return rows
+}
+
+// TotalRowContent adds the computed columns calculated from the sum
+// of the values of all rows
+func (c computedData) TotalRowContent() string {
+	totals := make(map[string]uint64)
+
+	for _, result := range c.resulter.Results() {
+		for name, value := range result.Values {
+			totals[name] += value
+		}
+	}
+
+	return insert(c.GenericData.TotalRowContent(), c.values(totals))
+}
+
+// EmptyRowContent adds empty computed columns
+func (c computedData) EmptyRowContent() string {
+	return insert(c.GenericData.EmptyRowContent(), make([]string, len(c.columns)))
+}
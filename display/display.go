@@ -4,6 +4,11 @@ package display
 import (
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
 )
 
 // Display is a generic interface to what a display can do
@@ -13,6 +18,7 @@ type Display interface {
 
 	// stuff used by some of the objects
 	ClearScreen()
+	Flush()
 	Close()
 	EventChan() chan event.Event
 	Resize(width, height int)
@@ -20,4 +26,13 @@ type Display interface {
 	// show various things
 	Display(p GenericData)
 	DisplayHelp()
+	DisplayInstruments(families []setup_instruments.Family, message string)
+	DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string)
+	DisplayAbout(stats *self_stats.Stats)
+	DisplayInfo(info server_info.Info)
+	DisplayHistory(series row_history.Series)
+
+	// tell the user about something which needs their attention
+	Bell()
+	DesktopNotification(message string)
 }
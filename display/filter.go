@@ -0,0 +1,59 @@
+package display
+
+import (
+	"regexp"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_filter"
+)
+
+// filteredData shows only the rows of the underlying data which match a filter
+type filteredData struct {
+	GenericData // embedded
+	re          *regexp.Regexp
+}
+
+// NewFilteredData returns the data limited to the rows matching the filter
+func NewFilteredData(data GenericData, re *regexp.Regexp) GenericData {
+	f := filteredData{GenericData: data, re: re}
+	if valuer, ok := data.(ps_table.Valuer); ok {
+		return valuesData{GenericData: f, values: func() []ps_table.RowValues { return f.values(valuer) }}
+	}
+	return f
+}
+
+// Description adds the filter to the description of the data
+func (f filteredData) Description() string {
+	return f.GenericData.Description() + " filter: " + f.re.String()
+}
+
+// RowContent returns the rows which match the filter
+func (f filteredData) RowContent() []string {
+	var rows []string
+
+	for _, row := range f.GenericData.RowContent() {
+		if row_filter.Matches(f.re, row) {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// Len returns the number of rows which match the filter
+func (f filteredData) Len() int {
+	return len(f.RowContent())
+}
+
+// values returns the values of the rows whose name matches the filter
+func (f filteredData) values(valuer ps_table.Valuer) []ps_table.RowValues {
+	var values []ps_table.RowValues
+
+	for _, row := range valuer.Values() {
+		if f.re.MatchString(row.Name) {
+			values = append(values, row)
+		}
+	}
+
+	return values
+}
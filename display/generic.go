@@ -16,6 +16,8 @@ type GenericData interface {
 	EmptyRowContent() string       // a string containing the details of an empty row
 	HaveRelativeStats() bool       // does this data type have relative statistics
 	WantRelativeStats() bool       // do we want to show relative statistics
+	SinceMark() bool               // are relative statistics shown since the mark
+	MarkCollectTime() time.Time    // time the marked data was collected
 }
 
 // GenericRow is a generic interface to a row of data collected from P_S
@@ -0,0 +1,247 @@
+package display
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+)
+
+// GrafanaDisplay notes the columns of each view shown and on Close
+// writes a Grafana dashboard for the metrics MetricsDisplay serves for
+// them: a row per view with a graph per column, the rows being labelled
+// by name and the host chosen from those seen by Prometheus.
+type GrafanaDisplay struct {
+	BaseDisplay // embedded
+	w           io.Writer
+	views       []string            // views in the order shown
+	columns     map[string][]string // columns of each view
+}
+
+// gauges are the columns which are current values rather than counters
+var gauges = map[string]bool{
+	"count":   true, // prepared statements
+	"threads": true, // threads owning them
+}
+
+// NewGrafanaDisplayTo returns a GrafanaDisplay which writes the dashboard to w
+func NewGrafanaDisplayTo(w io.Writer) *GrafanaDisplay {
+	return &GrafanaDisplay{w: w, columns: make(map[string][]string)}
+}
+
+// the parts of a dashboard's JSON model which are used
+type (
+	grafanaDashboard struct {
+		Title         string            `json:"title"`
+		UID           string            `json:"uid"`
+		Tags          []string          `json:"tags"`
+		Editable      bool              `json:"editable"`
+		SchemaVersion int               `json:"schemaVersion"`
+		Refresh       string            `json:"refresh"`
+		Time          grafanaTime       `json:"time"`
+		Templating    grafanaTemplating `json:"templating"`
+		Panels        []grafanaPanel    `json:"panels"`
+	}
+	grafanaTime struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	grafanaTemplating struct {
+		List []grafanaVariable `json:"list"`
+	}
+	grafanaVariable struct {
+		Name       string             `json:"name"`
+		Label      string             `json:"label"`
+		Type       string             `json:"type"`
+		Query      string             `json:"query"`
+		Datasource *grafanaDatasource `json:"datasource,omitempty"`
+		Refresh    int                `json:"refresh,omitempty"`
+		IncludeAll bool               `json:"includeAll"`
+		Multi      bool               `json:"multi"`
+	}
+	grafanaDatasource struct {
+		Type string `json:"type"`
+		UID  string `json:"uid"`
+	}
+	grafanaPanel struct {
+		ID          int                `json:"id"`
+		Type        string             `json:"type"`
+		Title       string             `json:"title"`
+		GridPos     grafanaGridPos     `json:"gridPos"`
+		Collapsed   bool               `json:"collapsed,omitempty"`
+		Panels      []grafanaPanel     `json:"panels,omitempty"`
+		Datasource  *grafanaDatasource `json:"datasource,omitempty"`
+		Targets     []grafanaTarget    `json:"targets,omitempty"`
+		FieldConfig *grafanaFieldCfg   `json:"fieldConfig,omitempty"`
+	}
+	grafanaGridPos struct {
+		H int `json:"h"`
+		W int `json:"w"`
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	grafanaTarget struct {
+		RefID        string `json:"refId"`
+		Expr         string `json:"expr"`
+		LegendFormat string `json:"legendFormat"`
+	}
+	grafanaFieldCfg struct {
+		Defaults grafanaDefaults `json:"defaults"`
+	}
+	grafanaDefaults struct {
+		Unit string `json:"unit"`
+	}
+)
+
+// graph returns the query and unit of the graph of a column of a view:
+// the rate of a counter, the latencies in picoseconds as seconds per
+// second, and the value of a gauge, for the ten busiest rows
+func graph(view, column string) (string, string) {
+	series := fmt.Sprintf(`ps_top_%s{host=~"$host",view="%s"}`, column, view)
+
+	switch {
+	case gauges[column]:
+		return "topk(10, " + series + ")", "short"
+	case strings.HasPrefix(column, "sum_timer_"), strings.HasSuffix(column, "_wait"), column == "sum_cpu_time":
+		return "topk(10, rate(" + series + "[$__rate_interval]) / 1e12)", "s"
+	case strings.Contains(column, "bytes"):
+		return "topk(10, rate(" + series + "[$__rate_interval]))", "Bps"
+	}
+
+	return "topk(10, rate(" + series + "[$__rate_interval]))", "ops"
+}
+
+// dashboard returns the dashboard of the columns of each view
+func dashboard(views []string, columns map[string][]string) ([]byte, error) {
+	datasource := &grafanaDatasource{Type: "prometheus", UID: "${datasource}"}
+	d := grafanaDashboard{
+		Title:         lib.MyName(),
+		UID:           lib.MyName(),
+		Tags:          []string{"mysql", "performance_schema", lib.MyName()},
+		Editable:      true,
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          grafanaTime{From: "now-1h", To: "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{Name: "host", Label: "Host", Type: "query", Query: `label_values({__name__=~"ps_top_.+"}, host)`, Datasource: datasource, Refresh: 2},
+		}},
+	}
+
+	id, y := 1, 0
+	for _, view := range views {
+		row := grafanaPanel{ID: id, Type: "row", Title: view, Collapsed: true, GridPos: grafanaGridPos{H: 1, W: 24, Y: y}}
+		id++
+		y++
+		for i, column := range columns[view] {
+			expr, unit := graph(view, column)
+			row.Panels = append(row.Panels, grafanaPanel{
+				ID:          id,
+				Type:        "timeseries",
+				Title:       column,
+				GridPos:     grafanaGridPos{H: 8, W: 12, X: 12 * (i % 2), Y: y + 8*(i/2)},
+				Datasource:  datasource,
+				Targets:     []grafanaTarget{{RefID: "A", Expr: expr, LegendFormat: "{{name}}"}},
+				FieldConfig: &grafanaFieldCfg{Defaults: grafanaDefaults{Unit: unit}},
+			})
+			id++
+		}
+		d.Panels = append(d.Panels, row)
+	}
+
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// ClearScreen does nothing for GrafanaDisplay
+func (s *GrafanaDisplay) ClearScreen() {
+}
+
+// Flush does nothing for GrafanaDisplay
+func (s *GrafanaDisplay) Flush() {
+}
+
+// Display notes the columns of the view shown, if it provides row values
+func (s *GrafanaDisplay) Display(p GenericData) {
+	valuer, ok := p.(ps_table.Valuer)
+	if !ok {
+		return
+	}
+	view := s.ctx.ViewName()
+
+	seen := make(map[string]bool)
+	for _, column := range s.columns[view] {
+		seen[column] = true
+	}
+	var columns []string
+	for _, row := range valuer.Values() {
+		for column := range row.Values {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
+	if _, found := s.columns[view]; !found {
+		s.views = append(s.views, view)
+	}
+	s.columns[view] = append(s.columns[view], columns...)
+	sort.Strings(s.columns[view])
+}
+
+// DisplayHelp does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayHelp() {
+}
+
+// DisplayInstruments does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+}
+
+// DisplayConsumers does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+}
+
+// DisplayAbout does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayAbout(stats *self_stats.Stats) {
+}
+
+// DisplayInfo does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayInfo(info server_info.Info) {
+}
+
+// DisplayHistory does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) DisplayHistory(series row_history.Series) {
+}
+
+// Close writes the dashboard of the views shown
+func (s *GrafanaDisplay) Close() {
+	page, err := dashboard(s.views, s.columns)
+	if err != nil {
+		log.Fatal("Unable to generate the Grafana dashboard: ", err)
+	}
+	if _, err := s.w.Write(append(page, '\n')); err != nil {
+		log.Fatal("Unable to write the Grafana dashboard: ", err)
+	}
+}
+
+// Resize does nothing on a GrafanaDisplay
+func (s *GrafanaDisplay) Resize(width, height int) {
+}
+
+// EventChan returns a channel which never has events
+func (s *GrafanaDisplay) EventChan() chan event.Event {
+	return make(chan event.Event)
+}
@@ -0,0 +1,73 @@
+package display
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/row_history"
+)
+
+// historyHeight is the number of lines used to plot the history of a row
+const historyHeight = 8
+
+// formatMetric formats a value of a row like the views do
+func formatMetric(metric string, value uint64) string {
+	if strings.HasPrefix(metric, "sum_timer") {
+		return lib.FormatTime(value)
+	}
+	return lib.FormatAmount(value)
+}
+
+// historyLines returns the lines showing the change of the metric of a
+// row in each interval as a bar chart, oldest on the left, followed by
+// the smallest, largest and last change
+func historyLines(series row_history.Series) []string {
+	lines := []string{"History of " + series.Name}
+	if len(series.Changes) == 0 {
+		return append(lines, "No history collected yet, it builds up with each interval")
+	}
+	lines = append(lines, fmt.Sprintf("%s per interval, last %d interval(s) over %s",
+		series.Metric, len(series.Changes), strings.TrimSpace(formatDuration(series.Period))))
+
+	minimum, maximum := series.Changes[0], series.Changes[0]
+	for _, change := range series.Changes {
+		if change < minimum {
+			minimum = change
+		}
+		if change > maximum {
+			maximum = change
+		}
+	}
+
+	// height of each bar, rounding up so any change is seen
+	heights := make([]int, len(series.Changes))
+	if maximum > 0 {
+		for i, change := range series.Changes {
+			heights[i] = int((change*historyHeight + maximum - 1) / maximum)
+		}
+	}
+
+	top := strings.TrimSpace(formatMetric(series.Metric, maximum))
+	for level := historyHeight; level > 0; level-- {
+		label := ""
+		if level == historyHeight {
+			label = top
+		}
+		bars := make([]byte, len(heights))
+		for i := range heights {
+			bars[i] = ' '
+			if heights[i] >= level {
+				bars[i] = '#'
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%*s |%s", len(top), label, bars))
+	}
+	lines = append(lines, fmt.Sprintf("%*s +%s", len(top), "0", strings.Repeat("-", len(heights))))
+	lines = append(lines, fmt.Sprintf("min %s  max %s  last %s",
+		strings.TrimSpace(formatMetric(series.Metric, minimum)),
+		top,
+		strings.TrimSpace(formatMetric(series.Metric, series.Changes[len(series.Changes)-1]))))
+
+	return lines
+}
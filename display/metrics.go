@@ -0,0 +1,215 @@
+package display
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+)
+
+// MetricsDisplay serves the row values of the last view shown over HTTP
+// in the Prometheus text format, one metric per column named
+// ps_top_<column> with the host, --title, view and row name as labels. The
+// health of each collector is served as JSON on /health.
+type MetricsDisplay struct {
+	BaseDisplay // embedded
+	listener    net.Listener
+	mu          sync.Mutex
+	page        []byte // the metrics served
+	health      []byte // the health of the collectors served
+	healthy     bool   // no collector is failing
+}
+
+// NewMetricsDisplay returns a MetricsDisplay serving /metrics on the address
+func NewMetricsDisplay(address string) (*MetricsDisplay, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := &MetricsDisplay{listener: listener}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serve)
+	mux.HandleFunc("/health", s.serveHealth)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !strings.Contains(err.Error(), "use of closed network connection") {
+			log.Fatal("Unable to serve metrics: ", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// serve writes the metrics of the last view shown
+func (s *MetricsDisplay) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	page := s.page
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(page)
+}
+
+// serveHealth writes the health of each collector, with a status of 503
+// if any of them is failing
+func (s *MetricsDisplay) serveHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	page, healthy := s.health, s.healthy
+	s.mu.Unlock()
+
+	if page == nil {
+		page, healthy = []byte("[]\n"), true // nothing collected yet
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(page)
+}
+
+// collectorHealth is how a collector is doing, as served on /health
+type collectorHealth struct {
+	Collector   string  `json:"collector"`
+	Status      string  `json:"status"`
+	Rows        int     `json:"rows"`
+	LastSeconds float64 `json:"last_seconds"`
+	Collections uint64  `json:"collections"`
+	Errors      uint64  `json:"errors"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+// health returns the health of the collectors as JSON and whether none
+// of them is failing
+func health(collectors []self_stats.Collector) ([]byte, bool) {
+	healthy := true
+	list := make([]collectorHealth, 0, len(collectors))
+
+	for _, c := range collectors {
+		healthy = healthy && !c.Failing
+		list = append(list, collectorHealth{
+			Collector:   c.Name,
+			Status:      c.Status(),
+			Rows:        c.Rows,
+			LastSeconds: c.Last.Seconds(),
+			Collections: c.Collections,
+			Errors:      c.Errors,
+			LastError:   c.LastError,
+		})
+	}
+	page, _ := json.MarshalIndent(list, "", "  ")
+
+	return append(page, '\n'), healthy
+}
+
+// labelValue escapes a label value for the text format
+func labelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// metrics returns the text format of the values of the rows. An empty
+// title is the same as no title label.
+func metrics(host, title, view string, rows []ps_table.RowValues) []byte {
+	byColumn := make(map[string][]string)
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	for _, row := range rows {
+		for column, value := range row.Values {
+			byColumn[column] = append(byColumn[column], fmt.Sprintf(`ps_top_%s{host="%s",title="%s",view="%s",name="%s"} %d`,
+				column, labelValue(host), labelValue(title), labelValue(view), labelValue(row.Name), value))
+		}
+	}
+	columns := make([]string, 0, len(byColumn))
+	for column := range byColumn {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	var b bytes.Buffer
+	for _, column := range columns {
+		fmt.Fprintf(&b, "# TYPE ps_top_%s untyped\n", column)
+		for _, line := range byColumn[column] {
+			fmt.Fprintln(&b, line)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// ClearScreen does nothing for MetricsDisplay
+func (s *MetricsDisplay) ClearScreen() {
+}
+
+// Flush does nothing for MetricsDisplay
+func (s *MetricsDisplay) Flush() {
+}
+
+// Display keeps the current values of the rows and the health of the
+// collectors to be served
+func (s *MetricsDisplay) Display(p GenericData) {
+	health, healthy := health(s.ctx.Collectors())
+
+	s.mu.Lock()
+	s.health, s.healthy = health, healthy
+	s.mu.Unlock()
+
+	valuer, ok := p.(ps_table.Valuer)
+	if !ok {
+		return
+	}
+	page := metrics(s.ctx.Hostname(), s.ctx.Title(), s.ctx.ViewName(), valuer.Values())
+
+	s.mu.Lock()
+	s.page = page
+	s.mu.Unlock()
+}
+
+// DisplayHelp does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayHelp() {
+}
+
+// DisplayInstruments does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+}
+
+// DisplayConsumers does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+}
+
+// DisplayAbout does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayAbout(stats *self_stats.Stats) {
+}
+
+// DisplayInfo does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayInfo(info server_info.Info) {
+}
+
+// DisplayHistory does nothing on a MetricsDisplay
+func (s *MetricsDisplay) DisplayHistory(series row_history.Series) {
+}
+
+// Close stops serving the metrics
+func (s *MetricsDisplay) Close() {
+	s.listener.Close()
+}
+
+// Resize does nothing on a MetricsDisplay
+func (s *MetricsDisplay) Resize(width, height int) {
+}
+
+// EventChan returns a channel which never has events
+func (s *MetricsDisplay) EventChan() chan event.Event {
+	return make(chan event.Event)
+}
@@ -0,0 +1,113 @@
+package display
+
+import (
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+)
+
+// MultiDisplay shows the data on a main display, which the user
+// interacts with, and also passes it to other sinks such as a change
+// journal or a metrics endpoint so they share the same collections.
+// The sinks are only given the views which provide row values.
+type MultiDisplay struct {
+	main  Display
+	sinks []Display
+}
+
+// NewMultiDisplay returns a MultiDisplay for the main display and sinks
+func NewMultiDisplay(main Display, sinks ...Display) *MultiDisplay {
+	return &MultiDisplay{main: main, sinks: sinks}
+}
+
+// SetContext sets the context of the main display and the sinks
+func (m *MultiDisplay) SetContext(ctx *context.Context) {
+	m.main.SetContext(ctx)
+	for _, sink := range m.sinks {
+		sink.SetContext(ctx)
+	}
+}
+
+// ClearScreen clears the main display
+func (m *MultiDisplay) ClearScreen() {
+	m.main.ClearScreen()
+}
+
+// Flush shows what has been drawn on the main display
+func (m *MultiDisplay) Flush() {
+	m.main.Flush()
+}
+
+// Close closes the main display and the sinks
+func (m *MultiDisplay) Close() {
+	m.main.Close()
+	for _, sink := range m.sinks {
+		sink.Close()
+	}
+}
+
+// EventChan returns the events of the main display
+func (m *MultiDisplay) EventChan() chan event.Event {
+	return m.main.EventChan()
+}
+
+// Resize resizes the main display
+func (m *MultiDisplay) Resize(width, height int) {
+	m.main.Resize(width, height)
+}
+
+// Display shows the data on the main display and passes it to the sinks
+func (m *MultiDisplay) Display(p GenericData) {
+	m.main.Display(p)
+	if _, ok := p.(ps_table.Valuer); !ok {
+		return
+	}
+	for _, sink := range m.sinks {
+		sink.Display(p)
+	}
+}
+
+// DisplayHelp shows the help on the main display
+func (m *MultiDisplay) DisplayHelp() {
+	m.main.DisplayHelp()
+}
+
+// DisplayInstruments shows the instruments on the main display
+func (m *MultiDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+	m.main.DisplayInstruments(families, message)
+}
+
+// DisplayConsumers shows the consumers on the main display
+func (m *MultiDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+	m.main.DisplayConsumers(view, consumers, message)
+}
+
+// DisplayAbout shows how ps-top is doing on the main display
+func (m *MultiDisplay) DisplayAbout(stats *self_stats.Stats) {
+	m.main.DisplayAbout(stats)
+}
+
+// DisplayInfo shows the server's configuration on the main display
+func (m *MultiDisplay) DisplayInfo(info server_info.Info) {
+	m.main.DisplayInfo(info)
+}
+
+// Bell rings the bell of the main display
+func (m *MultiDisplay) Bell() {
+	m.main.Bell()
+}
+
+// DesktopNotification sends the notification from the main display
+func (m *MultiDisplay) DesktopNotification(message string) {
+	m.main.DesktopNotification(message)
+}
+
+// DisplayHistory shows the history of a row on the main display
+func (m *MultiDisplay) DisplayHistory(series row_history.Series) {
+	m.main.DisplayHistory(series)
+}
@@ -0,0 +1,136 @@
+package display
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// percentData shows each value of the rows of the underlying data as a
+// percentage of the total of its column
+type percentData struct {
+	GenericData // embedded
+	resulter    ps_table.Resulter
+}
+
+// NewPercentData returns the data with its values shown as percentages
+// of the column totals
+func NewPercentData(data GenericData, resulter ps_table.Resulter) GenericData {
+	p := percentData{GenericData: data, resulter: resulter}
+	return withValues(p, data)
+}
+
+// valueNames returns the names of the values in the rows, sorted
+func valueNames(results []ps_table.RowValues) []string {
+	var names []string
+
+	if len(results) > 0 {
+		for name := range results[0].Values {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// nameColumn returns the last '|' separated column of the row, which holds the name
+func nameColumn(row string) string {
+	if i := strings.LastIndex(row, "|"); i >= 0 {
+		return row[i:]
+	}
+	return ""
+}
+
+// percentLine returns the columns, each as wide as its name, followed by the name
+func percentLine(names []string, columns []string, name string) string {
+	var s string
+
+	for i := range names {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%*s", len(names[i]), columns[i])
+	}
+
+	return s + name
+}
+
+// sumValues returns the sum of each value of the rows
+func sumValues(results []ps_table.RowValues) map[string]uint64 {
+	sum := make(map[string]uint64)
+
+	for _, result := range results {
+		for name, value := range result.Values {
+			sum[name] += value
+		}
+	}
+
+	return sum
+}
+
+// percentages returns the values as percentages of the totals
+func percentages(names []string, values, totals map[string]uint64) []string {
+	pcts := make([]string, 0, len(names))
+
+	for _, name := range names {
+		pcts = append(pcts, lib.FormatPct(lib.MyDivide(values[name], totals[name])))
+	}
+
+	return pcts
+}
+
+// Description adds that the values are percentages of the totals
+func (p percentData) Description() string {
+	return p.GenericData.Description() + " (% of total)"
+}
+
+// Headings returns the names of the values followed by the name heading
+func (p percentData) Headings() string {
+	names := valueNames(p.resulter.Results())
+
+	return percentLine(names, names, nameColumn(p.GenericData.Headings()))
+}
+
+// RowContent returns the values of each row as percentages of the totals
+func (p percentData) RowContent() []string {
+	results := p.resulter.Results()
+	names := valueNames(results)
+	sum := sumValues(results)
+	rows := p.GenericData.RowContent()
+
+	content := make([]string, 0, len(results))
+	for i := range results {
+		rowName := "|" + results[i].Name
+		if i < len(rows) {
+			rowName = nameColumn(rows[i])
+		}
+		content = append(content, percentLine(names, percentages(names, results[i].Values, sum), rowName))
+	}
+
+	return content
+}
+
+// TotalRowContent returns the percentages of the totals
+func (p percentData) TotalRowContent() string {
+	results := p.resulter.Results()
+	names := valueNames(results)
+	sum := sumValues(results)
+
+	return percentLine(names, percentages(names, sum, sum), nameColumn(p.GenericData.TotalRowContent()))
+}
+
+// EmptyRowContent returns an empty row
+func (p percentData) EmptyRowContent() string {
+	names := valueNames(p.resulter.Results())
+
+	return percentLine(names, make([]string, len(names)), "")
+}
+
+// Len returns the number of rows shown
+func (p percentData) Len() int {
+	return len(p.resulter.Results())
+}
@@ -0,0 +1,235 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
+	"github.com/sjmudd/ps-top/version"
+)
+
+// PlainDisplay writes the views as plain text lines without moving the
+// cursor, for braille displays and screen readers. A view is written
+// in full when it is first shown, after that only the rows which have
+// changed are written. Keys are read from stdin, followed by return.
+type PlainDisplay struct {
+	BaseDisplay // embedded
+	limit       int
+	out         io.Writer
+	description string          // description of the view last written
+	headings    string          // headings of the view last written
+	rows        map[string]bool // rows last written
+	totals      string          // totals last written
+	page        string          // help, instruments or about page last written
+}
+
+// NewPlainDisplay returns a PlainDisplay showing at most limit rows (0 for all)
+func NewPlainDisplay(limit int) *PlainDisplay {
+	s := new(PlainDisplay)
+
+	s.limit = limit
+	s.out = os.Stdout
+
+	return s
+}
+
+// ClearScreen forgets what has been written so the next view is written in full
+func (s *PlainDisplay) ClearScreen() {
+	s.description = ""
+	s.headings = ""
+	s.rows = nil
+	s.totals = ""
+	s.page = ""
+}
+
+// Flush does nothing for PlainDisplay as the lines are written as they change
+func (s *PlainDisplay) Flush() {
+}
+
+// newPage returns true if the page is not the one last written, in which
+// case it is recorded as written so the next view is written in full
+func (s *PlainDisplay) newPage(page string) bool {
+	if page == s.page {
+		return false
+	}
+	s.ClearScreen()
+	s.page = page
+
+	return true
+}
+
+// Display writes the view in full if it has changed, otherwise a line
+// with the time and the number of rows changed followed by those rows,
+// prefixed by their position. Nothing is written if there's no change.
+func (s *PlainDisplay) Display(p GenericData) {
+	rowContent := p.RowContent()
+	if s.limit > 0 && s.limit < len(rowContent) {
+		rowContent = rowContent[:s.limit]
+	}
+	total := p.TotalRowContent() + s.UptimeAverages(p)
+	full := s.rows == nil || p.Description() != s.description || p.Headings() != s.headings
+
+	rows := make(map[string]bool, len(rowContent))
+	var changed []string
+	for k := range rowContent {
+		if rowContent[k] == p.EmptyRowContent() {
+			continue
+		}
+		rows[rowContent[k]] = true
+		if full {
+			changed = append(changed, rowContent[k])
+		} else if !s.rows[rowContent[k]] {
+			changed = append(changed, fmt.Sprintf("%d: %s", k+1, rowContent[k]))
+		}
+	}
+
+	if full {
+		fmt.Fprintln(s.out, s.HeadingLine(p))
+		for _, line := range s.statusPanel(0) {
+			fmt.Fprintln(s.out, line)
+		}
+		fmt.Fprintln(s.out, s.tabBar()+s.viewNumberPrefix()+p.Description())
+		fmt.Fprintln(s.out, p.Headings())
+	} else if len(changed) > 0 || total != s.totals {
+		fmt.Fprintf(s.out, "%s %d row(s) changed\n", nowHHMMSS(), len(changed))
+		for _, line := range s.statusPanel(0) {
+			fmt.Fprintln(s.out, line)
+		}
+	}
+	for i := range changed {
+		fmt.Fprintln(s.out, changed[i])
+	}
+	if full || total != s.totals {
+		fmt.Fprintln(s.out, total)
+	}
+
+	s.description = p.Description()
+	s.headings = p.Headings()
+	s.rows = rows
+	s.totals = total
+	s.page = ""
+}
+
+// DisplayHelp writes the keys which may be used, once
+func (s *PlainDisplay) DisplayHelp() {
+	if !s.newPage("help") {
+		return
+	}
+	fmt.Fprintln(s.out, lib.MyName()+" version "+version.Version()+" "+lib.Copyright())
+	fmt.Fprintln(s.out, "Keys, followed by return:")
+	for _, line := range helpKeys() {
+		fmt.Fprintln(s.out, line)
+	}
+	fmt.Fprintln(s.out, "Press h to return to main screen")
+}
+
+// DisplayInstruments writes the instrument families with their enabled
+// and timed status, and a message (if any) from the last change
+func (s *PlainDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+	if !s.newPage("instruments: " + message) {
+		return
+	}
+	fmt.Fprintln(s.out, "Instrument families (setup_instruments)")
+	heading, rows := instrumentLines(families)
+	fmt.Fprintln(s.out, heading)
+	for i := range rows {
+		fmt.Fprintln(s.out, rows[i])
+	}
+	if message != "" {
+		fmt.Fprintln(s.out, message)
+	}
+	fmt.Fprintf(s.out, "1-%d - toggle a family, R - restore the settings left by a killed run, press I to return to main screen\n", len(families))
+}
+
+// DisplayConsumers writes the setup_consumers the view needs with
+// whether they are enabled, and a message (if any) from the last change
+func (s *PlainDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+	if !s.newPage("consumers: " + view + " " + fmt.Sprint(consumers) + message) {
+		return
+	}
+	fmt.Fprintln(s.out, "Consumers (setup_consumers) needed by "+view)
+	heading, rows := consumerLines(consumers)
+	fmt.Fprintln(s.out, heading)
+	for i := range rows {
+		fmt.Fprintln(s.out, rows[i])
+	}
+	if message != "" {
+		fmt.Fprintln(s.out, message)
+	}
+	fmt.Fprintln(s.out, "E - enable the disabled consumers, press C to return to main screen")
+}
+
+// DisplayAbout writes how long each view takes to collect and the
+// resources used by the program itself
+func (s *PlainDisplay) DisplayAbout(stats *self_stats.Stats) {
+	if !s.newPage("about") {
+		return
+	}
+	lines, heading, collectors := aboutLines(stats)
+	for i := range lines {
+		fmt.Fprintln(s.out, lines[i])
+	}
+	fmt.Fprintln(s.out, heading)
+	for i := range collectors {
+		fmt.Fprintln(s.out, collectors[i])
+	}
+	fmt.Fprintln(s.out, "Press A to return to main screen")
+}
+
+// DisplayInfo writes the server's configuration
+func (s *PlainDisplay) DisplayInfo(info server_info.Info) {
+	if !s.newPage("info " + lib.InTimezone(info.Collected).String()) {
+		return
+	}
+	for _, line := range infoLines(info) {
+		fmt.Fprintln(s.out, line)
+	}
+	fmt.Fprintln(s.out, "Press i to return to main screen")
+}
+
+// DisplayHistory writes the history of a row
+func (s *PlainDisplay) DisplayHistory(series row_history.Series) {
+	for _, line := range historyLines(series) {
+		fmt.Fprintln(s.out, line)
+	}
+}
+
+// Close does nothing on a PlainDisplay
+func (s *PlainDisplay) Close() {
+}
+
+// Resize does nothing on a PlainDisplay
+func (s *PlainDisplay) Resize(width, height int) {
+}
+
+// EventChan returns a channel of the events for the keys typed on
+// stdin. The keys are those used on the screen, each line may have
+// several of them.
+func (s *PlainDisplay) EventChan() chan event.Event {
+	e := make(chan event.Event)
+
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			for _, ch := range line {
+				if ev := KeyEvent(ch); ev.Type != event.EventUnknown {
+					e <- ev
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return e
+}
@@ -0,0 +1,35 @@
+package display
+
+// priorityData shows the rows of the underlying data in the order of
+// their priority
+type priorityData struct {
+	GenericData       // embedded
+	order       []int // indexes of the rows of the underlying data in the order shown
+}
+
+// NewPriorityData returns the data with the rows in the given order
+func NewPriorityData(data GenericData, order []int) GenericData {
+	p := priorityData{GenericData: data, order: order}
+	return withValues(p, data)
+}
+
+// Description adds the order of the rows
+func (p priorityData) Description() string {
+	return p.GenericData.Description() + " (by priority)"
+}
+
+// RowContent returns the rows in the order of their priority. The rows
+// are returned as they are if the order doesn't match them.
+func (p priorityData) RowContent() []string {
+	rows := p.GenericData.RowContent()
+	if len(rows) != len(p.order) {
+		return rows
+	}
+
+	ordered := make([]string, 0, len(rows))
+	for _, i := range p.order {
+		ordered = append(ordered, rows[i])
+	}
+
+	return ordered
+}
@@ -1,11 +1,21 @@
 package display
 
 import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/nsf/termbox-go"
 
 	"github.com/sjmudd/ps-top/event"
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/row_history"
 	"github.com/sjmudd/ps-top/screen"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
 	"github.com/sjmudd/ps-top/version"
 )
 
@@ -14,6 +24,57 @@ type ScreenDisplay struct {
 	BaseDisplay // embedded
 	screen      *screen.TermboxScreen
 	termboxChan chan termbox.Event
+	pending     *termbox.Event // event read while waiting for resizes to settle
+	title       string         // terminal title last set
+	terminal    bool           // is stdout the terminal? (escape sequences are only written to it if so)
+	layout      layout         // where the view was last laid out
+}
+
+// resizeSettle is how long to wait for more resize events, as dragging a
+// window sends a storm of them, and resizeMaxWait is the longest a
+// storm holds back the relayout so the screen still follows the drag
+const (
+	resizeSettle  = 50 * time.Millisecond
+	resizeMaxWait = 250 * time.Millisecond
+)
+
+// layout is where the parts of a view go on a screen of a given height
+type layout struct {
+	top   int // line of the view's description, below the status panel
+	rows  int // rows of data which fit between the headings and totals
+	first int // first row shown, scrolled to keep the row selected in view
+	total int // rows of data the view has
+}
+
+// newLayout lays out a view with total rows on a screen of the given
+// height, scrolling from first only as far as needed to show the row
+// selected (counting from 1). With no row selected the rows are shown
+// from the top.
+func newLayout(height, first, selected, total int) layout {
+	l := layout{top: 1 + statusPanelLines, total: total}
+	l.rows = height - 3 - l.top
+	if l.rows < 0 {
+		l.rows = 0
+	}
+
+	if selected == 0 {
+		first = 0
+	}
+	if selected > first+l.rows {
+		first = selected - l.rows
+	}
+	if selected > 0 && selected <= first {
+		first = selected - 1
+	}
+	if first > total-l.rows {
+		first = total - l.rows
+	}
+	if first < 0 {
+		first = 0
+	}
+	l.first = first
+
+	return l
 }
 
 // return a setup StdoutDisplay
@@ -24,44 +85,116 @@ func NewScreenDisplay(limit int, onlyTotals bool) *ScreenDisplay {
 	s.screen = new(screen.TermboxScreen)
 	s.screen.Initialise()
 	s.termboxChan = s.screen.TermBoxChan()
+	if info, err := os.Stdout.Stat(); err == nil {
+		s.terminal = info.Mode()&os.ModeCharDevice != 0
+	}
 
 	return s
 }
 
 // Display displays the wanted view to the screen
 func (s *ScreenDisplay) Display(t GenericData) {
-	s.screen.PrintAt(0, 0, s.HeadingLine(t.HaveRelativeStats(), t.WantRelativeStats(), t.InitialCollectTime(), t.LastCollectTime()))
-	s.screen.PrintAt(0, 1, t.Description())
-	s.screen.BoldPrintAt(0, 2, t.Headings())
-
-	maxRows := s.screen.Height() - 4
-	lastRow := s.screen.Height() - 1
+	s.screen.PrintAt(0, 0, s.HeadingLine(t))
 	rowContent := t.RowContent()
+	width, height := s.screen.Size()
+	s.layout = newLayout(height, s.layout.first, s.ctx.SelectedRow(), len(rowContent))
+	top := s.layout.top
 
-	for k := 0; k < maxRows; k++ {
-		y := 3 + k
-		if k <= len(rowContent)-1 && k < maxRows {
-			// print out rows
-			s.screen.PrintAt(0, y, rowContent[k])
-			s.screen.ClearLine(len(rowContent[k]), y)
-		} else {
-			// print out empty rows
-			if y < lastRow {
-				s.screen.PrintAt(0, y, t.EmptyRowContent())
+	panel := s.statusPanel(width)
+	for i := range panel {
+		s.screen.PrintAt(0, 1+i, panel[i])
+		s.screen.ClearLine(len(panel[i]), 1+i)
+	}
+	description := s.tabBar() + s.viewNumberPrefix() + t.Description()
+	s.screen.PrintAt(0, top, description)
+	s.screen.ClearLine(len(description), top)
+	headings := t.Headings()
+	s.screen.BoldPrintAt(0, top+1, headings)
+	s.screen.ClearLine(len(headings), top+1)
+
+	lastRow := height - 1
+	for k := 0; k < s.layout.rows; k++ {
+		y := top + 2 + k
+		row := s.layout.first + k
+		if row < len(rowContent) {
+			// print out rows, highlighting the one selected
+			if row+1 == s.ctx.SelectedRow() {
+				s.screen.ReversePrintAt(0, y, rowContent[row])
+			} else {
+				s.screen.PrintAt(0, y, rowContent[row])
 			}
+			s.screen.ClearLine(len(rowContent[row]), y)
+		} else if y < lastRow {
+			// print out empty rows
+			empty := t.EmptyRowContent()
+			s.screen.PrintAt(0, y, empty)
+			s.screen.ClearLine(len(empty), y)
 		}
 	}
 
 	// print out the totals at the bottom
-	total := t.TotalRowContent()
+	total := t.TotalRowContent() + s.UptimeAverages(t)
 	s.screen.BoldPrintAt(0, lastRow, total)
 	s.screen.ClearLine(len(total), lastRow)
 }
 
-// ClearScreen clears the (internal) screen and flushes out the result to the real screen
+// terminalTitle returns the title for the terminal window or tab: the
+// host and view, the --title if given and a marker if a threshold is
+// breached
+func (s *ScreenDisplay) terminalTitle() string {
+	title := lib.MyName() + " " + s.ctx.Hostname() + " " + s.ctx.ViewName()
+	if s.ctx.Title() != "" {
+		title += " " + s.ctx.Title()
+	}
+	if s.ctx.Alert() {
+		title = "(!) " + title
+	}
+
+	return title
+}
+
+// setTitle sets the terminal title (OSC 0) if it has changed. The
+// original title is saved on the terminal's title stack the first time
+// and restored by Close.
+func (s *ScreenDisplay) setTitle() {
+	title := s.terminalTitle()
+	if title == s.title || !s.terminal {
+		return
+	}
+	if s.title == "" {
+		fmt.Fprint(os.Stdout, "\x1b[22;0t")
+	}
+	fmt.Fprintf(os.Stdout, "\x1b]0;%s\x07", title)
+	s.title = title
+}
+
+// Bell rings the terminal bell
+func (s *ScreenDisplay) Bell() {
+	if s.terminal {
+		fmt.Fprint(os.Stdout, "\a")
+	}
+}
+
+// DesktopNotification sends an OSC 9 escape sequence which many
+// terminals show as a desktop notification
+func (s *ScreenDisplay) DesktopNotification(message string) {
+	if s.terminal {
+		fmt.Fprintf(os.Stdout, "\x1b]9;%s: %s\x07", lib.MyName(), message)
+	}
+}
+
+// ClearScreen clears the (internal) screen. The real screen is only
+// changed by Flush, once the next screen has been drawn, so it never
+// shows the screen cleared.
 func (s *ScreenDisplay) ClearScreen() {
 	s.screen.Clear()
+}
+
+// Flush shows what has been drawn since the last flush on the real
+// screen, writing only what has changed, and updates the terminal title
+func (s *ScreenDisplay) Flush() {
 	s.screen.Flush()
+	s.setTitle()
 }
 
 // DisplayHelp displays a help page on the screen
@@ -72,62 +205,388 @@ func (s *ScreenDisplay) DisplayHelp() {
 	s.screen.PrintAt(0, 3, "performance_schema schema. Ideas based on mysql-sys.")
 
 	s.screen.PrintAt(0, 5, "Keys:")
-	s.screen.PrintAt(0, 6, "- - reduce the poll interval by 1 second (minimum 1 second)")
-	s.screen.PrintAt(0, 7, "+ - increase the poll interval by 1 second")
-	s.screen.PrintAt(0, 8, "h/? - this help screen")
-	s.screen.PrintAt(0, 9, "q - quit")
-	s.screen.PrintAt(0, 10, "s - sort differently (where enabled) - sorts on a different column")
-	s.screen.PrintAt(0, 11, "t - toggle between showing time since resetting statistics or since P_S data was collected")
-	s.screen.PrintAt(0, 12, "z - reset statistics")
-	s.screen.PrintAt(0, 13, "<tab> or <right arrow> - change display modes between: latency, ops, file I/O, lock and user modes")
-	s.screen.PrintAt(0, 14, "<left arrow> - change display modes to the previous screen (see above)")
-	s.screen.PrintAt(0, 16, "Press h to return to main screen")
-}
-
-// Resize records the new size of the screen and resizes it
+	for i, line := range helpKeys() {
+		s.screen.PrintAt(0, 6+i, line)
+	}
+	s.screen.PrintAt(0, 7+len(helpKeys()), "Press h to return to main screen")
+}
+
+// helpKeys returns the lines describing the keys which may be used
+func helpKeys() []string {
+	return []string{
+		"- - reduce the poll interval by 1 second (minimum 1 second)",
+		"+ - increase the poll interval by 1 second",
+		"a - toggle between showing statement efficiency by statement or latency by table",
+		"b - toggle showing the average row length and file I/O bytes per row read and changed in the table I/O views",
+		"e - toggle between truncated and full statements in the user view",
+		"f - follow the connection of the first statement in the user view, or stop following it",
+		"h/? - this help screen, or h shows the history of the row selected (see below)",
+		"k - change the table I/O views between showing all tables, only tables or only temporary tables",
+		"l - toggle between showing users or pages of connections (PgUp/PgDn) in the user view",
+		"n - toggle between showing latencies in whole microseconds and amounts without a suffix or in a human readable form",
+		"m - mark the current statistics to compare with, M - toggle between showing them since the mark or the reset",
+		"o - toggle between showing the table I/O operations as percentages or by their latency",
+		"p - toggle between showing partitioned tables by table or by partition",
+		"P - toggle ordering the rows by priority: a weighted score of their share of the latency and ops and how fast they are growing",
+		"% - toggle between showing the values or their percentages of the column totals",
+		"q - quit",
+		"s - sort differently (where enabled) - sorts on a different column",
+		"t - toggle between showing time since resetting statistics or since P_S data was collected",
+		"u - toggle between showing mutex latency globally or by account (user@host)",
+		"w - toggle the locks view between the lock latency by table and the connections waiting for a lock now",
+		"z - reset statistics",
+		"Z - toggle between hiding the rows without activity or showing all the rows known",
+		"<tab> or <right arrow> - change display modes between: latency, ops, file I/O, lock and user modes",
+		"T - open or close a tab for the view, <tab> then changes between the tabs open",
+		"<left arrow> - change display modes to the previous screen (see above)",
+		"<up arrow>/<down arrow> - select a row, h then plots how it changed over the last intervals",
+		"> / < - expand or collapse the wait event class of the row selected in the wait events view",
+		"1-9 - change to the view with the number shown in the header",
+		"I - show the instruments screen to enable or disable instrument families (R restores those left by a killed run)",
+		"C - show the consumers (setup_consumers) the view needs, E enables those which are disabled",
+		"i - show the server's configuration: buffer pool, redo log, sync settings and replication role",
+		"A - show how long each view takes to collect and the resources " + lib.MyName() + " uses",
+	}
+}
+
+// DisplayHistory shows the history of a row in a box over the middle
+// of the view
+func (s *ScreenDisplay) DisplayHistory(series row_history.Series) {
+	lines := historyLines(series)
+	lines = append(lines, "", "Press h to close")
+
+	width := 0
+	for i := range lines {
+		if len(lines[i]) > width {
+			width = len(lines[i])
+		}
+	}
+	screenWidth, screenHeight := s.screen.Size()
+	x := (screenWidth - width - 4) / 2
+	y := (screenHeight - len(lines) - 2) / 2
+	if x < 0 {
+		x = 0
+	}
+	if y < 0 {
+		y = 0
+	}
+
+	border := "+" + strings.Repeat("-", width+2) + "+"
+	s.screen.BoldPrintAt(x, y, border)
+	for i := range lines {
+		s.screen.PrintAt(x, y+1+i, fmt.Sprintf("| %-*s |", width, lines[i]))
+	}
+	s.screen.BoldPrintAt(x, y+1+len(lines), border)
+}
+
+// DisplayInstruments displays the instrument families with their
+// enabled and timed status, and a message (if any) from the last change
+func (s *ScreenDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+	s.screen.PrintAt(0, 0, lib.MyName()+" version "+version.Version()+" "+lib.Copyright())
+
+	s.screen.PrintAt(0, 2, "Instrument families (setup_instruments)")
+	heading, rows := instrumentLines(families)
+	s.screen.BoldPrintAt(0, 4, heading)
+	for i := range rows {
+		s.screen.PrintAt(0, 5+i, rows[i])
+	}
+
+	y := 6 + len(families)
+	s.screen.PrintAt(0, y, fmt.Sprintf("1-%d - toggle a family: disable it if fully enabled, otherwise enable and time it", len(families)))
+	s.screen.PrintAt(0, y+1, "Changes are restored when "+lib.MyName()+" exits.")
+	s.screen.PrintAt(0, y+2, "R - restore the settings left changed by an earlier run which was killed")
+	s.screen.PrintAt(0, y+3, message)
+	s.screen.ClearLine(len(message), y+3)
+	s.screen.PrintAt(0, y+5, "Press I to return to main screen")
+}
+
+// instrumentLines returns the heading and a line for each instrument family
+func instrumentLines(families []setup_instruments.Family) (string, []string) {
+	heading := fmt.Sprintf("%-3s %-16s %11s %8s %8s", "Key", "Family", "Instruments", "Enabled", "Timed")
+	rows := make([]string, 0, len(families))
+	for i := range families {
+		rows = append(rows, fmt.Sprintf("%-3d %-16s %11d %8d %8d",
+			i+1,
+			families[i].Name,
+			families[i].Instruments,
+			families[i].Enabled,
+			families[i].Timed))
+	}
+
+	return heading, rows
+}
+
+// DisplayConsumers displays the setup_consumers the view needs with
+// whether they are enabled, and a message (if any) from the last change
+func (s *ScreenDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+	s.screen.PrintAt(0, 0, lib.MyName()+" version "+version.Version()+" "+lib.Copyright())
+
+	s.screen.PrintAt(0, 2, "Consumers (setup_consumers) needed by "+view)
+	s.screen.ClearLine(len("Consumers (setup_consumers) needed by "+view), 2)
+	heading, rows := consumerLines(consumers)
+	s.screen.BoldPrintAt(0, 4, heading)
+	for i := range rows {
+		s.screen.PrintAt(0, 5+i, rows[i])
+		s.screen.ClearLine(len(rows[i]), 5+i)
+	}
+
+	y := 6 + len(rows)
+	s.screen.PrintAt(0, y, "E - enable the consumers which are disabled. They are disabled again when "+lib.MyName()+" exits.")
+	s.screen.PrintAt(0, y+1, "<tab> or <arrow keys> - show the consumers needed by another view")
+	s.screen.PrintAt(0, y+2, message)
+	s.screen.ClearLine(len(message), y+2)
+	s.screen.PrintAt(0, y+4, "Press C to return to main screen")
+}
+
+// consumerLines returns the heading and a line for each consumer
+func consumerLines(consumers []setup_consumers.Consumer) (string, []string) {
+	heading := fmt.Sprintf("%-32s %s", "Consumer", "Enabled")
+	rows := make([]string, 0, len(consumers))
+	for i := range consumers {
+		enabled := "NO"
+		if consumers[i].Enabled {
+			enabled = "YES"
+		}
+		rows = append(rows, fmt.Sprintf("%-32s %s", consumers[i].Name, enabled))
+	}
+
+	return heading, rows
+}
+
+// formatDuration formats a duration like the latencies in the views
+func formatDuration(d time.Duration) string {
+	return lib.FormatTime(uint64(d.Nanoseconds()) * 1000)
+}
+
+// DisplayInfo displays the server's configuration
+func (s *ScreenDisplay) DisplayInfo(info server_info.Info) {
+	s.screen.PrintAt(0, 0, lib.MyName()+" version "+version.Version()+" "+lib.Copyright())
+
+	lines := infoLines(info)
+	for i := range lines {
+		s.screen.PrintAt(0, 2+i, lines[i])
+		s.screen.ClearLine(len(lines[i]), 2+i)
+	}
+	s.screen.PrintAt(0, 3+len(lines), "Press i to return to main screen")
+}
+
+// infoLines returns the lines describing the server's configuration
+func infoLines(info server_info.Info) []string {
+	lines := []string{"Server configuration collected at " + lib.InTimezone(info.Collected).Format("15:04:05"), ""}
+	for _, setting := range info.Settings {
+		lines = append(lines, fmt.Sprintf("%-20s %s", setting.Name+":", setting.Value))
+	}
+
+	return lines
+}
+
+// DisplayAbout displays how long each view takes to collect, the
+// collections which failed and the resources used by the program itself
+func (s *ScreenDisplay) DisplayAbout(stats *self_stats.Stats) {
+	s.screen.PrintAt(0, 0, lib.MyName()+" version "+version.Version()+" "+lib.Copyright())
+
+	lines, heading, collectors := aboutLines(stats)
+	for i := range lines {
+		s.screen.PrintAt(0, 2+i, lines[i])
+		s.screen.ClearLine(len(lines[i]), 2+i)
+	}
+
+	s.screen.BoldPrintAt(0, 6, heading)
+	for i := range collectors {
+		s.screen.PrintAt(0, 7+i, collectors[i])
+		s.screen.ClearLine(len(collectors[i]), 7+i)
+	}
+
+	s.screen.PrintAt(0, 9+len(collectors), "Press A to return to main screen")
+}
+
+// aboutLines returns the lines describing the program's own resource
+// usage and the heading and a line for each collector
+func aboutLines(stats *self_stats.Stats) ([]string, string, []string) {
+	memory := stats.Memory()
+	lines := []string{
+		fmt.Sprintf("Running for %s since %s", lib.Uptime(int(time.Since(stats.Started()).Seconds())), lib.InTimezone(stats.Started()).Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("Memory: heap %sB, from the OS %sB, %d GCs, %d goroutines",
+			lib.FormatAmount(memory.HeapAlloc), lib.FormatAmount(memory.Sys), memory.NumGC, memory.Goroutines),
+		fmt.Sprintf("Display updates missed while collecting: %d", stats.DroppedFrames()),
+	}
+
+	heading := fmt.Sprintf("%-20s %-7s %8s %6s %8s %10s %10s %10s|%s", "Collector", "Status", "Collects", "Errors", "Rows", "Last", "Average", "Max", "Last Error")
+	collectors := stats.Collectors()
+	rows := make([]string, 0, len(collectors))
+	for i := range collectors {
+		rows = append(rows, fmt.Sprintf("%-20s %-7s %8d %6d %8d %10s %10s %10s|%s",
+			collectors[i].Name,
+			collectors[i].Status(),
+			collectors[i].Collections,
+			collectors[i].Errors,
+			collectors[i].Rows,
+			formatDuration(collectors[i].Last),
+			formatDuration(collectors[i].Average()),
+			formatDuration(collectors[i].Max),
+			collectors[i].LastError))
+	}
+
+	return lines, heading, rows
+}
+
+// Resize records the new size of the screen and lays the view out
+// again for it: the rows which fit and how far they are scrolled, the
+// row selected being kept in view. The screen is redrawn from blank by
+// the next Display, and as nothing is shown until it is flushed the
+// terminal never shows it blank or half drawn.
 func (s *ScreenDisplay) Resize(width, height int) {
 	s.screen.SetSize(width, height)
+	s.screen.Clear()
+	s.layout = newLayout(height, s.layout.first, s.ctx.SelectedRow(), s.layout.total)
 }
 
 // Close is called prior to closing the screen
 func (s *ScreenDisplay) Close() {
 	s.screen.Close()
+	if s.title != "" {
+		fmt.Fprint(os.Stdout, "\x1b[23;0t")
+	}
+}
+
+// KeyEvent converts a key pressed to the app event it asks for
+func KeyEvent(ch rune) event.Event {
+	switch ch {
+	case '-':
+		return event.Event{Type: event.EventDecreasePollTime}
+	case '+':
+		return event.Event{Type: event.EventIncreasePollTime}
+	case 'a':
+		return event.Event{Type: event.EventToggleByTable}
+	case 'b':
+		return event.Event{Type: event.EventToggleIOPerRow}
+	case 'e':
+		return event.Event{Type: event.EventToggleStatements}
+	case 'f':
+		return event.Event{Type: event.EventFollow}
+	case 'h':
+		return event.Event{Type: event.EventHistory}
+	case '?':
+		return event.Event{Type: event.EventHelp}
+	case 'i':
+		return event.Event{Type: event.EventInfo}
+	case 'A':
+		return event.Event{Type: event.EventAbout}
+	case 'C':
+		return event.Event{Type: event.EventConsumers}
+	case 'E':
+		return event.Event{Type: event.EventEnableConsumers}
+	case 'I':
+		return event.Event{Type: event.EventInstruments}
+	case 'Z':
+		return event.Event{Type: event.EventToggleAllRows}
+	case 'n':
+		return event.Event{Type: event.EventToggleRawNumbers}
+	case 'R':
+		return event.Event{Type: event.EventRestoreInstruments}
+	case 'T':
+		return event.Event{Type: event.EventToggleTab}
+	case 'k':
+		return event.Event{Type: event.EventObjectType}
+	case 'l':
+		return event.Event{Type: event.EventToggleConnections}
+	case 'm':
+		return event.Event{Type: event.EventMark}
+	case 'M':
+		return event.Event{Type: event.EventToggleSinceMark}
+	case 'o':
+		return event.Event{Type: event.EventToggleOpLatency}
+	case 'p':
+		return event.Event{Type: event.EventTogglePartitions}
+	case 'P':
+		return event.Event{Type: event.EventTogglePriority}
+	case '%':
+		return event.Event{Type: event.EventTogglePercent}
+	case '>':
+		return event.Event{Type: event.EventExpand}
+	case '<':
+		return event.Event{Type: event.EventCollapse}
+	case 'q':
+		return event.Event{Type: event.EventFinished}
+	case 't':
+		return event.Event{Type: event.EventToggleWantRelative}
+	case 'u':
+		return event.Event{Type: event.EventToggleByAccount}
+	case 'w':
+		return event.Event{Type: event.EventToggleLockWaiters}
+	case 'z':
+		return event.Event{Type: event.EventResetStatistics}
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return event.Event{Type: event.EventViewNumber, Number: int(ch - '0')}
+	}
+
+	return event.Event{Type: event.EventUnknown}
+}
+
+// nextTermboxEvent returns the event kept back while waiting for resizes
+// to settle, if any, or waits for the next one
+func (s *ScreenDisplay) nextTermboxEvent() termbox.Event {
+	if s.pending != nil {
+		tbEvent := *s.pending
+		s.pending = nil
+		return tbEvent
+	}
+
+	return <-s.termboxChan
+}
+
+// lastResize coalesces a storm of resize events into the last one, so
+// the view is laid out once for the final size rather than for each
+// size the window passed through. Any other event ends the wait and is
+// kept for the next poll.
+func (s *ScreenDisplay) lastResize(resize termbox.Event) termbox.Event {
+	maxWait := time.After(resizeMaxWait)
+	for {
+		select {
+		case tbEvent := <-s.termboxChan:
+			if tbEvent.Type != termbox.EventResize {
+				s.pending = &tbEvent
+				return resize
+			}
+			resize = tbEvent
+		case <-time.After(resizeSettle):
+			return resize
+		case <-maxWait:
+			return resize
+		}
+	}
 }
 
 // convert screen to app events
 func (s *ScreenDisplay) pollEvent() event.Event {
 	e := event.Event{Type: event.EventUnknown}
-	select {
-	case tbEvent := <-s.termboxChan:
-		switch tbEvent.Type {
-		case termbox.EventKey:
-			switch tbEvent.Ch {
-			case '-':
-				e = event.Event{Type: event.EventDecreasePollTime}
-			case '+':
-				e = event.Event{Type: event.EventIncreasePollTime}
-			case 'h', '?':
-				e = event.Event{Type: event.EventHelp}
-			case 'q':
-				e = event.Event{Type: event.EventFinished}
-			case 't':
-				e = event.Event{Type: event.EventToggleWantRelative}
-			case 'z':
-				e = event.Event{Type: event.EventResetStatistics}
-			}
-			switch tbEvent.Key {
-			case termbox.KeyCtrlZ, termbox.KeyCtrlC, termbox.KeyEsc:
-				e = event.Event{Type: event.EventFinished}
-			case termbox.KeyArrowLeft:
-				e = event.Event{Type: event.EventViewPrev}
-			case termbox.KeyTab, termbox.KeyArrowRight:
-				e = event.Event{Type: event.EventViewNext}
-			}
-		case termbox.EventResize:
-			e = event.Event{Type: event.EventResizeScreen, Width: tbEvent.Width, Height: tbEvent.Height}
-		case termbox.EventError:
-			e = event.Event{Type: event.EventError}
+	tbEvent := s.nextTermboxEvent()
+	switch tbEvent.Type {
+	case termbox.EventKey:
+		e = KeyEvent(tbEvent.Ch)
+		switch tbEvent.Key {
+		case termbox.KeyCtrlZ, termbox.KeyCtrlC, termbox.KeyEsc:
+			e = event.Event{Type: event.EventFinished}
+		case termbox.KeyArrowLeft:
+			e = event.Event{Type: event.EventViewPrev}
+		case termbox.KeyArrowUp:
+			e = event.Event{Type: event.EventSelectUp}
+		case termbox.KeyArrowDown:
+			e = event.Event{Type: event.EventSelectDown}
+		case termbox.KeyPgup:
+			e = event.Event{Type: event.EventPageUp}
+		case termbox.KeyPgdn:
+			e = event.Event{Type: event.EventPageDown}
+		case termbox.KeyTab:
+			e = event.Event{Type: event.EventTabNext}
+		case termbox.KeyArrowRight:
+			e = event.Event{Type: event.EventViewNext}
 		}
+	case termbox.EventResize:
+		tbEvent = s.lastResize(tbEvent)
+		e = event.Event{Type: event.EventResizeScreen, Width: tbEvent.Width, Height: tbEvent.Height}
+	case termbox.EventError:
+		e = event.Event{Type: event.EventError}
 	}
 	return e
 }
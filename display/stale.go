@@ -0,0 +1,39 @@
+package display
+
+import (
+	"fmt"
+	"time"
+)
+
+// staleData shows the last data collected with a warning that it could
+// not be refreshed
+type staleData struct {
+	GenericData // embedded
+	err         error
+}
+
+// NewStaleData returns the data marked as stale because the last
+// collection failed with the given error
+func NewStaleData(data GenericData, err error) GenericData {
+	s := staleData{GenericData: data, err: err}
+	return withValues(s, data)
+}
+
+// badge returns STALE and the age of the data, if any was collected
+func (s staleData) badge() string {
+	last := s.GenericData.LastCollectTime()
+	if last.IsZero() {
+		return "STALE (no data)"
+	}
+	return fmt.Sprintf("STALE (%ds)", int(time.Since(last).Seconds()))
+}
+
+// Description puts the badge in front of the description of the data
+func (s staleData) Description() string {
+	return s.badge() + " " + s.GenericData.Description()
+}
+
+// TotalRowContent adds the badge and the error to the totals line
+func (s staleData) TotalRowContent() string {
+	return s.GenericData.TotalRowContent() + " " + s.badge() + ": " + s.err.Error()
+}
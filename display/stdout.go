@@ -2,8 +2,15 @@ package display
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/sjmudd/ps-top/event"
+	"github.com/sjmudd/ps-top/row_history"
+	"github.com/sjmudd/ps-top/self_stats"
+	"github.com/sjmudd/ps-top/server_info"
+	"github.com/sjmudd/ps-top/setup_consumers"
+	"github.com/sjmudd/ps-top/setup_instruments"
 )
 
 // StdoutDisplay holds specific information needed for sending data to stdout.
@@ -11,12 +18,19 @@ type StdoutDisplay struct {
 	BaseDisplay // embedded
 	limit       int
 	totals      bool
+	w           io.Writer
 }
 
 // return a setup StdoutDisplay
 func NewStdoutDisplay(limit int, onlyTotals bool) *StdoutDisplay {
+	return NewStdoutDisplayTo(os.Stdout, limit, onlyTotals)
+}
+
+// NewStdoutDisplayTo returns a StdoutDisplay which writes to w
+func NewStdoutDisplayTo(w io.Writer, limit int, onlyTotals bool) *StdoutDisplay {
 	s := new(StdoutDisplay)
 
+	s.w = w
 	s.limit = limit
 	s.totals = onlyTotals
 
@@ -27,11 +41,18 @@ func NewStdoutDisplay(limit int, onlyTotals bool) *StdoutDisplay {
 func (s *StdoutDisplay) ClearScreen() {
 }
 
+// Flush does nothing for StdoutDisplay
+func (s *StdoutDisplay) Flush() {
+}
+
 // Display displays the data for the required view
 func (s *StdoutDisplay) Display(p GenericData) {
-	fmt.Println(s.HeadingLine(p.HaveRelativeStats(), p.WantRelativeStats(), p.InitialCollectTime(), p.LastCollectTime()))
-	fmt.Println(p.Description())
-	fmt.Println(p.Headings())
+	fmt.Fprintln(s.w, s.HeadingLine(p))
+	for _, line := range s.statusPanel(0) {
+		fmt.Fprintln(s.w, line)
+	}
+	fmt.Fprintln(s.w, p.Description())
+	fmt.Fprintln(s.w, p.Headings())
 
 	if !s.totals {
 		rows := p.Len()
@@ -43,19 +64,39 @@ func (s *StdoutDisplay) Display(p GenericData) {
 		for k := 0; k < len(rowContent); k++ {
 			if k < rows {
 				if rowContent[k] != p.EmptyRowContent() {
-					fmt.Println(rowContent[k])
+					fmt.Fprintln(s.w, rowContent[k])
 				}
 			}
 		}
 	}
 
-	fmt.Println(p.TotalRowContent())
+	fmt.Fprintln(s.w, p.TotalRowContent()+s.UptimeAverages(p))
 }
 
 // DisplayHelp does nothing on a StdoutDisplay
 func (s *StdoutDisplay) DisplayHelp() {
 }
 
+// DisplayInstruments does nothing on a StdoutDisplay
+func (s *StdoutDisplay) DisplayInstruments(families []setup_instruments.Family, message string) {
+}
+
+// DisplayConsumers does nothing on a StdoutDisplay
+func (s *StdoutDisplay) DisplayConsumers(view string, consumers []setup_consumers.Consumer, message string) {
+}
+
+// DisplayAbout does nothing on a StdoutDisplay
+func (s *StdoutDisplay) DisplayAbout(stats *self_stats.Stats) {
+}
+
+// DisplayInfo does nothing on a StdoutDisplay
+func (s *StdoutDisplay) DisplayInfo(info server_info.Info) {
+}
+
+// DisplayHistory does nothing on a StdoutDisplay
+func (s *StdoutDisplay) DisplayHistory(series row_history.Series) {
+}
+
 // Close does nothing on a StdoutDisplay
 func (s *StdoutDisplay) Close() {
 }
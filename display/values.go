@@ -0,0 +1,29 @@
+package display
+
+import (
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// valuesData adds the row values of the underlying data to data which
+// changes how its rows are shown, so displays which write the values,
+// e.g. --changes, still get them
+type valuesData struct {
+	GenericData // embedded
+	values      func() []ps_table.RowValues
+}
+
+// withValues returns shown with the row values of the underlying data
+// if it has any
+func withValues(shown GenericData, data GenericData) GenericData {
+	valuer, ok := data.(ps_table.Valuer)
+	if !ok {
+		return shown
+	}
+
+	return valuesData{GenericData: shown, values: valuer.Values}
+}
+
+// Values returns the row values of the underlying data
+func (v valuesData) Values() []ps_table.RowValues {
+	return v.values()
+}
@@ -11,11 +11,43 @@ const (
 	EventFinished                       // please exit the program
 	EventViewNext                       // show me the next view
 	EventViewPrev                       // show me the previous view
+	EventViewNumber                     // show me the view with the given number
 	EventDecreasePollTime               // reduce the poll time (if possible)
 	EventIncreasePollTime               // increase the poll time
 	EventHelp                           // provide me with help
+	EventHistory                        // show me the history of the selected row, or help if none is selected
+	EventSelectUp                       // select the row above
+	EventSelectDown                     // select the row below
+	EventExpand                         // show the events below the branch selected
+	EventCollapse                       // hide the events below the branch selected
+	EventInstruments                    // show me the instruments screen
+	EventRestoreInstruments             // restore the instruments left changed by an earlier run
+	EventConsumers                      // show me the consumers needed by the view
+	EventEnableConsumers                // enable the consumers needed by the view which are disabled
+	EventAbout                          // show me how ps-top itself is performing
+	EventInfo                           // show me the server's configuration
 	EventToggleWantRelative             // toggle beween wanting absolute or relative stats
+	EventToggleStatements               // toggle between truncated and full statements
+	EventTogglePartitions               // toggle between showing tables or their partitions
+	EventToggleByTable                  // toggle between showing statements or their tables
+	EventToggleByAccount                // toggle between showing mutexes globally or by account
+	EventToggleOpLatency                // toggle between showing table I/O percentages or latency by operation
+	EventTogglePercent                  // toggle between showing values or their percentages of the column totals
+	EventToggleIOPerRow                 // toggle showing the file I/O per row in the table I/O views
+	EventToggleConnections              // toggle between showing users or listing their connections
+	EventToggleAllRows                  // toggle between hiding or showing the rows without activity
+	EventToggleLockWaiters              // toggle between showing the lock latency by table or the connections waiting for a lock now
+	EventTogglePriority                 // toggle between the view's sort and ordering the rows by their priority
+	EventObjectType                     // change the type of tables the table I/O views show: all, tables or temporary tables
+	EventPageUp                         // show the previous page of connections
+	EventPageDown                       // show the next page of connections
+	EventFollow                         // start or stop following a connection
 	EventResetStatistics                // reset the current stats back to zero
+	EventMark                           // mark the current stats as a comparison point
+	EventToggleSinceMark                // toggle between showing stats since the mark or since the reset
+	EventToggleTab                      // open or close a tab for the current view
+	EventToggleRawNumbers               // toggle between showing raw numbers or human readable ones
+	EventTabNext                        // show me the next tab, or the next view if there are no tabs
 	EventResizeScreen                   // not really a event but a state change
 	EventUnknown                        // something weird has happened
 	EventError                          // some error
@@ -26,6 +58,7 @@ type Event struct {
 	Type   Type
 	Width  int
 	Height int
+	Number int // number pressed for EventViewNumber
 }
 
 const eventChanSize = 100 // arbitrary size. Maybe should be 0?
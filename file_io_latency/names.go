@@ -0,0 +1,75 @@
+package file_io_latency
+
+import (
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// nameRule groups the files whose path matches a regular expression
+// under a name built from its capture groups
+type nameRule struct {
+	re       *regexp.Regexp
+	template string
+}
+
+var (
+	nameRules       []nameRule
+	loadedNameRules bool
+)
+
+// parseNameRules returns the rules of the [file_io_names] section, e.g.
+// [file_io_names]
+// /undo_\d+$ = <undo>
+// ^/data/(\w+)/relay-bin\.(\d{6}|index)$ = <relay_log $1>
+// The whole name is replaced by the template, in which $1 or ${name}
+// refer to the groups captured. Rules are tried in order of their
+// regular expressions.
+func parseNameRules(section map[string]string) ([]nameRule, error) {
+	patterns := make([]string, 0, len(section))
+	for pattern := range section {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+
+	rules := make([]nameRule, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, nameRule{re: re, template: section[pattern]})
+	}
+
+	return rules, nil
+}
+
+// customName returns the name given to the path by the first rule it
+// matches in ~/.pstoprc, if any
+func customName(path string) (string, bool) {
+	if !loadedNameRules {
+		loadedNameRules = true
+		rules, err := parseNameRules(rc.Section("file_io_names"))
+		if err != nil {
+			log.Fatal("Invalid regular expression in the [file_io_names] section of ~/.pstoprc: ", err)
+		}
+		nameRules = rules
+		logger.Println("file_io_latency: found", len(nameRules), "file name rule(s)")
+	}
+
+	return applyNameRules(nameRules, path)
+}
+
+// applyNameRules returns the name built by the first rule matching the path
+func applyNameRules(rules []nameRule, path string) (string, bool) {
+	for _, rule := range rules {
+		if match := rule.re.FindStringSubmatchIndex(path); match != nil {
+			return string(rule.re.ExpandString(nil, rule.template, path, match)), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,35 @@
+package file_io_latency
+
+import (
+	"testing"
+)
+
+func TestApplyNameRules(t *testing.T) {
+	rules, err := parseNameRules(map[string]string{
+		`/undo_\d+$`:                             "<undo>",
+		`^/data/(\w+)/relay-bin\.(\d{6}|index)$`: "<relay_log $1>",
+	})
+	if err != nil {
+		t.Fatalf("parseNameRules() failed: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		name  string
+		found bool
+	}{
+		{"/var/lib/mysql/undo_001", "<undo>", true},
+		{"/data/replica1/relay-bin.000042", "<relay_log replica1>", true},
+		{"/data/replica1/relay-bin.index", "<relay_log replica1>", true},
+		{"/var/lib/mysql/shop/orders.ibd", "", false},
+	}
+	for _, test := range tests {
+		if name, found := applyNameRules(rules, test.path); name != test.name || found != test.found {
+			t.Errorf("applyNameRules(%q) expected (%q, %v) but got (%q, %v)", test.path, test.name, test.found, name, found)
+		}
+	}
+
+	if _, err := parseNameRules(map[string]string{`(`: "x"}); err == nil {
+		t.Errorf("parseNameRules() expected an error for an invalid regular expression")
+	}
+}
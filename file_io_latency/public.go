@@ -4,19 +4,25 @@ package file_io_latency
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/sjmudd/ps-top/baseobject"
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/table"
 )
 
 // Object represents the contents of the data collected from file_summary_by_instance
 type Object struct {
-	baseobject.BaseObject // embedded
-	initial               Rows
+	baseobject.BaseObject      // embedded
+	initial               Rows // the rows of each file, merged into results by name
+	mark                  Rows // marked data for relative values since the mark
 	current               Rows
 	results               Rows
 	totals                Row
+	partitioned           bool           // some tables are partitioned
+	columns               *table.Columns // which of the optional columns can be SELECTed
 }
 
 // NewFileSummaryByInstance creates a new structure and include various variable values:
@@ -26,6 +32,7 @@ func NewFileSummaryByInstance(ctx *context.Context) *Object {
 	logger.Println("NewFileSummaryByInstance()")
 	n := new(Object)
 	n.SetContext(ctx)
+	n.columns = table.NewColumns("file_summary_by_instance", optionalColumns...)
 
 	return n
 }
@@ -38,14 +45,32 @@ func (t *Object) SetInitialFromCurrent() {
 }
 
 func (t *Object) copyCurrentToInitial() {
-	t.initial = make(Rows, len(t.current))
+	t.initial = append(t.initial[:0], t.current...)
 	t.SetInitialCollectTime(t.LastCollectTime())
-	copy(t.initial, t.current)
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
 }
 
 // Collect data from the db, then merge it in.
-func (t *Object) Collect(dbh *sql.DB) {
-	t.current = selectRows(dbh).mergeByName(t.Variables())
+func (t *Object) Collect(dbh *sql.DB) error {
+	rows, err := selectRows(dbh, t.columns)
+	if err != nil {
+		return err
+	}
+	t.current = rows
 	t.SetLastCollectTimeNow()
 
 	// copy in initial data if it was not there
@@ -57,16 +82,29 @@ func (t *Object) Collect(dbh *sql.DB) {
 	if t.initial.needsRefresh(t.current) {
 		t.copyCurrentToInitial()
 	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
 
 	t.makeResults()
+
+	return nil
 }
 
 func (t *Object) makeResults() {
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
-	if t.WantRelativeStats() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
 		t.results.subtract(t.initial)
 	}
+	t.results = t.results.mergeByName(t.Variables())
+	rolledUp, partitioned := t.results.rollupPartitions()
+	if !t.WantPartitions() {
+		t.results = rolledUp
+	}
+	t.partitioned = partitioned
 
 	t.results.sort()
 	t.totals = t.results.totals()
@@ -76,7 +114,7 @@ func (t *Object) makeResults() {
 func (t Object) Headings() string {
 	var r Row
 
-	return r.headings()
+	return r.headings(t.columns)
 }
 
 // RowContent returns the rows we need for displaying
@@ -84,7 +122,7 @@ func (t Object) RowContent() []string {
 	rows := make([]string, 0, len(t.results))
 
 	for i := range t.results {
-		rows = append(rows, t.results[i].rowContent(t.totals))
+		rows = append(rows, t.results[i].rowContent(t.totals, t.columns))
 	}
 
 	return rows
@@ -97,13 +135,13 @@ func (t Object) Len() int {
 
 // TotalRowContent returns all the totals
 func (t Object) TotalRowContent() string {
-	return t.totals.rowContent(t.totals)
+	return t.totals.rowContent(t.totals, t.columns)
 }
 
 // EmptyRowContent returns an empty string of data (for filling in)
 func (t Object) EmptyRowContent() string {
 	var empty Row
-	return empty.rowContent(empty)
+	return empty.rowContent(empty, t.columns)
 }
 
 // Description returns a description of the table
@@ -115,10 +153,42 @@ func (t Object) Description() string {
 		}
 	}
 
-	return fmt.Sprintf("File I/O Latency (file_summary_by_instance) %4d row(s)    ", count)
+	description := fmt.Sprintf("File I/O Latency (file_summary_by_instance) %4d row(s)    ", count)
+	if t.partitioned {
+		if t.WantPartitions() {
+			description += "by partition (p: by table)"
+		} else {
+			description += "by table (p: by partition)"
+		}
+	}
+
+	return description
 }
 
 // HaveRelativeStats is true for this object
 func (t Object) HaveRelativeStats() bool {
 	return true
 }
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	current := t.current.mergeByName(t.Variables())
+	values := make([]ps_table.RowValues, 0, len(current))
+
+	for i := range current {
+		values = append(values, current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -9,14 +9,16 @@ import (
 	"github.com/sjmudd/ps-top/global"
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
 	"github.com/sjmudd/ps-top/rc"
+	"github.com/sjmudd/ps-top/table"
 )
 
 /*
 CREATE TABLE `file_summary_by_instance` (
   `FILE_NAME` varchar(512) NOT NULL,
   `EVENT_NAME` varchar(128) NOT NULL,				// not collected
-  `OBJECT_INSTANCE_BEGIN` bigint(20) unsigned NOT NULL,		// not collected
+  `OBJECT_INSTANCE_BEGIN` bigint(20) unsigned NOT NULL,
   `COUNT_STAR` bigint(20) unsigned NOT NULL,
   `SUM_TIMER_WAIT` bigint(20) unsigned NOT NULL,
   `MIN_TIMER_WAIT` bigint(20) unsigned NOT NULL,
@@ -56,6 +58,20 @@ type Row struct {
 	sumTimerMisc          uint64
 	sumNumberOfBytesRead  uint64
 	sumNumberOfBytesWrite uint64
+	instance              uint64 // OBJECT_INSTANCE_BEGIN, 0 once merged
+}
+
+// identity identifies the file a row was collected from
+type identity struct {
+	name     string
+	instance uint64
+}
+
+// identity returns the instance and name of the file. The instance
+// changes if the file is recreated, but performance_schema reuses the
+// instances of closed files so the name is needed too.
+func (row Row) identity() identity {
+	return identity{name: row.name, instance: row.instance}
 }
 
 //     foo/../bar --> foo/bar   perl: $new =~ s{[^/]+/\.\./}{/};
@@ -70,7 +86,7 @@ var (
 	reSlashDotDotSlash = regexp.MustCompile(`[^/]+/\.\./`)
 	reTableFile        = regexp.MustCompile(`/([^/]+)/([^/]+)\.(frm|ibd|MYD|MYI|CSM|CSV|par)$`)
 	reTempTable        = regexp.MustCompile(`#sql-[0-9_]+`)
-	rePartTable        = regexp.MustCompile(`(.+)#P#p(\d+|MAX)`)
+	rePartTable        = regexp.MustCompile(`(.+?)#[Pp]#(.+)`)
 	reIbdata           = regexp.MustCompile(`/ibdata\d+$`)
 	reIbtmp            = regexp.MustCompile(`/ibtmp\d+$`)
 	reRedoLog          = regexp.MustCompile(`/ib_logfile\d+$`)
@@ -84,19 +100,32 @@ var (
 	reDollar           = regexp.MustCompile(`@0024`) // FIXME - add me to catch @0024 --> $ (specific case)
 )
 
-func (row Row) headings() string {
+// optionalColumns may be hidden if the user can't SELECT them.
+// The order matches the SELECT in selectRows().
+var optionalColumns = []string{
+	"SUM_TIMER_READ",
+	"SUM_TIMER_WRITE",
+	"SUM_NUMBER_OF_BYTES_READ",
+	"SUM_NUMBER_OF_BYTES_WRITE",
+	"SUM_TIMER_MISC",
+	"COUNT_READ",
+	"COUNT_WRITE",
+	"COUNT_MISC",
+}
+
+func (row Row) headings(columns *table.Columns) string {
 	return fmt.Sprintf("%10s %6s|%6s %6s %6s|%8s %8s|%8s %6s %6s %6s|%s",
 		"Latency",
 		"%",
-		"Read",
-		"Write",
-		"Misc",
-		"Rd bytes",
-		"Wr bytes",
+		columns.Show("SUM_TIMER_READ", "Read"),
+		columns.Show("SUM_TIMER_WRITE", "Write"),
+		columns.Show("SUM_TIMER_MISC", "Misc"),
+		columns.Show("SUM_NUMBER_OF_BYTES_READ", "Rd bytes"),
+		columns.Show("SUM_NUMBER_OF_BYTES_WRITE", "Wr bytes"),
 		"Ops",
-		"R Ops",
-		"W Ops",
-		"M Ops",
+		columns.Show("COUNT_READ", "R Ops"),
+		columns.Show("COUNT_WRITE", "W Ops"),
+		columns.Show("COUNT_MISC", "M Ops"),
 		"Table Name")
 }
 
@@ -138,7 +167,7 @@ func (row Row) Valid(logProblem bool) bool {
 }
 
 // generate a printable result
-func (row Row) rowContent(totals Row) string {
+func (row Row) rowContent(totals Row, columns *table.Columns) string {
 	var name = row.name
 
 	// We assume that if countStar = 0 then there's no data at all...
@@ -150,19 +179,19 @@ func (row Row) rowContent(totals Row) string {
 	return fmt.Sprintf("%10s %6s|%6s %6s %6s|%8s %8s|%8s %6s %6s %6s|%s",
 		lib.FormatTime(row.sumTimerWait),
 		lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerRead, row.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerWrite, row.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerMisc, row.sumTimerWait)),
-		lib.FormatAmount(row.sumNumberOfBytesRead),
-		lib.FormatAmount(row.sumNumberOfBytesWrite),
+		columns.Show("SUM_TIMER_READ", lib.FormatPct(lib.MyDivide(row.sumTimerRead, row.sumTimerWait))),
+		columns.Show("SUM_TIMER_WRITE", lib.FormatPct(lib.MyDivide(row.sumTimerWrite, row.sumTimerWait))),
+		columns.Show("SUM_TIMER_MISC", lib.FormatPct(lib.MyDivide(row.sumTimerMisc, row.sumTimerWait))),
+		columns.Show("SUM_NUMBER_OF_BYTES_READ", lib.FormatAmount(row.sumNumberOfBytesRead)),
+		columns.Show("SUM_NUMBER_OF_BYTES_WRITE", lib.FormatAmount(row.sumNumberOfBytesWrite)),
 		lib.FormatAmount(row.countStar),
-		lib.FormatPct(lib.MyDivide(row.countRead, row.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countWrite, row.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countMisc, row.countStar)),
+		columns.Show("COUNT_READ", lib.FormatPct(lib.MyDivide(row.countRead, row.countStar))),
+		columns.Show("COUNT_WRITE", lib.FormatPct(lib.MyDivide(row.countWrite, row.countStar))),
+		columns.Show("COUNT_MISC", lib.FormatPct(lib.MyDivide(row.countMisc, row.countStar))),
 		name)
 }
 
-// Add rows together, keeping the name of first row
+// Add rows together, keeping the name and instance of first row
 func add(row, other Row) Row {
 	newRow := row
 
@@ -211,6 +240,15 @@ func subtract(row, other Row) Row {
 	return newRow
 }
 
+// pathFlags returns the flags of the regular expressions built from the
+// server's paths: case insensitive if its file system is
+func pathFlags() string {
+	if lib.LowerCaseFileSystem() {
+		return "(?i)"
+	}
+	return ""
+}
+
 // From the original name we want to generate a simpler name to use.
 // This simpler name may also merge several different filenames into one.
 func (row Row) simplifyName(globalVariables *global.Variables) string {
@@ -220,6 +258,11 @@ func (row Row) simplifyName(globalVariables *global.Variables) string {
 		return cachedResult
 	}
 
+	// the user's rules take precedence over those below
+	if name, found := customName(path); found {
+		return cache.put(path, name)
+	}
+
 	// @0024 --> $ (should do this more generically)
 	path = reDollar.ReplaceAllLiteralString(path, "$")
 
@@ -232,7 +275,7 @@ func (row Row) simplifyName(globalVariables *global.Variables) string {
 
 		// we may match partitioned tables so check for them
 		if m3 := rePartTable.FindStringSubmatch(m1[2]); m3 != nil {
-			return cache.put(path, lib.TableName(m1[1], m3[1])) // <schema>.<table> (less partition info)
+			return cache.put(path, lib.TableName(m1[1], m3[1])+lib.PartitionSeparator+m3[2]) // <schema>.<table>#P#<partition>
 		}
 
 		return cache.put(path, rc.Munge(lib.TableName(m1[1], m1[2]))) // <schema>.<table>
@@ -267,7 +310,7 @@ func (row Row) simplifyName(globalVariables *global.Variables) string {
 		if relayLog[0] != '/' { // relative path
 			relayLog = cleanupPath(globalVariables.Get("datadir") + relayLog) // datadir always ends in /
 		}
-		reRelayLog := relayLog + `\.(\d{6}|index)$`
+		reRelayLog := pathFlags() + relayLog + `\.(\d{6}|index)$`
 		if regexp.MustCompile(reRelayLog).MatchString(path) {
 			return cache.put(path, "<relay_log>")
 		}
@@ -283,7 +326,7 @@ func (row Row) simplifyName(globalVariables *global.Variables) string {
 	}
 	// clean up datadir to <datadir>
 	if len(globalVariables.Get("datadir")) > 0 {
-		reDatadir := regexp.MustCompile("^" + globalVariables.Get("datadir"))
+		reDatadir := regexp.MustCompile(pathFlags() + "^" + globalVariables.Get("datadir"))
 		path = reDatadir.ReplaceAllLiteralString(path, "<datadir>/")
 	}
 
@@ -303,3 +346,22 @@ func cleanupPath(path string) string {
 
 	return path
 }
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"count_star":                row.countStar,
+			"count_read":                row.countRead,
+			"count_write":               row.countWrite,
+			"count_misc":                row.countMisc,
+			"sum_timer_wait":            row.sumTimerWait,
+			"sum_timer_read":            row.sumTimerRead,
+			"sum_timer_write":           row.sumTimerWrite,
+			"sum_timer_misc":            row.sumTimerMisc,
+			"sum_number_of_bytes_read":  row.sumNumberOfBytesRead,
+			"sum_number_of_bytes_write": row.sumNumberOfBytesWrite,
+		},
+	}
+}
@@ -13,9 +13,9 @@ func TestAdd(t *testing.T) {
 		sum  Row
 	}{
 		{
-			Row{"name1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
-			Row{"any__", 101, 102, 103, 104, 105, 106, 107, 108, 109, 110},
-			Row{"name1", 102, 104, 106, 108, 110, 112, 114, 116, 118, 120}},
+			Row{"name1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0},
+			Row{"any__", 101, 102, 103, 104, 105, 106, 107, 108, 109, 110, 0},
+			Row{"name1", 102, 104, 106, 108, 110, 112, 114, 116, 118, 120, 0}},
 	}
 
 	for _, test := range tests {
@@ -24,7 +24,7 @@ func TestAdd(t *testing.T) {
 			t.Errorf("r(%v).add(%v): expected %v, actual %v", test.val1, test.val2, test.sum, result)
 		}
 		if result.name != test.val1.name {
-			t.Errorf("r(%v).add(%v): name has changed from '%s' to '%s'", test1.val1, test.val2, test.val1.name, result.name)
+			t.Errorf("r(%v).add(%v): name has changed from '%s' to '%s'", test.val1, test.val2, test.val1.name, result.name)
 		}
 	}
 }
@@ -36,9 +36,9 @@ func TestSubtract(t *testing.T) {
 		diff Row
 	}{
 		{
-			Row{"name1", 102, 104, 106, 108, 110, 112, 114, 116, 118, 120},
-			Row{"any__", 101, 102, 103, 104, 105, 106, 107, 108, 109, 110},
-			Row{"name1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+			Row{"name1", 102, 104, 106, 108, 110, 112, 114, 116, 118, 120, 0},
+			Row{"any__", 101, 102, 103, 104, 105, 106, 107, 108, 109, 110, 0},
+			Row{"name1", 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0}},
 	}
 
 	for _, test := range tests {
@@ -51,3 +51,23 @@ func TestSubtract(t *testing.T) {
 		}
 	}
 }
+
+func TestSubtractReusedInstance(t *testing.T) {
+	initial := Rows{
+		{name: "/data/db/t1.ibd", countStar: 100, sumTimerWait: 1000, instance: 7},
+		{name: "/data/db/t2.ibd", countStar: 50, sumTimerWait: 500, instance: 8},
+	}
+	// t1 was closed and a new file took over its instance
+	rows := Rows{
+		{name: "/data/db/t3.ibd", countStar: 120, sumTimerWait: 1200, instance: 7},
+		{name: "/data/db/t2.ibd", countStar: 60, sumTimerWait: 600, instance: 8},
+	}
+
+	rows.subtract(initial)
+	if rows[0].countStar != 120 || rows[0].sumTimerWait != 1200 {
+		t.Errorf("subtract(): the file reusing an instance has %d ops, %d latency, want 120, 1200", rows[0].countStar, rows[0].sumTimerWait)
+	}
+	if rows[1].countStar != 10 || rows[1].sumTimerWait != 100 {
+		t.Errorf("subtract(): the same file has %d ops, %d latency, want 10, 100", rows[1].countStar, rows[1].sumTimerWait)
+	}
+}
@@ -4,13 +4,17 @@ package file_io_latency
 
 import (
 	"database/sql"
-	"log"
 	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+	"github.com/sjmudd/ps-top/table"
 )
 
 // Rows represents a slice of Row
@@ -82,6 +86,29 @@ func (rows Rows) mergeByName(globalVariables *global.Variables) Rows {
 	return mergedRows
 }
 
+// rollupPartitions combines the rows of the partitions of each table into one
+// row for the table, returning the result and whether any partitions were found.
+func (rows Rows) rollupPartitions() (Rows, bool) {
+	var partitioned bool
+	rowsByName := make(map[string]int)
+
+	var rolledUp Rows
+	for i := range rows {
+		table, _, found := lib.SplitPartition(rows[i].name)
+		partitioned = partitioned || found
+		if j, ok := rowsByName[table]; ok {
+			rolledUp[j] = add(rolledUp[j], rows[i])
+			continue
+		}
+		row := rows[i]
+		row.name = table
+		rowsByName[table] = len(rolledUp)
+		rolledUp = append(rolledUp, row)
+	}
+
+	return rolledUp, partitioned
+}
+
 // used for testing
 // usage: match(r.name, "demodb.table")
 func match(text string, searchFor string) bool {
@@ -98,32 +125,36 @@ func match(text string, searchFor string) bool {
 // - filter out empty values
 // - merge rows with the same name into a single row
 // - change name into a more descriptive value.
-func selectRows(dbh *sql.DB) Rows {
+func selectRows(dbh *sql.DB, columns *table.Columns) (Rows, error) {
 	alwaysAdd := true // false for testing
 
 	logger.Println("selectRows() starts")
 	var t Rows
 	start := time.Now()
 
+	// only select the optional columns we have access to
+	if err := columns.Check(dbh); err != nil {
+		return nil, err
+	}
+	selected := make([]string, len(optionalColumns))
+	for i := range optionalColumns {
+		selected[i] = columns.Select(optionalColumns[i])
+	}
+
 	sql := `
 SELECT	FILE_NAME,
+	OBJECT_INSTANCE_BEGIN,
 	SUM_TIMER_WAIT,
-	SUM_TIMER_READ,
-	SUM_TIMER_WRITE,
-	SUM_NUMBER_OF_BYTES_READ,
-	SUM_NUMBER_OF_BYTES_WRITE,
-	SUM_TIMER_MISC,
+	` + strings.Join(selected[:5], ",\n\t") + `,
 	COUNT_STAR,
-	COUNT_READ,
-	COUNT_WRITE,
-	COUNT_MISC
+	` + strings.Join(selected[5:], ",\n\t") + `
 FROM	file_summary_by_instance
 WHERE	SUM_TIMER_WAIT > 0
 `
 
 	rows, err := dbh.Query(sql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -132,6 +163,7 @@ WHERE	SUM_TIMER_WAIT > 0
 
 		if err := rows.Scan(
 			&r.name, // raw filename
+			&r.instance,
 			&r.sumTimerWait,
 			&r.sumTimerRead,
 			&r.sumTimerWrite,
@@ -142,7 +174,7 @@ WHERE	SUM_TIMER_WAIT > 0
 			&r.countRead,
 			&r.countWrite,
 			&r.countMisc); err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
 		if alwaysAdd || match(r.name, "demodb.table") {
@@ -150,18 +182,22 @@ WHERE	SUM_TIMER_WAIT > 0
 		}
 	}
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	if !t.Valid() {
 		logger.Println("WARNING: selectRows(): t is invalid")
 	}
 	logger.Println("selectRows() took:", time.Duration(time.Since(start)).String(), "and returned", len(t), "rows")
 
-	return t
+	return t, nil
 }
 
 // remove the initial values from those rows where there's a match
 // - if we find a row we can't match ignore it
+// - a row is matched by the instance and name of its file, so a recreated
+// file starts again from zero and a new file which took over the instance
+// of a closed one doesn't inherit its initial values, and if a file's
+// counters went backwards only its initial values are reset
 func (rows *Rows) subtract(initial Rows) {
 	// make temporary copy for debugging.
 	tempRows := make(Rows, len(*rows))
@@ -174,28 +210,7 @@ func (rows *Rows) subtract(initial Rows) {
 		logger.Println("WARNING: Rows.subtract(): initial is invalid (pre)")
 	}
 
-	// check that initial is "earlier"
-	rowsT := rows.totals()
-	initialT := initial.totals()
-	if rowsT.sumTimerWait < initialT.sumTimerWait {
-		logger.Println("BUG: (rows *Rows) subtract(initial): rows < initial")
-		logger.Println("sum(rows):  ", rowsT)
-		logger.Println("sum(intial):", initialT)
-	}
-
-	iByName := make(map[string]int)
-
-	// iterate over rows by name
-	for i := range initial {
-		iByName[initial[i].name] = i
-	}
-
-	for i := range *rows {
-		if _, ok := iByName[(*rows)[i].name]; ok {
-			initialI := iByName[(*rows)[i].name]
-			(*rows)[i] = subtract((*rows)[i], initial[initialI])
-		}
-	}
+	differ.SubtractRows(*rows, initial)
 	if !rows.Valid() {
 		logger.Println("WARNING: Rows.subtract(): rows is invalid (post)")
 		logger.Println("WARNING: tempRows:")
@@ -206,22 +221,45 @@ func (rows *Rows) subtract(initial Rows) {
 	}
 }
 
-func (rows Rows) Len() int      { return len(rows) }
-func (rows Rows) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
-func (rows Rows) Less(i, j int) bool {
-	return (rows[i].sumTimerWait > rows[j].sumTimerWait) ||
-		((rows[i].sumTimerWait == rows[j].sumTimerWait) && (rows[i].name < rows[j].name))
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"read":    func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerRead, rows[j].sumTimerRead) },
+		"write":   func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWrite, rows[j].sumTimerWrite) },
+		"misc":    func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerMisc, rows[j].sumTimerMisc) },
+		"bytes_read": func(i, j int) int {
+			return sort_keys.Descending(rows[i].sumNumberOfBytesRead, rows[j].sumNumberOfBytesRead)
+		},
+		"bytes_written": func(i, j int) int {
+			return sort_keys.Descending(rows[i].sumNumberOfBytesWrite, rows[j].sumNumberOfBytesWrite)
+		},
+		"name": func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
 }
 
+// sort by latency (descending) and name after any configured sort keys
 func (rows *Rows) sort() {
-	sort.Sort(rows)
+	sort.Slice(*rows, rows.sortKeys().Less("file_io_latency", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("file_io_latency", Rows(nil).sortKeys())
+}
+
+// differ matches the rows by the identity of their file to subtract
+// their initial values
+var differ = relative_stats.Differ[Row, identity]{
+	Key:      Row.identity,
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: func(row *Row, other Row) { *row = subtract(*row, other) },
 }
 
 // if the data in t2 is "newer", "has more values" than t then it needs refreshing.
-// check this by comparing totals.
+// check this by comparing the totals of the files found in both so files
+// which were removed or recreated don't cause a refresh.
 func (rows Rows) needsRefresh(t2 Rows) bool {
-	myTotals := rows.totals()
-	otherTotals := t2.totals()
-
-	return (myTotals.sumTimerWait > otherTotals.sumTimerWait) || (myTotals.countStar > otherTotals.countStar)
+	return differ.NeedsRefresh(rows, t2)
 }
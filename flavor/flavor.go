@@ -0,0 +1,51 @@
+// Package flavor detects the kind of server ps-top is connected to.
+// TiDB and Vitess speak the MySQL protocol but only provide some of the
+// performance_schema tables, so fewer assumptions can be made about them.
+package flavor
+
+import (
+	"strings"
+)
+
+// Flavor is the kind of server
+type Flavor int
+
+// the kinds of server we know about
+const (
+	MySQL  Flavor = iota // MySQL and its forks, e.g. MariaDB and Percona Server
+	TiDB                 // TiDB, which provides only some performance_schema tables
+	Vitess               // a Vitess vtgate, which passes on only some queries
+)
+
+// Detect returns the flavor of the server given its version and
+// version_comment settings, e.g. 8.0.11-TiDB-v7.5.0 or 8.0.30-Vitess
+func Detect(version, versionComment string) Flavor {
+	both := strings.ToLower(version + " " + versionComment)
+
+	switch {
+	case strings.Contains(both, "tidb"):
+		return TiDB
+	case strings.Contains(both, "vitess"):
+		return Vitess
+	}
+
+	return MySQL
+}
+
+// String returns the name of the flavor
+func (f Flavor) String() string {
+	switch f {
+	case TiDB:
+		return "TiDB"
+	case Vitess:
+		return "Vitess"
+	}
+
+	return "MySQL"
+}
+
+// Partial returns true if the server only provides some of the
+// performance_schema and can't be configured through setup_instruments
+func (f Flavor) Partial() bool {
+	return f != MySQL
+}
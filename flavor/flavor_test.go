@@ -0,0 +1,24 @@
+package flavor
+
+import (
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		version, comment string
+		expected         Flavor
+	}{
+		{"8.0.36", "MySQL Community Server - GPL", MySQL},
+		{"10.11.6-MariaDB", "MariaDB Server", MySQL},
+		{"8.0.11-TiDB-v7.5.0", "TiDB Server (Apache License 2.0) Community Edition", TiDB},
+		{"5.7.9-vitess-17.0.0", "", Vitess},
+		{"8.0.30", "Version: 18.0.0 (Git revision abc) built by Vitess", Vitess},
+	}
+
+	for _, test := range tests {
+		if got := Detect(test.version, test.comment); got != test.expected {
+			t.Errorf("Detect(%q, %q) = %v, want %v", test.version, test.comment, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package follow_thread contains the library routines for collecting the
+// current statement, stage and wait of a single connection from
+// performance_schema.threads and the events_*_current tables.
+package follow_thread
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
+)
+
+// maxEvents is the number of events remembered
+const maxEvents = 500
+
+// the kinds of event collected, in the order they nest
+const (
+	kindStatement = "statement"
+	kindStage     = "stage"
+	kindWait      = "wait"
+)
+
+// thread describes the connection being followed
+type thread struct {
+	threadID uint64
+	user     string
+	host     string
+	db       string
+	command  string
+	state    string
+}
+
+// Row contains an event of the thread
+type Row struct {
+	seen      time.Time // when the event was first collected
+	kind      string    // statement, stage or wait
+	eventID   uint64
+	name      string // statement text, stage or wait name
+	timerWait uint64
+	running   bool
+}
+
+// Rows contains the events in the order they were seen
+type Rows []Row
+
+// events of each kind and how to select the current one of the thread
+var currentEvents = []struct {
+	kind string
+	sql  string
+}{
+	{kindStatement, `
+SELECT	EVENT_ID,
+	COALESCE(SQL_TEXT, ''),
+	COALESCE(TIMER_WAIT, 0),
+	END_EVENT_ID IS NULL
+FROM	events_statements_current
+WHERE	THREAD_ID = ?`},
+	{kindStage, `
+SELECT	EVENT_ID,
+	EVENT_NAME,
+	COALESCE(TIMER_WAIT, 0),
+	END_EVENT_ID IS NULL
+FROM	events_stages_current
+WHERE	THREAD_ID = ?`},
+	{kindWait, `
+SELECT	EVENT_ID,
+	CONCAT(EVENT_NAME, COALESCE(CONCAT(' ', OBJECT_SCHEMA, '.', OBJECT_NAME), '')),
+	COALESCE(TIMER_WAIT, 0),
+	END_EVENT_ID IS NULL
+FROM	events_waits_current
+WHERE	THREAD_ID = ?`},
+}
+
+// selectThread returns the thread of the connection with the given
+// processlist id and false if the connection has gone
+func selectThread(dbh *sql.DB, id uint64) (thread, bool, error) {
+	var t thread
+	var db, state sql.NullString
+
+	query := `
+SELECT	THREAD_ID,
+	COALESCE(PROCESSLIST_USER, ''),
+	COALESCE(PROCESSLIST_HOST, ''),
+	PROCESSLIST_DB,
+	COALESCE(PROCESSLIST_COMMAND, ''),
+	PROCESSLIST_STATE
+FROM	threads
+WHERE	PROCESSLIST_ID = ?`
+
+	err := dbh.QueryRow(query, id).Scan(&t.threadID, &t.user, &t.host, &db, &t.command, &state)
+	switch {
+	case err == sql.ErrNoRows:
+		return t, false, nil
+	case err != nil:
+		return t, false, err
+	}
+	t.user = anonymiser.Anonymise("user", t.user)
+	t.host = anonymiser.Anonymise("host", t.host)
+	t.db = anonymiser.Anonymise("db", db.String)
+	t.state = state.String
+
+	return t, true, nil
+}
+
+// selectEvents returns the current statement, stage and wait of the thread
+func selectEvents(dbh *sql.DB, threadID uint64, seen time.Time) (Rows, error) {
+	var t Rows
+
+	for _, current := range currentEvents {
+		rows, err := dbh.Query(current.sql, threadID)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			r := Row{seen: seen, kind: current.kind}
+			if err := rows.Scan(&r.eventID, &r.name, &r.timerWait, &r.running); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			r.name = strings.Join(strings.Fields(r.name), " ")
+			if r.name != "" {
+				t = append(t, r)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// merge adds the events which haven't been seen before and updates the
+// latency of those which have, keeping at most maxEvents events. Events
+// which are no longer current have finished.
+func (rows Rows) merge(current Rows) Rows {
+	rows.stop()
+	for _, r := range current {
+		found := false
+		for i := len(rows) - 1; i >= 0 && !found; i-- {
+			if rows[i].kind == r.kind && rows[i].eventID == r.eventID {
+				rows[i].timerWait = r.timerWait
+				rows[i].running = r.running
+				found = true
+			}
+		}
+		if !found {
+			rows = append(rows, r)
+		}
+	}
+	if len(rows) > maxEvents {
+		rows = rows[len(rows)-maxEvents:]
+	}
+
+	return rows
+}
+
+// stop marks any events still running as finished, e.g. when the
+// connection has gone
+func (rows Rows) stop() {
+	for i := range rows {
+		rows[i].running = false
+	}
+}
+
+// totals returns the number of events of each kind and the statement latency
+func (rows Rows) totals() (map[string]int, uint64) {
+	counts := make(map[string]int)
+	var latency uint64
+
+	for i := range rows {
+		counts[rows[i].kind]++
+		if rows[i].kind == kindStatement {
+			latency += rows[i].timerWait
+		}
+	}
+
+	return counts, latency
+}
+
+// headings returns the headings for the view
+func (row *Row) headings() string {
+	return fmt.Sprintf("%8s %-9s %10s %-7s|%s", "Seen", "Type", "Latency", "State", "Event")
+}
+
+// generate a printable result
+func (row *Row) rowContent() string {
+	seen, state := "", ""
+	if !row.seen.IsZero() {
+		seen = lib.InTimezone(row.seen).Format("15:04:05")
+		state = "done"
+		if row.running {
+			state = "running"
+		}
+	}
+
+	return fmt.Sprintf("%8s %-9s %10s %-7s|%s",
+		seen,
+		row.kind,
+		lib.FormatTime(row.timerWait),
+		state,
+		row.name)
+}
@@ -0,0 +1,129 @@
+// Package follow_thread follows a single connection, showing the
+// statements, stages and waits it runs until it disconnects.
+package follow_thread
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the events seen of the connection being followed
+type Object struct {
+	baseobject.BaseObject        // embedded
+	id                    uint64 // processlist id of the connection
+	thread                thread // last details collected of the connection
+	connected             bool   // false once the connection has gone
+	events                Rows   // events in the order they were seen
+}
+
+// NewFollowThread returns a pointer to an object following the
+// connection with the given processlist id
+func NewFollowThread(ctx *context.Context, id uint64) *Object {
+	logger.Println("NewFollowThread(", id, ")")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.id = id
+	o.connected = true
+
+	return o
+}
+
+// Collect collects the current events of the connection and adds them
+// to those seen before. Nothing more is collected once it has gone.
+func (t *Object) Collect(dbh *sql.DB) error {
+	if !t.connected {
+		return nil
+	}
+	start := time.Now()
+
+	thread, connected, err := selectThread(dbh, t.id)
+	if err != nil {
+		return err
+	}
+	if !connected {
+		logger.Println("follow_thread.Object.Collect() connection", t.id, "has gone")
+		t.connected = false
+		t.events.stop()
+		t.SetLastCollectTimeNow()
+		return nil
+	}
+
+	events, err := selectEvents(dbh, thread.threadID, start)
+	if err != nil {
+		return err
+	}
+	t.thread = thread
+	t.events = t.events.merge(events)
+	t.SetLastCollectTimeNow()
+
+	logger.Println("follow_thread.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns the events, most recent first
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.events))
+
+	for i := len(t.events) - 1; i >= 0; i-- {
+		rows = append(rows, t.events[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the number of events of each kind seen
+func (t Object) TotalRowContent() string {
+	counts, latency := t.events.totals()
+	totals := Row{
+		name: fmt.Sprintf("Totals: %d statement(s), %d stage(s), %d wait(s)",
+			counts[kindStatement], counts[kindStage], counts[kindWait]),
+		timerWait: latency,
+	}
+
+	return totals.rowContent()
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// Description describes the connection being followed
+func (t Object) Description() string {
+	if !t.connected {
+		return fmt.Sprintf("Following connection %d: disconnected (f: stop following)", t.id)
+	}
+
+	return fmt.Sprintf("Following connection %d: %s@%s db: %s %s %s (f: stop following)",
+		t.id, t.thread.user, t.thread.host, t.thread.db, t.thread.command, t.thread.state)
+}
+
+// Len returns the number of events
+func (t Object) Len() int {
+	return len(t.events)
+}
+
+// HaveRelativeStats is false as we show the events as they happen
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("follow_thread.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
@@ -3,8 +3,11 @@ package global
 
 import (
 	"database/sql"
+	"strconv"
+	"strings"
 
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
 )
 
 func selectStatusFrom(seenError bool) string {
@@ -63,3 +66,63 @@ func (status *Status) Get(name string) int {
 
 	return value
 }
+
+// StatusValues holds numeric status values indexed by lower-cased name
+type StatusValues map[string]uint64
+
+// Values returns the numeric status values whose names start with any of
+// the given prefixes. Non-numeric values are ignored.
+func (status *Status) Values(prefixes ...string) (StatusValues, error) {
+	values := make(StatusValues)
+	if len(prefixes) == 0 {
+		return values, nil
+	}
+
+	conditions := make([]string, len(prefixes))
+	args := make([]interface{}, len(prefixes))
+	for i := range prefixes {
+		conditions[i] = "VARIABLE_NAME LIKE ?"
+		args[i] = prefixes[i] + "%"
+	}
+	query := "SELECT VARIABLE_NAME, VARIABLE_VALUE FROM " + selectStatusFrom(seenCompatibiltyError) + " WHERE " + strings.Join(conditions, " OR ")
+
+	rows, err := status.dbh.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if number, err := strconv.ParseUint(value, 10, 64); err == nil {
+			values[strings.ToLower(name)] = number
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// NeedsRefresh returns true if any counter has gone backwards since the
+// initial values were taken, e.g. after FLUSH STATUS. The gauges, given
+// by their lower-cased names, are ignored as they go down all the time.
+func (values StatusValues) NeedsRefresh(initial StatusValues, gauges map[string]bool) bool {
+	for name, value := range values {
+		if !gauges[name] && initial[name] > value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Subtract returns the values less those in initial. Values which have
+// gone backwards (e.g. after FLUSH STATUS) keep their values since then.
+func (values StatusValues) Subtract(initial StatusValues) StatusValues {
+	return relative_stats.SubtractValues(values, initial)
+}
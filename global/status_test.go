@@ -0,0 +1,25 @@
+package global
+
+import (
+	"testing"
+)
+
+func TestStatusValuesNeedsRefresh(t *testing.T) {
+	gauges := map[string]bool{"open_tables": true}
+	initial := StatusValues{"opened_tables": 100, "open_tables": 50}
+
+	tests := []struct {
+		current StatusValues
+		want    bool
+	}{
+		{StatusValues{"opened_tables": 120, "open_tables": 60}, false},
+		{StatusValues{"opened_tables": 120, "open_tables": 10}, false}, // a gauge went down
+		{StatusValues{"opened_tables": 5, "open_tables": 60}, true},    // FLUSH STATUS
+		{StatusValues{"opened_tables": 120, "open_tables": 60, "new_counter": 1}, false},
+	}
+	for _, test := range tests {
+		if got := test.current.NeedsRefresh(initial, gauges); got != test.want {
+			t.Errorf("%v.NeedsRefresh(%v) expected %v but got %v", test.current, initial, test.want, got)
+		}
+	}
+}
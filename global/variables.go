@@ -11,8 +11,17 @@ import (
 const (
 	showCompatibility56Error    = "Error 3167: The 'INFORMATION_SCHEMA.GLOBAL_VARIABLES' feature is disabled; see the documentation for 'show_compatibility_56'"
 	globalVariablesNotInISError = "Error 1109: Unknown table 'GLOBAL_VARIABLES' in information_schema"
+	tableDoesNotExistError      = "Error 1146:" // e.g. on TiDB and Vitess which only provide P_S
 )
 
+// isCompatibilityError returns true if the error tells us to use P_S
+// rather than I_S to query the global variables and status
+func isCompatibilityError(err error) bool {
+	return err.Error() == showCompatibility56Error ||
+		err.Error() == globalVariablesNotInISError ||
+		strings.HasPrefix(err.Error(), tableDoesNotExistError)
+}
+
 // We expect to use I_S to query Global Variables. 5.7 now wants us to use P_S,
 // so this variable will be changed if we see the show_compatibility_56 error message
 var seenCompatibiltyError = false
@@ -63,7 +72,7 @@ func (v *Variables) selectAll() {
 
 	rows, err := v.dbh.Query(query)
 	if err != nil {
-		if !seenCompatibiltyError && (err.Error() == showCompatibility56Error || err.Error() == globalVariablesNotInISError) {
+		if !seenCompatibiltyError && isCompatibilityError(err) {
 			logger.Println("selectAll() I_S query failed, trying with P_S")
 			seenCompatibiltyError = true
 			query = "SELECT VARIABLE_NAME, VARIABLE_VALUE FROM " + selectVariablesFrom(seenCompatibiltyError)
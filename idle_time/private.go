@@ -0,0 +1,249 @@
+package idle_time
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+/**************************************************************************
+
+The idle instrument (enabled and timed by default) times the waits of a
+connection for the client to send a statement, so it is the client's
+"think time" plus the network round trip. It is counted in the summary
+when the wait ends, i.e. when the next statement arrives.
+
+mysql> SELECT * FROM events_waits_summary_by_thread_by_event_name WHERE EVENT_NAME = 'idle' LIMIT 1\G
+*************************** 1. row ***************************
+     THREAD_ID: 51
+    EVENT_NAME: idle
+    COUNT_STAR: 1502
+SUM_TIMER_WAIT: 94812738000000
+MIN_TIMER_WAIT: 2140000
+AVG_TIMER_WAIT: 63124326000
+MAX_TIMER_WAIT: 10002371000000
+1 row in set (0.00 sec)
+
+The statements of stored programs (statement/sp/...) are left out of
+the busy time as they run inside the CALL which is already counted.
+
+**************************************************************************/
+
+// Row contains the idle and busy time of a connection
+type Row struct {
+	id         uint64 // processlist id
+	user       string
+	host       string
+	idle       uint64 // time waiting for the client
+	busy       uint64 // time executing statements
+	statements uint64 // statements executed
+}
+
+// Rows contains a slice of Rows
+type Rows []Row
+
+// name identifies the row
+func (row Row) name() string {
+	if row.host == "" {
+		return row.user
+	}
+
+	return row.user + "@" + row.host
+}
+
+// select the idle and busy time of each connection
+func selectRows(dbh *sql.DB) (Rows, error) {
+	var t Rows
+
+	logger.Println("idle_time.selectRows()")
+	query := `
+SELECT	t.PROCESSLIST_ID,
+	t.PROCESSLIST_USER,
+	t.PROCESSLIST_HOST,
+	COALESCE(w.SUM_TIMER_IDLE, 0),
+	COALESCE(s.SUM_TIMER_BUSY, 0),
+	COALESCE(s.COUNT_STATEMENTS, 0)
+FROM	threads t
+LEFT JOIN (
+	SELECT	THREAD_ID, SUM_TIMER_WAIT AS SUM_TIMER_IDLE
+	FROM	events_waits_summary_by_thread_by_event_name
+	WHERE	EVENT_NAME = 'idle'
+) w ON (w.THREAD_ID = t.THREAD_ID)
+LEFT JOIN (
+	SELECT	THREAD_ID, SUM(SUM_TIMER_WAIT) AS SUM_TIMER_BUSY, SUM(COUNT_STAR) AS COUNT_STATEMENTS
+	FROM	events_statements_summary_by_thread_by_event_name
+	WHERE	EVENT_NAME NOT LIKE 'statement/sp/%'
+	GROUP BY THREAD_ID
+) s ON (s.THREAD_ID = t.THREAD_ID)
+WHERE	t.TYPE = 'FOREGROUND'
+AND	t.PROCESSLIST_ID IS NOT NULL`
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		var user, host sql.NullString
+		if err := rows.Scan(
+			&r.id,
+			&user,
+			&host,
+			&r.idle,
+			&r.busy,
+			&r.statements); err != nil {
+			return nil, err
+		}
+		r.user = anonymiser.Anonymise("user", user.String)
+		r.host = anonymiser.Anonymise("host", host.String)
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// differ matches the rows by the connection's processlist id to
+// subtract their initial values
+var differ = relative_stats.Differ[Row, uint64]{
+	Key:      func(row Row) uint64 { return row.id },
+	Counters: func(row Row) []uint64 { return []uint64{row.idle, row.busy, row.statements} },
+	Subtract: func(row *Row, other Row) {
+		row.idle -= other.idle
+		row.busy -= other.busy
+		row.statements -= other.statements
+	},
+}
+
+// subtract removes the initial values of each connection which was
+// already there then
+func (rows Rows) subtract(initial Rows) {
+	differ.SubtractRows(rows, initial)
+}
+
+// totals returns the totals of all the rows
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.user = "Totals"
+
+	for i := range rows {
+		totals.idle += rows[i].idle
+		totals.busy += rows[i].busy
+		totals.statements += rows[i].statements
+	}
+
+	return totals
+}
+
+// idlePct returns the share of the time the connection was idle
+func (row Row) idlePct() float64 {
+	return lib.MyDivide(row.idle, row.idle+row.busy)
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"idle": func(i, j int) int { return sort_keys.Descending(rows[i].idle, rows[j].idle) },
+		"busy": func(i, j int) int { return sort_keys.Descending(rows[i].busy, rows[j].busy) },
+		"ops":  func(i, j int) int { return sort_keys.Descending(rows[i].statements, rows[j].statements) },
+		"id":   func(i, j int) int { return sort_keys.Descending(rows[j].id, rows[i].id) }, // ascending
+		"user": func(i, j int) int { return sort_keys.Ascending(rows[i].user, rows[j].user) },
+		"name": func(i, j int) int { return sort_keys.Ascending(rows[i].name(), rows[j].name()) },
+	}
+}
+
+// sort by the time idle (descending), then by the time busy (descending)
+// and by "name" (ascending) after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("idle_time", "idle", "busy", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("idle_time", Rows(nil).sortKeys())
+}
+
+// average returns the average time per statement (if any)
+func average(sumTimer, count uint64) string {
+	if count == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(sumTimer / count)
+}
+
+// idle time headings
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %10s %8s %10s %10s %7s|%s",
+		"Idle", "Idle%", "Busy", "Stmts", "Avg Busy", "Avg Idle", "Id", "User@Host")
+}
+
+// generate a printable result, the averages being per statement so
+// the average idle time is the client's think time between statements
+func (row *Row) rowContent() string {
+	id, pct := "", ""
+	if row.idle+row.busy > 0 {
+		pct = lib.FormatPct(row.idlePct())
+	}
+	if row.id > 0 {
+		id = fmt.Sprint(row.id)
+	}
+
+	return fmt.Sprintf("%10s %6s %10s %8s %10s %10s %7s|%s",
+		lib.FormatTime(row.idle),
+		pct,
+		lib.FormatTime(row.busy),
+		lib.FormatAmount(row.statements),
+		average(row.busy, row.statements),
+		average(row.idle, row.statements),
+		id,
+		row.name())
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10s %10s %10s %d %s",
+		lib.FormatTime(row.idle),
+		lib.FormatTime(row.busy),
+		lib.FormatAmount(row.statements),
+		row.id,
+		row.name())
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row, the statements
+// being count_star like the events of the other views
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: fmt.Sprintf("%d %s", row.id, row.name()),
+		Values: map[string]uint64{
+			"count_star":     row.statements,
+			"sum_timer_idle": row.idle,
+			"sum_timer_busy": row.busy,
+		},
+	}
+}
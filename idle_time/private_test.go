@@ -0,0 +1,35 @@
+package idle_time
+
+import (
+	"testing"
+)
+
+func TestSubtract(t *testing.T) {
+	initial := Rows{
+		{id: 10, user: "app", host: "web1", idle: 9000, busy: 1000, statements: 50},
+		{id: 11, user: "app", host: "web2", idle: 500, busy: 500, statements: 20},
+	}
+	current := Rows{
+		{id: 10, user: "app", host: "web1", idle: 9900, busy: 1100, statements: 60},
+		{id: 12, user: "report", host: "localhost", idle: 100, busy: 4000, statements: 2}, // new
+	}
+
+	current.subtract(initial)
+	want := Rows{
+		{id: 10, user: "app", host: "web1", idle: 900, busy: 100, statements: 10},
+		{id: 12, user: "report", host: "localhost", idle: 100, busy: 4000, statements: 2},
+	}
+	for i := range want {
+		if current[i] != want[i] {
+			t.Errorf("subtract()[%d] = %+v, want %+v", i, current[i], want[i])
+		}
+	}
+
+	totals := current.totals()
+	if totals.idle != 1000 || totals.busy != 4100 || totals.statements != 12 {
+		t.Errorf("totals() = %+v, want idle 1000, busy 4100 and 12 statements", totals)
+	}
+	if pct := totals.idlePct(); pct < 0.19 || pct > 0.2 {
+		t.Errorf("idlePct() = %v, want about 0.196", pct)
+	}
+}
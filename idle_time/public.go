@@ -0,0 +1,164 @@
+// Package idle_time shows the time each connection spent idle, waiting
+// for the client to send its next statement, against the time spent
+// executing statements. The idle instrument times the first so a
+// connection which is mostly idle points at the client or network being
+// slow rather than the server.
+package idle_time
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Object provides a public view of object
+type Object struct {
+	baseobject.BaseObject      // embedded
+	initial               Rows // initial data for relative values
+	mark                  Rows // marked data for relative values since the mark
+	current               Rows // last loaded values
+	results               Rows // results (maybe with subtraction)
+	totals                Row  // totals of results
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// NewIdleTime returns a pointer to an object of this type
+func NewIdleTime(ctx *context.Context) *Object {
+	logger.Println("NewIdleTime()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects data from the db, updating initial values if
+// needed, and then subtracting initial values if we want relative
+// values, after which it stores totals. Connections come and go so the
+// values are compared per connection rather than checking whether the
+// totals went backwards.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if t.InitialCollectTime().IsZero() {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("idle_time.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings of the object
+func (t *Object) Headings() string {
+	return t.totals.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent())
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// TotalRowContent returns a row containing the totals
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent()
+}
+
+// Description describes the idle and busy time of the connections
+func (t Object) Description() string {
+	return fmt.Sprintf("Idle vs Busy by connection (events_waits_summary_by_thread_by_event_name) %d connection(s), %s idle",
+		len(t.results), strings.TrimSpace(lib.FormatPct(t.totals.idlePct())))
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	t.results = append(Rows{}, t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+
+	t.results.sort()
+	t.totals = t.results.totals()
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
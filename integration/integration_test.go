@@ -0,0 +1,324 @@
+//go:build integration
+// +build integration
+
+// Package integration runs each collector against real servers started
+// with docker, to catch queries or columns which don't work with one of
+// the versions ps-top supports. Run it with:
+//
+//	go test -tags integration ./integration/
+//
+// PSTOP_TEST_IMAGES may give a comma separated list of images to use
+// instead of the default matrix.
+package integration
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/sjmudd/ps-top/binlog_events"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/ddl_progress"
+	fsbi "github.com/sjmudd/ps-top/file_io_latency"
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/idle_time"
+	"github.com/sjmudd/ps-top/key_cache"
+	"github.com/sjmudd/ps-top/lock_users"
+	"github.com/sjmudd/ps-top/lock_waits"
+	"github.com/sjmudd/ps-top/memory_usage"
+	ewsgben "github.com/sjmudd/ps-top/mutex_latency"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/prepared_statements"
+	"github.com/sjmudd/ps-top/program_latency"
+	"github.com/sjmudd/ps-top/ps_sizing"
+	"github.com/sjmudd/ps-top/query_cache"
+	"github.com/sjmudd/ps-top/setup_instruments"
+	"github.com/sjmudd/ps-top/slo_budget"
+	essgben "github.com/sjmudd/ps-top/stages_latency"
+	"github.com/sjmudd/ps-top/statement_stages"
+	"github.com/sjmudd/ps-top/statements_digest"
+	"github.com/sjmudd/ps-top/table_cache"
+	tiwsbt "github.com/sjmudd/ps-top/table_io_latency"
+	tlwsbt "github.com/sjmudd/ps-top/table_lock_latency"
+	"github.com/sjmudd/ps-top/unused_indexes"
+	"github.com/sjmudd/ps-top/user_latency"
+	"github.com/sjmudd/ps-top/view"
+	"github.com/sjmudd/ps-top/wait_events"
+)
+
+const (
+	imagesEnv    = "PSTOP_TEST_IMAGES" // images to test instead of defaultImages
+	imageEnv     = "PSTOP_TEST_IMAGE"  // image tested by the child process
+	startTimeout = 3 * time.Minute     // time allowed for a server to accept connections
+	testSchema   = "ps_top_test"
+)
+
+// the servers ps-top is expected to work with
+var defaultImages = []string{"mysql:5.6", "mysql:5.7", "mysql:8.0", "mariadb:10.11"}
+
+// the collectors to check and the view which tells if their table is there
+var collectors = []struct {
+	code view.Code
+	new  func(ctx *context.Context) ps_table.Tabler
+}{
+	{view.ViewLatency, func(ctx *context.Context) ps_table.Tabler { return tiwsbt.NewTableIoLatency(ctx) }},
+	{view.ViewIO, func(ctx *context.Context) ps_table.Tabler { return fsbi.NewFileSummaryByInstance(ctx) }},
+	{view.ViewLocks, func(ctx *context.Context) ps_table.Tabler { return tlwsbt.NewTableLockLatency(ctx) }},
+	{view.ViewUsers, func(ctx *context.Context) ps_table.Tabler { return user_latency.NewUserLatency(ctx) }},
+	{view.ViewMutex, func(ctx *context.Context) ps_table.Tabler { return ewsgben.NewMutexLatency(ctx) }},
+	{view.ViewStages, func(ctx *context.Context) ps_table.Tabler { return essgben.NewStagesLatency(ctx) }},
+	{view.ViewMemory, func(ctx *context.Context) ps_table.Tabler { return memory_usage.NewMemoryUsage(ctx) }},
+	{view.ViewLockWaits, func(ctx *context.Context) ps_table.Tabler { return lock_waits.NewLockWaits(ctx) }},
+	{view.ViewEfficiency, func(ctx *context.Context) ps_table.Tabler { return statements_digest.NewStatementsDigest(ctx) }},
+	{view.ViewTableCache, func(ctx *context.Context) ps_table.Tabler { return table_cache.NewTableCache(ctx) }},
+	{view.ViewKeyCache, func(ctx *context.Context) ps_table.Tabler { return key_cache.NewKeyCache(ctx) }},
+	{view.ViewQueryCache, func(ctx *context.Context) ps_table.Tabler { return query_cache.NewQueryCache(ctx) }},
+	{view.ViewStmtStages, func(ctx *context.Context) ps_table.Tabler { return statement_stages.NewStatementStages(ctx) }},
+	{view.ViewUnusedIdx, func(ctx *context.Context) ps_table.Tabler { return unused_indexes.NewUnusedIndexes(ctx) }},
+	{view.ViewBinlog, func(ctx *context.Context) ps_table.Tabler { return binlog_events.NewBinlogEvents(ctx) }},
+	{view.ViewDDL, func(ctx *context.Context) ps_table.Tabler { return ddl_progress.NewDDLProgress(ctx) }},
+	{view.ViewLockUsers, func(ctx *context.Context) ps_table.Tabler { return lock_users.NewLockUsers(ctx) }},
+	{view.ViewPSSizing, func(ctx *context.Context) ps_table.Tabler { return ps_sizing.NewPSSizing(ctx) }},
+	{view.ViewPrograms, func(ctx *context.Context) ps_table.Tabler { return program_latency.NewProgramLatency(ctx) }},
+	{view.ViewSLO, func(ctx *context.Context) ps_table.Tabler {
+		return slo_budget.NewSLOBudget(ctx, tiwsbt.NewTableIoLatency(ctx))
+	}},
+	{view.ViewPrepared, func(ctx *context.Context) ps_table.Tabler { return prepared_statements.NewPreparedStatements(ctx) }},
+	{view.ViewWaits, func(ctx *context.Context) ps_table.Tabler { return wait_events.NewWaitEvents(ctx) }},
+	{view.ViewIdle, func(ctx *context.Context) ps_table.Tabler { return idle_time.NewIdleTime(ctx) }},
+}
+
+// TestMatrix runs TestCollectors for each image in a process of its own
+// as the collectors cache what they find out about the server they use.
+func TestMatrix(t *testing.T) {
+	if os.Getenv(imageEnv) != "" {
+		return
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is needed for the integration tests:", err)
+	}
+	images := defaultImages
+	if list := os.Getenv(imagesEnv); list != "" {
+		images = strings.Split(list, ",")
+	}
+	home, err := ioutil.TempDir("", "ps-top-integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	for _, image := range images {
+		image := strings.TrimSpace(image)
+		t.Run(image, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestCollectors$", "-test.v")
+			// an empty HOME so ~/.pstoprc doesn't change what is collected
+			cmd.Env = append(os.Environ(), imageEnv+"="+image, "HOME="+home)
+			out, err := cmd.CombinedOutput()
+			t.Log(string(out))
+			if err != nil {
+				t.Fatal(image, "failed:", err)
+			}
+		})
+	}
+}
+
+// TestCollectors collects each view twice from the server of the image
+// given by the parent process with some activity in between.
+func TestCollectors(t *testing.T) {
+	image := os.Getenv(imageEnv)
+	if image == "" {
+		t.Skip("run by TestMatrix")
+	}
+	dbh, stop := startServer(t, image)
+	defer stop()
+
+	si := setup_instruments.NewSetupInstruments(dbh)
+	si.EnableMonitoring()
+	if err := view.ValidateViews(dbh, nil); err != nil { // no ProxySQL to check the proxy views on
+		t.Fatal(err)
+	}
+	ctx := context.NewContext(global.NewStatus(dbh), global.NewVariables(dbh))
+	ctx.SetWantRelativeStats(true)
+
+	tables := make([]ps_table.Tabler, len(collectors))
+	before := make([][]ps_table.RowValues, len(collectors))
+	for i, c := range collectors {
+		if !view.IsSelectable(c.code) {
+			t.Logf("%s: not available on %s", c.code, image)
+			continue
+		}
+		tables[i] = c.new(ctx)
+		if err := tables[i].Collect(dbh); err != nil {
+			t.Errorf("%s: first collection failed: %v", c.code, err)
+			tables[i] = nil
+			continue
+		}
+		tables[i].SetInitialFromCurrent()
+		before[i] = valuesOf(tables[i])
+	}
+
+	workload(t, dbh)
+
+	for i, c := range collectors {
+		if tables[i] == nil {
+			continue
+		}
+		if err := tables[i].Collect(dbh); err != nil {
+			t.Errorf("%s: second collection failed: %v", c.code, err)
+			continue
+		}
+		checkOutput(t, c.code, tables[i])
+		checkCounters(t, c.code, before[i], valuesOf(tables[i]))
+	}
+
+	if tables[0] != nil {
+		checkWorkloadSeen(t, tables[0])
+	}
+}
+
+// startServer starts a container of the image and returns a connection
+// to it once it accepts them, and the function which removes it.
+func startServer(t *testing.T, image string) (*sql.DB, func()) {
+	out, err := exec.Command("docker", "run", "--detach", "--rm", "--publish-all",
+		"--env", "MYSQL_ALLOW_EMPTY_PASSWORD=yes",
+		"--env", "MARIADB_ALLOW_EMPTY_ROOT_PASSWORD=yes",
+		image,
+		"--performance-schema=ON", "--log-bin=binlog", "--server-id=1").Output()
+	if err != nil {
+		t.Fatal("unable to start", image, ":", err)
+	}
+	id := strings.TrimSpace(string(out))
+	remove := func() { exec.Command("docker", "rm", "--force", id).Run() }
+
+	out, err = exec.Command("docker", "port", id, "3306/tcp").Output()
+	if err != nil {
+		remove()
+		t.Fatal("unable to find the port of", image, ":", err)
+	}
+	address := strings.Fields(string(out))[0]
+	address = strings.Replace(address, "0.0.0.0:", "127.0.0.1:", 1)
+
+	dbh, err := sql.Open("mysql", "root@tcp("+address+")/performance_schema")
+	if err != nil {
+		remove()
+		t.Fatal(err)
+	}
+	stop := func() {
+		dbh.Close()
+		remove()
+	}
+
+	// the images start a server without networking to initialise the
+	// data directory, so this waits for the real one
+	deadline := time.Now().Add(startTimeout)
+	for err = dbh.Ping(); err != nil; err = dbh.Ping() {
+		if time.Now().After(deadline) {
+			stop()
+			t.Fatal(image, "did not accept connections in", startTimeout, ":", err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	return dbh, stop
+}
+
+// workload creates, reads and changes a table so there are changes to see
+func workload(t *testing.T, dbh *sql.DB) {
+	statements := []string{
+		"CREATE DATABASE IF NOT EXISTS " + testSchema,
+		"CREATE TABLE IF NOT EXISTS " + testSchema + ".t (id INT NOT NULL PRIMARY KEY, v VARCHAR(20), KEY (v)) ENGINE=InnoDB",
+	}
+	for i := 0; i < 100; i++ {
+		statements = append(statements, fmt.Sprintf("INSERT INTO %s.t VALUES (%d, 'row %d')", testSchema, i, i))
+	}
+	statements = append(statements,
+		"UPDATE "+testSchema+".t SET v = CONCAT(v, ' updated') WHERE id < 50",
+		"DELETE FROM "+testSchema+".t WHERE id >= 90",
+		"ALTER TABLE "+testSchema+".t ADD COLUMN c INT",
+		"OPTIMIZE TABLE "+testSchema+".t",
+	)
+	for _, statement := range statements {
+		if _, err := dbh.Exec(statement); err != nil {
+			t.Fatal(statement, ":", err)
+		}
+	}
+
+	var count int
+	if err := dbh.QueryRow("SELECT COUNT(*) FROM " + testSchema + ".t WHERE v LIKE 'row%'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// valuesOf returns a copy of the current values of the table if it has them
+func valuesOf(table ps_table.Tabler) []ps_table.RowValues {
+	valuer, ok := table.(ps_table.Valuer)
+	if !ok {
+		return nil
+	}
+
+	var values []ps_table.RowValues
+	for _, row := range valuer.Values() {
+		copied := ps_table.RowValues{Name: row.Name, Values: make(map[string]uint64)}
+		for name, value := range row.Values {
+			copied.Values[name] = value
+		}
+		values = append(values, copied)
+	}
+
+	return values
+}
+
+// checkOutput checks the table can be shown
+func checkOutput(t *testing.T, code view.Code, table ps_table.Tabler) {
+	if table.Headings() == "" {
+		t.Errorf("%s: no headings", code)
+	}
+	if table.Description() == "" {
+		t.Errorf("%s: no description", code)
+	}
+	if table.TotalRowContent() == "" {
+		t.Errorf("%s: no totals", code)
+	}
+}
+
+// checkCounters checks no counter went backwards between the collections,
+// which would show as a huge relative value
+func checkCounters(t *testing.T, code view.Code, before, after []ps_table.RowValues) {
+	previous := make(map[string]map[string]uint64)
+	for _, row := range before {
+		previous[row.Name] = row.Values
+	}
+
+	for _, row := range after {
+		for name, value := range row.Values {
+			if old, found := previous[row.Name][name]; found && value < old {
+				t.Errorf("%s: %s %s went from %d to %d", code, row.Name, name, old, value)
+			}
+		}
+	}
+}
+
+// checkWorkloadSeen checks the relative table I/O shows the workload
+func checkWorkloadSeen(t *testing.T, table ps_table.Tabler) {
+	resulter, ok := table.(ps_table.Resulter)
+	if !ok {
+		t.Fatal("table_io_latency does not provide its results")
+	}
+
+	for _, row := range resulter.Results() {
+		if row.Name == testSchema+".t" {
+			if row.Values["count_insert"] < 100 {
+				t.Errorf("table_io_latency: %d insert(s) seen, expected at least 100", row.Values["count_insert"])
+			}
+			return
+		}
+	}
+	t.Errorf("table_io_latency: %s.t not seen", testSchema)
+}
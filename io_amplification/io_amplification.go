@@ -0,0 +1,154 @@
+// Package io_amplification estimates how much file I/O each table does
+// for the rows it reads and changes, by combining the rows counted in
+// table_io_waits_summary_by_table with the bytes counted for the
+// table's files in file_summary_by_instance and the average row length
+// from information_schema.TABLES. The file I/O includes reading and
+// writing pages for other reasons, e.g. purge or flushing, so the
+// values are estimates.
+package io_amplification
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/schema_filter"
+)
+
+// Headings are the names of the columns of an Estimate
+var Headings = []string{"Avg Row", "Rd B/Row", "Wr B/Row", "Read Amp"}
+
+// Estimate holds the values derived for a table. Values which can't be
+// derived, e.g. as no rows were read, are 0.
+type Estimate struct {
+	RowLength   uint64  // average row length
+	ReadPerRow  uint64  // bytes read from the table's files per row fetched
+	WritePerRow uint64  // bytes written to the table's files per row changed
+	ReadAmp     float64 // bytes read per row fetched over the average row length
+}
+
+// estimate returns the values derived from the counts of a table
+func estimate(table, file map[string]uint64, rowLength uint64) Estimate {
+	e := Estimate{RowLength: rowLength}
+	fetched := table["count_fetch"]
+	changed := table["count_insert"] + table["count_update"] + table["count_delete"]
+
+	if fetched > 0 {
+		e.ReadPerRow = file["sum_number_of_bytes_read"] / fetched
+		if rowLength > 0 {
+			e.ReadAmp = float64(file["sum_number_of_bytes_read"]) / float64(fetched*rowLength)
+		}
+	}
+	if changed > 0 {
+		e.WritePerRow = file["sum_number_of_bytes_write"] / changed
+	}
+
+	return e
+}
+
+// Estimates returns the estimate of each table by name, and of the
+// tables together, from the table I/O and file I/O of the same period.
+// Tables without file I/O, e.g. those in the shared tablespace, are
+// left out of the total.
+func Estimates(tableIO, fileIO []ps_table.RowValues, rowLengths map[string]uint64) (map[string]Estimate, Estimate) {
+	files := make(map[string]map[string]uint64, len(fileIO))
+	for _, row := range fileIO {
+		files[row.Name] = row.Values
+	}
+
+	estimates := make(map[string]Estimate, len(tableIO))
+	table, file := make(map[string]uint64), make(map[string]uint64)
+	var rowBytes, rows uint64 // to average the row length over the rows fetched
+	for _, row := range tableIO {
+		values, found := files[row.Name]
+		estimates[row.Name] = estimate(row.Values, values, rowLengths[row.Name])
+		if !found {
+			continue
+		}
+		for name, value := range row.Values {
+			table[name] += value
+		}
+		for name, value := range values {
+			file[name] += value
+		}
+		if rowLengths[row.Name] > 0 {
+			rowBytes += rowLengths[row.Name] * row.Values["count_fetch"]
+			rows += row.Values["count_fetch"]
+		}
+	}
+
+	var rowLength uint64
+	if rows > 0 {
+		rowLength = rowBytes / rows
+	}
+
+	return estimates, estimate(table, file, rowLength)
+}
+
+// Columns returns the formatted values of the estimate
+func (e Estimate) Columns() []string {
+	readAmp := ""
+	if e.ReadAmp > 0 {
+		readAmp = fmt.Sprintf("%.1fx", e.ReadAmp)
+	}
+
+	return []string{
+		lib.FormatAmount(e.RowLength),
+		lib.FormatAmount(e.ReadPerRow),
+		lib.FormatAmount(e.WritePerRow),
+		readAmp,
+	}
+}
+
+// refreshInterval is how often the average row lengths are collected
+// as they change slowly and information_schema.TABLES may be slow to query
+const refreshInterval = time.Minute
+
+// RowLengths holds the average row length of each table
+type RowLengths struct {
+	lengths   map[string]uint64
+	collected time.Time
+}
+
+// Get returns the average row length of each table by name, collecting
+// them if not done in the last minute
+func (r *RowLengths) Get(dbh *sql.DB, schemas *schema_filter.Filter) (map[string]uint64, error) {
+	if r.lengths != nil && time.Since(r.collected) < refreshInterval {
+		return r.lengths, nil
+	}
+
+	query := `SELECT TABLE_SCHEMA, TABLE_NAME, AVG_ROW_LENGTH FROM information_schema.TABLES
+WHERE TABLE_TYPE = 'BASE TABLE'
+AND TABLE_SCHEMA NOT IN ('mysql', 'sys', 'information_schema', 'performance_schema')`
+	condition, args := schemas.And("TABLE_SCHEMA", "TABLE_NAME")
+
+	rows, err := dbh.Query(query+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lengths := make(map[string]uint64)
+	for rows.Next() {
+		var schema, table string
+		var length sql.NullInt64
+		if err := rows.Scan(&schema, &table, &length); err != nil {
+			return nil, err
+		}
+		if length.Valid && length.Int64 > 0 {
+			lengths[lib.TableName(schema, table)] = uint64(length.Int64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("io_amplification: collected the average row length of", len(lengths), "table(s)")
+
+	r.lengths = lengths
+	r.collected = time.Now()
+
+	return lengths, nil
+}
@@ -0,0 +1,34 @@
+package io_amplification
+
+import (
+	"testing"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestEstimates(t *testing.T) {
+	tableIO := []ps_table.RowValues{
+		{Name: "a.t1", Values: map[string]uint64{"count_fetch": 100, "count_insert": 10, "count_update": 5, "count_delete": 5}},
+		{Name: "a.t2", Values: map[string]uint64{"count_fetch": 0, "count_insert": 0}},
+		{Name: "a.t3", Values: map[string]uint64{"count_fetch": 50}}, // no file I/O
+	}
+	fileIO := []ps_table.RowValues{
+		{Name: "a.t1", Values: map[string]uint64{"sum_number_of_bytes_read": 40000, "sum_number_of_bytes_write": 32000}},
+		{Name: "a.t2", Values: map[string]uint64{"sum_number_of_bytes_read": 16384}},
+	}
+	rowLengths := map[string]uint64{"a.t1": 100, "a.t3": 50}
+
+	estimates, total := Estimates(tableIO, fileIO, rowLengths)
+	if e := estimates["a.t1"]; e.RowLength != 100 || e.ReadPerRow != 400 || e.WritePerRow != 1600 || e.ReadAmp != 4 {
+		t.Errorf("Estimates() of a.t1 expected {100 400 1600 4} but got %+v", e)
+	}
+	if e := estimates["a.t2"]; e != (Estimate{}) {
+		t.Errorf("Estimates() of a.t2, without rows, expected nothing but got %+v", e)
+	}
+	if e := estimates["a.t3"]; e.RowLength != 50 || e.ReadPerRow != 0 {
+		t.Errorf("Estimates() of a.t3, without file I/O, expected only the row length but got %+v", e)
+	}
+	if total.RowLength != 100 || total.ReadPerRow != (40000+16384)/100 || total.WritePerRow != 1600 {
+		t.Errorf("Estimates() total expected to leave out a.t3 but got %+v", total)
+	}
+}
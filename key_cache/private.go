@@ -0,0 +1,243 @@
+// Package key_cache contains the library routines for showing the MyISAM
+// key cache and Aria page cache status counters with the table I/O of
+// MyISAM and Aria tables.
+package key_cache
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// statusPrefixes are the global status variables we collect
+var statusPrefixes = []string{"Key_", "Aria_pagecache_"}
+
+// a status variable to show and the variables whose sum its percentage is of
+type statusVariable struct {
+	name  string
+	of    []string
+	gauge bool // the current value is shown rather than the change
+}
+
+// statusVariables are shown in this order (if the server provides them)
+var statusVariables = []statusVariable{
+	{"Key_read_requests", nil, false},
+	{"Key_reads", []string{"Key_read_requests"}, false},
+	{"Key_write_requests", nil, false},
+	{"Key_writes", []string{"Key_write_requests"}, false},
+	{"Key_blocks_used", []string{"Key_blocks_used", "Key_blocks_unused"}, true},
+	{"Key_blocks_unused", []string{"Key_blocks_used", "Key_blocks_unused"}, true},
+	{"Key_blocks_not_flushed", []string{"Key_blocks_used"}, true},
+	{"Aria_pagecache_read_requests", nil, false},
+	{"Aria_pagecache_reads", []string{"Aria_pagecache_read_requests"}, false},
+	{"Aria_pagecache_write_requests", nil, false},
+	{"Aria_pagecache_writes", []string{"Aria_pagecache_write_requests"}, false},
+	{"Aria_pagecache_blocks_used", []string{"Aria_pagecache_blocks_used", "Aria_pagecache_blocks_unused"}, true},
+	{"Aria_pagecache_blocks_unused", []string{"Aria_pagecache_blocks_used", "Aria_pagecache_blocks_unused"}, true},
+	{"Aria_pagecache_blocks_not_flushed", []string{"Aria_pagecache_blocks_used"}, true},
+}
+
+// Row contains a status counter or the table I/O of a MyISAM or Aria table
+type Row struct {
+	name    string
+	engine  string // storage engine (table rows only)
+	value   uint64 // the change in a counter, the current value of a gauge or the table's operations
+	total   uint64 // the value the percentage is of, 0 if none
+	latency uint64 // table I/O latency (table rows only)
+	rate    bool   // show the value per second
+	table   bool   // a table row, or the total of them
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// select the table I/O of MyISAM and Aria tables
+func selectTableRows(dbh *sql.DB, schemas *schema_filter.Filter) (Rows, error) {
+	var t Rows
+
+	sql := `
+SELECT	t.OBJECT_SCHEMA,
+	t.OBJECT_NAME,
+	i.ENGINE,
+	t.COUNT_STAR,
+	t.SUM_TIMER_WAIT
+FROM	table_io_waits_summary_by_table t
+JOIN	information_schema.TABLES i ON (i.TABLE_SCHEMA = t.OBJECT_SCHEMA AND i.TABLE_NAME = t.OBJECT_NAME)
+WHERE	t.OBJECT_TYPE = 'TABLE'
+AND	i.ENGINE IN ('MyISAM', 'Aria')
+AND	t.COUNT_STAR > 0`
+	condition, args := schemas.And("t.OBJECT_SCHEMA", "t.OBJECT_NAME")
+
+	rows, err := dbh.Query(sql+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table string
+		var r Row
+
+		if err := rows.Scan(&schema, &table, &r.engine, &r.value, &r.latency); err != nil {
+			return nil, err
+		}
+		r.name = lib.TableName(schema, table)
+		r.rate = true
+		r.table = true
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("key_cache.selectTableRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// statusRows returns the status variables we show in their fixed order
+func statusRows(current, changed global.StatusValues) Rows {
+	var t Rows
+
+	// the value to show for the given variable
+	value := func(v statusVariable, name string) uint64 {
+		key := strings.ToLower(name)
+		if v.gauge {
+			return current[key]
+		}
+		return changed[key]
+	}
+
+	for _, v := range statusVariables {
+		if _, found := current[strings.ToLower(v.name)]; !found {
+			continue
+		}
+		r := Row{name: v.name, value: value(v, v.name), rate: !v.gauge}
+		for _, name := range v.of {
+			r.total += value(v, name)
+		}
+		t = append(t, r)
+	}
+
+	return t
+}
+
+// gauges are the lower-cased names of the variables whose current value
+// is shown, which may go down at any time
+var gauges = func() map[string]bool {
+	g := make(map[string]bool)
+	for _, v := range statusVariables {
+		if v.gauge {
+			g[strings.ToLower(v.name)] = true
+		}
+	}
+	return g
+}()
+
+// tableDiffer matches the tables by name to subtract their initial values
+var tableDiffer = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.value, row.latency} },
+	Subtract: func(row *Row, other Row) {
+		row.value -= other.value
+		row.latency -= other.latency
+	},
+}
+
+// tablesNeedRefresh returns true if the table I/O of the tables found in
+// both has gone backwards, e.g. after TRUNCATE TABLE
+// performance_schema.table_io_waits_summary_by_table.
+func tablesNeedRefresh(initial, current Rows) bool {
+	return tableDiffer.NeedsRefresh(initial, current)
+}
+
+// subtract the initial values of the same tables, dropping tables with no activity
+func (rows Rows) subtract(initial Rows) Rows {
+	changed := append(Rows(nil), rows...)
+	tableDiffer.SubtractRows(changed, initial)
+
+	var results Rows
+	for i := range changed {
+		if changed[i].value > 0 {
+			results = append(results, changed[i])
+		}
+	}
+
+	return results
+}
+
+// totals returns the sum of the table rows
+func (rows Rows) totals() Row {
+	totals := Row{name: "MyISAM/Aria tables", rate: true, table: true}
+
+	for i := range rows {
+		totals.value += rows[i].value
+		totals.latency += rows[i].latency
+	}
+
+	return totals
+}
+
+// sortKeys returns the keys the table rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].latency, rows[j].latency) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].value, rows[j].value) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort table rows by latency (descending) but also by "name" (ascending) if the values
+// are the same after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("key_cache", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("key_cache", Rows(nil).sortKeys())
+}
+
+// headings returns the headings of the value, rate, percentage, latency and name columns
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %10s %6s %10s|%s", "Value", "Rate/s", "%", "Latency", "Name")
+}
+
+// generate a printable result
+func (row *Row) rowContent(seconds float64) string {
+	rate := ""
+	if row.rate && seconds > 0 {
+		rate = lib.FormatAmount(uint64(float64(row.value)/seconds + 0.5))
+	}
+	pct := ""
+	if row.total > 0 {
+		pct = lib.FormatPct(lib.MyDivide(row.value, row.total))
+	}
+	latency := ""
+	name := row.name
+	if row.table {
+		latency = lib.FormatTime(row.latency)
+	}
+	if row.engine != "" {
+		name += " (" + row.engine + ")"
+	}
+
+	return fmt.Sprintf("%10s %10s %6s %10s|%s",
+		lib.FormatAmount(row.value),
+		rate,
+		pct,
+		latency,
+		name)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10d %10d %10d %s", row.value, row.total, row.latency, row.name)
+}
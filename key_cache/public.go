@@ -0,0 +1,184 @@
+// Package key_cache contains the library routines for showing the MyISAM
+// key cache and Aria page cache status counters with the table I/O of
+// MyISAM and Aria tables.
+package key_cache
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the key cache status counters and table I/O
+type Object struct {
+	baseobject.BaseObject                     // embedded
+	initial               global.StatusValues // initial status values for relative values
+	current               global.StatusValues // last loaded status values
+	initialTables         Rows                // initial table I/O for relative values
+	currentTables         Rows                // last loaded table I/O
+	results               Rows                // status rows followed by table rows
+	totals                Row                 // total table I/O
+}
+
+// NewKeyCache returns a pointer to an object of this type
+func NewKeyCache(ctx *context.Context) *Object {
+	logger.Println("NewKeyCache()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = make(global.StatusValues)
+	for name, value := range t.current {
+		t.initial[name] = value
+	}
+	t.initialTables = make(Rows, len(t.currentTables))
+	copy(t.initialTables, t.currentTables)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// Collect collects the status counters and table I/O, updating
+// initial values if needed and generating the results.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	values, err := t.Status().Values(statusPrefixes...)
+	if err != nil {
+		return err
+	}
+	rows, err := selectTableRows(dbh, t.SchemaFilter())
+	if err != nil {
+		return err
+	}
+	t.current = values
+	t.currentTables = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "status value(s) and", len(t.currentTables), "table(s)")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.current.NeedsRefresh(t.initial, gauges) || tablesNeedRefresh(t.initialTables, t.currentTables) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("key_cache.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// changed returns the status values to show, relative to the initial ones if wanted
+func (t Object) changed() global.StatusValues {
+	if t.WantRelativeStats() {
+		return t.current.Subtract(t.initial)
+	}
+	return t.current
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	tables := make(Rows, len(t.currentTables))
+	copy(tables, t.currentTables)
+	if t.WantRelativeStats() {
+		tables = tables.subtract(t.initialTables)
+	}
+	tables.sort()
+
+	t.totals = tables.totals()
+	t.totals.total = t.totals.value
+	for i := range tables {
+		tables[i].total = t.totals.value
+	}
+
+	t.results = append(statusRows(t.current, t.changed()), tables...)
+}
+
+// seconds returns the period over which the counters have been collected
+func (t Object) seconds() float64 {
+	if t.WantRelativeStats() {
+		return t.LastCollectTime().Sub(t.InitialCollectTime()).Seconds()
+	}
+	return float64(t.Status().Get("Uptime"))
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// Headings returns the headings of the object
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+	seconds := t.seconds()
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(seconds))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(0)
+}
+
+// TotalRowContent returns a row containing the total table I/O
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.seconds())
+}
+
+// hitRatio returns the percentage of read requests which didn't need a read
+func hitRatio(requests, reads uint64) string {
+	if requests < reads {
+		return strings.TrimSpace(lib.FormatPct(0))
+	}
+	return strings.TrimSpace(lib.FormatPct(lib.MyDivide(requests-reads, requests)))
+}
+
+// Description returns the key cache (and Aria page cache) read hit ratios
+func (t Object) Description() string {
+	changed := t.changed()
+	description := fmt.Sprintf("Key Cache (global_status, table_io_waits_summary_by_table) read hit ratio: %s",
+		hitRatio(changed["key_read_requests"], changed["key_reads"]))
+	if _, found := t.current["aria_pagecache_read_requests"]; found {
+		description += fmt.Sprintf(", Aria page cache: %s",
+			hitRatio(changed["aria_pagecache_read_requests"], changed["aria_pagecache_reads"]))
+	}
+
+	return description
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/sjmudd/anonymiser"
 )
@@ -67,11 +68,14 @@ func FormatSeconds(seconds uint64) string {
 // FormatTime is based on sys.format_time. It
 // formats to 10 characters including space and suffix.
 // All values have 2 decimal places. Zero is returned as
-// an empty string.
+// an empty string. Raw numbers are shown as whole microseconds.
 func FormatTime(picoseconds uint64) string {
 	if picoseconds == 0 {
 		return ""
 	}
+	if rawNumbers {
+		return rawTime(picoseconds)
+	}
 	if picoseconds >= 3600000000000000 {
 		return myround(float64(picoseconds)/3600000000000000, 8, 2) + " h"
 	}
@@ -123,8 +127,8 @@ func FormatAmount(amount uint64) string {
 	if amount == 0 {
 		return ""
 	}
-	if amount <= 1024 {
-		return strconv.Itoa(int(amount))
+	if amount <= 1024 || rawNumbers {
+		return strconv.FormatUint(amount, 10)
 	}
 
 	if amount > i1024_4 {
@@ -159,7 +163,7 @@ func SignedFormatAmount(amount int64) string {
 	if amount == 0 {
 		return ""
 	}
-	if math.Abs(float64(amount)) <= 1024 {
+	if math.Abs(float64(amount)) <= 1024 || rawNumbers {
 		return strconv.Itoa(int(amount))
 	}
 
@@ -238,8 +242,13 @@ func Uptime(uptime int) string {
 	return result
 }
 
-// TableName returns the table name from the columns as '<schema>.<table>'
+// TableName returns the table name from the columns as '<schema>.<table>',
+// in lower case if the server ignores the case of table names
 func TableName(schema, table string) string {
+	if lowerCaseTableNames {
+		schema = strings.ToLower(schema)
+		table = strings.ToLower(table)
+	}
 	schema = anonymiser.Anonymise("schema", schema)
 	table = anonymiser.Anonymise("table", table)
 
@@ -258,3 +267,49 @@ func TableName(schema, table string) string {
 	}
 	return name
 }
+
+// NameCache remembers the names returned by TableName so collecting the
+// same tables each interval doesn't build their names again. The schema
+// and table are given as bytes, e.g. as scanned into sql.RawBytes, as
+// looking them up doesn't allocate.
+type NameCache struct {
+	anonymised bool
+	lowerCase  bool
+	names      map[string]map[string]string // by schema and table
+}
+
+// TableName returns TableName(schema, table), from the cache if it's there
+func (c *NameCache) TableName(schema, table []byte) string {
+	if c.names == nil || c.anonymised != anonymiser.Enabled() || c.lowerCase != lowerCaseTableNames {
+		c.names = make(map[string]map[string]string)
+		c.anonymised = anonymiser.Enabled()
+		c.lowerCase = lowerCaseTableNames
+	}
+
+	tables, found := c.names[string(schema)]
+	if !found {
+		tables = make(map[string]string)
+		c.names[string(schema)] = tables
+	}
+	name, found := tables[string(table)]
+	if !found {
+		name = TableName(string(schema), string(table))
+		tables[string(table)] = name
+	}
+
+	return name
+}
+
+// PartitionSeparator separates the table and partition names of a partitioned table
+const PartitionSeparator = "#P#"
+
+// SplitPartition returns the table and partition names of a partitioned
+// table's name, e.g. 'db.t#P#p1' or 'db.t#P#p1#SP#p1sp0', and whether it
+// is one. The separator may be lower case on some platforms.
+func SplitPartition(name string) (table, partition string, found bool) {
+	i := strings.Index(strings.ToUpper(name), PartitionSeparator)
+	if i < 0 {
+		return name, "", false
+	}
+	return name[0:i], name[i+len(PartitionSeparator):], true
+}
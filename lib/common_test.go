@@ -2,6 +2,8 @@ package lib
 
 import (
 	"testing"
+
+	"github.com/sjmudd/anonymiser"
 )
 
 func TestMyName(t *testing.T) {
@@ -31,3 +33,39 @@ func TestFormatTime(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitPartition(t *testing.T) {
+	tests := []struct {
+		name      string
+		table     string
+		partition string
+		found     bool
+	}{
+		{"db.t", "db.t", "", false},
+		{"db.t#P#p1", "db.t", "p1", true},
+		{"db.t#p#p1", "db.t", "p1", true},
+		{"db.t#P#p1#SP#p1sp0", "db.t", "p1#SP#p1sp0", true},
+	}
+	for _, test := range tests {
+		table, partition, found := SplitPartition(test.name)
+		if table != test.table || partition != test.partition || found != test.found {
+			t.Errorf("SplitPartition(%q) = %q, %q, %v, want %q, %q, %v", test.name, table, partition, found, test.table, test.partition, test.found)
+		}
+	}
+}
+
+func TestNameCache(t *testing.T) {
+	var c NameCache
+
+	anonymiser.Enable(false)
+	if name := c.TableName([]byte("db"), []byte("t")); name != "db.t" {
+		t.Errorf("NameCache.TableName(db, t) = %q, want %q", name, "db.t")
+	}
+	if name := c.TableName([]byte(""), []byte("t")); name != "t" {
+		t.Errorf("NameCache.TableName(, t) = %q, want %q", name, "t")
+	}
+	schema, table := []byte("db"), []byte("t")
+	if allocs := testing.AllocsPerRun(10, func() { c.TableName(schema, table) }); allocs > 0 {
+		t.Errorf("NameCache.TableName() of a cached name made %v allocation(s)", allocs)
+	}
+}
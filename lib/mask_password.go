@@ -0,0 +1,29 @@
+package lib
+
+import (
+	"strings"
+)
+
+// MaskPassword returns the DSN, or address given as
+// [user[:password]@]host[:port], with its password (if any) shown as
+// **** so it can be logged or shown. The password runs from the first
+// : to the @ before the address, which is the last one before the /
+// of a DSN's database so a password may contain ?, / or @.
+func MaskPassword(dsn string) string {
+	at := -1
+	if slash := strings.LastIndex(dsn, "/"); slash >= 0 {
+		at = strings.LastIndex(dsn[:slash], "@")
+	}
+	if at < 0 {
+		at = strings.LastIndex(dsn, "@")
+	}
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+
+	return dsn[:colon+1] + "****" + dsn[at:]
+}
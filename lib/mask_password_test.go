@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestMaskPassword(t *testing.T) {
+	tests := map[string]string{
+		// DSNs
+		"user:secret@tcp(127.0.0.1:3306)/performance_schema":      "user:****@tcp(127.0.0.1:3306)/performance_schema",
+		"user:p@ss:w/rd@tcp(db:3306)/performance_schema?tls=true": "user:****@tcp(db:3306)/performance_schema?tls=true",
+		"u:pa?ss@tcp(h)/db":                             "u:****@tcp(h)/db",
+		"u:pa?ss@tcp(h)/db?loc=Europe/London":           "u:****@tcp(h)/db?loc=Europe/London",
+		"user@unix(/tmp/mysql.sock)/performance_schema": "user@unix(/tmp/mysql.sock)/performance_schema",
+		"user:@tcp(db)/":                                "user:****@tcp(db)/",
+		"":                                              "",
+		// ProxySQL admin addresses
+		"127.0.0.1:6032":             "127.0.0.1:6032",
+		"radmin@proxy1":              "radmin@proxy1",
+		"radmin:secret@proxy1:6032":  "radmin:****@proxy1:6032",
+		"radmin:p@ss@[::1]:6032":     "radmin:****@[::1]:6032",
+		"radmin:s3cr:et@proxy1:6032": "radmin:****@proxy1:6032",
+		"radmin:se/cret@proxy1:6032": "radmin:****@proxy1:6032",
+	}
+
+	for dsn, expected := range tests {
+		if got := MaskPassword(dsn); got != expected {
+			t.Errorf("MaskPassword(%q) = %q, expected %q", dsn, got, expected)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package lib
+
+import (
+	"strings"
+)
+
+var (
+	// lowerCaseTableNames is true if the server compares the names of
+	// schemas and tables in lower case
+	lowerCaseTableNames bool
+	// lowerCaseFileSystem is true if the server's files are on a case
+	// insensitive file system
+	lowerCaseFileSystem bool
+)
+
+// SetNameCase sets how names are compared from the server's
+// lower_case_table_names and lower_case_file_system. With
+// lower_case_table_names = 1 or 2 the server ignores the case of schema
+// and table names, so TableName returns them in lower case and the same
+// table seen with a different case in different performance_schema
+// tables, or in the path of its file, is kept as one row.
+func SetNameCase(lowerCaseTableNamesValue, lowerCaseFileSystemValue string) {
+	lowerCaseTableNames = lowerCaseTableNamesValue == "1" || lowerCaseTableNamesValue == "2"
+	lowerCaseFileSystem = strings.EqualFold(lowerCaseFileSystemValue, "ON")
+}
+
+// LowerCaseFileSystem returns true if file names which only differ in
+// case are the same file
+func LowerCaseFileSystem() bool {
+	return lowerCaseFileSystem
+}
@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/sjmudd/anonymiser"
+)
+
+func TestNameCase(t *testing.T) {
+	anonymiser.Enable(false)
+	defer SetNameCase("", "")
+
+	tests := []struct {
+		lowerCaseTableNames string
+		lowerCaseFileSystem string
+		name                string
+		fileSystem          bool
+	}{
+		{"0", "OFF", "Shop.Orders", false},
+		{"1", "OFF", "shop.orders", false},
+		{"2", "ON", "shop.orders", true},
+		{"0", "on", "Shop.Orders", true},
+		{"", "", "Shop.Orders", false}, // not known, e.g. not a MySQL server
+	}
+	for _, test := range tests {
+		var c NameCache
+
+		SetNameCase(test.lowerCaseTableNames, test.lowerCaseFileSystem)
+		if name := TableName("Shop", "Orders"); name != test.name {
+			t.Errorf("lower_case_table_names = %q: TableName(Shop, Orders) = %q, want %q", test.lowerCaseTableNames, name, test.name)
+		}
+		if name := c.TableName([]byte("Shop"), []byte("Orders")); name != test.name {
+			t.Errorf("lower_case_table_names = %q: NameCache.TableName(Shop, Orders) = %q, want %q", test.lowerCaseTableNames, name, test.name)
+		}
+		if LowerCaseFileSystem() != test.fileSystem {
+			t.Errorf("lower_case_file_system = %q: LowerCaseFileSystem() = %v, want %v", test.lowerCaseFileSystem, LowerCaseFileSystem(), test.fileSystem)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package lib
+
+import (
+	"strconv"
+)
+
+// rawNumbers is true if the numbers are shown as plain integers
+var rawNumbers bool
+
+// SetRawNumbers sets whether latencies are shown as whole microseconds
+// and amounts without a suffix, so they can be pasted into a spreadsheet
+// or compared with diff, rather than in a human readable form
+func SetRawNumbers(raw bool) {
+	rawNumbers = raw
+}
+
+// RawNumbers returns true if the numbers are shown as plain integers
+func RawNumbers() bool {
+	return rawNumbers
+}
+
+// rawTime returns the picoseconds as whole microseconds
+func rawTime(picoseconds uint64) string {
+	return strconv.FormatUint(picoseconds/1000000, 10)
+}
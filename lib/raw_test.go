@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestRawNumbers(t *testing.T) {
+	SetRawNumbers(true)
+	defer SetRawNumbers(false)
+
+	times := []struct {
+		input  uint64
+		output string
+	}{
+		{0, ""},
+		{999999, "0"},
+		{1000000, "1"},
+		{1234567890123, "1234567"},
+		{7200000000000000, "7200000000"},
+	}
+	for _, test := range times {
+		if got := FormatTime(test.input); got != test.output {
+			t.Errorf("FormatTime(%v) = %q, want %q", test.input, got, test.output)
+		}
+	}
+
+	amounts := []struct {
+		input  uint64
+		output string
+	}{
+		{0, ""},
+		{1024, "1024"},
+		{1048577, "1048577"},
+	}
+	for _, test := range amounts {
+		if got := FormatAmount(test.input); got != test.output {
+			t.Errorf("FormatAmount(%v) = %q, want %q", test.input, got, test.output)
+		}
+	}
+	if got := SignedFormatAmount(-5000); got != "-5000" {
+		t.Errorf("SignedFormatAmount(-5000) = %q, want %q", got, "-5000")
+	}
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Columns gives the field of a row of type R each column of a query is
+// scanned into, by the column's name, e.g.
+//
+//	lib.Columns[Row]{
+//		"EVENT_NAME": func(r *Row) interface{} { return &r.name },
+//		"COUNT_STAR": func(r *Row) interface{} { return &r.countStar },
+//	}
+//
+// The names are matched ignoring their case.
+type Columns[R any] map[string]func(row *R) interface{}
+
+// fields returns the function giving the field of the row each of the
+// named columns is scanned into, in the order of the columns. A column
+// without a field is an error.
+func (c Columns[R]) fields(names []string) ([]func(row *R) interface{}, error) {
+	byName := make(map[string]func(row *R) interface{}, len(c))
+	for name, field := range c {
+		byName[strings.ToUpper(name)] = field
+	}
+
+	fields := make([]func(row *R) interface{}, len(names))
+	for i, name := range names {
+		field, found := byName[strings.ToUpper(name)]
+		if !found {
+			var r R
+			return nil, fmt.Errorf("no field of %T is given for the column %q", r, name)
+		}
+		fields[i] = field
+	}
+
+	return fields, nil
+}
+
+// ReadRowsFromSQL runs the query and returns a row for each row it
+// returns, each column being scanned into the field columns gives for
+// it, so a collector only needs to define its row, the columns read
+// into it and any derived values. The columns are matched to the fields
+// once for the query rather than for each row.
+func ReadRowsFromSQL[R any](dbh *sql.DB, columns Columns[R], query string, args ...interface{}) ([]R, error) {
+	rows, err := dbh.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	fields, err := columns.fields(names)
+	if err != nil {
+		return nil, err
+	}
+
+	var t []R
+	destinations := make([]interface{}, len(fields))
+	for rows.Next() {
+		var r R
+		for i, field := range fields {
+			destinations[i] = field(&r)
+		}
+		if err := rows.Scan(destinations...); err != nil {
+			return nil, err
+		}
+		t = append(t, r)
+	}
+
+	return t, rows.Err()
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// a driver returning a fixed result for each query, so ReadRowsFromSQL
+// can be tested without a server
+type testDriver map[string]*testRows
+
+type testConn struct{ results testDriver }
+type testStmt struct {
+	results testDriver
+	query   string
+}
+type testRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (d testDriver) Open(dsn string) (driver.Conn, error) { return testConn{results: d}, nil }
+
+func (c testConn) Prepare(query string) (driver.Stmt, error) {
+	return testStmt{results: c.results, query: query}, nil
+}
+func (c testConn) Close() error              { return nil }
+func (c testConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (s testStmt) Close() error  { return nil }
+func (s testStmt) NumInput() int { return -1 }
+func (s testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	result, found := s.results[s.query]
+	if !found {
+		return nil, errors.New("unknown query " + s.query)
+	}
+	return &testRows{columns: result.columns, values: result.values}, nil
+}
+
+func (r *testRows) Columns() []string { return r.columns }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+func init() {
+	sql.Register("read_rows_test", testDriver{
+		"events": {
+			columns: []string{"COUNT_STAR", "event_name", "USER"},
+			values: [][]driver.Value{
+				{int64(10), "wait/io/file/sql/binlog", "app"},
+				{int64(3), "wait/io/file/innodb/innodb_data_file", nil},
+			},
+		},
+		"none":    {columns: []string{"EVENT_NAME"}},
+		"unknown": {columns: []string{"EVENT_NAME", "SUM_TIMER_WAIT"}},
+	})
+}
+
+type testRow struct {
+	name      string
+	countStar uint64
+	user      sql.NullString
+	derived   uint64
+}
+
+var testColumns = Columns[testRow]{
+	"EVENT_NAME": func(r *testRow) interface{} { return &r.name },
+	"COUNT_STAR": func(r *testRow) interface{} { return &r.countStar },
+	"USER":       func(r *testRow) interface{} { return &r.user },
+}
+
+func TestReadRowsFromSQL(t *testing.T) {
+	dbh, err := sql.Open("read_rows_test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.Close()
+
+	rows, err := ReadRowsFromSQL(dbh, testColumns, "events")
+	if err != nil {
+		t.Fatalf("ReadRowsFromSQL() returned an unexpected error: %v", err)
+	}
+	want := []testRow{
+		{name: "wait/io/file/sql/binlog", countStar: 10, user: sql.NullString{String: "app", Valid: true}},
+		{name: "wait/io/file/innodb/innodb_data_file", countStar: 3},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("ReadRowsFromSQL() expected %+v but got %+v", want, rows)
+	}
+
+	if rows, err := ReadRowsFromSQL(dbh, testColumns, "none"); err != nil || len(rows) != 0 {
+		t.Errorf("ReadRowsFromSQL() expected no rows but got %+v, %v", rows, err)
+	}
+
+	if _, err := ReadRowsFromSQL(dbh, testColumns, "unknown"); err == nil {
+		t.Errorf("ReadRowsFromSQL() expected an error for a column without a field")
+	}
+}
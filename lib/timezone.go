@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"strings"
+	"time"
+	_ "time/tzdata" // so zone names work where the system has no zoneinfo, e.g. in containers
+)
+
+// the zone the times shown are in
+var location = time.Local
+
+// SetTimezone sets the zone the times shown are in: local (the default),
+// UTC or an IANA zone name such as America/New_York
+func SetTimezone(name string) error {
+	switch {
+	case name == "", strings.EqualFold(name, "local"):
+		location = time.Local
+	case strings.EqualFold(name, "utc"):
+		location = time.UTC
+	default:
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		location = loc
+	}
+
+	return nil
+}
+
+// InTimezone returns the time in the zone the times shown are in
+func InTimezone(t time.Time) time.Time {
+	return t.In(location)
+}
+
+// TimezoneName returns the abbreviation of the zone the times are shown
+// in, e.g. UTC or CET, or "" if they are shown in local time
+func TimezoneName() string {
+	if location == time.Local {
+		return ""
+	}
+	name, _ := time.Now().In(location).Zone()
+
+	return name
+}
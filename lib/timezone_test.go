@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetTimezone(t *testing.T) {
+	defer SetTimezone("local")
+
+	moment := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name, hour, zone string
+	}{
+		{"UTC", "12:00", "UTC"},
+		{"utc", "12:00", "UTC"},
+		{"Asia/Tokyo", "21:00", "JST"},
+		{"America/New_York", "07:00", "EST"},
+	}
+	for _, test := range tests {
+		if err := SetTimezone(test.name); err != nil {
+			t.Errorf("SetTimezone(%q) gave an error: %v", test.name, err)
+			continue
+		}
+		if got := InTimezone(moment).Format("15:04"); got != test.hour {
+			t.Errorf("SetTimezone(%q): InTimezone() = %s, want %s", test.name, got, test.hour)
+		}
+		if got, _ := InTimezone(moment).Zone(); got != test.zone {
+			t.Errorf("SetTimezone(%q): zone = %s, want %s", test.name, got, test.zone)
+		}
+	}
+
+	if err := SetTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Errorf("SetTimezone(\"Mars/Olympus_Mons\") gave no error")
+	}
+	if err := SetTimezone("local"); err != nil || TimezoneName() != "" {
+		t.Errorf("SetTimezone(\"local\") = %v, TimezoneName() = %q, want no error and no name", err, TimezoneName())
+	}
+}
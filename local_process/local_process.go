@@ -0,0 +1,195 @@
+// Package local_process collects the operating system statistics of a
+// mysqld running on the same host from /proc, so they can be compared
+// with the counters the server provides.
+package local_process
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// clockTicks is USER_HZ, the unit of utime and stime in /proc/<pid>/stat
+const clockTicks = 100
+
+// procDir is where the process information is found
+var procDir = "/proc"
+
+// the names the server process may have
+var serverNames = map[string]bool{"mysqld": true, "mariadbd": true}
+
+// a sample of the counters of the process
+type sample struct {
+	when       time.Time
+	cpuTicks   uint64 // utime + stime
+	readBytes  uint64
+	writeBytes uint64
+}
+
+// Process holds the statistics of the local server process
+type Process struct {
+	pid      int
+	previous sample
+	current  sample
+	rss      uint64 // bytes
+	threads  uint64
+	fds      int  // open file descriptors, -1 if they can't be counted
+	haveIO   bool // /proc/<pid>/io can be read
+}
+
+// Find returns the server process given the pid_file and datadir
+// settings of the server. It fails if the process is not running on
+// this host, e.g. if we are connected to a remote server.
+func Find(pidFile, datadir string) (*Process, error) {
+	if pidFile == "" {
+		return nil, errors.New("pid_file is not known")
+	}
+	if !filepath.IsAbs(pidFile) {
+		pidFile = filepath.Join(datadir, pidFile)
+	}
+	content, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("%s does not contain a pid: %v", pidFile, err)
+	}
+	comm, err := ioutil.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return nil, err
+	}
+	if name := strings.TrimSpace(string(comm)); !serverNames[name] {
+		return nil, fmt.Errorf("process %d is %s, not the server", pid, name)
+	}
+	logger.Println("local_process.Find() found the server process", pid)
+
+	p := &Process{pid: pid}
+	if err := p.Collect(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Collect collects the current statistics of the process
+func (p *Process) Collect() error {
+	dir := filepath.Join(procDir, strconv.Itoa(p.pid))
+
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return err
+	}
+	s := sample{when: time.Now()}
+	if s.cpuTicks, p.threads, err = parseStat(string(stat)); err != nil {
+		return err
+	}
+
+	statm, err := ioutil.ReadFile(filepath.Join(dir, "statm"))
+	if err != nil {
+		return err
+	}
+	if fields := strings.Fields(string(statm)); len(fields) > 1 {
+		pages, _ := strconv.ParseUint(fields[1], 10, 64)
+		p.rss = pages * uint64(os.Getpagesize())
+	}
+
+	// these need the same user as mysqld (or root) so may not be available
+	io, err := ioutil.ReadFile(filepath.Join(dir, "io"))
+	if p.haveIO = err == nil; p.haveIO {
+		s.readBytes, s.writeBytes = parseIO(string(io))
+	}
+	p.fds = -1
+	if fds, err := ioutil.ReadDir(filepath.Join(dir, "fd")); err == nil {
+		p.fds = len(fds)
+	}
+
+	p.previous, p.current = p.current, s
+
+	return nil
+}
+
+// parseStat returns the cpu ticks used and the number of threads from
+// the content of /proc/<pid>/stat
+func parseStat(stat string) (uint64, uint64, error) {
+	// the command may contain spaces so start after it
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, 0, errors.New("unexpected format of /proc/<pid>/stat")
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 18 {
+		return 0, 0, errors.New("unexpected format of /proc/<pid>/stat")
+	}
+	// fields[0] is field 3 (state) so utime (14), stime (15) and num_threads (20)
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	threads, _ := strconv.ParseUint(fields[17], 10, 64)
+
+	return utime + stime, threads, nil
+}
+
+// parseIO returns the bytes read and written from the content of /proc/<pid>/io
+func parseIO(io string) (uint64, uint64) {
+	var read, written uint64
+
+	for _, line := range strings.Split(io, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[1], 10, 64)
+		switch fields[0] {
+		case "read_bytes:":
+			read = value
+		case "write_bytes:":
+			written = value
+		}
+	}
+
+	return read, written
+}
+
+// rate returns the change per second of a counter between the samples
+func rate(previous, current uint64, seconds float64) float64 {
+	if current < previous || seconds <= 0 {
+		return 0
+	}
+	return float64(current-previous) / seconds
+}
+
+// amount formats a value like lib.FormatAmount but shows 0 too
+func amount(value uint64) string {
+	if value == 0 {
+		return "0"
+	}
+	return strings.TrimSpace(lib.FormatAmount(value))
+}
+
+// Summary describes the statistics of the process on a single line. The
+// cpu used is shown as a percentage of one cpu so may be over 100%.
+func (p *Process) Summary() string {
+	summary := "mysqld"
+	if seconds := p.current.when.Sub(p.previous.when).Seconds(); !p.previous.when.IsZero() && seconds > 0 {
+		summary += fmt.Sprintf(" cpu: %.1f%%", 100*rate(p.previous.cpuTicks, p.current.cpuTicks, seconds)/clockTicks)
+		if p.haveIO {
+			summary += fmt.Sprintf(" io r/w: %sB/s %sB/s",
+				amount(uint64(rate(p.previous.readBytes, p.current.readBytes, seconds))),
+				amount(uint64(rate(p.previous.writeBytes, p.current.writeBytes, seconds))))
+		}
+	}
+	summary += fmt.Sprintf(" rss: %sB threads: %d", amount(p.rss), p.threads)
+	if p.fds >= 0 {
+		summary += fmt.Sprintf(" fds: %d", p.fds)
+	}
+
+	return summary
+}
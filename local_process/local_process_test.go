@@ -0,0 +1,28 @@
+package local_process
+
+import "testing"
+
+func TestParseStat(t *testing.T) {
+	stat := "1234 (my sqld) S 1 1234 1234 0 -1 4194560 95 0 0 0 150 50 0 0 20 0 37 0 12345 1000 200 18446744073709551615"
+
+	ticks, threads, err := parseStat(stat)
+	if err != nil {
+		t.Fatalf("parseStat() returned error: %v", err)
+	}
+	if ticks != 200 || threads != 37 {
+		t.Errorf("parseStat() = %d, %d, want 200, 37", ticks, threads)
+	}
+
+	if _, _, err := parseStat("1234 (mysqld) S 1"); err == nil {
+		t.Errorf("parseStat() of a short line did not return an error")
+	}
+}
+
+func TestParseIO(t *testing.T) {
+	io := "rchar: 100\nwchar: 200\nsyscr: 3\nsyscw: 4\nread_bytes: 4096\nwrite_bytes: 8192\ncancelled_write_bytes: 0\n"
+
+	read, written := parseIO(io)
+	if read != 4096 || written != 8192 {
+		t.Errorf("parseIO() = %d, %d, want 4096, 8192", read, written)
+	}
+}
@@ -0,0 +1,275 @@
+// Package lock_users contains the library routines for adding up the
+// locks held and waited for by each user from
+// performance_schema.metadata_locks, data_lock_waits and
+// information_schema.INNODB_TRX.
+package lock_users
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// Row contains the lock footprint of a user
+type Row struct {
+	name        string // user
+	held        uint64 // metadata locks granted
+	rowsLocked  uint64 // rows locked by InnoDB transactions
+	waiting     uint64 // metadata locks pending and InnoDB lock waits
+	blocking    uint64 // connections waiting for a lock the user holds
+	blockedTime uint64 // seconds those connections have been waiting
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// wait is a connection waiting for a lock held by a connection of the blocker
+type wait struct {
+	waiter   uint64 // thread or processlist id of the connection waiting
+	waitTime uint64 // seconds it has been waiting
+	user     string // user of the connection waiting
+	blocker  string // user holding the lock
+}
+
+// the metadata locks held and waited for by user
+const metadataLocksQuery = `
+SELECT	COALESCE(t.PROCESSLIST_USER, ''),
+	SUM(m.LOCK_STATUS = 'GRANTED'),
+	SUM(m.LOCK_STATUS = 'PENDING')
+FROM	performance_schema.metadata_locks m
+JOIN	performance_schema.threads t ON t.THREAD_ID = m.OWNER_THREAD_ID
+WHERE	m.OBJECT_TYPE = 'TABLE'
+GROUP BY t.PROCESSLIST_USER`
+
+// the connections waiting for a metadata lock on a table and the users
+// holding a lock on it
+const metadataWaitsQuery = `
+SELECT DISTINCT w.OWNER_THREAD_ID,
+	COALESCE(wt.PROCESSLIST_TIME, 0),
+	COALESCE(wt.PROCESSLIST_USER, ''),
+	COALESCE(bt.PROCESSLIST_USER, '')
+FROM	performance_schema.metadata_locks w
+JOIN	performance_schema.metadata_locks b ON b.OBJECT_TYPE = w.OBJECT_TYPE AND b.OBJECT_SCHEMA = w.OBJECT_SCHEMA AND b.OBJECT_NAME = w.OBJECT_NAME
+	AND b.LOCK_STATUS = 'GRANTED' AND b.OWNER_THREAD_ID <> w.OWNER_THREAD_ID
+JOIN	performance_schema.threads wt ON wt.THREAD_ID = w.OWNER_THREAD_ID
+JOIN	performance_schema.threads bt ON bt.THREAD_ID = b.OWNER_THREAD_ID
+WHERE	w.LOCK_STATUS = 'PENDING'
+AND	w.OBJECT_TYPE = 'TABLE'`
+
+// the rows locked by the InnoDB transactions of each user
+const rowsLockedQuery = `
+SELECT	COALESCE(t.PROCESSLIST_USER, ''),
+	SUM(x.trx_rows_locked)
+FROM	information_schema.INNODB_TRX x
+JOIN	performance_schema.threads t ON t.PROCESSLIST_ID = x.trx_mysql_thread_id
+GROUP BY t.PROCESSLIST_USER`
+
+// the connections waiting for an InnoDB lock and the users holding it (8.0+)
+const innodbWaitsQuery = `
+SELECT DISTINCT r.trx_mysql_thread_id,
+	COALESCE(TIMESTAMPDIFF(SECOND, r.trx_wait_started, NOW()), 0),
+	COALESCE(rt.PROCESSLIST_USER, ''),
+	COALESCE(bt.PROCESSLIST_USER, '')
+FROM	performance_schema.data_lock_waits w
+JOIN	information_schema.INNODB_TRX r ON r.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID
+JOIN	information_schema.INNODB_TRX b ON b.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID
+JOIN	performance_schema.threads rt ON rt.PROCESSLIST_ID = r.trx_mysql_thread_id
+JOIN	performance_schema.threads bt ON bt.PROCESSLIST_ID = b.trx_mysql_thread_id`
+
+// selectWaits returns the waits found by the query
+func selectWaits(dbh *sql.DB, query string) ([]wait, error) {
+	var waits []wait
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w wait
+		if err := rows.Scan(&w.waiter, &w.waitTime, &w.user, &w.blocker); err != nil {
+			return nil, err
+		}
+		waits = append(waits, w)
+	}
+
+	return waits, rows.Err()
+}
+
+// selectRows returns the lock footprint of each user. The InnoDB lock
+// waits are only read if data_lock_waits is there.
+func selectRows(dbh *sql.DB, haveLockWaits bool) (Rows, error) {
+	byName := make(map[string]*Row)
+	get := func(user string) *Row {
+		if user == "" {
+			user = "<background>"
+		}
+		user = anonymiser.Anonymise("user", user)
+		if r, found := byName[user]; found {
+			return r
+		}
+		r := &Row{name: user}
+		byName[user] = r
+		return r
+	}
+
+	rows, err := dbh.Query(metadataLocksQuery)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var user string
+		var held, waiting uint64
+		if err := rows.Scan(&user, &held, &waiting); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r := get(user)
+		r.held += held
+		r.waiting += waiting
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = dbh.Query(rowsLockedQuery)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var user string
+		var locked uint64
+		if err := rows.Scan(&user, &locked); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		get(user).rowsLocked += locked
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	waits, err := selectWaits(dbh, metadataWaitsQuery)
+	if err != nil {
+		return nil, err
+	}
+	addBlocking(waits, get)
+
+	if haveLockWaits {
+		waits, err := selectWaits(dbh, innodbWaitsQuery)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range distinctWaiters(waits) {
+			get(w.user).waiting++
+		}
+		addBlocking(waits, get)
+	}
+
+	var t Rows
+	for _, r := range byName {
+		t = append(t, *r)
+	}
+	logger.Println("lock_users.selectRows() found", len(t), "user(s)")
+
+	return t, nil
+}
+
+// distinctWaiters returns one wait for each connection waiting
+func distinctWaiters(waits []wait) []wait {
+	var distinct []wait
+	seen := make(map[uint64]bool)
+
+	for _, w := range waits {
+		if !seen[w.waiter] {
+			seen[w.waiter] = true
+			distinct = append(distinct, w)
+		}
+	}
+
+	return distinct
+}
+
+// addBlocking counts each connection waiting once for each user it
+// waits for, with the time it has been waiting
+func addBlocking(waits []wait, get func(user string) *Row) {
+	type pair struct {
+		waiter  uint64
+		blocker string
+	}
+	seen := make(map[pair]bool)
+
+	for _, w := range waits {
+		if p := (pair{w.waiter, w.blocker}); !seen[p] {
+			seen[p] = true
+			r := get(w.blocker)
+			r.blocking++
+			r.blockedTime += w.waitTime
+		}
+	}
+}
+
+// totals returns the totals of all rows
+func (rows Rows) totals() Row {
+	total := Row{name: "Totals"}
+
+	for i := range rows {
+		total.held += rows[i].held
+		total.rowsLocked += rows[i].rowsLocked
+		total.waiting += rows[i].waiting
+		total.blocking += rows[i].blocking
+		total.blockedTime += rows[i].blockedTime
+	}
+
+	return total
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"blocked":  func(i, j int) int { return sort_keys.Descending(rows[i].blockedTime, rows[j].blockedTime) },
+		"blocking": func(i, j int) int { return sort_keys.Descending(rows[i].blocking, rows[j].blocking) },
+		"held":     func(i, j int) int { return sort_keys.Descending(rows[i].held, rows[j].held) },
+		"rows":     func(i, j int) int { return sort_keys.Descending(rows[i].rowsLocked, rows[j].rowsLocked) },
+		"waiting":  func(i, j int) int { return sort_keys.Descending(rows[i].waiting, rows[j].waiting) },
+		"name":     func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by the time others have been blocked (descending), by the
+// connections blocked and then by "name" (ascending) after any
+// configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("lock_users", "blocked", "blocking", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("lock_users", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func headings() string {
+	return fmt.Sprintf("%8s %8s|%8s %9s %8s|%s", "Blocked", "Blocking", "MDL Held", "Rows Lckd", "Waiting", "User")
+}
+
+// generate a printable result
+func (row *Row) rowContent() string {
+	return fmt.Sprintf("%8s %8s|%8s %9s %8s|%s",
+		lib.FormatSeconds(row.blockedTime),
+		lib.FormatAmount(row.blocking),
+		lib.FormatAmount(row.held),
+		lib.FormatAmount(row.rowsLocked),
+		lib.FormatAmount(row.waiting),
+		row.name)
+}
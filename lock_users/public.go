@@ -0,0 +1,109 @@
+// Package lock_users shows the locks held and waited for by each user
+// and how long the connections they block have been waiting, to find
+// which application account is holding up the others.
+package lock_users
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/table"
+)
+
+// Object holds the lock footprint of each user
+type Object struct {
+	baseobject.BaseObject       // embedded
+	current               Rows  // last loaded values
+	totals                Row   // totals of current
+	lockWaits             *bool // is data_lock_waits there? (nil if not checked)
+}
+
+// NewLockUsers returns a pointer to an object of this type
+func NewLockUsers(ctx *context.Context) *Object {
+	logger.Println("NewLockUsers()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the locks of each user. There are no relative
+// values as this is the current state.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	if t.lockWaits == nil {
+		access := table.NewAccess("performance_schema", "data_lock_waits")
+		found := access.CheckSelectError(dbh) == nil
+		t.lockWaits = &found
+	}
+
+	rows, err := selectRows(dbh, *t.lockWaits)
+	if err != nil {
+		return err
+	}
+	rows.sort()
+	t.current = rows
+	t.totals = rows.totals()
+	t.SetLastCollectTimeNow()
+
+	logger.Println("lock_users.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+
+	for i := range t.current {
+		rows = append(rows, t.current[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the totals of all users
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent()
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	source := "metadata_locks"
+	if t.lockWaits != nil && *t.lockWaits {
+		source += ", data_lock_waits"
+	}
+
+	return fmt.Sprintf("Locks by User (%s) %d user(s)", source, len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as we show the current state
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("lock_users.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
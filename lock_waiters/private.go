@@ -0,0 +1,244 @@
+// Package lock_waiters contains the library routines for listing the
+// connections waiting for a lock now from performance_schema.metadata_locks
+// and data_lock_waits.
+package lock_waiters
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+const (
+	metadataLock = "MDL"    // a metadata lock on a table
+	innodbLock   = "InnoDB" // a row or table lock taken by InnoDB
+)
+
+// Row contains a connection waiting for a lock and those holding it
+type Row struct {
+	waiter   uint64   // processlist id of the connection waiting
+	blockers []uint64 // processlist ids of the connections holding the lock
+	waitTime uint64   // seconds it has been waiting
+	kind     string   // metadataLock or innodbLock
+	user     string   // user of the connection waiting
+	name     string   // <schema>.<table> waited for
+	lockMode string   // lock type or mode requested
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// wait is a connection waiting for a lock held by the blocker, as read
+type wait struct {
+	Row
+	blocker uint64
+}
+
+// the connections waiting for a metadata lock on a table and those
+// holding a lock on it
+const metadataWaitsQuery = `
+SELECT	COALESCE(wt.PROCESSLIST_ID, 0),
+	COALESCE(bt.PROCESSLIST_ID, 0),
+	COALESCE(wt.PROCESSLIST_TIME, 0),
+	COALESCE(wt.PROCESSLIST_USER, ''),
+	COALESCE(w.OBJECT_SCHEMA, ''),
+	COALESCE(w.OBJECT_NAME, ''),
+	w.LOCK_TYPE
+FROM	performance_schema.metadata_locks w
+JOIN	performance_schema.metadata_locks b ON b.OBJECT_TYPE = w.OBJECT_TYPE AND b.OBJECT_SCHEMA = w.OBJECT_SCHEMA AND b.OBJECT_NAME = w.OBJECT_NAME
+	AND b.LOCK_STATUS = 'GRANTED' AND b.OWNER_THREAD_ID <> w.OWNER_THREAD_ID
+JOIN	performance_schema.threads wt ON wt.THREAD_ID = w.OWNER_THREAD_ID
+JOIN	performance_schema.threads bt ON bt.THREAD_ID = b.OWNER_THREAD_ID
+WHERE	w.LOCK_STATUS = 'PENDING'
+AND	w.OBJECT_TYPE = 'TABLE'`
+
+// the connections waiting for an InnoDB lock and those holding it (8.0+)
+const innodbWaitsQuery = `
+SELECT	r.trx_mysql_thread_id,
+	b.trx_mysql_thread_id,
+	COALESCE(TIMESTAMPDIFF(SECOND, r.trx_wait_started, NOW()), 0),
+	COALESCE(rt.PROCESSLIST_USER, ''),
+	COALESCE(l.OBJECT_SCHEMA, ''),
+	COALESCE(l.OBJECT_NAME, ''),
+	COALESCE(l.LOCK_MODE, '')
+FROM	performance_schema.data_lock_waits w
+JOIN	information_schema.INNODB_TRX r ON r.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID
+JOIN	information_schema.INNODB_TRX b ON b.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID
+LEFT JOIN performance_schema.threads rt ON rt.PROCESSLIST_ID = r.trx_mysql_thread_id
+LEFT JOIN performance_schema.data_locks l ON l.ENGINE_LOCK_ID = w.REQUESTING_ENGINE_LOCK_ID`
+
+// selectWaits returns the waits of the given kind found by the query
+// for the tables of the schemas wanted
+func selectWaits(dbh *sql.DB, kind, query, condition string, args []interface{}) ([]wait, error) {
+	var waits []wait
+
+	rows, err := dbh.Query(query+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		w := wait{Row: Row{kind: kind}}
+		var schema, table string
+		if err := rows.Scan(
+			&w.waiter,
+			&w.blocker,
+			&w.waitTime,
+			&w.user,
+			&schema,
+			&table,
+			&w.lockMode); err != nil {
+			return nil, err
+		}
+		w.user = anonymiser.Anonymise("user", w.user)
+		w.name = lib.TableName(schema, table)
+		waits = append(waits, w)
+	}
+
+	return waits, rows.Err()
+}
+
+// selectRows returns the connections waiting for a lock now. The InnoDB
+// lock waits are only read if data_lock_waits is there.
+func selectRows(dbh *sql.DB, schemas *schema_filter.Filter, haveLockWaits bool) (Rows, error) {
+	condition, args := schemas.And("w.OBJECT_SCHEMA", "w.OBJECT_NAME")
+	waits, err := selectWaits(dbh, metadataLock, metadataWaitsQuery, condition, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if haveLockWaits {
+		condition, args := schemas.Where("l.OBJECT_SCHEMA", "l.OBJECT_NAME")
+		innodbWaits, err := selectWaits(dbh, innodbLock, innodbWaitsQuery, condition, args)
+		if err != nil {
+			return nil, err
+		}
+		waits = append(waits, innodbWaits...)
+	}
+
+	t := byWaiter(waits)
+	logger.Println("lock_waiters.selectRows() found", len(t), "connection(s) waiting")
+
+	return t, nil
+}
+
+// byWaiter returns a row for each connection waiting for a kind of lock
+// with the connections it waits for, as a connection may wait for a
+// lock held by several
+func byWaiter(waits []wait) Rows {
+	type key struct {
+		kind   string
+		waiter uint64
+	}
+	var t Rows
+	index := make(map[key]int)
+
+	for _, w := range waits {
+		k := key{w.kind, w.waiter}
+		i, found := index[k]
+		if !found {
+			i = len(t)
+			index[k] = i
+			t = append(t, w.Row)
+		}
+		if !containsID(t[i].blockers, w.blocker) {
+			t[i].blockers = append(t[i].blockers, w.blocker)
+		}
+	}
+	for i := range t {
+		sort.Slice(t[i].blockers, func(a, b int) bool { return t[i].blockers[a] < t[i].blockers[b] })
+	}
+
+	return t
+}
+
+// containsID returns true if the id is in the list
+func containsID(ids []uint64, id uint64) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blockedBy returns the ids of the connections holding the lock
+func (row Row) blockedBy() string {
+	ids := make([]string, 0, len(row.blockers))
+
+	for _, id := range row.blockers {
+		ids = append(ids, fmt.Sprint(id))
+	}
+
+	return strings.Join(ids, ",")
+}
+
+// totals returns the time the connections have been waiting altogether
+func (rows Rows) totals() Row {
+	total := Row{name: "Totals"}
+
+	for i := range rows {
+		total.waitTime += rows[i].waitTime
+	}
+
+	return total
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"wait":    func(i, j int) int { return sort_keys.Descending(rows[i].waitTime, rows[j].waitTime) },
+		"id":      func(i, j int) int { return sort_keys.Descending(rows[j].waiter, rows[i].waiter) }, // ascending
+		"user":    func(i, j int) int { return sort_keys.Ascending(rows[i].user, rows[j].user) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+		"lock":    func(i, j int) int { return sort_keys.Ascending(rows[i].kind, rows[j].kind) },
+		"blocker": func(i, j int) int { return sort_keys.Ascending(rows[i].blockedBy(), rows[j].blockedBy()) },
+	}
+}
+
+// sort by the time waiting (descending) and then by the connection
+// waiting (ascending) after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("lock_waiters", "wait", "id"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("lock_waiters", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func headings() string {
+	return fmt.Sprintf("%-8s %-6s %-20s|%8s %-12s %-16s|%s", "WaitTime", "Lock", "Mode", "Waiter", "User", "Blocked By", "Table")
+}
+
+// generate a printable result
+func (row *Row) rowContent() string {
+	waiter := ""
+	if row.kind != "" {
+		waiter = fmt.Sprint(row.waiter)
+	}
+
+	return fmt.Sprintf("%-8s %-6s %-20s|%8s %-12s %-16s|%s",
+		lib.FormatSeconds(row.waitTime),
+		row.kind,
+		row.lockMode,
+		waiter,
+		row.user,
+		row.blockedBy(),
+		row.name)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%d waits for %s: %ds %s %s %s", row.waiter, row.blockedBy(), row.waitTime, row.kind, row.name, row.lockMode)
+}
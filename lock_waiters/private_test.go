@@ -0,0 +1,41 @@
+package lock_waiters
+
+import (
+	"testing"
+)
+
+func TestByWaiter(t *testing.T) {
+	// 11 waits for a metadata lock held by 10 and 12, and for an InnoDB
+	// lock held by 12. 13 waits for 12, seen twice.
+	waits := []wait{
+		{Row{kind: metadataLock, waiter: 11, waitTime: 5}, 12},
+		{Row{kind: metadataLock, waiter: 11, waitTime: 5}, 10},
+		{Row{kind: innodbLock, waiter: 11, waitTime: 2}, 12},
+		{Row{kind: innodbLock, waiter: 13, waitTime: 9}, 12},
+		{Row{kind: innodbLock, waiter: 13, waitTime: 9}, 12},
+	}
+
+	var tests = []struct {
+		kind     string
+		waiter   uint64
+		blockers string
+	}{
+		{metadataLock, 11, "10,12"},
+		{innodbLock, 11, "12"},
+		{innodbLock, 13, "12"},
+	}
+	rows := byWaiter(waits)
+	if len(rows) != len(tests) {
+		t.Fatalf("byWaiter(): expected %d rows, got %d", len(tests), len(rows))
+	}
+	for i, test := range tests {
+		if rows[i].kind != test.kind || rows[i].waiter != test.waiter || rows[i].blockedBy() != test.blockers {
+			t.Errorf("byWaiter()[%d]: expected %s %d blocked by %s, got %s %d blocked by %s",
+				i, test.kind, test.waiter, test.blockers, rows[i].kind, rows[i].waiter, rows[i].blockedBy())
+		}
+	}
+
+	if total := rows.totals().waitTime; total != 16 {
+		t.Errorf("totals(): expected a wait time of 16, got %d", total)
+	}
+}
@@ -0,0 +1,110 @@
+// Package lock_waiters shows the connections waiting for a lock right
+// now, for metadata locks and InnoDB locks alike, with how long they have
+// been waiting and the connections holding the lock. This answers who is
+// stuck now rather than which tables have had the most lock latency.
+package lock_waiters
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/table"
+)
+
+// Object holds the connections waiting for a lock
+type Object struct {
+	baseobject.BaseObject       // embedded
+	current               Rows  // last loaded values
+	totals                Row   // totals of current
+	lockWaits             *bool // is data_lock_waits there? (nil if not checked)
+}
+
+// NewLockWaiters returns a pointer to an object of this type
+func NewLockWaiters(ctx *context.Context) *Object {
+	logger.Println("NewLockWaiters()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the connections waiting for a lock. There are no
+// relative values as this is the current state.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	if t.lockWaits == nil {
+		access := table.NewAccess("performance_schema", "data_lock_waits")
+		found := access.CheckSelectError(dbh) == nil
+		t.lockWaits = &found
+	}
+
+	rows, err := selectRows(dbh, t.SchemaFilter(), *t.lockWaits)
+	if err != nil {
+		return err
+	}
+	rows.sort()
+	t.current = rows
+	t.totals = rows.totals()
+	t.SetLastCollectTimeNow()
+
+	logger.Println("lock_waiters.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+
+	for i := range t.current {
+		rows = append(rows, t.current[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the time all the connections have been waiting
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent()
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	source := "metadata_locks"
+	if t.lockWaits != nil && *t.lockWaits {
+		source += ", data_lock_waits"
+	}
+
+	return fmt.Sprintf("Lock Waiters Now (%s) %d connection(s) waiting", source, len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as we show the current state
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("lock_waiters.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
@@ -0,0 +1,222 @@
+package lock_waits
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// node is a connection taking part in a lock wait chain
+type node struct {
+	id       uint64
+	waitTime uint64 // seconds this connection has been waiting (0 if not waiting)
+	name     string // table being waited for
+	lockMode string // lock mode being waited for
+	waiting  bool
+	children []*node // connections waiting on this one
+}
+
+// line is a single entry of a rendered chain
+type line struct {
+	depth int
+	node  *node
+}
+
+// Chain holds one blocking tree starting with the connection at the
+// head of the chain and all the connections waiting behind it.
+type Chain struct {
+	lines   []line
+	waiters int    // number of distinct connections blocked by the head
+	depth   int    // maximum depth of the tree
+	blocked uint64 // total seconds the blocked connections have been waiting
+}
+
+// Chains contains a slice of Chain
+type Chains []Chain
+
+// buildChains converts a list of waiter/blocker relationships into
+// trees of blocking connections. A connection blocked by more than
+// one other connection is shown under each blocker but only counted
+// once per chain. Cycles (deadlocks being resolved) are broken by
+// starting the chain at the lowest connection id in the cycle.
+func buildChains(rows Rows) Chains {
+	nodes := make(map[uint64]*node)
+	get := func(id uint64) *node {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+		n := &node{id: id}
+		nodes[id] = n
+		return n
+	}
+
+	for i := range rows {
+		waiter := get(rows[i].waiter)
+		blocker := get(rows[i].blocker)
+
+		waiter.waiting = true
+		if rows[i].waitTime >= waiter.waitTime {
+			waiter.waitTime = rows[i].waitTime
+			waiter.name = rows[i].name
+			waiter.lockMode = rows[i].lockMode
+		}
+		blocker.children = append(blocker.children, waiter)
+	}
+
+	// order everything by id so output is stable between collections
+	ids := make([]uint64, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+		sort.Sort(byID(nodes[id].children))
+	}
+	sort.Sort(uint64s(ids))
+
+	var chains Chains
+	seen := make(map[uint64]bool)
+
+	// heads of chains which are not waiting themselves
+	for _, id := range ids {
+		if n := nodes[id]; !n.waiting && len(n.children) > 0 {
+			chains = append(chains, newChain(n, seen))
+		}
+	}
+	// anything left over is part of a cycle
+	for _, id := range ids {
+		if n := nodes[id]; !seen[id] && len(n.children) > 0 {
+			chains = append(chains, newChain(n, seen))
+		}
+	}
+
+	chains.sort()
+
+	return chains
+}
+
+// newChain walks the tree below head recording the lines to show
+func newChain(head *node, seen map[uint64]bool) Chain {
+	var c Chain
+	counted := make(map[uint64]bool)
+	onPath := make(map[uint64]bool)
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		c.lines = append(c.lines, line{depth: depth, node: n})
+		seen[n.id] = true
+		if depth > c.depth {
+			c.depth = depth
+		}
+		if depth > 0 && !counted[n.id] {
+			counted[n.id] = true
+			c.waiters++
+			c.blocked += n.waitTime
+		}
+		if onPath[n.id] {
+			return // cycle, don't go round again
+		}
+		onPath[n.id] = true
+		for _, child := range n.children {
+			walk(child, depth+1)
+		}
+		onPath[n.id] = false
+	}
+	walk(head, 0)
+
+	return c
+}
+
+// rowContent returns the printable lines of the chain
+func (c Chain) rowContent() []string {
+	s := make([]string, 0, len(c.lines))
+
+	for _, l := range c.lines {
+		if l.depth == 0 {
+			s = append(s, fmt.Sprintf("%8s|%8s %7d %5d|%d",
+				lib.FormatSeconds(l.node.waitTime),
+				lib.FormatSeconds(c.blocked),
+				c.waiters,
+				c.depth,
+				l.node.id))
+			continue
+		}
+		s = append(s, fmt.Sprintf("%8s|%8s %7s %5s|%s+- %d %s %s",
+			lib.FormatSeconds(l.node.waitTime),
+			"",
+			"",
+			"",
+			strings.Repeat("   ", l.depth-1),
+			l.node.id,
+			l.node.name,
+			l.node.lockMode))
+	}
+
+	return s
+}
+
+// totals returns the values summed over all the chains
+func (chains Chains) totals() Chain {
+	var totals Chain
+
+	for i := range chains {
+		totals.waiters += chains[i].waiters
+		totals.blocked += chains[i].blocked
+		if chains[i].depth > totals.depth {
+			totals.depth = chains[i].depth
+		}
+	}
+
+	return totals
+}
+
+// totalRowContent returns a printable row of totals
+func (chains Chains) totalRowContent() string {
+	totals := chains.totals()
+
+	return fmt.Sprintf("%8s|%8s %7s %5s|%s",
+		"",
+		lib.FormatSeconds(totals.blocked),
+		lib.FormatCounter(totals.waiters, 7),
+		lib.FormatCounter(totals.depth, 5),
+		"Totals")
+}
+
+// sortKeys returns the keys the chains may be sorted by
+func (chains Chains) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"blocked": func(i, j int) int { return sort_keys.Descending(chains[i].blocked, chains[j].blocked) },
+		"waiters": func(i, j int) int {
+			return sort_keys.Descending(uint64(chains[i].waiters), uint64(chains[j].waiters))
+		},
+		"depth": func(i, j int) int {
+			return sort_keys.Descending(uint64(chains[i].depth), uint64(chains[j].depth))
+		},
+		"id": func(i, j int) int {
+			return -sort_keys.Descending(chains[i].lines[0].node.id, chains[j].lines[0].node.id)
+		},
+	}
+}
+
+// sort by blocked time (descending) but also by head id (ascending) if the values are the same
+// after any configured sort keys
+func (chains Chains) sort() {
+	sort.Slice(chains, chains.sortKeys().Less("lock_waits", "blocked", "id"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("lock_waits", Chains(nil).sortKeys())
+}
+
+type byID []*node
+
+func (n byID) Len() int           { return len(n) }
+func (n byID) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+func (n byID) Less(i, j int) bool { return n[i].id < n[j].id }
+
+type uint64s []uint64
+
+func (u uint64s) Len() int           { return len(u) }
+func (u uint64s) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u uint64s) Less(i, j int) bool { return u[i] < u[j] }
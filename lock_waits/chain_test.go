@@ -0,0 +1,68 @@
+package lock_waits
+
+import (
+	"testing"
+)
+
+func TestBuildChains(t *testing.T) {
+	// 10 blocks 11 and 12, 12 blocks 13. 20 blocks 21.
+	rows := Rows{
+		{waiter: 11, blocker: 10, waitTime: 5},
+		{waiter: 12, blocker: 10, waitTime: 7},
+		{waiter: 13, blocker: 12, waitTime: 3},
+		{waiter: 21, blocker: 20, waitTime: 60},
+	}
+
+	chains := buildChains(rows)
+	if len(chains) != 2 {
+		t.Fatalf("buildChains(): expected 2 chains, got %d", len(chains))
+	}
+
+	// longest blocked time first
+	var tests = []struct {
+		head    uint64
+		lines   int
+		waiters int
+		depth   int
+		blocked uint64
+	}{
+		{20, 2, 1, 1, 60},
+		{10, 4, 3, 2, 15},
+	}
+	for i, test := range tests {
+		c := chains[i]
+		if c.lines[0].node.id != test.head {
+			t.Errorf("chain %d: expected head %d, got %d", i, test.head, c.lines[0].node.id)
+		}
+		if len(c.lines) != test.lines {
+			t.Errorf("chain %d: expected %d lines, got %d", i, test.lines, len(c.lines))
+		}
+		if c.waiters != test.waiters {
+			t.Errorf("chain %d: expected %d waiters, got %d", i, test.waiters, c.waiters)
+		}
+		if c.depth != test.depth {
+			t.Errorf("chain %d: expected depth %d, got %d", i, test.depth, c.depth)
+		}
+		if c.blocked != test.blocked {
+			t.Errorf("chain %d: expected blocked %d, got %d", i, test.blocked, c.blocked)
+		}
+	}
+}
+
+func TestBuildChainsCycle(t *testing.T) {
+	rows := Rows{
+		{waiter: 2, blocker: 1, waitTime: 4},
+		{waiter: 1, blocker: 2, waitTime: 6},
+	}
+
+	chains := buildChains(rows)
+	if len(chains) != 1 {
+		t.Fatalf("buildChains(): expected 1 chain, got %d", len(chains))
+	}
+	if chains[0].lines[0].node.id != 1 {
+		t.Errorf("expected cycle to start at 1, got %d", chains[0].lines[0].node.id)
+	}
+	if chains[0].waiters != 2 {
+		t.Errorf("expected 2 waiters, got %d", chains[0].waiters)
+	}
+}
@@ -0,0 +1,102 @@
+// Package lock_waits contains the library routines for managing
+// performance_schema.data_lock_waits.
+package lock_waits
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/schema_filter"
+)
+
+/*
+
+From 8.0
+
+CREATE TABLE `data_lock_waits` (
+  `ENGINE` varchar(32) NOT NULL,
+  `REQUESTING_ENGINE_LOCK_ID` varchar(128) NOT NULL,
+  `REQUESTING_ENGINE_TRANSACTION_ID` bigint unsigned DEFAULT NULL,
+  `REQUESTING_THREAD_ID` bigint unsigned DEFAULT NULL,
+  `REQUESTING_EVENT_ID` bigint unsigned DEFAULT NULL,
+  `REQUESTING_OBJECT_INSTANCE_BEGIN` bigint unsigned NOT NULL,
+  `BLOCKING_ENGINE_LOCK_ID` varchar(128) NOT NULL,
+  `BLOCKING_ENGINE_TRANSACTION_ID` bigint unsigned DEFAULT NULL,
+  `BLOCKING_THREAD_ID` bigint unsigned DEFAULT NULL,
+  `BLOCKING_EVENT_ID` bigint unsigned DEFAULT NULL,
+  `BLOCKING_OBJECT_INSTANCE_BEGIN` bigint unsigned NOT NULL
+) ENGINE=PERFORMANCE_SCHEMA DEFAULT CHARSET=utf8mb4
+
+*/
+
+// Row holds a single "waiter is blocked by blocker" relationship
+type Row struct {
+	waiter   uint64 // processlist id of the waiting connection
+	blocker  uint64 // processlist id of the blocking connection
+	waitTime uint64 // seconds the waiter has been waiting
+	name     string // <schema>.<table> the waiter is waiting for
+	lockMode string // lock mode requested by the waiter
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// select the current lock waits
+func selectRows(dbh *sql.DB, schemas *schema_filter.Filter) (Rows, error) {
+	var t Rows
+
+	sql := `
+SELECT	r.trx_mysql_thread_id,
+	b.trx_mysql_thread_id,
+	COALESCE(TIMESTAMPDIFF(SECOND, r.trx_wait_started, NOW()), 0),
+	COALESCE(l.OBJECT_SCHEMA, ''),
+	COALESCE(l.OBJECT_NAME, ''),
+	COALESCE(l.LOCK_MODE, '')
+FROM	performance_schema.data_lock_waits w
+JOIN	information_schema.INNODB_TRX r ON r.trx_id = w.REQUESTING_ENGINE_TRANSACTION_ID
+JOIN	information_schema.INNODB_TRX b ON b.trx_id = w.BLOCKING_ENGINE_TRANSACTION_ID
+LEFT JOIN performance_schema.data_locks l ON l.ENGINE_LOCK_ID = w.REQUESTING_ENGINE_LOCK_ID`
+	condition, args := schemas.Where("l.OBJECT_SCHEMA", "l.OBJECT_NAME")
+
+	rows, err := dbh.Query(sql+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		var schema, table string
+
+		if err := rows.Scan(
+			&r.waiter,
+			&r.blocker,
+			&r.waitTime,
+			&schema,
+			&table,
+			&r.lockMode); err != nil {
+			return nil, err
+		}
+		r.name = lib.TableName(schema, table)
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("lock_waits.selectRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// Wait Time|Blocked  Waiters Depth|Lock Chain
+// hh:mm:ss |hh:mm:ss    9999  9999|xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
+func headings() string {
+	return fmt.Sprintf("%8s|%8s %7s %5s|%s", "WaitTime", "Blocked", "Waiters", "Depth", "Lock Chain")
+}
+
+// String describes a whole row
+func (r Row) String() string {
+	return fmt.Sprintf("%d waits for %d: %ds %s %s", r.waiter, r.blocker, r.waitTime, r.name, r.lockMode)
+}
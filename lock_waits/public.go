@@ -0,0 +1,94 @@
+// Package lock_waits shows which connections are blocking which others
+// based on performance_schema.data_lock_waits (MySQL 8.0+).
+package lock_waits
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the current lock waits and the chains built from them
+type Object struct {
+	baseobject.BaseObject        // embedded
+	current               Rows   // last loaded values
+	chains                Chains // blocking chains built from current
+}
+
+// NewLockWaits returns a pointer to an object of this type
+func NewLockWaits(ctx *context.Context) *Object {
+	logger.Println("NewLockWaits()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the current lock waits and builds the blocking chains.
+// There are no relative values as this is the current state.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh, t.SchemaFilter())
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	t.chains = buildChains(t.current)
+
+	logger.Println("lock_waits.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the chains as indented trees
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current)+len(t.chains))
+
+	for i := range t.chains {
+		rows = append(rows, t.chains[i].rowContent()...)
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the totals over all chains
+func (t Object) TotalRowContent() string {
+	return t.chains.totalRowContent()
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	return fmt.Sprintf("%8s|%8s %7s %5s|", "", "", "", "")
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	return fmt.Sprintf("Lock Wait Chains (data_lock_waits) %d chain(s), %d wait(s)", len(t.chains), len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.RowContent())
+}
+
+// HaveRelativeStats is false as we show the current state
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("lock_waits.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
@@ -6,11 +6,11 @@ import (
 	"database/sql"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql" // keep glint happy
-	"log"
 	"sort"
 
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/sort_keys"
 )
 
 /* This table exists in MySQL 5.7 but not 5.6
@@ -42,6 +42,17 @@ type Row struct {
 	totalBytesManaged uint64
 }
 
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"eventName":         func(r *Row) interface{} { return &r.name },
+	"currentCountUsed":  func(r *Row) interface{} { return &r.currentCountUsed },
+	"highCountUsed":     func(r *Row) interface{} { return &r.highCountUsed },
+	"currentBytesUsed":  func(r *Row) interface{} { return &r.currentBytesUsed },
+	"highBytesUsed":     func(r *Row) interface{} { return &r.highBytesUsed },
+	"totalMemoryOps":    func(r *Row) interface{} { return &r.totalMemoryOps },
+	"totalBytesManaged": func(r *Row) interface{} { return &r.totalBytesManaged },
+}
+
 // Rows contains multiple rows
 type Rows []Row
 
@@ -77,9 +88,6 @@ func (r *Row) add(other Row) {
 	r.currentCountUsed += other.currentCountUsed
 }
 
-func (r *Row) subtract(other Row) {
-}
-
 // return the totals of a slice of rows
 func (t Rows) totals() Row {
 	var totals Row
@@ -92,29 +100,8 @@ func (t Rows) totals() Row {
 	return totals
 }
 
-// catch a SELECT error - specifically this one.
-// Error 1146: Table 'performance_schema.memory_summary_global_by_event_name' doesn't exist
-func sqlErrorHandler(err error) bool {
-	var ignore bool
-
-	logger.Println("- SELECT gave an error:", err.Error())
-	if err.Error()[0:11] != "Error 1146:" {
-		fmt.Println(fmt.Sprintf("XXX'%s'XXX", err.Error()[0:11]))
-		log.Fatal("Unexpected error", fmt.Sprintf("XXX'%s'XXX", err.Error()[0:11]))
-		// log.Fatal("Unexpected error:", err.Error())
-	} else {
-		logger.Println("- expected error, so ignoring")
-		ignore = true
-	}
-
-	return ignore
-}
-
 // Select the raw data from the database
-func selectRows(dbh *sql.DB) Rows {
-	var t Rows
-	var skip bool
-
+func selectRows(dbh *sql.DB) (Rows, error) {
 	sql := `-- memory_usage
 SELECT	EVENT_NAME                                           AS eventName,
 	CURRENT_COUNT_USED                                   AS currentCountUsed,
@@ -127,77 +114,38 @@ FROM	memory_summary_global_by_event_name
 WHERE	HIGH_COUNT_USED > 0`
 
 	logger.Println("Querying db:", sql)
-	rows, err := dbh.Query(sql)
+	t, err := lib.ReadRowsFromSQL(dbh, columns, sql)
 	if err != nil {
-		// FIXME - This should be caught by the validateViews() upstream but isn't for initial
-		// FIXME   table collection. I'm waiting to clean up by splitting views and models but
-		// FIXME   that has not been done yet so for now work aruond the initial app.CollectAll()
-		// FIXME   by simply ignoring a request if the table does not exist.
-		skip = sqlErrorHandler(err) // temporarily catch a SELECT error. // should not be necessary now
+		return nil, err
 	}
 
-	if !skip {
-		defer rows.Close()
-
-		for rows.Next() {
-			var r Row
-			if err := rows.Scan(
-				&r.name,
-				&r.currentCountUsed,
-				&r.highCountUsed,
-				&r.currentBytesUsed,
-				&r.highBytesUsed,
-				&r.totalMemoryOps,
-				&r.totalBytesManaged); err != nil {
-				log.Fatal(err)
-			}
-			t = append(t, r)
-		}
-		if err := rows.Err(); err != nil {
-			log.Fatal(err)
-		}
-	}
-
-	return t
+	return t, nil
 }
 
-func (t Rows) Len() int      { return len(t) }
-func (t Rows) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
-func (t Rows) Less(i, j int) bool {
-	return (t[i].currentBytesUsed > t[j].currentBytesUsed) ||
-		((t[i].currentBytesUsed == t[j].currentBytesUsed) &&
-			//	return (t[i].totalMemoryOps > t[j].totalMemoryOps) ||
-			//		((t[i].totalMemoryOps == t[j].totalMemoryOps) &&
-			(t[i].name < t[j].name))
-
+// sortKeys returns the keys the rows may be sorted by
+func (t Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"current_bytes": func(i, j int) int { return sort_keys.SignedDescending(t[i].currentBytesUsed, t[j].currentBytesUsed) },
+		"high_bytes":    func(i, j int) int { return sort_keys.SignedDescending(t[i].highBytesUsed, t[j].highBytesUsed) },
+		"current_count": func(i, j int) int { return sort_keys.SignedDescending(t[i].currentCountUsed, t[j].currentCountUsed) },
+		"high_count":    func(i, j int) int { return sort_keys.SignedDescending(t[i].highCountUsed, t[j].highCountUsed) },
+		"ops":           func(i, j int) int { return sort_keys.SignedDescending(t[i].totalMemoryOps, t[j].totalMemoryOps) },
+		"name":          func(i, j int) int { return sort_keys.Ascending(t[i].name, t[j].name) },
+	}
 }
 
-// sort the data
+// sort the data by current bytes used (descending) and name after any configured sort keys
 func (t *Rows) sort() {
-	sort.Sort(t)
+	sort.Slice(*t, t.sortKeys().Less("memory_usage", "current_bytes", "name"))
 }
 
-// remove the initial values from those rows where there's a match
-// - if we find a row we can't match ignore it
-func (t *Rows) subtract(initial Rows) {
-	iByName := make(map[string]int)
-
-	// iterate over rows by name
-	for i := range initial {
-		iByName[initial[i].name] = i
-	}
-
-	for i := range *t {
-		if _, ok := iByName[(*t)[i].name]; ok {
-			initialI := iByName[(*t)[i].name]
-			(*t)[i].subtract(initial[initialI])
-		}
-	}
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("memory_usage", Rows(nil).sortKeys())
 }
 
 func (t *Object) makeResults() {
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
+	t.results = append(t.results[:0], t.current...)
 	t.results.sort()
 	t.totals = t.results.totals()
 }
@@ -32,11 +32,17 @@ func NewMemoryUsage(ctx *context.Context) *Object {
 }
 
 // Collect data from the db, no merging needed
-func (t *Object) Collect(dbh *sql.DB) {
-	t.current = selectRows(dbh)
+func (t *Object) Collect(dbh *sql.DB) error {
+	rows, err := selectRows(dbh)
+	if err != nil {
+		return err
+	}
+	t.current = rows
 	t.SetLastCollectTimeNow()
 
 	t.makeResults()
+
+	return nil
 }
 
 // SetInitialFromCurrent resets the statistics to current values
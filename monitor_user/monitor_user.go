@@ -0,0 +1,213 @@
+// Package monitor_user builds the statements which create a dedicated
+// account for ps-top to monitor a server with, granting only what it
+// needs for the server's version.
+package monitor_user
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultAccount is the account created if no other is given
+const DefaultAccount = "pstop@localhost"
+
+// Statement is a statement with a comment explaining why it is needed.
+// Optional statements are only needed by some features and are not run.
+type Statement struct {
+	Comment  string
+	SQL      string
+	Optional bool
+}
+
+// String returns the statement with its comment, commented out if it
+// is optional
+func (s Statement) String() string {
+	if s.Optional {
+		return "-- " + s.Comment + "\n-- " + s.SQL + ";"
+	}
+
+	return "-- " + s.Comment + "\n" + s.SQL + ";"
+}
+
+// version is a server version, e.g. 8.0.36 or 10.11.6-MariaDB
+type version struct {
+	major, minor, patch int
+	mariaDB             bool
+}
+
+// matches the numbers at the start of a version
+var versionNumbers = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// parseVersion returns the version of the server, e.g. given its
+// version setting
+func parseVersion(s string) (version, error) {
+	m := versionNumbers.FindStringSubmatch(s)
+	if m == nil {
+		return version{}, fmt.Errorf("unable to understand the server version %q", s)
+	}
+	v := version{mariaDB: strings.Contains(strings.ToLower(s), "mariadb")}
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+
+	return v, nil
+}
+
+// atLeast returns true if the version is the one given or later
+func (v version) atLeast(major, minor, patch int) bool {
+	if v.major != major {
+		return v.major > major
+	}
+	if v.minor != minor {
+		return v.minor > minor
+	}
+
+	return v.patch >= patch
+}
+
+// quote returns the string as a quoted SQL literal, doubling any single
+// quotes so it means the same whatever the sql_mode
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// splitAccount returns the user and host of the account (user@host),
+// with the host % if none is given. A backslash is refused as it means
+// something different with NO_BACKSLASH_ESCAPES.
+func splitAccount(account string) (string, string, error) {
+	user, host := account, "%"
+	if i := strings.LastIndex(account, "@"); i >= 0 {
+		user, host = account[:i], account[i+1:]
+	}
+	if user == "" || host == "" {
+		return "", "", fmt.Errorf("the account %q should be given as user@host", account)
+	}
+	if strings.Contains(account, `\`) {
+		return "", "", fmt.Errorf("the account %q should not contain a backslash", account)
+	}
+
+	return user, host, nil
+}
+
+// accountName returns the account (user@host) quoted for SQL
+func accountName(account string) (string, error) {
+	user, host, err := splitAccount(account)
+	if err != nil {
+		return "", err
+	}
+
+	return quote(user) + "@" + quote(host), nil
+}
+
+// Statements returns the statements which create the account (user@host)
+// with the password and grant it what ps-top needs on a server with the
+// given version
+func Statements(serverVersion, account, password string) ([]Statement, error) {
+	v, err := parseVersion(serverVersion)
+	if err != nil {
+		return nil, err
+	}
+	name, err := accountName(account)
+	if err != nil {
+		return nil, err
+	}
+
+	create := "CREATE USER " + name + " IDENTIFIED BY " + quote(password)
+	if (v.mariaDB && v.atLeast(10, 1, 3)) || (!v.mariaDB && v.atLeast(5, 7, 0)) {
+		create = "CREATE USER IF NOT EXISTS " + name + " IDENTIFIED BY " + quote(password)
+	}
+	binlog := "REPLICATION CLIENT"
+	binlogEvents := "REPLICATION SLAVE" // SHOW BINLOG EVENTS
+	if v.mariaDB && v.atLeast(10, 5, 2) {
+		binlog, binlogEvents = "BINLOG MONITOR", ""
+	}
+	kill := "SUPER"
+	switch {
+	case v.mariaDB && v.atLeast(10, 5, 2):
+		kill = "CONNECTION ADMIN"
+	case !v.mariaDB && v.atLeast(8, 0, 0):
+		kill = "CONNECTION_ADMIN"
+	}
+
+	statements := []Statement{
+		{Comment: "the monitoring account, an existing account keeps its password", SQL: create},
+		{Comment: "read the performance_schema tables the views are based on", SQL: "GRANT SELECT ON performance_schema.* TO " + name},
+		{Comment: "enable the instruments the views need (I key), restored on exit", SQL: "GRANT UPDATE ON performance_schema.setup_instruments TO " + name},
+		{Comment: "enable the consumers the view needs (C and E keys)", SQL: "GRANT UPDATE ON performance_schema.setup_consumers TO " + name},
+		{Comment: "see the InnoDB transactions in lock_waits and lock_users, and the binary log position in binlog_events", SQL: "GRANT PROCESS, " + binlog + " ON *.* TO " + name},
+	}
+	if binlogEvents != "" {
+		statements = append(statements, Statement{Comment: "only for binlog_events: read the events with SHOW BINLOG EVENTS, which also allows replicating from the server", SQL: "GRANT " + binlogEvents + " ON *.* TO " + name, Optional: true})
+	}
+
+	return append(statements,
+		Statement{Comment: "only for --enforce: kill the statements matching a watchdog kill rule", SQL: "GRANT " + kill + " ON *.* TO " + name, Optional: true},
+		Statement{Comment: "only for key_cache, unused_indexes and the file I/O per row: information_schema only lists the tables an account has privileges on, this also allows reading them", SQL: "GRANT SELECT ON *.* TO " + name, Optional: true},
+	), nil
+}
+
+// NewPassword returns a random password with upper and lower case
+// letters, digits and punctuation so it passes validate_password
+func NewPassword() (string, error) {
+	classes := []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnopqrstuvwxyz", "23456789", "-_.:+="}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, 0, 24)
+	for len(password) < cap(password) {
+		chars := all
+		if len(password) < len(classes) {
+			chars = classes[len(password)] // one of each class first
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		password = append(password, chars[n.Int64()])
+	}
+
+	// shuffle so the classes aren't always at the start
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[n.Int64()] = password[n.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+// Exists returns true if the account (user@host) is already there
+func Exists(dbh *sql.DB, account string) (bool, error) {
+	user, host, err := splitAccount(account)
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	if err := dbh.QueryRow("SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?", user, host).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Create runs the statements which aren't optional, stopping at the
+// first which fails
+func Create(dbh *sql.DB, statements []Statement) error {
+	for _, s := range statements {
+		if s.Optional {
+			continue
+		}
+		if _, err := dbh.Exec(s.SQL); err != nil {
+			return fmt.Errorf("%s: %v", s.SQL, err)
+		}
+	}
+
+	return nil
+}
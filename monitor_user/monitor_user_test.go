@@ -0,0 +1,94 @@
+package monitor_user
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		version  string
+		create   string
+		privs    string
+		kill     string
+		optional int
+	}{
+		{"5.6.51-log", "CREATE USER 'pstop'@'localhost'", "PROCESS, REPLICATION CLIENT", "SUPER", 3},
+		{"5.7.44", "CREATE USER IF NOT EXISTS 'pstop'@'localhost'", "PROCESS, REPLICATION CLIENT", "SUPER", 3},
+		{"8.0.36", "CREATE USER IF NOT EXISTS 'pstop'@'localhost'", "PROCESS, REPLICATION CLIENT", "CONNECTION_ADMIN", 3},
+		{"10.0.38-MariaDB", "CREATE USER 'pstop'@'localhost'", "PROCESS, REPLICATION CLIENT", "SUPER", 3},
+		{"10.11.6-MariaDB-log", "CREATE USER IF NOT EXISTS 'pstop'@'localhost'", "PROCESS, BINLOG MONITOR", "CONNECTION ADMIN", 2},
+	}
+
+	for _, test := range tests {
+		statements, err := Statements(test.version, DefaultAccount, "secret")
+		if err != nil {
+			t.Fatalf("Statements(%q) gave an error: %v", test.version, err)
+		}
+		all := make([]string, 0, len(statements))
+		optional := 0
+		for _, s := range statements {
+			all = append(all, s.SQL)
+			if s.Optional {
+				optional++
+			}
+			if strings.Contains(s.SQL, "REPLICATION SLAVE") && !s.Optional {
+				t.Errorf("Statements(%q) requires REPLICATION SLAVE, which is only needed by binlog_events", test.version)
+			}
+		}
+		joined := strings.Join(all, "\n")
+		for _, want := range []string{
+			test.create + " IDENTIFIED BY 'secret'",
+			"GRANT SELECT ON performance_schema.* TO 'pstop'@'localhost'",
+			"GRANT " + test.privs + " ON *.* TO 'pstop'@'localhost'",
+			"GRANT " + test.kill + " ON *.* TO 'pstop'@'localhost'",
+		} {
+			if !strings.Contains(joined, want+"\n") {
+				t.Errorf("Statements(%q) doesn't include %q:\n%s", test.version, want, joined)
+			}
+		}
+		if optional != test.optional {
+			t.Errorf("Statements(%q) has %d optional statement(s), want %d", test.version, optional, test.optional)
+		}
+	}
+
+	if _, err := Statements("unknown", DefaultAccount, "secret"); err == nil {
+		t.Errorf("Statements(\"unknown\") gave no error")
+	}
+}
+
+func TestAccountName(t *testing.T) {
+	tests := map[string]string{
+		"pstop@localhost":  "'pstop'@'localhost'",
+		"pstop":            "'pstop'@'%'",
+		"mon@10.0.0.%":     "'mon'@'10.0.0.%'",
+		"o'brien@db1":      `'o''brien'@'db1'`,
+		"user@name@remote": "'user@name'@'remote'",
+	}
+
+	for account, want := range tests {
+		if got, err := accountName(account); err != nil || got != want {
+			t.Errorf("accountName(%q) = %q, %v, want %q", account, got, err, want)
+		}
+	}
+	for _, account := range []string{"@localhost", `pstop\@localhost`} {
+		if _, err := accountName(account); err == nil {
+			t.Errorf("accountName(%q) gave no error", account)
+		}
+	}
+}
+
+func TestNewPassword(t *testing.T) {
+	password, err := NewPassword()
+	if err != nil {
+		t.Fatalf("NewPassword() gave an error: %v", err)
+	}
+	if len(password) != 24 {
+		t.Errorf("NewPassword() = %q, want 24 characters", password)
+	}
+	for _, class := range []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnopqrstuvwxyz", "23456789", "-_.:+="} {
+		if !strings.ContainsAny(password, class) {
+			t.Errorf("NewPassword() = %q, want one of %q", password, class)
+		}
+	}
+}
@@ -5,12 +5,14 @@ package mutex_latency
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 
+	"github.com/sjmudd/anonymiser"
 	"github.com/sjmudd/ps-top/lib"
-	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
 )
 
 // Row contains a row from performance_schema.events_waits_summary_global_by_event_name
@@ -20,12 +22,27 @@ type Row struct {
 	name         string
 	sumTimerWait uint64
 	countStar    uint64
+	user         sql.NullString // only collected by account
+	host         sql.NullString // only collected by account
+}
+
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"EVENT_NAME":     func(r *Row) interface{} { return &r.name },
+	"SUM_TIMER_WAIT": func(r *Row) interface{} { return &r.sumTimerWait },
+	"COUNT_STAR":     func(r *Row) interface{} { return &r.countStar },
+	"USER":           func(r *Row) interface{} { return &r.user },
+	"HOST":           func(r *Row) interface{} { return &r.host },
 }
 
 // Rows contains a slice of Row
 type Rows []Row
 
-func (row *Row) headings() string {
+// headings returns the headings, which by account also include the file I/O waits
+func (row *Row) headings(byAccount bool) string {
+	if byAccount {
+		return fmt.Sprintf("%10s %8s %8s|%s", "Latency", "Count", "%", "Account: Mutex or File I/O Name")
+	}
 	return fmt.Sprintf("%10s %8s %8s|%s", "Latency", "MtxCnt", "%", "Mutex Name")
 }
 
@@ -50,16 +67,8 @@ func (row *Row) add(other Row) {
 
 // subtract the countable values in one row from another
 func (row *Row) subtract(other Row) {
-	// check for issues here (we have a bug) and log it
-	// - this situation should not happen so there's a logic bug somewhere else
-	if row.sumTimerWait >= other.sumTimerWait {
-		row.sumTimerWait -= other.sumTimerWait
-		row.countStar -= other.countStar
-	} else {
-		logger.Println("WARNING: Row.subtract() - subtraction problem! (not subtracting)")
-		logger.Println("row=", row)
-		logger.Println("other=", other)
-	}
+	row.sumTimerWait -= other.sumTimerWait
+	row.countStar -= other.countStar
 }
 
 func (rows Rows) totals() Row {
@@ -73,80 +82,81 @@ func (rows Rows) totals() Row {
 	return totals
 }
 
-func selectRows(dbh *sql.DB) Rows {
-	var t Rows
-
+// selectRows returns the mutexes waited for globally, or the mutexes and
+// files waited for by account
+func selectRows(dbh *sql.DB, byAccount bool) (Rows, error) {
 	// we collect all information even if it's mainly empty as we may reference it later
 	sql := "SELECT EVENT_NAME, SUM_TIMER_WAIT, COUNT_STAR FROM events_waits_summary_global_by_event_name WHERE SUM_TIMER_WAIT > 0 AND EVENT_NAME LIKE 'wait/synch/mutex/innodb/%'"
+	if byAccount {
+		sql = "SELECT USER, HOST, EVENT_NAME, SUM_TIMER_WAIT, COUNT_STAR FROM events_waits_summary_by_account_by_event_name WHERE SUM_TIMER_WAIT > 0 AND (EVENT_NAME LIKE 'wait/synch/mutex/innodb/%' OR EVENT_NAME LIKE 'wait/io/file/%')"
+	}
 
-	rows, err := dbh.Query(sql)
+	t, err := lib.ReadRowsFromSQL(dbh, columns, sql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var r Row
-		if err := rows.Scan(
-			&r.name,
-			&r.sumTimerWait,
-			&r.countStar); err != nil {
-			log.Fatal(err)
-		}
 
-		// trim off the leading 'wait/synch/mutex/innodb/'
-		if len(r.name) >= 24 {
-			r.name = r.name[24:]
+	for i := range t {
+		// trim off the leading 'wait/synch/mutex/innodb/', or 'wait/io/' of the file I/O waits
+		switch {
+		case strings.HasPrefix(t[i].name, "wait/synch/mutex/innodb/"):
+			t[i].name = t[i].name[24:]
+		case strings.HasPrefix(t[i].name, "wait/io/"):
+			t[i].name = t[i].name[8:]
+		}
+		if byAccount {
+			t[i].name = account(t[i].user, t[i].host) + ": " + t[i].name
 		}
-
-		// we collect all information even if it's mainly empty as we may reference it later
-		t = append(t, r)
 	}
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+
+	return t, nil
+}
+
+// account returns user@host, or <background> for the server's own threads
+func account(user, host sql.NullString) string {
+	if !user.Valid {
+		return "<background>"
 	}
 
-	return t
+	return anonymiser.Anonymise("user", user.String) + "@" + anonymiser.Anonymise("host", host.String)
 }
 
-func (rows Rows) Len() int      { return len(rows) }
-func (rows Rows) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
 
 // sort by value (descending) but also by "name" (ascending) if the values are the same
-func (rows Rows) Less(i, j int) bool {
-	return rows[i].sumTimerWait > rows[j].sumTimerWait
+// after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("mutex_latency", "latency", "name"))
 }
 
-func (rows Rows) sort() {
-	sort.Sort(rows)
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("mutex_latency", Rows(nil).sortKeys())
+}
+
+// differ matches the rows by name to subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: (*Row).subtract,
 }
 
 // remove the initial values from those rows where there's a match
-// - if we find a row we can't match ignore it
 func (rows *Rows) subtract(initial Rows) {
-	initialByName := make(map[string]int)
-
-	// iterate over rows by name
-	for i := range initial {
-		initialByName[initial[i].name] = i
-	}
-
-	for i := range *rows {
-		name := (*rows)[i].name
-		if _, ok := initialByName[name]; ok {
-			initialIndex := initialByName[name]
-			(*rows)[i].subtract(initial[initialIndex])
-		}
-	}
+	differ.SubtractRows(*rows, initial)
 }
 
 // if the data in t2 is "newer", "has more values" than t then it needs refreshing.
-// check this by comparing totals.
+// check this by comparing the totals of the rows found in both.
 func (rows Rows) needsRefresh(otherRows Rows) bool {
-	totals := rows.totals()
-	otherTotals := otherRows.totals()
-
-	return totals.sumTimerWait > otherTotals.sumTimerWait
+	return differ.NeedsRefresh(rows, otherRows)
 }
 
 // describe a whole row
@@ -168,3 +178,14 @@ func (rows Rows) String() string {
 
 	return strings.Join(s, "\n")
 }
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"count_star":     row.countStar,
+			"sum_timer_wait": row.sumTimerWait,
+		},
+	}
+}
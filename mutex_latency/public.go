@@ -11,15 +11,18 @@ import (
 	"github.com/sjmudd/ps-top/baseobject"
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
 )
 
 // Object holds a table of rows
 type Object struct {
 	baseobject.BaseObject      // embedded
 	initial               Rows // initial data for relative values
+	mark                  Rows // marked data for relative values since the mark
 	current               Rows // last loaded values
 	results               Rows // results (maybe with subtraction)
 	totals                Row  // totals of results
+	byAccount             bool // the rows were collected by account
 }
 
 func NewMutexLatency(ctx *context.Context) *Object {
@@ -34,18 +37,42 @@ func NewMutexLatency(ctx *context.Context) *Object {
 }
 
 func (t *Object) copyCurrentToInitial() {
-	t.initial = make(Rows, len(t.current))
+	t.initial = append(t.initial[:0], t.current...)
 	t.SetInitialCollectTime(t.LastCollectTime())
-	copy(t.initial, t.current)
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
 }
 
 // Collect collects data from the db, updating initial
 // values if needed, and then subtracting initial values if we want
 // relative values, after which it stores totals.
-func (t *Object) Collect(dbh *sql.DB) {
+func (t *Object) Collect(dbh *sql.DB) error {
 	start := time.Now()
 	// logger.Println("Object.Collect() BEGIN")
-	t.current = selectRows(dbh)
+	rows, err := selectRows(dbh, t.WantByAccount())
+	if err != nil {
+		return err
+	}
+	if t.byAccount != t.WantByAccount() {
+		logger.Println("t.initial: cleared as the rows are now collected by account:", t.WantByAccount())
+		t.byAccount = t.WantByAccount()
+		t.initial = nil
+		t.clearMark()
+	}
+	t.current = rows
 	t.SetLastCollectTimeNow()
 
 	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
@@ -60,6 +87,10 @@ func (t *Object) Collect(dbh *sql.DB) {
 		logger.Println("t.initial: copying from t.current (data needs refreshing)")
 		t.copyCurrentToInitial()
 	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
 
 	t.makeResults()
 
@@ -70,13 +101,16 @@ func (t *Object) Collect(dbh *sql.DB) {
 	// logger.Println("t.results:", t.results)
 	// logger.Println("t.totals:", t.totals)
 	logger.Println("Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
 }
 
 func (t *Object) makeResults() {
 	// logger.Println( "- t.results set from t.current" )
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
-	if t.WantRelativeStats() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
 		// logger.Println( "- subtracting t.initial from t.results as WantRelativeStats()" )
 		t.results.subtract(t.initial)
 	}
@@ -107,7 +141,7 @@ func (t Object) EmptyRowContent() string {
 func (t *Object) Headings() string {
 	var r Row
 
-	return r.headings()
+	return r.headings(t.byAccount)
 }
 
 // RowContent returns a string representation of the row content
@@ -140,7 +174,10 @@ func (t Object) Description() string {
 			count++
 		}
 	}
-	return fmt.Sprintf("Mutex Latency (events_waits_summary_global_by_event_name) %d rows", count)
+	if t.byAccount {
+		return fmt.Sprintf("Mutex and File I/O Latency by account (events_waits_summary_by_account_by_event_name) %d rows (u: globally)", count)
+	}
+	return fmt.Sprintf("Mutex Latency (events_waits_summary_global_by_event_name) %d rows (u: by account)", count)
 }
 
 // Len returns the length of the result set
@@ -152,3 +189,25 @@ func (t Object) Len() int {
 func (t Object) HaveRelativeStats() bool {
 	return true
 }
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -0,0 +1,94 @@
+package mysqlx
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// authentication mechanisms
+const (
+	mechanismPlain        = "PLAIN"         // cleartext, only used over TLS or a unix socket
+	mechanismMySQL41      = "MYSQL41"       // mysql_native_password accounts
+	mechanismSHA256Memory = "SHA256_MEMORY" // caching_sha2_password accounts once their hash is cached
+)
+
+// xor returns a XOR b, which have the same length
+func xor(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// scrambleMySQL41 returns SHA1(password) XOR SHA1(nonce + SHA1(SHA1(password)))
+func scrambleMySQL41(password string, nonce []byte) []byte {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write(stage2[:])
+
+	return xor(stage1[:], h.Sum(nil))
+}
+
+// scrambleSHA256 returns SHA256(password) XOR SHA256(SHA256(SHA256(password)) + nonce)
+func scrambleSHA256(password string, nonce []byte) []byte {
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(nonce)
+
+	return xor(stage1[:], h.Sum(nil))
+}
+
+// challengeResponse returns the reply to the server's nonce for the mechanism:
+// the schema, user and hashed password separated by NUL bytes
+func challengeResponse(mechanism, schema, user, password string, nonce []byte) []byte {
+	var hash string
+
+	if password != "" {
+		switch mechanism {
+		case mechanismMySQL41:
+			hash = "*" + strings.ToUpper(hex.EncodeToString(scrambleMySQL41(password, nonce)))
+		case mechanismSHA256Memory:
+			hash = strings.ToUpper(hex.EncodeToString(scrambleSHA256(password, nonce)))
+		}
+	}
+
+	return []byte(schema + "\x00" + user + "\x00" + hash)
+}
+
+// authenticate logs in with the given mechanism
+func authenticate(rw io.ReadWriter, mechanism, schema, user, password string) error {
+	if mechanism == mechanismPlain {
+		data := []byte(schema + "\x00" + user + "\x00" + password)
+		if err := writeMessage(rw, clientAuthenticateStart, authenticateStart(mechanism, data)); err != nil {
+			return err
+		}
+		_, err := expect(rw, serverAuthenticateOk)
+		return err
+	}
+
+	if err := writeMessage(rw, clientAuthenticateStart, authenticateStart(mechanism, nil)); err != nil {
+		return err
+	}
+	payload, err := expect(rw, serverAuthenticateContinue)
+	if err != nil {
+		return err
+	}
+	nonce, err := authData(payload)
+	if err != nil {
+		return err
+	}
+	response := message(nil).bytes(1, challengeResponse(mechanism, schema, user, password, nonce))
+	if err := writeMessage(rw, clientAuthenticateContinue, response); err != nil {
+		return err
+	}
+	_, err = expect(rw, serverAuthenticateOk)
+	return err
+}
@@ -0,0 +1,258 @@
+// Package mysqlx provides a database/sql driver which talks to MySQL
+// using the X Protocol (usually on port 33060), for environments where
+// only that port is reachable. It supports what ps-top needs: running
+// SQL statements with arguments and reading their results.
+//
+// The dsn has the same format as the classic protocol driver's.
+// Connections over TCP use TLS if tls=true or tls=skip-verify is given.
+// Passwords are sent in cleartext over TLS or unix sockets, which works
+// with all authentication plugins. Otherwise the MYSQL41 mechanism is
+// used for mysql_native_password accounts, falling back to SHA256_MEMORY
+// for caching_sha2_password accounts whose password has been cached by
+// an earlier login.
+package mysqlx
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// DriverName is the name the driver is registered with
+const DriverName = "ps-top-mysqlx"
+
+// DefaultPort is the port the X Protocol usually listens on
+const DefaultPort = 33060
+
+const dialTimeout = 10 * time.Second
+
+// Dial connects to the server, which may be replaced to choose the
+// address to connect to
+var Dial = func(network, address string) (net.Conn, error) {
+	return net.DialTimeout(network, address, dialTimeout)
+}
+
+// xDriver opens X Protocol connections
+type xDriver struct{}
+
+// conn is a connection using the X Protocol
+type conn struct {
+	netConn net.Conn
+}
+
+// stmt runs a statement. Nothing is prepared on the server, the SQL
+// and its arguments are sent each time it is run.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+// rows holds a result set, which is read completely when the query is run
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// result is returned by statements which don't return rows
+type result struct{}
+
+func init() {
+	sql.Register(DriverName, xDriver{})
+}
+
+// tlsConfig returns the TLS configuration to use for the dsn's tls setting, nil if none
+func tlsConfig(cfg *mysql.Config) (*tls.Config, error) {
+	switch cfg.TLSConfig {
+	case "", "false":
+		return nil, nil
+	case "true":
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			host = cfg.Addr
+		}
+		return &tls.Config{ServerName: host}, nil
+	case "skip-verify":
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	return nil, errors.New("mysqlx: tls must be true, skip-verify or false")
+}
+
+// Open connects to the server given by the dsn and logs in
+func (d xDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Println("mysqlx.Open() connecting to", cfg.Net, cfg.Addr)
+	netConn, err := Dial(cfg.Net, cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{netConn: netConn}
+
+	if tlsCfg != nil {
+		if err := writeMessage(netConn, clientCapabilitiesSet, capabilitiesSetTLS()); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		if _, err := expect(netConn, serverOk); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(netConn, tlsCfg)
+		if err := tlsConn.Handshake(); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		c.netConn = tlsConn
+	}
+
+	if err := c.login(cfg, tlsCfg != nil || cfg.Net == "unix"); err != nil {
+		c.netConn.Close()
+		return nil, err
+	}
+	logger.Println("mysqlx.Open() logged in as", cfg.User)
+
+	return c, nil
+}
+
+// login authenticates, sending the password in cleartext only if the connection is secure
+func (c *conn) login(cfg *mysql.Config, secure bool) error {
+	if secure {
+		return authenticate(c.netConn, mechanismPlain, cfg.DBName, cfg.User, cfg.Passwd)
+	}
+
+	err := authenticate(c.netConn, mechanismMySQL41, cfg.DBName, cfg.User, cfg.Passwd)
+	if _, ok := err.(*Error); ok {
+		logger.Println("mysqlx: MYSQL41 authentication failed, trying SHA256_MEMORY:", err)
+		err = authenticate(c.netConn, mechanismSHA256Memory, cfg.DBName, cfg.User, cfg.Passwd)
+	}
+	return err
+}
+
+// Prepare returns a statement which runs the query
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+// Close says goodbye to the server and closes the connection
+func (c *conn) Close() error {
+	writeMessage(c.netConn, clientClose, nil)
+	return c.netConn.Close()
+}
+
+// Begin is not supported
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("mysqlx: transactions are not supported")
+}
+
+// execute runs the query and returns its first result set, if any
+func (c *conn) execute(query string, args []driver.Value) (*rows, error) {
+	m, err := stmtExecute(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMessage(c.netConn, clientStmtExecute, m); err != nil {
+		return nil, driver.ErrBadConn
+	}
+
+	r := &rows{}
+	var columns []column
+	var firstErr error
+	resultSets := 0
+	for {
+		msgType, payload, err := readMessage(c.netConn)
+		if err != nil {
+			return nil, driver.ErrBadConn
+		}
+		switch msgType {
+		case serverColumnMetaData:
+			col, err := parseColumn(payload)
+			if err != nil {
+				return nil, err
+			}
+			if resultSets == 0 {
+				columns = append(columns, col)
+				r.columns = append(r.columns, col.name)
+			}
+		case serverRow:
+			if resultSets > 0 || firstErr != nil {
+				continue
+			}
+			values, err := parseRow(payload, columns)
+			if err != nil {
+				firstErr = err // keep reading so the connection can be used again
+				continue
+			}
+			r.values = append(r.values, values)
+		case serverFetchDone, serverFetchDoneMoreResultset, serverFetchDoneMoreOutParams:
+			resultSets++
+		case serverStmtExecuteOk:
+			return r, firstErr
+		case serverError:
+			return nil, parseError(payload)
+		}
+	}
+}
+
+// Close does nothing
+func (s *stmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 as the number of arguments isn't checked
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+// Exec runs a statement which doesn't return rows
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := s.conn.execute(s.query, args); err != nil {
+		return nil, err
+	}
+	return result{}, nil
+}
+
+// Query runs a statement which returns rows
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.execute(s.query, args)
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+
+	return nil
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, errors.New("mysqlx: LastInsertId is not supported")
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return 0, errors.New("mysqlx: RowsAffected is not supported")
+}
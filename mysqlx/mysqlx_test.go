@@ -0,0 +1,152 @@
+package mysqlx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDecodeValue(t *testing.T) {
+	tests := []struct {
+		dataType uint64
+		data     []byte
+		want     string
+	}{
+		{typeSint, message(nil).varint(3), "-2"},
+		{typeSint, message(nil).varint(4), "2"},
+		{typeUint, message(nil).varint(18446744073709551615), "18446744073709551615"},
+		{typeBytes, []byte("abc\x00"), "abc"},
+		{typeBytes, []byte("\x00"), ""},
+		{typeDecimal, []byte{0x02, 0x12, 0x34, 0x5c}, "123.45"},
+		{typeDecimal, []byte{0x01, 0x12, 0x34, 0xd0}, "-123.4"},
+		{typeDatetime, message(nil).varint(2024).varint(2).varint(29), "2024-02-29"},
+		{typeDatetime, message(nil).varint(2024).varint(2).varint(29).varint(13).varint(5).varint(9), "2024-02-29 13:05:09"},
+		{typeTime, append([]byte{1}, message(nil).varint(36).varint(0).varint(1).varint(5)...), "-36:00:01.000005"},
+		{typeSet, []byte{0x01}, ""},
+		{typeSet, []byte{0x01, 'a', 0x02, 'b', 'c'}, "a,bc"},
+	}
+
+	for _, test := range tests {
+		got, err := decodeValue(test.dataType, test.data)
+		if err != nil {
+			t.Errorf("decodeValue(%d, %v) failed: %v", test.dataType, test.data, err)
+			continue
+		}
+		if string(got.([]byte)) != test.want {
+			t.Errorf("decodeValue(%d, %v) = %q, want %q", test.dataType, test.data, got, test.want)
+		}
+	}
+
+	if got, err := decodeValue(typeUint, nil); got != nil || err != nil {
+		t.Errorf("decodeValue() of an empty value = %v, %v, want NULL", got, err)
+	}
+}
+
+// the server checks the scramble by recovering SHA1(password) using the hash it stores
+func TestScrambleMySQL41(t *testing.T) {
+	password, nonce := "secret", []byte("01234567890123456789")
+	stage1 := sha1.Sum([]byte(password))
+	stored := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write(stored[:])
+	recovered := xor(scrambleMySQL41(password, nonce), h.Sum(nil))
+
+	if check := sha1.Sum(recovered); check != stored {
+		t.Errorf("scrambleMySQL41() gives a scramble the server would reject")
+	}
+}
+
+// fakeServer accepts a connection, checks the MYSQL41 login and answers
+// each StmtExecute with one BIGINT UNSIGNED and one VARCHAR column
+func fakeServer(t *testing.T, listener net.Listener) {
+	c, err := listener.Accept()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer c.Close()
+
+	if msgType, _, err := readMessage(c); err != nil || msgType != clientAuthenticateStart {
+		t.Errorf("expected AuthenticateStart, got %d, %v", msgType, err)
+		return
+	}
+	nonce := []byte("01234567890123456789")
+	writeMessage(c, serverAuthenticateContinue, message(nil).bytes(1, nonce))
+	_, payload, _ := readMessage(c)
+	data, _ := authData(payload)
+	if !bytes.Equal(data, challengeResponse(mechanismMySQL41, "performance_schema", "user", "pass", nonce)) {
+		writeMessage(c, serverError, message(nil).uint(2, 1045).string(3, "Access denied"))
+		return
+	}
+	writeMessage(c, serverNotice, nil)
+	writeMessage(c, serverAuthenticateOk, nil)
+
+	for {
+		msgType, payload, err := readMessage(c)
+		if err != nil || msgType != clientStmtExecute {
+			return
+		}
+		fs, _ := fields(payload)
+		var query string
+		for _, f := range fs {
+			if f.number == 1 {
+				query = string(f.data)
+			}
+		}
+		if strings.Contains(query, "missing") {
+			writeMessage(c, serverError, message(nil).uint(2, 1146).string(3, "Table 'missing' doesn't exist"))
+			continue
+		}
+		writeMessage(c, serverColumnMetaData, message(nil).uint(1, typeUint).string(2, "COUNT_STAR"))
+		writeMessage(c, serverColumnMetaData, message(nil).uint(1, typeBytes).string(2, "EVENT_NAME"))
+		writeMessage(c, serverRow, message(nil).bytes(1, message(nil).varint(42)).bytes(1, []byte("wait/io/file\x00")))
+		writeMessage(c, serverRow, message(nil).bytes(1, nil).bytes(1, []byte("idle\x00")))
+		writeMessage(c, serverFetchDone, nil)
+		writeMessage(c, serverStmtExecuteOk, nil)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("unable to listen:", err)
+	}
+	defer listener.Close()
+	go fakeServer(t, listener)
+
+	db, err := sql.Open(DriverName, fmt.Sprintf("user:pass@tcp(%s)/performance_schema", listener.Addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	rows, err := db.Query("SELECT COUNT_STAR, EVENT_NAME FROM events WHERE COUNT_STAR > ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var count sql.NullInt64
+		var name string
+		if err := rows.Scan(&count, &name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%v/%d/%s", count.Valid, count.Int64, name))
+	}
+	rows.Close()
+	if want := "true/42/wait/io/file false/0/idle"; strings.Join(got, " ") != want {
+		t.Errorf("Query() returned %q, want %q", strings.Join(got, " "), want)
+	}
+
+	_, err = db.Exec("SELECT * FROM missing")
+	if err == nil || !strings.HasPrefix(err.Error(), "Error 1146:") {
+		t.Errorf("Exec() of a missing table returned %v, want Error 1146", err)
+	}
+}
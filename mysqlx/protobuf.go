@@ -0,0 +1,135 @@
+package mysqlx
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("mysqlx: truncated protobuf message")
+
+// message builds a protobuf encoded message. Only the few field types
+// needed for the messages we send are supported.
+type message []byte
+
+func (m message) key(field, wireType int) message {
+	return m.varint(uint64(field<<3 | wireType))
+}
+
+func (m message) varint(v uint64) message {
+	for v >= 0x80 {
+		m = append(m, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(m, byte(v))
+}
+
+// uint adds an unsigned integer (or enum) field
+func (m message) uint(field int, v uint64) message {
+	return m.key(field, wireVarint).varint(v)
+}
+
+// sint adds a signed integer field using zig-zag encoding
+func (m message) sint(field int, v int64) message {
+	return m.uint(field, uint64(v<<1)^uint64(v>>63))
+}
+
+// bool adds a boolean field
+func (m message) bool(field int, v bool) message {
+	if v {
+		return m.uint(field, 1)
+	}
+	return m.uint(field, 0)
+}
+
+// double adds a double field
+func (m message) double(field int, v float64) message {
+	m = m.key(field, wireFixed64)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	return append(m, b[:]...)
+}
+
+// bytes adds a string, bytes or embedded message field
+func (m message) bytes(field int, v []byte) message {
+	return append(m.key(field, wireBytes).varint(uint64(len(v))), v...)
+}
+
+// string adds a string field
+func (m message) string(field int, v string) message {
+	return m.bytes(field, []byte(v))
+}
+
+// field is a field decoded from a protobuf message
+type field struct {
+	number int
+	value  uint64 // varint and fixed values
+	data   []byte // length delimited values
+}
+
+// readVarint returns the varint at the start of b and its length
+func readVarint(b []byte) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, errTruncated
+}
+
+// fields decodes the fields of a protobuf message
+func fields(b []byte) ([]field, error) {
+	var result []field
+
+	for len(b) > 0 {
+		key, n, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		b = b[n:]
+		f := field{number: int(key >> 3)}
+
+		switch key & 7 {
+		case wireVarint:
+			if f.value, n, err = readVarint(b); err != nil {
+				return nil, err
+			}
+		case wireFixed64:
+			if n = 8; len(b) < n {
+				return nil, errTruncated
+			}
+			f.value = binary.LittleEndian.Uint64(b)
+		case wireFixed32:
+			if n = 4; len(b) < n {
+				return nil, errTruncated
+			}
+			f.value = uint64(binary.LittleEndian.Uint32(b))
+		case wireBytes:
+			length, l, err := readVarint(b)
+			if err != nil {
+				return nil, err
+			}
+			if uint64(len(b)-l) < length {
+				return nil, errTruncated
+			}
+			f.data = b[l : l+int(length)]
+			n = l + int(length)
+		default:
+			return nil, errors.New("mysqlx: unsupported protobuf wire type")
+		}
+		b = b[n:]
+		result = append(result, f)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,254 @@
+package mysqlx
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// client message types
+const (
+	clientCapabilitiesSet      = 2
+	clientClose                = 3
+	clientAuthenticateStart    = 4
+	clientAuthenticateContinue = 5
+	clientStmtExecute          = 12
+)
+
+// server message types
+const (
+	serverOk                     = 0
+	serverError                  = 1
+	serverAuthenticateContinue   = 3
+	serverAuthenticateOk         = 4
+	serverNotice                 = 11
+	serverColumnMetaData         = 12
+	serverRow                    = 13
+	serverFetchDone              = 14
+	serverFetchDoneMoreResultset = 16
+	serverStmtExecuteOk          = 17
+	serverFetchDoneMoreOutParams = 18
+)
+
+// Mysqlx.Datatypes.Any and Scalar types
+const (
+	anyScalar = 1
+
+	scalarSint   = 1
+	scalarUint   = 2
+	scalarNull   = 3
+	scalarOctets = 4
+	scalarDouble = 5
+	scalarBool   = 7
+	scalarString = 8
+)
+
+// maxMessageSize limits the size of the messages we accept from the server
+const maxMessageSize = 64 * 1024 * 1024
+
+// Error is an error returned by the server. It is shown in the same
+// way as the errors of the classic protocol driver so callers which
+// check the error number work with both.
+type Error struct {
+	Code     uint32
+	SQLState string
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
+}
+
+// parseError decodes a Mysqlx.Error message
+func parseError(payload []byte) error {
+	fs, err := fields(payload)
+	if err != nil {
+		return err
+	}
+	e := &Error{}
+	for _, f := range fs {
+		switch f.number {
+		case 2:
+			e.Code = uint32(f.value)
+		case 3:
+			e.Message = string(f.data)
+		case 4:
+			e.SQLState = string(f.data)
+		}
+	}
+	return e
+}
+
+// writeMessage sends a message of the given type
+func writeMessage(w io.Writer, msgType byte, payload []byte) error {
+	buf := make([]byte, 5, 5+len(payload))
+	binary.LittleEndian.PutUint32(buf, uint32(len(payload)+1))
+	buf[4] = msgType
+
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// readMessage reads the next message from the server, skipping notices
+func readMessage(r io.Reader) (byte, []byte, error) {
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return 0, nil, err
+		}
+		size := binary.LittleEndian.Uint32(header[:4])
+		if size < 1 || size > maxMessageSize {
+			return 0, nil, fmt.Errorf("mysqlx: invalid message size %d", size)
+		}
+		payload := make([]byte, size-1)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return 0, nil, err
+		}
+		if header[4] != serverNotice {
+			return header[4], payload, nil
+		}
+	}
+}
+
+// expect reads the next message and checks it is of the wanted type,
+// returning any error sent by the server instead
+func expect(r io.Reader, wanted byte) ([]byte, error) {
+	msgType, payload, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	switch msgType {
+	case wanted:
+		return payload, nil
+	case serverError:
+		return nil, parseError(payload)
+	}
+	return nil, fmt.Errorf("mysqlx: unexpected message type %d, wanted %d", msgType, wanted)
+}
+
+// capabilitiesSetTLS returns a CapabilitiesSet message asking to switch to TLS
+func capabilitiesSetTLS() []byte {
+	scalar := message(nil).uint(1, scalarBool).bool(8, true)
+	any := message(nil).uint(1, anyScalar).bytes(2, scalar)
+	capability := message(nil).string(1, "tls").bytes(2, any)
+	capabilities := message(nil).bytes(1, capability)
+
+	return message(nil).bytes(1, capabilities)
+}
+
+// authenticateStart returns an AuthenticateStart message for the mechanism
+func authenticateStart(mechanism string, authData []byte) []byte {
+	m := message(nil).string(1, mechanism)
+	if authData != nil {
+		m = m.bytes(2, authData)
+	}
+	return m
+}
+
+// authData returns the auth_data field of an AuthenticateContinue message
+func authData(payload []byte) ([]byte, error) {
+	fs, err := fields(payload)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fs {
+		if f.number == 1 {
+			return f.data, nil
+		}
+	}
+	return nil, fmt.Errorf("mysqlx: no auth data from the server")
+}
+
+// scalar returns the Any message for a query argument
+func scalar(v driver.Value) ([]byte, error) {
+	var s message
+
+	switch v := v.(type) {
+	case nil:
+		s = s.uint(1, scalarNull)
+	case int64:
+		s = s.uint(1, scalarSint).sint(2, v)
+	case float64:
+		s = s.uint(1, scalarDouble).double(6, v)
+	case bool:
+		s = s.uint(1, scalarBool).bool(8, v)
+	case []byte:
+		s = s.uint(1, scalarOctets).bytes(5, message(nil).bytes(1, v))
+	case string:
+		s = s.uint(1, scalarString).bytes(9, message(nil).string(1, v))
+	case time.Time:
+		s = s.uint(1, scalarString).bytes(9, message(nil).string(1, v.Format("2006-01-02 15:04:05.999999")))
+	default:
+		return nil, fmt.Errorf("mysqlx: unsupported argument type %T", v)
+	}
+
+	return message(nil).uint(1, anyScalar).bytes(2, s), nil
+}
+
+// stmtExecute returns a StmtExecute message running the SQL with the given arguments
+func stmtExecute(query string, args []driver.Value) ([]byte, error) {
+	m := message(nil).string(3, "sql").string(1, query)
+	for _, arg := range args {
+		a, err := scalar(arg)
+		if err != nil {
+			return nil, err
+		}
+		m = m.bytes(2, a)
+	}
+	return m, nil
+}
+
+// column describes a column of a result set
+type column struct {
+	name     string
+	dataType uint64
+}
+
+// parseColumn decodes a ColumnMetaData message
+func parseColumn(payload []byte) (column, error) {
+	var c column
+
+	fs, err := fields(payload)
+	if err != nil {
+		return c, err
+	}
+	for _, f := range fs {
+		switch f.number {
+		case 1:
+			c.dataType = f.value
+		case 2:
+			c.name = string(f.data)
+		}
+	}
+	return c, nil
+}
+
+// parseRow decodes a Row message into its values
+func parseRow(payload []byte, columns []column) ([]driver.Value, error) {
+	fs, err := fields(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var row []driver.Value
+	for _, f := range fs {
+		if f.number != 1 {
+			continue
+		}
+		if len(row) >= len(columns) {
+			return nil, fmt.Errorf("mysqlx: row has more values than columns")
+		}
+		v, err := decodeValue(columns[len(row)].dataType, f.data)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, v)
+	}
+	for len(row) < len(columns) {
+		row = append(row, nil)
+	}
+
+	return row, nil
+}
@@ -0,0 +1,188 @@
+package mysqlx
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mysqlx.Resultset.ColumnMetaData field types
+const (
+	typeSint     = 1
+	typeUint     = 2
+	typeDouble   = 5
+	typeFloat    = 6
+	typeBytes    = 7
+	typeTime     = 10
+	typeDatetime = 12
+	typeSet      = 15
+	typeEnum     = 16
+	typeBit      = 17
+	typeDecimal  = 18
+)
+
+// decodeValue converts a value sent by the server into the text form
+// the classic protocol uses, so rows can be scanned in the same way.
+// An empty value is NULL.
+func decodeValue(dataType uint64, b []byte) (driver.Value, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	switch dataType {
+	case typeSint:
+		v, _, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(v>>1)^-int64(v&1), 10)), nil
+	case typeUint, typeBit:
+		v, _, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatUint(v, 10)), nil
+	case typeDouble:
+		if len(b) < 8 {
+			return nil, errTruncated
+		}
+		return []byte(strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)), 'f', -1, 64)), nil
+	case typeFloat:
+		if len(b) < 4 {
+			return nil, errTruncated
+		}
+		return []byte(strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'f', -1, 32)), nil
+	case typeBytes, typeEnum:
+		return b[:len(b)-1], nil // strip the trailing byte which distinguishes '' from NULL
+	case typeDecimal:
+		return decodeDecimal(b)
+	case typeDatetime:
+		return decodeDatetime(b)
+	case typeTime:
+		return decodeTime(b)
+	case typeSet:
+		return decodeSet(b)
+	}
+
+	return nil, fmt.Errorf("mysqlx: unsupported column type %d", dataType)
+}
+
+// decodeDecimal decodes a decimal: the scale followed by packed BCD digits
+// ending in a sign nibble
+func decodeDecimal(b []byte) (driver.Value, error) {
+	scale := int(b[0])
+
+	var digits strings.Builder
+	negative := false
+	done := false
+	for _, octet := range b[1:] {
+		for _, nibble := range []byte{octet >> 4, octet & 0x0f} {
+			switch {
+			case done:
+			case nibble < 10:
+				digits.WriteByte('0' + nibble)
+			case nibble == 0x0d || nibble == 0x0b:
+				negative = true
+				done = true
+			default:
+				done = true
+			}
+		}
+	}
+
+	s := digits.String()
+	for len(s) <= scale {
+		s = "0" + s
+	}
+	if scale > 0 {
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if negative {
+		s = "-" + s
+	}
+	return []byte(s), nil
+}
+
+// varints decodes a sequence of varints
+func varints(b []byte) ([]uint64, error) {
+	var values []uint64
+	for len(b) > 0 {
+		v, n, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values, nil
+}
+
+// clock formats hours, minutes, seconds and microseconds, any of which may be missing
+func clock(hours string, v []uint64) string {
+	for len(v) < 3 {
+		v = append(v, 0)
+	}
+	s := fmt.Sprintf("%s:%02d:%02d", hours, v[1], v[2])
+	if len(v) > 3 && v[3] > 0 {
+		s += fmt.Sprintf(".%06d", v[3])
+	}
+	return s
+}
+
+// decodeDatetime decodes the year, month and day, and optionally the time, of a date or datetime
+func decodeDatetime(b []byte) (driver.Value, error) {
+	v, err := varints(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 3 {
+		return nil, errTruncated
+	}
+
+	s := fmt.Sprintf("%04d-%02d-%02d", v[0], v[1], v[2])
+	if len(v) > 3 {
+		s += " " + clock(fmt.Sprintf("%02d", v[3]), v[3:])
+	}
+	return []byte(s), nil
+}
+
+// decodeTime decodes a time: a sign byte followed by hours, minutes, seconds and microseconds
+func decodeTime(b []byte) (driver.Value, error) {
+	v, err := varints(b[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		v = []uint64{0}
+	}
+
+	s := clock(fmt.Sprintf("%02d", v[0]), v)
+	if b[0] == 1 {
+		s = "-" + s
+	}
+	return []byte(s), nil
+}
+
+// decodeSet decodes the length prefixed members of a set
+func decodeSet(b []byte) (driver.Value, error) {
+	if len(b) == 1 && b[0] == 1 {
+		return []byte(""), nil // the empty set
+	}
+
+	var members []string
+	for len(b) > 0 {
+		length, n, err := readVarint(b)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(b)-n) < length {
+			return nil, errTruncated
+		}
+		members = append(members, string(b[n:n+int(length)]))
+		b = b[n+int(length):]
+	}
+	return []byte(strings.Join(members, ",")), nil
+}
@@ -8,8 +8,10 @@ import (
 )
 
 // Tabler is the interface for access to performance_schema rows
+// Collect returns an error if the data can't be collected, in
+// which case the previously collected data is kept.
 type Tabler interface {
-	Collect(dbh *sql.DB)
+	Collect(dbh *sql.DB) error
 	Description() string
 	EmptyRowContent() string
 	HaveRelativeStats() bool
@@ -17,8 +19,57 @@ type Tabler interface {
 	InitialCollectTime() time.Time
 	LastCollectTime() time.Time
 	Len() int
+	MarkCollectTime() time.Time
 	RowContent() []string
 	SetInitialFromCurrent()
+	SinceMark() bool
 	TotalRowContent() string
 	WantRelativeStats() bool
 }
+
+// RowValues holds the name of a row and its raw counter values,
+// indexed by column name, so rows can be compared between collections
+type RowValues struct {
+	Name   string
+	Values map[string]uint64
+}
+
+// Valuer is implemented by tables which can provide the current
+// raw values of their rows
+type Valuer interface {
+	Values() []RowValues
+}
+
+// Resulter is implemented by tables which can provide the values of
+// the rows shown, in the same order as RowContent
+type Resulter interface {
+	Results() []RowValues
+}
+
+// Health describes the last collection of a table so one which keeps
+// failing, e.g. because its grants were revoked, can be spotted
+type Health struct {
+	Collected time.Time     // when the last collection started
+	Took      time.Duration // how long it took
+	Rows      int           // the rows to show, 0 if it failed
+	Err       error         // why it failed, nil if it didn't
+}
+
+// OK is true if the last collection succeeded
+func (h Health) OK() bool {
+	return h.Err == nil
+}
+
+// Healther is implemented by tables which keep how their last
+// collection went. It is kept apart from Tabler so a table need not
+// implement it.
+type Healther interface {
+	Health() Health
+	SetHealth(health Health)
+}
+
+// Marker is implemented by tables which can keep the current values as
+// an additional comparison point, without changing the initial values
+type Marker interface {
+	SetMarkFromCurrent()
+}
@@ -0,0 +1,275 @@
+package prepared_statements
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+/**************************************************************************
+
+mysql> show create table prepared_statements_instances\G
+*************************** 1. row ***************************
+       Table: prepared_statements_instances
+Create Table: CREATE TABLE `prepared_statements_instances` (
+  `OBJECT_INSTANCE_BEGIN` bigint(20) unsigned NOT NULL,
+  `STATEMENT_ID` bigint(20) unsigned NOT NULL,
+  `STATEMENT_NAME` varchar(64) DEFAULT NULL,
+  `SQL_TEXT` longtext NOT NULL,
+  `OWNER_THREAD_ID` bigint(20) unsigned NOT NULL,
+  `OWNER_EVENT_ID` bigint(20) unsigned NOT NULL,
+  ...
+  `TIMER_PREPARE` bigint(20) unsigned NOT NULL,
+  `COUNT_REPREPARE` bigint(20) unsigned NOT NULL,
+  `COUNT_EXECUTE` bigint(20) unsigned NOT NULL,
+  `SUM_TIMER_EXECUTE` bigint(20) unsigned NOT NULL,
+  ...
+) ENGINE=PERFORMANCE_SCHEMA DEFAULT CHARSET=utf8
+1 row in set (0.00 sec)
+
+**************************************************************************/
+
+// instance is one prepared statement of a connection
+type instance struct {
+	threadID        uint64 // owner thread
+	statementID     uint64 // id within the owner's connection
+	owner           string // user@host of the owner
+	text            string // statement text on a single line
+	countExecute    uint64 // times it was executed
+	sumTimerExecute uint64 // time spent executing it
+}
+
+// key identifies the instance: statement ids are only unique within a connection
+func (i instance) key() string {
+	return fmt.Sprintf("%d/%d", i.threadID, i.statementID)
+}
+
+// instances contains a slice of instances
+type instances []instance
+
+// select the prepared statements into instances
+func selectInstances(dbh *sql.DB) (instances, error) {
+	var t instances
+
+	logger.Println("prepared_statements_instances.selectInstances()")
+	query := `SELECT p.OWNER_THREAD_ID, p.STATEMENT_ID, t.PROCESSLIST_USER, t.PROCESSLIST_HOST, p.SQL_TEXT, p.COUNT_EXECUTE, p.SUM_TIMER_EXECUTE
+FROM prepared_statements_instances p
+LEFT JOIN threads t ON (p.OWNER_THREAD_ID = t.THREAD_ID)`
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i instance
+		var user, host sql.NullString
+		if err := rows.Scan(
+			&i.threadID,
+			&i.statementID,
+			&user,
+			&host,
+			&i.text,
+			&i.countExecute,
+			&i.sumTimerExecute); err != nil {
+			return nil, err
+		}
+		if user.Valid {
+			i.owner = anonymiser.Anonymise("user", user.String) + "@" + anonymiser.Anonymise("host", host.String)
+		}
+		i.text = strings.Join(strings.Fields(i.text), " ")
+		t = append(t, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// differ matches the instances by their owner thread, statement id and
+// text, as the ids are used again once a statement is deallocated, to
+// subtract their initial values
+var differ = relative_stats.Differ[instance, string]{
+	Key:      func(i instance) string { return i.key() + " " + i.text },
+	Counters: func(i instance) []uint64 { return []uint64{i.countExecute, i.sumTimerExecute} },
+	Subtract: func(i *instance, other instance) {
+		i.countExecute -= other.countExecute
+		i.sumTimerExecute -= other.sumTimerExecute
+	},
+}
+
+// subtract removes the initial values of each instance which was
+// already prepared then. Statements come and go with their connections
+// so only an instance which has not gone backwards is changed.
+func (t instances) subtract(initial instances) {
+	differ.SubtractRows(t, initial)
+}
+
+// Row contains the prepared statements with the same text and owner
+type Row struct {
+	owner           string // user@host of the owner
+	text            string // statement text
+	count           uint64 // statements prepared
+	threads         uint64 // connections which prepared them
+	countExecute    uint64 // times they were executed
+	sumTimerExecute uint64 // time spent executing them
+}
+
+// Rows contains a slice of Rows
+type Rows []Row
+
+// name identifies the row
+func (row Row) name() string {
+	return row.owner + " " + row.text
+}
+
+// group adds up the instances with the same owner and text
+func (t instances) group() Rows {
+	var rows Rows
+	index := make(map[string]int)
+	threads := make(map[string]map[uint64]bool)
+
+	for i := range t {
+		r := Row{owner: t[i].owner, text: t[i].text}
+		name := r.name()
+		j, found := index[name]
+		if !found {
+			j = len(rows)
+			index[name] = j
+			threads[name] = make(map[uint64]bool)
+			rows = append(rows, r)
+		}
+		rows[j].count++
+		rows[j].countExecute += t[i].countExecute
+		rows[j].sumTimerExecute += t[i].sumTimerExecute
+		if !threads[name][t[i].threadID] {
+			threads[name][t[i].threadID] = true
+			rows[j].threads++
+		}
+	}
+
+	return rows
+}
+
+// threads returns the number of connections with prepared statements
+func (t instances) threads() uint64 {
+	seen := make(map[uint64]bool)
+
+	for i := range t {
+		seen[t[i].threadID] = true
+	}
+
+	return uint64(len(seen))
+}
+
+// generate the totals of a table, other than the connections which
+// may have prepared statements in several rows
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.text = "Totals"
+
+	for i := range rows {
+		totals.count += rows[i].count
+		totals.countExecute += rows[i].countExecute
+		totals.sumTimerExecute += rows[i].sumTimerExecute
+	}
+
+	return totals
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerExecute, rows[j].sumTimerExecute) },
+		"execs":   func(i, j int) int { return sort_keys.Descending(rows[i].countExecute, rows[j].countExecute) },
+		"count":   func(i, j int) int { return sort_keys.Descending(rows[i].count, rows[j].count) },
+		"owner":   func(i, j int) int { return sort_keys.Ascending(rows[i].owner, rows[j].owner) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].text, rows[j].text) },
+	}
+}
+
+// sort by latency (descending), then by the number prepared (descending)
+// so leaked statements which are never executed stand out, and by "name"
+// (ascending) after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("prepared_statements", "latency", "count", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("prepared_statements", Rows(nil).sortKeys())
+}
+
+// average returns the average latency of an execution (if any)
+func average(sumTimer, count uint64) string {
+	if count == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(sumTimer / count)
+}
+
+// prepared statement headings
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %8s %10s %7s %7s|%-24s|%s",
+		"Latency", "%", "Execs", "Avg Exec", "Count", "Threads", "Owner", "Statement")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	return fmt.Sprintf("%10s %6s %8s %10s %7s %7s|%-24s|%s",
+		lib.FormatTime(row.sumTimerExecute),
+		lib.FormatPct(lib.MyDivide(row.sumTimerExecute, totals.sumTimerExecute)),
+		lib.FormatAmount(row.countExecute),
+		average(row.sumTimerExecute, row.countExecute),
+		lib.FormatAmount(row.count),
+		lib.FormatAmount(row.threads),
+		row.owner,
+		row.text)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10s %10s %10s %s %s",
+		lib.FormatTime(row.sumTimerExecute),
+		lib.FormatAmount(row.countExecute),
+		lib.FormatAmount(row.count),
+		row.owner,
+		row.text)
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name(),
+		Values: map[string]uint64{
+			"count":             row.count,
+			"threads":           row.threads,
+			"count_execute":     row.countExecute,
+			"sum_timer_execute": row.sumTimerExecute,
+		},
+	}
+}
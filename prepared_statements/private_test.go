@@ -0,0 +1,50 @@
+package prepared_statements
+
+import (
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	current := instances{
+		{threadID: 10, statementID: 1, owner: "app@web1", text: "SELECT ?", countExecute: 5, sumTimerExecute: 500},
+		{threadID: 10, statementID: 2, owner: "app@web1", text: "SELECT ?", countExecute: 0, sumTimerExecute: 0},
+		{threadID: 11, statementID: 1, owner: "app@web1", text: "SELECT ?", countExecute: 3, sumTimerExecute: 300},
+		{threadID: 12, statementID: 1, owner: "report@localhost", text: "SELECT ?", countExecute: 1, sumTimerExecute: 900},
+	}
+
+	rows := current.group()
+	want := Rows{
+		{owner: "app@web1", text: "SELECT ?", count: 3, threads: 2, countExecute: 8, sumTimerExecute: 800},
+		{owner: "report@localhost", text: "SELECT ?", count: 1, threads: 1, countExecute: 1, sumTimerExecute: 900},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("group() returned %d row(s), want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("group()[%d] = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	initial := instances{
+		{threadID: 10, statementID: 1, text: "SELECT ?", countExecute: 5, sumTimerExecute: 500},
+		{threadID: 11, statementID: 1, text: "SELECT ?", countExecute: 9, sumTimerExecute: 900},
+		{threadID: 12, statementID: 1, text: "DELETE ?", countExecute: 2, sumTimerExecute: 200},
+	}
+	current := instances{
+		{threadID: 10, statementID: 1, text: "SELECT ?", countExecute: 7, sumTimerExecute: 750}, // still running
+		{threadID: 11, statementID: 1, text: "SELECT ?", countExecute: 1, sumTimerExecute: 100}, // thread id reused
+		{threadID: 12, statementID: 1, text: "UPDATE ?", countExecute: 4, sumTimerExecute: 400}, // re-prepared
+		{threadID: 13, statementID: 1, text: "SELECT ?", countExecute: 2, sumTimerExecute: 200}, // new
+	}
+
+	current.subtract(initial)
+	want := [][2]uint64{{2, 250}, {1, 100}, {4, 400}, {2, 200}}
+	for i := range want {
+		if got := [2]uint64{current[i].countExecute, current[i].sumTimerExecute}; got != want[i] {
+			t.Errorf("subtract() instance %d = %v, want %v", i, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Package prepared_statements shows the prepared statements of each
+// connection from prepared_statements_instances (5.7+), grouped by
+// their owner and text. Statements which are prepared over and over
+// but not deallocated, or prepared and never executed, show up as a
+// high count, while the latency shows the hot ones.
+package prepared_statements
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Object provides a public view of object
+type Object struct {
+	baseobject.BaseObject           // embedded
+	initial               instances // initial data for relative values
+	mark                  instances // marked data for relative values since the mark
+	current               instances // last loaded values
+	results               Rows      // results (maybe with subtraction) by owner and text
+	totals                Row       // totals of results
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// NewPreparedStatements returns a pointer to an object of this type
+func NewPreparedStatements(ctx *context.Context) *Object {
+	logger.Println("NewPreparedStatements()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects data from the db, updating initial values if
+// needed, and then subtracting initial values if we want relative
+// values, after which it stores totals. Statements are deallocated
+// when their connection closes so the values are compared per
+// statement rather than checking whether the totals went backwards.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectInstances(dbh)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if t.InitialCollectTime().IsZero() {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("prepared_statements.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings of the object
+func (t *Object) Headings() string {
+	return t.totals.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(e)
+}
+
+// TotalRowContent returns a row containing the totals
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// Description describes the prepared statements
+func (t Object) Description() string {
+	return fmt.Sprintf("Prepared Statements (prepared_statements_instances) %d statement(s) on %d connection(s)",
+		t.totals.count, t.totals.threads)
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	current := append(instances{}, t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		current.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		current.subtract(t.initial)
+	}
+
+	t.results = current.group()
+	t.results.sort()
+	t.totals = t.results.totals()
+	t.totals.threads = t.current.threads()
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	rows := t.current.group()
+	values := make([]ps_table.RowValues, 0, len(rows))
+
+	for i := range rows {
+		values = append(values, rows[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
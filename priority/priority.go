@@ -0,0 +1,124 @@
+// Package priority ranks the rows of a view by how much there may be to
+// gain from looking at them first: a weighted score of the row's share
+// of the view's latency, its share of the operations and how fast it has
+// been growing over the recent intervals. The weights may be configured
+// in ~/.pstoprc, e.g.
+// [priority]
+// latency = 0.6
+// trend = 0.3
+// ops = 0.1
+package priority
+
+import (
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// MinChanges is the number of intervals needed before a row's trend counts
+const MinChanges = 4
+
+// Weights holds how much each part of the score counts
+type Weights struct {
+	Latency float64 // share of the latency of the rows shown
+	Trend   float64 // growth over the recent intervals
+	Ops     float64 // share of the operations of the rows shown
+}
+
+// Default are the weights used if none are configured
+var Default = Weights{Latency: 0.5, Trend: 0.3, Ops: 0.2}
+
+// Configured returns the weights in the [priority] section of
+// ~/.pstoprc, the defaults being used for those not given
+func Configured() Weights {
+	w := Default
+
+	for key, value := range rc.Section("priority") {
+		weight, err := strconv.ParseFloat(value, 64)
+		if err != nil || weight < 0 {
+			log.Fatal("Invalid priority weight '", key, " = ", value, "' in ~/.pstoprc. Expected a number of 0 or more")
+		}
+		switch key {
+		case "latency":
+			w.Latency = weight
+		case "trend":
+			w.Trend = weight
+		case "ops":
+			w.Ops = weight
+		default:
+			log.Fatal("Unknown priority weight '", key, "' in ~/.pstoprc. Expected latency, trend or ops")
+		}
+	}
+	logger.Println("priority.Configured() weights:", w)
+
+	return w
+}
+
+// Growth returns how fast the changes of a row have been growing, from
+// -1 if it has gone quiet to 1 if it has only just become busy, by
+// comparing the last quarter of the intervals with those before. Rows
+// with too little history have no trend.
+func Growth(changes []uint64) float64 {
+	if len(changes) < MinChanges {
+		return 0
+	}
+	recent := len(changes) - len(changes)/4
+	before, after := mean(changes[:recent]), mean(changes[recent:])
+	if before+after == 0 {
+		return 0
+	}
+
+	return (after - before) / (after + before)
+}
+
+// mean returns the mean of the values
+func mean(values []uint64) float64 {
+	var sum float64
+
+	for _, v := range values {
+		sum += float64(v)
+	}
+
+	return sum / float64(len(values))
+}
+
+// share returns the value of the row as a fraction of the total
+func share(value, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(value) / float64(total)
+}
+
+// Order returns the indexes of the rows from the highest score to the
+// lowest, rows with the same score keeping their order. The trend is
+// scaled by the larger of the row's shares so a tiny row which grows
+// fast doesn't come before a busy one.
+func Order(rows []ps_table.RowValues, growth func(name string) float64, w Weights) []int {
+	var latency, ops uint64
+	for _, row := range rows {
+		latency += row.Values["sum_timer_wait"]
+		ops += row.Values["count_star"]
+	}
+
+	scores := make([]float64, len(rows))
+	order := make([]int, len(rows))
+	for i, row := range rows {
+		latencyShare := share(row.Values["sum_timer_wait"], latency)
+		opsShare := share(row.Values["count_star"], ops)
+		size := latencyShare
+		if opsShare > size {
+			size = opsShare
+		}
+		scores[i] = w.Latency*latencyShare + w.Ops*opsShare + w.Trend*growth(row.Name)*size
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	return order
+}
@@ -0,0 +1,59 @@
+package priority
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestGrowth(t *testing.T) {
+	var tests = []struct {
+		changes []uint64
+		want    float64
+	}{
+		{nil, 0},
+		{[]uint64{1, 2, 3}, 0},                 // too little history
+		{[]uint64{0, 0, 0, 0}, 0},              // idle
+		{[]uint64{5, 5, 5, 5, 5, 5, 5, 5}, 0},  // steady
+		{[]uint64{0, 0, 0, 0, 0, 0, 9, 9}, 1},  // only just busy
+		{[]uint64{9, 9, 9, 9, 9, 9, 0, 0}, -1}, // gone quiet
+		{[]uint64{1, 1, 1, 3}, 0.5},
+	}
+
+	for _, test := range tests {
+		if got := Growth(test.changes); got != test.want {
+			t.Errorf("Growth(%v): expected %v, got %v", test.changes, test.want, got)
+		}
+	}
+}
+
+func TestOrder(t *testing.T) {
+	row := func(name string, latency, ops uint64) ps_table.RowValues {
+		return ps_table.RowValues{Name: name, Values: map[string]uint64{"sum_timer_wait": latency, "count_star": ops}}
+	}
+	rows := []ps_table.RowValues{
+		row("slow", 500, 10),
+		row("busy", 300, 80),
+		row("growing", 200, 10),
+		row("idle", 0, 0),
+	}
+	trend := map[string]float64{"growing": 1, "slow": -1}
+	growth := func(name string) float64 { return trend[name] }
+
+	var tests = []struct {
+		weights Weights
+		want    []int
+	}{
+		{Weights{Latency: 1}, []int{0, 1, 2, 3}},
+		{Weights{Ops: 1}, []int{1, 0, 2, 3}},
+		{Weights{Latency: 0.5, Trend: 1}, []int{2, 1, 3, 0}},
+		{Weights{}, []int{0, 1, 2, 3}}, // all the same so the order is kept
+	}
+
+	for _, test := range tests {
+		if got := Order(rows, growth, test.weights); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Order(%+v): expected %v, got %v", test.weights, test.want, got)
+		}
+	}
+}
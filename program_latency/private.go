@@ -0,0 +1,277 @@
+package program_latency
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+	"github.com/sjmudd/ps-top/table"
+)
+
+/**************************************************************************
+
+mysql> show create table events_statements_summary_by_program\G
+*************************** 1. row ***************************
+       Table: events_statements_summary_by_program
+Create Table: CREATE TABLE `events_statements_summary_by_program` (
+  `OBJECT_TYPE` enum('EVENT','FUNCTION','PROCEDURE','TABLE','TRIGGER') DEFAULT NULL,
+  `OBJECT_SCHEMA` varchar(64) NOT NULL,
+  `OBJECT_NAME` varchar(64) NOT NULL,
+  `COUNT_STAR` bigint(20) unsigned NOT NULL,
+  `SUM_TIMER_WAIT` bigint(20) unsigned NOT NULL,
+  ...
+  `COUNT_STATEMENTS` bigint(20) unsigned NOT NULL,
+  `SUM_STATEMENTS_WAIT` bigint(20) unsigned NOT NULL,
+  ...
+  `SUM_ROWS_AFFECTED` bigint(20) unsigned NOT NULL,
+  `SUM_ROWS_SENT` bigint(20) unsigned NOT NULL,
+  `SUM_ROWS_EXAMINED` bigint(20) unsigned NOT NULL,
+  ...
+) ENGINE=PERFORMANCE_SCHEMA DEFAULT CHARSET=utf8
+1 row in set (0.00 sec)
+
+8.0.28 adds SUM_CPU_TIME, collected while the events_statements_cpu
+consumer is enabled.
+
+**************************************************************************/
+
+// Row contains the statistics of one stored program
+type Row struct {
+	name              string // schema.name of the program
+	objectType        string // EVENT, FUNCTION, PROCEDURE or TRIGGER
+	countStar         uint64 // times the program was run
+	sumTimerWait      uint64 // time spent running it
+	sumCPUTime        uint64 // CPU time used running it (8.0.28+)
+	countStatements   uint64 // statements run inside it
+	sumStatementsWait uint64 // time spent in those statements
+	sumRowsExamined   uint64
+	sumRowsSent       uint64
+	sumRowsAffected   uint64
+}
+
+// Rows contains a slice of Rows
+type Rows []Row
+
+// select the rows into table, only selecting SUM_CPU_TIME if we have access to it
+func selectRows(dbh *sql.DB, columns *table.Columns) (Rows, error) {
+	var t Rows
+
+	logger.Println("events_statements_summary_by_program.selectRows()")
+	if err := columns.Check(dbh); err != nil {
+		return nil, err
+	}
+	query := `SELECT OBJECT_TYPE, OBJECT_SCHEMA, OBJECT_NAME, COUNT_STAR, SUM_TIMER_WAIT, ` + columns.Select("SUM_CPU_TIME") + `, COUNT_STATEMENTS, SUM_STATEMENTS_WAIT,
+	SUM_ROWS_EXAMINED, SUM_ROWS_SENT, SUM_ROWS_AFFECTED
+FROM events_statements_summary_by_program
+WHERE SUM_TIMER_WAIT > 0`
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		var objectType sql.NullString
+		var schema, name string
+		if err := rows.Scan(
+			&objectType,
+			&schema,
+			&name,
+			&r.countStar,
+			&r.sumTimerWait,
+			&r.sumCPUTime,
+			&r.countStatements,
+			&r.sumStatementsWait,
+			&r.sumRowsExamined,
+			&r.sumRowsSent,
+			&r.sumRowsAffected); err != nil {
+			return nil, err
+		}
+		r.objectType = objectType.String
+		r.name = anonymiser.Anonymise("schema", schema) + "." + anonymiser.Anonymise("program", name)
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// if the data in t2 is "newer", "has more values" than t then it needs refreshing.
+// check this by comparing the totals of the rows found in both.
+func (rows Rows) needsRefresh(otherRows Rows) bool {
+	return differ.NeedsRefresh(rows, otherRows)
+}
+
+// generate the totals of a table
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.name = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// add the values of one row to another one
+func (row *Row) add(other Row) {
+	row.countStar += other.countStar
+	row.sumTimerWait += other.sumTimerWait
+	row.sumCPUTime += other.sumCPUTime
+	row.countStatements += other.countStatements
+	row.sumStatementsWait += other.sumStatementsWait
+	row.sumRowsExamined += other.sumRowsExamined
+	row.sumRowsSent += other.sumRowsSent
+	row.sumRowsAffected += other.sumRowsAffected
+}
+
+// subtract the countable values in one row from another
+func (row *Row) subtract(other Row) {
+	row.countStar -= other.countStar
+	row.sumTimerWait -= other.sumTimerWait
+	row.sumCPUTime -= other.sumCPUTime
+	row.countStatements -= other.countStatements
+	row.sumStatementsWait -= other.sumStatementsWait
+	row.sumRowsExamined -= other.sumRowsExamined
+	row.sumRowsSent -= other.sumRowsSent
+	row.sumRowsAffected -= other.sumRowsAffected
+}
+
+// key identifies the program: names are only unique within a type
+func (row Row) key() string {
+	return row.objectType + " " + row.name
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency":    func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"cpu":        func(i, j int) int { return sort_keys.Descending(rows[i].sumCPUTime, rows[j].sumCPUTime) },
+		"calls":      func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"statements": func(i, j int) int { return sort_keys.Descending(rows[i].countStatements, rows[j].countStatements) },
+		"examined":   func(i, j int) int { return sort_keys.Descending(rows[i].sumRowsExamined, rows[j].sumRowsExamined) },
+		"name":       func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by latency (descending) but also by "name" (ascending) if the values are the same
+// after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("program_latency", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("program_latency", Rows(nil).sortKeys())
+}
+
+// differ matches the rows by their type and name to subtract their
+// initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      Row.key,
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: (*Row).subtract,
+}
+
+// remove the initial values from those rows where there's a match
+func (rows *Rows) subtract(initial Rows) {
+	differ.SubtractRows(*rows, initial)
+}
+
+// average returns the average latency of a call (if any)
+func average(sumTimer, count uint64) string {
+	if count == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(sumTimer / count)
+}
+
+// cpuPct returns the share of the latency spent on the CPU, or nothing
+// if the CPU time isn't known
+func (row Row) cpuPct() string {
+	if row.sumCPUTime == 0 {
+		return ""
+	}
+
+	return lib.FormatPct(lib.MyDivide(row.sumCPUTime, row.sumTimerWait))
+}
+
+// program headings
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %10s %6s %8s %8s %10s %10s %10s %10s|%-9s|%s",
+		"Latency", "%", "CPU", "CPU%", "Calls", "Stmts", "Avg Call", "Examined", "Sent", "Affected", "Type", "Program")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	name := row.name
+	if row.countStar == 0 && name != "Totals" {
+		name = ""
+	}
+
+	return fmt.Sprintf("%10s %6s %10s %6s %8s %8s %10s %10s %10s %10s|%-9s|%s",
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
+		lib.FormatTime(row.sumCPUTime),
+		row.cpuPct(),
+		lib.FormatAmount(row.countStar),
+		lib.FormatAmount(row.countStatements),
+		average(row.sumTimerWait, row.countStar),
+		lib.FormatAmount(row.sumRowsExamined),
+		lib.FormatAmount(row.sumRowsSent),
+		lib.FormatAmount(row.sumRowsAffected),
+		row.objectType,
+		name)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10s %10s %-9s %s",
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatAmount(row.countStar),
+		row.objectType,
+		row.name)
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.key(),
+		Values: map[string]uint64{
+			"count_star":          row.countStar,
+			"sum_timer_wait":      row.sumTimerWait,
+			"sum_cpu_time":        row.sumCPUTime,
+			"count_statements":    row.countStatements,
+			"sum_statements_wait": row.sumStatementsWait,
+			"sum_rows_examined":   row.sumRowsExamined,
+			"sum_rows_sent":       row.sumRowsSent,
+			"sum_rows_affected":   row.sumRowsAffected,
+		},
+	}
+}
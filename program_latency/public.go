@@ -0,0 +1,183 @@
+// Package program_latency shows the time spent in stored procedures,
+// functions, triggers and events from events_statements_summary_by_program
+// (5.7+), separately from the top level statement digests.
+package program_latency
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/table"
+)
+
+// Object provides a public view of object
+type Object struct {
+	baseobject.BaseObject                // embedded
+	initial               Rows           // initial data for relative values
+	mark                  Rows           // marked data for relative values since the mark
+	current               Rows           // last loaded values
+	results               Rows           // results (maybe with subtraction)
+	totals                Row            // totals of results
+	columns               *table.Columns // which of the optional columns can be SELECTed
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
+}
+
+// NewProgramLatency returns a pointer to an object of this type
+func NewProgramLatency(ctx *context.Context) *Object {
+	logger.Println("NewProgramLatency()")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.columns = table.NewColumns("events_statements_summary_by_program", "SUM_CPU_TIME")
+
+	return o
+}
+
+// Collect collects data from the db, updating initial
+// values if needed, and then subtracting initial values if we want
+// relative values, after which it stores totals.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh, t.columns)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.initial.needsRefresh(t.current) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
+
+	t.makeResults()
+
+	logger.Println("program_latency.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings of the object
+func (t *Object) Headings() string {
+	return t.totals.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(e)
+}
+
+// TotalRowContent returns a row containing the totals
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// Description describes the stored programs
+func (t Object) Description() string {
+	var count int
+	for row := range t.results {
+		if t.results[row].sumTimerWait > 0 {
+			count++
+		}
+	}
+
+	return fmt.Sprintf("Stored Program Latency (events_statements_summary_by_program) %d rows", count)
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+
+	t.results.sort()
+	t.totals = t.results.totals()
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -0,0 +1,255 @@
+package proxy_backends
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+/**************************************************************************
+
+ProxySQL admin> SHOW CREATE TABLE stats.stats_mysql_connection_pool\G
+*************************** 1. row ***************************
+       table: stats_mysql_connection_pool
+Create Table: CREATE TABLE stats_mysql_connection_pool (
+    hostgroup INT,
+    srv_host VARCHAR,
+    srv_port VARCHAR,
+    status VARCHAR,
+    ConnUsed INT,
+    ConnFree INT,
+    ConnOK INT,
+    ConnERR INT,
+    MaxConnUsed INT,
+    Queries INT,
+    Queries_GTID_sync INT,
+    Bytes_data_sent INT,
+    Bytes_data_recv INT,
+    Latency_us INT)
+1 row in set (0.00 sec)
+
+ConnUsed, ConnFree and Latency_us (of the last ping) are current values
+and the others are counters.
+
+**************************************************************************/
+
+// picoseconds in a microsecond, as the times are shown like those of performance_schema
+const picoseconds = 1000000
+
+// Row contains the connections and queries of the proxy to a backend
+// server in a hostgroup
+type Row struct {
+	hostgroup int64
+	host      string
+	port      string
+	status    string
+	connUsed  uint64
+	connFree  uint64
+	connOK    uint64
+	connERR   uint64
+	queries   uint64
+	bytesSent uint64
+	bytesRecv uint64
+	latency   uint64
+}
+
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"hostgroup":       func(r *Row) interface{} { return &r.hostgroup },
+	"srv_host":        func(r *Row) interface{} { return &r.host },
+	"srv_port":        func(r *Row) interface{} { return &r.port },
+	"status":          func(r *Row) interface{} { return &r.status },
+	"ConnUsed":        func(r *Row) interface{} { return &r.connUsed },
+	"ConnFree":        func(r *Row) interface{} { return &r.connFree },
+	"ConnOK":          func(r *Row) interface{} { return &r.connOK },
+	"ConnERR":         func(r *Row) interface{} { return &r.connERR },
+	"Queries":         func(r *Row) interface{} { return &r.queries },
+	"Bytes_data_sent": func(r *Row) interface{} { return &r.bytesSent },
+	"Bytes_data_recv": func(r *Row) interface{} { return &r.bytesRecv },
+	"Latency_us":      func(r *Row) interface{} { return &r.latency },
+}
+
+// Rows contains a slice of Rows
+type Rows []Row
+
+// backend returns the backend's address
+func (row Row) backend() string {
+	if row.host == "" {
+		return ""
+	}
+
+	return net.JoinHostPort(row.host, row.port)
+}
+
+// name identifies the row
+func (row Row) name() string {
+	return fmt.Sprintf("%d %s", row.hostgroup, row.backend())
+}
+
+// select the backends of each hostgroup
+func selectRows(dbh *sql.DB) (Rows, error) {
+	logger.Println("proxy_backends.selectRows()")
+	query := `SELECT hostgroup, srv_host, srv_port, status, ConnUsed, ConnFree, ConnOK, ConnERR, Queries, Bytes_data_sent, Bytes_data_recv, Latency_us
+FROM stats.stats_mysql_connection_pool`
+	t, err := lib.ReadRowsFromSQL(dbh, columns, query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range t {
+		t[i].host = anonymiser.Anonymise("host", t[i].host)
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// add the values of another row
+func (row *Row) add(other Row) {
+	row.connUsed += other.connUsed
+	row.connFree += other.connFree
+	row.connOK += other.connOK
+	row.connERR += other.connERR
+	row.queries += other.queries
+	row.bytesSent += other.bytesSent
+	row.bytesRecv += other.bytesRecv
+}
+
+// subtract the counters of another row, leaving the current connections
+// and latency alone
+func (row *Row) subtract(other Row) {
+	row.connOK -= other.connOK
+	row.connERR -= other.connERR
+	row.queries -= other.queries
+	row.bytesSent -= other.bytesSent
+	row.bytesRecv -= other.bytesRecv
+}
+
+// differ matches the rows by hostgroup and backend to subtract their
+// initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      Row.name,
+	Counters: func(row Row) []uint64 { return []uint64{row.queries, row.connOK} },
+	Subtract: (*Row).subtract,
+}
+
+// remove the initial values from those rows where there's a match
+func (rows Rows) subtract(initial Rows) {
+	differ.SubtractRows(rows, initial)
+}
+
+// totals returns the totals of all the rows
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.status = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// needsRefresh is true if the totals of the backends found in both went
+// backwards, e.g. because ProxySQL was restarted
+func (rows Rows) needsRefresh(current Rows) bool {
+	return differ.NeedsRefresh(rows, current)
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"ops":       func(i, j int) int { return sort_keys.Descending(rows[i].queries, rows[j].queries) },
+		"errors":    func(i, j int) int { return sort_keys.Descending(rows[i].connERR, rows[j].connERR) },
+		"used":      func(i, j int) int { return sort_keys.Descending(rows[i].connUsed, rows[j].connUsed) },
+		"latency":   func(i, j int) int { return sort_keys.Descending(rows[i].latency, rows[j].latency) },
+		"hostgroup": func(i, j int) int { return sort_keys.SignedDescending(rows[j].hostgroup, rows[i].hostgroup) }, // ascending
+		"name":      func(i, j int) int { return sort_keys.Ascending(rows[i].backend(), rows[j].backend()) },
+	}
+}
+
+// sort by hostgroup and the number of queries (descending) so the
+// backends of a hostgroup can be compared, and then by "name"
+// (ascending) after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("proxy_backends", "hostgroup", "ops", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("proxy_backends", Rows(nil).sortKeys())
+}
+
+// backend headings
+func (row *Row) headings() string {
+	return fmt.Sprintf("%8s %6s %8s %8s %6s %6s %8s %8s %10s %-12s %4s|%s",
+		"Queries", "%", "ConnOK", "ConnERR", "Used", "Free", "Sent", "Recv", "Ping", "Status", "HG", "Backend")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	hostgroup, latency := "", ""
+	if row.host != "" {
+		hostgroup = fmt.Sprint(row.hostgroup)
+		latency = lib.FormatTime(row.latency * picoseconds)
+	}
+
+	return fmt.Sprintf("%8s %6s %8s %8s %6s %6s %8s %8s %10s %-12s %4s|%s",
+		lib.FormatAmount(row.queries),
+		lib.FormatPct(lib.MyDivide(row.queries, totals.queries)),
+		lib.FormatAmount(row.connOK),
+		lib.FormatAmount(row.connERR),
+		lib.FormatAmount(row.connUsed),
+		lib.FormatAmount(row.connFree),
+		lib.FormatAmount(row.bytesSent),
+		lib.FormatAmount(row.bytesRecv),
+		latency,
+		row.status,
+		hostgroup,
+		row.backend())
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%d %s %s %10s %10s",
+		row.hostgroup,
+		row.backend(),
+		row.status,
+		lib.FormatAmount(row.queries),
+		lib.FormatAmount(row.connERR))
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row, the queries
+// being count_star like the events of the other views
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name(),
+		Values: map[string]uint64{
+			"count_star": row.queries,
+			"conn_ok":    row.connOK,
+			"conn_err":   row.connERR,
+			"bytes_sent": row.bytesSent,
+			"bytes_recv": row.bytesRecv,
+		},
+	}
+}
@@ -0,0 +1,29 @@
+package proxy_backends
+
+import (
+	"testing"
+)
+
+func TestSubtract(t *testing.T) {
+	initial := Rows{
+		{hostgroup: 10, host: "db1", port: "3306", connUsed: 8, connOK: 100, connERR: 1, queries: 5000, bytesSent: 70000, bytesRecv: 90000, latency: 300},
+		{hostgroup: 20, host: "db2", port: "3306", connOK: 50, queries: 9000},
+	}
+	current := Rows{
+		{hostgroup: 10, host: "db1", port: "3306", connUsed: 3, connOK: 120, connERR: 4, queries: 6500, bytesSent: 75000, bytesRecv: 99000, latency: 250},
+		{hostgroup: 20, host: "db2", port: "3306", connOK: 10, queries: 200}, // restarted
+		{hostgroup: 20, host: "db3", port: "3306", connOK: 5, queries: 40},   // new
+	}
+
+	current.subtract(initial)
+	want := Rows{
+		{hostgroup: 10, host: "db1", port: "3306", connUsed: 3, connOK: 20, connERR: 3, queries: 1500, bytesSent: 5000, bytesRecv: 9000, latency: 250},
+		{hostgroup: 20, host: "db2", port: "3306", connOK: 10, queries: 200},
+		{hostgroup: 20, host: "db3", port: "3306", connOK: 5, queries: 40},
+	}
+	for i := range want {
+		if current[i] != want[i] {
+			t.Errorf("subtract()[%d] = %+v, want %+v", i, current[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package proxy_backends shows the backend servers ProxySQL sends the
+// queries to from its stats_mysql_connection_pool table: the queries,
+// connections and errors of each server in each hostgroup, so the load
+// seen by a server can be attributed to the proxy and compared with
+// the other servers of its hostgroup.
+package proxy_backends
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Object holds a table of rows
+type Object struct {
+	baseobject.BaseObject         // embedded
+	proxy                 *sql.DB // the ProxySQL admin interface
+	initial               Rows    // initial data for relative values
+	mark                  Rows    // marked data for relative values since the mark
+	current               Rows    // last loaded values
+	results               Rows    // results (maybe with subtraction)
+	totals                Row     // totals of results
+}
+
+// NewProxyBackends returns a pointer to an object of this type which
+// reads from the ProxySQL admin interface
+func NewProxyBackends(ctx *context.Context, proxy *sql.DB) *Object {
+	logger.Println("NewProxyBackends()")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.proxy = proxy
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// Collect collects data from the ProxySQL admin interface rather than
+// the server, updating initial values if needed, and then subtracting
+// initial values if we want relative values, after which it stores
+// totals.
+func (t *Object) Collect(_ *sql.DB) error {
+	if t.proxy == nil {
+		return errors.New("no ProxySQL admin interface was given with --proxy-admin")
+	}
+	start := time.Now()
+	rows, err := selectRows(t.proxy)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.initial.needsRefresh(t.current) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.mark = t.mark[:0]
+		t.SetMarkCollectTime(time.Time{})
+	}
+
+	t.makeResults()
+
+	logger.Println("proxy_backends.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+func (t *Object) makeResults() {
+	t.results = append(Rows{}, t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+
+	t.results.sort()
+	t.totals = t.results.totals()
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+
+	t.makeResults()
+}
+
+// EmptyRowContent returns a string representation of no data
+func (t Object) EmptyRowContent() string {
+	var r Row
+
+	return r.rowContent(r)
+}
+
+// Headings returns a string representation of the headings
+func (t *Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns a string representation of the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// TotalRowContent returns a string representation of the totals of the table
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// Description returns a description of the table
+func (t Object) Description() string {
+	return fmt.Sprintf("ProxySQL Backends (stats_mysql_connection_pool) %d rows", len(t.results))
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -0,0 +1,245 @@
+package proxy_digests
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+/**************************************************************************
+
+ProxySQL admin> SHOW CREATE TABLE stats.stats_mysql_query_digest\G
+*************************** 1. row ***************************
+       table: stats_mysql_query_digest
+Create Table: CREATE TABLE stats_mysql_query_digest (
+    hostgroup INT,
+    schemaname VARCHAR NOT NULL,
+    username VARCHAR NOT NULL,
+    client_address VARCHAR NOT NULL,
+    digest VARCHAR NOT NULL,
+    digest_text VARCHAR NOT NULL,
+    count_star INTEGER NOT NULL,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+    sum_time INTEGER NOT NULL,
+    min_time INTEGER NOT NULL,
+    max_time INTEGER NOT NULL,
+    sum_rows_affected INTEGER NOT NULL,
+    sum_rows_sent INTEGER NOT NULL,
+    PRIMARY KEY(hostgroup, schemaname, username, client_address, digest))
+1 row in set (0.00 sec)
+
+The times are in microseconds.
+
+**************************************************************************/
+
+// picoseconds in a microsecond, as the times are shown like those of performance_schema
+const picoseconds = 1000000
+
+// Row contains the statistics of a digest run by a user in a schema and
+// sent to a hostgroup
+type Row struct {
+	hostgroup       int64
+	schemaName      string
+	userName        string
+	digest          string
+	digestText      string
+	countStar       uint64
+	sumTime         uint64
+	sumRowsAffected uint64
+	sumRowsSent     uint64
+}
+
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"hostgroup":         func(r *Row) interface{} { return &r.hostgroup },
+	"schemaname":        func(r *Row) interface{} { return &r.schemaName },
+	"username":          func(r *Row) interface{} { return &r.userName },
+	"digest":            func(r *Row) interface{} { return &r.digest },
+	"digest_text":       func(r *Row) interface{} { return &r.digestText },
+	"count_star":        func(r *Row) interface{} { return &r.countStar },
+	"sum_time":          func(r *Row) interface{} { return &r.sumTime },
+	"sum_rows_affected": func(r *Row) interface{} { return &r.sumRowsAffected },
+	"sum_rows_sent":     func(r *Row) interface{} { return &r.sumRowsSent },
+}
+
+// Rows contains a slice of Rows
+type Rows []Row
+
+// name identifies the row
+func (row Row) name() string {
+	return fmt.Sprintf("%d %s %s %s", row.hostgroup, row.userName, row.schemaName, row.digest)
+}
+
+// select the digests the proxy has seen, the same digest run by clients
+// on different addresses being added together
+func selectRows(dbh *sql.DB) (Rows, error) {
+	logger.Println("proxy_digests.selectRows()")
+	query := `SELECT hostgroup, schemaname, username, digest, digest_text,
+SUM(count_star) AS count_star, SUM(sum_time) AS sum_time, SUM(sum_rows_affected) AS sum_rows_affected, SUM(sum_rows_sent) AS sum_rows_sent
+FROM stats.stats_mysql_query_digest
+GROUP BY hostgroup, schemaname, username, digest, digest_text`
+	t, err := lib.ReadRowsFromSQL(dbh, columns, query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range t {
+		t[i].schemaName = anonymiser.Anonymise("schema", t[i].schemaName)
+		t[i].userName = anonymiser.Anonymise("user", t[i].userName)
+		t[i].digestText = strings.Join(strings.Fields(t[i].digestText), " ")
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// add the values of another row
+func (row *Row) add(other Row) {
+	row.countStar += other.countStar
+	row.sumTime += other.sumTime
+	row.sumRowsAffected += other.sumRowsAffected
+	row.sumRowsSent += other.sumRowsSent
+}
+
+// subtract the values of another row
+func (row *Row) subtract(other Row) {
+	row.countStar -= other.countStar
+	row.sumTime -= other.sumTime
+	row.sumRowsAffected -= other.sumRowsAffected
+	row.sumRowsSent -= other.sumRowsSent
+}
+
+// differ matches the rows by hostgroup, user, schema and digest to
+// subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      Row.name,
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTime} },
+	Subtract: (*Row).subtract,
+}
+
+// remove the initial values from those rows where there's a match
+func (rows Rows) subtract(initial Rows) {
+	differ.SubtractRows(rows, initial)
+}
+
+// totals returns the totals of all the rows
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.digestText = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// needsRefresh is true if the totals of the digests found in both went
+// backwards, e.g. because they were reset with
+// stats_mysql_query_digest_reset
+func (rows Rows) needsRefresh(current Rows) bool {
+	return differ.NeedsRefresh(rows, current)
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency":   func(i, j int) int { return sort_keys.Descending(rows[i].sumTime, rows[j].sumTime) },
+		"ops":       func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"rows":      func(i, j int) int { return sort_keys.Descending(rows[i].sumRowsSent, rows[j].sumRowsSent) },
+		"hostgroup": func(i, j int) int { return sort_keys.SignedDescending(rows[j].hostgroup, rows[i].hostgroup) }, // ascending
+		"user":      func(i, j int) int { return sort_keys.Ascending(rows[i].userName, rows[j].userName) },
+		"name":      func(i, j int) int { return sort_keys.Ascending(rows[i].digestText, rows[j].digestText) },
+	}
+}
+
+// sort by latency (descending) and then by "name" (ascending) after any
+// configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("proxy_digests", "latency", "ops", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("proxy_digests", Rows(nil).sortKeys())
+}
+
+// average returns the average latency of a query (if any)
+func average(sumTime, count uint64) string {
+	if count == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(sumTime * picoseconds / count)
+}
+
+// digest headings
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %8s %10s %8s %8s %4s|%-12s|%-12s|%s",
+		"Latency", "%", "Ops", "Avg", "Sent", "Affected", "HG", "User", "Schema", "Digest")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	hostgroup := ""
+	if row.digest != "" {
+		hostgroup = fmt.Sprint(row.hostgroup)
+	}
+
+	return fmt.Sprintf("%10s %6s %8s %10s %8s %8s %4s|%-12s|%-12s|%s",
+		lib.FormatTime(row.sumTime*picoseconds),
+		lib.FormatPct(lib.MyDivide(row.sumTime, totals.sumTime)),
+		lib.FormatAmount(row.countStar),
+		average(row.sumTime, row.countStar),
+		lib.FormatAmount(row.sumRowsSent),
+		lib.FormatAmount(row.sumRowsAffected),
+		hostgroup,
+		row.userName,
+		row.schemaName,
+		row.digestText)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10s %10s %d %s %s %s",
+		lib.FormatTime(row.sumTime*picoseconds),
+		lib.FormatAmount(row.countStar),
+		row.hostgroup,
+		row.userName,
+		row.schemaName,
+		row.digestText)
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row, with the
+// latency in picoseconds like those of performance_schema
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name(),
+		Values: map[string]uint64{
+			"count_star":        row.countStar,
+			"sum_timer_wait":    row.sumTime * picoseconds,
+			"sum_rows_affected": row.sumRowsAffected,
+			"sum_rows_sent":     row.sumRowsSent,
+		},
+	}
+}
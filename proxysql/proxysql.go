@@ -0,0 +1,96 @@
+// Package proxysql detects a connection made through ProxySQL and
+// connects to ProxySQL's admin interface, whose stats schema shows the
+// proxy's side of the picture: the queries it has seen by digest and
+// the connections and queries sent to each backend server.
+//
+// MySQL Router has no SQL interface to its statistics so only ProxySQL
+// is supported.
+package proxysql
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sjmudd/ps-top/demo"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+const (
+	// Name is the name of the proxy shown
+	Name = "ProxySQL"
+	// DefaultAddress is the usual address of the admin interface
+	DefaultAddress = "admin:admin@127.0.0.1:6032"
+
+	defaultUser     = "admin"
+	defaultPassword = "admin"
+	defaultPort     = "6032"
+	sqlDriver       = "mysql"
+)
+
+// Detect returns true if the connection goes through ProxySQL, which
+// answers this query itself rather than sending it to a backend
+func Detect(dbh *sql.DB) bool {
+	var comment string
+
+	if err := dbh.QueryRow("select @@version_comment limit 1").Scan(&comment); err != nil {
+		logger.Println("proxysql.Detect() unable to check the version comment:", err)
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(comment), "proxysql")
+}
+
+// dsn returns the dsn of the admin interface given its address as
+// [user[:password]@]host[:port], using the defaults for those missing
+func dsn(address string) (string, error) {
+	user, password, hostPort := defaultUser, defaultPassword, address
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		user, hostPort = address[:i], address[i+1:]
+		if j := strings.Index(user, ":"); j >= 0 {
+			user, password = user[:j], user[j+1:]
+		}
+	}
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = strings.Trim(hostPort, "[]"), defaultPort
+	}
+	if user == "" || host == "" || strings.ContainsAny(host, "/@") {
+		return "", fmt.Errorf("the ProxySQL admin interface %q should be given as [user[:password]@]host[:port]", lib.MaskPassword(address))
+	}
+
+	return user + ":" + password + "@tcp(" + net.JoinHostPort(host, port) + ")/", nil
+}
+
+// Open connects to the admin interface at the address, or to the demo
+// server's synthetic statistics if demoMode is set. If no address is
+// given there is no connection and nil is returned.
+func Open(address string, demoMode bool) (*sql.DB, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	driver, source := demo.DriverName, ""
+	if !demoMode {
+		var err error
+		if source, err = dsn(address); err != nil {
+			return nil, err
+		}
+		driver = sqlDriver
+	}
+
+	dbh, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := dbh.Ping(); err != nil {
+		dbh.Close()
+		return nil, fmt.Errorf("unable to connect to the ProxySQL admin interface %s: %v", lib.MaskPassword(address), err)
+	}
+	dbh.SetMaxOpenConns(2) // the stats are only read by the proxy views
+	dbh.SetMaxIdleConns(1)
+
+	return dbh, nil
+}
@@ -0,0 +1,33 @@
+package proxysql
+
+import (
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":                      "admin:admin@tcp(127.0.0.1:6032)/",
+		"proxy1:6033":                    "admin:admin@tcp(proxy1:6033)/",
+		"radmin@proxy1":                  "radmin:admin@tcp(proxy1:6032)/",
+		"radmin:s3cr:et@proxy1:6032":     "radmin:s3cr:et@tcp(proxy1:6032)/",
+		"radmin:p@ss@[2001:db8::1]:6032": "radmin:p@ss@tcp([2001:db8::1]:6032)/",
+		"::1":                            "admin:admin@tcp([::1]:6032)/",
+	}
+
+	for address, expected := range tests {
+		got, err := dsn(address)
+		if err != nil {
+			t.Errorf("dsn(%q) returned an error: %v", address, err)
+			continue
+		}
+		if got != expected {
+			t.Errorf("dsn(%q) = %q, expected %q", address, got, expected)
+		}
+	}
+
+	for _, address := range []string{"", "@proxy1", "radmin@", "radmin@:6032"} {
+		if _, err := dsn(address); err == nil {
+			t.Errorf("dsn(%q) expected an error", address)
+		}
+	}
+}
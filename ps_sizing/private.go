@@ -0,0 +1,211 @@
+// Package ps_sizing contains the library routines for comparing the
+// performance_schema sizing variables with the *_lost status counters and
+// the rows in the tables they size.
+package ps_sizing
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// prefix of the sizing variables, not shown in the view
+const prefix = "performance_schema_"
+
+// a sizing variable, the status counter of what was lost because it was
+// too small and the performance_schema table it sizes (if any)
+type sizing struct {
+	variable string
+	lost     string
+	table    string
+}
+
+// sizings are the variables checked (if the server has them)
+var sizings = []sizing{
+	{"performance_schema_digests_size", "performance_schema_digest_lost", "events_statements_summary_by_digest"},
+	{"performance_schema_accounts_size", "performance_schema_accounts_lost", "accounts"},
+	{"performance_schema_hosts_size", "performance_schema_hosts_lost", "hosts"},
+	{"performance_schema_users_size", "performance_schema_users_lost", "users"},
+	{"performance_schema_max_thread_instances", "performance_schema_thread_instances_lost", "threads"},
+	{"performance_schema_max_mutex_instances", "performance_schema_mutex_instances_lost", "mutex_instances"},
+	{"performance_schema_max_rwlock_instances", "performance_schema_rwlock_instances_lost", "rwlock_instances"},
+	{"performance_schema_max_cond_instances", "performance_schema_cond_instances_lost", "cond_instances"},
+	{"performance_schema_max_file_instances", "performance_schema_file_instances_lost", "file_instances"},
+	{"performance_schema_max_file_handles", "performance_schema_file_handles_lost", ""},
+	{"performance_schema_max_table_instances", "performance_schema_table_instances_lost", ""},
+	{"performance_schema_max_table_handles", "performance_schema_table_handles_lost", "table_handles"},
+	{"performance_schema_max_table_lock_stat", "performance_schema_table_lock_stat_lost", "table_lock_waits_summary_by_table"},
+	{"performance_schema_max_index_stat", "performance_schema_index_stat_lost", "table_io_waits_summary_by_index_usage"},
+	{"performance_schema_max_metadata_locks", "performance_schema_metadata_lock_lost", "metadata_locks"},
+	{"performance_schema_max_prepared_statements_instances", "performance_schema_prepared_statements_lost", "prepared_statements_instances"},
+	{"performance_schema_max_program_instances", "performance_schema_program_lost", ""},
+	{"performance_schema_max_statement_stack", "performance_schema_nested_statement_lost", ""},
+	{"performance_schema_max_memory_classes", "performance_schema_memory_classes_lost", ""},
+	{"performance_schema_max_statement_classes", "performance_schema_statement_classes_lost", ""},
+	{"performance_schema_session_connect_attrs_size", "performance_schema_session_connect_attrs_lost", ""},
+}
+
+// the sizes above this fraction full are reported as nearly full
+const nearlyFull = 0.9
+
+// Row contains a sizing variable and what it sizes
+type Row struct {
+	name     string // variable name without the prefix
+	size     int64  // -1 if autosized
+	used     uint64 // rows in the table sized
+	haveUsed bool   // the rows could be counted
+	lost     uint64 // instruments or events lost as the size was too small
+	advice   string
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// countRows returns the rows in the given performance_schema table
+func countRows(dbh *sql.DB, table string) (uint64, error) {
+	var count uint64
+
+	err := dbh.QueryRow("SELECT COUNT(*) FROM performance_schema." + table).Scan(&count)
+
+	return count, err
+}
+
+// selectRows returns a row for each sizing variable the server has. A
+// table which can't be counted, e.g. on an older server, is left out.
+func selectRows(dbh *sql.DB, variables *global.Variables, status global.StatusValues) Rows {
+	var t Rows
+
+	for _, s := range sizings {
+		value := variables.Get(s.variable)
+		if value == "" {
+			continue
+		}
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			logger.Println("ps_sizing.selectRows() ignoring", s.variable, "=", value, ":", err)
+			continue
+		}
+
+		r := Row{name: strings.TrimPrefix(s.variable, prefix), size: size, lost: status[s.lost]}
+		if s.table != "" {
+			if used, err := countRows(dbh, s.table); err != nil {
+				logger.Println("ps_sizing.selectRows() unable to count", s.table, ":", err)
+			} else {
+				r.used, r.haveUsed = used, true
+			}
+		}
+		r.advice = advice(s.variable, r.size, r.used, r.lost)
+		t = append(t, r)
+	}
+	logger.Println("ps_sizing.selectRows() found", len(t), "sizing variable(s)")
+
+	return t
+}
+
+// advice returns the my.cnf change suggested for the variable, or
+// nothing if it looks big enough. The suggested size is twice the
+// larger of the size and the rows used.
+func advice(variable string, size int64, used, lost uint64) string {
+	base := used
+	if size > 0 && uint64(size) > base {
+		base = uint64(size)
+	}
+
+	switch {
+	case lost > 0 && base == 0:
+		return fmt.Sprintf("lost data: set %s above 0", variable)
+	case lost > 0 && size < 0:
+		return fmt.Sprintf("lost data although autosized: set %s = %d", variable, 2*base)
+	case lost > 0:
+		return fmt.Sprintf("lost data: set %s = %d", variable, 2*base)
+	case size > 0 && float64(used) >= nearlyFull*float64(size):
+		return fmt.Sprintf("nearly full: consider %s = %d", variable, 2*base)
+	}
+
+	return ""
+}
+
+// usedPct returns the fraction of the size used, 0 if unknown
+func (row Row) usedPct() float64 {
+	if !row.haveUsed || row.size <= 0 {
+		return 0
+	}
+
+	return lib.MyDivide(row.used, uint64(row.size))
+}
+
+// totals returns the total lost and the number of variables with advice
+func (rows Rows) totals() Row {
+	total := Row{name: "Totals"}
+	changes := 0
+
+	for i := range rows {
+		total.lost += rows[i].lost
+		if rows[i].advice != "" {
+			changes++
+		}
+	}
+	total.advice = fmt.Sprintf("%d change(s) suggested", changes)
+
+	return total
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"lost": func(i, j int) int { return sort_keys.Descending(rows[i].lost, rows[j].lost) },
+		"used": func(i, j int) int {
+			return sort_keys.Descending(uint64(1e6*rows[i].usedPct()), uint64(1e6*rows[j].usedPct()))
+		},
+		"name": func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by lost (descending), then by the fraction used (descending) and
+// then by "name" (ascending) after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("ps_sizing", "lost", "used", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("ps_sizing", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func headings() string {
+	return fmt.Sprintf("%10s %10s %6s %10s|%-40s|%s", "Size", "Used", "Used%", "Lost", "Variable", "Advice")
+}
+
+// generate a printable result
+func (row Row) rowContent() string {
+	var size string
+	switch {
+	case row.size < 0:
+		size = "auto"
+	case row.size > 0:
+		size = strconv.FormatInt(row.size, 10)
+	}
+	var used, pct string
+	if row.haveUsed {
+		used = lib.FormatAmount(row.used)
+		if row.size > 0 {
+			pct = lib.FormatPct(row.usedPct())
+		}
+	}
+
+	return fmt.Sprintf("%10s %10s %6s %10s|%-40s|%s",
+		size,
+		used,
+		pct,
+		lib.FormatAmount(row.lost),
+		row.name,
+		row.advice)
+}
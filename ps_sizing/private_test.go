@@ -0,0 +1,26 @@
+package ps_sizing
+
+import (
+	"testing"
+)
+
+func TestAdvice(t *testing.T) {
+	tests := []struct {
+		size       int64
+		used, lost uint64
+		want       string
+	}{
+		{10000, 4000, 0, ""},
+		{10000, 9500, 0, "nearly full: consider performance_schema_digests_size = 20000"},
+		{10000, 10000, 25, "lost data: set performance_schema_digests_size = 20000"},
+		{-1, 3000, 25, "lost data although autosized: set performance_schema_digests_size = 6000"},
+		{0, 0, 25, "lost data: set performance_schema_digests_size above 0"},
+		{-1, 3000, 0, ""},
+	}
+
+	for _, test := range tests {
+		if got := advice("performance_schema_digests_size", test.size, test.used, test.lost); got != test.want {
+			t.Errorf("advice(%d, %d, %d) = %q, want %q", test.size, test.used, test.lost, got, test.want)
+		}
+	}
+}
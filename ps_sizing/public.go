@@ -0,0 +1,99 @@
+// Package ps_sizing shows the performance_schema sizing variables with
+// how full the tables they size are and what has been lost because they
+// are too small, and suggests the my.cnf changes to make. An undersized
+// performance_schema silently skews the other views.
+package ps_sizing
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the sizing variables
+type Object struct {
+	baseobject.BaseObject      // embedded
+	current               Rows // sizing variables at the last collection
+	totals                Row  // totals of current
+}
+
+// NewPSSizing returns a pointer to an object of this type
+func NewPSSizing(ctx *context.Context) *Object {
+	logger.Println("NewPSSizing()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the lost counters and the rows used. There are no
+// relative values as anything lost since the server started skews the
+// statistics.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	lost, err := t.Status().Values(prefix)
+	if err != nil {
+		return err
+	}
+	rows := selectRows(dbh, t.Variables(), lost)
+	rows.sort()
+	t.current = rows
+	t.totals = rows.totals()
+	t.SetLastCollectTimeNow()
+
+	logger.Println("ps_sizing.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+
+	for i := range t.current {
+		rows = append(rows, t.current[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the total lost and the changes suggested
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent()
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	return fmt.Sprintf("P_S Sizing (global_variables, global_status) %d variable(s)", len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as we show the lost counters since the server started
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("ps_sizing.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
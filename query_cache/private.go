@@ -0,0 +1,127 @@
+// Package query_cache contains the library routines for showing the
+// query cache and thread pool status counters.
+package query_cache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+)
+
+// statusPrefixes are the global status variables we collect
+var statusPrefixes = []string{"Qcache_", "Threadpool_", "Com_select"}
+
+// a status variable to show and the variables whose sum its percentage is of
+type statusVariable struct {
+	name  string
+	of    []string
+	gauge bool // the current value is shown rather than the change
+}
+
+// statusVariables are shown in this order (if the server provides them)
+var statusVariables = []statusVariable{
+	{"Qcache_hits", []string{"Qcache_hits", "Com_select"}, false},
+	{"Com_select", []string{"Qcache_hits", "Com_select"}, false},
+	{"Qcache_inserts", []string{"Com_select"}, false},
+	{"Qcache_not_cached", []string{"Com_select"}, false},
+	{"Qcache_lowmem_prunes", []string{"Qcache_inserts"}, false},
+	{"Qcache_queries_in_cache", nil, true},
+	{"Qcache_free_memory", nil, true},
+	{"Qcache_free_blocks", []string{"Qcache_total_blocks"}, true},
+	{"Qcache_total_blocks", nil, true},
+	{"Threadpool_threads", nil, true},
+	{"Threadpool_idle_threads", []string{"Threadpool_threads"}, true},
+}
+
+// Row contains a status counter
+type Row struct {
+	name  string
+	value uint64 // the change in a counter or the current value of a gauge
+	total uint64 // the value the percentage is of, 0 if none
+	rate  bool   // show the value per second
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// statusRows returns the status variables we show in their fixed order.
+// The query cache rows are only shown if the server has a query cache
+// and the thread pool rows if it has a thread pool.
+func statusRows(current, changed global.StatusValues) Rows {
+	var t Rows
+
+	// the value to show for the given variable
+	value := func(v statusVariable, name string) uint64 {
+		key := strings.ToLower(name)
+		if v.gauge {
+			return current[key]
+		}
+		return changed[key]
+	}
+
+	_, haveQueryCache := current["qcache_hits"]
+	for _, v := range statusVariables {
+		if _, found := current[strings.ToLower(v.name)]; !found {
+			continue
+		}
+		if v.name == "Com_select" && !haveQueryCache {
+			continue
+		}
+		r := Row{name: v.name, value: value(v, v.name), rate: !v.gauge}
+		for _, name := range v.of {
+			r.total += value(v, name)
+		}
+		t = append(t, r)
+	}
+
+	return t
+}
+
+// gauges are the lower-cased names of the variables whose current value
+// is shown, which may go down at any time
+var gauges = func() map[string]bool {
+	g := make(map[string]bool)
+	for _, v := range statusVariables {
+		if v.gauge {
+			g[strings.ToLower(v.name)] = true
+		}
+	}
+	return g
+}()
+
+// hitRatio returns the percentage of SELECTs served from the query cache
+func hitRatio(changed global.StatusValues) string {
+	hits := changed["qcache_hits"]
+
+	return strings.TrimSpace(lib.FormatPct(lib.MyDivide(hits, hits+changed["com_select"])))
+}
+
+// headings returns the headings of the value, rate, percentage and name columns
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %10s %6s|%s", "Value", "Rate/s", "%", "Name")
+}
+
+// generate a printable result
+func (row *Row) rowContent(seconds float64) string {
+	rate := ""
+	if row.rate && seconds > 0 {
+		rate = lib.FormatAmount(uint64(float64(row.value)/seconds + 0.5))
+	}
+	pct := ""
+	if row.total > 0 {
+		pct = lib.FormatPct(lib.MyDivide(row.value, row.total))
+	}
+
+	return fmt.Sprintf("%10s %10s %6s|%s",
+		lib.FormatAmount(row.value),
+		rate,
+		pct,
+		row.name)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10d %10d %s", row.value, row.total, row.name)
+}
@@ -0,0 +1,160 @@
+// Package query_cache contains the library routines for showing the
+// query cache and thread pool status counters.
+package query_cache
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the query cache and thread pool status counters
+type Object struct {
+	baseobject.BaseObject                     // embedded
+	initial               global.StatusValues // initial status values for relative values
+	current               global.StatusValues // last loaded status values
+	results               Rows                // the status rows
+	totals                Row                 // SELECTs served from the cache or executed
+}
+
+// NewQueryCache returns a pointer to an object of this type
+func NewQueryCache(ctx *context.Context) *Object {
+	logger.Println("NewQueryCache()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = make(global.StatusValues)
+	for name, value := range t.current {
+		t.initial[name] = value
+	}
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// Collect collects the status counters, updating initial values
+// if needed and generating the results.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	values, err := t.Status().Values(statusPrefixes...)
+	if err != nil {
+		return err
+	}
+	t.current = values
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "status value(s)")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.current.NeedsRefresh(t.initial, gauges) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("query_cache.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// changed returns the status values to show, relative to the initial ones if wanted
+func (t Object) changed() global.StatusValues {
+	if t.WantRelativeStats() {
+		return t.current.Subtract(t.initial)
+	}
+	return t.current
+}
+
+// generate the results and totals
+func (t *Object) makeResults() {
+	changed := t.changed()
+
+	t.results = statusRows(t.current, changed)
+	t.totals = Row{name: "SELECTs (cached and executed)", rate: true}
+	if _, found := t.current["qcache_hits"]; found {
+		t.totals.value = changed["qcache_hits"] + changed["com_select"]
+	}
+}
+
+// seconds returns the period over which the counters have been collected
+func (t Object) seconds() float64 {
+	if t.WantRelativeStats() {
+		return t.LastCollectTime().Sub(t.InitialCollectTime()).Seconds()
+	}
+	return float64(t.Status().Get("Uptime"))
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// Headings returns the headings of the object
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+	seconds := t.seconds()
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(seconds))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(0)
+}
+
+// TotalRowContent returns a row containing the SELECTs seen
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.seconds())
+}
+
+// Description returns the query cache hit ratio and thread pool usage if available
+func (t Object) Description() string {
+	description := "Query Cache and Thread Pool (global_status)"
+
+	if _, found := t.current["qcache_hits"]; found {
+		description += " query cache hit ratio: " + hitRatio(t.changed())
+	} else {
+		description += " no query cache"
+	}
+	if threads, found := t.current["threadpool_threads"]; found {
+		description += fmt.Sprintf(", thread pool: %d thread(s), %d idle", threads, t.current["threadpool_idle_threads"])
+	}
+
+	return description
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
@@ -30,6 +30,8 @@ type mungeRegexp struct {
 type mungeRegexps []mungeRegexp
 
 var (
+	config        go_ini.File // contents of ~/.pstoprc
+	loadedConfig  bool        // Have we [attempted to] load ~/.pstoprc?
 	regexps       mungeRegexps
 	loadedRegexps bool // Have we [attempted to] loaded data?
 	haveRegexps   bool // Do we have any valid data?
@@ -65,23 +67,24 @@ func convertFilename(filename string) string {
 	return filename
 }
 
-// Load the ~/.pstoprc regexp expressions in section [munge]
-func loadRegexps() {
-	if loadedRegexps {
-		return
+// load reads ~/.pstoprc (once) and returns its contents. A missing
+// file is not an error, we just don't have any configuration.
+func load() go_ini.File {
+	if loadedConfig {
+		return config
 	}
-	loadedRegexps = true
+	loadedConfig = true
 
-	logger.Println("rc.loadRegexps()")
+	logger.Println("rc.load()")
 
-	haveRegexps = false
+	config = make(go_ini.File)
 	filename := convertFilename(pstoprc)
 
 	// Is the file is there?
 	f, err := os.Open(filename)
 	if err != nil {
-		logger.Println("- unable to open " + filename + ", nothing to munge")
-		return // can't open file. This is not fatal. We just can't do anything useful.
+		logger.Println("- unable to open " + filename + ", no configuration to use")
+		return config // can't open file. This is not fatal. We just can't do anything useful.
 	}
 	// If we get here the file is readable, so close it again.
 	err = f.Close()
@@ -90,15 +93,49 @@ func loadRegexps() {
 	}
 
 	// Load and process the ini file.
-	i, err := go_ini.LoadFile(filename)
+	config, err = go_ini.LoadFile(filename)
 	if err != nil {
 		log.Fatal("Could not load ~/.pstoprc", filename, ":", err)
 	}
 
+	return config
+}
+
+// Section returns the named section of ~/.pstoprc as a map of
+// key/value pairs. If the section is missing the map is empty.
+func Section(name string) map[string]string {
+	return load().Section(name)
+}
+
+// Sections returns the sections of ~/.pstoprc whose names start with
+// the given prefix. The map is indexed by the remainder of the name.
+func Sections(prefix string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+
+	for name, section := range load() {
+		if strings.HasPrefix(name, prefix) {
+			sections[strings.TrimPrefix(name, prefix)] = section
+		}
+	}
+
+	return sections
+}
+
+// Load the ~/.pstoprc regexp expressions in section [munge]
+func loadRegexps() {
+	if loadedRegexps {
+		return
+	}
+	loadedRegexps = true
+
+	logger.Println("rc.loadRegexps()")
+
+	haveRegexps = false
+
 	// Note: This is wrong if I want to have an _ordered_ list of regexps
 	// as go-ini provides me a hash so I lose the ordering. This may not
 	// be desirable but as a first step accept this is broken.
-	section := i.Section("munge")
+	section := Section("munge")
 
 	regexps = make(mungeRegexps, 0, len(section))
 
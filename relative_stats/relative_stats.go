@@ -0,0 +1,141 @@
+// Package relative_stats subtracts the baseline values collected earlier,
+// the initial values or those of the mark, from the values collected now
+// so the change since then can be shown.
+//
+// Rows are matched to their baseline by a key. A row without a baseline
+// is new and keeps its values. The counters only ever go up so if any of
+// a row's counters are lower than its baseline they were reset, e.g. by
+// TRUNCATE TABLE, by the object being recreated or by a restart, or they
+// wrapped around. The current values of that row, those since the reset,
+// are kept rather than showing the huge values of an unsigned
+// subtraction, and its baseline is zeroed so its later values are also
+// those since the reset. The other rows are unaffected.
+package relative_stats
+
+// Differ describes how to match and subtract the rows of type R, which
+// are identified by keys of type K
+type Differ[R any, K comparable] struct {
+	Key      func(row R) K        // identifies the row
+	Counters func(row R) []uint64 // the counters checked for going backwards
+	Subtract func(row *R, base R) // subtracts the baseline's values from the row
+}
+
+// Index returns the position of each row by its key, so it can be kept
+// with a baseline which is subtracted many times. If rows share a key
+// the last one is used.
+func (d Differ[R, K]) Index(rows []R) map[K]int {
+	byKey := make(map[K]int, len(rows))
+
+	for i := range rows {
+		byKey[d.Key(rows[i])] = i
+	}
+
+	return byKey
+}
+
+// WentBackwards is true if any counter of the row is lower than that of
+// its baseline
+func (d Differ[R, K]) WentBackwards(row, base R) bool {
+	counters, baseCounters := d.Counters(row), d.Counters(base)
+
+	for i := range counters {
+		if i < len(baseCounters) && counters[i] < baseCounters[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SubtractRows subtracts from each row the values of the baseline row with
+// the same key. If the row's counters went backwards the baseline row's
+// counters are zeroed, keeping its key, so the row's values since the
+// reset are shown now and later.
+func (d Differ[R, K]) SubtractRows(rows, baseline []R) {
+	d.SubtractIndexed(rows, baseline, d.Index(baseline))
+}
+
+// SubtractIndexed is SubtractRows given the Index of the baseline
+func (d Differ[R, K]) SubtractIndexed(rows, baseline []R, byKey map[K]int) {
+	for i := range rows {
+		j, ok := byKey[d.Key(rows[i])]
+		if !ok {
+			continue
+		}
+		if d.WentBackwards(rows[i], baseline[j]) {
+			reset := rows[i]
+			d.Subtract(&reset, rows[i])
+			baseline[j] = reset
+			continue
+		}
+		d.Subtract(&rows[i], baseline[j])
+	}
+}
+
+// NeedsRefresh is true if the statistics were reset on the server, e.g.
+// by truncating the performance_schema table or by a restart, in which
+// case the baseline should be collected again: some of the rows found in
+// both the baseline and the current rows went backwards and none of them
+// went forwards. A row which went backwards on its own, e.g. a table
+// which was recreated, is left to SubtractRows so one busy table being
+// recreated doesn't reset the baseline of the others. Rows which were
+// added or removed since the baseline don't count.
+func (d Differ[R, K]) NeedsRefresh(baseline, current []R) bool {
+	var backwards, forwards int
+	byKey := d.Index(baseline)
+
+	for i := range current {
+		j, ok := byKey[d.Key(current[i])]
+		if !ok {
+			continue
+		}
+		switch {
+		case d.WentBackwards(current[i], baseline[j]):
+			backwards++
+		case d.WentForwards(current[i], baseline[j]):
+			forwards++
+		}
+	}
+
+	return backwards > 0 && forwards == 0
+}
+
+// WentForwards is true if any counter of the row is higher than that of
+// its baseline
+func (d Differ[R, K]) WentForwards(row, base R) bool {
+	counters, baseCounters := d.Counters(row), d.Counters(base)
+
+	for i := range counters {
+		if i < len(baseCounters) && counters[i] > baseCounters[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delta returns the change of a counter since its baseline, or its
+// current value if it went backwards as it was reset or wrapped around
+func Delta(value, base uint64) uint64 {
+	if value < base {
+		return value
+	}
+	return value - base
+}
+
+// SubtractValues returns the change of each counter of values since the
+// counter of the same name in baseline, those without a baseline keeping
+// their values. The baseline of a counter which went backwards is zeroed
+// so its later values are also those since the reset.
+func SubtractValues[K comparable](values, baseline map[K]uint64) map[K]uint64 {
+	result := make(map[K]uint64, len(values))
+
+	for name, value := range values {
+		if base, ok := baseline[name]; ok && value < base {
+			baseline[name] = 0
+		}
+		result[name] = Delta(value, baseline[name])
+	}
+
+	return result
+}
@@ -0,0 +1,168 @@
+package relative_stats
+
+import (
+	"reflect"
+	"testing"
+)
+
+type row struct {
+	name    string
+	count   uint64
+	latency uint64
+}
+
+var differ = Differ[row, string]{
+	Key:      func(r row) string { return r.name },
+	Counters: func(r row) []uint64 { return []uint64{r.count, r.latency} },
+	Subtract: func(r *row, base row) {
+		r.count -= base.count
+		r.latency -= base.latency
+	},
+}
+
+func TestWentBackwards(t *testing.T) {
+	tests := []struct {
+		row, base row
+		want      bool
+	}{
+		{row{"a", 10, 100}, row{"a", 5, 50}, false},
+		{row{"a", 10, 100}, row{"a", 10, 100}, false},
+		{row{"a", 4, 100}, row{"a", 5, 50}, true},
+		{row{"a", 10, 40}, row{"a", 5, 50}, true},
+	}
+
+	for _, test := range tests {
+		if got := differ.WentBackwards(test.row, test.base); got != test.want {
+			t.Errorf("WentBackwards(%+v, %+v) = %v, want %v", test.row, test.base, got, test.want)
+		}
+	}
+}
+
+func TestSubtractRows(t *testing.T) {
+	baseline := []row{
+		{"same", 10, 100},
+		{"reset", 50, 500},
+		{"wrapped", ^uint64(0) - 5, 900},
+		{"removed", 7, 70},
+	}
+	rows := []row{
+		{"new", 3, 30},
+		{"same", 15, 160},
+		{"reset", 2, 20},
+		{"wrapped", 4, 950},
+	}
+
+	differ.SubtractRows(rows, baseline)
+	want := []row{
+		{"new", 3, 30},
+		{"same", 5, 60},
+		{"reset", 2, 20},
+		{"wrapped", 4, 950},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("SubtractRows() = %+v, want %+v", rows, want)
+	}
+}
+
+func TestSubtractRowsAfterReset(t *testing.T) {
+	baseline := []row{{"recreated", 1000, 10000}, {"other", 10, 100}}
+	byKey := differ.Index(baseline)
+
+	// the first table is recreated and then busier than before, the
+	// other keeps going
+	collections := []struct {
+		current, want []row
+	}{
+		{[]row{{"recreated", 500, 5000}, {"other", 20, 200}}, []row{{"recreated", 500, 5000}, {"other", 10, 100}}},
+		{[]row{{"recreated", 990, 9900}, {"other", 30, 300}}, []row{{"recreated", 990, 9900}, {"other", 20, 200}}},
+		{[]row{{"recreated", 1200, 12000}, {"other", 40, 400}}, []row{{"recreated", 1200, 12000}, {"other", 30, 300}}},
+	}
+
+	for i, collection := range collections {
+		rows := append([]row(nil), collection.current...)
+		if differ.NeedsRefresh(baseline, rows) {
+			t.Errorf("collection %d: NeedsRefresh() = true, want false as only one row was reset", i)
+		}
+		differ.SubtractIndexed(rows, baseline, byKey)
+		if !reflect.DeepEqual(rows, collection.want) {
+			t.Errorf("collection %d: SubtractIndexed() = %+v, want %+v", i, rows, collection.want)
+		}
+	}
+	if want := (row{"recreated", 0, 0}); baseline[0] != want {
+		t.Errorf("SubtractIndexed() left the baseline of the row reset as %+v, want %+v", baseline[0], want)
+	}
+}
+
+func TestSubtractRowsEmptyBaseline(t *testing.T) {
+	rows := []row{{"a", 1, 10}}
+
+	differ.SubtractRows(rows, nil)
+	if want := []row{{"a", 1, 10}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("SubtractRows() = %+v, want %+v", rows, want)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	got := differ.Index([]row{{"a", 1, 1}, {"b", 2, 2}, {"a", 3, 3}})
+	want := map[string]int{"a": 2, "b": 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Index() = %v, want %v", got, want)
+	}
+}
+
+func TestNeedsRefresh(t *testing.T) {
+	baseline := []row{{"a", 10, 100}, {"b", 20, 200}, {"dropped", 1000, 10000}}
+
+	tests := []struct {
+		name    string
+		current []row
+		want    bool
+	}{
+		{"grew", []row{{"a", 11, 110}, {"b", 20, 200}}, false},
+		{"dropped and new rows", []row{{"a", 10, 100}, {"b", 20, 200}, {"new", 1, 1}}, false},
+		{"one row reset", []row{{"a", 1, 10}, {"b", 40, 400}}, false},
+		{"all reset", []row{{"a", 1, 10}, {"b", 2, 20}}, true},
+		{"latency went backwards", []row{{"a", 10, 50}, {"b", 20, 200}}, true},
+		{"nothing in common", []row{{"c", 0, 0}}, false},
+	}
+
+	for _, test := range tests {
+		if got := differ.NeedsRefresh(baseline, test.current); got != test.want {
+			t.Errorf("NeedsRefresh() %s = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		value, base, want uint64
+	}{
+		{10, 4, 6},
+		{10, 10, 0},
+		{3, 10, 3},
+		{5, ^uint64(0), 5},
+	}
+
+	for _, test := range tests {
+		if got := Delta(test.value, test.base); got != test.want {
+			t.Errorf("Delta(%d, %d) = %d, want %d", test.value, test.base, got, test.want)
+		}
+	}
+}
+
+func TestSubtractValues(t *testing.T) {
+	values := map[string]uint64{"hits": 100, "misses": 3, "new": 7}
+	baseline := map[string]uint64{"hits": 60, "misses": 9, "gone": 1}
+
+	got := SubtractValues(values, baseline)
+	want := map[string]uint64{"hits": 40, "misses": 3, "new": 7}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SubtractValues() = %v, want %v", got, want)
+	}
+
+	// the counter reset keeps counting from zero
+	values["misses"] = 12
+	if got := SubtractValues(values, baseline)["misses"]; got != 12 {
+		t.Errorf("SubtractValues() of misses after a reset = %d, want 12", got)
+	}
+}
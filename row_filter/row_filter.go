@@ -0,0 +1,72 @@
+// Package row_filter provides the regular expressions which may be
+// configured for each view to limit the rows shown.
+//
+// Filters are configured per view in the [filter] section of ~/.pstoprc, e.g.
+// [filter]
+// file_io_latency = ^ibdata
+// table_io_latency = ^shop\.
+// or on the command line for the view shown on startup. A row is shown
+// if any of its columns matches the view's filter.
+package row_filter
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+var (
+	configured       map[string]*regexp.Regexp // filters by view name
+	loadedConfigured bool                      // Have we [attempted to] load ~/.pstoprc?
+)
+
+// compile returns the regular expression for a view's filter.
+// Invalid expressions are fatal.
+func compile(view, pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Fatal("Invalid filter '", pattern, "' for view ", view, ": ", err)
+	}
+	return re
+}
+
+// load the [filter] section of ~/.pstoprc (once)
+func load() {
+	if loadedConfigured {
+		return
+	}
+	loadedConfigured = true
+
+	configured = make(map[string]*regexp.Regexp)
+	for view, pattern := range rc.Section("filter") {
+		configured[view] = compile(view, pattern)
+	}
+	logger.Println("row_filter.load() found filters for", len(configured), "view(s)")
+}
+
+// Set sets the filter for the given view, overriding ~/.pstoprc
+func Set(view, pattern string) {
+	load()
+	logger.Println("row_filter.Set(", view, ",", pattern, ")")
+	configured[view] = compile(view, pattern)
+}
+
+// Configured returns the filter configured for the given view, nil if there is none
+func Configured(view string) *regexp.Regexp {
+	load()
+	return configured[view]
+}
+
+// Matches returns true if any of the '|' separated columns of the row
+// matches the filter, ignoring the padding around each column
+func Matches(re *regexp.Regexp, row string) bool {
+	for _, column := range strings.Split(row, "|") {
+		if re.MatchString(strings.TrimSpace(column)) {
+			return true
+		}
+	}
+	return false
+}
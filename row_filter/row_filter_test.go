@@ -0,0 +1,26 @@
+package row_filter
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	re := regexp.MustCompile("^ibdata")
+
+	tests := []struct {
+		row  string
+		want bool
+	}{
+		{"  12.3 ms  45.6%|     10|/var/lib/mysql/ibdata1", false},
+		{"  12.3 ms  45.6%|     10|ibdata1", true},
+		{"  12.3 ms  45.6%|     10|   ibdata2   ", true},
+		{"  12.3 ms  45.6%|     10|shop.orders", false},
+	}
+
+	for _, test := range tests {
+		if got := Matches(re, test.row); got != test.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", re, test.row, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package row_history keeps the values of the rows of each view over
+// the last collections so the recent history of a single row can be
+// shown, e.g. to see if a table has been busy all along or has only
+// just become busy.
+package row_history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Intervals is the number of intervals kept for each view
+const Intervals = 60
+
+// snapshot holds the values of the rows of a view from one collection
+type snapshot struct {
+	collected time.Time
+	values    map[string]map[string]uint64 // values by row name
+}
+
+// ring holds the last Intervals+1 snapshots of a view, which give the
+// changes over the last Intervals intervals
+type ring struct {
+	snapshots [Intervals + 1]snapshot
+	next      int // where the next snapshot is written
+	count     int // number of snapshots held
+}
+
+// add adds a snapshot, overwriting the oldest one if the ring is full
+func (r *ring) add(s snapshot) {
+	r.snapshots[r.next] = s
+	r.next = (r.next + 1) % len(r.snapshots)
+	if r.count < len(r.snapshots) {
+		r.count++
+	}
+}
+
+// ordered returns the snapshots held, oldest first
+func (r *ring) ordered() []snapshot {
+	s := make([]snapshot, 0, r.count)
+	for i := r.count; i > 0; i-- {
+		s = append(s, r.snapshots[(r.next-i+len(r.snapshots))%len(r.snapshots)])
+	}
+
+	return s
+}
+
+// History holds the recent values of the rows of each view
+type History struct {
+	views map[string]*ring
+}
+
+// NewHistory returns an empty History
+func NewHistory() *History {
+	return &History{views: make(map[string]*ring)}
+}
+
+// Record keeps the values of the rows of the view collected at the given time
+func (h *History) Record(view string, rows []ps_table.RowValues, collected time.Time) {
+	r := h.views[view]
+	if r == nil {
+		r = new(ring)
+		h.views[view] = r
+	}
+
+	values := make(map[string]map[string]uint64, len(rows))
+	for _, row := range rows {
+		values[row.Name] = row.Values
+	}
+	r.add(snapshot{collected: collected, values: values})
+}
+
+// Metric returns the name of the value shown for a row: the latency if
+// there is one, otherwise the number of events, otherwise the first
+// value by name
+func Metric(values map[string]uint64) string {
+	for _, name := range []string{"sum_timer_wait", "count_star"} {
+		if _, ok := values[name]; ok {
+			return name
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return ""
+	}
+
+	return names[0]
+}
+
+// Series holds the change of a row's metric in each of the recent intervals
+type Series struct {
+	View    string
+	Name    string        // name of the row
+	Metric  string        // name of the value
+	Changes []uint64      // change in each interval, oldest first
+	Period  time.Duration // time covered by the changes
+}
+
+// Series returns the changes of the metric of a row of the view. A row
+// missing from a collection, or whose value went down as the statistics
+// were reset, counts as no change in that interval.
+func (h *History) Series(view, name string) Series {
+	series := Series{View: view, Name: name}
+	r := h.views[view]
+	if r == nil {
+		return series
+	}
+	snapshots := r.ordered()
+	if len(snapshots) == 0 {
+		return series
+	}
+	series.Metric = Metric(snapshots[len(snapshots)-1].values[name])
+	if series.Metric == "" {
+		return series
+	}
+
+	for i := 1; i < len(snapshots); i++ {
+		var change uint64
+		before, okBefore := snapshots[i-1].values[name][series.Metric]
+		after, okAfter := snapshots[i].values[name][series.Metric]
+		if okBefore && okAfter && after > before {
+			change = after - before
+		}
+		series.Changes = append(series.Changes, change)
+	}
+	series.Period = snapshots[len(snapshots)-1].collected.Sub(snapshots[0].collected)
+
+	return series
+}
@@ -0,0 +1,62 @@
+package row_history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestMetric(t *testing.T) {
+	tests := []struct {
+		values map[string]uint64
+		metric string
+	}{
+		{map[string]uint64{"count_star": 1, "sum_timer_wait": 2}, "sum_timer_wait"},
+		{map[string]uint64{"count_star": 1, "bytes": 2}, "count_star"},
+		{map[string]uint64{"misses": 1, "hits": 2}, "hits"},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if metric := Metric(test.values); metric != test.metric {
+			t.Errorf("Metric(%v) expected %q but got %q", test.values, test.metric, metric)
+		}
+	}
+}
+
+func TestSeries(t *testing.T) {
+	h := NewHistory()
+	start := time.Now()
+	values := []uint64{100, 150, 40, 90} // the third is after a reset
+	for i, value := range values {
+		h.Record("test", []ps_table.RowValues{{Name: "a", Values: map[string]uint64{"count_star": value}}}, start.Add(time.Duration(i)*time.Second))
+	}
+	h.Record("test", nil, start.Add(4*time.Second)) // row missing
+
+	series := h.Series("test", "a")
+	if series.Metric != "" {
+		t.Errorf("Series() of a row missing from the last collection expected no metric but got %q", series.Metric)
+	}
+
+	h.Record("test", []ps_table.RowValues{{Name: "a", Values: map[string]uint64{"count_star": 95}}}, start.Add(5*time.Second))
+	series = h.Series("test", "a")
+	if expected := []uint64{50, 0, 50, 0, 0}; !reflect.DeepEqual(series.Changes, expected) || series.Metric != "count_star" || series.Period != 5*time.Second {
+		t.Errorf("Series() expected changes %v over 5s but got %+v", expected, series)
+	}
+}
+
+func TestIntervals(t *testing.T) {
+	h := NewHistory()
+	for i := 0; i < 2*Intervals; i++ {
+		h.Record("test", []ps_table.RowValues{{Name: "a", Values: map[string]uint64{"count_star": uint64(i * i)}}}, time.Now())
+	}
+
+	series := h.Series("test", "a")
+	if len(series.Changes) != Intervals {
+		t.Fatalf("Series() expected %d changes but got %d", Intervals, len(series.Changes))
+	}
+	if last := series.Changes[Intervals-1]; last != uint64(2*(2*Intervals-1)-1) {
+		t.Errorf("Series() expected the last change to be the newest but got %d", last)
+	}
+}
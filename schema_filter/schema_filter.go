@@ -0,0 +1,135 @@
+// Package schema_filter restricts the table based views to the tables
+// of some schemas, or hides those of others, when selecting from the
+// server. This reduces the rows returned on servers with many schemas.
+package schema_filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Filter holds the schemas to show and those to ignore, and the patterns
+// of the names of the tables to show and those to ignore
+type Filter struct {
+	include  []string
+	exclude  []string
+	tables   Patterns
+	excluded Patterns
+}
+
+// Patterns holds regular expressions matching the schema.table names of
+// tables. It may be given as a flag more than once.
+type Patterns []string
+
+// String returns the patterns as a comma separated list
+func (p *Patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+// Set adds a pattern, checking it is a valid regular expression
+func (p *Patterns) Set(pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid table pattern %q: %v", pattern, err)
+	}
+	*p = append(*p, pattern)
+
+	return nil
+}
+
+// NewFilter returns a filter given comma separated lists of the schemas
+// to show and those to ignore, and the patterns of the schema.table
+// names of the tables to show and those to ignore, or nil if all are
+// empty. A pattern has to match the whole name so sbtest\..* shows the
+// tables of sbtest.
+func NewFilter(include, exclude string, tables, excluded Patterns) *Filter {
+	f := &Filter{
+		include:  split(include),
+		exclude:  split(exclude),
+		tables:   tables,
+		excluded: excluded,
+	}
+	if len(f.include) == 0 && len(f.exclude) == 0 && len(f.tables) == 0 && len(f.excluded) == 0 {
+		return nil
+	}
+
+	return f
+}
+
+// split returns the non-empty names in a comma separated list
+func split(list string) []string {
+	var names []string
+
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// condition returns the condition restricting the schema and table
+// columns and its arguments
+func (f *Filter) condition(schema, table string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if f == nil {
+		return "", nil
+	}
+	add := func(operator string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+		conditions = append(conditions, schema+" "+operator+" ("+placeholders+")")
+		for _, name := range names {
+			args = append(args, name)
+		}
+	}
+	add("IN", f.include)
+	add("NOT IN", f.exclude)
+
+	// anchored with a group rather than (?:) which MySQL 5.7 doesn't know
+	name := "CONCAT(" + schema + ", '.', " + table + ")"
+	var matches []string
+	for _, pattern := range f.tables {
+		matches = append(matches, name+" REGEXP ?")
+		args = append(args, "^("+pattern+")$")
+	}
+	if len(matches) == 1 {
+		conditions = append(conditions, matches[0])
+	} else if len(matches) > 1 {
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+	for _, pattern := range f.excluded {
+		conditions = append(conditions, name+" NOT REGEXP ?")
+		args = append(args, "^("+pattern+")$")
+	}
+
+	return strings.Join(conditions, "\nAND\t"), args
+}
+
+// And returns the condition to append to a query which already has a
+// WHERE clause, and the arguments to pass with it. It is empty if there
+// is nothing to filter.
+func (f *Filter) And(schema, table string) (string, []interface{}) {
+	condition, args := f.condition(schema, table)
+	if condition == "" {
+		return "", nil
+	}
+
+	return "\nAND\t" + condition, args
+}
+
+// Where returns a WHERE clause to append to a query without one, and the
+// arguments to pass with it. It is empty if there is nothing to filter.
+func (f *Filter) Where(schema, table string) (string, []interface{}) {
+	condition, args := f.condition(schema, table)
+	if condition == "" {
+		return "", nil
+	}
+
+	return "\nWHERE\t" + condition, args
+}
@@ -0,0 +1,58 @@
+package schema_filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewFilter(t *testing.T) {
+	if f := NewFilter("", " , ", nil, nil); f != nil {
+		t.Errorf("NewFilter() of empty lists = %+v, want nil", f)
+	}
+
+	var f *Filter
+	if condition, args := f.And("OBJECT_SCHEMA", "OBJECT_NAME"); condition != "" || args != nil {
+		t.Errorf("nil Filter.And() = %q, %v, want no condition", condition, args)
+	}
+}
+
+func TestCondition(t *testing.T) {
+	const name = "CONCAT(OBJECT_SCHEMA, '.', OBJECT_NAME)"
+	tests := []struct {
+		include, exclude string
+		tables, excluded Patterns
+		where            string
+		args             []interface{}
+	}{
+		{"db1", "", nil, nil, "\nWHERE\tOBJECT_SCHEMA IN (?)", []interface{}{"db1"}},
+		{"db1, db2", "", nil, nil, "\nWHERE\tOBJECT_SCHEMA IN (?, ?)", []interface{}{"db1", "db2"}},
+		{"", "mysql,sys", nil, nil, "\nWHERE\tOBJECT_SCHEMA NOT IN (?, ?)", []interface{}{"mysql", "sys"}},
+		{"db1", "db2", nil, nil, "\nWHERE\tOBJECT_SCHEMA IN (?)\nAND\tOBJECT_SCHEMA NOT IN (?)", []interface{}{"db1", "db2"}},
+		{"", "", Patterns{`sbtest\..*`}, nil, "\nWHERE\t" + name + " REGEXP ?", []interface{}{`^(sbtest\..*)$`}},
+		{"", "", Patterns{"a.*", "b.*"}, Patterns{".*_archive"},
+			"\nWHERE\t(" + name + " REGEXP ? OR " + name + " REGEXP ?)\nAND\t" + name + " NOT REGEXP ?",
+			[]interface{}{"^(a.*)$", "^(b.*)$", "^(.*_archive)$"}},
+		{"db1", "", nil, Patterns{".*_archive"}, "\nWHERE\tOBJECT_SCHEMA IN (?)\nAND\t" + name + " NOT REGEXP ?", []interface{}{"db1", "^(.*_archive)$"}},
+	}
+
+	for _, test := range tests {
+		where, args := NewFilter(test.include, test.exclude, test.tables, test.excluded).Where("OBJECT_SCHEMA", "OBJECT_NAME")
+		if where != test.where || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("NewFilter(%q, %q, %q, %q).Where() = %q, %v, want %q, %v", test.include, test.exclude, test.tables, test.excluded, where, args, test.where, test.args)
+		}
+	}
+}
+
+func TestPatternsSet(t *testing.T) {
+	var p Patterns
+
+	if err := p.Set("sbtest.*"); err != nil {
+		t.Errorf("Patterns.Set(%q) gave error %v", "sbtest.*", err)
+	}
+	if err := p.Set("(unclosed"); err == nil {
+		t.Errorf("Patterns.Set(%q) gave no error", "(unclosed")
+	}
+	if p.String() != "sbtest.*" {
+		t.Errorf("Patterns.String() = %q, want %q", p.String(), "sbtest.*")
+	}
+}
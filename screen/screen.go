@@ -1,11 +1,19 @@
 // Package screen configures the screen, basically remembering the size
 // and foreground and background colours.
+//
+// Text is drawn into a buffer holding the whole screen and only shown
+// by Flush, which writes just the cells which have changed since the
+// last flush. A redraw therefore never shows a half drawn or cleared
+// screen, and only the values which changed are sent to the terminal,
+// which matters on slow terminals and over ssh. The methods may be
+// called from several goroutines.
 package screen
 
 import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 
 	"github.com/nsf/termbox-go"
 
@@ -14,40 +22,92 @@ import (
 
 // TermboxScreen is a wrapper around termbox
 type TermboxScreen struct {
+	mu            sync.Mutex // termbox is not safe to use from several goroutines
 	width, height int
 	fg, bg        termbox.Attribute
+	next          []termbox.Cell // what the screen should show, a row at a time
+	shown         []termbox.Cell // what was shown by the last flush
 }
 
-// BoldPrintAt displays bold text at the location specified, but
-// does not try to display outside of the screen boundary.
-func (s *TermboxScreen) BoldPrintAt(x int, y int, text string) {
+// blank is an empty cell
+var blank = termbox.Cell{Ch: ' ', Fg: termbox.ColorDefault, Bg: termbox.ColorDefault}
+
+// printAt draws the characters at the location specified with the
+// given attributes, but does not try to draw outside of the screen
+// boundary. The lock must be held.
+func (s *TermboxScreen) printAt(x int, y int, text string, fg termbox.Attribute) {
+	if y < 0 || y >= s.height {
+		return
+	}
 	offset := 0
 	for c := range text {
-		if (x + offset) < s.width {
-			termbox.SetCell(x+offset, y, rune(text[c]), s.fg|termbox.AttrBold, s.bg)
-			offset++
+		if x+offset >= 0 && x+offset < s.width {
+			s.next[y*s.width+x+offset] = termbox.Cell{Ch: rune(text[c]), Fg: fg, Bg: s.bg}
 		}
+		offset++
 	}
-	s.Flush()
 }
 
-// Clear clears the screen
+// BoldPrintAt displays bold text at the location specified, but
+// does not try to display outside of the screen boundary.
+func (s *TermboxScreen) BoldPrintAt(x int, y int, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.printAt(x, y, text, s.fg|termbox.AttrBold)
+}
+
+// ReversePrintAt displays text in reverse video at the location
+// specified, but does not try to display outside of the screen boundary.
+func (s *TermboxScreen) ReversePrintAt(x int, y int, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.printAt(x, y, text, s.fg|termbox.AttrReverse)
+}
+
+// Clear clears the screen, which is shown by the next Flush
 func (s *TermboxScreen) Clear() {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.next {
+		s.next[i] = blank
+	}
 }
 
 // Close closes the screen prior to shutdown
 func (s *TermboxScreen) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	termbox.Close()
 }
 
-// Flush pushes out the pending changes to the screen
+// Flush shows what has been drawn since the last flush, writing only
+// the cells which have changed
 func (s *TermboxScreen) Flush() {
-	termbox.Flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for i := range s.next {
+		if s.next[i] != s.shown[i] {
+			termbox.SetCell(i%s.width, i/s.width, s.next[i].Ch, s.next[i].Fg, s.next[i].Bg)
+			s.shown[i] = s.next[i]
+			changed = true
+		}
+	}
+	if changed {
+		termbox.Flush()
+	}
 }
 
 // Height returns the current height of the screen
 func (s *TermboxScreen) Height() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.height
 }
 
@@ -60,7 +120,6 @@ func (s *TermboxScreen) Initialise() {
 		os.Exit(1)
 	}
 
-	s.Clear()
 	s.fg = termbox.ColorDefault
 	s.bg = termbox.ColorDefault
 
@@ -69,44 +128,60 @@ func (s *TermboxScreen) Initialise() {
 
 // PrintAt prints the characters at the requested location while they fit in the screen
 func (s *TermboxScreen) PrintAt(x int, y int, text string) {
-	offset := 0
-	for c := range text {
-		if (x + offset) < s.width {
-			termbox.SetCell(x+offset, y, rune(text[c]), s.fg, s.bg)
-			offset++
-		}
-	}
-	s.Flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.printAt(x, y, text, s.fg)
 }
 
 // ClearLine clears the line with spaces to the right hand side of the screen
 func (s *TermboxScreen) ClearLine(x int, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if y < 0 || y >= s.height {
+		return
+	}
 	for i := x; i < s.width; i++ {
-		termbox.SetCell(i, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		s.next[y*s.width+i] = blank
 	}
-	s.Flush()
 }
 
-// SetSize records the size of the screen
+// SetSize records the size of the screen. What was drawn is kept where
+// it still fits and the whole screen is written by the next Flush, as
+// the terminal may have lost what it showed when it was resized.
 func (s *TermboxScreen) SetSize(width, height int) {
-	// if we get bigger then clear out the bottom line
-	for x := 0; x < s.width; x++ {
-		termbox.SetCell(x, s.height-1, ' ', s.fg, s.bg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := make([]termbox.Cell, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			next[y*width+x] = blank
+			if x < s.width && y < s.height {
+				next[y*width+x] = s.next[y*s.width+x]
+			}
+		}
 	}
-	s.Flush()
+	s.next = next
+	s.shown = make([]termbox.Cell, width*height) // differs from every cell drawn
 
 	s.width = width
 	s.height = height
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 }
 
 // Size returns the current (width, height) of the screen
 func (s *TermboxScreen) Size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return s.width, s.height
 }
 
 // TermBoxChan creates a channel for termbox.Events and run a poller to send
 // these events to the channel.  Return the channel to the caller..
-func (s TermboxScreen) TermBoxChan() chan termbox.Event {
+func (s *TermboxScreen) TermBoxChan() chan termbox.Event {
 	termboxChan := make(chan termbox.Event)
 	go func() {
 		for {
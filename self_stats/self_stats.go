@@ -0,0 +1,128 @@
+// Package self_stats records how ps-top itself is performing: how long
+// each collector takes, the queries which fail, the display updates
+// which are missed because collecting took too long and the memory used.
+// This helps when ps-top runs for a long time.
+package self_stats
+
+import (
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Collector holds the statistics of the collections of one view
+type Collector struct {
+	Name        string
+	Collections uint64
+	Errors      uint64 // collections which failed
+	LastError   string // the error of the last failed collection
+	Failing     bool   // the last collection failed
+	Rows        int    // the rows to show from the last collection
+	Last        time.Duration
+	Max         time.Duration
+	Total       time.Duration
+}
+
+// Average returns the average time taken to collect
+func (c Collector) Average() time.Duration {
+	if c.Collections == 0 {
+		return 0
+	}
+	return c.Total / time.Duration(c.Collections)
+}
+
+// Status returns "ok" or "FAILING" if the last collection failed
+func (c Collector) Status() string {
+	if c.Failing {
+		return "FAILING"
+	}
+	return "ok"
+}
+
+// Memory holds the memory used by ps-top
+type Memory struct {
+	HeapAlloc  uint64 // bytes of allocated heap objects
+	Sys        uint64 // bytes obtained from the OS
+	NumGC      uint32 // completed garbage collections
+	Goroutines int
+}
+
+// Stats holds the statistics of ps-top
+type Stats struct {
+	started    time.Time
+	collectors map[string]*Collector
+	dropped    uint64
+}
+
+// NewStats returns a pointer to an empty Stats
+func NewStats() *Stats {
+	return &Stats{
+		started:    time.Now(),
+		collectors: make(map[string]*Collector),
+	}
+}
+
+// Started returns when the statistics started
+func (s *Stats) Started() time.Time {
+	return s.started
+}
+
+// Collected records a collection of the named collector, the time it
+// took, the rows collected and its error, if any
+func (s *Stats) Collected(name string, took time.Duration, rows int, err error) {
+	c, found := s.collectors[name]
+	if !found {
+		c = &Collector{Name: name}
+		s.collectors[name] = c
+	}
+	c.Collections++
+	c.Last = took
+	c.Total += took
+	if took > c.Max {
+		c.Max = took
+	}
+	c.Rows = rows
+	c.Failing = err != nil
+	if err != nil {
+		c.Errors++
+		c.LastError = err.Error()
+	}
+}
+
+// Dropped records display updates which were missed
+func (s *Stats) Dropped(frames int) {
+	if frames > 0 {
+		s.dropped += uint64(frames)
+	}
+}
+
+// DroppedFrames returns the number of display updates which were missed
+func (s *Stats) DroppedFrames() uint64 {
+	return s.dropped
+}
+
+// Collectors returns the statistics of each collector ordered by name
+func (s *Stats) Collectors() []Collector {
+	collectors := make([]Collector, 0, len(s.collectors))
+
+	for _, c := range s.collectors {
+		collectors = append(collectors, *c)
+	}
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].Name < collectors[j].Name })
+
+	return collectors
+}
+
+// Memory returns the memory currently used by ps-top
+func (s *Stats) Memory() Memory {
+	var m runtime.MemStats
+
+	runtime.ReadMemStats(&m)
+
+	return Memory{
+		HeapAlloc:  m.HeapAlloc,
+		Sys:        m.Sys,
+		NumGC:      m.NumGC,
+		Goroutines: runtime.NumGoroutine(),
+	}
+}
@@ -0,0 +1,34 @@
+package self_stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCollected(t *testing.T) {
+	s := NewStats()
+	s.Collected("table_io_latency", 30*time.Millisecond, 12, nil)
+	s.Collected("table_io_latency", 10*time.Millisecond, 0, errors.New("timeout"))
+	s.Collected("file_io_latency", 5*time.Millisecond, 7, nil)
+	s.Dropped(2)
+	s.Dropped(0)
+
+	collectors := s.Collectors()
+	if len(collectors) != 2 || collectors[0].Name != "file_io_latency" {
+		t.Fatalf("Collectors() = %+v, want file_io_latency and table_io_latency", collectors)
+	}
+	c := collectors[1]
+	if c.Collections != 2 || c.Errors != 1 || c.LastError != "timeout" || !c.Failing || c.Status() != "FAILING" {
+		t.Errorf("Collectors() table_io_latency = %+v, want 2 collections and 1 error", c)
+	}
+	if c.Last != 10*time.Millisecond || c.Max != 30*time.Millisecond || c.Average() != 20*time.Millisecond {
+		t.Errorf("Collectors() table_io_latency last/max/average = %v/%v/%v, want 10ms/30ms/20ms", c.Last, c.Max, c.Average())
+	}
+	if f := collectors[0]; f.Failing || f.Rows != 7 || f.Status() != "ok" {
+		t.Errorf("Collectors() file_io_latency = %+v, want 7 rows and ok", f)
+	}
+	if s.DroppedFrames() != 2 {
+		t.Errorf("DroppedFrames() = %d, want 2", s.DroppedFrames())
+	}
+}
@@ -0,0 +1,143 @@
+// Package server_info collects the server's key configuration, such as
+// the buffer pool and redo log sizes, the sync settings and the
+// replication role, to give context to the numbers in the other views.
+// It is collected when asked for as it rarely changes.
+package server_info
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Setting is a named value shown on the info screen
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Info holds the server's configuration when it was collected
+type Info struct {
+	Collected time.Time
+	Settings  []Setting
+}
+
+// Collect returns the server's current configuration
+func Collect(dbh *sql.DB) Info {
+	variables := global.NewVariables(dbh)
+	pages, err := global.NewStatus(dbh).Values("Innodb_buffer_pool_pages")
+	if err != nil {
+		logger.Println("server_info.Collect() unable to collect the buffer pool pages:", err)
+	}
+
+	return Info{
+		Collected: time.Now(),
+		Settings:  settings(variables.Get, pages, source(dbh)),
+	}
+}
+
+// source returns the host and port this server replicates from, empty
+// if it is not a replica
+func source(dbh *sql.DB) string {
+	var host string
+	var port int
+
+	err := dbh.QueryRow("SELECT HOST, PORT FROM performance_schema.replication_connection_configuration LIMIT 1").Scan(&host, &port)
+	switch {
+	case err == sql.ErrNoRows:
+		return ""
+	case err != nil:
+		logger.Println("server_info.source() unable to find the replication source:", err)
+		return "unknown"
+	}
+
+	return host + ":" + strconv.Itoa(port)
+}
+
+// formatSize formats a size in bytes in the largest unit it is a whole
+// number of, as in my.cnf
+func formatSize(value string) string {
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || size == 0 {
+		return value
+	}
+	for _, unit := range []string{"", "K", "M", "G", "T"} {
+		if size%1024 != 0 || unit == "T" {
+			return strconv.FormatUint(size, 10) + unit
+		}
+		size /= 1024
+	}
+
+	return value
+}
+
+// percent returns part as a percentage of total, or empty if total is 0
+func percent(part, total uint64) string {
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(total))
+}
+
+// redoLogSize returns the size of the redo log. MySQL 8.0.30 and later
+// size it with innodb_redo_log_capacity, earlier versions by the size and
+// number of the log files.
+func redoLogSize(get func(string) string) string {
+	if capacity := get("innodb_redo_log_capacity"); capacity != "" {
+		return formatSize(capacity)
+	}
+	size, err1 := strconv.ParseUint(get("innodb_log_file_size"), 10, 64)
+	files, err2 := strconv.ParseUint(get("innodb_log_files_in_group"), 10, 64)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s (%d x %s)", formatSize(strconv.FormatUint(size*files, 10)), files, formatSize(strconv.FormatUint(size, 10)))
+}
+
+// role describes the replication role given the replication source, if any
+func role(get func(string) string, source string) string {
+	role := "source (not a replica)"
+	if source != "" {
+		role = "replica of " + source
+	}
+	switch {
+	case get("super_read_only") == "ON":
+		role += ", super_read_only"
+	case get("read_only") == "ON":
+		role += ", read_only"
+	}
+
+	return role
+}
+
+// settings returns the settings shown given a function to get a global
+// variable, the buffer pool page status and the replication source
+func settings(get func(string) string, pages global.StatusValues, source string) []Setting {
+	usage := ""
+	if total := pages["innodb_buffer_pool_pages_total"]; total > 0 {
+		usage = fmt.Sprintf("%s with data, %s dirty",
+			percent(pages["innodb_buffer_pool_pages_data"], total),
+			percent(pages["innodb_buffer_pool_pages_dirty"], total))
+	}
+
+	return []Setting{
+		{"Version", get("version") + " " + get("version_comment")},
+		{"Host", get("hostname") + ":" + get("port")},
+		{"Replication", role(get, source)},
+		{"Binary log", get("log_bin") + " " + get("binlog_format") + ", gtid_mode " + get("gtid_mode")},
+		{"Buffer pool size", formatSize(get("innodb_buffer_pool_size")) + " in " + get("innodb_buffer_pool_instances") + " instance(s)"},
+		{"Buffer pool usage", usage},
+		{"Redo log size", redoLogSize(get)},
+		{"Flush log at commit", get("innodb_flush_log_at_trx_commit")},
+		{"Sync binlog", get("sync_binlog")},
+		{"Flush method", get("innodb_flush_method")},
+		{"Key buffer size", formatSize(get("key_buffer_size"))},
+		{"Max connections", get("max_connections")},
+		{"SQL mode", get("sql_mode")},
+	}
+}
@@ -0,0 +1,49 @@
+package server_info
+
+import (
+	"testing"
+)
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"134217728", "128M"},
+		{"8388608", "8M"},
+		{"1073741824", "1G"},
+		{"1000", "1000"},
+		{"1536", "1536"},
+		{"0", "0"},
+		{"lots", "lots"},
+	}
+	for _, test := range tests {
+		if got := formatSize(test.value); got != test.expected {
+			t.Errorf("formatSize(%q) expected %q but got %q", test.value, test.expected, got)
+		}
+	}
+}
+
+func TestRedoLogSize(t *testing.T) {
+	old := map[string]string{"innodb_log_file_size": "50331648", "innodb_log_files_in_group": "2"}
+	if got := redoLogSize(func(name string) string { return old[name] }); got != "96M (2 x 48M)" {
+		t.Errorf("redoLogSize() expected %q but got %q", "96M (2 x 48M)", got)
+	}
+
+	current := map[string]string{"innodb_redo_log_capacity": "104857600"}
+	if got := redoLogSize(func(name string) string { return current[name] }); got != "100M" {
+		t.Errorf("redoLogSize() expected %q but got %q", "100M", got)
+	}
+}
+
+func TestRole(t *testing.T) {
+	variables := map[string]string{"read_only": "ON"}
+	get := func(name string) string { return variables[name] }
+
+	if got := role(get, ""); got != "source (not a replica), read_only" {
+		t.Errorf("role() got %q", got)
+	}
+	if got := role(get, "db1:3306"); got != "replica of db1:3306, read_only" {
+		t.Errorf("role() got %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Package session_log records what the user does, such as changing the
+// view or resetting the statistics, with the time it was done. This
+// allows what was seen and done during an incident to be reconstructed.
+package session_log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// timeFormat is the format of the time at the start of each line
+const timeFormat = "2006-01-02 15:04:05.000"
+
+// Log is a session log. A nil Log records nothing.
+type Log struct {
+	file *os.File
+}
+
+// Open opens the session log, appending to it if it exists
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{file: file}, nil
+}
+
+// Record writes the action and its details on a line of their own
+func (l *Log) Record(action string, details ...string) {
+	if l == nil {
+		return
+	}
+	line := lib.InTimezone(time.Now()).Format(timeFormat) + " " + action
+	if len(details) > 0 {
+		line += " " + strings.Join(details, " ")
+	}
+	if _, err := fmt.Fprintln(l.file, line); err != nil {
+		logger.Println("session_log.Record() failed:", err)
+	}
+}
+
+// Close closes the session log
+func (l *Log) Close() {
+	if l == nil {
+		return
+	}
+	if err := l.file.Close(); err != nil {
+		logger.Println("session_log.Close() failed:", err)
+	}
+}
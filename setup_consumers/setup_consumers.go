@@ -0,0 +1,152 @@
+// Package setup_consumers shows which of the
+// performance_schema.setup_consumers each view needs are enabled, and
+// enables the missing ones. Disabled consumers are the most common
+// reason a view shows nothing.
+package setup_consumers
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Consumer is one row of setup_consumers
+type Consumer struct {
+	Name    string
+	Enabled bool
+}
+
+// instrumentation is needed by every view: consumers below it only
+// collect if it is enabled
+var instrumentation = []string{"global_instrumentation", "thread_instrumentation"}
+
+// needed are the consumers used by each view, in addition to those
+// above. A history consumer is only filled if the current one is
+// enabled too. events_statements_cpu (8.0.28+) times the CPU used by
+// statements.
+var needed = map[string][]string{
+	"statement_efficiency": {"statements_digest", "events_statements_cpu"},
+	"statement_stages":     {"events_statements_current", "events_statements_history_long", "events_stages_current", "events_stages_history_long"},
+	"program_latency":      {"events_statements_current", "events_statements_cpu"},
+	"ddl_progress":         {"events_stages_current"},
+}
+
+// Needed returns the names of the consumers the view needs
+func Needed(view string) []string {
+	return append(append([]string{}, instrumentation...), needed[view]...)
+}
+
+// SetupConsumers "object"
+type SetupConsumers struct {
+	dbh     *sql.DB
+	enabled []string // consumers we enabled, to be disabled on exit
+}
+
+// NewSetupConsumers returns a SetupConsumers with a handle to the database
+func NewSetupConsumers(dbh *sql.DB) *SetupConsumers {
+	return &SetupConsumers{dbh: dbh}
+}
+
+// placeholders returns the placeholders and arguments for the names
+func placeholders(names []string) (string, []interface{}) {
+	args := make([]interface{}, len(names))
+	for i := range names {
+		args[i] = names[i]
+	}
+
+	return strings.TrimSuffix(strings.Repeat("?,", len(names)), ","), args
+}
+
+// Consumers returns the consumers the view needs in the order listed
+// by Needed, with whether they are enabled. Those the server doesn't
+// have, such as events_statements_cpu before 8.0.28, are left out.
+func (sc *SetupConsumers) Consumers(view string) ([]Consumer, error) {
+	names := Needed(view)
+	in, args := placeholders(names)
+
+	rows, err := sc.dbh.Query("SELECT NAME, ENABLED FROM setup_consumers WHERE NAME IN ("+in+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enabled := make(map[string]bool)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		enabled[name] = value == "YES"
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var consumers []Consumer
+	for i := range names {
+		if value, found := enabled[names[i]]; found {
+			consumers = append(consumers, Consumer{Name: names[i], Enabled: value})
+		}
+	}
+
+	return consumers, nil
+}
+
+// Disabled returns the names of the consumers which are not enabled
+func Disabled(consumers []Consumer) []string {
+	var disabled []string
+	for i := range consumers {
+		if !consumers[i].Enabled {
+			disabled = append(disabled, consumers[i].Name)
+		}
+	}
+
+	return disabled
+}
+
+// EnableMissing enables the consumers the view needs which are
+// disabled, returning their names. They are disabled again by Restore.
+// Save them with the setup_instruments settings so they are disabled
+// even if we are killed.
+// An error is returned if we are not allowed to change setup_consumers.
+func (sc *SetupConsumers) EnableMissing(view string) ([]string, error) {
+	consumers, err := sc.Consumers(view)
+	if err != nil {
+		return nil, err
+	}
+	disabled := Disabled(consumers)
+	if len(disabled) == 0 {
+		return nil, nil
+	}
+
+	in, args := placeholders(disabled)
+	logger.Println("setup_consumers: enabling", disabled)
+	if _, err := sc.dbh.Exec("UPDATE setup_consumers SET ENABLED = 'YES' WHERE NAME IN ("+in+")", args...); err != nil {
+		return nil, fmt.Errorf("unable to enable %s: %s", strings.Join(disabled, ", "), err.Error())
+	}
+	sc.enabled = append(sc.enabled, disabled...)
+
+	return disabled, nil
+}
+
+// Enabled returns the consumers we enabled which have not been disabled
+func (sc *SetupConsumers) Enabled() []string {
+	return sc.enabled
+}
+
+// Restore disables the consumers we enabled
+func (sc *SetupConsumers) Restore() {
+	if len(sc.enabled) == 0 {
+		return
+	}
+
+	in, args := placeholders(sc.enabled)
+	logger.Println("setup_consumers: disabling", sc.enabled)
+	if _, err := sc.dbh.Exec("UPDATE setup_consumers SET ENABLED = 'NO' WHERE NAME IN ("+in+")", args...); err != nil {
+		logger.Println("setup_consumers: unable to restore the consumers:", err)
+		return
+	}
+	sc.enabled = nil
+}
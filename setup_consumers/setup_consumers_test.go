@@ -0,0 +1,31 @@
+package setup_consumers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNeeded(t *testing.T) {
+	if needed := Needed("table_io_latency"); !reflect.DeepEqual(needed, instrumentation) {
+		t.Errorf("Needed(table_io_latency) expected %v but got %v", instrumentation, needed)
+	}
+	expected := []string{"global_instrumentation", "thread_instrumentation", "statements_digest", "events_statements_cpu"}
+	if needed := Needed("statement_efficiency"); !reflect.DeepEqual(needed, expected) {
+		t.Errorf("Needed(statement_efficiency) expected %v but got %v", expected, needed)
+	}
+	if len(instrumentation) != 2 {
+		t.Errorf("Needed() changed the consumers every view needs: %v", instrumentation)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	consumers := []Consumer{
+		{Name: "global_instrumentation", Enabled: true},
+		{Name: "thread_instrumentation", Enabled: false},
+		{Name: "statements_digest", Enabled: false},
+	}
+	expected := []string{"thread_instrumentation", "statements_digest"}
+	if disabled := Disabled(consumers); !reflect.DeepEqual(disabled, expected) {
+		t.Errorf("Disabled() expected %v but got %v", expected, disabled)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/sjmudd/ps-top/logger"
 )
@@ -36,20 +37,40 @@ type SetupInstruments struct {
 	updateTried     bool
 	updateSucceeded bool
 	rows            Rows
+	saved           map[string]bool // names of the rows saved in rows
 	dbh             *sql.DB
+	server          string    // server the settings belong to
+	started         time.Time // when we started
+	stateFile       string    // file the original settings are saved in (if any)
+	stale           []state   // settings left changed by earlier runs
+	staleFiles      []string  // files of the stale settings
+	consumers       []string  // setup_consumers we enabled, saved with the settings
 }
 
+// Family is a group of instruments sharing a common name prefix
+type Family struct {
+	Name        string
+	Instruments int // number of instruments in the family
+	Enabled     int // number of those which are enabled
+	Timed       int // number of those which are timed
+}
+
+// families are the instrument families which can be toggled
+var families = []string{"wait/io/file/", "wait/synch/", "stage/", "statement/", "memory/"}
+
 // NewSetupInstruments returns a newly initialised SetupInstruments
 // structure with a handle to the database.  Better to return a
 // pointer ?
 func NewSetupInstruments(dbh *sql.DB) SetupInstruments {
-	return SetupInstruments{dbh: dbh}
+	return SetupInstruments{dbh: dbh, started: time.Now()}
 }
 
-// EnableMonitoring enables mutex and stage monitoring
+// EnableMonitoring enables mutex, stage and idle monitoring
 func (si *SetupInstruments) EnableMonitoring() {
 	si.EnableMutexMonitoring()
 	si.EnableStageMonitoring()
+	si.EnableDDLMonitoring()
+	si.EnableIdleMonitoring()
 }
 
 // EnableStageMonitoring change settings to monitor stage/sql/%
@@ -65,6 +86,19 @@ func (si *SetupInstruments) EnableStageMonitoring() {
 	logger.Println("EnableStageMonitoring finishes")
 }
 
+// EnableDDLMonitoring changes settings to monitor stage/innodb/alter%,
+// the stages of an InnoDB ALTER TABLE which report its progress
+func (si *SetupInstruments) EnableDDLMonitoring() {
+	logger.Println("EnableDDLMonitoring")
+	sqlMatch := "stage/innodb/alter%"
+	sqlSelect := "SELECT NAME, ENABLED, TIMED FROM setup_instruments WHERE NAME LIKE '" + sqlMatch + "' AND 'YES' NOT IN (ENABLED,TIMED)"
+	collecting := "Collecting setup_instruments stage/innodb/alter configuration settings"
+	updating := "Updating setup_instruments configuration for: stage/innodb/alter"
+
+	si.Configure(sqlSelect, collecting, updating)
+	logger.Println("EnableDDLMonitoring finishes")
+}
+
 // EnableMutexMonitoring changes settings to monitor wait/synch/mutex/%
 func (si *SetupInstruments) EnableMutexMonitoring() {
 	logger.Println("EnableMutexMonitoring")
@@ -77,6 +111,18 @@ func (si *SetupInstruments) EnableMutexMonitoring() {
 	logger.Println("EnableMutexMonitoring finishes")
 }
 
+// EnableIdleMonitoring changes settings to time the idle instrument,
+// the wait of a connection for its client
+func (si *SetupInstruments) EnableIdleMonitoring() {
+	logger.Println("EnableIdleMonitoring")
+	sqlSelect := "SELECT NAME, ENABLED, TIMED FROM setup_instruments WHERE NAME = 'idle' AND 'YES' NOT IN (ENABLED,TIMED)"
+	collecting := "Collecting setup_instruments idle configuration settings"
+	updating := "Updating setup_instruments configuration for: idle"
+
+	si.Configure(sqlSelect, collecting, updating)
+	logger.Println("EnableIdleMonitoring finishes")
+}
+
 // return true if the error is not in the expected list
 func errorInExpectedList(actualError string, expectedErrors []string) bool {
 	logger.Println("checking if", actualError, "is in", expectedErrors)
@@ -174,10 +220,97 @@ func (si *SetupInstruments) Configure(sqlSelect string, collecting, updating str
 			logger.Println(count, "rows changed in p_s.setup_instruments")
 		}
 		stmt.Close()
+		si.saveState()
 	}
 	logger.Println("Configure() returns updateTried", si.updateTried, ", updateSucceeded", si.updateSucceeded)
 }
 
+// Families returns the instrument families with the number of
+// instruments in each and how many of those are enabled and timed.
+func (si *SetupInstruments) Families() []Family {
+	const familySQL = "SELECT COUNT(*), COALESCE(SUM(ENABLED = 'YES'),0), COALESCE(SUM(TIMED = 'YES'),0) FROM setup_instruments WHERE NAME LIKE ?"
+
+	result := make([]Family, len(families))
+	for i := range families {
+		result[i].Name = families[i]
+		if err := si.dbh.QueryRow(familySQL, families[i]+"%").Scan(
+			&result[i].Instruments,
+			&result[i].Enabled,
+			&result[i].Timed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	return result
+}
+
+// ToggleFamily disables the given family (numbered from 1) if all its
+// instruments are enabled and enables it otherwise. The original
+// settings are saved so they are restored on exit. An error is returned
+// if we are not allowed to change setup_instruments.
+func (si *SetupInstruments) ToggleFamily(number int) error {
+	if number < 1 || number > len(families) {
+		return fmt.Errorf("there is no instrument family %d", number)
+	}
+	family := si.Families()[number-1]
+	logger.Println("ToggleFamily(", family.Name, ")")
+
+	value := "YES"
+	if family.Instruments > 0 && family.Enabled == family.Instruments {
+		value = "NO"
+	}
+
+	if err := si.saveFamily(family.Name); err != nil {
+		return err
+	}
+
+	const updateSQL = "UPDATE setup_instruments SET ENABLED = ?, TIMED = ? WHERE NAME LIKE ?"
+	logger.Println("dbh.Exec", updateSQL, value, value, family.Name+"%")
+	if _, err := si.dbh.Exec(updateSQL, value, value, family.Name+"%"); err != nil {
+		if errorInExpectedList(err.Error(), ExpectedUpdateErrors) {
+			return fmt.Errorf("unable to change %s: %s", family.Name, err.Error())
+		}
+		log.Fatal(err)
+	}
+	si.updateTried = true
+	si.updateSucceeded = true
+	si.saveState()
+
+	return nil
+}
+
+// saveFamily saves the settings of the instruments in the family which
+// have not already been saved, so we keep their original values.
+func (si *SetupInstruments) saveFamily(name string) error {
+	const selectSQL = "SELECT NAME, ENABLED, TIMED FROM setup_instruments WHERE NAME LIKE ?"
+
+	if si.saved == nil {
+		si.saved = make(map[string]bool)
+		for i := range si.rows {
+			si.saved[si.rows[i].name] = true
+		}
+	}
+
+	rows, err := si.dbh.Query(selectSQL, name+"%")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		if err := rows.Scan(&r.name, &r.enabled, &r.timed); err != nil {
+			log.Fatal(err)
+		}
+		if !si.saved[r.name] {
+			si.rows = append(si.rows, r)
+			si.saved[r.name] = true
+		}
+	}
+
+	return rows.Err()
+}
+
 // RestoreConfiguration restores setup_instruments rows to their previous settings (if changed previously).
 func (si *SetupInstruments) RestoreConfiguration() {
 	logger.Println("RestoreConfiguration()")
@@ -206,4 +339,7 @@ func (si *SetupInstruments) RestoreConfiguration() {
 	logger.Println("stmt.Close()")
 	stmt.Close()
 	logger.Println(count, "rows changed in p_s.setup_instruments")
+	// nothing is left to restore, though the consumers may still be
+	si.updateSucceeded = false
+	si.saveState()
 }
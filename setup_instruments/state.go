@@ -0,0 +1,272 @@
+package setup_instruments
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// state is written to a file while we have changed setup_instruments
+// or enabled setup_consumers so the original settings can be restored
+// if we don't get the chance
+type state struct {
+	Pid         int               `json:"pid"`
+	Started     time.Time         `json:"started"`
+	Server      string            `json:"server"`
+	Instruments []savedInstrument `json:"instruments"`
+	Consumers   []string          `json:"consumers,omitempty"` // consumers we enabled
+}
+
+// savedInstrument is the original setting of an instrument we changed
+type savedInstrument struct {
+	Name    string `json:"name"`
+	Enabled string `json:"enabled"`
+	Timed   string `json:"timed"`
+}
+
+// characters not used in the name of the state file
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// stateDir returns the directory the state files are kept in,
+// ~/.cache/ps-top or the equivalent, creating it if needed. Only the
+// user may use it so no one else can plant a state file or a symlink
+// in it.
+func stateDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, "ps-top")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || !ownedByUser(info) {
+		return "", fmt.Errorf("%s is not a directory belonging to this user", dir)
+	}
+	if info.Mode().Perm() != 0700 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// ownedByUser returns true if the file belongs to the user running ps-top
+func ownedByUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// statePrefix returns the start of the names of the state files for the server
+func statePrefix(dir, server string) string {
+	return filepath.Join(dir, "setup_instruments-"+unsafeName.ReplaceAllString(server, "_")+"-")
+}
+
+// running returns true if the process is still running
+func running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+
+	return err == nil || err == syscall.EPERM
+}
+
+// SetServer records the server we are connected to so the original
+// settings are saved to a file while they are changed, and looks for
+// the files left by earlier runs against the same server which were
+// killed before restoring theirs.
+func (si *SetupInstruments) SetServer(server string) {
+	si.server = server
+	dir, err := stateDir()
+	if err != nil {
+		logger.Println("SetServer(): the original settings won't be saved:", err)
+		return
+	}
+	prefix := statePrefix(dir, server)
+	si.stateFile = fmt.Sprintf("%s%d.json", prefix, os.Getpid())
+
+	files, err := filepath.Glob(prefix + "*.json")
+	if err != nil {
+		logger.Println("SetServer(): unable to look for state files:", err)
+		return
+	}
+	for _, file := range files {
+		if file == si.stateFile {
+			continue
+		}
+		if info, err := os.Lstat(file); err != nil || !info.Mode().IsRegular() || !ownedByUser(info) {
+			logger.Println("SetServer(): ignoring", file, "which is not a file belonging to this user")
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			logger.Println("SetServer(): unable to read", file, ":", err)
+			continue
+		}
+		var s state
+		if err := json.Unmarshal(content, &s); err != nil {
+			logger.Println("SetServer(): ignoring", file, ":", err)
+			continue
+		}
+		if running(s.Pid) {
+			logger.Println("SetServer():", file, "belongs to running process", s.Pid)
+			continue
+		}
+		logger.Println("SetServer(): found stale state file", file)
+		si.stale = append(si.stale, s)
+		si.staleFiles = append(si.staleFiles, file)
+	}
+}
+
+// Stale returns a description of the settings left changed by earlier
+// runs which did not restore them, or an empty string if there are none
+func (si *SetupInstruments) Stale() string {
+	if len(si.stale) == 0 {
+		return ""
+	}
+	count, consumers := 0, 0
+	for i := range si.stale {
+		count += len(si.stale[i].Instruments)
+		consumers += len(si.stale[i].Consumers)
+	}
+
+	return fmt.Sprintf("A previous run (pid %d, started %s) left %d setup_instruments row(s) changed and %d setup_consumers enabled",
+		si.stale[0].Pid, lib.InTimezone(si.stale[0].Started).Format("2006-01-02 15:04:05"), count, consumers)
+}
+
+// SetConsumers records the setup_consumers we have enabled so they are
+// saved to the state file with the original setup_instruments settings
+func (si *SetupInstruments) SetConsumers(consumers []string) {
+	si.consumers = append([]string{}, consumers...)
+	si.saveState()
+}
+
+// enabledByUs returns true if we have enabled the consumer
+func (si *SetupInstruments) enabledByUs(consumer string) bool {
+	for i := range si.consumers {
+		if si.consumers[i] == consumer {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreStale restores the settings left changed by earlier runs and
+// removes their files. Any of those instruments we have changed since
+// will be restored to the same original settings on exit, and the
+// consumers we have enabled since are left for us to disable.
+func (si *SetupInstruments) RestoreStale() error {
+	const updateSQL = "UPDATE setup_instruments SET ENABLED = ?, TIMED = ? WHERE NAME = ?"
+	const consumerSQL = "UPDATE setup_consumers SET ENABLED = 'NO' WHERE NAME = ?"
+
+	for _, s := range si.stale {
+		for _, instrument := range s.Instruments {
+			logger.Println("dbh.Exec", updateSQL, instrument.Enabled, instrument.Timed, instrument.Name)
+			if _, err := si.dbh.Exec(updateSQL, instrument.Enabled, instrument.Timed, instrument.Name); err != nil {
+				return fmt.Errorf("unable to restore %s: %s", instrument.Name, err.Error())
+			}
+			for i := range si.rows {
+				if si.rows[i].name == instrument.Name {
+					si.rows[i].enabled, si.rows[i].timed = instrument.Enabled, instrument.Timed
+				}
+			}
+		}
+		for _, consumer := range s.Consumers {
+			if si.enabledByUs(consumer) {
+				continue
+			}
+			logger.Println("dbh.Exec", consumerSQL, consumer)
+			if _, err := si.dbh.Exec(consumerSQL, consumer); err != nil {
+				return fmt.Errorf("unable to disable %s: %s", consumer, err.Error())
+			}
+		}
+	}
+	for _, file := range si.staleFiles {
+		if err := os.Remove(file); err != nil {
+			logger.Println("RestoreStale(): unable to remove", file, ":", err)
+		}
+	}
+	si.stale, si.staleFiles = nil, nil
+	si.saveState()
+
+	return nil
+}
+
+// saveState writes the original settings of the rows we have changed
+// and the consumers we have enabled to the state file (if there is
+// one), removing it if there is nothing left to restore
+func (si *SetupInstruments) saveState() {
+	if si.stateFile == "" {
+		return
+	}
+	if !si.updateSucceeded && len(si.consumers) == 0 {
+		si.removeState()
+		return
+	}
+
+	s := state{Pid: os.Getpid(), Started: si.started, Server: si.server, Instruments: make([]savedInstrument, 0, len(si.rows)), Consumers: si.consumers}
+	if si.updateSucceeded {
+		for i := range si.rows {
+			s.Instruments = append(s.Instruments, savedInstrument{Name: si.rows[i].name, Enabled: si.rows[i].enabled, Timed: si.rows[i].timed})
+		}
+	}
+	content, err := json.Marshal(s)
+	if err != nil {
+		logger.Println("saveState(): unable to encode the state:", err)
+		return
+	}
+	if err := writeFile(si.stateFile, content); err != nil {
+		logger.Println("saveState(): unable to write", si.stateFile, ":", err)
+	}
+}
+
+// writeFile replaces the file with the content, writing it to a new
+// file (only the user can read) which is renamed so a symlink is never
+// followed and the file is never seen half written
+func writeFile(path string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".state-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return nil
+}
+
+// removeState removes the state file once the settings are restored
+func (si *SetupInstruments) removeState() {
+	if si.stateFile == "" {
+		return
+	}
+	if err := os.Remove(si.stateFile); err != nil && !os.IsNotExist(err) {
+		logger.Println("removeState(): unable to remove", si.stateFile, ":", err)
+	}
+}
@@ -0,0 +1,100 @@
+package setup_instruments
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("XDG_CACHE_HOME", os.Getenv("XDG_CACHE_HOME"))
+	os.Setenv("HOME", dir)
+	os.Setenv("XDG_CACHE_HOME", dir)
+	states, err := stateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings of a run which was killed: its process has gone
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip("unable to run true:", err)
+	}
+	killed := state{Pid: cmd.Process.Pid, Server: "db1:3306", Instruments: []savedInstrument{{"stage/sql/init", "NO", "NO"}}}
+	content, err := json.Marshal(killed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(statePrefix(states, "db1:3306")+"1.json", content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// a symlink is not a state file we wrote so it is ignored
+	if err := os.Symlink(statePrefix(states, "db1:3306")+"1.json", statePrefix(states, "db1:3306")+"2.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the settings of this run, which is still going, are not stale
+	running := SetupInstruments{updateSucceeded: true, rows: Rows{{name: "wait/synch/mutex/x", enabled: "NO", timed: "NO"}}}
+	running.SetServer("db1:3306")
+	running.saveState()
+
+	tests := []struct {
+		server string
+		stale  int
+	}{
+		{"db1:3306", 1},
+		{"db2:3306", 0},
+	}
+	for _, test := range tests {
+		var si SetupInstruments
+		si.SetServer(test.server)
+		if len(si.stale) != test.stale {
+			t.Errorf("SetServer(%q) found %d stale state(s), expected %d: %+v", test.server, len(si.stale), test.stale, si.stale)
+		}
+		if (si.Stale() != "") != (test.stale > 0) {
+			t.Errorf("SetServer(%q) Stale() = %q", test.server, si.Stale())
+		}
+	}
+}
+
+func TestSaveConsumers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("XDG_CACHE_HOME", os.Getenv("XDG_CACHE_HOME"))
+	os.Setenv("HOME", dir)
+	os.Setenv("XDG_CACHE_HOME", dir)
+
+	// setup_instruments could not be changed but the consumers were enabled
+	var si SetupInstruments
+	si.SetServer("db1:3306")
+	si.SetConsumers([]string{"events_stages_current"})
+	content, err := ioutil.ReadFile(si.stateFile)
+	if err != nil {
+		t.Fatal("the enabled consumers were not saved:", err)
+	}
+	var s state
+	if err := json.Unmarshal(content, &s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Consumers) != 1 || s.Consumers[0] != "events_stages_current" {
+		t.Errorf("SetConsumers() saved %v", s.Consumers)
+	}
+
+	// once they are disabled there is nothing left to restore
+	si.SetConsumers(nil)
+	if _, err := os.Stat(si.stateFile); !os.IsNotExist(err) {
+		t.Errorf("SetConsumers(nil) left %s behind: %v", si.stateFile, err)
+	}
+}
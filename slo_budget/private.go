@@ -0,0 +1,114 @@
+package slo_budget
+
+import (
+	"fmt"
+
+	"github.com/sjmudd/ps-top/lib"
+)
+
+// Row holds how an SLO is doing
+type Row struct {
+	slo         SLO
+	seen        bool   // a collection has been made
+	usedLatency uint64 // latency counted at the last collection
+	usedOps     uint64 // operations counted at the last collection
+	lastLatency uint64 // latency in the last interval
+	lastOps     uint64 // operations in the last interval
+	latency     uint64 // latency since the reset
+	ops         uint64 // operations since the reset
+	intervals   int    // intervals with operations since the reset
+	met         int    // intervals within budget since the reset
+}
+
+// Rows contains the SLOs
+type Rows []Row
+
+// record adds the interval ending with the latency and operations
+// counted now. Counters which have gone backwards have been reset so
+// the values counted are the change.
+func (r *Row) record(latency, ops uint64) {
+	if r.seen {
+		r.lastLatency, r.lastOps = latency, ops
+		if latency >= r.usedLatency && ops >= r.usedOps {
+			r.lastLatency, r.lastOps = latency-r.usedLatency, ops-r.usedOps
+		}
+		if r.lastOps > 0 {
+			r.latency += r.lastLatency
+			r.ops += r.lastOps
+			r.intervals++
+			if r.withinBudget() {
+				r.met++
+			}
+		}
+	}
+	r.seen = true
+	r.usedLatency, r.usedOps = latency, ops
+}
+
+// reset forgets the intervals since the reset, keeping the last counts
+func (r *Row) reset() {
+	r.latency, r.ops, r.intervals, r.met = 0, 0, 0, 0
+}
+
+// perOp returns the average latency of an operation
+func perOp(latency, ops uint64) uint64 {
+	if ops == 0 {
+		return 0
+	}
+	return latency / ops
+}
+
+// withinBudget returns true if the operations of the last interval were
+// within budget
+func (r Row) withinBudget() bool {
+	return perOp(r.lastLatency, r.lastOps) <= r.slo.Budget
+}
+
+// burnRate returns how fast the budget was used in the last interval:
+// above 1 the operations took longer than the budget
+func (r Row) burnRate() float64 {
+	if r.lastOps == 0 {
+		return 0
+	}
+	return float64(r.lastLatency) / float64(r.lastOps) / float64(r.slo.Budget)
+}
+
+// attainment returns the fraction of the intervals within budget
+func (r Row) attainment() float64 {
+	if r.intervals == 0 {
+		return 0
+	}
+	return float64(r.met) / float64(r.intervals)
+}
+
+func headings() string {
+	return fmt.Sprintf("%10s %6s|%10s %8s %8s|%10s %9s %6s|%s",
+		"Budget", "Op", "Last/op", "Ops", "Burn", "Avg/op", "Met", "Attain", "SLO (tables)")
+}
+
+// rowContent returns the formatted row
+func (r Row) rowContent() string {
+	if r.slo.tables == nil {
+		return fmt.Sprintf("%10s %6s|%10s %8s %8s|%10s %9s %6s|", "", "", "", "", "", "", "", "")
+	}
+
+	burn, met, attain := "", "", ""
+	if r.lastOps > 0 {
+		burn = fmt.Sprintf("%.2fx", r.burnRate())
+	}
+	if r.intervals > 0 {
+		met = fmt.Sprintf("%d/%d", r.met, r.intervals)
+		attain = fmt.Sprintf("%5.1f%%", 100*r.attainment())
+	}
+
+	return fmt.Sprintf("%10s %6s|%10s %8s %8s|%10s %9s %6s|%s",
+		lib.FormatTime(r.slo.Budget),
+		r.slo.Op,
+		lib.FormatTime(perOp(r.lastLatency, r.lastOps)),
+		lib.FormatAmount(r.lastOps),
+		burn,
+		lib.FormatTime(perOp(r.latency, r.ops)),
+		met,
+		attain,
+		r.slo.Name+" ("+r.slo.Tables()+")")
+}
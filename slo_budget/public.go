@@ -0,0 +1,142 @@
+// Package slo_budget checks the table I/O latency against simple
+// service level objectives configured in ~/.pstoprc, e.g.
+// [slo]
+// order_reads = table=shop\.orders.* op=read budget=5ms
+// all_writes = table=.* op=write budget=2ms
+// Each interval an SLO is met if the average latency of its operations
+// on the tables it covers is within budget. The view shows the last
+// interval's latency and burn rate, its latency divided by the budget,
+// and how many intervals were within budget since the reset.
+package slo_budget
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// TableIO is the table I/O collector the SLOs are checked against
+type TableIO interface {
+	ps_table.Tabler
+	ps_table.Valuer
+}
+
+// Object holds the SLOs and how they are doing
+type Object struct {
+	baseobject.BaseObject         // embedded
+	tableIO               TableIO // the table_io_latency collector
+	rows                  Rows
+}
+
+// NewSLOBudget returns a pointer to an object of this type checking the
+// SLOs configured in ~/.pstoprc against the table I/O collected by
+// tableIO. Invalid SLOs are fatal.
+func NewSLOBudget(ctx *context.Context, tableIO TableIO) *Object {
+	logger.Println("NewSLOBudget()")
+	o := &Object{tableIO: tableIO}
+	o.SetContext(ctx)
+
+	for name, definition := range rc.Section("slo") {
+		s, err := ParseSLO(name, definition)
+		if err != nil {
+			log.Fatal("Invalid SLO in ~/.pstoprc: ", err)
+		}
+		o.rows = append(o.rows, Row{slo: s})
+	}
+	sort.Slice(o.rows, func(i, j int) bool { return o.rows[i].slo.Name < o.rows[j].slo.Name })
+
+	return o
+}
+
+// Collect collects the table I/O and adds the interval since the last
+// collection to each SLO
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	if err := t.tableIO.Collect(dbh); err != nil {
+		return err
+	}
+	values := t.tableIO.Values()
+	for i := range t.rows {
+		t.rows[i].record(t.rows[i].slo.usage(values))
+	}
+	t.SetLastCollectTimeNow()
+	if t.InitialCollectTime().IsZero() {
+		t.SetInitialCollectTime(t.LastCollectTime())
+	}
+
+	logger.Println("slo_budget.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	return headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.rows))
+
+	for i := range t.rows {
+		rows = append(rows, t.rows[i].rowContent())
+	}
+
+	return rows
+}
+
+// TotalRowContent returns how many SLOs were within budget in the last interval
+func (t Object) TotalRowContent() string {
+	var active, met int
+	for i := range t.rows {
+		if t.rows[i].lastOps > 0 {
+			active++
+			if t.rows[i].withinBudget() {
+				met++
+			}
+		}
+	}
+
+	return fmt.Sprintf("%d of %d SLO(s) with operations were within budget in the last interval", met, active)
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent()
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	if len(t.rows) == 0 {
+		return "SLO Budget (table_io_waits_summary_by_table) no SLOs, add them to the [slo] section of ~/.pstoprc"
+	}
+	return fmt.Sprintf("SLO Budget (table_io_waits_summary_by_table) %d SLO(s)", len(t.rows))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.rows)
+}
+
+// HaveRelativeStats is false as the intervals are counted since the reset
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent forgets the intervals counted so far
+func (t *Object) SetInitialFromCurrent() {
+	for i := range t.rows {
+		t.rows[i].reset()
+	}
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
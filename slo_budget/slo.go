@@ -0,0 +1,94 @@
+package slo_budget
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// SLO is a budget for the average latency of an operation on the
+// tables whose names match a regular expression
+type SLO struct {
+	Name   string
+	tables *regexp.Regexp
+	Op     string // read, write, insert, update, delete or all
+	Budget uint64 // picoseconds per operation
+}
+
+// ops gives the table I/O counted for each operation
+var ops = map[string][]string{
+	"read":   {"fetch"},
+	"write":  {"insert", "update", "delete"},
+	"insert": {"insert"},
+	"update": {"update"},
+	"delete": {"delete"},
+	"all":    {"fetch", "insert", "update", "delete"},
+}
+
+// ParseSLO returns the SLO with the given name and definition, e.g.
+// table=shop\.orders.* op=read budget=5ms
+// The table is a regular expression which must match the whole
+// <schema>.<table> name. The operation defaults to all.
+func ParseSLO(name, definition string) (SLO, error) {
+	s := SLO{Name: name, Op: "all"}
+
+	for _, field := range strings.Fields(definition) {
+		equals := strings.Index(field, "=")
+		if equals < 1 {
+			return SLO{}, fmt.Errorf("slo %s: expected <name>=<value> but got %q", name, field)
+		}
+		key, value := field[:equals], field[equals+1:]
+		switch key {
+		case "table":
+			re, err := regexp.Compile("^(?:" + value + ")$")
+			if err != nil {
+				return SLO{}, fmt.Errorf("slo %s: %v", name, err)
+			}
+			s.tables = re
+		case "op":
+			if _, found := ops[value]; !found {
+				return SLO{}, fmt.Errorf("slo %s: unknown op %q, expected read, write, insert, update, delete or all", name, value)
+			}
+			s.Op = value
+		case "budget":
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return SLO{}, fmt.Errorf("slo %s: budget should be a duration, e.g. 5ms, not %q", name, value)
+			}
+			s.Budget = uint64(d.Nanoseconds()) * 1000
+		default:
+			return SLO{}, fmt.Errorf("slo %s: unknown setting %q", name, key)
+		}
+	}
+	if s.tables == nil || s.Budget == 0 {
+		return SLO{}, fmt.Errorf("slo %s: both table= and budget= are needed", name)
+	}
+
+	return s, nil
+}
+
+// Tables returns the regular expression of the tables covered
+func (s SLO) Tables() string {
+	return strings.TrimSuffix(strings.TrimPrefix(s.tables.String(), "^(?:"), ")$")
+}
+
+// usage returns the latency and number of the operations of the SLO
+// counted in the table I/O of the tables it covers
+func (s SLO) usage(rows []ps_table.RowValues) (uint64, uint64) {
+	var latency, count uint64
+
+	for _, row := range rows {
+		if !s.tables.MatchString(row.Name) {
+			continue
+		}
+		for _, op := range ops[s.Op] {
+			latency += row.Values["sum_timer_"+op]
+			count += row.Values["count_"+op]
+		}
+	}
+
+	return latency, count
+}
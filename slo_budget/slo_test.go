@@ -0,0 +1,64 @@
+package slo_budget
+
+import (
+	"testing"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestParseSLO(t *testing.T) {
+	for _, definition := range []string{
+		"table=shop.orders",
+		"budget=5ms",
+		"table=( budget=5ms",
+		"table=shop.orders budget=soon",
+		"table=shop.orders budget=5ms op=scan",
+		"table=shop.orders budget=5ms colour=red",
+	} {
+		if _, err := ParseSLO("bad", definition); err == nil {
+			t.Errorf("ParseSLO(%q) expected an error", definition)
+		}
+	}
+
+	s, err := ParseSLO("order_reads", `table=shop\.orders.* op=read budget=5ms`)
+	if err != nil {
+		t.Fatalf("ParseSLO() failed: %v", err)
+	}
+	if s.Op != "read" || s.Budget != 5000000000 || s.Tables() != `shop\.orders.*` {
+		t.Errorf("ParseSLO() got %+v", s)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	s, _ := ParseSLO("order_writes", `table=shop\.order.* op=write budget=1ms`)
+	rows := []ps_table.RowValues{
+		{Name: "shop.orders", Values: map[string]uint64{"sum_timer_fetch": 100, "count_fetch": 10, "sum_timer_insert": 30, "count_insert": 3, "sum_timer_delete": 20, "count_delete": 1}},
+		{Name: "shop.order_items", Values: map[string]uint64{"sum_timer_update": 50, "count_update": 5}},
+		{Name: "shop.customers", Values: map[string]uint64{"sum_timer_update": 500, "count_update": 5}},
+	}
+
+	if latency, ops := s.usage(rows); latency != 100 || ops != 9 {
+		t.Errorf("usage() expected (100, 9) but got (%d, %d)", latency, ops)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	r := Row{slo: SLO{Budget: 10}}
+
+	r.record(1000, 100) // the first collection only sets the starting point
+	r.record(1500, 150) // 10 per op: within budget
+	r.record(2500, 200) // 20 per op: over budget
+	r.record(2500, 200) // no operations: not counted
+
+	if r.intervals != 2 || r.met != 1 {
+		t.Errorf("expected 1 of 2 intervals within budget but got %d of %d", r.met, r.intervals)
+	}
+	if r.ops != 100 || r.latency != 1500 {
+		t.Errorf("expected 100 operations taking 1500 but got %d taking %d", r.ops, r.latency)
+	}
+
+	r.record(3000, 210) // 50 per op
+	if burn := r.burnRate(); burn != 5 {
+		t.Errorf("expected a burn rate of 5 but got %v", burn)
+	}
+}
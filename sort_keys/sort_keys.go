@@ -0,0 +1,176 @@
+// Package sort_keys provides the sort keys which may be configured
+// for each view and a way to apply them when sorting rows.
+//
+// Keys are configured per view in the [sort] section of ~/.pstoprc, e.g.
+// [sort]
+// table_io_latency = latency,ops
+// file_io_latency = ops
+// or on the command line for the view shown on startup. The configured
+// keys are applied first, followed by the view's default ordering.
+package sort_keys
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// Comparer compares the rows at positions i and j for a single key. It
+// returns a negative value if row i should be shown first, a positive value
+// if row j should be shown first and 0 if they can't be told apart.
+type Comparer func(i, j int) int
+
+// Keys maps the name of each key a view can be sorted by to its Comparer
+type Keys map[string]Comparer
+
+var (
+	configured       map[string][]string     // sort keys by view name
+	loadedConfigured bool                    // Have we [attempted to] load ~/.pstoprc?
+	registered       = make(map[string]Keys) // the keys of each view by view name
+)
+
+// Parse splits a comma separated list of keys
+func Parse(list string) []string {
+	var keys []string
+
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// load the [sort] section of ~/.pstoprc (once)
+func load() {
+	if loadedConfigured {
+		return
+	}
+	loadedConfigured = true
+
+	configured = make(map[string][]string)
+	for view, list := range rc.Section("sort") {
+		configured[view] = Parse(list)
+	}
+	logger.Println("sort_keys.load() found sort keys for", len(configured), "view(s)")
+}
+
+// Set sets the keys to sort the given view by, overriding ~/.pstoprc
+func Set(view string, keys []string) {
+	load()
+	logger.Println("sort_keys.Set(", view, ",", keys, ")")
+	configured[view] = keys
+}
+
+// Configured returns the keys configured for the given view
+func Configured(view string) []string {
+	load()
+	return configured[view]
+}
+
+// Register records the keys the view may be sorted by so the keys
+// configured for it can be checked on startup by Check. The comparers
+// are not used so the keys of no rows may be given.
+func Register(view string, keys Keys) {
+	if registered[view] == nil {
+		registered[view] = make(Keys)
+	}
+	for name, comparer := range keys {
+		registered[view][name] = comparer
+	}
+}
+
+// Check returns an error if a key configured for a view is not one it
+// may be sorted by, so a mistake in --sort or the [sort] section of
+// ~/.pstoprc is reported on startup rather than when the view is
+// collected
+func Check() error {
+	load()
+
+	views := make([]string, 0, len(configured))
+	for view := range configured {
+		views = append(views, view)
+	}
+	sort.Strings(views)
+
+	for _, view := range views {
+		keys, found := registered[view]
+		if !found {
+			continue // not a view which can be sorted
+		}
+		for _, name := range configured[view] {
+			if _, ok := keys[name]; !ok {
+				return fmt.Errorf("Unknown sort key '%s' for view %s. Try one of: %s", name, view, strings.Join(keys.names(), ", "))
+			}
+		}
+	}
+
+	return nil
+}
+
+// names returns the names of the keys in alphabetical order
+func (k Keys) names() []string {
+	names := make([]string, 0, len(k))
+	for name := range k {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Less returns a function suitable for sort.Slice which orders rows by the
+// keys configured for the view followed by the given default keys.
+// Unknown configured keys are fatal, though Check finds them on startup.
+func (k Keys) Less(view string, defaults ...string) func(i, j int) bool {
+	var comparers []Comparer
+
+	for _, name := range append(Configured(view), defaults...) {
+		comparer, ok := k[name]
+		if !ok {
+			log.Fatal("Unknown sort key '", name, "' for view ", view, ". Try one of: ", strings.Join(k.names(), ", "))
+		}
+		comparers = append(comparers, comparer)
+	}
+
+	return func(i, j int) bool {
+		for _, compare := range comparers {
+			if c := compare(i, j); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}
+}
+
+// Descending compares two numbers so that the largest is shown first
+func Descending(a, b uint64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
+
+// SignedDescending compares two signed numbers so that the largest is shown first
+func SignedDescending(a, b int64) int {
+	switch {
+	case a > b:
+		return -1
+	case a < b:
+		return 1
+	}
+	return 0
+}
+
+// Ascending compares two strings so that they are shown in alphabetical order
+func Ascending(a, b string) int {
+	return strings.Compare(a, b)
+}
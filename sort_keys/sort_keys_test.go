@@ -0,0 +1,21 @@
+package sort_keys
+
+import (
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	loadedConfigured, configured = true, make(map[string][]string)
+	Register("test_view", Keys{"latency": nil, "name": nil})
+
+	Set("test_view", []string{"latency", "name"})
+	Set("other_view", []string{"anything"}) // not registered so not checked
+	if err := Check(); err != nil {
+		t.Errorf("Check() returned an unexpected error: %v", err)
+	}
+
+	Set("test_view", []string{"latency", "ops"})
+	if err := Check(); err == nil {
+		t.Errorf("Check() expected an error for the unknown key ops")
+	}
+}
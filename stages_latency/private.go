@@ -3,12 +3,14 @@ package stages_latency
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 
 	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
 )
 
 /**************************************************************************
@@ -35,54 +37,42 @@ type Row struct {
 	sumTimerWait uint64
 }
 
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"EVENT_NAME":     func(r *Row) interface{} { return &r.name },
+	"COUNT_STAR":     func(r *Row) interface{} { return &r.countStar },
+	"SUM_TIMER_WAIT": func(r *Row) interface{} { return &r.sumTimerWait },
+}
+
 // Rows contains a slice of Rows
 type Rows []Row
 
 // select the rows into table
-func selectRows(dbh *sql.DB) Rows {
-	var t Rows
-
+func selectRows(dbh *sql.DB) (Rows, error) {
 	logger.Println("events_stages_summary_global_by_event_name.selectRows()")
 	sql := "SELECT EVENT_NAME, COUNT_STAR, SUM_TIMER_WAIT FROM events_stages_summary_global_by_event_name WHERE SUM_TIMER_WAIT > 0"
 
-	rows, err := dbh.Query(sql)
+	t, err := lib.ReadRowsFromSQL(dbh, columns, sql)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var r Row
-		if err := rows.Scan(
-			&r.name,
-			&r.countStar,
-			&r.sumTimerWait); err != nil {
-			log.Fatal(err)
-		}
 
+	for i := range t {
 		// convert the stage name, removing any leading stage/sql/
-		if len(r.name) > 10 && r.name[0:10] == "stage/sql/" {
-			r.name = r.name[10:]
+		if len(t[i].name) > 10 && t[i].name[0:10] == "stage/sql/" {
+			t[i].name = t[i].name[10:]
 		}
-
-		t = append(t, r)
-	}
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
 	}
 	logger.Println("recovered", len(t), "row(s):")
 	logger.Println(t)
 
-	return t
+	return t, nil
 }
 
 // if the data in t2 is "newer", "has more values" than t then it needs refreshing.
-// check this by comparing totals.
+// check this by comparing the totals of the rows found in both.
 func (rows Rows) needsRefresh(otherRows Rows) bool {
-	myTotals := rows.totals()
-	otherTotals := otherRows.totals()
-
-	return myTotals.sumTimerWait > otherTotals.sumTimerWait
+	return differ.NeedsRefresh(rows, otherRows)
 }
 
 // generate the totals of a table
@@ -105,48 +95,40 @@ func (row *Row) add(other Row) {
 
 // subtract the countable values in one row from another
 func (row *Row) subtract(other Row) {
-	// check for issues here (we have a bug) and log it
-	// - this situation should not happen so there's a logic bug somewhere else
-	if row.sumTimerWait >= other.sumTimerWait {
-		row.sumTimerWait -= other.sumTimerWait
-		row.countStar -= other.countStar
-	} else {
-		logger.Println("WARNING: Row.subtract() - subtraction problem! (not subtracting)")
-		logger.Println("row=", row)
-		logger.Println("other=", other)
-	}
+	row.sumTimerWait -= other.sumTimerWait
+	row.countStar -= other.countStar
 }
 
-func (rows Rows) Len() int      { return len(rows) }
-func (rows Rows) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
 
 // sort by value (descending) but also by "name" (ascending) if the values are the same
-func (rows Rows) Less(i, j int) bool {
-	return (rows[i].sumTimerWait > rows[j].sumTimerWait) ||
-		((rows[i].sumTimerWait == rows[j].sumTimerWait) && (rows[i].name < rows[j].name))
+// after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("stages_latency", "latency", "name"))
 }
 
-func (rows Rows) sort() {
-	sort.Sort(rows)
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("stages_latency", Rows(nil).sortKeys())
+}
+
+// differ matches the rows by name to subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: (*Row).subtract,
 }
 
 // remove the initial values from those rows where there's a match
-// - if we find a row we can't match ignore it
 func (rows *Rows) subtract(initial Rows) {
-	initialByName := make(map[string]int)
-
-	// iterate over rows by name
-	for i := range initial {
-		initialByName[initial[i].name] = i
-	}
-
-	for i := range *rows {
-		name := (*rows)[i].name
-		if _, ok := initialByName[name]; ok {
-			initialIndex := initialByName[name]
-			(*rows)[i].subtract(initial[initialIndex])
-		}
-	}
+	differ.SubtractRows(*rows, initial)
 }
 
 // stage headings
@@ -186,3 +168,14 @@ func (rows Rows) String() string {
 
 	return strings.Join(s, "\n")
 }
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"count_star":     row.countStar,
+			"sum_timer_wait": row.sumTimerWait,
+		},
+	}
+}
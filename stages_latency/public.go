@@ -9,6 +9,7 @@ import (
 	"github.com/sjmudd/ps-top/baseobject"
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
 )
 
 /*
@@ -48,15 +49,30 @@ root@localhost [performance_schema]> select * from events_stages_summary_global_
 type Object struct {
 	baseobject.BaseObject      // embedded
 	initial               Rows // initial data for relative values
+	mark                  Rows // marked data for relative values since the mark
 	current               Rows // last loaded values
 	results               Rows // results (maybe with subtraction)
 	totals                Row  // totals of results
 }
 
 func (t *Object) copyCurrentToInitial() {
-	t.initial = make(Rows, len(t.current))
+	t.initial = append(t.initial[:0], t.current...)
 	t.SetInitialCollectTime(t.LastCollectTime())
-	copy(t.initial, t.current)
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
 }
 
 func NewStagesLatency(ctx *context.Context) *Object {
@@ -70,9 +86,13 @@ func NewStagesLatency(ctx *context.Context) *Object {
 // Collect collects data from the db, updating initial
 // values if needed, and then subtracting initial values if we want
 // relative values, after which it stores totals.
-func (t *Object) Collect(dbh *sql.DB) {
+func (t *Object) Collect(dbh *sql.DB) error {
 	start := time.Now()
-	t.current = selectRows(dbh)
+	rows, err := selectRows(dbh)
+	if err != nil {
+		return err
+	}
+	t.current = rows
 	t.SetLastCollectTimeNow()
 	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
 
@@ -86,6 +106,10 @@ func (t *Object) Collect(dbh *sql.DB) {
 		logger.Println("t.initial: copying from t.current (data needs refreshing)")
 		t.copyCurrentToInitial()
 	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
 
 	t.makeResults()
 
@@ -96,6 +120,8 @@ func (t *Object) Collect(dbh *sql.DB) {
 	// logger.Println("t.results:", t.results)
 	// logger.Println("t.totals:", t.totals)
 	logger.Println("Table_io_waits_summary_by_table.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
 }
 
 // Headings returns the headings of the object
@@ -147,9 +173,10 @@ func (t *Object) SetInitialFromCurrent() {
 // generate the results and totals and sort data
 func (t *Object) makeResults() {
 	// logger.Println( "- t.results set from t.current" )
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
-	if t.WantRelativeStats() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
 		t.results.subtract(t.initial)
 	}
 
@@ -166,3 +193,25 @@ func (t Object) Len() int {
 func (t Object) HaveRelativeStats() bool {
 	return true
 }
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
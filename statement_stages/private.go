@@ -0,0 +1,272 @@
+// Package statement_stages contains the library routines for combining
+// performance_schema.events_statements_history_long with
+// performance_schema.events_stages_history_long.
+package statement_stages
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// consumers which must be enabled for the history tables to be filled
+var consumers = []string{"events_statements_history_long", "events_stages_history_long"}
+
+const (
+	maxStatements = 10 // statements shown
+	maxStages     = 5  // stages shown for each statement
+)
+
+// Row contains a statement, or a stage of the statement shown above it
+type Row struct {
+	name         string // statement text or stage name
+	stage        bool   // a stage of the previous statement
+	countStar    uint64 // times the statement or stage was seen
+	sumTimerWait uint64
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// a statement from events_statements_history_long
+type statementEvent struct {
+	threadID  uint64
+	eventID   uint64
+	text      string
+	timerWait uint64
+}
+
+// statementColumns gives the field of a statementEvent each column is read into
+var statementColumns = lib.Columns[statementEvent]{
+	"THREAD_ID":  func(s *statementEvent) interface{} { return &s.threadID },
+	"EVENT_ID":   func(s *statementEvent) interface{} { return &s.eventID },
+	"TEXT":       func(s *statementEvent) interface{} { return &s.text },
+	"TIMER_WAIT": func(s *statementEvent) interface{} { return &s.timerWait },
+}
+
+// a stage from events_stages_history_long and the statement it belongs to
+type stageEvent struct {
+	threadID    uint64
+	statementID uint64 // NESTING_EVENT_ID
+	name        string
+	timerWait   uint64
+}
+
+// stageColumns gives the field of a stageEvent each column is read into
+var stageColumns = lib.Columns[stageEvent]{
+	"THREAD_ID":        func(s *stageEvent) interface{} { return &s.threadID },
+	"NESTING_EVENT_ID": func(s *stageEvent) interface{} { return &s.statementID },
+	"EVENT_NAME":       func(s *stageEvent) interface{} { return &s.name },
+	"TIMER_WAIT":       func(s *stageEvent) interface{} { return &s.timerWait },
+}
+
+// disabledConsumers returns the consumers needed by the view which are not enabled
+func disabledConsumers(dbh *sql.DB) ([]string, error) {
+	enabled := make(map[string]bool)
+
+	sql := "SELECT NAME, ENABLED FROM setup_consumers WHERE NAME IN ('" + strings.Join(consumers, "', '") + "')"
+
+	rows, err := dbh.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		enabled[name] = value == "YES"
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var disabled []string
+	for _, consumer := range consumers {
+		if !enabled[consumer] {
+			disabled = append(disabled, consumer)
+		}
+	}
+
+	return disabled, nil
+}
+
+// selectStatements returns the finished statements in the history table
+func selectStatements(dbh *sql.DB) ([]statementEvent, error) {
+	sql := `
+SELECT	THREAD_ID,
+	EVENT_ID,
+	COALESCE(DIGEST_TEXT, SQL_TEXT, '') AS TEXT,
+	TIMER_WAIT
+FROM	events_statements_history_long
+WHERE	TIMER_WAIT IS NOT NULL`
+
+	t, err := lib.ReadRowsFromSQL(dbh, statementColumns, sql)
+	if err != nil {
+		return nil, err
+	}
+	for i := range t {
+		t[i].text = strings.Join(strings.Fields(t[i].text), " ")
+	}
+
+	return t, nil
+}
+
+// selectStages returns the finished stages of statements in the history table
+func selectStages(dbh *sql.DB) ([]stageEvent, error) {
+	sql := `
+SELECT	THREAD_ID,
+	NESTING_EVENT_ID,
+	EVENT_NAME,
+	TIMER_WAIT
+FROM	events_stages_history_long
+WHERE	NESTING_EVENT_TYPE = 'STATEMENT'
+AND	TIMER_WAIT IS NOT NULL`
+
+	t, err := lib.ReadRowsFromSQL(dbh, stageColumns, sql)
+	if err != nil {
+		return nil, err
+	}
+	for i := range t {
+		// convert the stage name, removing any leading stage/sql/
+		t[i].name = strings.TrimPrefix(t[i].name, "stage/sql/")
+	}
+
+	return t, nil
+}
+
+// selectRows reads both history tables and matches the stages to their
+// statements here rather than joining the tables on the server, as the
+// history tables have no indexes. This keeps the cost to one scan of each.
+func selectRows(dbh *sql.DB) (Rows, Row, error) {
+	statements, err := selectStatements(dbh)
+	if err != nil {
+		return nil, Row{}, err
+	}
+	stages, err := selectStages(dbh)
+	if err != nil {
+		return nil, Row{}, err
+	}
+	logger.Println("statement_stages.selectRows() recovered", len(statements), "statement(s) and", len(stages), "stage(s)")
+
+	rows, totals := correlate(statements, stages)
+
+	return rows, totals, nil
+}
+
+// correlate adds up the statements with the same text and the stages
+// seen while running them. It returns the top statements, each followed
+// by its top stages, and the totals of all statements.
+func correlate(statements []statementEvent, stages []stageEvent) (Rows, Row) {
+	type eventKey struct{ threadID, eventID uint64 }
+
+	var statementRows Rows
+	totals := Row{name: "Totals"}
+	byText := make(map[string]int)               // statement text to position in statementRows
+	textOf := make(map[eventKey]string)          // statement event to text
+	stagesOf := make(map[string]map[string]*Row) // stages by statement text and stage name
+
+	for _, s := range statements {
+		i, found := byText[s.text]
+		if !found {
+			i = len(statementRows)
+			byText[s.text] = i
+			statementRows = append(statementRows, Row{name: s.text})
+			stagesOf[s.text] = make(map[string]*Row)
+		}
+		statementRows[i].countStar++
+		statementRows[i].sumTimerWait += s.timerWait
+		totals.countStar++
+		totals.sumTimerWait += s.timerWait
+		textOf[eventKey{s.threadID, s.eventID}] = s.text
+	}
+
+	for _, s := range stages {
+		text, found := textOf[eventKey{s.threadID, s.statementID}]
+		if !found {
+			continue // the statement is no longer in the history
+		}
+		stage, found := stagesOf[text][s.name]
+		if !found {
+			stage = &Row{name: s.name, stage: true}
+			stagesOf[text][s.name] = stage
+		}
+		stage.countStar++
+		stage.sumTimerWait += s.timerWait
+	}
+
+	statementRows.sort()
+	if len(statementRows) > maxStatements {
+		statementRows = statementRows[:maxStatements]
+	}
+
+	var rows Rows
+	for _, statement := range statementRows {
+		var stageRows Rows
+		for _, stage := range stagesOf[statement.name] {
+			stageRows = append(stageRows, *stage)
+		}
+		sort.Slice(stageRows, func(i, j int) bool {
+			if stageRows[i].sumTimerWait != stageRows[j].sumTimerWait {
+				return stageRows[i].sumTimerWait > stageRows[j].sumTimerWait
+			}
+			return stageRows[i].name < stageRows[j].name
+		})
+		if len(stageRows) > maxStages {
+			stageRows = stageRows[:maxStages]
+		}
+		rows = append(append(rows, statement), stageRows...)
+	}
+
+	return rows, totals
+}
+
+// sortKeys returns the keys the statements may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"count":   func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort the statements by latency (descending) but also by "name" (ascending)
+// if the values are the same after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("statement_stages", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("statement_stages", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %8s|%s", "Latency", "%", "Counter", "Statement / Stage")
+}
+
+// generate a printable result. The percentage of a statement is of the
+// latency of all statements and that of a stage of its statement's latency.
+func (row *Row) rowContent(total uint64) string {
+	name := row.name
+	if row.stage {
+		name = "    " + name
+	}
+	if row.countStar == 0 && name != "Totals" {
+		name = ""
+	}
+
+	return fmt.Sprintf("%10s %6s %8s|%s",
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatPct(lib.MyDivide(row.sumTimerWait, total)),
+		lib.FormatAmount(row.countStar),
+		name)
+}
@@ -0,0 +1,37 @@
+package statement_stages
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCorrelate(t *testing.T) {
+	statements := []statementEvent{
+		{threadID: 1, eventID: 10, text: "SELECT ?", timerWait: 100},
+		{threadID: 2, eventID: 10, text: "SELECT ?", timerWait: 200},
+		{threadID: 1, eventID: 20, text: "UPDATE `t` SET `a` = ?", timerWait: 1000},
+	}
+	stages := []stageEvent{
+		{threadID: 1, statementID: 10, name: "executing", timerWait: 60},
+		{threadID: 2, statementID: 10, name: "executing", timerWait: 150},
+		{threadID: 2, statementID: 10, name: "statistics", timerWait: 30},
+		{threadID: 1, statementID: 20, name: "updating", timerWait: 900},
+		{threadID: 3, statementID: 10, name: "executing", timerWait: 999}, // statement no longer in the history
+	}
+
+	rows, totals := correlate(statements, stages)
+
+	want := Rows{
+		{name: "UPDATE `t` SET `a` = ?", countStar: 1, sumTimerWait: 1000},
+		{name: "updating", stage: true, countStar: 1, sumTimerWait: 900},
+		{name: "SELECT ?", countStar: 2, sumTimerWait: 300},
+		{name: "executing", stage: true, countStar: 2, sumTimerWait: 210},
+		{name: "statistics", stage: true, countStar: 1, sumTimerWait: 30},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("correlate() rows = %v, want %v", rows, want)
+	}
+	if totals.countStar != 3 || totals.sumTimerWait != 1300 {
+		t.Errorf("correlate() totals = %+v, want 3 statements taking 1300", totals)
+	}
+}
@@ -0,0 +1,116 @@
+// Package statement_stages shows which stages take the time of the
+// top statements, based on the statement and stage history tables.
+package statement_stages
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the top statements and their stages
+type Object struct {
+	baseobject.BaseObject          // embedded
+	current               Rows     // statements, each followed by its stages
+	totals                Row      // totals of all statements
+	disabled              []string // consumers which need enabling
+}
+
+// NewStatementStages returns a pointer to an object of this type
+func NewStatementStages(ctx *context.Context) *Object {
+	logger.Println("NewStatementStages()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the statements and stages in the history tables.
+// The history tables are not scanned if their consumers are disabled.
+// There are no relative values as the history tables only hold the
+// most recent events.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	disabled, err := disabledConsumers(dbh)
+	if err != nil {
+		return err
+	}
+
+	rows, totals := Rows(nil), Row{name: "Totals"}
+	if len(disabled) == 0 {
+		if rows, totals, err = selectRows(dbh); err != nil {
+			return err
+		}
+	}
+	t.disabled, t.current, t.totals = disabled, rows, totals
+	t.SetLastCollectTimeNow()
+
+	logger.Println("statement_stages.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns the statements, each followed by its stages
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+	statementWait := t.totals.sumTimerWait
+
+	for i := range t.current {
+		if !t.current[i].stage {
+			rows = append(rows, t.current[i].rowContent(t.totals.sumTimerWait))
+			statementWait = t.current[i].sumTimerWait
+		} else {
+			rows = append(rows, t.current[i].rowContent(statementWait))
+		}
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the totals of all statements
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals.sumTimerWait)
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(0)
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	if len(t.disabled) > 0 {
+		return "Stages by Statement: please enable the consumers " + strings.Join(t.disabled, ", ")
+	}
+
+	return fmt.Sprintf("Stages by Statement (events_statements_history_long, events_stages_history_long) %d statements", t.totals.countStar)
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as the history tables only hold recent events
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("statement_stages.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
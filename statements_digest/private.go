@@ -0,0 +1,382 @@
+// Package statements_digest contains the library routines for managing
+// performance_schema.events_statements_summary_by_digest.
+package statements_digest
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+	"github.com/sjmudd/ps-top/table"
+)
+
+/*
+
+CREATE TABLE `events_statements_summary_by_digest` (
+  `SCHEMA_NAME` varchar(64) DEFAULT NULL,
+  `DIGEST` varchar(32) DEFAULT NULL,
+  `DIGEST_TEXT` longtext,
+  `COUNT_STAR` bigint(20) unsigned NOT NULL,
+  `SUM_TIMER_WAIT` bigint(20) unsigned NOT NULL,
+  ...
+  `SUM_ROWS_AFFECTED` bigint(20) unsigned NOT NULL,
+  `SUM_ROWS_SENT` bigint(20) unsigned NOT NULL,
+  `SUM_ROWS_EXAMINED` bigint(20) unsigned NOT NULL,
+  ...
+  `SUM_SELECT_FULL_JOIN` bigint(20) unsigned NOT NULL,
+  ...
+  `SUM_SELECT_SCAN` bigint(20) unsigned NOT NULL,
+  ...
+  `SUM_NO_INDEX_USED` bigint(20) unsigned NOT NULL,
+  `SUM_NO_GOOD_INDEX_USED` bigint(20) unsigned NOT NULL,
+  `FIRST_SEEN` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00',
+  `LAST_SEEN` timestamp NOT NULL DEFAULT '0000-00-00 00:00:00'
+) ENGINE=PERFORMANCE_SCHEMA DEFAULT CHARSET=utf8
+
+8.0.28 adds SUM_CPU_TIME, the CPU time used by the statements, which is
+only collected while the events_statements_cpu consumer is enabled.
+
+*/
+
+// Row contains a row from events_statements_summary_by_digest
+type Row struct {
+	name            string // schema and digest which identify the row
+	text            string // schema and digest text used for display
+	countStar       uint64
+	sumTimerWait    uint64
+	sumCPUTime      uint64
+	rowsAffected    uint64
+	rowsSent        uint64
+	rowsExamined    uint64
+	selectFullJoin  uint64
+	selectScan      uint64
+	noIndexUsed     uint64
+	noGoodIndexUsed uint64
+	wastedRows      uint64   // rows examined but not sent or changed (derived)
+	tables          []string // tables used by the statement (derived)
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// optionalColumns may be missing on servers with a partial
+// performance_schema, such as TiDB, and are then shown as 0
+var optionalColumns = []string{
+	"SUM_ROWS_AFFECTED", "SUM_SELECT_FULL_JOIN", "SUM_SELECT_SCAN",
+	"SUM_NO_INDEX_USED", "SUM_NO_GOOD_INDEX_USED", "SUM_CPU_TIME",
+}
+
+// select the rows into table, only selecting the optional columns we have access to
+func selectRows(dbh *sql.DB, columns *table.Columns) (Rows, error) {
+	var t Rows
+
+	if err := columns.Check(dbh); err != nil {
+		return nil, err
+	}
+	sql := `
+SELECT	COALESCE(SCHEMA_NAME, ''),
+	COALESCE(DIGEST, ''),
+	COALESCE(DIGEST_TEXT, ''),
+	COUNT_STAR,
+	SUM_TIMER_WAIT,
+	` + columns.Select("SUM_CPU_TIME") + `,
+	` + columns.Select("SUM_ROWS_AFFECTED") + `,
+	SUM_ROWS_SENT,
+	SUM_ROWS_EXAMINED,
+	` + columns.Select("SUM_SELECT_FULL_JOIN") + `,
+	` + columns.Select("SUM_SELECT_SCAN") + `,
+	` + columns.Select("SUM_NO_INDEX_USED") + `,
+	` + columns.Select("SUM_NO_GOOD_INDEX_USED") + `
+FROM	events_statements_summary_by_digest`
+
+	rows, err := dbh.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		var schema, digest, text string
+
+		if err := rows.Scan(
+			&schema,
+			&digest,
+			&text,
+			&r.countStar,
+			&r.sumTimerWait,
+			&r.sumCPUTime,
+			&r.rowsAffected,
+			&r.rowsSent,
+			&r.rowsExamined,
+			&r.selectFullJoin,
+			&r.selectScan,
+			&r.noIndexUsed,
+			&r.noGoodIndexUsed); err != nil {
+			return nil, err
+		}
+		r.name = schema + "/" + digest
+		r.text = digestText(schema, text)
+		r.tables = digestTables(schema, text)
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("statements_digest.selectRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// digestText returns the text to show for a digest on a single line
+func digestText(schema, text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if schema == "" {
+		return text
+	}
+
+	return anonymiser.Anonymise("schema", schema) + ": " + text
+}
+
+// setWasted records the number of rows examined which were not sent
+// to the client or changed.
+func (row *Row) setWasted() {
+	row.wastedRows = validSubtract(row.rowsExamined, row.rowsSent+row.rowsAffected)
+}
+
+// examined returns the rows of the statements which examined any rows,
+// reusing the space of rows
+func (rows Rows) examined() Rows {
+	t := rows[:0]
+
+	for i := range rows {
+		if rows[i].rowsExamined > 0 {
+			t = append(t, rows[i])
+		}
+	}
+
+	return t
+}
+
+// setWasted sets the wasted rows of each row
+func (rows Rows) setWasted() {
+	for i := range rows {
+		rows[i].setWasted()
+	}
+}
+
+// flags returns a short string indicating the type of scans seen
+// N: no index used, G: no good index used, S: full table scan, J: full join
+func (row Row) flags() string {
+	flags := ""
+	if row.noIndexUsed > 0 {
+		flags += "N"
+	}
+	if row.noGoodIndexUsed > 0 {
+		flags += "G"
+	}
+	if row.selectScan > 0 {
+		flags += "S"
+	}
+	if row.selectFullJoin > 0 {
+		flags += "J"
+	}
+
+	return flags
+}
+
+// formatRatio returns the ratio of rows examined to rows used
+func (row Row) formatRatio() string {
+	used := row.rowsSent + row.rowsAffected
+	if row.rowsExamined == 0 {
+		return ""
+	}
+	if used == 0 {
+		return "inf"
+	}
+
+	return fmt.Sprintf("%.1f", lib.MyDivide(row.rowsExamined, used))
+}
+
+// add the values of one row to another one
+func (row *Row) add(other Row) {
+	row.countStar += other.countStar
+	row.sumTimerWait += other.sumTimerWait
+	row.sumCPUTime += other.sumCPUTime
+	row.rowsAffected += other.rowsAffected
+	row.rowsSent += other.rowsSent
+	row.rowsExamined += other.rowsExamined
+	row.selectFullJoin += other.selectFullJoin
+	row.selectScan += other.selectScan
+	row.noIndexUsed += other.noIndexUsed
+	row.noGoodIndexUsed += other.noGoodIndexUsed
+	row.wastedRows += other.wastedRows
+}
+
+// sometimes the values can drop and catch us out. This routines hides that by returning 0
+func validSubtract(this, that uint64) uint64 {
+	if this > that {
+		return this - that
+	}
+	return 0
+}
+
+// subtract the countable values in one row from another
+func (row *Row) subtract(other Row) {
+	row.countStar -= other.countStar
+	row.sumTimerWait -= other.sumTimerWait
+	row.sumCPUTime -= other.sumCPUTime
+	row.rowsAffected -= other.rowsAffected
+	row.rowsSent -= other.rowsSent
+	row.rowsExamined -= other.rowsExamined
+	row.selectFullJoin -= other.selectFullJoin
+	row.selectScan -= other.selectScan
+	row.noIndexUsed -= other.noIndexUsed
+	row.noGoodIndexUsed -= other.noGoodIndexUsed
+}
+
+// generate the totals of a table
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.name = "Totals"
+	totals.text = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// if the data in t2 is "newer", "has more values" than t then it needs refreshing.
+// check this by comparing the totals of the rows found in both.
+func (rows Rows) needsRefresh(otherRows Rows) bool {
+	return differ.NeedsRefresh(rows, otherRows)
+}
+
+// differ matches the rows by digest to subtract their initial values.
+// Digests may be dropped from the table and then added again so their
+// counters can go backwards.
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait, row.rowsExamined} },
+	Subtract: (*Row).subtract,
+}
+
+// remove the initial values from those rows where there's a match
+func (rows *Rows) subtract(initial Rows) {
+	differ.SubtractRows(*rows, initial)
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"wasted":   func(i, j int) int { return sort_keys.Descending(rows[i].wastedRows, rows[j].wastedRows) },
+		"examined": func(i, j int) int { return sort_keys.Descending(rows[i].rowsExamined, rows[j].rowsExamined) },
+		"sent":     func(i, j int) int { return sort_keys.Descending(rows[i].rowsSent, rows[j].rowsSent) },
+		"affected": func(i, j int) int { return sort_keys.Descending(rows[i].rowsAffected, rows[j].rowsAffected) },
+		"execs":    func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"latency":  func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"cpu":      func(i, j int) int { return sort_keys.Descending(rows[i].sumCPUTime, rows[j].sumCPUTime) },
+		"name":     func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by wasted rows (descending) but also by "name" (ascending) if the values are the same
+// after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("statement_efficiency", "wasted", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("statement_efficiency", Rows(nil).sortKeys())
+}
+
+//	Wasted      %|  Examined       Sent   Affected    Ratio|     Execs|       CPU   CPU%|Flag|Statement
+//
+// 1234567890 100.0%|1234567890 1234567890 1234567890 12345678|1234567890|1234567890 100.0%|NGSJ|xxxxxxxxxxxxxxxxxxxxxxxxxxxx
+func (row *Row) efficiencyHeadings() string {
+	return fmt.Sprintf("%10s %6s|%10s %10s %10s %8s|%10s|%10s %6s|%-4s|%s",
+		"Wasted", "%", "Examined", "Sent", "Affected", "Ratio", "Execs", "CPU", "CPU%", "Flag", "Statement")
+}
+
+// cpuPct returns the share of the latency spent on the CPU, high for a
+// CPU bound statement and low for one which mostly waits, or nothing if
+// the CPU time isn't known
+func (row Row) cpuPct() string {
+	if row.sumCPUTime == 0 {
+		return ""
+	}
+
+	return lib.FormatPct(lib.MyDivide(row.sumCPUTime, row.sumTimerWait))
+}
+
+// generate a printable result
+func (row *Row) efficiencyRowContent(totals Row) string {
+	text := row.text
+	if row.rowsExamined == 0 && text != "Totals" {
+		text = ""
+	}
+
+	return fmt.Sprintf("%10s %6s|%10s %10s %10s %8s|%10s|%10s %6s|%-4s|%s",
+		lib.FormatAmount(row.wastedRows),
+		lib.FormatPct(lib.MyDivide(row.wastedRows, totals.wastedRows)),
+		lib.FormatAmount(row.rowsExamined),
+		lib.FormatAmount(row.rowsSent),
+		lib.FormatAmount(row.rowsAffected),
+		row.formatRatio(),
+		lib.FormatAmount(row.countStar),
+		lib.FormatTime(row.sumCPUTime),
+		row.cpuPct(),
+		row.flags(),
+		text)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10s %10s %10s %10s %10s %s",
+		lib.FormatAmount(row.countStar),
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatAmount(row.rowsExamined),
+		lib.FormatAmount(row.rowsSent),
+		lib.FormatAmount(row.rowsAffected),
+		row.name)
+}
+
+// String describes a whole table
+func (rows Rows) String() string {
+	s := make([]string, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"count_star":             row.countStar,
+			"sum_timer_wait":         row.sumTimerWait,
+			"sum_cpu_time":           row.sumCPUTime,
+			"sum_rows_affected":      row.rowsAffected,
+			"sum_rows_sent":          row.rowsSent,
+			"sum_rows_examined":      row.rowsExamined,
+			"sum_select_full_join":   row.selectFullJoin,
+			"sum_select_scan":        row.selectScan,
+			"sum_no_index_used":      row.noIndexUsed,
+			"sum_no_good_index_used": row.noGoodIndexUsed,
+		},
+	}
+}
@@ -0,0 +1,211 @@
+// Package statements_digest provides the views based on
+// performance_schema.events_statements_summary_by_digest.
+package statements_digest
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/table"
+)
+
+// Object holds a table of rows
+type Object struct {
+	baseobject.BaseObject                // embedded
+	initial               Rows           // initial data for relative values
+	mark                  Rows           // marked data for relative values since the mark
+	current               Rows           // last loaded values
+	results               Rows           // results (maybe with subtraction)
+	totals                Row            // totals of results
+	columns               *table.Columns // which of the optional columns can be SELECTed
+}
+
+// NewStatementsDigest returns a pointer to an object of this type
+func NewStatementsDigest(ctx *context.Context) *Object {
+	logger.Println("NewStatementsDigest()")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.columns = table.NewColumns("events_statements_summary_by_digest", optionalColumns...)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
+}
+
+// Collect collects data from the db, updating initial
+// values if needed, and then subtracting initial values if we want
+// relative values, after which it stores totals.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh, t.columns)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.initial.needsRefresh(t.current) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
+
+	t.makeResults()
+
+	logger.Println("statements_digest.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	t.results = append(t.results[:0], t.current...)
+	if !t.WantByTable() {
+		// statements examining no rows, e.g. INSERT ... VALUES, still count towards their tables
+		t.results = t.results.examined()
+	}
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+	t.results.setWasted()
+	t.totals = t.results.totals()
+
+	if t.WantByTable() {
+		t.results = t.results.byTable()
+		t.results.sortByTable()
+	} else {
+		t.results.sort()
+	}
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// Headings returns the headings of the object
+func (t Object) Headings() string {
+	var r Row
+
+	if t.WantByTable() {
+		return r.tableHeadings()
+	}
+	return r.efficiencyHeadings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		if t.WantByTable() {
+			rows = append(rows, t.results[i].tableRowContent(t.totals))
+		} else {
+			rows = append(rows, t.results[i].efficiencyRowContent(t.totals))
+		}
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	if t.WantByTable() {
+		return e.tableRowContent(e)
+	}
+	return e.efficiencyRowContent(e)
+}
+
+// TotalRowContent returns a row containing the totals
+func (t Object) TotalRowContent() string {
+	if t.WantByTable() {
+		return t.totals.tableRowContent(t.totals)
+	}
+	return t.totals.efficiencyRowContent(t.totals)
+}
+
+// Description describes the view
+func (t Object) Description() string {
+	if t.WantByTable() {
+		return fmt.Sprintf("Statement Latency by Table (events_statements_summary_by_digest) %d tables (a: by statement)", len(t.results))
+	}
+
+	var count int
+	for row := range t.results {
+		if t.results[row].wastedRows > 0 {
+			count++
+		}
+	}
+
+	return fmt.Sprintf("Statement Efficiency (events_statements_summary_by_digest) %d rows (a: by table)", count)
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
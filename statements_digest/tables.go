@@ -0,0 +1,152 @@
+package statements_digest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+)
+
+// tableKeywords are followed by the name of a table in digest text
+var tableKeywords = map[string]bool{
+	"FROM":   true,
+	"INTO":   true,
+	"JOIN":   true,
+	"UPDATE": true,
+}
+
+// notAliases are words which may follow a table name but are not an alias for it
+var notAliases = map[string]bool{
+	"CROSS": true, "DUAL": true, "FOR": true, "FORCE": true, "FROM": true,
+	"GROUP": true, "HAVING": true, "IGNORE": true, "INNER": true, "INTO": true,
+	"JOIN": true, "LEFT": true, "LIMIT": true, "LOCK": true, "NATURAL": true,
+	"ON": true, "ORDER": true, "OUTER": true, "PARTITION": true, "RIGHT": true,
+	"SELECT": true, "SET": true, "STRAIGHT_JOIN": true, "UNION": true, "USE": true,
+	"USING": true, "VALUE": true, "VALUES": true, "WHERE": true, "WINDOW": true,
+}
+
+var (
+	reDigestToken = regexp.MustCompile("`[^`]*`|[A-Za-z0-9_$@?]+|[^\\sA-Za-z0-9_$@?`]")
+	reIdentifier  = regexp.MustCompile(`^(?:` + "`[^`]+`" + `|[A-Za-z_$][A-Za-z0-9_$]*)$`)
+)
+
+// isIdentifier returns true if the token can be the name of a table
+func isIdentifier(token string) bool {
+	return reIdentifier.MatchString(token) && !notAliases[strings.ToUpper(token)]
+}
+
+// unquote removes the backticks around an identifier
+func unquote(token string) string {
+	return strings.Trim(token, "`")
+}
+
+// tableReference reads the table name starting at tokens[i] and any
+// alias after it, returning the name and the position of the next token.
+// The name is empty if tokens[i] does not name a table.
+func tableReference(schema string, tokens []string, i int) (string, int) {
+	if i >= len(tokens) || !isIdentifier(tokens[i]) {
+		return "", i
+	}
+	table := unquote(tokens[i])
+	i++
+	if i+1 < len(tokens) && tokens[i] == "." && isIdentifier(tokens[i+1]) {
+		schema, table = table, unquote(tokens[i+1])
+		i += 2
+	}
+
+	// skip any alias
+	if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "AS" {
+		i += 2
+	} else if i < len(tokens) && isIdentifier(tokens[i]) {
+		i++
+	}
+
+	return lib.TableName(schema, table), i
+}
+
+// digestTables returns the tables used by a statement, found by looking
+// for the names which follow FROM, JOIN, UPDATE and INTO in its digest
+// text. Unqualified names are taken to be in the statement's default
+// schema. Derived tables and views are not looked into.
+func digestTables(schema, text string) []string {
+	var tables []string
+	tokens := reDigestToken.FindAllString(text, -1)
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(tokens); i++ {
+		if !tableKeywords[strings.ToUpper(tokens[i])] {
+			continue
+		}
+		// comma separated lists of tables, e.g. FROM a, b or UPDATE a, b
+		for next := i + 1; ; next++ {
+			var name string
+			if name, next = tableReference(schema, tokens, next); name == "" {
+				break
+			}
+			if !seen[name] {
+				seen[name] = true
+				tables = append(tables, name)
+			}
+			i = next - 1
+			if next >= len(tokens) || tokens[next] != "," {
+				break
+			}
+		}
+	}
+
+	return tables
+}
+
+// byTable returns the values of the statements added up for each table
+// they use. A statement which uses several tables counts towards each one.
+func (rows Rows) byTable() Rows {
+	var t Rows
+	index := make(map[string]int)
+
+	for i := range rows {
+		for _, table := range rows[i].tables {
+			j, found := index[table]
+			if !found {
+				j = len(t)
+				index[table] = j
+				t = append(t, Row{name: table, text: table})
+			}
+			t[j].add(rows[i])
+		}
+	}
+
+	return t
+}
+
+// sortByTable sorts the table rows by latency (descending) but also by
+// "name" (ascending) if the values are the same after any configured sort keys
+func (rows Rows) sortByTable() {
+	sort.Slice(rows, rows.sortKeys().Less("statement_efficiency", "latency", "name"))
+}
+
+// tableHeadings returns the headings used when showing latency by table
+func (row *Row) tableHeadings() string {
+	return fmt.Sprintf("%10s %6s %10s %6s|%10s %10s %10s %10s|%s",
+		"Latency", "%", "CPU", "CPU%", "Execs", "Examined", "Sent", "Affected", "Table Name")
+}
+
+// generate a printable result of the statement latency of a table
+func (row *Row) tableRowContent(totals Row) string {
+	name := row.name
+	if row.countStar == 0 && name != "Totals" {
+		name = ""
+	}
+
+	return fmt.Sprintf("%10s %6s %10s %6s|%10s %10s %10s %10s|%s",
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
+		lib.FormatTime(row.sumCPUTime),
+		row.cpuPct(),
+		lib.FormatAmount(row.countStar),
+		lib.FormatAmount(row.rowsExamined),
+		lib.FormatAmount(row.rowsSent),
+		lib.FormatAmount(row.rowsAffected),
+		name)
+}
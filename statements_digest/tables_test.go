@@ -0,0 +1,52 @@
+package statements_digest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/anonymiser"
+)
+
+func TestDigestTables(t *testing.T) {
+	anonymiser.Enable(false)
+
+	tests := []struct {
+		schema string
+		text   string
+		want   []string
+	}{
+		{"shop", "SELECT * FROM `orders` WHERE `id` = ?", []string{"shop.orders"}},
+		{"shop", "SELECT `o` . `id` FROM `orders` `o` JOIN `stock` . `items` AS `i` ON `o` . `id` = `i` . `order_id`", []string{"shop.orders", "stock.items"}},
+		{"shop", "SELECT * FROM `orders` , `customers` WHERE `id` = ?", []string{"shop.orders", "shop.customers"}},
+		{"shop", "INSERT INTO `orders` ( `id` , `total` ) VALUES (...)", []string{"shop.orders"}},
+		{"shop", "UPDATE `orders` SET `total` = ? WHERE `id` = ?", []string{"shop.orders"}},
+		{"shop", "DELETE FROM `orders` WHERE `id` IN ( SELECT `id` FROM `old_orders` )", []string{"shop.orders", "shop.old_orders"}},
+		{"", "SELECT * FROM `shop` . `orders` LEFT JOIN `shop` . `orders` ON ?", []string{"shop.orders"}},
+		{"shop", "SELECT * FROM ( SELECT ? ) `derived`", nil},
+		{"shop", "SELECT ? FROM DUAL", nil},
+		{"shop", "SELECT `id` INTO @x FROM orders", []string{"shop.orders"}},
+		{"", "SET NAMES ?", nil},
+	}
+
+	for _, test := range tests {
+		if got := digestTables(test.schema, test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("digestTables(%q, %q) = %q, want %q", test.schema, test.text, got, test.want)
+		}
+	}
+}
+
+func TestByTableIncludesStatementsExaminingNoRows(t *testing.T) {
+	rows := Rows{
+		{name: "select", countStar: 3, rowsExamined: 30, tables: []string{"shop.orders"}},
+		{name: "insert", countStar: 5, rowsAffected: 5, tables: []string{"shop.orders"}},
+	}
+
+	byTable := rows.byTable()
+	if len(byTable) != 1 || byTable[0].countStar != 8 || byTable[0].rowsAffected != 5 {
+		t.Errorf("byTable() expected the 8 executions of both statements in shop.orders but got %+v", byTable)
+	}
+
+	if examined := rows.examined(); len(examined) != 1 || examined[0].name != "select" {
+		t.Errorf("examined() expected only the select but got %+v", examined)
+	}
+}
@@ -0,0 +1,105 @@
+// Package status_watch keeps a small watch list of global status
+// variables configured in ~/.pstoprc, e.g.
+// [status_watch]
+// variables = Threads_running,Innodb_row_lock_waits
+// which are shown with their change since the last collection in a
+// panel under the header of every view.
+package status_watch
+
+import (
+	"strings"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// Watch holds the values of the status variables watched
+type Watch struct {
+	names    []string            // names of the variables as configured, in order
+	previous global.StatusValues // values of the collection before the last
+	current  global.StatusValues // values of the last collection
+}
+
+// parseNames splits a comma separated list of variable names
+func parseNames(list string) []string {
+	var names []string
+
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// NewWatch returns a Watch of the variables configured in ~/.pstoprc
+func NewWatch() *Watch {
+	w := &Watch{names: parseNames(rc.Section("status_watch")["variables"])}
+	logger.Println("status_watch.NewWatch() watching", len(w.names), "status variable(s)")
+
+	return w
+}
+
+// Enabled returns true if any variables are watched
+func (w *Watch) Enabled() bool {
+	return len(w.names) > 0
+}
+
+// Collect collects the current values of the variables watched. If they
+// can't be collected the values collected before are kept.
+func (w *Watch) Collect(status *global.Status) error {
+	if !w.Enabled() {
+		return nil
+	}
+	values, err := status.Values(w.names...)
+	if err != nil {
+		return err
+	}
+
+	// the names are matched as prefixes so keep only those wanted
+	w.previous, w.current = w.current, make(global.StatusValues)
+	for _, name := range w.names {
+		if value, ok := values[strings.ToLower(name)]; ok {
+			w.current[strings.ToLower(name)] = value
+		}
+	}
+
+	return nil
+}
+
+// amount formats a value without padding, which unlike
+// lib.FormatAmount shows 0
+func amount(value uint64) string {
+	if value == 0 {
+		return "0"
+	}
+	return strings.TrimSpace(lib.FormatAmount(value))
+}
+
+// Items returns each variable watched with its value and its change
+// since the previous collection, e.g. "Threads_running 12 (+3)"
+func (w *Watch) Items() []string {
+	items := make([]string, 0, len(w.names))
+
+	for _, name := range w.names {
+		value, ok := w.current[strings.ToLower(name)]
+		if !ok {
+			items = append(items, name+" ?")
+			continue
+		}
+		item := name + " " + amount(value)
+		if previous, ok := w.previous[strings.ToLower(name)]; ok {
+			if value >= previous {
+				item += " (+" + amount(value-previous) + ")"
+			} else {
+				item += " (-" + amount(previous-value) + ")"
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
@@ -0,0 +1,30 @@
+package status_watch
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/ps-top/global"
+)
+
+func TestParseNames(t *testing.T) {
+	got := parseNames(" Threads_running, ,Innodb_row_lock_waits ")
+	want := []string{"Threads_running", "Innodb_row_lock_waits"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNames() = %q, want %q", got, want)
+	}
+}
+
+func TestItems(t *testing.T) {
+	w := &Watch{
+		names:    []string{"Threads_running", "Innodb_row_lock_waits", "Questions", "Unknown"},
+		previous: global.StatusValues{"threads_running": 12, "innodb_row_lock_waits": 40},
+		current:  global.StatusValues{"threads_running": 9, "innodb_row_lock_waits": 47, "questions": 2000},
+	}
+
+	got := w.Items()
+	want := []string{"Threads_running 9 (-3)", "Innodb_row_lock_waits 47 (+7)", "Questions 1.95 k", "Unknown ?"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Items() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package table
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Errors which tell us a column can't be SELECTed. We only match on the error number.
+// Error 1142: SELECT command denied to user 'myuser'@'10.11.12.13' for table 'file_summary_by_instance'
+// Error 1143: SELECT command denied to user 'myuser'@'10.11.12.13' for column 'SUM_TIMER_MISC' in table 'file_summary_by_instance'
+// Error 1054: Unknown column 'SUM_NO_GOOD_INDEX_USED' in 'field list' (e.g. TiDB's partial performance_schema)
+var columnDeniedErrors = []string{
+	"Error 1142:",
+	"Error 1143:",
+	"Error 1054:",
+}
+
+// Columns records which columns of a table can be SELECTed. This
+// allows a view to select only those columns it has access to, e.g.
+// when column level grants are used, rather than failing completely.
+// Each collector keeps its own, which is checked once.
+type Columns struct {
+	table      string
+	columns    []string
+	selectable map[string]bool // nil until checked
+}
+
+// NewColumns returns the columns of the table to check
+func NewColumns(table string, columns ...string) *Columns {
+	return &Columns{table: table, columns: columns}
+}
+
+// isColumnDenied returns true if the error says we can't SELECT the column
+func isColumnDenied(err error) bool {
+	for i := range columnDeniedErrors {
+		if strings.HasPrefix(err.Error(), columnDeniedErrors[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// canSelect returns true if the given expression can be selected from
+// the table, or an error if that can't be checked
+func canSelect(dbh *sql.DB, table, expression string) (bool, error) {
+	rows, err := dbh.Query("SELECT " + expression + " FROM " + table + " LIMIT 0")
+	if err != nil {
+		if isColumnDenied(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to check access to the columns of %s: %w", table, err)
+	}
+	rows.Close()
+
+	return true, nil
+}
+
+// Check checks which of the columns of the table can be SELECTed. The
+// result is kept so the check is only made once, unless it fails.
+func (c *Columns) Check(dbh *sql.DB) error {
+	if c.selectable != nil {
+		return nil
+	}
+
+	selectable := make(map[string]bool)
+	all, err := canSelect(dbh, c.table, strings.Join(c.columns, ", "))
+	if err != nil {
+		return err
+	}
+	for i := range c.columns {
+		if all {
+			selectable[c.columns[i]] = true
+			continue
+		}
+		if selectable[c.columns[i]], err = canSelect(dbh, c.table, c.columns[i]); err != nil {
+			return err
+		}
+		if !selectable[c.columns[i]] {
+			logger.Println("table.Columns.Check():", c.table+"."+c.columns[i], "is not SELECTable, hiding it")
+		}
+	}
+	c.selectable = selectable
+
+	return nil
+}
+
+// Selectable returns whether the column can be SELECTed.
+// If we haven't checked yet assume it can be.
+func (c *Columns) Selectable(column string) bool {
+	if c == nil || c.selectable == nil {
+		return true
+	}
+	return c.selectable[column]
+}
+
+// Select returns the column to use in a SELECT list. If we can't
+// SELECT the column 0 is used instead so the query returns the same
+// number of columns.
+func (c *Columns) Select(column string) string {
+	if c.Selectable(column) {
+		return column
+	}
+	return "0"
+}
+
+// Show returns the value if the column it comes from can be SELECTed
+// and an empty string if not, so the column is hidden in the display.
+func (c *Columns) Show(column, value string) string {
+	if c.Selectable(column) {
+		return value
+	}
+	return ""
+}
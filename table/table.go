@@ -12,6 +12,8 @@ import (
 type Access struct {
 	database            string
 	table               string
+	query               string   // used instead of a table if given
+	also                []Access // other tables which must also be SELECTable
 	checkedSelectError  bool
 	selectError         error
 	checkedConfigurable bool
@@ -24,6 +26,20 @@ func NewAccess(database, table string) Access {
 	return Access{database: database, table: table}
 }
 
+// NewQueryAccess returns a new Access type for the results of a SELECT query
+func NewQueryAccess(query string) Access {
+	logger.Println("NewQueryAccess(", query, ")")
+	return Access{query: query}
+}
+
+// Also returns the access with the given table added to those which must
+// also be SELECTable, e.g. a table the view's query joins which needs a
+// different privilege
+func (ta Access) Also(database, table string) Access {
+	ta.also = append(append([]Access(nil), ta.also...), NewAccess(database, table))
+	return ta
+}
+
 // Database returns the database name
 func (ta Access) Database() string {
 	return ta.database
@@ -34,14 +50,25 @@ func (ta Access) Table() string {
 	return ta.table
 }
 
-// Name returns the fully qualified table name
+// Name returns the fully qualified table name or the query
+// as a derived table if we are checking a query
 func (ta Access) Name() string {
 	if len(ta.database) > 0 && len(ta.table) > 0 {
 		return ta.database + "." + ta.table
 	}
+	if len(ta.query) > 0 {
+		return "(" + ta.query + ") AS q"
+	}
 	return ""
 }
 
+// SetUnavailable records that the table can't be SELECTed without
+// checking, e.g. as there's no connection to the server it is on
+func (ta *Access) SetUnavailable(err error) {
+	ta.selectError = err
+	ta.checkedSelectError = true
+}
+
 // SelectError returns whether SELECT works on the table
 func (ta *Access) CheckSelectError(dbh *sql.DB) error {
 	// return cached result if we have one
@@ -60,6 +87,12 @@ func (ta *Access) CheckSelectError(dbh *sql.DB) error {
 	default:
 		ta.selectError = nil // select worked
 	}
+	for i := range ta.also {
+		if ta.selectError != nil {
+			break
+		}
+		ta.selectError = ta.also[i].CheckSelectError(dbh)
+	}
 	ta.checkedSelectError = true
 
 	return ta.selectError
@@ -0,0 +1,204 @@
+// Package table_cache contains the library routines for combining the
+// table cache and handler status counters with performance_schema.table_handles.
+package table_cache
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// statusPrefixes are the global status variables we collect
+var statusPrefixes = []string{"Table_open_cache_", "Opened_tables", "Open_tables", "Handler_"}
+
+// a status variable to show, and the group used to calculate its percentage
+type statusVariable struct {
+	name  string
+	group string
+	gauge bool // the current value is shown rather than the change
+}
+
+// statusVariables are shown in this order (if the server provides them)
+var statusVariables = []statusVariable{
+	{"Table_open_cache_hits", "cache", false},
+	{"Table_open_cache_misses", "cache", false},
+	{"Table_open_cache_overflows", "cache", false},
+	{"Opened_tables", "opened", false},
+	{"Open_tables", "open", true},
+	{"Handler_read_first", "read", false},
+	{"Handler_read_key", "read", false},
+	{"Handler_read_last", "read", false},
+	{"Handler_read_next", "read", false},
+	{"Handler_read_prev", "read", false},
+	{"Handler_read_rnd", "read", false},
+	{"Handler_read_rnd_next", "read", false},
+	{"Handler_write", "write", false},
+	{"Handler_update", "write", false},
+	{"Handler_delete", "write", false},
+}
+
+const handlesGroup = "handles" // group of the table_handles rows
+
+/*
+
+CREATE TABLE `table_handles` (
+  `OBJECT_TYPE` varchar(64) NOT NULL,
+  `OBJECT_SCHEMA` varchar(64) NOT NULL,
+  `OBJECT_NAME` varchar(64) NOT NULL,
+  `OBJECT_INSTANCE_BEGIN` bigint(20) unsigned NOT NULL,
+  `OWNER_THREAD_ID` bigint(20) unsigned DEFAULT NULL,
+  `OWNER_EVENT_ID` bigint(20) unsigned DEFAULT NULL,
+  `INTERNAL_LOCK` varchar(64) DEFAULT NULL,
+  `EXTERNAL_LOCK` varchar(64) DEFAULT NULL
+) ENGINE=PERFORMANCE_SCHEMA DEFAULT CHARSET=utf8
+
+*/
+
+// Row contains a status counter or the open handles of a table
+type Row struct {
+	name   string
+	group  string // rows in the same group are compared to calculate percentages
+	value  uint64 // the change in a counter, or the current value of a gauge
+	locked uint64 // handles with an internal or external lock (table rows only)
+	rate   bool   // show the value per second
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// select the open table handles grouped by table
+func selectHandleRows(dbh *sql.DB, schemas *schema_filter.Filter) (Rows, error) {
+	var t Rows
+
+	sql := `
+SELECT	OBJECT_SCHEMA,
+	OBJECT_NAME,
+	COUNT(*),
+	SUM(INTERNAL_LOCK IS NOT NULL OR EXTERNAL_LOCK IS NOT NULL)
+FROM	table_handles
+WHERE	OBJECT_TYPE = 'TABLE'`
+	condition, args := schemas.And("OBJECT_SCHEMA", "OBJECT_NAME")
+	sql += condition + "\nGROUP BY OBJECT_SCHEMA, OBJECT_NAME"
+
+	rows, err := dbh.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table string
+		r := Row{group: handlesGroup}
+
+		if err := rows.Scan(&schema, &table, &r.value, &r.locked); err != nil {
+			return nil, err
+		}
+		r.name = lib.TableName(schema, table)
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("table_cache.selectHandleRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// statusRows returns the status variables we show in their fixed order
+func statusRows(current, changed global.StatusValues) Rows {
+	var t Rows
+
+	for _, v := range statusVariables {
+		key := strings.ToLower(v.name)
+		if _, found := current[key]; !found {
+			continue
+		}
+		r := Row{name: v.name, group: v.group, rate: !v.gauge}
+		if v.gauge {
+			r.value = current[key]
+		} else {
+			r.value = changed[key]
+		}
+		t = append(t, r)
+	}
+
+	return t
+}
+
+// gauges are the lower-cased names of the variables whose current value
+// is shown, which may go down at any time
+var gauges = func() map[string]bool {
+	g := make(map[string]bool)
+	for _, v := range statusVariables {
+		if v.gauge {
+			g[strings.ToLower(v.name)] = true
+		}
+	}
+	return g
+}()
+
+// groupTotals returns the sum of the values in each group
+func (rows Rows) groupTotals() map[string]uint64 {
+	totals := make(map[string]uint64)
+
+	for i := range rows {
+		totals[rows[i].group] += rows[i].value
+	}
+
+	return totals
+}
+
+// sortKeys returns the keys the table rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"handles": func(i, j int) int { return sort_keys.Descending(rows[i].value, rows[j].value) },
+		"locked":  func(i, j int) int { return sort_keys.Descending(rows[i].locked, rows[j].locked) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort table rows by handles (descending) but also by "name" (ascending) if the values
+// are the same after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("table_cache", "handles", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("table_cache", Rows(nil).sortKeys())
+}
+
+// headings returns the headings of the value, rate, percentage and name columns
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %10s %6s|%s", "Value", "Rate/s", "%", "Name")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals map[string]uint64, seconds float64) string {
+	rate := ""
+	if row.rate && seconds > 0 {
+		rate = lib.FormatAmount(uint64(float64(row.value)/seconds + 0.5))
+	}
+	name := row.name
+	if row.locked > 0 {
+		name += fmt.Sprintf(" (%d locked)", row.locked)
+	}
+
+	return fmt.Sprintf("%10s %10s %6s|%s",
+		lib.FormatAmount(row.value),
+		rate,
+		lib.FormatPct(lib.MyDivide(row.value, totals[row.group])),
+		name)
+}
+
+// String describes a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%10d %10d %s", row.value, row.locked, row.name)
+}
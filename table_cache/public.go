@@ -0,0 +1,167 @@
+// Package table_cache contains the library routines for combining the
+// table cache and handler status counters with performance_schema.table_handles.
+package table_cache
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/global"
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the status counters and table handles
+type Object struct {
+	baseobject.BaseObject                     // embedded
+	initial               global.StatusValues // initial status values for relative values
+	current               global.StatusValues // last loaded status values
+	handles               Rows                // open handles by table
+	results               Rows                // status rows followed by table rows
+	totals                Row                 // total open handles
+}
+
+// NewTableCache returns a pointer to an object of this type
+func NewTableCache(ctx *context.Context) *Object {
+	logger.Println("NewTableCache()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = make(global.StatusValues)
+	for name, value := range t.current {
+		t.initial[name] = value
+	}
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// Collect collects the status counters and table handles, updating
+// initial values if needed and generating the results.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	values, err := t.Status().Values(statusPrefixes...)
+	if err != nil {
+		return err
+	}
+	rows, err := selectHandleRows(dbh, t.SchemaFilter())
+	if err != nil {
+		return err
+	}
+	t.current = values
+	t.handles = rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "status value(s) and", len(t.handles), "table(s)")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.current.NeedsRefresh(t.initial, gauges) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("table_cache.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	changed := t.current
+	if t.WantRelativeStats() {
+		changed = t.current.Subtract(t.initial)
+	}
+
+	handles := make(Rows, len(t.handles))
+	copy(handles, t.handles)
+	handles.sort()
+
+	t.results = append(statusRows(t.current, changed), handles...)
+
+	t.totals = Row{name: "Open table handles", group: handlesGroup}
+	for i := range handles {
+		t.totals.value += handles[i].value
+		t.totals.locked += handles[i].locked
+	}
+}
+
+// seconds returns the period over which the counters have been collected
+func (t Object) seconds() float64 {
+	if t.WantRelativeStats() {
+		return t.LastCollectTime().Sub(t.InitialCollectTime()).Seconds()
+	}
+	return float64(t.Status().Get("Uptime"))
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// Headings returns the headings of the object
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+	totals := t.results.groupTotals()
+	seconds := t.seconds()
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(totals, seconds))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(nil, 0)
+}
+
+// TotalRowContent returns a row containing the total open handles
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(map[string]uint64{handlesGroup: t.totals.value}, 0)
+}
+
+// Description returns the table cache hit ratio
+func (t Object) Description() string {
+	changed := t.current
+	if t.WantRelativeStats() {
+		changed = t.current.Subtract(t.initial)
+	}
+	hits := changed["table_open_cache_hits"]
+	misses := changed["table_open_cache_misses"]
+
+	return fmt.Sprintf("Table Cache and Handlers (global_status, table_handles) hit ratio: %s",
+		strings.TrimSpace(lib.FormatPct(lib.MyDivide(hits, hits+misses))))
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
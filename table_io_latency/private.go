@@ -5,11 +5,16 @@ package table_io_latency
 import (
 	"database/sql"
 	"fmt"
-	"log"
 	"sort"
 	"strings"
 
+	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
+	"github.com/sjmudd/ps-top/table"
 )
 
 // Row contains w from table_io_waits_summary_by_table
@@ -17,7 +22,8 @@ type Row struct {
 	// Note: upper case names to match the performance_schema column names
 	// This type is _not_ exported.
 
-	name string // we don't keep the retrieved columns but store the generated table name
+	name      string // we don't keep the retrieved columns but store the generated table name
+	temporary bool   // is it a temporary table (OBJECT_TYPE = 'TEMPORARY TABLE')?
 
 	sumTimerWait   uint64
 	sumTimerRead   uint64
@@ -39,49 +45,118 @@ type Row struct {
 // Rows contains a set of rows
 type Rows []Row
 
-// latencyHeadings returns the latency headings as a string
-func (row Row) latencyHeadings() string {
-	return fmt.Sprintf("%10s %6s|%6s %6s %6s %6s|%s", "Latency", "%", "Fetch", "Insert", "Update", "Delete", "Table Name")
+// temporarySuffix is added to the names of temporary tables so they
+// aren't mixed up with a table of the same name
+const temporarySuffix = " (temporary)"
+
+// optionalColumns may be hidden if the user can't SELECT them
+var optionalColumns = []string{
+	"COUNT_READ", "SUM_TIMER_READ", "COUNT_WRITE", "SUM_TIMER_WRITE",
+	"COUNT_FETCH", "SUM_TIMER_FETCH", "COUNT_INSERT", "SUM_TIMER_INSERT",
+	"COUNT_UPDATE", "SUM_TIMER_UPDATE", "COUNT_DELETE", "SUM_TIMER_DELETE",
+}
+
+// latencyHeadings returns the latency headings as a string, with the
+// latency of each operation rather than its percentage if wanted
+func (row Row) latencyHeadings(columns *table.Columns, opLatency bool) string {
+	format := "%10s %6s|%6s %6s %6s %6s|%s"
+	if opLatency {
+		format = "%10s %6s|%10s %10s %10s %10s|%s"
+	}
+
+	return fmt.Sprintf(format, "Latency", "%",
+		columns.Show("SUM_TIMER_FETCH", "Fetch"),
+		columns.Show("SUM_TIMER_INSERT", "Insert"),
+		columns.Show("SUM_TIMER_UPDATE", "Update"),
+		columns.Show("SUM_TIMER_DELETE", "Delete"),
+		"Table Name")
 }
 
-// opsHeadings returns the headings by operations as a string
-func (row Row) opsHeadings() string {
-	return fmt.Sprintf("%10s %6s|%6s %6s %6s %6s|%s", "Ops", "%", "Fetch", "Insert", "Update", "Delete", "Table Name")
+// opsHeadings returns the headings by operations as a string, with the
+// average latency of each operation rather than its percentage if wanted
+func (row Row) opsHeadings(columns *table.Columns, opLatency bool) string {
+	if opLatency {
+		return fmt.Sprintf("%10s %6s|%10s %10s %10s %10s|%s", "Ops", "%",
+			columns.Show("SUM_TIMER_FETCH", "Avg Fetch"),
+			columns.Show("SUM_TIMER_INSERT", "Avg Insert"),
+			columns.Show("SUM_TIMER_UPDATE", "Avg Update"),
+			columns.Show("SUM_TIMER_DELETE", "Avg Delete"),
+			"Table Name")
+	}
+
+	return fmt.Sprintf("%10s %6s|%6s %6s %6s %6s|%s", "Ops", "%",
+		columns.Show("COUNT_FETCH", "Fetch"),
+		columns.Show("COUNT_INSERT", "Insert"),
+		columns.Show("COUNT_UPDATE", "Update"),
+		columns.Show("COUNT_DELETE", "Delete"),
+		"Table Name")
 }
 
 // latencyRowContents reutrns the printable result
-func (row Row) latencyRowContent(totals Row) string {
+func (row Row) latencyRowContent(totals Row, columns *table.Columns, opLatency bool) string {
 	// assume the data is empty so hide it.
 	name := row.name
 	if row.countStar == 0 && name != "Totals" {
 		name = ""
 	}
 
+	if opLatency {
+		return fmt.Sprintf("%10s %6s|%10s %10s %10s %10s|%s",
+			lib.FormatTime(row.sumTimerWait),
+			lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
+			columns.Show("SUM_TIMER_FETCH", lib.FormatTime(row.sumTimerFetch)),
+			columns.Show("SUM_TIMER_INSERT", lib.FormatTime(row.sumTimerInsert)),
+			columns.Show("SUM_TIMER_UPDATE", lib.FormatTime(row.sumTimerUpdate)),
+			columns.Show("SUM_TIMER_DELETE", lib.FormatTime(row.sumTimerDelete)),
+			name)
+	}
+
 	return fmt.Sprintf("%10s %6s|%6s %6s %6s %6s|%s",
 		lib.FormatTime(row.sumTimerWait),
 		lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerFetch, row.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerInsert, row.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerUpdate, row.sumTimerWait)),
-		lib.FormatPct(lib.MyDivide(row.sumTimerDelete, row.sumTimerWait)),
+		columns.Show("SUM_TIMER_FETCH", lib.FormatPct(lib.MyDivide(row.sumTimerFetch, row.sumTimerWait))),
+		columns.Show("SUM_TIMER_INSERT", lib.FormatPct(lib.MyDivide(row.sumTimerInsert, row.sumTimerWait))),
+		columns.Show("SUM_TIMER_UPDATE", lib.FormatPct(lib.MyDivide(row.sumTimerUpdate, row.sumTimerWait))),
+		columns.Show("SUM_TIMER_DELETE", lib.FormatPct(lib.MyDivide(row.sumTimerDelete, row.sumTimerWait))),
 		name)
 }
 
+// average returns the average latency of count operations taking
+// sumTimer in total, or nothing if there were none
+func average(sumTimer, count uint64) string {
+	if count == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(sumTimer / count)
+}
+
 // generate a printable result for ops
-func (row Row) opsRowContent(totals Row) string {
+func (row Row) opsRowContent(totals Row, columns *table.Columns, opLatency bool) string {
 	// assume the data is empty so hide it.
 	name := row.name
 	if row.countStar == 0 && name != "Totals" {
 		name = ""
 	}
 
+	if opLatency {
+		return fmt.Sprintf("%10s %6s|%10s %10s %10s %10s|%s",
+			lib.FormatAmount(row.countStar),
+			lib.FormatPct(lib.MyDivide(row.countStar, totals.countStar)),
+			columns.Show("SUM_TIMER_FETCH", average(row.sumTimerFetch, row.countFetch)),
+			columns.Show("SUM_TIMER_INSERT", average(row.sumTimerInsert, row.countInsert)),
+			columns.Show("SUM_TIMER_UPDATE", average(row.sumTimerUpdate, row.countUpdate)),
+			columns.Show("SUM_TIMER_DELETE", average(row.sumTimerDelete, row.countDelete)),
+			name)
+	}
+
 	return fmt.Sprintf("%10s %6s|%6s %6s %6s %6s|%s",
 		lib.FormatAmount(row.countStar),
 		lib.FormatPct(lib.MyDivide(row.countStar, totals.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countFetch, row.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countInsert, row.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countUpdate, row.countStar)),
-		lib.FormatPct(lib.MyDivide(row.countDelete, row.countStar)),
+		columns.Show("COUNT_FETCH", lib.FormatPct(lib.MyDivide(row.countFetch, row.countStar))),
+		columns.Show("COUNT_INSERT", lib.FormatPct(lib.MyDivide(row.countInsert, row.countStar))),
+		columns.Show("COUNT_UPDATE", lib.FormatPct(lib.MyDivide(row.countUpdate, row.countStar))),
+		columns.Show("COUNT_DELETE", lib.FormatPct(lib.MyDivide(row.countDelete, row.countStar))),
 		name)
 }
 
@@ -133,22 +208,35 @@ func (rows Rows) totals() Row {
 	return totals
 }
 
-func selectRows(dbh *sql.DB) Rows {
-	var t Rows
+// selectRows returns the rows of tables with activity, reusing the
+// space of t for them
+func selectRows(dbh *sql.DB, schemas *schema_filter.Filter, columns *table.Columns, names *lib.NameCache, t Rows) (Rows, error) {
+	t = t[:0]
+
+	// only select the optional columns we have access to
+	if err := columns.Check(dbh); err != nil {
+		return nil, err
+	}
+	selected := make([]string, len(optionalColumns))
+	for i := range optionalColumns {
+		selected[i] = columns.Select(optionalColumns[i])
+	}
 
 	// we collect all information even if it's mainly empty as we may reference it later
-	sql := "SELECT OBJECT_SCHEMA, OBJECT_NAME, COUNT_STAR, SUM_TIMER_WAIT, COUNT_READ, SUM_TIMER_READ, COUNT_WRITE, SUM_TIMER_WRITE, COUNT_FETCH, SUM_TIMER_FETCH, COUNT_INSERT, SUM_TIMER_INSERT, COUNT_UPDATE, SUM_TIMER_UPDATE, COUNT_DELETE, SUM_TIMER_DELETE FROM table_io_waits_summary_by_table WHERE SUM_TIMER_WAIT > 0"
+	query := "SELECT OBJECT_TYPE, OBJECT_SCHEMA, OBJECT_NAME, COUNT_STAR, SUM_TIMER_WAIT, " + strings.Join(selected, ", ") + " FROM table_io_waits_summary_by_table WHERE SUM_TIMER_WAIT > 0"
+	condition, args := schemas.And("OBJECT_SCHEMA", "OBJECT_NAME")
 
-	rows, err := dbh.Query(sql)
+	rows, err := dbh.Query(query+condition, args...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var schema, table string
+		var objectType, schema, table sql.RawBytes
 		var r Row
 		if err := rows.Scan(
+			&objectType,
 			&schema,
 			&table,
 			&r.countStar,
@@ -165,75 +253,104 @@ func selectRows(dbh *sql.DB) Rows {
 			&r.sumTimerUpdate,
 			&r.countDelete,
 			&r.sumTimerDelete); err != nil {
-			log.Fatal(err)
+			return nil, err
+		}
+		r.name = names.TableName(schema, table)
+		if string(objectType) == context.TemporaryTables {
+			r.temporary = true
+			r.name += temporarySuffix
 		}
-		r.name = lib.TableName(schema, table)
 
 		// we collect all information even if it's mainly empty as we may reference it later
 		t = append(t, r)
 	}
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return t
+	return t, nil
 }
 
-func (rows Rows) Len() int      { return len(rows) }
-func (rows Rows) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
+// ofType returns the rows of the type of tables wanted, reusing their space
+func (rows Rows) ofType(objectType string) Rows {
+	if objectType == context.AllTables {
+		return rows
+	}
+	wantTemporary := objectType == context.TemporaryTables
 
-// sort by value (descending) but also by "name" (ascending) if the values are the same
-func (rows Rows) Less(i, j int) bool {
-	return (rows[i].sumTimerWait > rows[j].sumTimerWait) ||
-		((rows[i].sumTimerWait == rows[j].sumTimerWait) &&
-			(rows[i].name < rows[j].name))
+	t := rows[:0]
+	for i := range rows {
+		if rows[i].temporary == wantTemporary {
+			t = append(t, rows[i])
+		}
+	}
+
+	return t
 }
 
-// ByOps is used for sorting by the number of operations
-type ByOps Rows
+// haveTemporary returns true if any of the rows are temporary tables
+func (rows Rows) haveTemporary() bool {
+	for i := range rows {
+		if rows[i].temporary {
+			return true
+		}
+	}
 
-func (rows ByOps) Len() int      { return len(rows) }
-func (rows ByOps) Swap(i, j int) { rows[i], rows[j] = rows[j], rows[i] }
-func (rows ByOps) Less(i, j int) bool {
-	return (rows[i].countStar > rows[j].countStar) ||
-		((rows[i].sumTimerWait == rows[j].sumTimerWait) &&
-			(rows[i].name < rows[j].name))
+	return false
 }
 
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"fetch":   func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerFetch, rows[j].sumTimerFetch) },
+		"insert":  func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerInsert, rows[j].sumTimerInsert) },
+		"update":  func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerUpdate, rows[j].sumTimerUpdate) },
+		"delete":  func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerDelete, rows[j].sumTimerDelete) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by latency or ops (descending) but also by "name" (ascending)
+// if the values are the same, after any configured sort keys
 func (rows Rows) sort(wantLatency bool) {
 	if wantLatency {
-		sort.Sort(rows)
+		sort.Slice(rows, rows.sortKeys().Less("table_io_latency", "latency", "name"))
 	} else {
-		sort.Sort(ByOps(rows))
+		sort.Slice(rows, rows.sortKeys().Less("table_io_ops", "ops", "name"))
 	}
 }
 
-// remove the initial values from those rows where there's a match
-// - if we find a row we can't match ignore it
-func (rows *Rows) subtract(initial Rows) {
-	initialByName := make(map[string]int)
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("table_io_latency", Rows(nil).sortKeys())
+	sort_keys.Register("table_io_ops", Rows(nil).sortKeys())
+}
 
-	// iterate over rows by name
-	for i := range initial {
-		initialByName[initial[i].name] = i
-	}
+// differ matches the rows by table name to subtract their initial values,
+// e.g. ignoring those of a table which was dropped and created again
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: (*Row).subtract,
+}
 
-	for i := range *rows {
-		rowName := (*rows)[i].name
-		if _, ok := initialByName[rowName]; ok {
-			initialIndex := initialByName[rowName]
-			(*rows)[i].subtract(initial[initialIndex])
-		}
-	}
+// byName returns the index of each row by name
+func (rows Rows) byName() map[string]int {
+	return differ.Index(rows)
+}
+
+// remove the initial values from those rows where there's a match
+func (rows *Rows) subtract(initial Rows, initialByName map[string]int) {
+	differ.SubtractIndexed(*rows, initial, initialByName)
 }
 
 // if the data in t2 is "newer", "has more values" than t then it needs refreshing.
-// check this by comparing totals.
+// check this by comparing the totals of the tables found in both so tables
+// which were dropped or renamed don't cause a refresh.
 func (rows Rows) needsRefresh(otherRows Rows) bool {
-	myTotals := rows.totals()
-	otherTotals := otherRows.totals()
-
-	return myTotals.sumTimerWait > otherTotals.sumTimerWait
+	return differ.NeedsRefresh(rows, otherRows)
 }
 
 // describe a whole row
@@ -269,3 +386,22 @@ func (rows Rows) String() string {
 
 	return strings.Join(s, "\n")
 }
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"sum_timer_wait":   row.sumTimerWait,
+			"sum_timer_fetch":  row.sumTimerFetch,
+			"sum_timer_insert": row.sumTimerInsert,
+			"sum_timer_update": row.sumTimerUpdate,
+			"sum_timer_delete": row.sumTimerDelete,
+			"count_star":       row.countStar,
+			"count_fetch":      row.countFetch,
+			"count_insert":     row.countInsert,
+			"count_update":     row.countUpdate,
+			"count_delete":     row.countDelete,
+		},
+	}
+}
@@ -9,18 +9,27 @@ import (
 
 	"github.com/sjmudd/ps-top/baseobject"
 	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/table"
 )
 
 // Object contains performance_schema.table_io_waits_summary_by_table data
 type Object struct {
 	baseobject.BaseObject
 	wantLatency bool
-	initial     Rows   // initial data for relative values
-	current     Rows   // last loaded values
-	results     Rows   // results (maybe with subtraction)
-	totals      Row    // totals of results
-	descStart   string // start of description
+	initial     Rows           // initial data for relative values
+	mark        Rows           // marked data for relative values since the mark
+	current     Rows           // last loaded values
+	spare       Rows           // the previous values, whose space the next collection reuses
+	results     Rows           // results (maybe with subtraction)
+	totals      Row            // totals of results
+	descStart   string         // start of description
+	byName      map[string]int // index of each initial row by name
+	markByName  map[string]int // index of each marked row by name
+	names       lib.NameCache  // names of the tables collected
+	columns     *table.Columns // which of the optional columns can be SELECTed
 }
 
 func NewTableIoLatency(ctx *context.Context) *Object {
@@ -29,23 +38,28 @@ func NewTableIoLatency(ctx *context.Context) *Object {
 	}
 	o := new(Object)
 	o.SetContext(ctx)
+	o.columns = table.NewColumns("table_io_waits_summary_by_table", optionalColumns...)
 
 	return o
 }
 
 func (t *Object) copyCurrentToInitial() {
-	t.initial = make(Rows, len(t.current))
+	t.initial = append(t.initial[:0], t.current...)
 	t.SetInitialCollectTime(t.LastCollectTime())
-	copy(t.initial, t.current)
+	t.byName = t.initial.byName()
 }
 
 // Collect collects data from the db, updating initial values
 // if needed, and then subtracting initial values if we want relative
 // values, after which it stores totals.
-func (t *Object) Collect(dbh *sql.DB) {
+func (t *Object) Collect(dbh *sql.DB) error {
 	start := time.Now()
 	// logger.Println("Object.Collect() BEGIN")
-	t.current = selectRows(dbh)
+	rows, err := selectRows(dbh, t.SchemaFilter(), t.columns, &t.names, t.spare)
+	if err != nil {
+		return err
+	}
+	t.spare, t.current = t.current, rows
 	t.SetLastCollectTimeNow()
 	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
 
@@ -59,6 +73,10 @@ func (t *Object) Collect(dbh *sql.DB) {
 		logger.Println("t.initial: copying from t.current (data needs refreshing)")
 		t.copyCurrentToInitial()
 	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
 
 	t.makeResults()
 
@@ -69,18 +87,23 @@ func (t *Object) Collect(dbh *sql.DB) {
 	// logger.Println("t.results:", t.results)
 	// logger.Println("t.totals:", t.totals)
 	logger.Println("Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
 }
 
 func (t *Object) makeResults() {
 	logger.Println("table_io_latency.makeResults()")
 	logger.Println("- HaveRelativeStats()", t.HaveRelativeStats())
 	logger.Println("- WantRelativeStats()", t.WantRelativeStats())
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
-	if t.WantRelativeStats() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		logger.Println("- subtracting t.mark from t.results as SinceMark()")
+		t.results.subtract(t.mark, t.markByName)
+	} else if t.WantRelativeStats() {
 		logger.Println("- subtracting t.initial from t.results as WantRelativeStats()")
-		t.results.subtract(t.initial)
+		t.results.subtract(t.initial, t.byName)
 	}
+	t.results = t.results.ofType(t.ObjectType())
 
 	// logger.Println( "- sorting t.results" )
 	t.results.sort(t.wantLatency)
@@ -99,15 +122,32 @@ func (t *Object) SetInitialFromCurrent() {
 	// logger.Println( "Object.SetInitialFromCurrent() END" )
 }
 
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.markByName = t.mark.byName()
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.markByName = nil
+	t.SetMarkCollectTime(time.Time{})
+}
+
 // Headings returns the headings for the table
 func (t Object) Headings() string {
 	var r Row
 
 	if t.wantLatency {
-		return r.latencyHeadings()
+		return r.latencyHeadings(t.columns, t.WantOpLatency())
 	}
 
-	return r.opsHeadings()
+	return r.opsHeadings(t.columns, t.WantOpLatency())
 }
 
 // RowContent returns the top maxRows data from the table
@@ -116,9 +156,9 @@ func (t Object) RowContent() []string {
 
 	for i := range t.results {
 		if t.wantLatency {
-			rows = append(rows, t.results[i].latencyRowContent(t.totals))
+			rows = append(rows, t.results[i].latencyRowContent(t.totals, t.columns, t.WantOpLatency()))
 		} else {
-			rows = append(rows, t.results[i].opsRowContent(t.totals))
+			rows = append(rows, t.results[i].opsRowContent(t.totals, t.columns, t.WantOpLatency()))
 		}
 	}
 
@@ -130,19 +170,19 @@ func (t Object) EmptyRowContent() string {
 	var r Row
 
 	if t.wantLatency {
-		return r.latencyRowContent(r)
+		return r.latencyRowContent(r, t.columns, t.WantOpLatency())
 	}
 
-	return r.opsRowContent(r)
+	return r.opsRowContent(r, t.columns, t.WantOpLatency())
 }
 
 // TotalRowContent returns a formated row containing totals data
 func (t Object) TotalRowContent() string {
 	if t.wantLatency {
-		return t.totals.latencyRowContent(t.totals)
+		return t.totals.latencyRowContent(t.totals, t.columns, t.WantOpLatency())
 	}
 
-	return t.totals.opsRowContent(t.totals)
+	return t.totals.opsRowContent(t.totals, t.columns, t.WantOpLatency())
 }
 
 // Description returns the description of the table as a string
@@ -154,7 +194,17 @@ func (t Object) Description() string {
 		}
 	}
 
-	return fmt.Sprintf("Table %s (table_io_waits_summary_by_table) %d rows", t.descStart, count)
+	description := fmt.Sprintf("Table %s (table_io_waits_summary_by_table) %d rows", t.descStart, count)
+	switch {
+	case t.ObjectType() == context.Tables:
+		description += " of tables (k: temporary tables)"
+	case t.ObjectType() == context.TemporaryTables:
+		description += " of temporary tables (k: all tables)"
+	case t.current.haveTemporary():
+		description += " incl. temporary tables (k: tables only)"
+	}
+
+	return description
 }
 
 // Len returns the length of the result set
@@ -176,3 +226,25 @@ func (t Object) WantsLatency() bool {
 func (t Object) HaveRelativeStats() bool {
 	return true
 }
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -6,11 +6,14 @@ import (
 	"database/sql"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql" // keep glint happy
-	"log"
 	"sort"
 	"strings"
 
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
 )
 
 /*
@@ -95,6 +98,8 @@ Create Table: CREATE TABLE `table_lock_waits_summary_by_table` (
 // Row holds a row of data from table_lock_waits_summary_by_table
 type Row struct {
 	name                          string // combination of <schema>.<table>
+	schema                        string
+	table                         string
 	sumTimerWait                  uint64
 	sumTimerRead                  uint64
 	sumTimerWrite                 uint64
@@ -110,15 +115,70 @@ type Row struct {
 	sumTimerWriteExternal         uint64
 }
 
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"OBJECT_SCHEMA":                     func(r *Row) interface{} { return &r.schema },
+	"OBJECT_NAME":                       func(r *Row) interface{} { return &r.table },
+	"SUM_TIMER_WAIT":                    func(r *Row) interface{} { return &r.sumTimerWait },
+	"SUM_TIMER_READ":                    func(r *Row) interface{} { return &r.sumTimerRead },
+	"SUM_TIMER_WRITE":                   func(r *Row) interface{} { return &r.sumTimerWrite },
+	"SUM_TIMER_READ_WITH_SHARED_LOCKS":  func(r *Row) interface{} { return &r.sumTimerReadWithSharedLocks },
+	"SUM_TIMER_READ_HIGH_PRIORITY":      func(r *Row) interface{} { return &r.sumTimerReadHighPriority },
+	"SUM_TIMER_READ_NO_INSERT":          func(r *Row) interface{} { return &r.sumTimerReadNoInsert },
+	"SUM_TIMER_READ_NORMAL":             func(r *Row) interface{} { return &r.sumTimerReadNormal },
+	"SUM_TIMER_READ_EXTERNAL":           func(r *Row) interface{} { return &r.sumTimerReadExternal },
+	"SUM_TIMER_WRITE_ALLOW_WRITE":       func(r *Row) interface{} { return &r.sumTimerWriteAllowWrite },
+	"SUM_TIMER_WRITE_CONCURRENT_INSERT": func(r *Row) interface{} { return &r.sumTimerWriteConcurrentInsert },
+	"SUM_TIMER_WRITE_LOW_PRIORITY":      func(r *Row) interface{} { return &r.sumTimerWriteLowPriority },
+	"SUM_TIMER_WRITE_NORMAL":            func(r *Row) interface{} { return &r.sumTimerWriteNormal },
+	"SUM_TIMER_WRITE_EXTERNAL":          func(r *Row) interface{} { return &r.sumTimerWriteExternal },
+}
+
 // Rows contains multiple rows
 type Rows []Row
 
-// Latency      %|  Read  Write|S.Lock   High  NoIns Normal Extrnl|AlloWr CncIns WrtDly    Low Normal Extrnl|
-// 1234567 100.0%|xxxxx% xxxxx%|xxxxx% xxxxx% xxxxx% xxxxx% xxxxx%|xxxxx% xxxxx% xxxxx% xxxxx% xxxxx% xxxxx%|xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
+// lockType is a type of read or write lock and a way of getting its latency
+type lockType struct {
+	name    string
+	latency func(r *Row) uint64
+}
+
+// lockTypes are the lock types in the order shown
+var lockTypes = []lockType{
+	{"R:S.Lock", func(r *Row) uint64 { return r.sumTimerReadWithSharedLocks }},
+	{"R:High", func(r *Row) uint64 { return r.sumTimerReadHighPriority }},
+	{"R:NoIns", func(r *Row) uint64 { return r.sumTimerReadNoInsert }},
+	{"R:Normal", func(r *Row) uint64 { return r.sumTimerReadNormal }},
+	{"R:Extrnl", func(r *Row) uint64 { return r.sumTimerReadExternal }},
+	{"W:AlloWr", func(r *Row) uint64 { return r.sumTimerWriteAllowWrite }},
+	{"W:CncIns", func(r *Row) uint64 { return r.sumTimerWriteConcurrentInsert }},
+	{"W:Low", func(r *Row) uint64 { return r.sumTimerWriteLowPriority }},
+	{"W:Normal", func(r *Row) uint64 { return r.sumTimerWriteNormal }},
+	{"W:Extrnl", func(r *Row) uint64 { return r.sumTimerWriteExternal }},
+}
+
+// dominantLockType returns the name of the lock type with the most latency,
+// or an empty string if there is none
+func (r *Row) dominantLockType() string {
+	var name string
+	var max uint64
+
+	for i := range lockTypes {
+		if latency := lockTypes[i].latency(r); latency > max {
+			name, max = lockTypes[i].name, latency
+		}
+	}
+
+	return name
+}
+
+// Latency      %|   Read      %      Write      %|Dominant|S.Lock   High  NoIns Normal Extrnl|AlloWr CncIns    Low Normal Extrnl|
+// 1234567 100.0%|1234567 xxxxx% 1234567 xxxxx%|W:Normal|xxxxx% xxxxx% xxxxx% xxxxx% xxxxx%|xxxxx% xxxxx% xxxxx% xxxxx% xxxxx%|xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx
 func (r *Row) headings() string {
-	return fmt.Sprintf("%10s %6s|%6s %6s|%6s %6s %6s %6s %6s|%6s %6s %6s %6s %6s|%-30s",
+	return fmt.Sprintf("%10s %6s|%10s %6s %10s %6s|%-8s|%6s %6s %6s %6s %6s|%6s %6s %6s %6s %6s|%-30s",
 		"Latency", "%",
-		"Read", "Write",
+		"Read", "%", "Write", "%",
+		"Dominant",
 		"S.Lock", "High", "NoIns", "Normal", "Extrnl",
 		"AlloWr", "CncIns", "Low", "Normal", "Extrnl",
 		"Table Name")
@@ -133,13 +193,17 @@ func (r *Row) rowContent(totals Row) string {
 		name = ""
 	}
 
-	return fmt.Sprintf("%10s %6s|%6s %6s|%6s %6s %6s %6s %6s|%6s %6s %6s %6s %6s|%s",
+	return fmt.Sprintf("%10s %6s|%10s %6s %10s %6s|%-8s|%6s %6s %6s %6s %6s|%6s %6s %6s %6s %6s|%s",
 		lib.FormatTime(r.sumTimerWait),
 		lib.FormatPct(lib.MyDivide(r.sumTimerWait, totals.sumTimerWait)),
 
+		lib.FormatTime(r.sumTimerRead),
 		lib.FormatPct(lib.MyDivide(r.sumTimerRead, r.sumTimerWait)),
+		lib.FormatTime(r.sumTimerWrite),
 		lib.FormatPct(lib.MyDivide(r.sumTimerWrite, r.sumTimerWait)),
 
+		r.dominantLockType(),
+
 		lib.FormatPct(lib.MyDivide(r.sumTimerReadWithSharedLocks, r.sumTimerWait)),
 		lib.FormatPct(lib.MyDivide(r.sumTimerReadHighPriority, r.sumTimerWait)),
 		lib.FormatPct(lib.MyDivide(r.sumTimerReadNoInsert, r.sumTimerWait)),
@@ -202,9 +266,7 @@ func (t Rows) totals() Row {
 // - filter out empty values
 // - merge rows with the same name into a single row
 // - change FILE_NAME into a more descriptive value.
-func selectRows(dbh *sql.DB) Rows {
-	var t Rows
-
+func selectRows(dbh *sql.DB, schemas *schema_filter.Filter) (Rows, error) {
 	sql := `
 SELECT	OBJECT_SCHEMA,
 	OBJECT_NAME,
@@ -223,85 +285,56 @@ SELECT	OBJECT_SCHEMA,
 	SUM_TIMER_WRITE_EXTERNAL
 FROM	table_lock_waits_summary_by_table
 WHERE	COUNT_STAR > 0`
+	condition, args := schemas.And("OBJECT_SCHEMA", "OBJECT_NAME")
 
-	rows, err := dbh.Query(sql)
+	t, err := lib.ReadRowsFromSQL(dbh, columns, sql+condition, args...)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var r Row
-		var schema, table string
-
-		if err := rows.Scan(
-			&schema,
-			&table,
-			&r.sumTimerWait,
-			&r.sumTimerRead,
-			&r.sumTimerWrite,
-			&r.sumTimerReadWithSharedLocks,
-			&r.sumTimerReadHighPriority,
-			&r.sumTimerReadNoInsert,
-			&r.sumTimerReadNormal,
-			&r.sumTimerReadExternal,
-			&r.sumTimerWriteAllowWrite,
-			&r.sumTimerWriteConcurrentInsert,
-			&r.sumTimerWriteLowPriority,
-			&r.sumTimerWriteNormal,
-			&r.sumTimerWriteExternal); err != nil {
-			log.Fatal(err)
-		}
-		r.name = lib.TableName(schema, table)
-		// we collect all data as we may need it later
-		t = append(t, r)
+		return nil, err
 	}
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+
+	for i := range t {
+		t[i].name = lib.TableName(t[i].schema, t[i].table)
 	}
 
-	return t
+	return t, nil
 }
 
-func (t Rows) Len() int      { return len(t) }
-func (t Rows) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
-func (t Rows) Less(i, j int) bool {
-	return (t[i].sumTimerWait > t[j].sumTimerWait) ||
-		((t[i].sumTimerWait == t[j].sumTimerWait) &&
-			(t[i].name < t[j].name))
-
+// sortKeys returns the keys the rows may be sorted by
+func (t Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(t[i].sumTimerWait, t[j].sumTimerWait) },
+		"read":    func(i, j int) int { return sort_keys.Descending(t[i].sumTimerRead, t[j].sumTimerRead) },
+		"write":   func(i, j int) int { return sort_keys.Descending(t[i].sumTimerWrite, t[j].sumTimerWrite) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(t[i].name, t[j].name) },
+	}
 }
 
-// sort the data
+// sort the data by latency (descending) and name after any configured sort keys
 func (t *Rows) sort() {
-	sort.Sort(t)
+	sort.Slice(*t, t.sortKeys().Less("table_lock_latency", "latency", "name"))
 }
 
-// remove the initial values from those rows where there's a match
-// - if we find a row we can't match ignore it
-func (t *Rows) subtract(initial Rows) {
-	iByName := make(map[string]int)
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("table_lock_latency", Rows(nil).sortKeys())
+}
 
-	// iterate over rows by name
-	for i := range initial {
-		iByName[initial[i].name] = i
-	}
+// differ matches the rows by table name to subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(r Row) string { return r.name },
+	Counters: func(r Row) []uint64 { return []uint64{r.sumTimerWait, r.sumTimerRead, r.sumTimerWrite} },
+	Subtract: (*Row).subtract,
+}
 
-	for i := range *t {
-		if _, ok := iByName[(*t)[i].name]; ok {
-			initialI := iByName[(*t)[i].name]
-			(*t)[i].subtract(initial[initialI])
-		}
-	}
+// remove the initial values from those rows where there's a match
+func (t *Rows) subtract(initial Rows) {
+	differ.SubtractRows(*t, initial)
 }
 
 // if the data in t2 is "newer", "has more values" than t then it needs refreshing.
-// check this by comparing totals.
+// check this by comparing the totals of the tables found in both.
 func (t Rows) needsRefresh(t2 Rows) bool {
-	myTotals := t.totals()
-	otherTotals := t2.totals()
-
-	return myTotals.sumTimerWait > otherTotals.sumTimerWait
+	return differ.NeedsRefresh(t, t2)
 }
 
 // describe a whole row
@@ -335,3 +368,15 @@ func (t Rows) String() string {
 
 	return strings.Join(s, "\n")
 }
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"sum_timer_wait":  row.sumTimerWait,
+			"sum_timer_read":  row.sumTimerRead,
+			"sum_timer_write": row.sumTimerWrite,
+		},
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/sjmudd/ps-top/baseobject"
 	"github.com/sjmudd/ps-top/context"
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
 )
 
 const (
@@ -19,6 +20,7 @@ const (
 type Object struct {
 	baseobject.BaseObject
 	initial Rows // initial data for relative values
+	mark    Rows // marked data for relative values since the mark
 	current Rows // last loaded values
 	results Rows // results (maybe with subtraction)
 	totals  Row  // totals of results
@@ -33,15 +35,33 @@ func NewTableLockLatency(ctx *context.Context) *Object {
 }
 
 func (t *Object) copyCurrentToInitial() {
-	t.initial = make(Rows, len(t.current))
+	t.initial = append(t.initial[:0], t.current...)
 	t.SetInitialCollectTime(t.LastCollectTime())
-	copy(t.initial, t.current)
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
 }
 
 // Collect data from the db, then merge it in.
-func (t *Object) Collect(dbh *sql.DB) {
+func (t *Object) Collect(dbh *sql.DB) error {
 	start := time.Now()
-	t.current = selectRows(dbh)
+	rows, err := selectRows(dbh, t.SchemaFilter())
+	if err != nil {
+		return err
+	}
+	t.current = rows
 	t.SetLastCollectTimeNow()
 
 	if len(t.initial) == 0 && len(t.current) > 0 {
@@ -52,15 +72,22 @@ func (t *Object) Collect(dbh *sql.DB) {
 	if t.initial.needsRefresh(t.current) {
 		t.copyCurrentToInitial()
 	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
 
 	t.makeResults()
 	logger.Println("Object.Collect() took:", time.Duration(time.Since(start)).String())
+
+	return nil
 }
 
 func (t *Object) makeResults() {
-	t.results = make(Rows, len(t.current))
-	copy(t.results, t.current)
-	if t.WantRelativeStats() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		t.results.subtract(t.mark)
+	} else if t.WantRelativeStats() {
 		t.results.subtract(t.initial)
 	}
 
@@ -117,3 +144,25 @@ func (t Object) Len() int {
 func (t Object) HaveRelativeStats() bool {
 	return true
 }
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.current))
+
+	for i := range t.current {
+		values = append(values, t.current[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -0,0 +1,88 @@
+package threshold
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// Notifier tells the user about a breach of a threshold
+type Notifier interface {
+	Notify(b Breach)
+}
+
+// Terminal is the screen the user interacts with, which rings the bell
+// and sends desktop notifications. Other displays, e.g. ps-stats, do
+// nothing so their output isn't mixed with escape sequences.
+type Terminal interface {
+	Bell()
+	DesktopNotification(message string)
+}
+
+// message returns a short description of the breach
+func (b Breach) message() string {
+	return fmt.Sprintf("%s: %s %s changed by %d (threshold %d)", b.View, b.Name, b.Value, b.Change, b.Limit)
+}
+
+// bell rings the terminal bell
+type bell struct {
+	terminal Terminal
+}
+
+// Notify rings the bell
+func (n bell) Notify(b Breach) {
+	n.terminal.Bell()
+}
+
+// osc9 sends an OSC 9 escape sequence which many terminals show as a desktop notification
+type osc9 struct {
+	terminal Terminal
+}
+
+// Notify sends the notification
+func (n osc9) Notify(b Breach) {
+	n.terminal.DesktopNotification(b.message())
+}
+
+// command runs a command with the view, row, value name, change and threshold as arguments
+type command struct {
+	path string
+}
+
+// Notify starts the command without waiting for it to finish
+func (c command) Notify(b Breach) {
+	cmd := exec.Command(c.path, b.View, b.Name, b.Value, fmt.Sprintf("%d", b.Change), fmt.Sprintf("%d", b.Limit))
+	if err := cmd.Start(); err != nil {
+		logger.Println("threshold: unable to run", c.path, ":", err)
+		return
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			logger.Println("threshold:", c.path, "failed:", err)
+		}
+	}()
+}
+
+// configuredNotifiers returns the notifiers enabled in the [notify]
+// section of ~/.pstoprc. The terminal bell is used if none are.
+func configuredNotifiers(terminal Terminal) []Notifier {
+	var notifiers []Notifier
+	config := rc.Section("notify")
+
+	if config["bell"] == "true" {
+		notifiers = append(notifiers, bell{terminal: terminal})
+	}
+	if config["osc9"] == "true" {
+		notifiers = append(notifiers, osc9{terminal: terminal})
+	}
+	if config["command"] != "" {
+		notifiers = append(notifiers, command{path: config["command"]})
+	}
+	if len(notifiers) == 0 {
+		notifiers = append(notifiers, bell{terminal: terminal})
+	}
+
+	return notifiers
+}
@@ -0,0 +1,157 @@
+// Package threshold watches the values of the rows of a view and
+// notifies the user when a value changes by more than the threshold
+// configured for it in ~/.pstoprc, e.g.
+// [thresholds]
+// file_io_latency.sum_timer_wait = 2s
+// table_io_ops.count_star = 10000
+// Thresholds of sum_timer_* values may be given as a duration.
+package threshold
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// Breach describes a value of a row which changed by more than its threshold
+type Breach struct {
+	View   string // name of the view
+	Name   string // name of the row
+	Value  string // name of the value, e.g. sum_timer_wait
+	Change uint64 // the change since the last collection
+	Limit  uint64 // the threshold
+}
+
+// Watcher checks the rows of each view against the configured thresholds
+type Watcher struct {
+	limits    map[string]map[string]uint64            // thresholds by view and value name
+	notifiers []Notifier                              // how to tell the user
+	previous  map[string]map[string]map[string]uint64 // values of the last check by view and row name
+	breached  map[string]bool                         // breaches found by the last check
+}
+
+// parseLimit converts a threshold from ~/.pstoprc. Durations are
+// converted to picoseconds to match the sum_timer_* values.
+func parseLimit(value string) (uint64, bool) {
+	if limit, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return limit, true
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return uint64(d.Nanoseconds()) * 1000, true
+	}
+
+	return 0, false
+}
+
+// NewWatcher returns a Watcher for the thresholds and notifications
+// configured in ~/.pstoprc, the bell and desktop notifications going to
+// the terminal
+func NewWatcher(terminal Terminal) *Watcher {
+	w := &Watcher{
+		limits:    make(map[string]map[string]uint64),
+		notifiers: configuredNotifiers(terminal),
+		previous:  make(map[string]map[string]map[string]uint64),
+		breached:  make(map[string]bool),
+	}
+
+	for key, value := range rc.Section("thresholds") {
+		dot := strings.LastIndex(key, ".")
+		limit, ok := parseLimit(value)
+		if dot < 1 || !ok {
+			log.Fatal("Invalid threshold '", key, " = ", value, "' in ~/.pstoprc. Expected <view>.<value> = <number or duration>")
+		}
+		view, name := key[:dot], key[dot+1:]
+		if w.limits[view] == nil {
+			w.limits[view] = make(map[string]uint64)
+		}
+		w.limits[view][name] = limit
+	}
+	logger.Println("threshold.NewWatcher() found thresholds for", len(w.limits), "view(s) and", len(w.notifiers), "notifier(s)")
+
+	return w
+}
+
+// Enabled returns true if any thresholds are configured
+func (w *Watcher) Enabled() bool {
+	return len(w.limits) > 0
+}
+
+// Breached returns true if a value was over its threshold at the last check
+func (w *Watcher) Breached() bool {
+	return len(w.breached) > 0
+}
+
+// breaches returns the values which changed by more than their limits.
+// A value which has gone backwards has been reset so its current value
+// is the change.
+func breaches(view string, limits map[string]uint64, previous map[string]map[string]uint64, rows []ps_table.RowValues) []Breach {
+	var found []Breach
+
+	for i := range rows {
+		before, seen := previous[rows[i].Name]
+		if !seen {
+			continue
+		}
+		for name, limit := range limits {
+			value, ok := rows[i].Values[name]
+			if !ok {
+				continue
+			}
+			change := value
+			if value >= before[name] {
+				change = value - before[name]
+			}
+			if change > limit {
+				found = append(found, Breach{View: view, Name: rows[i].Name, Value: name, Change: change, Limit: limit})
+			}
+		}
+	}
+
+	return found
+}
+
+// Check compares the rows of the view with those of the last check and
+// notifies the user of any value which has changed by more than its
+// threshold. A row is only notified again once it has dropped back
+// below the threshold so a continuing problem doesn't repeat the alert.
+func (w *Watcher) Check(view string, rows []ps_table.RowValues) {
+	limits, ok := w.limits[view]
+	if !ok {
+		return
+	}
+
+	breached := make(map[string]bool)
+	if previous, seen := w.previous[view]; seen {
+		for _, b := range breaches(view, limits, previous, rows) {
+			key := b.Name + "/" + b.Value
+			breached[key] = true
+			if w.breached[view+"/"+key] {
+				continue
+			}
+			logger.Println("threshold.Check() breach:", b)
+			for _, n := range w.notifiers {
+				n.Notify(b)
+			}
+		}
+	}
+
+	for key := range w.breached {
+		if strings.HasPrefix(key, view+"/") {
+			delete(w.breached, key)
+		}
+	}
+	for key := range breached {
+		w.breached[view+"/"+key] = true
+	}
+
+	current := make(map[string]map[string]uint64, len(rows))
+	for i := range rows {
+		current[rows[i].Name] = rows[i].Values
+	}
+	w.previous[view] = current
+}
@@ -0,0 +1,46 @@
+package threshold
+
+import (
+	"testing"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		limit uint64
+		ok    bool
+	}{
+		{"10000", 10000, true},
+		{"2s", 2000000000000, true},
+		{"1ms", 1000000000, true},
+		{"-1s", 0, false},
+		{"lots", 0, false},
+	}
+	for _, test := range tests {
+		if limit, ok := parseLimit(test.input); limit != test.limit || ok != test.ok {
+			t.Errorf("parseLimit(%q) expected (%v, %v) but got (%v, %v)", test.input, test.limit, test.ok, limit, ok)
+		}
+	}
+}
+
+func TestBreaches(t *testing.T) {
+	limits := map[string]uint64{"count_star": 100}
+	previous := map[string]map[string]uint64{
+		"a": {"count_star": 1000},
+		"b": {"count_star": 1000},
+		"c": {"count_star": 1000},
+	}
+	rows := []ps_table.RowValues{
+		{Name: "a", Values: map[string]uint64{"count_star": 1100}}, // exactly the limit
+		{Name: "b", Values: map[string]uint64{"count_star": 1101}}, // over the limit
+		{Name: "c", Values: map[string]uint64{"count_star": 200}},  // reset, so changed by 200
+		{Name: "d", Values: map[string]uint64{"count_star": 5000}}, // new row, nothing to compare
+	}
+
+	found := breaches("test", limits, previous, rows)
+	if len(found) != 2 || found[0].Name != "b" || found[1].Name != "c" || found[1].Change != 200 {
+		t.Errorf("breaches() expected rows b and c but got %+v", found)
+	}
+}
@@ -0,0 +1,105 @@
+// Package ui_script reads the scripts given with --script which drive
+// ps-top as a user would, so the same data can be collected for each
+// run of a benchmark. Each line holds a command, e.g.
+// # compare the table and file I/O
+// view table_io_latency
+// wait 10
+// export /tmp/table_io.txt
+// key 3
+// wait 10
+// export /tmp/file_io.txt
+// quit
+// Blank lines and lines starting with # are ignored.
+package ui_script
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sjmudd/ps-top/view"
+)
+
+// Command is one line of a script
+type Command struct {
+	Name string // view, wait, export, fingerprint, key, reset, mark or quit
+	Arg  string
+	Line int
+}
+
+// String returns the command as written in the script
+func (c Command) String() string {
+	return strings.TrimSpace(c.Name + " " + c.Arg)
+}
+
+// commands gives the commands known and whether they take an argument
+var commands = map[string]bool{
+	"view":        true,  // view <name or number>: show the view
+	"wait":        true,  // wait <n>: wait for n collections
+	"export":      true,  // export <file>: append what is shown to the file
+	"fingerprint": true,  // fingerprint <file>: write the workload fingerprint to the file
+	"key":         true,  // key <keys>: press the keys
+	"reset":       false, // reset the statistics, as z does
+	"mark":        false, // mark the statistics, as m does
+	"quit":        false, // stop ps-top
+}
+
+// Parse returns the commands of the script
+func Parse(r io.Reader) ([]Command, error) {
+	var script []Command
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(text, " ", 2)
+		c := Command{Name: fields[0], Line: line}
+		if len(fields) > 1 {
+			c.Arg = strings.TrimSpace(fields[1])
+		}
+
+		wantArg, known := commands[c.Name]
+		switch {
+		case !known:
+			return nil, fmt.Errorf("line %d: unknown command %q", line, c.Name)
+		case wantArg && c.Arg == "":
+			return nil, fmt.Errorf("line %d: %s needs an argument", line, c.Name)
+		case !wantArg && c.Arg != "":
+			return nil, fmt.Errorf("line %d: %s takes no argument", line, c.Name)
+		}
+		if c.Name == "wait" {
+			if n, err := strconv.Atoi(c.Arg); err != nil || n < 1 {
+				return nil, fmt.Errorf("line %d: wait needs a number of collections, not %q", line, c.Arg)
+			}
+		}
+		if c.Name == "view" {
+			if n, err := strconv.Atoi(c.Arg); err == nil {
+				if n < 1 || n > 9 {
+					return nil, fmt.Errorf("line %d: view needs a number from 1 to 9, not %d", line, n)
+				}
+			} else if _, found := view.CodeByName(c.Arg); !found {
+				return nil, fmt.Errorf("line %d: unknown view %q", line, c.Arg)
+			}
+		}
+		script = append(script, c)
+	}
+
+	return script, scanner.Err()
+}
+
+// Load returns the commands of the script in the file
+func Load(path string) ([]Command, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
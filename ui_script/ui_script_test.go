@@ -0,0 +1,52 @@
+package ui_script
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	script, err := Parse(strings.NewReader(`# a benchmark run
+view table_io_latency
+
+wait 10
+export /tmp/table io.txt
+key tz
+quit
+`))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	expected := []Command{
+		{"view", "table_io_latency", 2},
+		{"wait", "10", 4},
+		{"export", "/tmp/table io.txt", 5},
+		{"key", "tz", 6},
+		{"quit", "", 7},
+	}
+	if len(script) != len(expected) {
+		t.Fatalf("Parse() expected %d commands but got %d: %v", len(expected), len(script), script)
+	}
+	for i := range expected {
+		if script[i] != expected[i] {
+			t.Errorf("Parse() command %d expected %+v but got %+v", i, expected[i], script[i])
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{
+		"jump 3",
+		"view",
+		"view no_such_view",
+		"view 0",
+		"wait soon",
+		"wait 0",
+		"quit now",
+	} {
+		if _, err := Parse(strings.NewReader(text)); err == nil {
+			t.Errorf("Parse(%q) expected an error", text)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package unused_indexes contains the library routines for combining
+// performance_schema.table_io_waits_summary_by_index_usage with the
+// index definitions in information_schema.STATISTICS.
+package unused_indexes
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/schema_filter"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// Row contains an index which has not been read
+type Row struct {
+	name          string // schema.table.index
+	columns       string // the columns of the index in order
+	unique        bool   // the index enforces a unique constraint
+	countInsert   uint64
+	countUpdate   uint64
+	countDelete   uint64
+	sumTimerWrite uint64 // insert, update and delete latency
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// select the secondary indexes with no reads since the statistics were
+// reset, with the cost of keeping them up to date. The primary key and
+// the system schemas are not included.
+func selectRows(dbh *sql.DB, schemas *schema_filter.Filter) (Rows, error) {
+	var t Rows
+
+	sql := `
+SELECT	t.OBJECT_SCHEMA,
+	t.OBJECT_NAME,
+	t.INDEX_NAME,
+	MIN(s.NON_UNIQUE),
+	GROUP_CONCAT(s.COLUMN_NAME ORDER BY s.SEQ_IN_INDEX),
+	t.COUNT_INSERT,
+	t.COUNT_UPDATE,
+	t.COUNT_DELETE,
+	t.SUM_TIMER_INSERT + t.SUM_TIMER_UPDATE + t.SUM_TIMER_DELETE
+FROM	table_io_waits_summary_by_index_usage t
+JOIN	information_schema.STATISTICS s ON (s.TABLE_SCHEMA = t.OBJECT_SCHEMA AND s.TABLE_NAME = t.OBJECT_NAME AND s.INDEX_NAME = t.INDEX_NAME)
+WHERE	t.INDEX_NAME IS NOT NULL
+AND	t.INDEX_NAME <> 'PRIMARY'
+AND	t.COUNT_FETCH = 0
+AND	t.OBJECT_SCHEMA NOT IN ('mysql', 'sys', 'performance_schema', 'information_schema')`
+	condition, args := schemas.And("t.OBJECT_SCHEMA", "t.OBJECT_NAME")
+	sql += condition + `
+GROUP BY t.OBJECT_SCHEMA, t.OBJECT_NAME, t.INDEX_NAME, t.COUNT_INSERT, t.COUNT_UPDATE, t.COUNT_DELETE,
+	t.SUM_TIMER_INSERT, t.SUM_TIMER_UPDATE, t.SUM_TIMER_DELETE`
+
+	rows, err := dbh.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var schema, table, index string
+		var nonUnique uint64
+		var r Row
+
+		if err := rows.Scan(
+			&schema,
+			&table,
+			&index,
+			&nonUnique,
+			&r.columns,
+			&r.countInsert,
+			&r.countUpdate,
+			&r.countDelete,
+			&r.sumTimerWrite); err != nil {
+			return nil, err
+		}
+		r.name = lib.TableName(schema, table) + "." + index
+		r.unique = nonUnique == 0
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	logger.Println("unused_indexes.selectRows() recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// totals returns the totals of all the indexes
+func (rows Rows) totals() Row {
+	totals := Row{name: "Totals"}
+
+	for i := range rows {
+		totals.countInsert += rows[i].countInsert
+		totals.countUpdate += rows[i].countUpdate
+		totals.countDelete += rows[i].countDelete
+		totals.sumTimerWrite += rows[i].sumTimerWrite
+	}
+
+	return totals
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWrite, rows[j].sumTimerWrite) },
+		"insert":  func(i, j int) int { return sort_keys.Descending(rows[i].countInsert, rows[j].countInsert) },
+		"update":  func(i, j int) int { return sort_keys.Descending(rows[i].countUpdate, rows[j].countUpdate) },
+		"delete":  func(i, j int) int { return sort_keys.Descending(rows[i].countDelete, rows[j].countDelete) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort the data by write latency (descending) and name after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("unused_indexes", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("unused_indexes", Rows(nil).sortKeys())
+}
+
+// headings returns the headings for the view
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s|%8s %8s %8s|%-6s|%s", "Latency", "%", "Insert", "Update", "Delete", "Unique", "Index (Columns)")
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	name, unique := row.name, ""
+	if row.columns != "" {
+		name += " (" + row.columns + ")"
+	}
+	if row.unique {
+		unique = "yes"
+	}
+
+	return fmt.Sprintf("%10s %6s|%8s %8s %8s|%-6s|%s",
+		lib.FormatTime(row.sumTimerWrite),
+		lib.FormatPct(lib.MyDivide(row.sumTimerWrite, totals.sumTimerWrite)),
+		lib.FormatAmount(row.countInsert),
+		lib.FormatAmount(row.countUpdate),
+		lib.FormatAmount(row.countDelete),
+		unique,
+		name)
+}
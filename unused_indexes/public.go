@@ -0,0 +1,98 @@
+// Package unused_indexes shows the indexes which have not been read
+// since the statistics were reset but still cost time to maintain,
+// like sys.schema_unused_indexes.
+package unused_indexes
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the unused indexes
+type Object struct {
+	baseobject.BaseObject      // embedded
+	current               Rows // indexes with no reads
+	totals                Row  // totals of all the indexes
+}
+
+// NewUnusedIndexes returns a pointer to an object of this type
+func NewUnusedIndexes(ctx *context.Context) *Object {
+	logger.Println("NewUnusedIndexes()")
+	o := new(Object)
+	o.SetContext(ctx)
+
+	return o
+}
+
+// Collect collects the indexes with no reads. An index which is read
+// later drops out of the view. There are no relative values as an index
+// is only unused if it has not been read since the statistics were reset.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh, t.SchemaFilter())
+	if err != nil {
+		return err
+	}
+	rows.sort()
+	t.current, t.totals = rows, rows.totals()
+	t.SetLastCollectTimeNow()
+
+	logger.Println("unused_indexes.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns the rows we need for displaying
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.current))
+
+	for i := range t.current {
+		rows = append(rows, t.current[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the totals of all the indexes
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(e)
+}
+
+// Description returns a description of the view
+func (t Object) Description() string {
+	return fmt.Sprintf("Unused indexes (table_io_waits_summary_by_index_usage) %d with no reads since the statistics were reset", len(t.current))
+}
+
+// Len returns the number of lines to display
+func (t Object) Len() int {
+	return len(t.current)
+}
+
+// HaveRelativeStats is false as an index is only unused if it has never been read
+func (t Object) HaveRelativeStats() bool {
+	return false
+}
+
+// SetInitialFromCurrent - NOT IMPLEMENTED
+func (t *Object) SetInitialFromCurrent() {
+	logger.Println("unused_indexes.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
+}
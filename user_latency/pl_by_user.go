@@ -4,8 +4,10 @@ package user_latency
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/sort_keys"
 )
 
 /*
@@ -35,24 +37,59 @@ type PlByUserRow struct {
 	updates     uint64
 	deletes     uint64
 	other       uint64
+	statement   string // the running statement of the longest running connection, or the latest statement
+	stmtActive  bool   // the statement is still running
+	stmtTime    uint64 // how long the statement has been running, or since it finished
+	stmtID      uint64 // processlist id of the connection of the statement
 }
 
+// maxStatementLength is the length statements are truncated to unless shown in full
+const maxStatementLength = 60
+
 // PlByUserRows contains a slice of PlByUserRow rows
 type PlByUserRows []PlByUserRow
 
 /*
-Run Time   %age|Sleeping      %|Conn Actv|Hosts DBs|Sel Ins Upd Del Oth|username
-hh:mm:ss 100.0%|hh:mm:ss 100.0%|9999 9999|9999  999|999 999 999 999 999|xxxxxxxxxxxxxx
+Run Time   %age|Sleeping      %|Conn Actv|Hosts DBs|Sel Ins Upd Del Oth|User            |Statement
+hh:mm:ss 100.0%|hh:mm:ss 100.0%|9999 9999|9999  999|999 999 999 999 999|xxxxxxxxxxxxxx  |SELECT ...
 */
 
 func (r *PlByUserRow) headings() string {
-	return fmt.Sprintf("%-8s %6s|%-8s %6s|%4s %4s|%5s %3s|%3s %3s %3s %3s %3s|%s",
-		"Run Time", "%", "Sleeping", "%", "Conn", "Actv", "Hosts", "DBs", "Sel", "Ins", "Upd", "Del", "Oth", "User")
+	return fmt.Sprintf("%-8s %6s|%-8s %6s|%4s %4s|%5s %3s|%3s %3s %3s %3s %3s|%-16s|%s",
+		"Run Time", "%", "Sleeping", "%", "Conn", "Actv", "Hosts", "DBs", "Sel", "Ins", "Upd", "Del", "Oth", "User", "Statement")
+}
+
+// noteStatement records the statement of a connection if it is more interesting
+// than the one we have: running statements beat finished ones, then the longest
+// running or most recently finished statement wins.
+func (r *PlByUserRow) noteStatement(statement string, id uint64, active bool, time uint64) {
+	if statement == "" {
+		return
+	}
+	switch {
+	case r.statement == "",
+		active && !r.stmtActive,
+		active && r.stmtActive && time > r.stmtTime,
+		!active && !r.stmtActive && time < r.stmtTime:
+		r.statement = statement
+		r.stmtActive = active
+		r.stmtTime = time
+		r.stmtID = id
+	}
+}
+
+// statementText returns the statement on a single line, truncated unless wanted in full
+func (r *PlByUserRow) statementText(full bool) string {
+	text := strings.Join(strings.Fields(r.statement), " ")
+	if !full && len(text) > maxStatementLength {
+		text = text[0:maxStatementLength-3] + "..."
+	}
+	return text
 }
 
 // generate a printable result
-func (r *PlByUserRow) rowContent(totals PlByUserRow) string {
-	return fmt.Sprintf("%8s %6s|%8s %6s|%4s %4s|%5s %3s|%3s %3s %3s %3s %3s|%s",
+func (r *PlByUserRow) rowContent(totals PlByUserRow, full bool) string {
+	return fmt.Sprintf("%8s %6s|%8s %6s|%4s %4s|%5s %3s|%3s %3s %3s %3s %3s|%-16s|%s",
 		lib.FormatSeconds(r.runtime),
 		lib.FormatPct(lib.MyDivide(r.runtime, totals.runtime)),
 		lib.FormatSeconds(r.sleeptime),
@@ -66,7 +103,8 @@ func (r *PlByUserRow) rowContent(totals PlByUserRow) string {
 		lib.FormatCounter(int(r.updates), 3),
 		lib.FormatCounter(int(r.deletes), 3),
 		lib.FormatCounter(int(r.other), 3),
-		r.username)
+		r.username,
+		r.statementText(full))
 }
 
 // generate a row of totals from a table
@@ -116,23 +154,29 @@ func (t PlByUserRows) String() string {
 	return s
 }
 
-// ByRunTime is for sorting rows by runtime
-type ByRunTime PlByUserRows
-
-func (t ByRunTime) Len() int      { return len(t) }
-func (t ByRunTime) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
-func (t ByRunTime) Less(i, j int) bool {
-	return (t[i].totalTime() > t[j].totalTime()) ||
-		((t[i].totalTime() == t[j].totalTime()) && (t[i].connections > t[j].connections)) ||
-		((t[i].totalTime() == t[j].totalTime()) && (t[i].connections == t[j].connections) && (t[i].username < t[j].username))
+// sortKeys returns the keys the rows may be sorted by
+func (t PlByUserRows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"time":        func(i, j int) int { return sort_keys.Descending(t[i].totalTime(), t[j].totalTime()) },
+		"runtime":     func(i, j int) int { return sort_keys.Descending(t[i].runtime, t[j].runtime) },
+		"sleeptime":   func(i, j int) int { return sort_keys.Descending(t[i].sleeptime, t[j].sleeptime) },
+		"connections": func(i, j int) int { return sort_keys.Descending(t[i].connections, t[j].connections) },
+		"active":      func(i, j int) int { return sort_keys.Descending(t[i].active, t[j].active) },
+		"name":        func(i, j int) int { return sort_keys.Ascending(t[i].username, t[j].username) },
+	}
 }
 
-// Sort by User rows
+// Sort by User rows by total time, connections and name after any configured sort keys
 func (t PlByUserRows) Sort() {
-	sort.Sort(ByRunTime(t))
+	sort.Slice(t, t.sortKeys().Less("user_latency", "time", "connections", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("user_latency", PlByUserRows(nil).sortKeys())
 }
 
 func (t PlByUserRows) emptyRowContent() string {
 	var r PlByUserRow
-	return r.rowContent(r)
+	return r.rowContent(r, false)
 }
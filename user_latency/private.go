@@ -1,13 +1,14 @@
 // Package user_latency file contains the library routines for managing the
-// information_schema.processlist table.
+// connections listed in performance_schema.threads.
 package user_latency
 
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
 
 	"github.com/sjmudd/anonymiser"
+	"github.com/sjmudd/ps-top/lib"
 	"github.com/sjmudd/ps-top/logger"
 )
 
@@ -24,7 +25,7 @@ CREATE TEMPORARY TABLE `PROCESSLIST` (
 1 row in set (0.02 sec)
 */
 
-// Row contains a row from from information_schema.processlist
+// Row contains a connection from performance_schema.threads
 type Row struct {
 	ID      uint64
 	user    string
@@ -36,11 +37,127 @@ type Row struct {
 	info    string
 }
 
+// lastStatementsQuery returns the most recent statement of each connection, even if it has finished
+const lastStatementsQuery = `
+SELECT	t.PROCESSLIST_ID,
+	t.PROCESSLIST_USER,
+	t.PROCESSLIST_TIME,
+	s.SQL_TEXT
+FROM	performance_schema.threads t
+JOIN	performance_schema.events_statements_current s ON (s.THREAD_ID = t.THREAD_ID)
+WHERE	t.PROCESSLIST_ID IS NOT NULL
+AND	s.SQL_TEXT IS NOT NULL
+ORDER BY s.EVENT_ID`
+
+// maxLastStatements is the most connections for which the most recent
+// statements are looked up, as this reads a row for every connection
+const maxLastStatements = 1000
+
 // Rows contains a slice of Row
 type Rows []Row
 
-// get the output of I_S.PROCESSLIST
-func selectRows(dbh *sql.DB) Rows {
+// groupRow contains the connections with the same user, host, db and command
+type groupRow struct {
+	user        string
+	host        string
+	db          string
+	command     string
+	connections uint64
+	time        uint64 // sum of the time of the connections
+	sending     uint64 // binlog dump threads sending events
+	selects     uint64
+	inserts     uint64
+	updates     uint64
+	deletes     uint64
+}
+
+// groupsQuery adds up the connections on the server so only a row per
+// user, host, db and command is returned however many connections there are
+const groupsQuery = `
+SELECT	PROCESSLIST_USER,
+	PROCESSLIST_HOST,
+	PROCESSLIST_DB,
+	PROCESSLIST_COMMAND,
+	COUNT(*),
+	SUM(PROCESSLIST_TIME),
+	SUM(PROCESSLIST_STATE LIKE '%Sending binlog event to slave%'),
+	SUM(PROCESSLIST_INFO LIKE '%SELECT%'),
+	SUM(PROCESSLIST_INFO LIKE '%INSERT%'),
+	SUM(PROCESSLIST_INFO LIKE '%UPDATE%'),
+	SUM(PROCESSLIST_INFO LIKE '%DELETE%')
+FROM	performance_schema.threads
+WHERE	PROCESSLIST_ID IS NOT NULL
+GROUP BY PROCESSLIST_USER, PROCESSLIST_HOST, PROCESSLIST_DB, PROCESSLIST_COMMAND`
+
+// connectionsQuery lists the connections, longest running first
+const connectionsQuery = `
+SELECT	PROCESSLIST_ID,
+	PROCESSLIST_USER,
+	PROCESSLIST_HOST,
+	PROCESSLIST_DB,
+	PROCESSLIST_COMMAND,
+	PROCESSLIST_TIME,
+	PROCESSLIST_STATE,
+	PROCESSLIST_INFO
+FROM	performance_schema.threads
+WHERE	PROCESSLIST_ID IS NOT NULL`
+
+// selectGroups returns the connections added up by user, host, db and command
+func selectGroups(dbh *sql.DB) ([]groupRow, error) {
+	var t []groupRow
+
+	rows, err := dbh.Query(groupsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g groupRow
+		var user, host, db, command sql.NullString
+		var time, sending, selects, inserts, updates, deletes sql.NullInt64
+
+		if err := rows.Scan(&user, &host, &db, &command, &g.connections, &time, &sending, &selects, &inserts, &updates, &deletes); err != nil {
+			return nil, err
+		}
+		g.user = anonymiser.Anonymise("user", user.String)
+		g.host = host.String
+		g.db = db.String
+		g.command = command.String
+		g.time = uint64(time.Int64)
+		g.sending = uint64(sending.Int64)
+		g.selects = uint64(selects.Int64)
+		g.inserts = uint64(inserts.Int64)
+		g.updates = uint64(updates.Int64)
+		g.deletes = uint64(deletes.Int64)
+		t = append(t, g)
+	}
+	logger.Println("user_latency.selectGroups() recovered", len(t), "row(s)")
+
+	return t, rows.Err()
+}
+
+// selectRunning returns the connections running a statement
+func selectRunning(dbh *sql.DB) (Rows, error) {
+	return selectRows(dbh, connectionsQuery+"\nAND\tPROCESSLIST_COMMAND NOT IN ('Sleep', 'Daemon')\nAND\tPROCESSLIST_INFO IS NOT NULL")
+}
+
+// selectPage returns a page of the connections, longest running first
+func selectPage(dbh *sql.DB, limit, offset int) (Rows, error) {
+	return selectRows(dbh, connectionsQuery+"\nORDER BY PROCESSLIST_TIME DESC, PROCESSLIST_ID\nLIMIT ? OFFSET ?", limit, offset)
+}
+
+// countConnections returns the number of connections
+func countConnections(dbh *sql.DB) (int, error) {
+	var count int
+
+	err := dbh.QueryRow("SELECT COUNT(*) FROM performance_schema.threads WHERE PROCESSLIST_ID IS NOT NULL").Scan(&count)
+
+	return count, err
+}
+
+// get the connections returned by the query
+func selectRows(dbh *sql.DB, query string, args ...interface{}) (Rows, error) {
 	var t Rows
 	var id sql.NullInt64
 	var user sql.NullString
@@ -51,13 +168,9 @@ func selectRows(dbh *sql.DB) Rows {
 	var state sql.NullString
 	var info sql.NullString
 
-	// we collect all information even if it's mainly empty as we may reference it later
-
-	sql := "SELECT ID, USER, HOST, DB, COMMAND, TIME, STATE, INFO FROM INFORMATION_SCHEMA.PROCESSLIST"
-
-	rows, err := dbh.Query(sql)
+	rows, err := dbh.Query(query, args...)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -72,7 +185,7 @@ func selectRows(dbh *sql.DB) Rows {
 			&time,
 			&state,
 			&info); err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		r.ID = uint64(id.Int64)
 
@@ -94,10 +207,64 @@ func selectRows(dbh *sql.DB) Rows {
 		t = append(t, r)
 	}
 	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// get the most recent statement of each connection by processlist id
+func selectLastStatements(dbh *sql.DB) (map[uint64]Row, error) {
+	statements := make(map[uint64]Row)
+
+	rows, err := dbh.Query(lastStatementsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r Row
+		var user sql.NullString
+		var time sql.NullInt64
+
+		if err := rows.Scan(&r.ID, &user, &time, &r.info); err != nil {
+			return nil, err
+		}
+		r.user = anonymiser.Anonymise("user", user.String)
+		r.time = uint64(time.Int64)
+		statements[r.ID] = r // nested statements come first so the outer one wins
+	}
+
+	return statements, rows.Err()
+}
+
+// headings returns the headings of the connections listed
+func (r *Row) headings() string {
+	return fmt.Sprintf("%10s %8s|%-16s|%-20s|%-12s|%-10s|%-20s|%s", "Id", "Time", "User", "Host", "DB", "Command", "State", "Info")
+}
+
+// rowContent returns a connection as a printable row with its statement
+// truncated unless wanted in full
+func (r *Row) rowContent(full bool) string {
+	var id string
+	if r.ID > 0 {
+		id = fmt.Sprintf("%d", r.ID)
+	}
+	info := strings.Join(strings.Fields(r.info), " ")
+	if !full && r.ID > 0 && len(info) > maxStatementLength {
+		info = info[0:maxStatementLength-3] + "..."
 	}
 
-	return t
+	return fmt.Sprintf("%10s %8s|%-16s|%-20s|%-12s|%-10s|%-20s|%s",
+		id,
+		lib.FormatSeconds(r.time),
+		r.user,
+		r.host,
+		r.db,
+		r.command,
+		r.state,
+		info)
 }
 
 // describe a whole row
@@ -1,11 +1,9 @@
-// Package user_latency contains library routines for ps-top related to the INFORMATION_SCHEMA.PROCESSLIST table.
+// Package user_latency contains library routines for ps-top related to the connections in performance_schema.threads.
 package user_latency
 
 import (
 	"database/sql"
 	"fmt"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/sjmudd/ps-top/baseobject"
@@ -18,11 +16,21 @@ type mapStringInt map[string]int
 // Object contains a table of rows
 type Object struct {
 	baseobject.BaseObject
-	current Rows         // processlist
-	results PlByUserRows // results by user
-	totals  PlByUserRow  // totals of results
+	groups  []groupRow     // connections added up by user, host, db and command
+	running Rows           // connections running a statement
+	current Rows           // the page of connections listed
+	count   int            // number of connections (when listing them)
+	page    int            // page of connections listed
+	listing bool           // the connections are listed rather than added up by user
+	last    map[uint64]Row // most recent statement by connection id
+	noLast  bool           // most recent statements can't be collected
+	results PlByUserRows   // results by user
+	totals  PlByUserRow    // totals of results
 }
 
+// PageSize is the number of connections listed on each page
+const PageSize = 50
+
 func NewUserLatency(ctx *context.Context) *Object {
 	logger.Println("NewUserLatency()")
 	o := new(Object)
@@ -31,42 +39,129 @@ func NewUserLatency(ctx *context.Context) *Object {
 	return o
 }
 
-// Collect collects data from the db, updating initial
-// values if needed, and then subtracting initial values if we want
-// relative values, after which it stores totals.
-func (t *Object) Collect(dbh *sql.DB) {
+// Collect collects the connections added up by user on the server, or
+// a page of the connections if they are being listed, as there may be
+// too many to read them all each time.
+func (t *Object) Collect(dbh *sql.DB) error {
 	logger.Println("Object.Collect() - starting collection of data")
 	start := time.Now()
 
-	t.current = selectRows(dbh)
-	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+	if t.WantConnections() {
+		if err := t.collectPage(dbh); err != nil {
+			return err
+		}
+		t.listing = true
+		logger.Println("Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+		return nil
+	}
+
+	groups, err := selectGroups(dbh)
+	if err != nil {
+		return err
+	}
+	running, err := selectRunning(dbh)
+	if err != nil {
+		return err
+	}
+	t.groups, t.running, t.listing = groups, running, false
+	if !t.noLast {
+		t.last = nil
+		if connections := t.connections(); connections > maxLastStatements {
+			logger.Println("Not collecting the most recent statements of", connections, "connections")
+		} else if t.last, err = selectLastStatements(dbh); err != nil {
+			logger.Println("Unable to collect the most recent statements, only showing running ones:", err)
+			t.noLast = true
+		}
+	}
 
-	t.processlist2byUser()
+	t.groups2byUser()
 
 	logger.Println("Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// collectPage collects the page of connections wanted, showing the last
+// page if there are fewer connections than that
+func (t *Object) collectPage(dbh *sql.DB) error {
+	count, err := countConnections(dbh)
+	if err != nil {
+		return err
+	}
+	page := t.ConnectionsPage()
+	if last := (count - 1) / PageSize; page > last {
+		page = last
+	}
+	if page < 0 {
+		page = 0
+	}
+	rows, err := selectPage(dbh, PageSize, page*PageSize)
+	if err != nil {
+		return err
+	}
+	t.count, t.page, t.current = count, page, rows
+	logger.Println("t.current collected", len(t.current), "of", count, "connection(s) for page", page)
+
+	return nil
+}
+
+// Page returns the page of connections listed, which is the last one if
+// fewer connections were found than the page wanted
+func (t Object) Page() int {
+	return t.page
+}
+
+// connections returns the number of connections in the groups
+func (t Object) connections() uint64 {
+	var connections uint64
+	for i := range t.groups {
+		connections += t.groups[i].connections
+	}
+	return connections
 }
 
 // Headings returns a string representing the view headings
 func (t Object) Headings() string {
+	if t.listing {
+		var r Row
+		return r.headings()
+	}
 	return t.results.Headings()
 }
 
 // EmptyRowContent returns an empty string representing the view values
 func (t Object) EmptyRowContent() string {
+	if t.listing {
+		var r Row
+		return r.rowContent(false)
+	}
 	return t.results.emptyRowContent()
 }
 
 // TotalRowContent returns a string representing the total view values
 func (t Object) TotalRowContent() string {
-	return t.totals.rowContent(t.totals)
+	if t.listing {
+		var r Row
+		r.info = fmt.Sprintf("Totals: %d connection(s), page %d of %d", t.count, t.page+1, (t.count+PageSize-1)/PageSize)
+		return r.rowContent(false)
+	}
+	return t.totals.rowContent(t.totals, false)
 }
 
 // RowContent returns a string representing the row's view values
 func (t Object) RowContent() []string {
+	if t.listing {
+		rows := make([]string, 0, len(t.current))
+		for i := range t.current {
+			rows = append(rows, t.current[i].rowContent(t.WantFullStatements()))
+		}
+		return rows
+	}
+
 	rows := make([]string, 0, len(t.results))
 
 	for i := range t.results {
-		rows = append(rows, t.results[i].rowContent(t.totals))
+		rows = append(rows, t.results[i].rowContent(t.totals, t.WantFullStatements()))
 	}
 
 	return rows
@@ -74,8 +169,15 @@ func (t Object) RowContent() []string {
 
 // Description returns a string description of the data being returned
 func (t Object) Description() string {
+	if t.listing {
+		first := t.page*PageSize + 1
+		if t.count == 0 {
+			first = 0
+		}
+		return fmt.Sprintf("Connections (threads) %d-%d of %d, longest running first (PgUp/PgDn: page, l: by user)", first, t.page*PageSize+len(t.current), t.count)
+	}
 	count := t.countRow()
-	return fmt.Sprintf("Activity by Username (processlist) %d rows", count)
+	return fmt.Sprintf("Activity by Username (threads) %d rows (l: list connections)", count)
 }
 
 func (t Object) countRow() int {
@@ -88,123 +190,72 @@ func (t Object) countRow() int {
 	return count
 }
 
-// return the hostname without the port part
-func getHostname(hostPort string) string {
-	i := strings.Index(hostPort, ":")
-	if i >= 0 {
-		return hostPort[0:i]
-	}
-	return hostPort // shouldn't happen !!!
-}
-
-// read in processlist and add the appropriate values into a new pl_by_user table
-func (t *Object) processlist2byUser() {
-	logger.Println("Object.processlist2byUser() START")
-
-	reActiveReplMasterThread := regexp.MustCompile("Sending binlog event to slave")
-	reSelect := regexp.MustCompile(`(?i)SELECT`) // make case insensitive
-	reInsert := regexp.MustCompile(`(?i)INSERT`) // make case insensitive
-	reUpdate := regexp.MustCompile(`(?i)UPDATE`) // make case insensitive
-	reDelete := regexp.MustCompile(`(?i)DELETE`) // make case insensitive
-
-	var row PlByUserRow
-	var results PlByUserRows
-	var myHosts mapStringInt
-	var myDB mapStringInt
-	var ok bool
+// add up the connections of each user and note their most interesting statement
+func (t *Object) groups2byUser() {
+	logger.Println("Object.groups2byUser() START")
 
-	rowByUser := make(map[string]PlByUserRow)
-	hostsByUser := make(map[string]mapStringInt)
-	DBsByUser := make(map[string]mapStringInt)
+	rowByUser := make(map[string]*PlByUserRow)
+	hostsByUser := make(map[string]map[string]bool)
+	dbsByUser := make(map[string]map[string]bool)
 
 	// global values for totals.
-	globalHosts := make(map[string]int)
-	globalDbs := make(map[string]int)
-
-	for i := range t.current {
-		// munge the username for special purposes (event scheduler, replication threads etc)
-		id := t.current[i].ID
-		username := t.current[i].user // limit size for display
-		host := getHostname(t.current[i].host)
-		command := t.current[i].command
-		db := t.current[i].db
-		info := t.current[i].info
-		state := t.current[i].state
-
-		logger.Println("- id/user/host:", id, username, host)
-
-		// fill global values
-		if host != "" {
-			globalHosts[host] = 1
+	globalHosts := make(map[string]bool)
+	globalDbs := make(map[string]bool)
+
+	for _, g := range t.groups {
+		row, found := rowByUser[g.user]
+		if !found {
+			row = &PlByUserRow{username: g.user}
+			rowByUser[g.user] = row
+			hostsByUser[g.user] = make(map[string]bool)
+			dbsByUser[g.user] = make(map[string]bool)
 		}
-		if db != "" {
-			globalDbs[db] = 1
-		}
-
-		if oldRow, ok := rowByUser[username]; ok {
-			logger.Println("- found old row in rowByUser")
-			row = oldRow // get old row
-		} else {
-			logger.Println("- NOT found old row in rowByUser")
-			// create new row - RESET THE VALUES !!!!
-			rowp := new(PlByUserRow)
-			row = *rowp
-			row.username = t.current[i].user
-			rowByUser[username] = row
-		}
-		row.connections++
+		row.connections += g.connections
 		// ignore system SQL threads (may be more to filter out)
-		if username != "system user" && host != "" && command != "Binlog Dump" {
-			if command == "Sleep" {
-				row.sleeptime += t.current[i].time
+		if g.user != "system user" && g.host != "" && g.command != "Binlog Dump" {
+			if g.command == "Sleep" {
+				row.sleeptime += g.time
 			} else {
-				row.runtime += t.current[i].time
-				row.active++
+				row.runtime += g.time
+				row.active += g.connections
 			}
 		}
-		if command == "Binlog Dump" && reActiveReplMasterThread.MatchString(state) {
-			row.active++
+		if g.command == "Binlog Dump" {
+			row.active += g.sending
 		}
 
-		// add the host if not known already
-		if host != "" {
-			if myHosts, ok = hostsByUser[username]; !ok {
-				myHosts = make(mapStringInt)
-			}
-			myHosts[host] = 1 // whatever - value doesn't matter
-			hostsByUser[username] = myHosts
+		if g.host != "" {
+			hostsByUser[g.user][g.host] = true
+			globalHosts[g.host] = true
 		}
-		row.hosts = uint64(len(hostsByUser[username]))
-
-		// add the db count if not known already
-		if db != "" {
-			if myDB, ok = DBsByUser[username]; !ok {
-				myDB = make(mapStringInt)
-			}
-			myDB[db] = 1 // whatever - value doesn't matter
-			DBsByUser[username] = myDB
+		if g.db != "" {
+			dbsByUser[g.user][g.db] = true
+			globalDbs[g.db] = true
 		}
-		row.dbs = uint64(len(DBsByUser[username]))
+		row.hosts = uint64(len(hostsByUser[g.user]))
+		row.dbs = uint64(len(dbsByUser[g.user]))
 
-		if reSelect.MatchString(info) == true {
-			row.selects++
-		}
-		if reInsert.MatchString(info) == true {
-			row.inserts++
-		}
-		if reUpdate.MatchString(info) == true {
-			row.updates++
+		row.selects += g.selects
+		row.inserts += g.inserts
+		row.updates += g.updates
+		row.deletes += g.deletes
+	}
+
+	// the running statements beat the most recent ones of idle connections
+	for i := range t.running {
+		if row, found := rowByUser[t.running[i].user]; found {
+			row.noteStatement(t.running[i].info, t.running[i].ID, true, t.running[i].time)
 		}
-		if reDelete.MatchString(info) == true {
-			row.deletes++
+	}
+	for id, last := range t.last {
+		if row, found := rowByUser[last.user]; found {
+			row.noteStatement(last.info, id, false, last.time)
 		}
-
-		rowByUser[username] = row
 	}
 
-	results = make(PlByUserRows, 0, len(rowByUser))
+	results := make(PlByUserRows, 0, len(rowByUser))
 	for _, v := range rowByUser {
-		results = append(results, v)
+		results = append(results, *v)
 	}
 	t.results = results
 	t.results.Sort() // sort output
@@ -214,11 +265,14 @@ func (t *Object) processlist2byUser() {
 	t.totals.hosts = uint64(len(globalHosts))
 	t.totals.dbs = uint64(len(globalDbs))
 
-	logger.Println("Object.processlist2byUser() END")
+	logger.Println("Object.groups2byUser() END")
 }
 
 // Len returns the length of the result set
 func (t Object) Len() int {
+	if t.listing {
+		return len(t.current)
+	}
 	return len(t.results)
 }
 
@@ -230,3 +284,21 @@ func (t Object) HaveRelativeStats() bool {
 func (t *Object) SetInitialFromCurrent() {
 	logger.Println("user_latency.Object.SetInitialFromCurrent() NOT IMPLEMENTED")
 }
+
+// FollowID returns the processlist id of the connection whose statement
+// is shown on the first row with one, or of the first connection listed,
+// so that connection can be followed
+func (t Object) FollowID() (uint64, bool) {
+	if t.listing {
+		if len(t.current) > 0 {
+			return t.current[0].ID, true
+		}
+		return 0, false
+	}
+	for i := range t.results {
+		if t.results[i].statement != "" {
+			return t.results[i].stmtID, true
+		}
+	}
+	return 0, false
+}
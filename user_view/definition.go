@@ -0,0 +1,109 @@
+package user_view
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+const (
+	sectionPrefix = "view:" // ~/.pstoprc sections defining a user view
+	allColumns    = "*"     // delta value meaning all columns are counters
+)
+
+// Definition holds the configuration of a user defined view
+type Definition struct {
+	Name        string          // name of the view as used by --view
+	Description string          // description shown above the headings
+	Query       string          // SELECT returning a key and numeric columns
+	deltas      map[string]bool // columns which are counters
+	allDeltas   bool            // all columns are counters
+}
+
+var (
+	definitions       []Definition // user views from ~/.pstoprc
+	loadedDefinitions bool         // Have we [attempted to] load them?
+)
+
+// newDefinition returns the Definition of the named view given its
+// ~/.pstoprc section.
+func newDefinition(name string, section map[string]string) (Definition, error) {
+	d := Definition{
+		Name:        strings.TrimSpace(name),
+		Description: section["description"],
+		Query:       strings.TrimSpace(section["query"]),
+		deltas:      make(map[string]bool),
+	}
+
+	if d.Name == "" {
+		return d, errors.New("the view name is empty")
+	}
+	if d.Query == "" {
+		return d, errors.New("no query given")
+	}
+	if d.Description == "" {
+		d.Description = "User view " + d.Name
+	}
+
+	for _, column := range strings.Split(section["delta"], ",") {
+		column = strings.TrimSpace(column)
+		switch column {
+		case "":
+		case allColumns:
+			d.allDeltas = true
+		default:
+			d.deltas[column] = true
+		}
+	}
+
+	return d, nil
+}
+
+// Definitions returns the user views defined in ~/.pstoprc ordered by
+// name. Each view is configured in a section of its own, e.g.
+// [view:handlers]
+// description = Handler calls (global status)
+// query = SELECT 'handlers', SUM(VARIABLE_VALUE) AS handler_calls FROM performance_schema.global_status WHERE VARIABLE_NAME LIKE 'Handler%'
+// delta = handler_calls
+func Definitions() []Definition {
+	if loadedDefinitions {
+		return definitions
+	}
+	loadedDefinitions = true
+
+	logger.Println("user_view.Definitions()")
+
+	for name, section := range rc.Sections(sectionPrefix) {
+		d, err := newDefinition(name, section)
+		if err != nil {
+			log.Fatal("~/.pstoprc [", sectionPrefix, name, "]: ", err)
+		}
+		definitions = append(definitions, d)
+	}
+	sort.Sort(byName(definitions))
+
+	logger.Println("- found", len(definitions), "user view(s)")
+
+	return definitions
+}
+
+// byName is used for sorting definitions by name
+type byName []Definition
+
+func (d byName) Len() int           { return len(d) }
+func (d byName) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byName) Less(i, j int) bool { return d[i].Name < d[j].Name }
+
+// isDelta returns whether the given column is a counter
+func (d Definition) isDelta(column string) bool {
+	return d.allDeltas || d.deltas[column]
+}
+
+// haveDeltas returns whether any column is a counter
+func (d Definition) haveDeltas() bool {
+	return d.allDeltas || len(d.deltas) > 0
+}
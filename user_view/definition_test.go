@@ -0,0 +1,44 @@
+package user_view
+
+import (
+	"testing"
+)
+
+func TestNewDefinition(t *testing.T) {
+	d, err := newDefinition(" handlers ", map[string]string{
+		"query": "SELECT 'x', 1 AS a, 2 AS b",
+		"delta": "a, c",
+	})
+	if err != nil {
+		t.Fatalf("newDefinition(): unexpected error: %v", err)
+	}
+	if d.Name != "handlers" {
+		t.Errorf("expected name 'handlers', got '%s'", d.Name)
+	}
+	if d.Description != "User view handlers" {
+		t.Errorf("expected default description, got '%s'", d.Description)
+	}
+
+	var tests = []struct {
+		column  string
+		isDelta bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+	for _, test := range tests {
+		if d.isDelta(test.column) != test.isDelta {
+			t.Errorf("isDelta(%q): expected %v", test.column, test.isDelta)
+		}
+	}
+
+	d, _ = newDefinition("all", map[string]string{"query": "SELECT 1, 2", "delta": "*"})
+	if !d.isDelta("anything") {
+		t.Errorf("delta = * should make all columns counters")
+	}
+
+	if _, err := newDefinition("noquery", map[string]string{}); err == nil {
+		t.Errorf("expected an error for a missing query")
+	}
+}
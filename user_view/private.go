@@ -0,0 +1,208 @@
+// Package user_view contains the library routines for managing the
+// views defined by the user in ~/.pstoprc.
+package user_view
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+const valueWidth = 10 // width of each value column
+
+// Row contains a row returned by the user's query: a key followed by numeric values
+type Row struct {
+	name   string
+	values []int64
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+// select the rows returned by the query together with the names of the value columns
+func selectRows(dbh *sql.DB, query string) ([]string, Rows, error) {
+	var t Rows
+
+	rows, err := dbh.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(columns) < 2 {
+		return nil, nil, fmt.Errorf("user_view.selectRows(): query must return a key and at least one numeric column: %s", query)
+	}
+
+	// values are scanned as floats so DECIMAL results such as SUM() are accepted
+	name := new(sql.NullString)
+	values := make([]sql.NullFloat64, len(columns)-1)
+	dest := []interface{}{name}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		r := Row{name: name.String, values: make([]int64, len(values))}
+		for i := range values {
+			r.values[i] = int64(math.Round(values[i].Float64))
+		}
+		t = append(t, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	logger.Println("user_view.selectRows() recovered", len(t), "row(s)")
+
+	return columns[1:], t, nil
+}
+
+// add the values of one row to another one
+func (row *Row) add(other Row) {
+	if len(row.values) < len(other.values) {
+		row.values = append(row.values, make([]int64, len(other.values)-len(row.values))...)
+	}
+	for i := range other.values {
+		row.values[i] += other.values[i]
+	}
+}
+
+// subtract the counters in one row from another
+func (row *Row) subtract(other Row, isDelta []bool) {
+	for i := range row.values {
+		if i < len(other.values) && isDelta[i] {
+			row.values[i] -= other.values[i]
+		}
+	}
+}
+
+// counters returns the values of the counters, those columns whose
+// isDelta is set
+func (row Row) counters(isDelta []bool) []uint64 {
+	var counters []uint64
+
+	for i := range row.values {
+		if i >= len(isDelta) || !isDelta[i] {
+			continue
+		}
+		var counter uint64
+		if row.values[i] > 0 {
+			counter = uint64(row.values[i])
+		}
+		counters = append(counters, counter)
+	}
+
+	return counters
+}
+
+// differ matches the rows by name to subtract the initial values of
+// the counters
+func differ(isDelta []bool) relative_stats.Differ[Row, string] {
+	return relative_stats.Differ[Row, string]{
+		Key:      func(row Row) string { return row.name },
+		Counters: func(row Row) []uint64 { return row.counters(isDelta) },
+		Subtract: func(row *Row, other Row) { row.subtract(other, isDelta) },
+	}
+}
+
+// copy returns a copy of the rows which does not share values with the original
+func (rows Rows) copy() Rows {
+	c := make(Rows, len(rows))
+
+	for i := range rows {
+		c[i].name = rows[i].name
+		c[i].values = append([]int64(nil), rows[i].values...)
+	}
+
+	return c
+}
+
+// generate the totals of a table
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.name = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// needsRefresh returns true if any counter in the totals of the rows
+// found in both has gone backwards, e.g. because the underlying data
+// has been reset.
+func (rows Rows) needsRefresh(otherRows Rows, isDelta []bool) bool {
+	return differ(isDelta).NeedsRefresh(rows, otherRows)
+}
+
+// remove the initial values from those rows where there's a match
+func (rows *Rows) subtract(initial Rows, isDelta []bool) {
+	differ(isDelta).SubtractRows(*rows, initial)
+}
+
+// sortKeys returns the keys the rows may be sorted by: the value
+// columns by name and the key column as "name"
+func (rows Rows) sortKeys(columns []string) sort_keys.Keys {
+	keys := sort_keys.Keys{
+		"name": func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+	for c := range columns {
+		c := c
+		keys[columns[c]] = func(i, j int) int { return sort_keys.SignedDescending(rows[i].values[c], rows[j].values[c]) }
+	}
+
+	return keys
+}
+
+// sort by the first value (descending) but also by "name" (ascending) if the values are the same
+// after any configured sort keys
+func (rows Rows) sort(view string, columns []string) {
+	if len(columns) == 0 {
+		return // nothing collected yet
+	}
+	sort.Slice(rows, rows.sortKeys(columns).Less(view, columns[0], "name"))
+}
+
+// headings returns a heading line using the column names of the query
+func headings(columns []string) string {
+	s := make([]string, len(columns))
+
+	for i := range columns {
+		column := columns[i]
+		if len(column) > valueWidth {
+			column = column[:valueWidth]
+		}
+		s[i] = fmt.Sprintf("%*s", valueWidth, column)
+	}
+
+	return strings.Join(s, " ") + "|Name"
+}
+
+// generate a printable result
+func (row *Row) rowContent(columns int) string {
+	s := make([]string, columns)
+
+	for i := range s {
+		var value int64
+		if i < len(row.values) {
+			value = row.values[i]
+		}
+		s[i] = fmt.Sprintf("%*s", valueWidth, lib.SignedFormatAmount(value))
+	}
+
+	return strings.Join(s, " ") + "|" + row.name
+}
@@ -0,0 +1,135 @@
+// Package user_view contains the library routines for managing the
+// views defined by the user in ~/.pstoprc.
+package user_view
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds a table of rows
+type Object struct {
+	baseobject.BaseObject            // embedded
+	definition            Definition // what to collect
+	columns               []string   // names of the value columns
+	isDelta               []bool     // which value columns are counters
+	initial               Rows       // initial data for relative values
+	current               Rows       // last loaded values
+	results               Rows       // results (maybe with subtraction)
+	totals                Row        // totals of results
+}
+
+// NewUserView returns a pointer to an object for the given definition
+func NewUserView(ctx *context.Context, definition Definition) *Object {
+	logger.Println("NewUserView(", definition.Name, ")")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.definition = definition
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = t.current.copy()
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// Collect runs the user's query, updating initial values if needed,
+// and then subtracting initial values from the counters if we want
+// relative values, after which it stores totals.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	columns, rows, err := selectRows(dbh, t.definition.Query)
+	if err != nil {
+		return err
+	}
+	t.columns, t.current = columns, rows
+	t.SetLastCollectTimeNow()
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	t.isDelta = make([]bool, len(t.columns))
+	for i := range t.columns {
+		t.isDelta[i] = t.definition.isDelta(t.columns[i])
+	}
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.initial.needsRefresh(t.current, t.isDelta) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+
+	t.makeResults()
+
+	logger.Println("user_view.Object.Collect(", t.definition.Name, ") END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+// generate the results and totals and sort data
+func (t *Object) makeResults() {
+	t.results = t.current.copy()
+	if t.WantRelativeStats() {
+		t.results.subtract(t.initial, t.isDelta)
+	}
+
+	t.results.sort(t.definition.Name, t.columns)
+	t.totals = t.results.totals()
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+	t.makeResults()
+}
+
+// Headings returns the headings of the object
+func (t Object) Headings() string {
+	return headings(t.columns)
+}
+
+// RowContent returns a slice of strings containing the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(len(t.columns)))
+	}
+
+	return rows
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(len(t.columns))
+}
+
+// TotalRowContent returns a row containing the totals
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(len(t.columns))
+}
+
+// Description returns the description given in ~/.pstoprc
+func (t Object) Description() string {
+	return t.definition.Description
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true if any column is a counter
+func (t Object) HaveRelativeStats() bool {
+	return t.definition.haveDeltas()
+}
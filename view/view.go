@@ -4,9 +4,17 @@ import (
 	"database/sql"
 	"errors"
 	"log"
+	"strconv"
 
 	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
 	"github.com/sjmudd/ps-top/table"
+	"github.com/sjmudd/ps-top/user_view"
+)
+
+const (
+	maxShortcut   = 9   // views can be selected with the keys 1-9
+	firstUserView = 100 // views defined in ~/.pstoprc are numbered from here
 )
 
 // Code represents the type of information to view (as an int)
@@ -14,15 +22,33 @@ type Code int
 
 // View* constants represent different views we can see
 const (
-	ViewNone    Code = iota // view nothing (should never be set)
-	ViewLatency Code = iota // view the table latency information
-	ViewOps     Code = iota // view the table information by number of operations
-	ViewIO      Code = iota // view the file I/O information
-	ViewLocks   Code = iota // view lock information
-	ViewUsers   Code = iota // view user information
-	ViewMutex   Code = iota // view mutex information
-	ViewStages  Code = iota // view SQL stages information
-	ViewMemory  Code = iota // view memory usage (5.7 only)
+	ViewNone       Code = iota // view nothing (should never be set)
+	ViewLatency    Code = iota // view the table latency information
+	ViewOps        Code = iota // view the table information by number of operations
+	ViewIO         Code = iota // view the file I/O information
+	ViewLocks      Code = iota // view lock information
+	ViewUsers      Code = iota // view user information
+	ViewMutex      Code = iota // view mutex information
+	ViewStages     Code = iota // view SQL stages information
+	ViewMemory     Code = iota // view memory usage (5.7 only)
+	ViewLockWaits  Code = iota // view lock wait chains (8.0 only)
+	ViewEfficiency Code = iota // view statement efficiency (rows examined vs used)
+	ViewTableCache Code = iota // view table cache and handler statistics
+	ViewKeyCache   Code = iota // view MyISAM key cache and Aria page cache statistics
+	ViewQueryCache Code = iota // view query cache and thread pool statistics
+	ViewStmtStages Code = iota // view the stages of the top statements
+	ViewUnusedIdx  Code = iota // view the indexes which are maintained but not read
+	ViewBinlog     Code = iota // view the binary log events by type
+	ViewDDL        Code = iota // view the DDL statements in progress
+	ViewLockUsers  Code = iota // view the locks held and waited for by user
+	ViewPSSizing   Code = iota // view the performance_schema sizing variables and what they have lost
+	ViewPrograms   Code = iota // view stored procedures, functions, triggers and events (5.7+)
+	ViewSLO        Code = iota // view the table I/O latency against the SLOs in ~/.pstoprc
+	ViewPrepared   Code = iota // view prepared statements by owner and text (5.7+)
+	ViewWaits      Code = iota // view the wait events rolled up by class
+	ViewIdle       Code = iota // view the time each connection was idle against busy
+	ViewProxyQuery Code = iota // view the queries ProxySQL has seen by digest
+	ViewProxyConns Code = iota // view the connections and queries of ProxySQL to each backend
 )
 
 // View holds the integer type of view (maybe need to fix this setup)
@@ -36,42 +62,100 @@ var (
 
 	nextView map[Code]Code // map from one view to the next taking into account invalid views
 	prevView map[Code]Code // map from one view to the next taking into account invalid views
+
+	shortcuts map[int]Code // map from a number (1-9) to the view it selects
+
+	proxyViews map[Code]bool // views read from the ProxySQL admin interface rather than the server
+
+	userViews     map[Code]user_view.Definition // views defined in ~/.pstoprc
+	userViewCodes []Code                        // user views in the order they are shown
 )
 
 func init() {
 	names = map[Code]string{
-		ViewLatency: "table_io_latency",
-		ViewOps:     "table_io_ops",
-		ViewIO:      "file_io_latency",
-		ViewLocks:   "table_lock_latency",
-		ViewUsers:   "user_latency",
-		ViewMutex:   "mutex_latency",
-		ViewStages:  "stages_latency",
-		ViewMemory:  "memory_usage",
+		ViewLatency:    "table_io_latency",
+		ViewOps:        "table_io_ops",
+		ViewIO:         "file_io_latency",
+		ViewLocks:      "table_lock_latency",
+		ViewUsers:      "user_latency",
+		ViewMutex:      "mutex_latency",
+		ViewStages:     "stages_latency",
+		ViewMemory:     "memory_usage",
+		ViewLockWaits:  "lock_waits",
+		ViewEfficiency: "statement_efficiency",
+		ViewTableCache: "table_cache",
+		ViewKeyCache:   "key_cache",
+		ViewQueryCache: "query_cache",
+		ViewStmtStages: "statement_stages",
+		ViewUnusedIdx:  "unused_indexes",
+		ViewBinlog:     "binlog_events",
+		ViewDDL:        "ddl_progress",
+		ViewLockUsers:  "lock_users",
+		ViewPSSizing:   "ps_sizing",
+		ViewPrograms:   "program_latency",
+		ViewSLO:        "slo_budget",
+		ViewPrepared:   "prepared_statements",
+		ViewWaits:      "wait_events",
+		ViewIdle:       "idle_time",
+		ViewProxyQuery: "proxy_digests",
+		ViewProxyConns: "proxy_backends",
 	}
 
 	tables = map[Code]table.Access{
-		ViewLatency: table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
-		ViewOps:     table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
-		ViewIO:      table.NewAccess("performance_schema", "file_summary_by_instance"),
-		ViewLocks:   table.NewAccess("performance_schema", "table_lock_waits_summary_by_table"),
-		ViewUsers:   table.NewAccess("information_schema", "processlist"),
-		ViewMutex:   table.NewAccess("performance_schema", "events_waits_summary_global_by_event_name"),
-		ViewStages:  table.NewAccess("performance_schema", "events_stages_summary_global_by_event_name"),
-		ViewMemory:  table.NewAccess("performance_schema", "memory_summary_global_by_event_name"),
+		ViewLatency:    table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
+		ViewOps:        table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
+		ViewIO:         table.NewAccess("performance_schema", "file_summary_by_instance"),
+		ViewLocks:      table.NewAccess("performance_schema", "table_lock_waits_summary_by_table"),
+		ViewUsers:      table.NewAccess("performance_schema", "threads"),
+		ViewMutex:      table.NewAccess("performance_schema", "events_waits_summary_global_by_event_name"),
+		ViewStages:     table.NewAccess("performance_schema", "events_stages_summary_global_by_event_name"),
+		ViewMemory:     table.NewAccess("performance_schema", "memory_summary_global_by_event_name"),
+		ViewLockWaits:  table.NewAccess("performance_schema", "data_lock_waits").Also("information_schema", "INNODB_TRX"),
+		ViewEfficiency: table.NewAccess("performance_schema", "events_statements_summary_by_digest"),
+		ViewTableCache: table.NewAccess("performance_schema", "table_handles"),
+		ViewKeyCache:   table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
+		ViewQueryCache: table.NewAccess("performance_schema", "global_status"),
+		ViewStmtStages: table.NewAccess("performance_schema", "events_stages_history_long"),
+		ViewUnusedIdx:  table.NewAccess("performance_schema", "table_io_waits_summary_by_index_usage"),
+		ViewBinlog:     table.NewAccess("performance_schema", "global_variables"),
+		ViewDDL:        table.NewAccess("performance_schema", "events_stages_current"),
+		ViewLockUsers:  table.NewAccess("performance_schema", "metadata_locks"),
+		ViewPSSizing:   table.NewAccess("performance_schema", "threads"),
+		ViewPrograms:   table.NewAccess("performance_schema", "events_statements_summary_by_program"),
+		ViewSLO:        table.NewAccess("performance_schema", "table_io_waits_summary_by_table"),
+		ViewPrepared:   table.NewAccess("performance_schema", "prepared_statements_instances"),
+		ViewWaits:      table.NewAccess("performance_schema", "events_waits_summary_global_by_event_name"),
+		ViewIdle:       table.NewAccess("performance_schema", "events_waits_summary_by_thread_by_event_name"),
+		ViewProxyQuery: table.NewAccess("stats", "stats_mysql_query_digest"),
+		ViewProxyConns: table.NewAccess("stats", "stats_mysql_connection_pool"),
+	}
+
+	proxyViews = map[Code]bool{
+		ViewProxyQuery: true,
+		ViewProxyConns: true,
 	}
 }
 
-// ValidateViews check which views are readable. If none are we give a fatal error
-func ValidateViews(dbh *sql.DB) error {
+// ValidateViews check which views are readable. If none are we give a fatal error.
+// The proxy views are checked on the ProxySQL admin interface, if there is one.
+func ValidateViews(dbh, proxy *sql.DB) error {
 	var count int
 	var status string
 	logger.Println("Validating access to views...")
 
+	addUserViews()
+
 	// determine which of the defined views is valid because the underlying table access works
 	for v := range names {
 		ta := tables[v]
-		e := ta.CheckSelectError(dbh)
+		handle := dbh
+		if proxyViews[v] {
+			if proxy == nil {
+				ta.SetUnavailable(errors.New("no ProxySQL admin interface was given with --proxy-admin"))
+			}
+			handle = proxy
+		}
+		e := ta.CheckSelectError(handle)
 		suffix := ""
 		if e == nil {
 			status = "is"
@@ -94,6 +178,28 @@ func ValidateViews(dbh *sql.DB) error {
 	return nil
 }
 
+// addUserViews adds the views defined in ~/.pstoprc to those we know about
+func addUserViews() {
+	userViews = make(map[Code]user_view.Definition)
+	userViewCodes = nil
+
+	for i, definition := range user_view.Definitions() {
+		if _, found := CodeByName(definition.Name); found {
+			log.Fatal("~/.pstoprc: user view '", definition.Name, "' has the same name as an existing view")
+		}
+		code := Code(firstUserView + i)
+		names[code] = definition.Name
+		tables[code] = table.NewQueryAccess(definition.Query)
+		userViews[code] = definition
+		userViewCodes = append(userViewCodes, code)
+	}
+}
+
+// UserViews returns the views defined in ~/.pstoprc indexed by their Code
+func UserViews() map[Code]user_view.Definition {
+	return userViews
+}
+
 /* set the previous and next views taking into account any invalid views
 
 name     selectable?    prev      next
@@ -118,10 +224,15 @@ func setPrevAndNextViews() {
 	}
 
 	// Cleaner way to do this? Probably. Fix later.
-	prevCodeOrder := []Code{ViewMemory, ViewStages, ViewMutex, ViewUsers, ViewLocks, ViewIO, ViewOps, ViewLatency}
-	nextCodeOrder := []Code{ViewLatency, ViewOps, ViewIO, ViewLocks, ViewUsers, ViewMutex, ViewStages, ViewMemory}
+	prevCodeOrder := []Code{ViewProxyConns, ViewProxyQuery, ViewIdle, ViewWaits, ViewPrepared, ViewSLO, ViewPrograms, ViewPSSizing, ViewLockUsers, ViewDDL, ViewBinlog, ViewUnusedIdx, ViewStmtStages, ViewQueryCache, ViewKeyCache, ViewTableCache, ViewEfficiency, ViewLockWaits, ViewMemory, ViewStages, ViewMutex, ViewUsers, ViewLocks, ViewIO, ViewOps, ViewLatency}
+	nextCodeOrder := []Code{ViewLatency, ViewOps, ViewIO, ViewLocks, ViewUsers, ViewMutex, ViewStages, ViewMemory, ViewLockWaits, ViewEfficiency, ViewTableCache, ViewKeyCache, ViewQueryCache, ViewStmtStages, ViewUnusedIdx, ViewBinlog, ViewDDL, ViewLockUsers, ViewPSSizing, ViewPrograms, ViewSLO, ViewPrepared, ViewWaits, ViewIdle, ViewProxyQuery, ViewProxyConns}
+	for i := range userViewCodes {
+		prevCodeOrder = append([]Code{userViewCodes[i]}, prevCodeOrder...)
+		nextCodeOrder = append(nextCodeOrder, userViewCodes[i])
+	}
 	prevView = setValidByValues(prevCodeOrder)
 	nextView = setValidByValues(nextCodeOrder)
+	setShortcuts(nextCodeOrder, rc.Section("views"))
 
 	// print out the results
 	logger.Println("Final mapping of view order:")
@@ -178,6 +289,62 @@ func setValidByValues(orderedCodes []Code) map[Code]Code {
 	return orderedMap
 }
 
+// setShortcuts assigns the numbers 1-9 to views. By default the
+// selectable views are numbered in the order they are cycled through
+// but this may be overridden in the [views] section of ~/.pstoprc, e.g.
+// [views]
+// 1 = table_io_latency
+// 2 = file_io_latency
+func setShortcuts(orderedCodes []Code, config map[string]string) {
+	logger.Println("view.setShortcuts()")
+	shortcuts = make(map[int]Code)
+
+	if len(config) == 0 {
+		number := 1
+		for i := range orderedCodes {
+			if number > maxShortcut {
+				break
+			}
+			if tables[orderedCodes[i]].SelectError() == nil {
+				shortcuts[number] = orderedCodes[i]
+				number++
+			}
+		}
+		return
+	}
+
+	for key, name := range config {
+		number, err := strconv.Atoi(key)
+		if err != nil || number < 1 || number > maxShortcut {
+			log.Fatal("~/.pstoprc [views]: '", key, "' should be a number from 1 to ", maxShortcut)
+		}
+		code, ok := CodeByName(name)
+		if !ok {
+			log.Fatal("~/.pstoprc [views]: ", key, " = '", name, "' is not a known view")
+		}
+		if tables[code].SelectError() != nil {
+			logger.Println("view.setShortcuts():", name, "is not SELECTable, ignoring shortcut", number)
+			continue
+		}
+		shortcuts[number] = code
+	}
+}
+
+// CodeByName returns the Code of the view with the given name
+func CodeByName(name string) (Code, bool) {
+	for code := range names {
+		if names[code] == name {
+			return code, true
+		}
+	}
+	return ViewNone, false
+}
+
+// IsSelectable returns whether the table behind the given view can be SELECTed from
+func IsSelectable(viewCode Code) bool {
+	return tables[viewCode].SelectError() == nil
+}
+
 // SetNext changes the current view to the next one
 func (v *View) SetNext() Code {
 	v.code = nextView[v.code]
@@ -230,6 +397,28 @@ func (v *View) SetByName(name string) {
 	log.Fatal("Asked for a view name, '", name, "' which doesn't exist. Try one of:", allViews)
 }
 
+// SetByNumber sets the view to the one assigned to the given number (1-9).
+// It returns false if no view is assigned to the number.
+func (v *View) SetByNumber(number int) bool {
+	code, ok := shortcuts[number]
+	if !ok {
+		return false
+	}
+	v.code = code
+
+	return true
+}
+
+// Number returns the number (1-9) assigned to the current view or 0 if none is
+func (v View) Number() int {
+	for number, code := range shortcuts {
+		if code == v.code {
+			return number
+		}
+	}
+	return 0
+}
+
 // Get returns the Code version of the current view
 func (v View) Get() Code {
 	return v.code
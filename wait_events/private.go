@@ -0,0 +1,239 @@
+package wait_events
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+	"github.com/sjmudd/ps-top/relative_stats"
+	"github.com/sjmudd/ps-top/sort_keys"
+)
+
+// topLevel is the number of components of the event names shown when
+// nothing is expanded, e.g. wait/io
+const topLevel = 2
+
+// Row contains a row from performance_schema.events_waits_summary_global_by_event_name,
+// or the rollup of the rows whose name starts with the name of a branch.
+type Row struct {
+	name         string
+	sumTimerWait uint64
+	countStar    uint64
+	depth        int  // levels below the top level
+	branch       bool // other events start with this name
+	expanded     bool // the events below the branch are shown
+}
+
+// columns gives the field of a Row each column is read into
+var columns = lib.Columns[Row]{
+	"EVENT_NAME":     func(r *Row) interface{} { return &r.name },
+	"SUM_TIMER_WAIT": func(r *Row) interface{} { return &r.sumTimerWait },
+	"COUNT_STAR":     func(r *Row) interface{} { return &r.countStar },
+}
+
+// Rows contains a slice of Row
+type Rows []Row
+
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %10s %10s %6s|%s", "Latency", "Ops", "Avg Wait", "%", "Wait Event")
+}
+
+// label returns the name shown: the last component of the name indented
+// below its branch, marked + if the branch may be expanded or - if it may
+// be collapsed
+func (row *Row) label() string {
+	name := row.name
+	if row.depth > 0 {
+		name = name[strings.LastIndex(name, "/")+1:]
+	}
+	marker := "  "
+	if row.branch && row.expanded {
+		marker = "- "
+	} else if row.branch {
+		marker = "+ "
+	}
+
+	return strings.Repeat("  ", row.depth) + marker + name
+}
+
+// generate a printable result
+func (row *Row) rowContent(totals Row) string {
+	name := row.label()
+	if row.name == "" {
+		name = ""
+	} else if row.name == "Totals" {
+		name = row.name
+	}
+	average := ""
+	if row.countStar > 0 {
+		average = lib.FormatTime(row.sumTimerWait / row.countStar)
+	}
+
+	return fmt.Sprintf("%10s %10s %10s %6s|%s",
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatAmount(row.countStar),
+		average,
+		lib.FormatPct(lib.MyDivide(row.sumTimerWait, totals.sumTimerWait)),
+		name)
+}
+
+func (row *Row) add(other Row) {
+	row.sumTimerWait += other.sumTimerWait
+	row.countStar += other.countStar
+}
+
+// subtract the countable values in one row from another
+func (row *Row) subtract(other Row) {
+	row.sumTimerWait -= other.sumTimerWait
+	row.countStar -= other.countStar
+}
+
+func (rows Rows) totals() Row {
+	var totals Row
+	totals.name = "Totals"
+
+	for i := range rows {
+		totals.add(rows[i])
+	}
+
+	return totals
+}
+
+// selectRows returns the wait events which have been waited for
+func selectRows(dbh *sql.DB) (Rows, error) {
+	sql := "SELECT EVENT_NAME, SUM_TIMER_WAIT, COUNT_STAR FROM events_waits_summary_global_by_event_name WHERE SUM_TIMER_WAIT > 0"
+	t, err := lib.ReadRowsFromSQL(dbh, columns, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// sortKeys returns the keys the rows may be sorted by
+func (rows Rows) sortKeys() sort_keys.Keys {
+	return sort_keys.Keys{
+		"latency": func(i, j int) int { return sort_keys.Descending(rows[i].sumTimerWait, rows[j].sumTimerWait) },
+		"ops":     func(i, j int) int { return sort_keys.Descending(rows[i].countStar, rows[j].countStar) },
+		"name":    func(i, j int) int { return sort_keys.Ascending(rows[i].name, rows[j].name) },
+	}
+}
+
+// sort by value (descending) but also by "name" (ascending) if the values are the same
+// after any configured sort keys
+func (rows Rows) sort() {
+	sort.Slice(rows, rows.sortKeys().Less("wait_events", "latency", "name"))
+}
+
+// register the sort keys so those configured can be checked on startup
+func init() {
+	sort_keys.Register("wait_events", Rows(nil).sortKeys())
+}
+
+// differ matches the rows by name to subtract their initial values
+var differ = relative_stats.Differ[Row, string]{
+	Key:      func(row Row) string { return row.name },
+	Counters: func(row Row) []uint64 { return []uint64{row.countStar, row.sumTimerWait} },
+	Subtract: (*Row).subtract,
+}
+
+// remove the initial values from those rows where there's a match
+func (rows *Rows) subtract(initial Rows) {
+	differ.SubtractRows(*rows, initial)
+}
+
+// if the data in t2 is "newer", "has more values" than t then it needs refreshing.
+// check this by comparing the totals of the rows found in both.
+func (rows Rows) needsRefresh(otherRows Rows) bool {
+	return differ.NeedsRefresh(rows, otherRows)
+}
+
+// branchOf returns the name of the branch at the given depth holding the
+// event, or the event itself if its name is no longer
+func branchOf(name string, depth int) string {
+	parts := strings.Split(name, "/")
+	if topLevel+depth >= len(parts) {
+		return name
+	}
+
+	return strings.Join(parts[:topLevel+depth], "/")
+}
+
+// parent returns the name of the branch holding the row's name, or ""
+// at the top level
+func parent(name string) string {
+	depth := strings.Count(name, "/") + 1 - topLevel
+	if depth <= 0 {
+		return ""
+	}
+
+	return branchOf(name, depth-1)
+}
+
+// rollup adds up the events of each branch at the given depth, with the
+// events below those which are expanded following them, each level
+// sorted on its own
+func (rows Rows) rollup(expanded map[string]bool, depth int) Rows {
+	var branches Rows
+	below := make(map[string]Rows)
+	index := make(map[string]int)
+
+	for i := range rows {
+		name := branchOf(rows[i].name, depth)
+		j, found := index[name]
+		if !found {
+			j = len(branches)
+			index[name] = j
+			branches = append(branches, Row{name: name, depth: depth, expanded: expanded[name]})
+		}
+		branches[j].add(rows[i])
+		if name != rows[i].name {
+			branches[j].branch = true
+			below[name] = append(below[name], rows[i])
+		}
+	}
+	branches.sort()
+
+	var result Rows
+	for i := range branches {
+		result = append(result, branches[i])
+		if branches[i].branch && branches[i].expanded {
+			result = append(result, below[branches[i].name].rollup(expanded, depth+1)...)
+		}
+	}
+
+	return result
+}
+
+// describe a whole row
+func (row Row) String() string {
+	return fmt.Sprintf("%s|%10s %6s",
+		row.name,
+		lib.FormatTime(row.sumTimerWait),
+		lib.FormatAmount(row.countStar))
+}
+
+// describe a whole table
+func (rows Rows) String() string {
+	s := make([]string, 0, len(rows))
+
+	for i := range rows {
+		s = append(s, rows[i].String())
+	}
+
+	return strings.Join(s, "\n")
+}
+
+// values returns the name and counter values of the row
+func (row Row) values() ps_table.RowValues {
+	return ps_table.RowValues{
+		Name: row.name,
+		Values: map[string]uint64{
+			"count_star":     row.countStar,
+			"sum_timer_wait": row.sumTimerWait,
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package wait_events
+
+import (
+	"testing"
+)
+
+func TestRollup(t *testing.T) {
+	rows := Rows{
+		{name: "wait/io/file/innodb/innodb_data_file", sumTimerWait: 500, countStar: 5},
+		{name: "wait/io/file/innodb/innodb_log_file", sumTimerWait: 200, countStar: 2},
+		{name: "wait/io/file/sql/binlog", sumTimerWait: 400, countStar: 4},
+		{name: "wait/io/table/sql/handler", sumTimerWait: 300, countStar: 3},
+		{name: "wait/synch/mutex/innodb/buf_pool_mutex", sumTimerWait: 900, countStar: 9},
+		{name: "idle", sumTimerWait: 100, countStar: 1},
+	}
+
+	tests := []struct {
+		expanded map[string]bool
+		want     []string
+	}{
+		{map[string]bool{}, []string{"wait/io", "wait/synch", "idle"}},
+		{map[string]bool{"wait/io": true}, []string{"wait/io", "wait/io/file", "wait/io/table", "wait/synch", "idle"}},
+		{map[string]bool{"wait/io": true, "wait/io/file": true}, []string{"wait/io", "wait/io/file", "wait/io/file/innodb", "wait/io/file/sql", "wait/io/table", "wait/synch", "idle"}},
+		{map[string]bool{"wait/io/file": true}, []string{"wait/io", "wait/synch", "idle"}}, // hidden below a collapsed branch
+	}
+
+	for _, test := range tests {
+		got := rows.rollup(test.expanded, 0)
+		if len(got) != len(test.want) {
+			t.Errorf("rollup(%v) returned %d row(s), want %d: %v", test.expanded, len(got), len(test.want), got)
+			continue
+		}
+		for i := range test.want {
+			if got[i].name != test.want[i] {
+				t.Errorf("rollup(%v)[%d] = %q, want %q", test.expanded, i, got[i].name, test.want[i])
+			}
+		}
+	}
+
+	got := rows.rollup(map[string]bool{"wait/io": true}, 0)
+	if got[0].sumTimerWait != 1400 || got[0].countStar != 14 || !got[0].branch || !got[0].expanded {
+		t.Errorf("rollup() wait/io = %+v, want the sum of its events, expanded", got[0])
+	}
+	if got[1].depth != 1 || got[1].sumTimerWait != 1100 {
+		t.Errorf("rollup() wait/io/file = %+v, want depth 1 and the sum of its events", got[1])
+	}
+	if got[4].branch {
+		t.Errorf("rollup() idle = %+v, want a single event", got[4])
+	}
+}
+
+func TestParent(t *testing.T) {
+	tests := map[string]string{
+		"wait/io/file/innodb/innodb_data_file": "wait/io/file/innodb",
+		"wait/io/file":                         "wait/io",
+		"wait/io":                              "",
+		"idle":                                 "",
+	}
+
+	for name, want := range tests {
+		if got := parent(name); got != want {
+			t.Errorf("parent(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package wait_events shows the events_waits_summary_global_by_event_name
+// table rolled up by the hierarchy of the event names, e.g.
+// wait/io/file/innodb/innodb_data_file is part of wait/io/file which is
+// part of wait/io. Only the top level classes are shown until a branch
+// is expanded to show the events below it.
+package wait_events
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Object holds a table of rows
+type Object struct {
+	baseobject.BaseObject                 // embedded
+	initial               Rows            // initial data for relative values
+	mark                  Rows            // marked data for relative values since the mark
+	current               Rows            // last loaded values
+	results               Rows            // results (maybe with subtraction) rolled up by branch
+	totals                Row             // totals of results
+	expanded              map[string]bool // the branches whose events are shown
+}
+
+// NewWaitEvents returns a pointer to an object of this type
+func NewWaitEvents(ctx *context.Context) *Object {
+	logger.Println("NewWaitEvents()")
+	o := new(Object)
+	o.SetContext(ctx)
+	o.expanded = make(map[string]bool)
+
+	return o
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+// SetMarkFromCurrent keeps the current values as the mark, leaving the
+// initial values alone
+func (t *Object) SetMarkFromCurrent() {
+	t.mark = append(t.mark[:0], t.current...)
+	t.SetMarkCollectTime(t.LastCollectTime())
+
+	t.makeResults()
+}
+
+// clearMark removes the mark
+func (t *Object) clearMark() {
+	t.mark = t.mark[:0]
+	t.SetMarkCollectTime(time.Time{})
+}
+
+// Collect collects data from the db, updating initial
+// values if needed, and then subtracting initial values if we want
+// relative values, after which it stores totals.
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+	rows, err := selectRows(dbh)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+
+	logger.Println("t.current collected", len(t.current), "row(s) from SELECT")
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+
+	// check for reload initial characteristics
+	if t.initial.needsRefresh(t.current) {
+		logger.Println("t.initial: copying from t.current (data needs refreshing)")
+		t.copyCurrentToInitial()
+	}
+	if len(t.mark) > 0 && t.mark.needsRefresh(t.current) {
+		logger.Println("t.mark: cleared (data needs refreshing)")
+		t.clearMark()
+	}
+
+	t.makeResults()
+
+	logger.Println("wait_events.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+func (t *Object) makeResults() {
+	rows := append(Rows{}, t.current...)
+	if t.WantRelativeStats() && t.SinceMark() {
+		rows.subtract(t.mark)
+	} else if t.WantRelativeStats() {
+		rows.subtract(t.initial)
+	}
+
+	t.results = rows.rollup(t.expanded, 0)
+	t.totals = rows.totals()
+}
+
+// Expand shows the events below the branch with the given name, if it
+// is one
+func (t *Object) Expand(name string) {
+	for i := range t.results {
+		if t.results[i].name == name && t.results[i].branch {
+			t.expanded[name] = true
+			t.makeResults()
+			return
+		}
+	}
+}
+
+// Collapse hides the events below the branch with the given name, or
+// if it isn't expanded those of the branch holding it. The name of the
+// branch collapsed is returned, or "" if there was none.
+func (t *Object) Collapse(name string) string {
+	if !t.expanded[name] {
+		name = parent(name)
+	}
+	if name == "" {
+		return ""
+	}
+	delete(t.expanded, name)
+	t.makeResults()
+
+	return name
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+
+	t.makeResults()
+}
+
+// EmptyRowContent returns a string representation of no data
+func (t Object) EmptyRowContent() string {
+	var r Row
+
+	return r.rowContent(r)
+}
+
+// Headings returns a string representation of the headings
+func (t *Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns a string representation of the row content
+func (t Object) RowContent() []string {
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(t.totals))
+	}
+
+	return rows
+}
+
+// TotalRowContent returns a string representation of the totals of the table
+func (t Object) TotalRowContent() string {
+	return t.totals.rowContent(t.totals)
+}
+
+// Description returns a description of the table
+func (t Object) Description() string {
+	return fmt.Sprintf("Wait Events (events_waits_summary_global_by_event_name) %d rows (>/<: expand/collapse the row selected)", len(t.results))
+}
+
+// Len returns the length of the result set
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
+
+// Values returns the current values of each row
+func (t Object) Values() []ps_table.RowValues {
+	rows := t.current.rollup(t.expanded, 0)
+	values := make([]ps_table.RowValues, 0, len(rows))
+
+	for i := range rows {
+		values = append(values, rows[i].values())
+	}
+
+	return values
+}
+
+// Results returns the values of each row shown
+func (t Object) Results() []ps_table.RowValues {
+	values := make([]ps_table.RowValues, 0, len(t.results))
+
+	for i := range t.results {
+		values = append(values, t.results[i].values())
+	}
+
+	return values
+}
@@ -0,0 +1,40 @@
+package wait_info
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+)
+
+// defaultIntervals are used for views which change slowly and are
+// expensive to collect, unless configured otherwise
+var defaultIntervals = map[string]time.Duration{
+	"unused_indexes": time.Minute,
+	"ps_sizing":      10 * time.Second,
+}
+
+// Intervals returns the collection intervals configured per view in
+// the [interval] section of ~/.pstoprc, indexed by view name, e.g.
+// [interval]
+// file_io_latency = 10
+// The values are in seconds and must be at least 1.
+func Intervals() map[string]time.Duration {
+	intervals := make(map[string]time.Duration)
+	for view, interval := range defaultIntervals {
+		intervals[view] = interval
+	}
+
+	for view, value := range rc.Section("interval") {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			log.Fatal("Invalid interval '", value, "' for view ", view, " in ~/.pstoprc. It should be a number of seconds")
+		}
+		intervals[view] = time.Second * time.Duration(seconds)
+	}
+	logger.Println("wait_info.Intervals() found intervals for", len(intervals), "view(s)")
+
+	return intervals
+}
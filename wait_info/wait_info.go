@@ -43,6 +43,19 @@ func (wi WaitInfo) LastCollected() time.Time {
 	return wi.lastCollected
 }
 
+// Missed returns the number of whole intervals which passed since the
+// last collection without collecting, e.g. because collecting took
+// longer than the interval
+func (wi WaitInfo) Missed() int {
+	if wi.lastCollected.IsZero() || wi.collectInterval <= 0 {
+		return 0
+	}
+	if missed := int(time.Since(wi.lastCollected)/wi.collectInterval) - 1; missed > 0 {
+		return missed
+	}
+	return 0
+}
+
 // TimeToWait returns the amount of time to wait before doing the next collection
 func (wi WaitInfo) TimeToWait() time.Duration {
 	now := time.Now()
@@ -0,0 +1,326 @@
+package watch_table
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sjmudd/ps-top/lib"
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/relative_stats"
+)
+
+// the sources of the rows, in the order they are shown
+const (
+	sourceIO     = "io"
+	sourceIndex  = "index"
+	sourceLock   = "lock"
+	sourceFile   = "file"
+	sourceDigest = "digest"
+)
+
+// sourceOrder is the position of each source on the screen
+var sourceOrder = map[string]int{sourceIO: 0, sourceIndex: 1, sourceLock: 2, sourceFile: 3, sourceDigest: 4}
+
+// Row contains the latency and operations of one thing about the table,
+// e.g. the fetches from it or a digest using it
+type Row struct {
+	source  string // where the row comes from
+	name    string // what the row describes
+	latency uint64
+	ops     uint64
+}
+
+// Rows contains the rows of each source
+type Rows []Row
+
+// key identifies the row
+func (row Row) key() string {
+	return row.source + " " + row.name
+}
+
+// add the values of another row
+func (row *Row) add(other Row) {
+	row.latency += other.latency
+	row.ops += other.ops
+}
+
+// the queries of each source, selecting the columns identifying the
+// table again so the rows can be checked
+const (
+	ioQuery = `
+SELECT	OBJECT_SCHEMA, OBJECT_NAME,
+	COUNT_FETCH, SUM_TIMER_FETCH,
+	COUNT_INSERT, SUM_TIMER_INSERT,
+	COUNT_UPDATE, SUM_TIMER_UPDATE,
+	COUNT_DELETE, SUM_TIMER_DELETE
+FROM	table_io_waits_summary_by_table
+WHERE	OBJECT_SCHEMA = ? AND OBJECT_NAME = ?`
+	indexQuery = `
+SELECT	OBJECT_SCHEMA, OBJECT_NAME,
+	COALESCE(INDEX_NAME, ''), COUNT_STAR, SUM_TIMER_WAIT
+FROM	table_io_waits_summary_by_index_usage
+WHERE	OBJECT_SCHEMA = ? AND OBJECT_NAME = ?`
+	lockQuery = `
+SELECT	OBJECT_SCHEMA, OBJECT_NAME,
+	COUNT_READ, SUM_TIMER_READ,
+	COUNT_WRITE, SUM_TIMER_WRITE
+FROM	table_lock_waits_summary_by_table
+WHERE	OBJECT_SCHEMA = ? AND OBJECT_NAME = ?`
+	fileQuery = `
+SELECT	FILE_NAME, COUNT_STAR, SUM_TIMER_WAIT
+FROM	file_summary_by_instance
+WHERE	FILE_NAME LIKE ?`
+	digestQuery = `
+SELECT	COALESCE(SCHEMA_NAME, ''), DIGEST_TEXT, COUNT_STAR, SUM_TIMER_WAIT
+FROM	events_statements_summary_by_digest
+WHERE	DIGEST_TEXT LIKE ?`
+)
+
+// selectRows returns the rows of each source about the table
+func selectRows(dbh *sql.DB, schema, table string) (Rows, error) {
+	var t Rows
+
+	logger.Println("watch_table.selectRows(", schema, ",", table, ")")
+	for _, selectSource := range []func(*sql.DB, string, string) (Rows, error){
+		selectIO, selectIndexes, selectLocks, selectFiles, selectDigests,
+	} {
+		rows, err := selectSource(dbh, schema, table)
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, rows...)
+	}
+	logger.Println("recovered", len(t), "row(s)")
+
+	return t, nil
+}
+
+// selectIO returns the table I/O of each operation
+func selectIO(dbh *sql.DB, schema, table string) (Rows, error) {
+	rows, err := dbh.Query(ioQuery, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var t Rows
+	for rows.Next() {
+		var rowSchema, rowTable string
+		fetch, insert, update, del := Row{name: "fetch"}, Row{name: "insert"}, Row{name: "update"}, Row{name: "delete"}
+		if err := rows.Scan(&rowSchema, &rowTable,
+			&fetch.ops, &fetch.latency,
+			&insert.ops, &insert.latency,
+			&update.ops, &update.latency,
+			&del.ops, &del.latency); err != nil {
+			return nil, err
+		}
+		if rowSchema == schema && rowTable == table {
+			t = t.merge(sourceIO, fetch, insert, update, del)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+// selectIndexes returns the table I/O using each index, that without an
+// index being shown as <no index>
+func selectIndexes(dbh *sql.DB, schema, table string) (Rows, error) {
+	rows, err := dbh.Query(indexQuery, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var t Rows
+	for rows.Next() {
+		var rowSchema, rowTable string
+		var r Row
+		if err := rows.Scan(&rowSchema, &rowTable, &r.name, &r.ops, &r.latency); err != nil {
+			return nil, err
+		}
+		if r.name == "" {
+			r.name = "<no index>"
+		}
+		if rowSchema == schema && rowTable == table {
+			t = t.merge(sourceIndex, r)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+// selectLocks returns the read and write lock waits
+func selectLocks(dbh *sql.DB, schema, table string) (Rows, error) {
+	rows, err := dbh.Query(lockQuery, schema, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var t Rows
+	for rows.Next() {
+		var rowSchema, rowTable string
+		read, write := Row{name: "read locks"}, Row{name: "write locks"}
+		if err := rows.Scan(&rowSchema, &rowTable, &read.ops, &read.latency, &write.ops, &write.latency); err != nil {
+			return nil, err
+		}
+		if rowSchema == schema && rowTable == table {
+			t = t.merge(sourceLock, read, write)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+// tableFile returns a regexp matching the files of the table, including
+// those of its partitions
+func tableFile(schema, table string) *regexp.Regexp {
+	return regexp.MustCompile(`/` + regexp.QuoteMeta(schema) + `/` + regexp.QuoteMeta(table) + `(#[Pp]#[^/]+)?\.(ibd|MYD|MYI)$`)
+}
+
+// selectFiles returns the file I/O of each file of the table
+func selectFiles(dbh *sql.DB, schema, table string) (Rows, error) {
+	rows, err := dbh.Query(fileQuery, "%/"+schema+"/"+table+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	re := tableFile(schema, table)
+	var t Rows
+	for rows.Next() {
+		var r Row
+		if err := rows.Scan(&r.name, &r.ops, &r.latency); err != nil {
+			return nil, err
+		}
+		if re.MatchString(r.name) {
+			r.name = r.name[strings.LastIndex(r.name, "/")+1:]
+			t = t.merge(sourceFile, r)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+// usesTable is true if the digest text names the table, either in the
+// digest's schema or qualified by its own
+func usesTable(digestSchema, digestText, schema, table string) bool {
+	return strings.Contains(digestText, "`"+schema+"` . `"+table+"`") ||
+		(digestSchema == schema && strings.Contains(digestText, "`"+table+"`"))
+}
+
+// selectDigests returns the statement digests using the table
+func selectDigests(dbh *sql.DB, schema, table string) (Rows, error) {
+	rows, err := dbh.Query(digestQuery, "%`"+table+"`%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var t Rows
+	for rows.Next() {
+		var digestSchema string
+		var r Row
+		if err := rows.Scan(&digestSchema, &r.name, &r.ops, &r.latency); err != nil {
+			return nil, err
+		}
+		r.name = strings.Join(strings.Fields(r.name), " ")
+		if usesTable(digestSchema, r.name, schema, table) {
+			t = t.merge(sourceDigest, r)
+		}
+	}
+
+	return t, rows.Err()
+}
+
+// merge adds the rows of the source, adding together those with the same name
+func (rows Rows) merge(source string, others ...Row) Rows {
+	for _, other := range others {
+		other.source = source
+		found := false
+		for i := range rows {
+			if rows[i].key() == other.key() {
+				rows[i].add(other)
+				found = true
+				break
+			}
+		}
+		if !found {
+			rows = append(rows, other)
+		}
+	}
+
+	return rows
+}
+
+// differ matches the rows by source and name to subtract their initial
+// values, e.g. ignoring those of a table which was dropped and created again
+var differ = relative_stats.Differ[Row, string]{
+	Key:      Row.key,
+	Counters: func(row Row) []uint64 { return []uint64{row.latency, row.ops} },
+	Subtract: func(row *Row, other Row) {
+		row.latency -= other.latency
+		row.ops -= other.ops
+	},
+}
+
+// remove the initial values from those rows where there's a match
+func (rows Rows) subtract(initial Rows) {
+	differ.SubtractRows(rows, initial)
+}
+
+// totalsBySource returns the totals of the rows of each source
+func (rows Rows) totalsBySource() map[string]Row {
+	totals := make(map[string]Row)
+
+	for i := range rows {
+		total := totals[rows[i].source]
+		total.source = rows[i].source
+		total.add(rows[i])
+		totals[rows[i].source] = total
+	}
+
+	return totals
+}
+
+// sort by source and then by latency (descending) and name within each source
+func (rows Rows) sort() {
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch {
+		case rows[i].source != rows[j].source:
+			return sourceOrder[rows[i].source] < sourceOrder[rows[j].source]
+		case rows[i].latency != rows[j].latency:
+			return rows[i].latency > rows[j].latency
+		default:
+			return rows[i].name < rows[j].name
+		}
+	})
+}
+
+// average returns the average latency of an operation (if any)
+func average(latency, ops uint64) string {
+	if ops == 0 {
+		return ""
+	}
+
+	return lib.FormatTime(latency / ops)
+}
+
+// headings of the view
+func (row *Row) headings() string {
+	return fmt.Sprintf("%10s %6s %8s %10s %-6s|%s", "Latency", "%", "Ops", "Avg", "Source", "Name")
+}
+
+// generate a printable result with the percentage of the latency of its source
+func (row *Row) rowContent(totals Row) string {
+	return fmt.Sprintf("%10s %6s %8s %10s %-6s|%s",
+		lib.FormatTime(row.latency),
+		lib.FormatPct(lib.MyDivide(row.latency, totals.latency)),
+		lib.FormatAmount(row.ops),
+		average(row.latency, row.ops),
+		row.source,
+		row.name)
+}
@@ -0,0 +1,69 @@
+package watch_table
+
+import (
+	"testing"
+)
+
+func TestTableFile(t *testing.T) {
+	re := tableFile("shop", "events")
+	tests := []struct {
+		file  string
+		match bool
+	}{
+		{"/var/lib/mysql/shop/events.ibd", true},
+		{"/var/lib/mysql/shop/events#P#p2025.ibd", true},
+		{"/var/lib/mysql/shop/events#p#p2026.ibd", true},
+		{"/var/lib/mysql/audit/events.ibd", false},
+		{"/var/lib/mysql/shop/events_old.ibd", false},
+		{"/var/lib/mysql/shop/events.frm", false},
+	}
+
+	for _, test := range tests {
+		if got := re.MatchString(test.file); got != test.match {
+			t.Errorf("tableFile(shop, events).MatchString(%q) = %v, want %v", test.file, got, test.match)
+		}
+	}
+}
+
+func TestUsesTable(t *testing.T) {
+	tests := []struct {
+		schema string
+		text   string
+		uses   bool
+	}{
+		{"shop", "SELECT * FROM `orders` WHERE `customer_id` = ?", true},
+		{"audit", "SELECT * FROM `orders` WHERE `customer_id` = ?", false},
+		{"audit", "SELECT * FROM `shop` . `orders` WHERE `customer_id` = ?", true},
+		{"shop", "SELECT * FROM `orders_archive`", false},
+	}
+
+	for _, test := range tests {
+		if got := usesTable(test.schema, test.text, "shop", "orders"); got != test.uses {
+			t.Errorf("usesTable(%q, %q, shop, orders) = %v, want %v", test.schema, test.text, got, test.uses)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	initial := Rows{
+		{source: sourceIO, name: "fetch", latency: 1000, ops: 10},
+		{source: sourceFile, name: "orders.ibd", latency: 5000, ops: 50},
+	}
+	current := Rows{
+		{source: sourceIO, name: "fetch", latency: 1500, ops: 12},
+		{source: sourceFile, name: "orders.ibd", latency: 300, ops: 3}, // recreated
+		{source: sourceIndex, name: "PRIMARY", latency: 700, ops: 7},   // new
+	}
+
+	current.subtract(initial)
+	want := Rows{
+		{source: sourceIO, name: "fetch", latency: 500, ops: 2},
+		{source: sourceFile, name: "orders.ibd", latency: 300, ops: 3},
+		{source: sourceIndex, name: "PRIMARY", latency: 700, ops: 7},
+	}
+	for i := range want {
+		if current[i] != want[i] {
+			t.Errorf("subtract()[%d] = %+v, want %+v", i, current[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package watch_table shows everything known about a single table on
+// one screen: its I/O by operation and by index, its lock waits, the
+// file I/O of its tablespace and the statement digests which use it.
+package watch_table
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/baseobject"
+	"github.com/sjmudd/ps-top/context"
+	"github.com/sjmudd/ps-top/logger"
+)
+
+// Object holds the rows collected about the table being watched
+type Object struct {
+	baseobject.BaseObject        // embedded
+	schema                string // schema of the table watched
+	table                 string // name of the table watched
+	initial               Rows   // initial data for relative values
+	current               Rows   // last loaded values
+	results               Rows   // results (maybe with subtraction)
+}
+
+// NewWatchTable returns a pointer to an object watching the table
+// given as db.table
+func NewWatchTable(ctx *context.Context, name string) (*Object, error) {
+	logger.Println("NewWatchTable(", name, ")")
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("the table to watch %q should be given as db.table", name)
+	}
+	o := new(Object)
+	o.SetContext(ctx)
+	o.schema, o.table = parts[0], parts[1]
+
+	return o, nil
+}
+
+// Collect collects the rows of each source about the table and then
+// subtracts the initial values if we want relative values
+func (t *Object) Collect(dbh *sql.DB) error {
+	start := time.Now()
+
+	rows, err := selectRows(dbh, t.schema, t.table)
+	if err != nil {
+		return err
+	}
+	t.current = rows
+	t.SetLastCollectTimeNow()
+
+	if len(t.initial) == 0 && len(t.current) > 0 {
+		logger.Println("t.initial: copying from t.current (initial setup)")
+		t.copyCurrentToInitial()
+	}
+	t.makeResults()
+
+	logger.Println("watch_table.Object.Collect() END, took:", time.Duration(time.Since(start)).String())
+
+	return nil
+}
+
+func (t *Object) copyCurrentToInitial() {
+	t.initial = append(t.initial[:0], t.current...)
+	t.SetInitialCollectTime(t.LastCollectTime())
+}
+
+func (t *Object) makeResults() {
+	t.results = append(t.results[:0], t.current...)
+	if t.WantRelativeStats() {
+		t.results.subtract(t.initial)
+	}
+	t.results.sort()
+}
+
+// SetInitialFromCurrent resets the statistics to current values
+func (t *Object) SetInitialFromCurrent() {
+	t.copyCurrentToInitial()
+
+	t.makeResults()
+}
+
+// Headings returns the headings for the view
+func (t Object) Headings() string {
+	var r Row
+
+	return r.headings()
+}
+
+// RowContent returns the rows of each source in turn
+func (t Object) RowContent() []string {
+	totals := t.results.totalsBySource()
+	rows := make([]string, 0, len(t.results))
+
+	for i := range t.results {
+		rows = append(rows, t.results[i].rowContent(totals[t.results[i].source]))
+	}
+
+	return rows
+}
+
+// TotalRowContent returns the table I/O of the table, which includes
+// the time spent using its indexes
+func (t Object) TotalRowContent() string {
+	totals := t.results.totalsBySource()[sourceIO]
+	totals.name = "Totals (table I/O)"
+
+	return totals.rowContent(totals)
+}
+
+// EmptyRowContent returns an empty row
+func (t Object) EmptyRowContent() string {
+	var e Row
+
+	return e.rowContent(e)
+}
+
+// Description describes the table watched
+func (t Object) Description() string {
+	return fmt.Sprintf("Watching table %s.%s: I/O, indexes, locks, files and digests", t.schema, t.table)
+}
+
+// Len returns the number of rows
+func (t Object) Len() int {
+	return len(t.results)
+}
+
+// HaveRelativeStats is true for this object
+func (t Object) HaveRelativeStats() bool {
+	return true
+}
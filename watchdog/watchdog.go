@@ -0,0 +1,351 @@
+// Package watchdog checks the running statements against rules
+// configured in the [watchdog] section of ~/.pstoprc and highlights,
+// logs or kills those which match, e.g.
+// [watchdog]
+// long_reports = user=report command=Query time>300 info=^SELECT action=kill
+// Each rule is a list of conditions which must all match:
+// user, host, db and command match the whole value (as a regular
+// expression), info matches any part of the statement and time> gives
+// the number of seconds it must have been running for longer than. The action is
+// highlight (the default), log or kill. Statements are only killed
+// with --enforce, otherwise the kill is logged as one which would
+// have been made. A kill rule needs a user, host, db, info or time
+// condition so it can't kill every running statement.
+package watchdog
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjmudd/ps-top/logger"
+	"github.com/sjmudd/ps-top/rc"
+	"github.com/sjmudd/ps-top/session_log"
+)
+
+// Action is what is done with a statement matching a rule
+type Action int
+
+// the actions
+const (
+	Highlight Action = iota // show the match on the screen
+	Log                     // also write it to the audit log
+	Kill                    // also kill the statement (with --enforce)
+)
+
+var actionNames = map[string]Action{"highlight": Highlight, "log": Log, "kill": Kill}
+
+// String returns the name of the action
+func (a Action) String() string {
+	for name, action := range actionNames {
+		if action == a {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// Rule describes the statements to watch for
+type Rule struct {
+	Name    string
+	user    *regexp.Regexp
+	host    *regexp.Regexp
+	db      *regexp.Regexp
+	command *regexp.Regexp
+	info    *regexp.Regexp
+	minTime uint64 // seconds
+	timed   bool   // is minTime given?
+	Action  Action
+}
+
+// Connection describes a connection and the statement it is running
+type Connection struct {
+	ID      uint64
+	User    string
+	Host    string
+	DB      string
+	Command string
+	Time    uint64 // seconds
+	Info    string
+}
+
+// ParseRule returns the rule with the given name and definition.
+// Only running statements (command=Query) are matched unless a command
+// is given.
+func ParseRule(name, definition string) (Rule, error) {
+	r := Rule{Name: name, command: regexp.MustCompile("^(?:Query)$")}
+
+	for _, condition := range strings.Fields(definition) {
+		if strings.HasPrefix(condition, "time>") {
+			seconds, err := strconv.ParseUint(strings.TrimPrefix(condition, "time>"), 10, 64)
+			if err != nil {
+				return Rule{}, fmt.Errorf("rule %s: invalid time in %q", name, condition)
+			}
+			r.minTime = seconds
+			r.timed = true
+			continue
+		}
+
+		equals := strings.Index(condition, "=")
+		if equals < 1 {
+			return Rule{}, fmt.Errorf("rule %s: expected <name>=<value> or time>seconds but got %q", name, condition)
+		}
+		key, value := condition[:equals], condition[equals+1:]
+		if key == "action" {
+			action, ok := actionNames[value]
+			if !ok {
+				return Rule{}, fmt.Errorf("rule %s: unknown action %q, expected highlight, log or kill", name, value)
+			}
+			r.Action = action
+			continue
+		}
+
+		pattern := "^(?:" + value + ")$" // the whole value must match
+		if key == "info" {
+			pattern = value
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("rule %s: %v", name, err)
+		}
+		switch key {
+		case "user":
+			r.user = re
+		case "host":
+			r.host = re
+		case "db":
+			r.db = re
+		case "command":
+			r.command = re
+		case "info":
+			r.info = re
+		default:
+			return Rule{}, fmt.Errorf("rule %s: unknown condition %q", name, key)
+		}
+	}
+	if r.Action == Kill && r.user == nil && r.host == nil && r.db == nil && r.info == nil && !r.timed {
+		return Rule{}, fmt.Errorf("rule %s: action=kill needs a user, host, db, info or time condition", name)
+	}
+
+	return r, nil
+}
+
+// matches returns true if the value matches the condition (if any)
+func matches(re *regexp.Regexp, value string) bool {
+	return re == nil || re.MatchString(value)
+}
+
+// Matches returns true if the connection matches all the conditions of the rule
+func (r Rule) Matches(c Connection) bool {
+	return (!r.timed || c.Time > r.minTime) &&
+		matches(r.user, c.User) &&
+		matches(r.host, c.Host) &&
+		matches(r.db, c.DB) &&
+		matches(r.command, c.Command) &&
+		matches(r.info, c.Info)
+}
+
+// Watchdog checks the connections against the rules
+type Watchdog struct {
+	rules    []Rule
+	enforce  bool                // kill statements matching a kill rule
+	maxKills int                 // most statements killed a minute
+	audit    *session_log.Log    // where matches are logged
+	kills    []time.Time         // when statements were killed in the last minute
+	seen     map[string]bool     // matches already acted on
+	matched  map[string][]uint64 // ids of the connections matching each rule at the last check
+}
+
+// NewWatchdog returns a Watchdog for the rules configured in ~/.pstoprc.
+// Invalid rules are fatal.
+func NewWatchdog(enforce bool, maxKills int, audit *session_log.Log) *Watchdog {
+	w := &Watchdog{
+		enforce:  enforce,
+		maxKills: maxKills,
+		audit:    audit,
+		seen:     make(map[string]bool),
+		matched:  make(map[string][]uint64),
+	}
+
+	for name, definition := range rc.Section("watchdog") {
+		r, err := ParseRule(name, definition)
+		if err != nil {
+			log.Fatal("Invalid watchdog rule in ~/.pstoprc: ", err)
+		}
+		w.rules = append(w.rules, r)
+	}
+	sort.Slice(w.rules, func(i, j int) bool { return w.rules[i].Name < w.rules[j].Name })
+	logger.Println("watchdog.NewWatchdog() found", len(w.rules), "rule(s)")
+
+	return w
+}
+
+// Enabled returns true if any rules are configured
+func (w *Watchdog) Enabled() bool {
+	return len(w.rules) > 0
+}
+
+// the connections other than our own
+const connectionsQuery = `SELECT PROCESSLIST_ID, PROCESSLIST_USER, PROCESSLIST_HOST, PROCESSLIST_DB, PROCESSLIST_COMMAND, PROCESSLIST_TIME, PROCESSLIST_INFO
+FROM performance_schema.threads
+WHERE PROCESSLIST_ID IS NOT NULL
+AND PROCESSLIST_ID <> CONNECTION_ID()`
+
+// selectConnections returns the connections other than our own
+func selectConnections(dbh *sql.DB) ([]Connection, error) {
+	return queryConnections(dbh, connectionsQuery)
+}
+
+// selectConnection returns the connection with the given id as it is
+// now, and false if it has gone
+func selectConnection(dbh *sql.DB, id uint64) (Connection, bool, error) {
+	connections, err := queryConnections(dbh, connectionsQuery+"\nAND PROCESSLIST_ID = ?", id)
+	if err != nil || len(connections) == 0 {
+		return Connection{}, false, err
+	}
+
+	return connections[0], true, nil
+}
+
+// queryConnections returns the connections found by the query
+func queryConnections(dbh *sql.DB, query string, args ...interface{}) ([]Connection, error) {
+	rows, err := dbh.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var connections []Connection
+	for rows.Next() {
+		var c Connection
+		var user, host, db, command, info sql.NullString
+		var seconds sql.NullInt64
+		if err := rows.Scan(&c.ID, &user, &host, &db, &command, &seconds, &info); err != nil {
+			return nil, err
+		}
+		c.User, c.Host, c.DB, c.Command, c.Info = user.String, host.String, db.String, command.String, info.String
+		if seconds.Int64 > 0 {
+			c.Time = uint64(seconds.Int64)
+		}
+		connections = append(connections, c)
+	}
+
+	return connections, rows.Err()
+}
+
+// mayKill returns true if another statement may be killed without
+// going over the limit of kills a minute
+func (w *Watchdog) mayKill(now time.Time) bool {
+	recent := w.kills[:0]
+	for _, t := range w.kills {
+		if now.Sub(t) < time.Minute {
+			recent = append(recent, t)
+		}
+	}
+	w.kills = recent
+
+	return len(w.kills) < w.maxKills
+}
+
+// describe returns the details of the connection for the audit log
+func describe(c Connection) string {
+	return fmt.Sprintf("id=%d user=%s host=%s db=%s time=%d info=%q", c.ID, c.User, c.Host, c.DB, c.Time, c.Info)
+}
+
+// act logs, and kills if wanted, a statement matching a rule for the
+// first time. It returns false if the statement was not killed when it
+// should have been so it is checked again.
+func (w *Watchdog) act(dbh *sql.DB, r Rule, c Connection) bool {
+	switch {
+	case r.Action == Log:
+		w.audit.Record("watchdog", r.Name, "matched", describe(c))
+	case r.Action == Kill && !w.enforce:
+		w.audit.Record("watchdog", r.Name, "would_kill", describe(c))
+	case r.Action == Kill && !w.mayKill(time.Now()):
+		w.audit.Record("watchdog", r.Name, "not_killed", fmt.Sprintf("limit of %d kills a minute reached", w.maxKills), describe(c))
+		return false
+	case r.Action == Kill:
+		// the connection may have moved on since it was checked
+		now, found, err := selectConnection(dbh, c.ID)
+		if err != nil {
+			w.audit.Record("watchdog", r.Name, "kill_failed", err.Error(), describe(c))
+			return false
+		}
+		if !found || now.Info != c.Info || !r.Matches(now) {
+			w.audit.Record("watchdog", r.Name, "not_killed", "the statement has changed", describe(c))
+			return false
+		}
+		if _, err := dbh.Exec(fmt.Sprintf("KILL QUERY %d", c.ID)); err != nil {
+			w.audit.Record("watchdog", r.Name, "kill_failed", err.Error(), describe(c))
+			return false
+		}
+		w.kills = append(w.kills, time.Now())
+		w.audit.Record("watchdog", r.Name, "killed", describe(c))
+	}
+
+	return true
+}
+
+// Check compares the connections with the rules, acting on the
+// statements which match a rule for the first time, or which were not
+// killed at an earlier check
+func (w *Watchdog) Check(dbh *sql.DB) error {
+	connections, err := selectConnections(dbh)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	matched := make(map[string][]uint64)
+	for _, r := range w.rules {
+		for _, c := range connections {
+			if !r.Matches(c) {
+				continue
+			}
+			matched[r.Name] = append(matched[r.Name], c.ID)
+			// the same statement stays matched while it runs
+			key := fmt.Sprintf("%s/%d/%s", r.Name, c.ID, c.Info)
+			if w.seen[key] {
+				seen[key] = true
+				continue
+			}
+			logger.Println("watchdog.Check() rule", r.Name, "matched", describe(c))
+			if w.act(dbh, r, c) {
+				seen[key] = true
+			}
+		}
+	}
+	w.seen = seen
+	w.matched = matched
+
+	return nil
+}
+
+// Matched returns true if a statement matched a rule at the last check
+func (w *Watchdog) Matched() bool {
+	return len(w.matched) > 0
+}
+
+// Summary describes the matches of the last check, e.g.
+// "long_reports(kill): 101 102", empty if there were none
+func (w *Watchdog) Summary() string {
+	var summary []string
+	for _, r := range w.rules {
+		ids, found := w.matched[r.Name]
+		if !found {
+			continue
+		}
+		s := make([]string, len(ids))
+		for i := range ids {
+			s[i] = strconv.FormatUint(ids[i], 10)
+		}
+		summary = append(summary, r.Name+"("+r.Action.String()+"): "+strings.Join(s, " "))
+	}
+
+	return strings.Join(summary, ", ")
+}
@@ -0,0 +1,94 @@
+package watchdog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, definition := range []string{
+		"user=report time>soon",
+		"user",
+		"action=panic",
+		"colour=red",
+		"info=(",
+		"action=kill",
+		"command=Sleep action=kill",
+	} {
+		if _, err := ParseRule("bad", definition); err == nil {
+			t.Errorf("ParseRule(%q) expected an error", definition)
+		}
+	}
+
+	r, err := ParseRule("reports", "user=report time>300 info=^SELECT action=kill")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+	if r.Action != Kill {
+		t.Errorf("expected action kill but got %v", r.Action)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	r, err := ParseRule("reports", "user=report time>300 info=^SELECT")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+	long := Connection{ID: 1, User: "report", Command: "Query", Time: 301, Info: "SELECT * FROM t"}
+
+	tests := []struct {
+		c       Connection
+		matched bool
+	}{
+		{long, true},
+		{Connection{ID: 2, User: "report", Command: "Query", Time: 299, Info: "SELECT 1"}, false},
+		{Connection{ID: 6, User: "report", Command: "Query", Time: 300, Info: "SELECT 1"}, false}, // not more than 300
+		{Connection{ID: 3, User: "reports", Command: "Query", Time: 400, Info: "SELECT 1"}, false},
+		{Connection{ID: 4, User: "report", Command: "Sleep", Time: 400}, false},
+		{Connection{ID: 5, User: "report", Command: "Query", Time: 400, Info: "UPDATE t SET a = 1"}, false},
+	}
+	for _, test := range tests {
+		if matched := r.Matches(test.c); matched != test.matched {
+			t.Errorf("Matches(%+v) expected %v but got %v", test.c, test.matched, matched)
+		}
+	}
+}
+
+func TestMatchesWithoutTime(t *testing.T) {
+	r, err := ParseRule("reports", "user=report")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+	if c := (Connection{ID: 1, User: "report", Command: "Query"}); !r.Matches(c) {
+		t.Errorf("Matches(%+v) expected true for a statement which has just started", c)
+	}
+}
+
+func TestMayKill(t *testing.T) {
+	w := &Watchdog{maxKills: 2}
+	now := time.Now()
+	w.kills = []time.Time{now.Add(-2 * time.Minute), now.Add(-30 * time.Second)}
+	if !w.mayKill(now) {
+		t.Errorf("mayKill() expected true with one kill in the last minute")
+	}
+	w.kills = append(w.kills, now)
+	if w.mayKill(now) {
+		t.Errorf("mayKill() expected false with two kills in the last minute")
+	}
+}
+
+func TestActNotDone(t *testing.T) {
+	kill, err := ParseRule("reports", "user=report action=kill")
+	if err != nil {
+		t.Fatalf("ParseRule() failed: %v", err)
+	}
+	c := Connection{ID: 1, User: "report", Command: "Query", Info: "SELECT 1"}
+
+	if w := (&Watchdog{}); !w.act(nil, kill, c) {
+		t.Errorf("act() expected the kill logged without --enforce to be done")
+	}
+	// the kill is refused by the limit so the statement must be checked again
+	if w := (&Watchdog{enforce: true, maxKills: 0}); w.act(nil, kill, c) {
+		t.Errorf("act() expected a kill refused by the limit not to be done")
+	}
+}
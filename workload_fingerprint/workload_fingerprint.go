@@ -0,0 +1,123 @@
+// Package workload_fingerprint summarises the workload of a period,
+// since the statistics were reset or marked, as a small JSON document:
+// the heaviest statement digests and tables with their share of the
+// latency and the balance of reads and writes. Fingerprints taken in
+// different environments, or before and after a migration, can then be
+// compared to see whether the workload is the same.
+package workload_fingerprint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+// Top is the number of digests and tables kept
+const Top = 10
+
+// Share is the part of the workload of a digest or table
+type Share struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"` // share of the latency, from 0 to 1
+	Count  uint64  `json:"count"`  // statements run or rows accessed
+}
+
+// ReadWrite is the balance of the rows read and written
+type ReadWrite struct {
+	Reads            uint64  `json:"reads"`              // rows fetched
+	Writes           uint64  `json:"writes"`             // rows inserted, updated or deleted
+	ReadRatio        float64 `json:"read_ratio"`         // share of the rows which were read
+	ReadLatencyRatio float64 `json:"read_latency_ratio"` // share of the latency spent reading
+}
+
+// Fingerprint summarises the workload of a period
+type Fingerprint struct {
+	Time       time.Time `json:"time"`
+	Host       string    `json:"host"`
+	Version    string    `json:"version"`
+	Title      string    `json:"title,omitempty"`
+	Seconds    float64   `json:"seconds"`    // length of the period
+	Statements uint64    `json:"statements"` // statements run in the period
+	Digests    []Share   `json:"digests"`
+	Tables     []Share   `json:"tables"`
+	ReadWrite  ReadWrite `json:"read_write"`
+}
+
+// round keeps 4 decimal places, which is plenty to compare workloads
+func round(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
+
+// ratio returns part / total, 0 if the total is 0
+func ratio(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round(float64(part) / float64(total))
+}
+
+// top returns the rows with the most latency, heaviest first, and their
+// share of the latency of all the rows
+func top(rows []ps_table.RowValues, count string) []Share {
+	var total uint64
+	for i := range rows {
+		total += rows[i].Values["sum_timer_wait"]
+	}
+
+	shares := []Share{}
+	for i := range rows {
+		if latency := rows[i].Values["sum_timer_wait"]; latency > 0 {
+			shares = append(shares, Share{Name: rows[i].Name, Weight: ratio(latency, total), Count: rows[i].Values[count]})
+		}
+	}
+	sort.SliceStable(shares, func(i, j int) bool {
+		if shares[i].Weight != shares[j].Weight {
+			return shares[i].Weight > shares[j].Weight
+		}
+		return shares[i].Name < shares[j].Name
+	})
+	if len(shares) > Top {
+		shares = shares[:Top]
+	}
+
+	return shares
+}
+
+// New returns the fingerprint of the digests and table I/O of a period
+func New(digests, tables []ps_table.RowValues) Fingerprint {
+	f := Fingerprint{
+		Digests: top(digests, "count_star"),
+		Tables:  top(tables, "count_star"),
+	}
+
+	for i := range digests {
+		f.Statements += digests[i].Values["count_star"]
+	}
+
+	var readLatency, latency uint64
+	for i := range tables {
+		v := tables[i].Values
+		f.ReadWrite.Reads += v["count_fetch"]
+		f.ReadWrite.Writes += v["count_insert"] + v["count_update"] + v["count_delete"]
+		readLatency += v["sum_timer_fetch"]
+		latency += v["sum_timer_wait"]
+	}
+	f.ReadWrite.ReadRatio = ratio(f.ReadWrite.Reads, f.ReadWrite.Reads+f.ReadWrite.Writes)
+	f.ReadWrite.ReadLatencyRatio = ratio(readLatency, latency)
+
+	return f
+}
+
+// Write writes the fingerprint to the file, replacing what was there
+func (f Fingerprint) Write(path string) error {
+	content, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
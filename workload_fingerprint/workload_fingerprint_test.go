@@ -0,0 +1,46 @@
+package workload_fingerprint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjmudd/ps-top/p_s/ps_table"
+)
+
+func TestNew(t *testing.T) {
+	digests := []ps_table.RowValues{
+		{Name: "shop/aaa", Values: map[string]uint64{"count_star": 10, "sum_timer_wait": 100}},
+		{Name: "shop/bbb", Values: map[string]uint64{"count_star": 30, "sum_timer_wait": 300}},
+		{Name: "shop/ccc", Values: map[string]uint64{"count_star": 5, "sum_timer_wait": 0}},
+	}
+	tables := []ps_table.RowValues{
+		{Name: "shop.orders", Values: map[string]uint64{"count_star": 80, "sum_timer_wait": 600, "count_fetch": 60, "sum_timer_fetch": 300, "count_insert": 20}},
+		{Name: "shop.stock", Values: map[string]uint64{"count_star": 20, "sum_timer_wait": 200, "count_fetch": 15, "sum_timer_fetch": 100, "count_update": 5}},
+	}
+
+	f := New(digests, tables)
+
+	if f.Statements != 45 {
+		t.Errorf("New() counted %d statements, expected 45", f.Statements)
+	}
+	expectedDigests := []Share{{"shop/bbb", 0.75, 30}, {"shop/aaa", 0.25, 10}}
+	if !reflect.DeepEqual(f.Digests, expectedDigests) {
+		t.Errorf("New() digests %v, expected %v", f.Digests, expectedDigests)
+	}
+	expectedTables := []Share{{"shop.orders", 0.75, 80}, {"shop.stock", 0.25, 20}}
+	if !reflect.DeepEqual(f.Tables, expectedTables) {
+		t.Errorf("New() tables %v, expected %v", f.Tables, expectedTables)
+	}
+	expectedReadWrite := ReadWrite{Reads: 75, Writes: 25, ReadRatio: 0.75, ReadLatencyRatio: 0.5}
+	if f.ReadWrite != expectedReadWrite {
+		t.Errorf("New() read/write %+v, expected %+v", f.ReadWrite, expectedReadWrite)
+	}
+}
+
+func TestNewEmpty(t *testing.T) {
+	f := New(nil, nil)
+
+	if len(f.Digests) != 0 || f.Digests == nil || f.ReadWrite.ReadRatio != 0 {
+		t.Errorf("New(nil, nil) = %+v, expected empty (but not null) lists and no ratios", f)
+	}
+}